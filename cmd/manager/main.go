@@ -19,9 +19,20 @@ import (
 	"time"
 
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/actuator"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/credentials"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/debug"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/managers/vm"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/powerschedule"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/recommender"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/repair"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/resync"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/rollingrestart"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/teardown"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/webhook"
 	mapiv1beta1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 	"github.com/openshift/machine-api-operator/pkg/controller/machine"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -40,6 +51,16 @@ func main() {
 	//klog.InitFlags(nil)
 
 	watchNamespace := flag.String("namespace", "", "Namespace that the controller watches to reconcile machine-api objects. If unspecified, the controller watches for machine-api objects across all namespaces.")
+	metricsAddress := flag.String("metrics-addr", "0", "Address the metrics endpoint binds to, e.g. :8081. Metrics serving is disabled when \"0\".")
+	enableRecommender := flag.Bool("enable-recommender", false, "Recommend a size for the VMs of every machineset from the node usage metrics, written to the machineset annotations.")
+	recommenderInterval := flag.Duration("recommender-interval", 10*time.Minute, "How often the recommender refreshes its recommendations.")
+	infraKubeconfig := flag.String("infra-kubeconfig", os.Getenv("INFRA_KUBECONFIG"), "Path of the underkube kubeconfig file. When set, it is used for all the machines instead of their UnderKubeconfigSecretName secret. Defaults to the INFRA_KUBECONFIG environment variable.")
+	infraInCluster := flag.Bool("infra-in-cluster", false, "Create the VMs on the management cluster itself, using the manager credentials instead of an underkube kubeconfig.")
+	debugAddress := flag.String("debug-addr", "", "Address the debug endpoints bind to: pprof, metrics, the runtime tuning of the log verbosity and of the controllers concurrency, the resync of machines, and the SSH tunnels to the machines. They are not authenticated, bind them to localhost. Disabled when empty.")
+	webhookPort := flag.Int("webhook-port", 0, "Port the admission webhook validating the machines listens on, the ValidatingWebhookConfiguration of the machines must call its /validate-machine path. Disabled when 0.")
+	webhookCertDir := flag.String("webhook-cert-dir", "", "Directory holding the tls.crt and tls.key of the admission webhook, defaults to the controller-runtime one.")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 20*time.Second, "How long the Create and Delete operations in flight are given to finish on shutdown, the ones still running then are recorded as interrupted on their machine. Keep it below the termination grace period of the pod.")
+	credentialsMaxConcurrency := flag.Int("credentials-max-concurrency", 10, "Maximum number of secrets the credentials controller can reconcile at once, once raised through the KubevirtProviderConfig or the debug endpoints. It reconciles one at a time until then.")
 	// TODO Remove this flag when stable
 	flag.Set("logtostderr", "true")
 	flag.Parse()
@@ -57,9 +78,13 @@ func main() {
 	// No need to setup "SyncPeriod: &syncPeriod" because there is no reconciled instance implemented
 	syncPeriod := 10 * time.Minute
 	opts := manager.Options{
-		SyncPeriod: &syncPeriod,
-		// Disable metrics serving
-		MetricsBindAddress: "0",
+		SyncPeriod:         &syncPeriod,
+		MetricsBindAddress: *metricsAddress,
+	}
+
+	if *webhookPort != 0 {
+		opts.Port = *webhookPort
+		opts.CertDir = *webhookCertDir
 	}
 
 	if *watchNamespace != "" {
@@ -77,6 +102,9 @@ func main() {
 	if err := mapiv1beta1.AddToScheme(mgr.GetScheme()); err != nil {
 		klog.Fatalf("Error setting up scheme: %v", err)
 	}
+	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
+		klog.Fatalf("Error setting up scheme: %v", err)
+	}
 
 	// Initialize overkube clients
 	kubernetesClient, err := overkube.New(mgr)
@@ -84,8 +112,42 @@ func main() {
 		entryLog.Error(err, "Failed to create overkube client from configuration")
 	}
 
-	// Initialize provider vm manager (underkubeClientBuilder would be the function underkube.New)
-	providerVM := vm.New(underkube.New, kubernetesClient)
+	// The underkube clients are cached per credentials secret, the credentials controller invalidates
+	// them when their secret changes. A kubeconfig file isn't watched, its client is rebuilt when the
+	// underkube refuses its credentials.
+	// In the in-cluster mode, a single client using the manager credentials is shared by all the machines.
+	var underkubeClientBuilder underkube.ClientBuilderFuncType
+	limiters := map[string]*debug.Limiter{}
+	providerConfig := providerconfig.NewStore()
+	switch {
+	case *infraInCluster && *infraKubeconfig != "":
+		klog.Fatalf("--infra-in-cluster and --infra-kubeconfig are mutually exclusive")
+	case *infraInCluster:
+		klog.Infof("Creating the VMs on the management cluster")
+		underkubeClientBuilder, err = underkube.NewInCluster(cfg)
+		if err != nil {
+			klog.Fatalf("Error creating the in-cluster KubeVirt client: %v", err)
+		}
+	case *infraKubeconfig != "":
+		klog.Infof("Using the underkube kubeconfig file %s for all the machines", *infraKubeconfig)
+		underkubeClientBuilder = underkube.NewClientCache(underkube.NewFromFile(*infraKubeconfig)).Get
+	default:
+		clientCache := underkube.NewClientCache(underkube.New)
+		limiters[credentials.ControllerName] = debug.NewLimiter(1, *credentialsMaxConcurrency)
+		if err := credentials.Add(mgr, kubernetesClient, clientCache, limiters[credentials.ControllerName], providerConfig); err != nil {
+			klog.Fatalf("Error adding the credentials controller: %v", err)
+		}
+		underkubeClientBuilder = clientCache.Get
+	}
+
+	// The cluster wide KubevirtProviderConfig is read at runtime, the credentials limiter is nil outside of
+	// the secrets mode
+	if err := providerconfig.Add(mgr, providerConfig, limiters[credentials.ControllerName]); err != nil {
+		klog.Fatalf("Error adding the provider config controller: %v", err)
+	}
+
+	// Initialize provider vm manager
+	providerVM := vm.New(underkubeClientBuilder, kubernetesClient, providerConfig, !*infraInCluster && *infraKubeconfig == "")
 
 	// Initialize machine actuator.
 	machineActuator := actuator.New(providerVM, mgr.GetEventRecorderFor("kubevirtcontroller"))
@@ -95,8 +157,60 @@ func main() {
 		klog.Fatalf("Error adding actuator: %v", err)
 	}
 
+	// Failed machines whose VM still exists get their providerID repaired and are reconciled again
+	if err := repair.Add(mgr, providerVM); err != nil {
+		klog.Fatalf("Error adding the providerID repair controller: %v", err)
+	}
+
+	if *webhookPort != 0 {
+		webhook.Add(mgr, providerConfig)
+	}
+
+	// The integrations running in the manager force the resync of machines through the trigger, the other
+	// processes through the debug endpoints or its annotation on the machines
+	resyncTrigger := resync.New(mgr.GetClient(), providerConfig)
+	if err := mgr.Add(resyncTrigger); err != nil {
+		klog.Fatalf("Error adding the resync trigger: %v", err)
+	}
+
+	if *enableRecommender {
+		if err := mgr.Add(recommender.New(mgr.GetClient(), *recommenderInterval)); err != nil {
+			klog.Fatalf("Error adding recommender: %v", err)
+		}
+	}
+
+	// The power scheduler only acts on the machinesets with a power schedule
+	if err := mgr.Add(powerschedule.New(mgr.GetClient())); err != nil {
+		klog.Fatalf("Error adding power scheduler: %v", err)
+	}
+
+	// The restarter only acts on the machinesets with a pending rolling restart
+	if err := mgr.Add(rollingrestart.New(mgr.GetClient())); err != nil {
+		klog.Fatalf("Error adding rolling restarter: %v", err)
+	}
+
+	// The teardown coordinator only acts on the clusters whose teardown was requested
+	if err := mgr.Add(teardown.New(mgr.GetClient(), mgr.GetAPIReader(), *watchNamespace, kubernetesClient, underkubeClientBuilder, providerConfig)); err != nil {
+		klog.Fatalf("Error adding teardown coordinator: %v", err)
+	}
+
+	if *debugAddress != "" {
+		if err := mgr.Add(debug.New(*debugAddress, limiters, providerVM, func(namespace, name, secretName string) {
+			resyncTrigger.Resync(resync.Request{Namespace: namespace, Name: name, SecretName: secretName})
+		})); err != nil {
+			klog.Fatalf("Error adding debug server: %v", err)
+		}
+	}
+
 	// Start the Cmd
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		klog.Fatalf("Error starting manager: %v", err)
 	}
+
+	// The controllers stopped on the termination signal, the operations they started get some time to
+	// finish before the process exits
+	klog.Infof("Draining the machine operations in flight")
+	if !machineActuator.Drain(*shutdownTimeout) {
+		klog.Warningf("Machine operations still in flight after %v, recorded as interrupted", *shutdownTimeout)
+	}
 }
@@ -8,10 +8,14 @@ require (
 	github.com/gogo/protobuf v1.3.1
 	github.com/golang/mock v1.2.0
 	github.com/google/gofuzz v1.1.0
+	github.com/gorilla/websocket v1.4.0
 	github.com/openshift/custom-resource-status v0.0.0-20190822192428-e62f2f3b79f3
 	github.com/openshift/machine-api-operator v0.2.1-0.20200402110321-4f3602b96da3
 	github.com/pborman/uuid v1.2.0
+	github.com/prometheus/client_golang v1.1.0
+	github.com/prometheus/client_model v0.0.0-20190129233127-fd36f4220a90
 	golang.org/x/crypto v0.0.0-20191205180655-e7c4368fe9dd // indirect
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
 	gopkg.in/inf.v0 v0.9.1
 	gotest.tools v2.2.0+incompatible
 	k8s.io/api v0.18.3
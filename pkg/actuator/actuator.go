@@ -20,12 +20,16 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/managers/vm"
 )
 
@@ -36,12 +40,16 @@ const (
 	updateEventAction = "Update"
 	deleteEventAction = "Delete"
 	noEventAction     = ""
+	// credentialsRequeueAfter is the delay before syncing a machine with invalid underkube credentials
+	// again, retrying sooner is pointless until someone fixes the secret
+	credentialsRequeueAfter = 5 * time.Minute
 )
 
 // Actuator is responsible for performing machine reconciliation.
 type Actuator struct {
 	eventRecorder record.EventRecorder
 	providerVM    vm.ProviderVM
+	operations    operations
 }
 
 // New returns an actuator.
@@ -59,6 +67,18 @@ func (a *Actuator) handleMachineError(machine *machinev1.Machine, err error, eve
 	if eventAction != noEventAction {
 		a.eventRecorder.Eventf(machine, corev1.EventTypeWarning, "Failed"+eventAction, "%v", err)
 	}
+	if underkube.IsCredentialsError(err) {
+		switch eventAction {
+		case createEventAction:
+			// The machine controller fails the machine on an invalid configuration
+			return machinecontroller.InvalidMachineConfiguration("%v", err)
+		case updateEventAction:
+			return &machinecontroller.RequeueAfterError{RequeueAfter: credentialsRequeueAfter}
+		}
+	}
+	if underkube.IsInfraNotReady(err) && eventAction == createEventAction {
+		return machinecontroller.InvalidMachineConfiguration("%v", err)
+	}
 	return err
 }
 
@@ -66,6 +86,11 @@ func (a *Actuator) handleMachineError(machine *machinev1.Machine, err error, eve
 func (a *Actuator) Create(ctx context.Context, machine *machinev1.Machine) error {
 	klog.Infof("%s: actuator creating machine", vm.GetMachineName(machine))
 
+	if !a.operations.begin(machine, kubevirtproviderv1.CreateOperation) {
+		return errShuttingDown(machine, kubevirtproviderv1.CreateOperation)
+	}
+	defer a.operations.end(machine)
+
 	if err := a.providerVM.Create(machine); err != nil {
 		fmtErr := fmt.Errorf(vmsFailFmt, vm.GetMachineName(machine), createEventAction, err)
 		return a.handleMachineError(machine, fmtErr, createEventAction)
@@ -109,6 +134,11 @@ func (a *Actuator) Update(ctx context.Context, machine *machinev1.Machine) error
 func (a *Actuator) Delete(ctx context.Context, machine *machinev1.Machine) error {
 	klog.Infof("%s: actuator deleting machine", vm.GetMachineName(machine))
 
+	if !a.operations.begin(machine, kubevirtproviderv1.DeleteOperation) {
+		return errShuttingDown(machine, kubevirtproviderv1.DeleteOperation)
+	}
+	defer a.operations.end(machine)
+
 	if err := a.providerVM.Delete(machine); err != nil {
 		fmtErr := fmt.Errorf(vmsFailFmt, vm.GetMachineName(machine), deleteEventAction, err)
 		return a.handleMachineError(machine, fmtErr, deleteEventAction)
@@ -1,10 +1,16 @@
 package actuator
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"gotest.tools/assert"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 )
 
 func init() {
@@ -17,6 +23,55 @@ func TestMachineEvents(t *testing.T) {
 }
 
 func TestHandleMachineErrors(t *testing.T) {
-	// TODO implement
+	credentialsErr := fmt.Errorf("machine-test: %w", &underkube.CredentialsError{Message: "Underkube credentials secret kubeconfig did not contain key kubeconfig"})
+	infraNotReadyErr := fmt.Errorf("machine-test: %w", &underkube.InfraNotReadyError{Message: "Underkube not ready: the virtualmachines API kubevirt.io/v1alpha3 is not served, install KubeVirt on the underkube"})
+	otherErr := errors.New("machine-test: failed to create virtual machine")
 
+	cases := []struct {
+		name        string
+		err         error
+		eventAction string
+		wantErr     error
+	}{
+		{
+			name:        "Fail the creation on invalid credentials",
+			err:         credentialsErr,
+			eventAction: createEventAction,
+			wantErr:     machinecontroller.InvalidMachineConfiguration("%v", credentialsErr),
+		},
+		{
+			name:        "Delay the update on invalid credentials",
+			err:         credentialsErr,
+			eventAction: updateEventAction,
+			wantErr:     &machinecontroller.RequeueAfterError{RequeueAfter: credentialsRequeueAfter},
+		},
+		{
+			name:        "Keep the deletion error",
+			err:         credentialsErr,
+			eventAction: deleteEventAction,
+			wantErr:     credentialsErr,
+		},
+		{
+			name:        "Fail the creation when the underkube is not ready",
+			err:         infraNotReadyErr,
+			eventAction: createEventAction,
+			wantErr:     machinecontroller.InvalidMachineConfiguration("%v", infraNotReadyErr),
+		},
+		{
+			name:        "Keep other errors",
+			err:         otherErr,
+			eventAction: createEventAction,
+			wantErr:     otherErr,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(1)
+			a := New(nil, recorder)
+
+			err := a.handleMachineError(&machinev1.Machine{}, tc.err, tc.eventAction)
+			assert.Equal(t, fmt.Sprintf("%T: %v", err, err), fmt.Sprintf("%T: %v", tc.wantErr, tc.wantErr))
+			assert.Equal(t, <-recorder.Events, "Warning Failed"+tc.eventAction+" "+tc.err.Error())
+		})
+	}
 }
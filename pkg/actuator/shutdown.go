@@ -0,0 +1,122 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package actuator
+
+import (
+	"sync"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/managers/vm"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"k8s.io/klog"
+)
+
+// shutdownRequeueAfter is the delay before the machine controller retries an operation refused while the
+// provider shuts down, long enough for the next provider pod to pick the machine up instead
+const shutdownRequeueAfter = 30 * time.Second
+
+// operation is a Create or Delete running in the actuator
+type operation struct {
+	operationType kubevirtproviderv1.OperationType
+	// machine is a copy of the machine when the operation started
+	machine *machinev1.Machine
+}
+
+// operations tracks the in-flight operations, and refuses new ones once draining
+type operations struct {
+	lock     sync.Mutex
+	draining bool
+	inFlight map[string]operation
+	done     sync.WaitGroup
+}
+
+// begin starts tracking the operation on the machine, it returns false when the actuator is draining
+func (o *operations) begin(machine *machinev1.Machine, operationType kubevirtproviderv1.OperationType) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if o.draining {
+		return false
+	}
+	if o.inFlight == nil {
+		o.inFlight = map[string]operation{}
+	}
+	o.inFlight[operationKey(machine)] = operation{operationType: operationType, machine: machine.DeepCopy()}
+	o.done.Add(1)
+	return true
+}
+
+// end stops tracking the operation on the machine
+func (o *operations) end(machine *machinev1.Machine) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	delete(o.inFlight, operationKey(machine))
+	o.done.Done()
+}
+
+// drain refuses the new operations, and waits for the in-flight ones until the timeout. It returns the
+// operations still running then.
+func (o *operations) drain(timeout time.Duration) []operation {
+	o.lock.Lock()
+	o.draining = true
+	o.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		o.done.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	interrupted := make([]operation, 0, len(o.inFlight))
+	for _, op := range o.inFlight {
+		interrupted = append(interrupted, op)
+	}
+	return interrupted
+}
+
+func operationKey(machine *machinev1.Machine) string {
+	return machine.GetNamespace() + "/" + machine.GetName()
+}
+
+// errShuttingDown is returned for the operations refused while draining, the machine controller retries
+// them later
+func errShuttingDown(machine *machinev1.Machine, operationType kubevirtproviderv1.OperationType) error {
+	klog.Infof("%s: provider shutting down, not starting the %s operation", vm.GetMachineName(machine), operationType)
+	return &machinecontroller.RequeueAfterError{RequeueAfter: shutdownRequeueAfter}
+}
+
+// Drain stops the actuator from starting new Create and Delete operations, and gives the in-flight ones
+// until the timeout to finish, so the provider doesn't leave half-created VMs behind when its pod is
+// rescheduled. The operations still running then are recorded as interrupted in the provider status of
+// their machine. It returns true when all the operations finished.
+func (a *Actuator) Drain(timeout time.Duration) bool {
+	interrupted := a.operations.drain(timeout)
+	for _, op := range interrupted {
+		if err := a.providerVM.RecordInterruptedOperation(op.machine, op.operationType); err != nil {
+			klog.Errorf("%s: failed to record the interrupted %s operation: %v", vm.GetMachineName(op.machine), op.operationType, err)
+		}
+	}
+	return len(interrupted) == 0
+}
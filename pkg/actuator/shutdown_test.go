@@ -0,0 +1,81 @@
+package actuator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/managers/vm"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// blockingProviderVM creates and deletes the VMs once released, and records the interrupted operations
+type blockingProviderVM struct {
+	vm.ProviderVM
+	started     chan string
+	release     chan struct{}
+	interrupted []string
+}
+
+func (p *blockingProviderVM) Create(machine *machinev1.Machine) error {
+	p.started <- machine.Name
+	<-p.release
+	return nil
+}
+
+func (p *blockingProviderVM) Delete(machine *machinev1.Machine) error {
+	p.started <- machine.Name
+	<-p.release
+	return nil
+}
+
+func (p *blockingProviderVM) RecordInterruptedOperation(machine *machinev1.Machine, operationType kubevirtproviderv1.OperationType) error {
+	p.interrupted = append(p.interrupted, machine.Name+"/"+string(operationType))
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	cases := []struct {
+		name            string
+		releaseAfter    time.Duration
+		wantDrained     bool
+		wantInterrupted []string
+	}{
+		{
+			name:         "Operations finishing in time",
+			releaseAfter: 10 * time.Millisecond,
+			wantDrained:  true,
+		},
+		{
+			name:            "Operations still running after the timeout",
+			releaseAfter:    time.Second,
+			wantInterrupted: []string{"machine-create/Create"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerVM := &blockingProviderVM{started: make(chan string), release: make(chan struct{})}
+			a := New(providerVM, record.NewFakeRecorder(10))
+
+			created := make(chan error)
+			go func() {
+				created <- a.Create(context.Background(), &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-create"}})
+			}()
+			<-providerVM.started
+			time.AfterFunc(tc.releaseAfter, func() { close(providerVM.release) })
+
+			assert.Equal(t, a.Drain(100*time.Millisecond), tc.wantDrained)
+			assert.DeepEqual(t, providerVM.interrupted, tc.wantInterrupted)
+
+			err := a.Delete(context.Background(), &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-delete"}})
+			_, ok := err.(*machinecontroller.RequeueAfterError)
+			assert.Assert(t, ok, "the operations started while draining are requeued, got %v", err)
+			assert.NilError(t, <-created)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// The deepcopy functions of the KubevirtProviderConfig are written by hand, as the repository doesn't
+// run deepcopy-gen. Keep them in sync with the types.
+
+// DeepCopyInto copies the receiver into out
+func (in *KubevirtProviderConfig) DeepCopyInto(out *KubevirtProviderConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy copies the receiver into a new KubevirtProviderConfig
+func (in *KubevirtProviderConfig) DeepCopy() *KubevirtProviderConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubevirtProviderConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the receiver into a new runtime.Object
+func (in *KubevirtProviderConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *KubevirtProviderConfigSpec) DeepCopyInto(out *KubevirtProviderConfigSpec) {
+	*out = *in
+	if in.CredentialsOverrideSecrets != nil {
+		in, out := &in.CredentialsOverrideSecrets, &out.CredentialsOverrideSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CloneSourceNamespaces != nil {
+		in, out := &in.CloneSourceNamespaces, &out.CloneSourceNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Reconcile != nil {
+		in, out := &in.Reconcile, &out.Reconcile
+		*out = new(ReconcileTuning)
+		**out = **in
+	}
+	if in.Budgets != nil {
+		in, out := &in.Budgets, &out.Budgets
+		*out = make([]ClusterBudget, len(*in))
+		copy(*out, *in)
+	}
+	if in.FaultInjection != nil {
+		in, out := &in.FaultInjection, &out.FaultInjection
+		*out = new(FaultInjection)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy copies the receiver into a new KubevirtProviderConfigSpec
+func (in *KubevirtProviderConfigSpec) DeepCopy() *KubevirtProviderConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KubevirtProviderConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *KubevirtProviderConfigList) DeepCopyInto(out *KubevirtProviderConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KubevirtProviderConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy copies the receiver into a new KubevirtProviderConfigList
+func (in *KubevirtProviderConfigList) DeepCopy() *KubevirtProviderConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(KubevirtProviderConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject copies the receiver into a new runtime.Object
+func (in *KubevirtProviderConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *FaultInjection) DeepCopyInto(out *FaultInjection) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]FaultInjectionRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy copies the receiver into a new FaultInjection
+func (in *FaultInjection) DeepCopy() *FaultInjection {
+	if in == nil {
+		return nil
+	}
+	out := new(FaultInjection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out
+func (in *FaultInjectionRule) DeepCopyInto(out *FaultInjectionRule) {
+	*out = *in
+	if in.Verbs != nil {
+		in, out := &in.Verbs, &out.Verbs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProviderConfigName is the name of the only KubevirtProviderConfig the provider reads
+const ProviderConfigName = "cluster"
+
+// KubevirtProviderConfig is the cluster wide configuration of the provider. It is cluster scoped, and
+// only the one named cluster is used. The provider watches it, so changes apply without a restart.
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type KubevirtProviderConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec KubevirtProviderConfigSpec `json:"spec,omitempty"`
+}
+
+// KubevirtProviderConfigSpec holds the defaults of the provider specs and the tuning of the controllers.
+// Changing a default updates the machines relying on it, as changing their provider spec would.
+type KubevirtProviderConfigSpec struct {
+	// DefaultUnderKubeconfigSecretName is the underkube credentials secret of the machines whose provider
+	// spec doesn't name one, looked up in the namespace of the machine
+	DefaultUnderKubeconfigSecretName string `json:"defaultUnderKubeconfigSecretName,omitempty"`
+	// CredentialsOverrideSecrets are the credentials secrets a machine may be switched to with the
+	// kubevirt.io/underkube-credentials-secret annotation, overriding the secret of its provider spec, until
+	// the machine has a provider ID. The annotation is refused when empty.
+	CredentialsOverrideSecrets []string `json:"credentialsOverrideSecrets,omitempty"`
+	// DefaultStorageClassName is the storage class of the boot volumes whose provider spec doesn't set one.
+	// The default storage class of the underkube is used when both are empty.
+	DefaultStorageClassName string `json:"defaultStorageClassName,omitempty"`
+	// DefaultRequestedMemory is the memory of the VMs whose provider spec doesn't set it, defaults to 2048M
+	DefaultRequestedMemory string `json:"defaultRequestedMemory,omitempty"`
+	// DefaultRequestedStorage is the size of the boot volumes whose provider spec doesn't set it, defaults to 35Gi
+	DefaultRequestedStorage string `json:"defaultRequestedStorage,omitempty"`
+	// DefaultServiceMode is the service mode of the machines whose provider spec doesn't set one, e.g.
+	// None for the deployments that don't want a Service per VM. Defaults to PerMachine.
+	DefaultServiceMode ServiceMode `json:"defaultServiceMode,omitempty"`
+	// ServiceDomain is the DNS domain of the underkube services, in the internal DNS names of the machines.
+	// Defaults to cluster.local.
+	ServiceDomain string `json:"serviceDomain,omitempty"`
+	// CloneSourceNamespaces are the namespaces whose PVCs the provider lets the VM namespaces clone, by binding
+	// the service account of the VM namespace to a role of the source namespace. The clones from any other
+	// namespace need the permission granted beforehand, CDI refuses them otherwise.
+	CloneSourceNamespaces []string `json:"cloneSourceNamespaces,omitempty"`
+	// Reconcile tunes the controllers of the provider
+	Reconcile *ReconcileTuning `json:"reconcile,omitempty"`
+	// Budgets cap the underkube resources the VMs of the tenant clusters use, on top of the resource
+	// quotas of the underkube namespaces
+	Budgets []ClusterBudget `json:"budgets,omitempty"`
+	// FaultInjection makes the underkube requests of the provider fail or slow down on purpose, to exercise
+	// the machine operations against an unreliable underkube in integration tests and staging. Never set it
+	// in production.
+	FaultInjection *FaultInjection `json:"faultInjection,omitempty"`
+}
+
+// FaultInjection lists the faults injected in the underkube requests
+type FaultInjection struct {
+	// Rules are matched in order, the first one matching the verb of a request applies to it
+	Rules []FaultInjectionRule `json:"rules,omitempty"`
+}
+
+// FaultInjectionRule injects faults in a share of the requests of its verbs
+type FaultInjectionRule struct {
+	// Verbs are the HTTP methods of the requests, e.g. POST or DELETE, all of them when empty
+	Verbs []string `json:"verbs,omitempty"`
+	// ErrorPercent is the share of the requests failed with a 503 error without reaching the underkube
+	ErrorPercent int `json:"errorPercent,omitempty"`
+	// PartialFailurePercent is the share of the requests sent to the underkube whose response is replaced by
+	// a 503 error, the underkube applied the change the provider sees failing
+	PartialFailurePercent int `json:"partialFailurePercent,omitempty"`
+	// Latency delays all the requests of the rule before they are sent
+	Latency metav1.Duration `json:"latency,omitempty"`
+}
+
+// ClusterBudget caps the resources of the VMs of a tenant cluster. The machines whose VM would exceed it
+// aren't created. Each limit is ignored when empty.
+type ClusterBudget struct {
+	// ClusterID is the machine.openshift.io/cluster-api-cluster label of the machines of the tenant cluster
+	ClusterID string `json:"clusterID"`
+	// MaxCPU is the total vCPUs of the VMs, a VM without CPU request counts as one vCPU
+	MaxCPU string `json:"maxCPU,omitempty"`
+	// MaxMemory is the total memory of the VMs
+	MaxMemory string `json:"maxMemory,omitempty"`
+	// MaxStorage is the total size of the disks of the VMs
+	MaxStorage string `json:"maxStorage,omitempty"`
+}
+
+// ReconcileTuning tunes the controllers of the provider
+type ReconcileTuning struct {
+	// CredentialsConcurrency is the number of secrets the credentials controller reconciles at once. It is
+	// capped by the --credentials-max-concurrency flag, and defaults to 1.
+	CredentialsConcurrency int `json:"credentialsConcurrency,omitempty"`
+	// MaxConcurrentDeletions is the number of VMs of a tenant cluster deleted at once, the deletion of the
+	// other machines of the cluster waits for one of them to be gone. Unlimited when 0.
+	MaxConcurrentDeletions int `json:"maxConcurrentDeletions,omitempty"`
+}
+
+// KubevirtProviderConfigList is a list of KubevirtProviderConfig
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type KubevirtProviderConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []KubevirtProviderConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KubevirtProviderConfig{}, &KubevirtProviderConfigList{})
+}
@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
 )
 
@@ -26,17 +28,706 @@ type KubevirtMachineProviderSpec struct {
 	SourcePvcName             string `json:"sourcePvcName,omitempty"`
 	SourcePvcNamespace        string `json:"sourcePvcNamespace,omitempty"`
 	UnderKubeconfigSecretName string `json:"underKubeconfigSecretName,omitempty"`
-	RequestedMemory           string `json:"requestedMemory,omitempty"`
-	RequestedCPU              string `json:"requestedCPU,omitempty"`
-	StorageClassName          string `json:"storageClassName,omitempty"`
-	IgnitionSecretName        string `json:"ignitionSecretName,omitempty"`
+	// Namespace of the VM on the underkube. Defaults to the namespace of the kubeconfig context, then to
+	// the cluster ID. Any other namespace must be allowed by the credentials secret. The namespace is
+	// resolved when the VM is created, and recorded in the provider status.
+	Namespace        string `json:"namespace,omitempty"`
+	RequestedMemory  string `json:"requestedMemory,omitempty"`
+	RequestedCPU     string `json:"requestedCPU,omitempty"`
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// RequestedStorage is the size of the root disk. Increasing it expands the disk of the existing
+	// machines, when the storage class allows volume expansion.
+	RequestedStorage   string `json:"requestedStorage,omitempty"`
+	IgnitionSecretName string `json:"ignitionSecretName,omitempty"`
+	// IgnoredFields lists dot separated paths of the VM (e.g. spec.template.metadata.annotations)
+	// the provider never reconciles, so changes made to them on the underkube are kept.
+	IgnoredFields []string `json:"ignoredFields,omitempty"`
+	// Interfaces are the NICs of the VM. The VM gets a single NIC on the pod network when empty.
+	Interfaces []NetworkInterface `json:"interfaces,omitempty"`
+	// NetworkData is the cloud-init network config, version 1 or 2, of the guest, e.g. for its static IPs,
+	// bonds and VLANs. It replaces the network data generated from the interfaces. NetworkDataSecretName
+	// reads it from the networkdata key of a secret of the VM namespace instead.
+	NetworkData           string `json:"networkData,omitempty"`
+	NetworkDataSecretName string `json:"networkDataSecretName,omitempty"`
+	// PrimaryInterface is the name of the interface whose IP is reported as the node internal IP, and
+	// hinted to kubelet. Defaults to the interface with the primary role, or else to the first one.
+	PrimaryInterface string `json:"primaryInterface,omitempty"`
+	// IPFamilies orders the addresses reported on the machine and picks the family of the IP hinted
+	// to kubelet, e.g. [IPv6, IPv4] to register the nodes on IPv6 first
+	IPFamilies []corev1.IPFamily `json:"ipFamilies,omitempty"`
+	// MacAddressAllocation is how the interfaces without static MAC address get theirs, Derived when empty
+	MacAddressAllocation MacAddressAllocation `json:"macAddressAllocation,omitempty"`
+	// HealthCheck probes the readiness of the VM. A VM failing it is removed from the endpoints of
+	// the services selecting it, so load balancers stop sending it traffic.
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+	// Expose publishes ports of the VM outside of the underkube with an Ingress, managed with the machine
+	Expose *Expose `json:"expose,omitempty"`
+	// Deadlines bound the long phases of the machine, which goes Failed when one elapses
+	Deadlines *Deadlines `json:"deadlines,omitempty"`
+	// GPUs are the host GPUs passed through to the VM. The underkube must expose them with a device
+	// plugin, and KubeVirt must permit them.
+	GPUs []GPU `json:"gpus,omitempty"`
+	// MediatedDevices are the mediated devices, e.g. vGPU profiles, of the VM. The underkube must expose
+	// them with a device plugin, the machine fails when no underkube node offers them.
+	MediatedDevices []MediatedDevice `json:"mediatedDevices,omitempty"`
+	// IOThreadsPolicy runs the IO of the disks in IO threads instead of the QEMU main loop, for the storage
+	// heavy workers: shared runs all the disks in one thread, auto spreads them over a pool sized after the
+	// vCPUs. The disks IO runs in the main loop when empty.
+	IOThreadsPolicy IOThreadsPolicy `json:"ioThreadsPolicy,omitempty"`
+	// DedicatedIOThreadDisks lists the disks running their IO in a thread of their own, whatever the policy.
+	// Their bus must be virtio.
+	DedicatedIOThreadDisks []DiskName `json:"dedicatedIOThreadDisks,omitempty"`
+	// DiskBuses set the bus of the boot disk and the config drive, virtio when unset. The legacy images
+	// without virtio drivers boot from sata.
+	DiskBuses []DiskBus `json:"diskBuses,omitempty"`
+	// DiskBootOrders set the boot order of the boot disk, along with the bootOrder of the interfaces, lowest
+	// first, e.g. the boot disk first and a NIC to PXE boot from as fallback. Once a disk or interface
+	// has a boot order, the devices without one aren't booted from, so the boot disk must have one too.
+	// The config drive isn't bootable. The VM boots from its boot disk when none is set.
+	DiskBootOrders []DiskBootOrder `json:"diskBootOrders,omitempty"`
+	// ConfigVolumes attach ConfigMaps and Secrets of the VM namespace to the VM, e.g. the certificates and
+	// the agent configs the guest needs before its node joins the cluster. The VM doesn't start until they
+	// exist.
+	ConfigVolumes []ConfigVolume `json:"configVolumes,omitempty"`
+	// CPU is the model and the feature flags of the CPU of the VM, the KubeVirt default (host-model) when
+	// empty
+	CPU *CPU `json:"cpu,omitempty"`
+	// Hugepages backs the memory of the VM with hugepages, which DPDK workloads need. The underkube
+	// nodes must have hugepages of the page size allocatable, and the requested memory must be a
+	// multiple of it.
+	Hugepages *Hugepages `json:"hugepages,omitempty"`
+	// Firmware is the bootloader of the VM, BIOS by default. The UEFI only images need EFI.
+	Firmware *Firmware `json:"firmware,omitempty"`
+	// RNG attaches a virtio RNG device fed by the entropy of the underkube node, without it the guests may
+	// run out of entropy during the bootstrap and stall on the TLS operations of cloud-init and ignition
+	RNG bool `json:"rng,omitempty"`
+	// AutoattachSerialConsole and AutoattachGraphicsDevice attach the serial console and the VGA device with
+	// its VNC console, they default to true. The hardened images turn the graphics off and keep the serial
+	// console.
+	AutoattachSerialConsole  *bool `json:"autoattachSerialConsole,omitempty"`
+	AutoattachGraphicsDevice *bool `json:"autoattachGraphicsDevice,omitempty"`
+	// AnnotationProfiles expand into the KubeVirt annotations of the VMI they stand for, so the behaviors
+	// they enable don't need the annotations to be known
+	AnnotationProfiles []AnnotationProfile `json:"annotationProfiles,omitempty"`
+	// ServiceMode is how the VM is published in the underkube DNS: PerMachine gives each VM its own
+	// Service, MachineSet puts the VMs of a machineset behind one Service whose endpoints the provider
+	// manages, to avoid a Service per VM in large pools, None creates no Service. Defaults to the
+	// defaultServiceMode of the KubevirtProviderConfig, else PerMachine.
+	ServiceMode ServiceMode `json:"serviceMode,omitempty"`
+	// MachineType is the QEMU machine type of the VM: q35, pc, or a versioned type like pc-q35-rhel8.2.0.
+	// The PCIe passthrough of devices needs q35. Defaults to the KubeVirt default machine type.
+	MachineType string `json:"machineType,omitempty"`
+	// ReadinessGates must all pass before the provider reports the VM as provisioned, the machine stays
+	// in the Provisioning phase until then. Defaults to the VMReady gate.
+	ReadinessGates []ReadinessGate `json:"readinessGates,omitempty"`
+	// Tolerations are set on the VMI, so its virt-launcher pod can be scheduled onto the tainted underkube
+	// nodes, e.g. the ones reserved for the capacity of the tenant clusters
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// Affinity is set on the VMI to constrain the underkube nodes of its virt-launcher pod. The pods carry
+	// the cluster ID and role labels of the machine, e.g. a podAntiAffinity selecting the master role of
+	// the cluster with the kubernetes.io/hostname topology puts the control plane on distinct nodes.
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+	// EvictionStrategy is what happens to the VM when its underkube node is drained. LiveMigrate moves it
+	// to another node, its boot volume is then ReadWriteMany and KubeVirt must permit live migrations. The
+	// existing VMs keep the access mode of their boot volume. Defaults to None, the VM is stopped with the
+	// node.
+	EvictionStrategy EvictionStrategy `json:"evictionStrategy,omitempty"`
+	// RunStrategy is the KubeVirt run strategy of the VM while the machine is powered on, e.g.
+	// RerunOnFailure restarts a crashed guest but leaves a guest that shut down stopped. Halted creates
+	// the VM stopped. Defaults to Always.
+	RunStrategy RunStrategy `json:"runStrategy,omitempty"`
+	// TerminationGracePeriodSeconds is the time the guest is given to shut down, when the VM is stopped or
+	// deleted, before it is killed. It must be shorter than the deletion deadline. Defaults to 10.
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+	// LauncherSecurity configures the security of the virt-launcher pod of the VM, for the underkubes
+	// still enforcing a PodSecurityPolicy
+	LauncherSecurity *LauncherSecurity `json:"launcherSecurity,omitempty"`
+	// InfraTopologyLabels mirrors the region and zone labels of the underkube node running the VMI, and
+	// its hostname, to the labels of the machine and of its node, so the tenant workloads can be spread
+	// across the physical topology. The underkube credentials must be allowed to get the nodes.
+	InfraTopologyLabels bool `json:"infraTopologyLabels,omitempty"`
+	// MetadataPropagation copies labels and annotations of the machine to the VMI of its VM
+	MetadataPropagation *MetadataPropagation `json:"metadataPropagation,omitempty"`
 	// TODO: add here the required CPU, Memory, machine type
 	// ignition    string `json:"pvcName,omitempty"`
 }
 
+// InterfaceRole is what a NIC of the VM is used for
+type InterfaceRole string
+
+const (
+	// InterfaceRolePrimary is the node network, the one the node registers with
+	InterfaceRolePrimary InterfaceRole = "primary"
+	// InterfaceRoleStorage is a network dedicated to the storage traffic
+	InterfaceRoleStorage InterfaceRole = "storage"
+	// InterfaceRoleWorkload is a network dedicated to the workloads of the node
+	InterfaceRoleWorkload InterfaceRole = "workload"
+)
+
+// NetworkInterface is a NIC of the VM, and how the guest configures it
+type NetworkInterface struct {
+	// Name of the interface in the guest
+	Name string `json:"name"`
+	// NetworkName is the Multus network attachment definition the NIC is connected to, its name or
+	// namespace/name when it isn't in the VM namespace. The NIC is connected to the pod network when empty.
+	NetworkName string `json:"networkName,omitempty"`
+	// Role of the interface, workload when empty
+	Role InterfaceRole `json:"role,omitempty"`
+	// Addresses are the static addresses of the interface, in CIDR notation. DHCP is used when empty.
+	Addresses []string `json:"addresses,omitempty"`
+	// Gateway is the default gateway reached through the interface
+	Gateway string `json:"gateway,omitempty"`
+	// Nameservers are the DNS servers reached through the interface
+	Nameservers []string `json:"nameservers,omitempty"`
+	// Binding is how the NIC is connected to its network, bridge when empty. A masquerade NIC is NATed
+	// behind the pod IP and must be on the pod network. An sriov NIC is a virtual function passed through
+	// to the VM, its network must be a Multus network of the sriov CNI.
+	Binding InterfaceBinding `json:"binding,omitempty"`
+	// ResourceName is the device plugin resource the virtual functions of an sriov NIC are allocated
+	// from, e.g. intel.com/sriov_netdevice. One is requested per sriov NIC.
+	ResourceName string `json:"resourceName,omitempty"`
+	// MacAddress is the static MAC address of the NIC, e.g. to keep a DHCP reservation of the tenant
+	// network. It is allocated as the MacAddressAllocation of the provider spec says when empty.
+	MacAddress string `json:"macAddress,omitempty"`
+	// BootOrder of the NIC among the boot devices of the VM, e.g. to PXE boot from it, see DiskBootOrders
+	BootOrder *uint `json:"bootOrder,omitempty"`
+}
+
+// MacAddressAllocation is how the NICs without static MAC address get theirs
+type MacAddressAllocation string
+
+const (
+	// MacAddressAllocationDerived derives the MAC address from the machine and interface names, so it is
+	// kept across the recreation of the VM
+	MacAddressAllocationDerived MacAddressAllocation = "Derived"
+	// MacAddressAllocationPool leaves the MAC address to KubeMacPool, which allocates it when the VM is
+	// created. The network data of the VM can't match such a NIC, its guest configuration is left to the
+	// image.
+	MacAddressAllocationPool MacAddressAllocation = "Pool"
+)
+
+// InterfaceBinding is how a NIC of the VM is connected to its network
+type InterfaceBinding string
+
+const (
+	// InterfaceBindingBridge bridges the NIC to the network of the virt-launcher pod
+	InterfaceBindingBridge InterfaceBinding = "bridge"
+	// InterfaceBindingMasquerade NATs the NIC behind the IP of the virt-launcher pod
+	InterfaceBindingMasquerade InterfaceBinding = "masquerade"
+	// InterfaceBindingSRIOV passes an SR-IOV virtual function through to the VM
+	InterfaceBindingSRIOV InterfaceBinding = "sriov"
+)
+
+// HealthCheck is a readiness probe of the VM
+type HealthCheck struct {
+	// Port of the VM to probe
+	Port int32 `json:"port"`
+	// Path of the HTTP GET probe. The port is probed with a TCP connection when empty.
+	Path string `json:"path,omitempty"`
+	// IntervalSeconds between two probes, defaults to 10
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+	// TimeoutSeconds after which a probe fails, defaults to 1
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+	// FailureThreshold is the number of consecutive failed probes after which the VM is not ready, defaults to 3
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// GPU is a host GPU passed through to the VM
+type GPU struct {
+	// Name of the GPU device of the VM
+	Name string `json:"name"`
+	// DeviceName is the resource name the device plugin of the underkube exposes the GPU with,
+	// e.g. nvidia.com/GV100GL_Tesla_V100
+	DeviceName string `json:"deviceName"`
+}
+
+// MediatedDevice is a mediated device of the VM, a slice of a host device like a vGPU
+type MediatedDevice struct {
+	// Name of the device of the VM
+	Name string `json:"name"`
+	// DeviceName is the resource name the device plugin of the underkube exposes the mediated device
+	// type with, e.g. nvidia.com/GRID_T4-1Q
+	DeviceName string `json:"deviceName"`
+}
+
+// CPU is the CPU model, features and topology of the VM
+type CPU struct {
+	// Sockets, Cores and Threads are the CPU topology the guest sees, e.g. for licensed software counting
+	// sockets. The VM gets sockets*cores*threads vCPUs, the unset ones counting as 1. Without any of them
+	// KubeVirt derives a single socket topology from the requested CPU.
+	Sockets uint32 `json:"sockets,omitempty"`
+	Cores   uint32 `json:"cores,omitempty"`
+	Threads uint32 `json:"threads,omitempty"`
+	// Model of the CPU, a libvirt model (e.g. Skylake-Server) or host-passthrough to give the VM the CPU
+	// of the node, which nested virtualization needs
+	Model string `json:"model,omitempty"`
+	// Features are the CPU flags added to or removed from the model
+	Features []CPUFeature `json:"features,omitempty"`
+	// DedicatedCPUPlacement pins the vCPUs of the VM to dedicated host CPUs. The underkube nodes must run
+	// the static CPU manager policy, and KubeVirt must enable its CPUManager feature gate.
+	DedicatedCPUPlacement bool `json:"dedicatedCpuPlacement,omitempty"`
+	// IsolateEmulatorThread gives the emulator thread of the VM one more dedicated host CPU, it needs
+	// DedicatedCPUPlacement
+	IsolateEmulatorThread bool `json:"isolateEmulatorThread,omitempty"`
+}
+
+// CPUFeature is a CPU flag of the VM
+type CPUFeature struct {
+	// Name of the flag, e.g. vmx
+	Name string `json:"name"`
+	// Policy of the flag: force, require, optional, disable or forbid. Defaults to require.
+	Policy string `json:"policy,omitempty"`
+}
+
+// Hugepages is the hugepages configuration of the memory of the VM
+type Hugepages struct {
+	// PageSize of the hugepages, 2Mi or 1Gi
+	PageSize string `json:"pageSize"`
+}
+
+// Bootloader is the firmware the VM boots with
+type Bootloader string
+
+const (
+	BIOSBootloader Bootloader = "BIOS"
+	EFIBootloader  Bootloader = "EFI"
+)
+
+// Firmware is the firmware configuration of the VM
+type Firmware struct {
+	// Bootloader is BIOS or EFI. Defaults to BIOS.
+	Bootloader Bootloader `json:"bootloader,omitempty"`
+	// SMM enables the System Management Mode of the VM
+	SMM bool `json:"smm,omitempty"`
+}
+
+// AnnotationProfile is a curated set of KubeVirt annotations of the VMI
+type AnnotationProfile string
+
+const (
+	// MigratableBridgeProfile allows the live migration of the VMs whose NIC is bridged to the pod network
+	MigratableBridgeProfile AnnotationProfile = "migratable-bridge"
+	// DeschedulerEvictableProfile lets the descheduler evict the virt-launcher pod, migrating the VM
+	DeschedulerEvictableProfile AnnotationProfile = "descheduler-evictable"
+	// KeepLauncherOnFailureProfile keeps the virt-launcher pod of a failed VM around for debugging
+	KeepLauncherOnFailureProfile AnnotationProfile = "keep-launcher-on-failure"
+)
+
+// ServiceMode is how the VMs are published in the underkube DNS
+type ServiceMode string
+
+const (
+	// PerMachineServiceMode creates a headless Service per VM, named after it
+	PerMachineServiceMode ServiceMode = "PerMachine"
+	// MachineSetServiceMode creates a headless Service per machineset, named <machineset>-machines, with an
+	// endpoint per VM whose hostname is the VM name. The machines must belong to a machineset, and can't
+	// expose ports.
+	MachineSetServiceMode ServiceMode = "MachineSet"
+	// NoServiceMode creates no Service, the VM is only known by its name and addresses. The machines
+	// can't expose ports, and their ServiceEndpointReady gates must name a Service.
+	NoServiceMode ServiceMode = "None"
+)
+
+// EvictionStrategy is what happens to the VM when its underkube node is drained
+type EvictionStrategy string
+
+const (
+	// EvictionStrategyNone stops the VM with the node, the machine comes back on another node
+	EvictionStrategyNone EvictionStrategy = "None"
+	// EvictionStrategyLiveMigrate live migrates the VM to another node. The VM can't have NICs bridged to
+	// the pod network, unless allowed by the migratable-bridge profile, nor sriov NICs or GPUs.
+	EvictionStrategyLiveMigrate EvictionStrategy = "LiveMigrate"
+)
+
+// CloneStrategy is how CDI cloned a PVC
+type CloneStrategy string
+
+const (
+	// CloneStrategySnapshot clones from a volume snapshot of the source PVC, the storage class must have a
+	// VolumeSnapshotClass
+	CloneStrategySnapshot CloneStrategy = "snapshot"
+	// CloneStrategyHostAssisted copies the data of the source PVC through a pair of CDI pods, on any storage
+	CloneStrategyHostAssisted CloneStrategy = "host-assisted"
+)
+
+// IOThreadsPolicy is how the disks of the VM share the IO threads
+type IOThreadsPolicy string
+
+const (
+	// IOThreadsPolicyShared runs the IO of all the disks in one thread
+	IOThreadsPolicyShared IOThreadsPolicy = "shared"
+	// IOThreadsPolicyAuto spreads the IO of the disks over a pool of threads sized after the vCPUs
+	IOThreadsPolicyAuto IOThreadsPolicy = "auto"
+)
+
+// DiskName is a disk the provider attaches to the VM. Only the boot disk and the config drive are known,
+// the VM has no data disk.
+type DiskName string
+
+const (
+	// BootDisk is the disk of the boot volume
+	BootDisk DiskName = "Boot"
+	// CloudInitDisk is the config drive holding the user data
+	CloudInitDisk DiskName = "CloudInit"
+)
+
+// Bus is the bus a disk is attached to
+type Bus string
+
+const (
+	// VirtioBus is the paravirtualized bus, the fastest one, it needs the virtio drivers in the guest
+	VirtioBus Bus = "virtio"
+	// SATABus emulates a SATA controller, for the legacy images without virtio drivers
+	SATABus Bus = "sata"
+	// SCSIBus emulates a SCSI controller, for the guests that expect their disks on one
+	SCSIBus Bus = "scsi"
+)
+
+// DiskBus is the bus of a disk of the VM
+type DiskBus struct {
+	// Disk is Boot or CloudInit
+	Disk DiskName `json:"disk"`
+	// Bus is virtio, sata or scsi
+	Bus Bus `json:"bus"`
+}
+
+// DiskBootOrder is the boot order of a disk of the VM
+type DiskBootOrder struct {
+	// Disk is Boot, the config drive isn't bootable
+	Disk DiskName `json:"disk"`
+	// BootOrder of the disk among the boot devices of the VM, positive and lowest first
+	BootOrder uint `json:"bootOrder"`
+}
+
+// ConfigVolume is a ConfigMap or a Secret of the VM namespace attached to the VM, one of ConfigMapName and
+// SecretName is set
+type ConfigVolume struct {
+	// Name of the volume, unique among the config volumes of the VM
+	Name          string `json:"name"`
+	ConfigMapName string `json:"configMapName,omitempty"`
+	SecretName    string `json:"secretName,omitempty"`
+	// VolumeLabel is the label of the disk in the guest, to mount it by label
+	VolumeLabel string `json:"volumeLabel,omitempty"`
+}
+
+// RunStrategy is the KubeVirt run strategy of the VM
+type RunStrategy string
+
+const (
+	// RunStrategyAlways keeps the VM running, restarting it whenever it stops
+	RunStrategyAlways RunStrategy = "Always"
+	// RunStrategyRerunOnFailure restarts the VM when it fails, not when the guest shuts it down
+	RunStrategyRerunOnFailure RunStrategy = "RerunOnFailure"
+	// RunStrategyManual only starts and stops the VM through the KubeVirt start and stop subresources
+	RunStrategyManual RunStrategy = "Manual"
+	// RunStrategyHalted keeps the VM stopped
+	RunStrategyHalted RunStrategy = "Halted"
+)
+
+// MetadataPropagation selects the labels and annotations of the machine copied to the VMI, and so to its
+// virt-launcher pod, for the underkube tooling selecting the VMs, e.g. monitoring or backup. The VM carries
+// all the labels and annotations of the machine already. The labels the provider sets on the VMI take
+// precedence, and a running VMI gets the changes at its next start.
+type MetadataPropagation struct {
+	// LabelPrefixes select the labels whose key starts with one of them, a full key selects that label
+	LabelPrefixes []string `json:"labelPrefixes,omitempty"`
+	// AnnotationPrefixes select the annotations whose key starts with one of them
+	AnnotationPrefixes []string `json:"annotationPrefixes,omitempty"`
+}
+
+// LauncherSecurity configures the security of the virt-launcher pod of the VM
+type LauncherSecurity struct {
+	// SeccompProfile is the seccomp profile of the pod: runtime/default, unconfined, or
+	// localhost/<profile> for a profile installed on the underkube nodes. KubeVirt passes it from the VMI
+	// annotations to the pod as the legacy seccomp annotation, which a PodSecurityPolicy checks. The
+	// underkubes from Kubernetes 1.25 ignore the annotation, and Pod Security admission only checks the
+	// securityContext of the pod, which the v1alpha3 API can't set.
+	SeccompProfile string `json:"seccompProfile,omitempty"`
+}
+
+// ReadinessGateType is a check the VM must pass before the machine is provisioned
+type ReadinessGateType string
+
+const (
+	// VMReadyGate passes when KubeVirt reports the VM ready
+	VMReadyGate ReadinessGateType = "VMReady"
+	// AgentConnectedGate passes when the guest agent of the VM is connected
+	AgentConnectedGate ReadinessGateType = "AgentConnected"
+	// AddressPublishedGate passes when the machine reports an internal IP of the VM
+	AddressPublishedGate ReadinessGateType = "AddressPublished"
+	// ServiceEndpointReadyGate passes when the VM is a ready endpoint of an underkube Service
+	ServiceEndpointReadyGate ReadinessGateType = "ServiceEndpointReady"
+)
+
+// ReadinessGate is a check the VM must pass before the machine is provisioned
+type ReadinessGate struct {
+	Type ReadinessGateType `json:"type"`
+	// ServiceName is the Service of the ServiceEndpointReady gate, in the namespace of the VM. Defaults to
+	// the Service of the machine.
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+// Deadlines bound the phases of a machine, each one defaults when unset
+type Deadlines struct {
+	// Import bounds the import of the boot volume, from the VM creation. Defaults to 30m.
+	Import *metav1.Duration `json:"import,omitempty"`
+	// Start bounds the start of the VM, from the VMI creation until the VM is ready. Defaults to 10m.
+	Start *metav1.Duration `json:"start,omitempty"`
+	// NodeJoin bounds the registration of the node, from the VMI creation. Defaults to 20m.
+	NodeJoin *metav1.Duration `json:"nodeJoin,omitempty"`
+	// Deletion bounds the deletion of the VM, from the deletion of the machine. The VM is deleted without
+	// grace period once it elapses. Defaults to 10m.
+	Deletion *metav1.Duration `json:"deletion,omitempty"`
+}
+
+// Expose lists the ports of the VM published by an Ingress of the underkube. On OpenShift, the
+// Ingress is turned into Routes by the ingress-to-route controller.
+type Expose struct {
+	Ports []ExposedPort `json:"ports,omitempty"`
+}
+
+// ExposedPort is a port of the VM published under a host and path
+type ExposedPort struct {
+	// Name of the port in the machine service
+	Name string `json:"name"`
+	// Port of the VM
+	Port int32 `json:"port"`
+	// Host the Ingress rule matches, any host when empty
+	Host string `json:"host,omitempty"`
+	// Path the Ingress rule matches, defaults to /
+	Path string `json:"path,omitempty"`
+}
+
 // KubevirtMachineProviderStatus is the type that will be embedded in a Machine.Status.ProviderStatus field.
 // It contains Kubevirt-specific status information.
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 type KubevirtMachineProviderStatus struct {
 	kubevirtapiv1.VirtualMachineStatus
+	// MachineConditions report the progress of the machine, from the VM creation to the guest agent
+	MachineConditions []KubevirtMachineCondition `json:"machineConditions,omitempty"`
+	// LastOperation is the last operation the provider ran on the machine
+	LastOperation *LastOperation `json:"lastOperation,omitempty"`
+	// FailureReason classifies the current failure of the machine, empty when the machine is healthy
+	FailureReason FailureReason `json:"failureReason,omitempty"`
+	// FailureMessage is the underlying error of FailureReason
+	FailureMessage string `json:"failureMessage,omitempty"`
+	// BootProgress lists the boot milestones the current VMI of the machine reached
+	BootProgress *BootProgress `json:"bootProgress,omitempty"`
+	// ProvisioningTimeline lists the provisioning phases the machine reached, with the time each one was
+	// first observed. Unlike the boot progress, it isn't reset when the VM restarts, and it is complete
+	// once the node joined.
+	ProvisioningTimeline []ProvisioningPhase `json:"provisioningTimeline,omitempty"`
+	// BootVolumeCloneStrategy is the strategy CDI was observed cloning the boot volume with, from the phases
+	// of its data volume: snapshot or host-assisted. CDI v1alpha1 reports no phase for a CSI clone, so it is
+	// never csi-clone, and it stays empty when the clone completed before the provider saw it in progress.
+	BootVolumeCloneStrategy CloneStrategy `json:"bootVolumeCloneStrategy,omitempty"`
+	// VMNamespace is the underkube namespace the VM was created in. It is resolved once, so a later change of
+	// the namespace of the kubeconfig context doesn't lose the VM.
+	VMNamespace string `json:"vmNamespace,omitempty"`
+	// UnderKubeconfigSecretName is the underkube credentials secret the machine uses. It can't change once the
+	// machine has a provider ID.
+	UnderKubeconfigSecretName string `json:"underKubeconfigSecretName,omitempty"`
+	// BootCompleted records the VM was observed ready once, the start deadline only applies to its first boot
+	BootCompleted bool `json:"bootCompleted,omitempty"`
+	// MachineServiceCreated records the provider created the Service of the VM, so it is removed once the
+	// service mode of the machine changes, and waited for on deletion
+	MachineServiceCreated bool `json:"machineServiceCreated,omitempty"`
+	// IngressCreated records the provider created the Ingress of the exposed ports of the VM, so it is removed
+	// once the ports are no longer exposed, and waited for on deletion
+	IngressCreated bool `json:"ingressCreated,omitempty"`
+	// VMMutations is the history of the changes the provider made to the VM, the oldest first. Only the
+	// most recent ones are kept.
+	VMMutations []VMMutation `json:"vmMutations,omitempty"`
+}
+
+// VMMutationType is the kind of a change the provider made to the VM
+type VMMutationType string
+
+const (
+	VMCreatedMutation   VMMutationType = "Created"
+	VMUpdatedMutation   VMMutationType = "Updated"
+	VMDeletedMutation   VMMutationType = "Deleted"
+	VMStartedMutation   VMMutationType = "Started"
+	VMStoppedMutation   VMMutationType = "Stopped"
+	VMPausedMutation    VMMutationType = "Paused"
+	VMUnpausedMutation  VMMutationType = "Unpaused"
+	VMRestartedMutation VMMutationType = "Restarted"
+)
+
+// VMMutation is a change the provider made to the VM
+type VMMutation struct {
+	Type VMMutationType `json:"type"`
+	Time metav1.Time    `json:"time"`
+	// MachineGeneration is the generation of the machine the provider reconciled when it made the change
+	MachineGeneration int64 `json:"machineGeneration,omitempty"`
+	// Diff is the strategic merge patch the update applied to the VM spec, truncated when too long
+	Diff string `json:"diff,omitempty"`
+}
+
+// BootMilestoneName is a step of the boot of the VM
+type BootMilestoneName string
+
+const (
+	// VMIStartedMilestone is reached when the VMI runs
+	VMIStartedMilestone BootMilestoneName = "VMIStarted"
+	// GuestAgentConnectedMilestone is reached when the guest agent connects, once the guest OS is up
+	GuestAgentConnectedMilestone BootMilestoneName = "GuestAgentConnected"
+	// NodeJoinedMilestone is reached when the kubelet registered the node of the machine
+	NodeJoinedMilestone BootMilestoneName = "NodeJoined"
+)
+
+// BootMilestone is a milestone reached by the VMI, with the time it was first observed
+type BootMilestone struct {
+	Name BootMilestoneName `json:"name"`
+	Time metav1.Time       `json:"time"`
+}
+
+// BootProgress reports the boot of a VMI. KubeVirt doesn't let the provider run commands in the guest, so
+// the cloud-init steps aren't reported, only the milestones visible from the infra and tenant clusters.
+type BootProgress struct {
+	// VMIUID identifies the VMI the milestones belong to, they start over when the VM restarts
+	VMIUID     string          `json:"vmiUID"`
+	Milestones []BootMilestone `json:"milestones,omitempty"`
+}
+
+// ProvisioningPhaseName is a step of the provisioning of the machine
+type ProvisioningPhaseName string
+
+const (
+	// SpecAcceptedPhase is reached when the provider spec passed the validation and the VM was created
+	SpecAcceptedPhase ProvisioningPhaseName = "SpecAccepted"
+	// VolumesReadyPhase is reached when the data volumes of the VM were imported
+	VolumesReadyPhase ProvisioningPhaseName = "VolumesReady"
+	// VMIStartedPhase is reached when the VMI runs
+	VMIStartedPhase ProvisioningPhaseName = "VMIStarted"
+	// AgentConnectedPhase is reached when the guest agent connects. The guests without agent never reach it.
+	AgentConnectedPhase ProvisioningPhaseName = "AgentConnected"
+	// NodeJoinedPhase is reached when the kubelet registered the node of the machine
+	NodeJoinedPhase ProvisioningPhaseName = "NodeJoined"
+)
+
+// ProvisioningPhase is a provisioning phase reached by the machine, with the time it was first observed
+type ProvisioningPhase struct {
+	Name ProvisioningPhaseName `json:"name"`
+	Time metav1.Time           `json:"time"`
+}
+
+// FailureReason is the reason a machine failed. The set of reasons is fixed, so alerting rules can be
+// keyed on them.
+type FailureReason string
+
+const (
+	// InsufficientResourcesFailure reports no infra node can fit the VM
+	InsufficientResourcesFailure FailureReason = "InsufficientResources"
+	// ImagePullFailure reports the infra cluster can't pull an image of the VM
+	ImagePullFailure FailureReason = "ImagePullFailure"
+	// InvalidConfigurationFailure reports the provider spec, or the resources it refers to, is invalid
+	InvalidConfigurationFailure FailureReason = "InvalidConfiguration"
+	// InfraUnreachableFailure reports the infra API server can't be reached
+	InfraUnreachableFailure FailureReason = "InfraUnreachable"
+	// QuotaExceededFailure reports a resource quota of the infra namespace, or the budget of the tenant
+	// cluster, rejected the VM
+	QuotaExceededFailure FailureReason = "QuotaExceeded"
+	// BootTimeoutFailure reports the VM didn't become ready in time after it started
+	BootTimeoutFailure FailureReason = "BootTimeout"
+	// ImportTimeoutFailure reports the boot volume wasn't imported in time
+	ImportTimeoutFailure FailureReason = "ImportTimeout"
+	// NodeJoinTimeoutFailure reports the node of the machine didn't register in time
+	NodeJoinTimeoutFailure FailureReason = "NodeJoinTimeout"
+	// DeletionTimeoutFailure reports the VM wasn't deleted in time
+	DeletionTimeoutFailure FailureReason = "DeletionTimeout"
+	// InfraNotReadyFailure reports the infra cluster lacks an API or the namespace the VM needs
+	InfraNotReadyFailure FailureReason = "InfraNotReady"
+)
+
+// OperationType is the type of an operation of the provider on a machine
+type OperationType string
+
+const (
+	CreateOperation OperationType = "Create"
+	UpdateOperation OperationType = "Update"
+	DeleteOperation OperationType = "Delete"
+)
+
+// OperationOutcome is the outcome of an operation of the provider on a machine
+type OperationOutcome string
+
+const (
+	OperationSucceeded OperationOutcome = "Succeeded"
+	OperationFailed    OperationOutcome = "Failed"
+	// OperationInterrupted reports the provider shut down before the operation finished, the next
+	// reconcile of the machine resumes it
+	OperationInterrupted OperationOutcome = "Interrupted"
+)
+
+// LastOperation describes an attempt of the provider to create, update or delete the machine
+type LastOperation struct {
+	Type OperationType `json:"type"`
+	// Time is when the operation first ended with this outcome, the repeated operations ending the same
+	// way keep it
+	Time    metav1.Time      `json:"time"`
+	Outcome OperationOutcome `json:"outcome"`
+	// Error is the error the operation failed with
+	Error string `json:"error,omitempty"`
+}
+
+// KubevirtMachineConditionType is the type of a KubevirtMachineCondition
+type KubevirtMachineConditionType string
+
+const (
+	// VMProvisionedCondition reports the VM was created in the underkube
+	VMProvisionedCondition KubevirtMachineConditionType = "VMProvisioned"
+	// VMReadyCondition reports the VM is running and ready
+	VMReadyCondition KubevirtMachineConditionType = "VMReady"
+	// VolumesReadyCondition reports the data volumes of the VM were imported
+	VolumesReadyCondition KubevirtMachineConditionType = "VolumesReady"
+	// NetworkReadyCondition reports the VM got an IP address
+	NetworkReadyCondition KubevirtMachineConditionType = "NetworkReady"
+	// AgentConnectedCondition reports the guest agent of the VM is connected
+	AgentConnectedCondition KubevirtMachineConditionType = "AgentConnected"
+	// LauncherScheduledCondition reports the virt-launcher pod of the VM was scheduled on an infra node
+	LauncherScheduledCondition KubevirtMachineConditionType = "LauncherScheduled"
+	// LauncherImagePulledCondition reports the images of the virt-launcher pod were pulled
+	LauncherImagePulledCondition KubevirtMachineConditionType = "LauncherImagePulled"
+	// LauncherRunningCondition reports the virt-launcher pod is running, with the reason its containers
+	// stopped otherwise
+	LauncherRunningCondition KubevirtMachineConditionType = "LauncherRunning"
+	// CredentialsValidCondition reports whether the underkube kubeconfig secret could be used, it is only
+	// set once the credentials were found invalid
+	CredentialsValidCondition KubevirtMachineConditionType = "CredentialsValid"
+	// InfraReadyCondition reports whether the preflight checks run before creating the VM passed
+	InfraReadyCondition KubevirtMachineConditionType = "InfraReady"
+	// MissingInfraCapabilitiesCondition lists the optional underkube components the features of the machine
+	// miss, with the features each one affects. Unlike the other conditions, True is the unhealthy status.
+	MissingInfraCapabilitiesCondition KubevirtMachineConditionType = "MissingInfraCapabilities"
+	// DeleteBlockedCondition reports the VM deletion waits for the node of the machine to be cordoned and
+	// drained, with the pods still running on it, for other VMs of the tenant cluster to be deleted, or for
+	// the protection annotation to be removed. True is the unhealthy status.
+	DeleteBlockedCondition KubevirtMachineConditionType = "DeleteBlocked"
+	// QuotaExceededCondition reports the VM wasn't created because the VMs of the tenant cluster would
+	// exceed the budget of the provider config. True is the unhealthy status.
+	QuotaExceededCondition KubevirtMachineConditionType = "QuotaExceeded"
+	// MediatedDevicesAvailableCondition reports whether the underkube nodes offer the mediated devices of
+	// the VM. It is only set for the machines with mediated devices.
+	MediatedDevicesAvailableCondition KubevirtMachineConditionType = "MediatedDevicesAvailable"
+	// ReadinessGatesPassedCondition reports whether the readiness gates of the provider spec passed, with
+	// the ones that didn't
+	ReadinessGatesPassedCondition KubevirtMachineConditionType = "ReadinessGatesPassed"
+	// CleanupCompleteCondition reports whether the dependent resources of the VM of a deleted machine were
+	// removed, with the ones remaining
+	CleanupCompleteCondition KubevirtMachineConditionType = "CleanupComplete"
+)
+
+// KubevirtMachineCondition follows the semantics of the upstream metav1.Condition: LastTransitionTime
+// only moves when Status changes, and Reason is a CamelCase machine readable explanation.
+type KubevirtMachineCondition struct {
+	Type   KubevirtMachineConditionType `json:"type"`
+	Status corev1.ConditionStatus       `json:"status"`
+	// ObservedGeneration is the generation of the machine the condition was computed from
+	ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+	// LastUpdateTime is the last time the reason or the message changed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+	Reason         string      `json:"reason"`
+	Message        string      `json:"message"`
 }
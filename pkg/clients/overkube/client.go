@@ -22,6 +22,7 @@ import (
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -31,9 +32,14 @@ import (
 
 // Client is a wrapper object for actual overkube clients: kubernetesClient and runtimeClient
 type Client interface {
+	CreateMachine(machine *machinev1.Machine) error
+	GetMachine(namespace string, name string) (*machinev1.Machine, error)
 	PatchMachine(machine *machinev1.Machine, originMachineCopy *machinev1.Machine) error
 	StatusPatchMachine(machine *machinev1.Machine, originMachineCopy *machinev1.Machine) error
 	GetSecret(secretName string, namespace string) (*corev1.Secret, error)
+	GetNode(nodeName string) (*corev1.Node, error)
+	PatchNode(node *corev1.Node, originNodeCopy *corev1.Node) error
+	ListNodePods(nodeName string) (*corev1.PodList, error)
 }
 
 type kubeClient struct {
@@ -54,6 +60,18 @@ func New(mgr manager.Manager) (Client, error) {
 	}, nil
 }
 
+func (c *kubeClient) CreateMachine(machine *machinev1.Machine) error {
+	return c.runtimeClient.Create(context.Background(), machine)
+}
+
+func (c *kubeClient) GetMachine(namespace string, name string) (*machinev1.Machine, error) {
+	machine := &machinev1.Machine{}
+	if err := c.runtimeClient.Get(context.Background(), client.ObjectKey{Namespace: namespace, Name: name}, machine); err != nil {
+		return nil, err
+	}
+	return machine, nil
+}
+
 func (c *kubeClient) PatchMachine(machine *machinev1.Machine, originMachineCopy *machinev1.Machine) error {
 	return c.runtimeClient.Patch(context.Background(), machine, client.MergeFrom(originMachineCopy))
 }
@@ -65,3 +83,18 @@ func (c *kubeClient) StatusPatchMachine(machine *machinev1.Machine, originMachin
 func (c *kubeClient) GetSecret(secretName string, namespace string) (*corev1.Secret, error) {
 	return c.kubernetesClient.CoreV1().Secrets(namespace).Get(secretName, k8smetav1.GetOptions{})
 }
+
+func (c *kubeClient) GetNode(nodeName string) (*corev1.Node, error) {
+	return c.kubernetesClient.CoreV1().Nodes().Get(nodeName, k8smetav1.GetOptions{})
+}
+
+func (c *kubeClient) PatchNode(node *corev1.Node, originNodeCopy *corev1.Node) error {
+	return c.runtimeClient.Patch(context.Background(), node, client.MergeFrom(originNodeCopy))
+}
+
+// ListNodePods lists the pods of all the namespaces scheduled on the node
+func (c *kubeClient) ListNodePods(nodeName string) (*corev1.PodList, error) {
+	return c.kubernetesClient.CoreV1().Pods(k8smetav1.NamespaceAll).List(k8smetav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("spec.nodeName", nodeName).String(),
+	})
+}
@@ -34,6 +34,35 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// CreateMachine mocks base method
+func (m *MockClient) CreateMachine(machine *v1beta1.Machine) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateMachine", machine)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateMachine indicates an expected call of CreateMachine
+func (mr *MockClientMockRecorder) CreateMachine(machine interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateMachine", reflect.TypeOf((*MockClient)(nil).CreateMachine), machine)
+}
+
+// GetMachine mocks base method
+func (m *MockClient) GetMachine(namespace, name string) (*v1beta1.Machine, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMachine", namespace, name)
+	ret0, _ := ret[0].(*v1beta1.Machine)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMachine indicates an expected call of GetMachine
+func (mr *MockClientMockRecorder) GetMachine(namespace, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMachine", reflect.TypeOf((*MockClient)(nil).GetMachine), namespace, name)
+}
+
 // PatchMachine mocks base method
 func (m *MockClient) PatchMachine(machine, originMachineCopy *v1beta1.Machine) error {
 	m.ctrl.T.Helper()
@@ -76,3 +105,47 @@ func (mr *MockClientMockRecorder) GetSecret(secretName, namespace interface{}) *
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecret", reflect.TypeOf((*MockClient)(nil).GetSecret), secretName, namespace)
 }
+
+// GetNode mocks base method
+func (m *MockClient) GetNode(nodeName string) (*v1.Node, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNode", nodeName)
+	ret0, _ := ret[0].(*v1.Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNode indicates an expected call of GetNode
+func (mr *MockClientMockRecorder) GetNode(nodeName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNode", reflect.TypeOf((*MockClient)(nil).GetNode), nodeName)
+}
+
+// PatchNode mocks base method
+func (m *MockClient) PatchNode(node, originNodeCopy *v1.Node) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchNode", node, originNodeCopy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchNode indicates an expected call of PatchNode
+func (mr *MockClientMockRecorder) PatchNode(node, originNodeCopy interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchNode", reflect.TypeOf((*MockClient)(nil).PatchNode), node, originNodeCopy)
+}
+
+// ListNodePods mocks base method
+func (m *MockClient) ListNodePods(nodeName string) (*v1.PodList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListNodePods", nodeName)
+	ret0, _ := ret[0].(*v1.PodList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListNodePods indicates an expected call of ListNodePods
+func (mr *MockClientMockRecorder) ListNodePods(nodeName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNodePods", reflect.TypeOf((*MockClient)(nil).ListNodePods), nodeName)
+}
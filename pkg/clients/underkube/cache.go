@@ -0,0 +1,74 @@
+package underkube
+
+import (
+	"sync"
+
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+)
+
+// ClientCache keeps the underkube clients built from every credentials secret, so they aren't rebuilt on
+// every reconcile. The clients of a secret must be invalidated when the secret changes.
+type ClientCache struct {
+	builder ClientBuilderFuncType
+
+	lock    sync.Mutex
+	clients map[types.NamespacedName]Client
+}
+
+// NewClientCache returns a cache building its clients with builder
+func NewClientCache(builder ClientBuilderFuncType) *ClientCache {
+	return &ClientCache{
+		builder: builder,
+		clients: map[types.NamespacedName]Client{},
+	}
+}
+
+// Get returns the client of the credentials secret, building it on first use. It is a ClientBuilderFuncType.
+// The returned client rebuilds itself from the current secret when the underkube rejects its credentials.
+func (c *ClientCache) Get(overKubernetesClient overkube.Client, underKubeconfigSecretName, namespace string) (Client, error) {
+	key := types.NamespacedName{Namespace: namespace, Name: underKubeconfigSecretName}
+	client, err := c.get(overKubernetesClient, key)
+	if err != nil {
+		return nil, err
+	}
+	return &reauthClient{cache: c, overKubernetesClient: overKubernetesClient, key: key, client: client}, nil
+}
+
+func (c *ClientCache) get(overKubernetesClient overkube.Client, key types.NamespacedName) (Client, error) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if client, ok := c.clients[key]; ok {
+		return client, nil
+	}
+
+	client, err := c.builder(overKubernetesClient, key.Name, key.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[key] = client
+	return client, nil
+}
+
+// Invalidate drops the client of the credentials secret, the next Get rebuilds it from the current secret
+func (c *ClientCache) Invalidate(underKubeconfigSecretName, namespace string) {
+	key := types.NamespacedName{Namespace: namespace, Name: underKubeconfigSecretName}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.clients[key]; ok {
+		klog.Infof("Invalidating the underkube client of secret %v", key)
+		delete(c.clients, key)
+	}
+}
+
+// evict drops the cached client of key if it is still stale. Another caller may already have replaced it.
+func (c *ClientCache) evict(key types.NamespacedName, stale Client) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.clients[key] == stale {
+		delete(c.clients, key)
+	}
+}
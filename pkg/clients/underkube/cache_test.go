@@ -0,0 +1,132 @@
+package underkube
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestClientCache(t *testing.T) {
+	builds := map[string]int{}
+	var buildErr error
+	cache := NewClientCache(func(_ overkube.Client, underKubeconfigSecretName, namespace string) (Client, error) {
+		if buildErr != nil {
+			return nil, buildErr
+		}
+		builds[namespace+"/"+underKubeconfigSecretName]++
+		return &client{}, nil
+	})
+
+	get := func(namespace string) (Client, error) {
+		client, err := cache.Get(nil, "kubeconfig", namespace)
+		if err != nil {
+			return nil, err
+		}
+		return client.(*reauthClient).client, nil
+	}
+
+	first, err := get("cluster-1")
+	assert.NilError(t, err)
+	second, err := get("cluster-1")
+	assert.NilError(t, err)
+	assert.Assert(t, first == second, "the cached client was not reused")
+	_, err = cache.Get(nil, "kubeconfig", "cluster-2")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, builds, map[string]int{"cluster-1/kubeconfig": 1, "cluster-2/kubeconfig": 1})
+
+	cache.Invalidate("kubeconfig", "cluster-1")
+	third, err := get("cluster-1")
+	assert.NilError(t, err)
+	assert.Assert(t, first != third, "the invalidated client was reused")
+	assert.DeepEqual(t, builds, map[string]int{"cluster-1/kubeconfig": 2, "cluster-2/kubeconfig": 1})
+
+	// Errors are not cached
+	cache.Invalidate("kubeconfig", "cluster-2")
+	buildErr = errors.New("invalid kubeconfig")
+	_, err = cache.Get(nil, "kubeconfig", "cluster-2")
+	assert.Error(t, err, "invalid kubeconfig")
+	buildErr = nil
+	_, err = cache.Get(nil, "kubeconfig", "cluster-2")
+	assert.NilError(t, err)
+	assert.Equal(t, builds["cluster-2/kubeconfig"], 2)
+}
+
+func TestReauthClient(t *testing.T) {
+	unauthorized := apimachineryerrors.NewUnauthorized("certificate has expired")
+	cases := []struct {
+		name       string
+		firstErr   error
+		retryErr   error
+		wantBuilds int
+		wantErr    string
+	}{
+		{
+			name:       "Don't retry a successful call",
+			wantBuilds: 1,
+		},
+		{
+			name:       "Don't retry other errors",
+			firstErr:   apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "services"}, "worker"),
+			wantBuilds: 1,
+			wantErr:    `services "worker" not found`,
+		},
+		{
+			name:       "Rebuild the client when the credentials are refused",
+			firstErr:   unauthorized,
+			wantBuilds: 2,
+		},
+		{
+			name:       "Don't retry a forbidden call",
+			firstErr:   apimachineryerrors.NewForbidden(schema.GroupResource{Resource: "services"}, "worker", errors.New("no access")),
+			wantBuilds: 1,
+			wantErr:    `services "worker" is forbidden: no access`,
+		},
+		{
+			name:       "Retry only once",
+			firstErr:   unauthorized,
+			retryErr:   unauthorized,
+			wantBuilds: 2,
+			wantErr:    "certificate has expired",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			stale := mock.NewMockClient(mockCtrl)
+			rebuilt := mock.NewMockClient(mockCtrl)
+			service := &corev1.Service{}
+			stale.EXPECT().GetService("worker", "cluster-1", k8smetav1.GetOptions{}).Return(service, tc.firstErr).Times(1)
+			if tc.wantBuilds > 1 {
+				rebuilt.EXPECT().GetService("worker", "cluster-1", k8smetav1.GetOptions{}).Return(service, tc.retryErr).Times(1)
+			}
+
+			builds := 0
+			cache := NewClientCache(func(overkube.Client, string, string) (Client, error) {
+				builds++
+				if builds == 1 {
+					return stale, nil
+				}
+				return rebuilt, nil
+			})
+			client, err := cache.Get(nil, "kubeconfig", "cluster-1")
+			assert.NilError(t, err)
+
+			_, err = client.GetService("worker", "cluster-1", k8smetav1.GetOptions{})
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.Equal(t, builds, tc.wantBuilds)
+		})
+	}
+}
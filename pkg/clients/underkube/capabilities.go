@@ -0,0 +1,98 @@
+package underkube
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// Capability is an optional component of the underkube some features of the VMs depend on
+type Capability string
+
+const (
+	// CDICapability imports the boot volumes of the VMs
+	CDICapability Capability = "CDI"
+	// MultusCapability connects the VMs to secondary networks
+	MultusCapability Capability = "Multus"
+	// KubeMacPoolCapability allocates stable unique MAC addresses to the NICs of the VMs
+	KubeMacPoolCapability Capability = "KubeMacPool"
+	// SnapshotCapability takes CSI snapshots of the volumes
+	SnapshotCapability Capability = "Snapshot"
+	// MetalLBCapability provides the LoadBalancer services of bare metal underkubes
+	MetalLBCapability Capability = "MetalLB"
+
+	// kubeMacPoolWebhookName is the webhook of KubeMacPool, which has no API of its own
+	kubeMacPoolWebhookName = "kubemacpool-mutator"
+)
+
+// Capabilities tells whether each capability is available on the underkube
+type Capabilities map[Capability]bool
+
+// String lists the available capabilities
+func (c Capabilities) String() string {
+	var available []string
+	for capability, ok := range c {
+		if ok {
+			available = append(available, string(capability))
+		}
+	}
+	sort.Strings(available)
+	return fmt.Sprintf("[%s]", strings.Join(available, " "))
+}
+
+type capabilityAPI struct {
+	groupVersion schema.GroupVersion
+	resource     string
+}
+
+var (
+	// capabilityAPIs are the APIs served by the capabilities, whose types aren't vendored but for CDI
+	capabilityAPIs = map[Capability]capabilityAPI{
+		CDICapability:      {groupVersion: cdiv1.SchemeGroupVersion, resource: "datavolumes"},
+		MultusCapability:   {groupVersion: schema.GroupVersion{Group: "k8s.cni.cncf.io", Version: "v1"}, resource: "network-attachment-definitions"},
+		SnapshotCapability: {groupVersion: schema.GroupVersion{Group: "snapshot.storage.k8s.io", Version: "v1beta1"}, resource: "volumesnapshots"},
+		MetalLBCapability:  {groupVersion: schema.GroupVersion{Group: "metallb.io", Version: "v1beta1"}, resource: "addresspools"},
+	}
+
+	capabilityHints = map[Capability]string{
+		CDICapability:         "install CDI on the underkube",
+		MultusCapability:      "install Multus on the underkube",
+		KubeMacPoolCapability: "install KubeMacPool on the underkube",
+		SnapshotCapability:    "install the CSI snapshot CRDs and controller on the underkube",
+		MetalLBCapability:     "install MetalLB on the underkube",
+	}
+)
+
+// CapabilityHint returns how to make the capability available
+func CapabilityHint(capability Capability) string {
+	return capabilityHints[capability]
+}
+
+// DetectCapabilities finds which capabilities the underkube runs. A capability the credentials aren't
+// allowed to see is reported as missing.
+func DetectCapabilities(c Client) (Capabilities, error) {
+	capabilities := Capabilities{}
+	for capability, api := range capabilityAPIs {
+		served, err := servesResource(c, api.groupVersion, api.resource)
+		if err != nil {
+			return nil, err
+		}
+		capabilities[capability] = served
+	}
+
+	_, err := c.GetMutatingWebhookConfiguration(kubeMacPoolWebhookName, k8smetav1.GetOptions{})
+	switch {
+	case apimachineryerrors.IsForbidden(err):
+		klog.V(3).Infof("Unable to detect KubeMacPool on the underkube: %v", err)
+	case err != nil && !apimachineryerrors.IsNotFound(err):
+		return nil, err
+	}
+	capabilities[KubeMacPoolCapability] = err == nil
+	return capabilities, nil
+}
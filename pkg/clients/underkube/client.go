@@ -17,16 +17,35 @@ limitations under the License.
 package underkube
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
 	machineapiapierrors "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
 	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/transport"
+	"k8s.io/client-go/util/flowcontrol"
 	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
 	"kubevirt.io/client-go/kubecli"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 )
 
 //go:generate mockgen -source=./client.go -destination=./mock/client_generated.go -package=mock
@@ -34,6 +53,17 @@ import (
 const (
 	// underKubeConfig is secret key containing kubeconfig content of the UnderKube
 	underKubeConfig = "kubeconfig"
+	// AllowedNamespacesAnnotation lists, comma separated, the namespaces the machines using a credentials
+	// secret may set as their VM namespace besides the namespace of the kubeconfig context. "*" allows any.
+	AllowedNamespacesAnnotation = "kubevirt.io/allowed-namespaces"
+	// kubevirtConfigMapName is the ConfigMap of the KubeVirt installation holding its configuration
+	kubevirtConfigMapName = "kubevirt-config"
+	// featureGatesKey is the kubevirtConfigMapName key listing the enabled feature gates, comma separated
+	featureGatesKey = "feature-gates"
+	// listPageSize is the number of items requested per page when the caller didn't set a limit
+	listPageSize = 500
+	// virtualMachineInstancesResource is the resource of the VMIs in the KubeVirt API group
+	virtualMachineInstancesResource = "virtualmachineinstances"
 )
 
 // ClientBuilderFuncType is function type for building underkube client
@@ -46,20 +76,83 @@ type Client interface {
 	GetVirtualMachine(namespace string, name string, options *k8smetav1.GetOptions) (*kubevirtapiv1.VirtualMachine, error)
 	GetVirtualMachineInstance(namespace string, name string, options *k8smetav1.GetOptions) (*kubevirtapiv1.VirtualMachineInstance, error)
 	ListVirtualMachine(namespace string, options *k8smetav1.ListOptions) (*kubevirtapiv1.VirtualMachineList, error)
+	ListVirtualMachineInstance(namespace string, options *k8smetav1.ListOptions) (*kubevirtapiv1.VirtualMachineInstanceList, error)
+	WatchVirtualMachineInstance(namespace string, options k8smetav1.ListOptions) (watch.Interface, error)
 	UpdateVirtualMachine(namespace string, vm *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error)
 	PatchVirtualMachine(namespace string, name string, pt types.PatchType, data []byte, subresources ...string) (result *kubevirtapiv1.VirtualMachine, err error)
 	RestartVirtualMachine(namespace string, name string) error
 	StartVirtualMachine(namespace string, name string) error
 	StopVirtualMachine(namespace string, name string) error
+	PauseVirtualMachineInstance(namespace string, name string) error
+	UnpauseVirtualMachineInstance(namespace string, name string) error
+	PortForwardVirtualMachineInstance(namespace string, name string, port int) (io.ReadWriteCloser, error)
 	CreateService(service *corev1.Service, namespace string) (*corev1.Service, error)
 	DeleteService(serviceName string, namespace string, options *k8smetav1.DeleteOptions) error
 	UpdateService(service *corev1.Service, namespace string) (*corev1.Service, error)
 	GetService(serviceName string, namespace string, options k8smetav1.GetOptions) (*corev1.Service, error)
+	ListServices(namespace string, options k8smetav1.ListOptions) (*corev1.ServiceList, error)
+	GetEndpoints(name string, namespace string, options k8smetav1.GetOptions) (*corev1.Endpoints, error)
+	CreateEndpoints(endpoints *corev1.Endpoints, namespace string) (*corev1.Endpoints, error)
+	UpdateEndpoints(endpoints *corev1.Endpoints, namespace string) (*corev1.Endpoints, error)
+	ListPods(namespace string, options k8smetav1.ListOptions) (*corev1.PodList, error)
+	ListSecrets(namespace string, options k8smetav1.ListOptions) (*corev1.SecretList, error)
+	ListIngresses(namespace string, options k8smetav1.ListOptions) (*networkingv1beta1.IngressList, error)
+	ListPersistentVolumeClaims(namespace string, options k8smetav1.ListOptions) (*corev1.PersistentVolumeClaimList, error)
+	GetPersistentVolumeClaim(pvcName string, namespace string, options k8smetav1.GetOptions) (*corev1.PersistentVolumeClaim, error)
+	UpdatePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaim, namespace string) (*corev1.PersistentVolumeClaim, error)
+	GetStorageClass(storageClassName string, options k8smetav1.GetOptions) (*storagev1.StorageClass, error)
+	GetNode(nodeName string, options k8smetav1.GetOptions) (*corev1.Node, error)
+	ListNodes(options k8smetav1.ListOptions) (*corev1.NodeList, error)
+	CreateSecret(secret *corev1.Secret, namespace string) (*corev1.Secret, error)
+	DeleteSecret(secretName string, namespace string, options *k8smetav1.DeleteOptions) error
+	UpdateSecret(secret *corev1.Secret, namespace string) (*corev1.Secret, error)
+	GetSecret(secretName string, namespace string, options k8smetav1.GetOptions) (*corev1.Secret, error)
+	CreateRole(role *rbacv1.Role, namespace string) (*rbacv1.Role, error)
+	GetRole(roleName string, namespace string, options k8smetav1.GetOptions) (*rbacv1.Role, error)
+	CreateRoleBinding(roleBinding *rbacv1.RoleBinding, namespace string) (*rbacv1.RoleBinding, error)
+	GetRoleBinding(roleBindingName string, namespace string, options k8smetav1.GetOptions) (*rbacv1.RoleBinding, error)
+	DeleteRoleBinding(roleBindingName string, namespace string, options *k8smetav1.DeleteOptions) error
+	CreateIngress(ingress *networkingv1beta1.Ingress, namespace string) (*networkingv1beta1.Ingress, error)
+	DeleteIngress(ingressName string, namespace string, options *k8smetav1.DeleteOptions) error
+	UpdateIngress(ingress *networkingv1beta1.Ingress, namespace string) (*networkingv1beta1.Ingress, error)
+	GetIngress(ingressName string, namespace string, options k8smetav1.GetOptions) (*networkingv1beta1.Ingress, error)
+	GetDataVolume(dataVolumeName string, namespace string, options k8smetav1.GetOptions) (*cdiv1.DataVolume, error)
+	GetNamespace(namespaceName string, options k8smetav1.GetOptions) (*corev1.Namespace, error)
+	ServerResourcesForGroupVersion(groupVersion string) (*k8smetav1.APIResourceList, error)
+	GetKubeVirtVersion() (string, error)
+	GetFeatureGates() ([]string, error)
+	GetMutatingWebhookConfiguration(name string, options k8smetav1.GetOptions) (*admissionregistrationv1beta1.MutatingWebhookConfiguration, error)
+	// DefaultNamespace is the namespace of the kubeconfig current context, empty when it has none
+	DefaultNamespace() string
+	// AllowedNamespaces are the namespaces allowed by the credentials secret besides the default one
+	AllowedNamespaces() []string
+}
+
+// CredentialsError is returned by New when the underkube kubeconfig secret is missing or invalid.
+// Unlike the other errors, it won't go away until someone fixes the secret.
+type CredentialsError struct {
+	Message string
+}
+
+func (e *CredentialsError) Error() string {
+	return e.Message
+}
+
+func credentialsError(msg string, args ...interface{}) *CredentialsError {
+	return &CredentialsError{Message: fmt.Sprintf(msg, args...)}
+}
+
+// IsCredentialsError returns true if err is, or wraps, a CredentialsError
+func IsCredentialsError(err error) bool {
+	var credentialsErr *CredentialsError
+	return errors.As(err, &credentialsErr)
 }
 
 type client struct {
-	kubevirtClient   kubecli.KubevirtClient
-	kuberentesClient *kubernetes.Clientset
+	kubevirtClient    kubecli.KubevirtClient
+	kuberentesClient  *kubernetes.Clientset
+	defaultNamespace  string
+	allowedNamespaces []string
 }
 
 // New creates our client wrapper object for the actual kubeVirt and kubernetes clients we use.
@@ -75,29 +168,110 @@ func New(overKubernetesClient overkube.Client, underKubeconfigSecretName, namesp
 	returnedSecret, err := overKubernetesClient.GetSecret(underKubeconfigSecretName, namespace)
 	if err != nil {
 		if apimachineryerrors.IsNotFound(err) {
-			return nil, machineapiapierrors.InvalidMachineConfiguration("Underkube credentials secret %s/%s: %v not found", namespace, underKubeconfigSecretName, err)
+			return nil, credentialsError("Underkube credentials secret %s/%s: %v not found", namespace, underKubeconfigSecretName, err)
 		}
 		return nil, err
 	}
 	underKubeConfig, ok := returnedSecret.Data[underKubeConfig]
 	if !ok {
-		return nil, machineapiapierrors.InvalidMachineConfiguration("Underkube credentials secret %v did not contain key %v",
+		return nil, credentialsError("Underkube credentials secret %v did not contain key %v",
 			underKubeconfigSecretName, underKubeConfig)
 	}
 
-	clientConfig, err := clientcmd.NewClientConfigFromBytes(underKubeConfig)
+	c, err := newFromKubeconfig(underKubeConfig, fmt.Sprintf("Underkube credentials secret %v", underKubeconfigSecretName))
 	if err != nil {
 		return nil, err
 	}
-	kubevirtClient, getClientErr := kubecli.GetKubevirtClientFromClientConfig(clientConfig)
-	if getClientErr != nil {
-		return nil, getClientErr
+	c.allowedNamespaces = parseList(returnedSecret.Annotations[AllowedNamespacesAnnotation])
+	return c, nil
+}
+
+// parseList splits a comma separated list, ignoring the empty items
+func parseList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// NewFromFile returns a ClientBuilderFuncType building the clients from the kubeconfig file at path instead
+// of the machine credentials secret, for deployments managing the credentials outside of Kubernetes.
+// The file is read on every build, so a rotated file is picked up when the client is rebuilt.
+func NewFromFile(path string) ClientBuilderFuncType {
+	return func(_ overkube.Client, _, _ string) (Client, error) {
+		underKubeConfig, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, credentialsError("Underkube kubeconfig file: %v", err)
+		}
+		c, err := newFromKubeconfig(underKubeConfig, fmt.Sprintf("Underkube kubeconfig file %v", path))
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// newFromKubeconfig builds the client from the kubeconfig content, source describes where it comes from
+func newFromKubeconfig(underKubeConfig []byte, source string) (*client, error) {
+	clientConfig, err := clientcmd.NewClientConfigFromBytes(underKubeConfig)
+	if err != nil {
+		return nil, credentialsError("%s: invalid kubeconfig: %v", source, err)
 	}
 	restClientConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, credentialsError("%s: invalid kubeconfig: %v", source, err)
+	}
+	c, err := newFromRESTConfig(restClientConfig)
 	if err != nil {
 		return nil, err
 	}
-	kubernetesClient, err := kubernetes.NewForConfig(restClientConfig)
+	// The namespace ClientConfig resolves falls back to "default", only an explicit one is a default here
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, credentialsError("%s: invalid kubeconfig: %v", source, err)
+	}
+	if context, ok := rawConfig.Contexts[rawConfig.CurrentContext]; ok {
+		c.defaultNamespace = context.Namespace
+	}
+	return c, nil
+}
+
+// NewInCluster returns a ClientBuilderFuncType for the in-cluster mode, where the VMs run on the management
+// cluster itself. The client is built once from the management cluster config, and shared by all the
+// machines regardless of their credentials secret.
+func NewInCluster(config *rest.Config) (ClientBuilderFuncType, error) {
+	client, err := newFromRESTConfig(rest.CopyConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	return func(overkube.Client, string, string) (Client, error) {
+		return client, nil
+	}, nil
+}
+
+func newFromRESTConfig(restClientConfig *rest.Config) (*client, error) {
+	// The request rate is shaped by the underkube API Priority and Fairness feedback, up to the QPS of the
+	// clients. The faults are injected below the throttle, which sees them as the answers of the underkube.
+	restClientConfig.WrapTransport = transport.Wrappers(defaultFaults.wrapTransport, defaultThrottle.wrapTransport)
+
+	// GetKubevirtClientFromRESTConfig overrides the serialization settings of the config it is given. The
+	// clients it nests share the rate limiter of the config.
+	kubevirtClientConfig := rest.CopyConfig(restClientConfig)
+	kubevirtClientConfig.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(throttleMaxQPS, throttleBurst)
+	kubevirtClient, getClientErr := kubecli.GetKubevirtClientFromRESTConfig(kubevirtClientConfig)
+	if getClientErr != nil {
+		return nil, getClientErr
+	}
+	// Core resources (secrets, services, pods) support protobuf, which is cheaper to serialize than json.
+	// This must not leak into the KubeVirt client config since custom resources are served as json only.
+	kubernetesClientConfig := rest.CopyConfig(restClientConfig)
+	kubernetesClientConfig.RateLimiter = flowcontrol.NewTokenBucketRateLimiter(throttleMaxQPS, throttleBurst)
+	kubernetesClientConfig.ContentType = runtime.ContentTypeProtobuf
+	kubernetesClientConfig.AcceptContentTypes = strings.Join([]string{runtime.ContentTypeProtobuf, runtime.ContentTypeJSON}, ",")
+	kubernetesClient, err := kubernetes.NewForConfig(kubernetesClientConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -107,6 +281,14 @@ func New(overKubernetesClient overkube.Client, underKubeconfigSecretName, namesp
 	}, nil
 }
 
+func (c *client) DefaultNamespace() string {
+	return c.defaultNamespace
+}
+
+func (c *client) AllowedNamespaces() []string {
+	return c.allowedNamespaces
+}
+
 func (c *client) CreateVirtualMachine(namespace string, newVM *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
 	return c.kubevirtClient.VirtualMachine(namespace).Create(newVM)
 }
@@ -123,8 +305,68 @@ func (c *client) GetVirtualMachineInstance(namespace string, name string, option
 	return c.kubevirtClient.VirtualMachineInstance(namespace).Get(name, options)
 }
 
+// ListVirtualMachine pages through the virtual machines of the namespace and returns all of them
 func (c *client) ListVirtualMachine(namespace string, options *k8smetav1.ListOptions) (*kubevirtapiv1.VirtualMachineList, error) {
-	return c.kubevirtClient.VirtualMachine(namespace).List(options)
+	if options == nil {
+		options = &k8smetav1.ListOptions{}
+	}
+	result := &kubevirtapiv1.VirtualMachineList{}
+	err := listPages(*options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kubevirtClient.VirtualMachine(namespace).List(&pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+// ListVirtualMachineInstance pages through the virtual machine instances of the namespace, all namespaces
+// when empty, and returns all of them. The label selector and the metadata.name and metadata.namespace
+// field selectors of the options filter them.
+func (c *client) ListVirtualMachineInstance(namespace string, options *k8smetav1.ListOptions) (*kubevirtapiv1.VirtualMachineInstanceList, error) {
+	if options == nil {
+		options = &k8smetav1.ListOptions{}
+	}
+	result := &kubevirtapiv1.VirtualMachineInstanceList{}
+	err := listPages(*options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kubevirtClient.VirtualMachineInstance(namespace).List(&pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+// WatchVirtualMachineInstance watches the virtual machine instances of the namespace, all namespaces when
+// empty, from the resource version of the options. The KubeVirt client has no watch, it goes through its
+// REST client, which decodes the KubeVirt types.
+func (c *client) WatchVirtualMachineInstance(namespace string, options k8smetav1.ListOptions) (watch.Interface, error) {
+	options.Watch = true
+	return c.kubevirtClient.RestClient().Get().
+		Resource(virtualMachineInstancesResource).
+		Namespace(namespace).
+		VersionedParams(&options, scheme.ParameterCodec).
+		Watch()
 }
 
 func (c *client) UpdateVirtualMachine(namespace string, vm *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
@@ -147,6 +389,14 @@ func (c *client) StopVirtualMachine(namespace string, name string) error {
 	return c.kubevirtClient.VirtualMachine(namespace).Stop(name)
 }
 
+func (c *client) PauseVirtualMachineInstance(namespace string, name string) error {
+	return c.kubevirtClient.VirtualMachineInstance(namespace).Pause(name)
+}
+
+func (c *client) UnpauseVirtualMachineInstance(namespace string, name string) error {
+	return c.kubevirtClient.VirtualMachineInstance(namespace).Unpause(name)
+}
+
 func (c *client) CreateService(service *corev1.Service, namespace string) (*corev1.Service, error) {
 	return c.kuberentesClient.CoreV1().Services(namespace).Create(service)
 }
@@ -162,3 +412,290 @@ func (c *client) UpdateService(service *corev1.Service, namespace string) (*core
 func (c *client) GetService(serviceName string, namespace string, options k8smetav1.GetOptions) (*corev1.Service, error) {
 	return c.kuberentesClient.CoreV1().Services(namespace).Get(serviceName, options)
 }
+
+func (c *client) GetEndpoints(name string, namespace string, options k8smetav1.GetOptions) (*corev1.Endpoints, error) {
+	return c.kuberentesClient.CoreV1().Endpoints(namespace).Get(name, options)
+}
+
+func (c *client) CreateEndpoints(endpoints *corev1.Endpoints, namespace string) (*corev1.Endpoints, error) {
+	return c.kuberentesClient.CoreV1().Endpoints(namespace).Create(endpoints)
+}
+
+func (c *client) UpdateEndpoints(endpoints *corev1.Endpoints, namespace string) (*corev1.Endpoints, error) {
+	return c.kuberentesClient.CoreV1().Endpoints(namespace).Update(endpoints)
+}
+
+func (c *client) GetPersistentVolumeClaim(pvcName string, namespace string, options k8smetav1.GetOptions) (*corev1.PersistentVolumeClaim, error) {
+	return c.kuberentesClient.CoreV1().PersistentVolumeClaims(namespace).Get(pvcName, options)
+}
+
+func (c *client) UpdatePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaim, namespace string) (*corev1.PersistentVolumeClaim, error) {
+	return c.kuberentesClient.CoreV1().PersistentVolumeClaims(namespace).Update(pvc)
+}
+
+func (c *client) GetStorageClass(storageClassName string, options k8smetav1.GetOptions) (*storagev1.StorageClass, error) {
+	return c.kuberentesClient.StorageV1().StorageClasses().Get(storageClassName, options)
+}
+
+func (c *client) GetNode(nodeName string, options k8smetav1.GetOptions) (*corev1.Node, error) {
+	return c.kuberentesClient.CoreV1().Nodes().Get(nodeName, options)
+}
+
+func (c *client) ListNodes(options k8smetav1.ListOptions) (*corev1.NodeList, error) {
+	result := &corev1.NodeList{}
+	err := listPages(options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kuberentesClient.CoreV1().Nodes().List(pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+func (c *client) CreateSecret(secret *corev1.Secret, namespace string) (*corev1.Secret, error) {
+	return c.kuberentesClient.CoreV1().Secrets(namespace).Create(secret)
+}
+
+func (c *client) DeleteSecret(secretName string, namespace string, options *k8smetav1.DeleteOptions) error {
+	return c.kuberentesClient.CoreV1().Secrets(namespace).Delete(secretName, options)
+}
+
+func (c *client) UpdateSecret(secret *corev1.Secret, namespace string) (*corev1.Secret, error) {
+	return c.kuberentesClient.CoreV1().Secrets(namespace).Update(secret)
+}
+
+func (c *client) GetSecret(secretName string, namespace string, options k8smetav1.GetOptions) (*corev1.Secret, error) {
+	return c.kuberentesClient.CoreV1().Secrets(namespace).Get(secretName, options)
+}
+
+func (c *client) CreateRole(role *rbacv1.Role, namespace string) (*rbacv1.Role, error) {
+	return c.kuberentesClient.RbacV1().Roles(namespace).Create(role)
+}
+
+func (c *client) GetRole(roleName string, namespace string, options k8smetav1.GetOptions) (*rbacv1.Role, error) {
+	return c.kuberentesClient.RbacV1().Roles(namespace).Get(roleName, options)
+}
+
+func (c *client) CreateRoleBinding(roleBinding *rbacv1.RoleBinding, namespace string) (*rbacv1.RoleBinding, error) {
+	return c.kuberentesClient.RbacV1().RoleBindings(namespace).Create(roleBinding)
+}
+
+func (c *client) GetRoleBinding(roleBindingName string, namespace string, options k8smetav1.GetOptions) (*rbacv1.RoleBinding, error) {
+	return c.kuberentesClient.RbacV1().RoleBindings(namespace).Get(roleBindingName, options)
+}
+
+func (c *client) DeleteRoleBinding(roleBindingName string, namespace string, options *k8smetav1.DeleteOptions) error {
+	return c.kuberentesClient.RbacV1().RoleBindings(namespace).Delete(roleBindingName, options)
+}
+
+func (c *client) CreateIngress(ingress *networkingv1beta1.Ingress, namespace string) (*networkingv1beta1.Ingress, error) {
+	return c.kuberentesClient.NetworkingV1beta1().Ingresses(namespace).Create(ingress)
+}
+
+func (c *client) DeleteIngress(ingressName string, namespace string, options *k8smetav1.DeleteOptions) error {
+	return c.kuberentesClient.NetworkingV1beta1().Ingresses(namespace).Delete(ingressName, options)
+}
+
+func (c *client) UpdateIngress(ingress *networkingv1beta1.Ingress, namespace string) (*networkingv1beta1.Ingress, error) {
+	return c.kuberentesClient.NetworkingV1beta1().Ingresses(namespace).Update(ingress)
+}
+
+func (c *client) GetIngress(ingressName string, namespace string, options k8smetav1.GetOptions) (*networkingv1beta1.Ingress, error) {
+	return c.kuberentesClient.NetworkingV1beta1().Ingresses(namespace).Get(ingressName, options)
+}
+
+// GetDataVolume goes through the KubeVirt REST client, since the CDI clientset is not part of the KubeVirt client
+func (c *client) GetDataVolume(dataVolumeName string, namespace string, options k8smetav1.GetOptions) (*cdiv1.DataVolume, error) {
+	body, err := c.kubevirtClient.RestClient().Get().
+		AbsPath("/apis", cdiv1.SchemeGroupVersion.Group, cdiv1.SchemeGroupVersion.Version, "namespaces", namespace, "datavolumes", dataVolumeName).
+		VersionedParams(&options, k8smetav1.ParameterCodec).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	dataVolume := &cdiv1.DataVolume{}
+	if err := json.Unmarshal(body, dataVolume); err != nil {
+		return nil, err
+	}
+	return dataVolume, nil
+}
+
+func (c *client) GetNamespace(namespaceName string, options k8smetav1.GetOptions) (*corev1.Namespace, error) {
+	return c.kuberentesClient.CoreV1().Namespaces().Get(namespaceName, options)
+}
+
+func (c *client) ServerResourcesForGroupVersion(groupVersion string) (*k8smetav1.APIResourceList, error) {
+	return c.kuberentesClient.Discovery().ServerResourcesForGroupVersion(groupVersion)
+}
+
+func (c *client) GetKubeVirtVersion() (string, error) {
+	info, err := c.kubevirtClient.ServerVersion().Get()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
+
+// GetFeatureGates returns the feature gates enabled in the configuration of the KubeVirt installation
+func (c *client) GetFeatureGates() ([]string, error) {
+	kubevirts, err := c.kubevirtClient.KubeVirt(k8smetav1.NamespaceAll).List(&k8smetav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(kubevirts.Items) == 0 {
+		return nil, apimachineryerrors.NewNotFound(schema.GroupResource{Group: kubevirtapiv1.GroupName, Resource: "kubevirts"}, "")
+	}
+	configMap, err := c.kuberentesClient.CoreV1().ConfigMaps(kubevirts.Items[0].Namespace).Get(kubevirtConfigMapName, k8smetav1.GetOptions{})
+	if apimachineryerrors.IsNotFound(err) {
+		// Nothing configured, no feature gate is enabled
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseList(configMap.Data[featureGatesKey]), nil
+}
+
+func (c *client) GetMutatingWebhookConfiguration(name string, options k8smetav1.GetOptions) (*admissionregistrationv1beta1.MutatingWebhookConfiguration, error) {
+	return c.kuberentesClient.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().Get(name, options)
+}
+
+// ListServices pages through the services of the namespace and returns all of them
+func (c *client) ListServices(namespace string, options k8smetav1.ListOptions) (*corev1.ServiceList, error) {
+	result := &corev1.ServiceList{}
+	err := listPages(options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kuberentesClient.CoreV1().Services(namespace).List(pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+func (c *client) ListPods(namespace string, options k8smetav1.ListOptions) (*corev1.PodList, error) {
+	result := &corev1.PodList{}
+	err := listPages(options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kuberentesClient.CoreV1().Pods(namespace).List(pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+func (c *client) ListSecrets(namespace string, options k8smetav1.ListOptions) (*corev1.SecretList, error) {
+	result := &corev1.SecretList{}
+	err := listPages(options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kuberentesClient.CoreV1().Secrets(namespace).List(pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+func (c *client) ListIngresses(namespace string, options k8smetav1.ListOptions) (*networkingv1beta1.IngressList, error) {
+	result := &networkingv1beta1.IngressList{}
+	err := listPages(options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kuberentesClient.NetworkingV1beta1().Ingresses(namespace).List(pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+func (c *client) ListPersistentVolumeClaims(namespace string, options k8smetav1.ListOptions) (*corev1.PersistentVolumeClaimList, error) {
+	result := &corev1.PersistentVolumeClaimList{}
+	err := listPages(options, func(pageOptions k8smetav1.ListOptions) (string, error) {
+		page, err := c.kuberentesClient.CoreV1().PersistentVolumeClaims(namespace).List(pageOptions)
+		if err != nil {
+			return "", err
+		}
+		if pageOptions.Continue == "" {
+			result.Items = nil
+			result.ListMeta = page.ListMeta
+		}
+		result.Items = append(result.Items, page.Items...)
+		return page.Continue, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Continue = ""
+	return result, nil
+}
+
+// listPages calls listPage with Limit/Continue set until the underkube reports there are no more pages.
+// If the continue token expires in the middle of the listing, the listing is restarted without pagination
+// so the result is still a consistent snapshot.
+func listPages(options k8smetav1.ListOptions, listPage func(pageOptions k8smetav1.ListOptions) (string, error)) error {
+	if options.Limit == 0 {
+		options.Limit = listPageSize
+	}
+	for {
+		continueToken, err := listPage(options)
+		if err != nil {
+			if apimachineryerrors.IsResourceExpired(err) && options.Continue != "" {
+				options.Limit = 0
+				options.Continue = ""
+				_, err = listPage(options)
+			}
+			return err
+		}
+		if continueToken == "" {
+			return nil
+		}
+		options.Continue = continueToken
+	}
+}
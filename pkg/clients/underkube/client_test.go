@@ -0,0 +1,153 @@
+package underkube
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestListPages(t *testing.T) {
+	cases := []struct {
+		name          string
+		pages         map[string]string
+		expireOnToken string
+		wantRequests  []k8smetav1.ListOptions
+	}{
+		{
+			name:  "follow continue tokens",
+			pages: map[string]string{"": "page-2", "page-2": "page-3", "page-3": ""},
+			wantRequests: []k8smetav1.ListOptions{
+				{Limit: listPageSize},
+				{Limit: listPageSize, Continue: "page-2"},
+				{Limit: listPageSize, Continue: "page-3"},
+			},
+		},
+		{
+			name:          "restart without pagination when the token expires",
+			pages:         map[string]string{"": "page-2"},
+			expireOnToken: "page-2",
+			wantRequests: []k8smetav1.ListOptions{
+				{Limit: listPageSize},
+				{Limit: listPageSize, Continue: "page-2"},
+				{},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []k8smetav1.ListOptions
+			err := listPages(k8smetav1.ListOptions{}, func(pageOptions k8smetav1.ListOptions) (string, error) {
+				requests = append(requests, pageOptions)
+				if tc.expireOnToken != "" && pageOptions.Continue == tc.expireOnToken {
+					return "", apimachineryerrors.NewResourceExpired("continue token expired")
+				}
+				if pageOptions.Limit == 0 {
+					return "", nil
+				}
+				return tc.pages[pageOptions.Continue], nil
+			})
+			assert.NilError(t, err)
+			assert.DeepEqual(t, tc.wantRequests, requests)
+		})
+	}
+}
+
+func TestNewFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "underkube")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "kubeconfig")
+	builder := NewFromFile(path)
+
+	_, err = builder(nil, "", "")
+	assert.Assert(t, IsCredentialsError(err))
+
+	assert.NilError(t, ioutil.WriteFile(path, []byte("not a kubeconfig"), 0600))
+	_, err = builder(nil, "", "")
+	assert.Assert(t, IsCredentialsError(err))
+
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+- name: infra
+  cluster:
+    server: https://infra.example.com:6443
+users:
+- name: provider
+  user:
+    token: secret-token
+contexts:
+- name: infra
+  context:
+    cluster: infra
+    user: provider
+    namespace: tenant-1
+current-context: infra
+`
+	assert.NilError(t, ioutil.WriteFile(path, []byte(kubeconfig), 0600))
+	client, err := builder(nil, "", "")
+	assert.NilError(t, err)
+	assert.Equal(t, client.DefaultNamespace(), "tenant-1")
+}
+
+func TestParseList(t *testing.T) {
+	assert.Assert(t, parseList("") == nil)
+	assert.DeepEqual(t, parseList("tenant-1, tenant-1-vms,,"), []string{"tenant-1", "tenant-1-vms"})
+}
+
+func TestNewInCluster(t *testing.T) {
+	config := &rest.Config{Host: "https://management.example.com:6443", BearerToken: "secret-token"}
+	builder, err := NewInCluster(config)
+	assert.NilError(t, err)
+	assert.Assert(t, config.WrapTransport == nil, "the management cluster config was modified")
+
+	first, err := builder(nil, "", "cluster-1")
+	assert.NilError(t, err)
+	second, err := builder(nil, "kubeconfig", "cluster-2")
+	assert.NilError(t, err)
+	assert.Assert(t, first == second, "the in-cluster client was not shared")
+}
+
+func TestVirtualMachineInstanceListAndWatch(t *testing.T) {
+	const vmi = `{"apiVersion":"kubevirt.io/v1alpha3","kind":"VirtualMachineInstance","metadata":{"name":"worker-0","namespace":"tenant-1","resourceVersion":"%s"},"status":{"nodeName":"host-1"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/kubevirt.io/v1alpha3/namespaces/tenant-1/virtualmachineinstances" || r.URL.Query().Get("labelSelector") != "machine.openshift.io/cluster-api-cluster=tenant" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("watch") == "true" {
+			fmt.Fprintf(w, `{"type":"MODIFIED","object":`+vmi+`}`, "2")
+			return
+		}
+		fmt.Fprintf(w, `{"apiVersion":"kubevirt.io/v1alpha3","kind":"VirtualMachineInstanceList","metadata":{"resourceVersion":"1"},"items":[`+vmi+`]}`, "1")
+	}))
+	defer server.Close()
+	c, err := newFromRESTConfig(&rest.Config{Host: server.URL})
+	assert.NilError(t, err)
+	options := k8smetav1.ListOptions{LabelSelector: "machine.openshift.io/cluster-api-cluster=tenant"}
+
+	list, err := c.ListVirtualMachineInstance("tenant-1", &options)
+	assert.NilError(t, err)
+	assert.Equal(t, len(list.Items), 1)
+	assert.Equal(t, list.Items[0].Status.NodeName, "host-1")
+
+	options.ResourceVersion = list.ResourceVersion
+	watcher, err := c.WatchVirtualMachineInstance("tenant-1", options)
+	assert.NilError(t, err)
+	defer watcher.Stop()
+	event := <-watcher.ResultChan()
+	assert.Equal(t, event.Type, watch.Modified)
+	assert.Equal(t, event.Object.(*kubevirtapiv1.VirtualMachineInstance).ResourceVersion, "2")
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package underkube
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// injectedErrorFault fails the request before it reaches the underkube, injectedPartialFailureFault after
+	injectedErrorFault          = "error"
+	injectedPartialFailureFault = "partial-failure"
+)
+
+var (
+	injectedFaultsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kubevirt_underkube_injected_faults_total",
+			Help: "Number of underkube requests failed on purpose by the fault injection, per verb and fault.",
+		},
+		[]string{"verb", "fault"},
+	)
+
+	// defaultFaults is shared by all the underkube clients like the throttle, the provider config controller
+	// sets its rules
+	defaultFaults = newFaultInjector()
+)
+
+func init() {
+	metrics.Registry.MustRegister(injectedFaultsCounter)
+}
+
+// SetFaultInjection replaces the faults injected in the requests of all the underkube clients, nil disables
+// the injection
+func SetFaultInjection(config *kubevirtproviderv1.FaultInjection) {
+	defaultFaults.set(config)
+}
+
+// faultInjector fails or delays the underkube requests matching its rules
+type faultInjector struct {
+	lock  sync.RWMutex
+	rules []kubevirtproviderv1.FaultInjectionRule
+	// random returns a number in [0, 100)
+	random func() float64
+	sleep  func(req *http.Request, d time.Duration) error
+}
+
+func newFaultInjector() *faultInjector {
+	return &faultInjector{
+		random: func() float64 { return rand.Float64() * 100 },
+		sleep:  sleepContext,
+	}
+}
+
+func (f *faultInjector) set(config *kubevirtproviderv1.FaultInjection) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if config == nil || len(config.Rules) == 0 {
+		if f.rules != nil {
+			klog.Infof("Underkube fault injection disabled")
+		}
+		f.rules = nil
+		return
+	}
+	f.rules = config.DeepCopy().Rules
+	klog.Warningf("Underkube fault injection enabled with %d rules", len(f.rules))
+}
+
+// ruleFor returns the first rule matching the verb of the request, nil when there is none
+func (f *faultInjector) ruleFor(req *http.Request) *kubevirtproviderv1.FaultInjectionRule {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	for i := range f.rules {
+		if len(f.rules[i].Verbs) == 0 {
+			return &f.rules[i]
+		}
+		for _, verb := range f.rules[i].Verbs {
+			if strings.EqualFold(verb, req.Method) {
+				return &f.rules[i]
+			}
+		}
+	}
+	return nil
+}
+
+// wrapTransport returns a transport.WrapperFunc to be set on the underkube rest config, below the throttle
+func (f *faultInjector) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &faultInjectingRoundTripper{delegate: rt, faults: f}
+}
+
+type faultInjectingRoundTripper struct {
+	delegate http.RoundTripper
+	faults   *faultInjector
+}
+
+func (rt *faultInjectingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rule := rt.faults.ruleFor(req)
+	if rule == nil {
+		return rt.delegate.RoundTrip(req)
+	}
+	if rule.Latency.Duration > 0 {
+		if err := rt.faults.sleep(req, rule.Latency.Duration); err != nil {
+			return nil, err
+		}
+	}
+	if rt.faults.random() < float64(rule.ErrorPercent) {
+		injectedFaultsCounter.WithLabelValues(req.Method, injectedErrorFault).Inc()
+		return injectedFaultResponse(req, injectedErrorFault), nil
+	}
+
+	resp, err := rt.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if rt.faults.random() < float64(rule.PartialFailurePercent) {
+		resp.Body.Close()
+		injectedFaultsCounter.WithLabelValues(req.Method, injectedPartialFailureFault).Inc()
+		return injectedFaultResponse(req, injectedPartialFailureFault), nil
+	}
+	return resp, nil
+}
+
+// injectedFaultResponse returns the 503 response of an injected fault, with the Status body the clients
+// turn into a ServiceUnavailable error
+func injectedFaultResponse(req *http.Request, fault string) *http.Response {
+	status := k8smetav1.Status{
+		TypeMeta: k8smetav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   k8smetav1.StatusFailure,
+		Message:  fmt.Sprintf("%s injected by the provider fault injection", fault),
+		Reason:   k8smetav1.StatusReasonServiceUnavailable,
+		Code:     http.StatusServiceUnavailable,
+	}
+	body, _ := json.Marshal(status)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", http.StatusServiceUnavailable, http.StatusText(http.StatusServiceUnavailable)),
+		StatusCode:    http.StatusServiceUnavailable,
+		Proto:         req.Proto,
+		ProtoMajor:    req.ProtoMajor,
+		ProtoMinor:    req.ProtoMinor,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func sleepContext(req *http.Request, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-req.Context().Done():
+		return req.Context().Err()
+	case <-timer.C:
+		return nil
+	}
+}
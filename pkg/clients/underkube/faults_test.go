@@ -0,0 +1,65 @@
+package underkube
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestFaultInjection(t *testing.T) {
+	received := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received[r.Method]++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"Service","apiVersion":"v1","metadata":{"name":"machine-1","namespace":"cluster-1"}}`))
+	}))
+	defer server.Close()
+
+	faults := newFaultInjector()
+	var slept time.Duration
+	faults.sleep = func(_ *http.Request, d time.Duration) error {
+		slept += d
+		return nil
+	}
+	config := &rest.Config{Host: server.URL, WrapTransport: faults.wrapTransport}
+	client, err := kubernetes.NewForConfig(config)
+	assert.NilError(t, err)
+	services := client.CoreV1().Services("cluster-1")
+
+	// No rule, the requests go through
+	_, err = services.Get("machine-1", k8smetav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, received[http.MethodGet], 1)
+
+	faults.set(&kubevirtproviderv1.FaultInjection{Rules: []kubevirtproviderv1.FaultInjectionRule{
+		{Verbs: []string{"delete"}, ErrorPercent: 100},
+		{Verbs: []string{"PUT"}, PartialFailurePercent: 100},
+		{Latency: k8smetav1.Duration{Duration: time.Second}},
+	}})
+
+	err = services.Delete("machine-1", &k8smetav1.DeleteOptions{})
+	assert.Assert(t, apimachineryerrors.IsServiceUnavailable(err), "unexpected error %v", err)
+	assert.Equal(t, received[http.MethodDelete], 0)
+
+	_, err = services.Update(&corev1.Service{ObjectMeta: k8smetav1.ObjectMeta{Name: "machine-1", Namespace: "cluster-1"}})
+	assert.Assert(t, apimachineryerrors.IsServiceUnavailable(err), "unexpected error %v", err)
+	assert.Equal(t, received[http.MethodPut], 1)
+
+	_, err = services.Get("machine-1", k8smetav1.GetOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, slept, time.Second)
+
+	faults.set(nil)
+	err = services.Delete("machine-1", &k8smetav1.DeleteOptions{})
+	assert.NilError(t, err)
+	assert.Equal(t, received[http.MethodDelete], 1)
+}
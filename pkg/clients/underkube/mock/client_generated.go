@@ -6,10 +6,17 @@ package mock
 
 import (
 	gomock "github.com/golang/mock/gomock"
+	io "io"
+	v1beta1 "k8s.io/api/admissionregistration/v1beta1"
 	v1 "k8s.io/api/core/v1"
-	v10 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	v1beta10 "k8s.io/api/networking/v1beta1"
+	v10 "k8s.io/api/rbac/v1"
+	v11 "k8s.io/api/storage/v1"
+	v12 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	types "k8s.io/apimachinery/pkg/types"
-	v11 "kubevirt.io/client-go/api/v1"
+	watch "k8s.io/apimachinery/pkg/watch"
+	v13 "kubevirt.io/client-go/api/v1"
+	v1alpha1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 	reflect "reflect"
 )
 
@@ -37,10 +44,10 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 }
 
 // CreateVirtualMachine mocks base method
-func (m *MockClient) CreateVirtualMachine(namespace string, newVM *v11.VirtualMachine) (*v11.VirtualMachine, error) {
+func (m *MockClient) CreateVirtualMachine(namespace string, newVM *v13.VirtualMachine) (*v13.VirtualMachine, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "CreateVirtualMachine", namespace, newVM)
-	ret0, _ := ret[0].(*v11.VirtualMachine)
+	ret0, _ := ret[0].(*v13.VirtualMachine)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -52,7 +59,7 @@ func (mr *MockClientMockRecorder) CreateVirtualMachine(namespace, newVM interfac
 }
 
 // DeleteVirtualMachine mocks base method
-func (m *MockClient) DeleteVirtualMachine(namespace, name string, options *v10.DeleteOptions) error {
+func (m *MockClient) DeleteVirtualMachine(namespace, name string, options *v12.DeleteOptions) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DeleteVirtualMachine", namespace, name, options)
 	ret0, _ := ret[0].(error)
@@ -66,10 +73,10 @@ func (mr *MockClientMockRecorder) DeleteVirtualMachine(namespace, name, options
 }
 
 // GetVirtualMachine mocks base method
-func (m *MockClient) GetVirtualMachine(namespace, name string, options *v10.GetOptions) (*v11.VirtualMachine, error) {
+func (m *MockClient) GetVirtualMachine(namespace, name string, options *v12.GetOptions) (*v13.VirtualMachine, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetVirtualMachine", namespace, name, options)
-	ret0, _ := ret[0].(*v11.VirtualMachine)
+	ret0, _ := ret[0].(*v13.VirtualMachine)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -81,10 +88,10 @@ func (mr *MockClientMockRecorder) GetVirtualMachine(namespace, name, options int
 }
 
 // GetVirtualMachineInstance mocks base method
-func (m *MockClient) GetVirtualMachineInstance(namespace, name string, options *v10.GetOptions) (*v11.VirtualMachineInstance, error) {
+func (m *MockClient) GetVirtualMachineInstance(namespace, name string, options *v12.GetOptions) (*v13.VirtualMachineInstance, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetVirtualMachineInstance", namespace, name, options)
-	ret0, _ := ret[0].(*v11.VirtualMachineInstance)
+	ret0, _ := ret[0].(*v13.VirtualMachineInstance)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -96,10 +103,10 @@ func (mr *MockClientMockRecorder) GetVirtualMachineInstance(namespace, name, opt
 }
 
 // ListVirtualMachine mocks base method
-func (m *MockClient) ListVirtualMachine(namespace string, options *v10.ListOptions) (*v11.VirtualMachineList, error) {
+func (m *MockClient) ListVirtualMachine(namespace string, options *v12.ListOptions) (*v13.VirtualMachineList, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "ListVirtualMachine", namespace, options)
-	ret0, _ := ret[0].(*v11.VirtualMachineList)
+	ret0, _ := ret[0].(*v13.VirtualMachineList)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -110,11 +117,41 @@ func (mr *MockClientMockRecorder) ListVirtualMachine(namespace, options interfac
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVirtualMachine", reflect.TypeOf((*MockClient)(nil).ListVirtualMachine), namespace, options)
 }
 
+// ListVirtualMachineInstance mocks base method
+func (m *MockClient) ListVirtualMachineInstance(namespace string, options *v12.ListOptions) (*v13.VirtualMachineInstanceList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVirtualMachineInstance", namespace, options)
+	ret0, _ := ret[0].(*v13.VirtualMachineInstanceList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVirtualMachineInstance indicates an expected call of ListVirtualMachineInstance
+func (mr *MockClientMockRecorder) ListVirtualMachineInstance(namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVirtualMachineInstance", reflect.TypeOf((*MockClient)(nil).ListVirtualMachineInstance), namespace, options)
+}
+
+// WatchVirtualMachineInstance mocks base method
+func (m *MockClient) WatchVirtualMachineInstance(namespace string, options v12.ListOptions) (watch.Interface, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchVirtualMachineInstance", namespace, options)
+	ret0, _ := ret[0].(watch.Interface)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchVirtualMachineInstance indicates an expected call of WatchVirtualMachineInstance
+func (mr *MockClientMockRecorder) WatchVirtualMachineInstance(namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchVirtualMachineInstance", reflect.TypeOf((*MockClient)(nil).WatchVirtualMachineInstance), namespace, options)
+}
+
 // UpdateVirtualMachine mocks base method
-func (m *MockClient) UpdateVirtualMachine(namespace string, vm *v11.VirtualMachine) (*v11.VirtualMachine, error) {
+func (m *MockClient) UpdateVirtualMachine(namespace string, vm *v13.VirtualMachine) (*v13.VirtualMachine, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UpdateVirtualMachine", namespace, vm)
-	ret0, _ := ret[0].(*v11.VirtualMachine)
+	ret0, _ := ret[0].(*v13.VirtualMachine)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -126,14 +163,14 @@ func (mr *MockClientMockRecorder) UpdateVirtualMachine(namespace, vm interface{}
 }
 
 // PatchVirtualMachine mocks base method
-func (m *MockClient) PatchVirtualMachine(namespace, name string, pt types.PatchType, data []byte, subresources ...string) (*v11.VirtualMachine, error) {
+func (m *MockClient) PatchVirtualMachine(namespace, name string, pt types.PatchType, data []byte, subresources ...string) (*v13.VirtualMachine, error) {
 	m.ctrl.T.Helper()
 	varargs := []interface{}{namespace, name, pt, data}
 	for _, a := range subresources {
 		varargs = append(varargs, a)
 	}
 	ret := m.ctrl.Call(m, "PatchVirtualMachine", varargs...)
-	ret0, _ := ret[0].(*v11.VirtualMachine)
+	ret0, _ := ret[0].(*v13.VirtualMachine)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -187,6 +224,49 @@ func (mr *MockClientMockRecorder) StopVirtualMachine(namespace, name interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StopVirtualMachine", reflect.TypeOf((*MockClient)(nil).StopVirtualMachine), namespace, name)
 }
 
+// PauseVirtualMachineInstance mocks base method
+func (m *MockClient) PauseVirtualMachineInstance(namespace, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseVirtualMachineInstance", namespace, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PauseVirtualMachineInstance indicates an expected call of PauseVirtualMachineInstance
+func (mr *MockClientMockRecorder) PauseVirtualMachineInstance(namespace, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseVirtualMachineInstance", reflect.TypeOf((*MockClient)(nil).PauseVirtualMachineInstance), namespace, name)
+}
+
+// UnpauseVirtualMachineInstance mocks base method
+func (m *MockClient) UnpauseVirtualMachineInstance(namespace, name string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnpauseVirtualMachineInstance", namespace, name)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnpauseVirtualMachineInstance indicates an expected call of UnpauseVirtualMachineInstance
+func (mr *MockClientMockRecorder) UnpauseVirtualMachineInstance(namespace, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnpauseVirtualMachineInstance", reflect.TypeOf((*MockClient)(nil).UnpauseVirtualMachineInstance), namespace, name)
+}
+
+// PortForwardVirtualMachineInstance mocks base method
+func (m *MockClient) PortForwardVirtualMachineInstance(namespace, name string, port int) (io.ReadWriteCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PortForwardVirtualMachineInstance", namespace, name, port)
+	ret0, _ := ret[0].(io.ReadWriteCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PortForwardVirtualMachineInstance indicates an expected call of PortForwardVirtualMachineInstance
+func (mr *MockClientMockRecorder) PortForwardVirtualMachineInstance(namespace, name, port interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PortForwardVirtualMachineInstance", reflect.TypeOf((*MockClient)(nil).PortForwardVirtualMachineInstance), namespace, name, port)
+}
+
 // CreateService mocks base method
 func (m *MockClient) CreateService(service *v1.Service, namespace string) (*v1.Service, error) {
 	m.ctrl.T.Helper()
@@ -203,7 +283,7 @@ func (mr *MockClientMockRecorder) CreateService(service, namespace interface{})
 }
 
 // DeleteService mocks base method
-func (m *MockClient) DeleteService(serviceName, namespace string, options *v10.DeleteOptions) error {
+func (m *MockClient) DeleteService(serviceName, namespace string, options *v12.DeleteOptions) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "DeleteService", serviceName, namespace, options)
 	ret0, _ := ret[0].(error)
@@ -232,7 +312,7 @@ func (mr *MockClientMockRecorder) UpdateService(service, namespace interface{})
 }
 
 // GetService mocks base method
-func (m *MockClient) GetService(serviceName, namespace string, options v10.GetOptions) (*v1.Service, error) {
+func (m *MockClient) GetService(serviceName, namespace string, options v12.GetOptions) (*v1.Service, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetService", serviceName, namespace, options)
 	ret0, _ := ret[0].(*v1.Service)
@@ -245,3 +325,508 @@ func (mr *MockClientMockRecorder) GetService(serviceName, namespace, options int
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetService", reflect.TypeOf((*MockClient)(nil).GetService), serviceName, namespace, options)
 }
+
+// ListServices mocks base method
+func (m *MockClient) ListServices(namespace string, options v12.ListOptions) (*v1.ServiceList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListServices", namespace, options)
+	ret0, _ := ret[0].(*v1.ServiceList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListServices indicates an expected call of ListServices
+func (mr *MockClientMockRecorder) ListServices(namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListServices", reflect.TypeOf((*MockClient)(nil).ListServices), namespace, options)
+}
+
+// GetEndpoints mocks base method
+func (m *MockClient) GetEndpoints(name, namespace string, options v12.GetOptions) (*v1.Endpoints, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEndpoints", name, namespace, options)
+	ret0, _ := ret[0].(*v1.Endpoints)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetEndpoints indicates an expected call of GetEndpoints
+func (mr *MockClientMockRecorder) GetEndpoints(name, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEndpoints", reflect.TypeOf((*MockClient)(nil).GetEndpoints), name, namespace, options)
+}
+
+// CreateEndpoints mocks base method
+func (m *MockClient) CreateEndpoints(endpoints *v1.Endpoints, namespace string) (*v1.Endpoints, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateEndpoints", endpoints, namespace)
+	ret0, _ := ret[0].(*v1.Endpoints)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateEndpoints indicates an expected call of CreateEndpoints
+func (mr *MockClientMockRecorder) CreateEndpoints(endpoints, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEndpoints", reflect.TypeOf((*MockClient)(nil).CreateEndpoints), endpoints, namespace)
+}
+
+// UpdateEndpoints mocks base method
+func (m *MockClient) UpdateEndpoints(endpoints *v1.Endpoints, namespace string) (*v1.Endpoints, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateEndpoints", endpoints, namespace)
+	ret0, _ := ret[0].(*v1.Endpoints)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateEndpoints indicates an expected call of UpdateEndpoints
+func (mr *MockClientMockRecorder) UpdateEndpoints(endpoints, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateEndpoints", reflect.TypeOf((*MockClient)(nil).UpdateEndpoints), endpoints, namespace)
+}
+
+// ListPods mocks base method
+func (m *MockClient) ListPods(namespace string, options v12.ListOptions) (*v1.PodList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPods", namespace, options)
+	ret0, _ := ret[0].(*v1.PodList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPods indicates an expected call of ListPods
+func (mr *MockClientMockRecorder) ListPods(namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPods", reflect.TypeOf((*MockClient)(nil).ListPods), namespace, options)
+}
+
+// ListSecrets mocks base method
+func (m *MockClient) ListSecrets(namespace string, options v12.ListOptions) (*v1.SecretList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSecrets", namespace, options)
+	ret0, _ := ret[0].(*v1.SecretList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSecrets indicates an expected call of ListSecrets
+func (mr *MockClientMockRecorder) ListSecrets(namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSecrets", reflect.TypeOf((*MockClient)(nil).ListSecrets), namespace, options)
+}
+
+// ListIngresses mocks base method
+func (m *MockClient) ListIngresses(namespace string, options v12.ListOptions) (*v1beta10.IngressList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListIngresses", namespace, options)
+	ret0, _ := ret[0].(*v1beta10.IngressList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListIngresses indicates an expected call of ListIngresses
+func (mr *MockClientMockRecorder) ListIngresses(namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListIngresses", reflect.TypeOf((*MockClient)(nil).ListIngresses), namespace, options)
+}
+
+// ListPersistentVolumeClaims mocks base method
+func (m *MockClient) ListPersistentVolumeClaims(namespace string, options v12.ListOptions) (*v1.PersistentVolumeClaimList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPersistentVolumeClaims", namespace, options)
+	ret0, _ := ret[0].(*v1.PersistentVolumeClaimList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPersistentVolumeClaims indicates an expected call of ListPersistentVolumeClaims
+func (mr *MockClientMockRecorder) ListPersistentVolumeClaims(namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPersistentVolumeClaims", reflect.TypeOf((*MockClient)(nil).ListPersistentVolumeClaims), namespace, options)
+}
+
+// GetPersistentVolumeClaim mocks base method
+func (m *MockClient) GetPersistentVolumeClaim(pvcName, namespace string, options v12.GetOptions) (*v1.PersistentVolumeClaim, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPersistentVolumeClaim", pvcName, namespace, options)
+	ret0, _ := ret[0].(*v1.PersistentVolumeClaim)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPersistentVolumeClaim indicates an expected call of GetPersistentVolumeClaim
+func (mr *MockClientMockRecorder) GetPersistentVolumeClaim(pvcName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPersistentVolumeClaim", reflect.TypeOf((*MockClient)(nil).GetPersistentVolumeClaim), pvcName, namespace, options)
+}
+
+// UpdatePersistentVolumeClaim mocks base method
+func (m *MockClient) UpdatePersistentVolumeClaim(pvc *v1.PersistentVolumeClaim, namespace string) (*v1.PersistentVolumeClaim, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePersistentVolumeClaim", pvc, namespace)
+	ret0, _ := ret[0].(*v1.PersistentVolumeClaim)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdatePersistentVolumeClaim indicates an expected call of UpdatePersistentVolumeClaim
+func (mr *MockClientMockRecorder) UpdatePersistentVolumeClaim(pvc, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePersistentVolumeClaim", reflect.TypeOf((*MockClient)(nil).UpdatePersistentVolumeClaim), pvc, namespace)
+}
+
+// GetStorageClass mocks base method
+func (m *MockClient) GetStorageClass(storageClassName string, options v12.GetOptions) (*v11.StorageClass, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetStorageClass", storageClassName, options)
+	ret0, _ := ret[0].(*v11.StorageClass)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetStorageClass indicates an expected call of GetStorageClass
+func (mr *MockClientMockRecorder) GetStorageClass(storageClassName, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetStorageClass", reflect.TypeOf((*MockClient)(nil).GetStorageClass), storageClassName, options)
+}
+
+// GetNode mocks base method
+func (m *MockClient) GetNode(nodeName string, options v12.GetOptions) (*v1.Node, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNode", nodeName, options)
+	ret0, _ := ret[0].(*v1.Node)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNode indicates an expected call of GetNode
+func (mr *MockClientMockRecorder) GetNode(nodeName, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNode", reflect.TypeOf((*MockClient)(nil).GetNode), nodeName, options)
+}
+
+// ListNodes mocks base method
+func (m *MockClient) ListNodes(options v12.ListOptions) (*v1.NodeList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListNodes", options)
+	ret0, _ := ret[0].(*v1.NodeList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListNodes indicates an expected call of ListNodes
+func (mr *MockClientMockRecorder) ListNodes(options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListNodes", reflect.TypeOf((*MockClient)(nil).ListNodes), options)
+}
+
+// CreateSecret mocks base method
+func (m *MockClient) CreateSecret(secret *v1.Secret, namespace string) (*v1.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSecret", secret, namespace)
+	ret0, _ := ret[0].(*v1.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSecret indicates an expected call of CreateSecret
+func (mr *MockClientMockRecorder) CreateSecret(secret, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSecret", reflect.TypeOf((*MockClient)(nil).CreateSecret), secret, namespace)
+}
+
+// DeleteSecret mocks base method
+func (m *MockClient) DeleteSecret(secretName, namespace string, options *v12.DeleteOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSecret", secretName, namespace, options)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSecret indicates an expected call of DeleteSecret
+func (mr *MockClientMockRecorder) DeleteSecret(secretName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSecret", reflect.TypeOf((*MockClient)(nil).DeleteSecret), secretName, namespace, options)
+}
+
+// UpdateSecret mocks base method
+func (m *MockClient) UpdateSecret(secret *v1.Secret, namespace string) (*v1.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSecret", secret, namespace)
+	ret0, _ := ret[0].(*v1.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateSecret indicates an expected call of UpdateSecret
+func (mr *MockClientMockRecorder) UpdateSecret(secret, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSecret", reflect.TypeOf((*MockClient)(nil).UpdateSecret), secret, namespace)
+}
+
+// GetSecret mocks base method
+func (m *MockClient) GetSecret(secretName, namespace string, options v12.GetOptions) (*v1.Secret, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSecret", secretName, namespace, options)
+	ret0, _ := ret[0].(*v1.Secret)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSecret indicates an expected call of GetSecret
+func (mr *MockClientMockRecorder) GetSecret(secretName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSecret", reflect.TypeOf((*MockClient)(nil).GetSecret), secretName, namespace, options)
+}
+
+// CreateRole mocks base method
+func (m *MockClient) CreateRole(role *v10.Role, namespace string) (*v10.Role, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRole", role, namespace)
+	ret0, _ := ret[0].(*v10.Role)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRole indicates an expected call of CreateRole
+func (mr *MockClientMockRecorder) CreateRole(role, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRole", reflect.TypeOf((*MockClient)(nil).CreateRole), role, namespace)
+}
+
+// GetRole mocks base method
+func (m *MockClient) GetRole(roleName, namespace string, options v12.GetOptions) (*v10.Role, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRole", roleName, namespace, options)
+	ret0, _ := ret[0].(*v10.Role)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRole indicates an expected call of GetRole
+func (mr *MockClientMockRecorder) GetRole(roleName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRole", reflect.TypeOf((*MockClient)(nil).GetRole), roleName, namespace, options)
+}
+
+// CreateRoleBinding mocks base method
+func (m *MockClient) CreateRoleBinding(roleBinding *v10.RoleBinding, namespace string) (*v10.RoleBinding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRoleBinding", roleBinding, namespace)
+	ret0, _ := ret[0].(*v10.RoleBinding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRoleBinding indicates an expected call of CreateRoleBinding
+func (mr *MockClientMockRecorder) CreateRoleBinding(roleBinding, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRoleBinding", reflect.TypeOf((*MockClient)(nil).CreateRoleBinding), roleBinding, namespace)
+}
+
+// GetRoleBinding mocks base method
+func (m *MockClient) GetRoleBinding(roleBindingName, namespace string, options v12.GetOptions) (*v10.RoleBinding, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRoleBinding", roleBindingName, namespace, options)
+	ret0, _ := ret[0].(*v10.RoleBinding)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRoleBinding indicates an expected call of GetRoleBinding
+func (mr *MockClientMockRecorder) GetRoleBinding(roleBindingName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRoleBinding", reflect.TypeOf((*MockClient)(nil).GetRoleBinding), roleBindingName, namespace, options)
+}
+
+// DeleteRoleBinding mocks base method
+func (m *MockClient) DeleteRoleBinding(roleBindingName, namespace string, options *v12.DeleteOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRoleBinding", roleBindingName, namespace, options)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRoleBinding indicates an expected call of DeleteRoleBinding
+func (mr *MockClientMockRecorder) DeleteRoleBinding(roleBindingName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRoleBinding", reflect.TypeOf((*MockClient)(nil).DeleteRoleBinding), roleBindingName, namespace, options)
+}
+
+// CreateIngress mocks base method
+func (m *MockClient) CreateIngress(ingress *v1beta10.Ingress, namespace string) (*v1beta10.Ingress, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateIngress", ingress, namespace)
+	ret0, _ := ret[0].(*v1beta10.Ingress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateIngress indicates an expected call of CreateIngress
+func (mr *MockClientMockRecorder) CreateIngress(ingress, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateIngress", reflect.TypeOf((*MockClient)(nil).CreateIngress), ingress, namespace)
+}
+
+// DeleteIngress mocks base method
+func (m *MockClient) DeleteIngress(ingressName, namespace string, options *v12.DeleteOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteIngress", ingressName, namespace, options)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteIngress indicates an expected call of DeleteIngress
+func (mr *MockClientMockRecorder) DeleteIngress(ingressName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteIngress", reflect.TypeOf((*MockClient)(nil).DeleteIngress), ingressName, namespace, options)
+}
+
+// UpdateIngress mocks base method
+func (m *MockClient) UpdateIngress(ingress *v1beta10.Ingress, namespace string) (*v1beta10.Ingress, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateIngress", ingress, namespace)
+	ret0, _ := ret[0].(*v1beta10.Ingress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateIngress indicates an expected call of UpdateIngress
+func (mr *MockClientMockRecorder) UpdateIngress(ingress, namespace interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateIngress", reflect.TypeOf((*MockClient)(nil).UpdateIngress), ingress, namespace)
+}
+
+// GetIngress mocks base method
+func (m *MockClient) GetIngress(ingressName, namespace string, options v12.GetOptions) (*v1beta10.Ingress, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetIngress", ingressName, namespace, options)
+	ret0, _ := ret[0].(*v1beta10.Ingress)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetIngress indicates an expected call of GetIngress
+func (mr *MockClientMockRecorder) GetIngress(ingressName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetIngress", reflect.TypeOf((*MockClient)(nil).GetIngress), ingressName, namespace, options)
+}
+
+// GetDataVolume mocks base method
+func (m *MockClient) GetDataVolume(dataVolumeName, namespace string, options v12.GetOptions) (*v1alpha1.DataVolume, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDataVolume", dataVolumeName, namespace, options)
+	ret0, _ := ret[0].(*v1alpha1.DataVolume)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDataVolume indicates an expected call of GetDataVolume
+func (mr *MockClientMockRecorder) GetDataVolume(dataVolumeName, namespace, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDataVolume", reflect.TypeOf((*MockClient)(nil).GetDataVolume), dataVolumeName, namespace, options)
+}
+
+// GetNamespace mocks base method
+func (m *MockClient) GetNamespace(namespaceName string, options v12.GetOptions) (*v1.Namespace, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNamespace", namespaceName, options)
+	ret0, _ := ret[0].(*v1.Namespace)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNamespace indicates an expected call of GetNamespace
+func (mr *MockClientMockRecorder) GetNamespace(namespaceName, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNamespace", reflect.TypeOf((*MockClient)(nil).GetNamespace), namespaceName, options)
+}
+
+// ServerResourcesForGroupVersion mocks base method
+func (m *MockClient) ServerResourcesForGroupVersion(groupVersion string) (*v12.APIResourceList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ServerResourcesForGroupVersion", groupVersion)
+	ret0, _ := ret[0].(*v12.APIResourceList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ServerResourcesForGroupVersion indicates an expected call of ServerResourcesForGroupVersion
+func (mr *MockClientMockRecorder) ServerResourcesForGroupVersion(groupVersion interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ServerResourcesForGroupVersion", reflect.TypeOf((*MockClient)(nil).ServerResourcesForGroupVersion), groupVersion)
+}
+
+// GetKubeVirtVersion mocks base method
+func (m *MockClient) GetKubeVirtVersion() (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetKubeVirtVersion")
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetKubeVirtVersion indicates an expected call of GetKubeVirtVersion
+func (mr *MockClientMockRecorder) GetKubeVirtVersion() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetKubeVirtVersion", reflect.TypeOf((*MockClient)(nil).GetKubeVirtVersion))
+}
+
+// GetFeatureGates mocks base method
+func (m *MockClient) GetFeatureGates() ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFeatureGates")
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFeatureGates indicates an expected call of GetFeatureGates
+func (mr *MockClientMockRecorder) GetFeatureGates() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFeatureGates", reflect.TypeOf((*MockClient)(nil).GetFeatureGates))
+}
+
+// GetMutatingWebhookConfiguration mocks base method
+func (m *MockClient) GetMutatingWebhookConfiguration(name string, options v12.GetOptions) (*v1beta1.MutatingWebhookConfiguration, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMutatingWebhookConfiguration", name, options)
+	ret0, _ := ret[0].(*v1beta1.MutatingWebhookConfiguration)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMutatingWebhookConfiguration indicates an expected call of GetMutatingWebhookConfiguration
+func (mr *MockClientMockRecorder) GetMutatingWebhookConfiguration(name, options interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMutatingWebhookConfiguration", reflect.TypeOf((*MockClient)(nil).GetMutatingWebhookConfiguration), name, options)
+}
+
+// DefaultNamespace mocks base method
+func (m *MockClient) DefaultNamespace() string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DefaultNamespace")
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// DefaultNamespace indicates an expected call of DefaultNamespace
+func (mr *MockClientMockRecorder) DefaultNamespace() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DefaultNamespace", reflect.TypeOf((*MockClient)(nil).DefaultNamespace))
+}
+
+// AllowedNamespaces mocks base method
+func (m *MockClient) AllowedNamespaces() []string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AllowedNamespaces")
+	ret0, _ := ret[0].([]string)
+	return ret0
+}
+
+// AllowedNamespaces indicates an expected call of AllowedNamespaces
+func (mr *MockClientMockRecorder) AllowedNamespaces() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AllowedNamespaces", reflect.TypeOf((*MockClient)(nil).AllowedNamespaces))
+}
@@ -0,0 +1,110 @@
+package underkube
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	"kubevirt.io/client-go/kubecli"
+	"kubevirt.io/client-go/subresources"
+)
+
+// PortForwardVirtualMachineInstance opens a stream to a TCP port of the VMI through the portforward
+// subresource of KubeVirt. The KubeVirt client has no method for it, the websocket is opened like the
+// ones of the console subresources.
+func (c *client) PortForwardVirtualMachineInstance(namespace string, name string, port int) (io.ReadWriteCloser, error) {
+	config := c.kubevirtClient.Config()
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &dialRoundTripper{dialer: &websocket.Dialer{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		WriteBufferSize: kubecli.WebsocketMessageBufferSize,
+		ReadBufferSize:  kubecli.WebsocketMessageBufferSize,
+		Subprotocols:    []string{subresources.PlainStreamProtocolName},
+	}}
+	// The wrappers add the credentials of the config to the request
+	roundTripper, err := rest.HTTPWrappersForConfig(config, dialer)
+	if err != nil {
+		return nil, err
+	}
+	request, err := kubecli.RequestFromConfig(config, name, namespace, fmt.Sprintf("portforward/%d", port))
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := roundTripper.RoundTrip(request)
+	if err != nil {
+		if response != nil {
+			// A status error, so an underkube refusing the credentials rebuilds the client
+			resource := schema.GroupResource{Group: kubevirtapiv1.GroupName, Resource: "virtualmachineinstances/portforward"}
+			return nil, apimachineryerrors.NewGenericServerResponse(response.StatusCode, http.MethodGet, resource, name, err.Error(), 0, false)
+		}
+		return nil, fmt.Errorf("failed to open the port forward to %s/%s: %w", namespace, name, err)
+	}
+	return &websocketStream{conn: dialer.conn}, nil
+}
+
+// dialRoundTripper dials the websocket of the request, once the wrappers of the rest config set its headers
+type dialRoundTripper struct {
+	dialer *websocket.Dialer
+	conn   *websocket.Conn
+}
+
+func (d *dialRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	conn, response, err := d.dialer.Dial(request.URL.String(), request.Header)
+	d.conn = conn
+	return response, err
+}
+
+// websocketStream reads and writes the binary messages of a websocket as a stream. It supports one reader
+// and one writer at once.
+type websocketStream struct {
+	conn   *websocket.Conn
+	reader io.Reader
+}
+
+func (s *websocketStream) Read(p []byte) (int, error) {
+	for {
+		if s.reader == nil {
+			messageType, reader, err := s.conn.NextReader()
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return 0, io.EOF
+			}
+			if err != nil {
+				return 0, err
+			}
+			if messageType != websocket.BinaryMessage {
+				continue
+			}
+			s.reader = reader
+		}
+		n, err := s.reader.Read(p)
+		if err == io.EOF {
+			s.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (s *websocketStream) Write(p []byte) (int, error) {
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *websocketStream) Close() error {
+	return s.conn.Close()
+}
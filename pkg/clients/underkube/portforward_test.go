@@ -0,0 +1,45 @@
+package underkube
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"gotest.tools/assert"
+)
+
+func TestWebsocketStream(t *testing.T) {
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		assert.NilError(t, err)
+		defer conn.Close()
+		_, message, err := conn.ReadMessage()
+		assert.NilError(t, err)
+		received <- string(message)
+
+		// The text messages are not part of the stream
+		assert.NilError(t, conn.WriteMessage(websocket.BinaryMessage, []byte("SSH-2.0-")))
+		assert.NilError(t, conn.WriteMessage(websocket.TextMessage, []byte("ignored")))
+		assert.NilError(t, conn.WriteMessage(websocket.BinaryMessage, []byte("OpenSSH_8.0\r\n")))
+		assert.NilError(t, conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")))
+	}))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.NilError(t, err)
+	stream := &websocketStream{conn: conn}
+	defer stream.Close()
+
+	n, err := stream.Write([]byte("SSH-2.0-client\r\n"))
+	assert.NilError(t, err)
+	assert.Equal(t, n, 16)
+	assert.Equal(t, <-received, "SSH-2.0-client\r\n")
+
+	data, err := ioutil.ReadAll(stream)
+	assert.NilError(t, err)
+	assert.Equal(t, string(data), "SSH-2.0-OpenSSH_8.0\r\n")
+}
@@ -0,0 +1,187 @@
+package underkube
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/version"
+	"github.com/prometheus/client_golang/prometheus"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	unsupportedKubeVirtCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kubevirt_underkube_unsupported_version_total",
+			Help: "Number of preflight checks that found an underkube KubeVirt version outside of the supported range, per version.",
+		},
+		[]string{"version"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(unsupportedKubeVirtCounter)
+}
+
+// InfraNotReadyError is returned by Preflight when the underkube lacks something the machines need. Like
+// the CredentialsError, it won't go away until someone fixes the underkube.
+type InfraNotReadyError struct {
+	Message string
+}
+
+func (e *InfraNotReadyError) Error() string {
+	return e.Message
+}
+
+// IsInfraNotReady returns true if err is, or wraps, an InfraNotReadyError
+func IsInfraNotReady(err error) bool {
+	var infraNotReadyErr *InfraNotReadyError
+	return errors.As(err, &infraNotReadyErr)
+}
+
+// PreflightOptions lists what Preflight checks besides the KubeVirt API and version
+type PreflightOptions struct {
+	// Namespace the VMs are created in, not checked when empty
+	Namespace string
+	// Capabilities are the optional infra components the VMs can't do without
+	Capabilities []Capability
+	// FeatureGates are the KubeVirt feature gates the VMs need
+	FeatureGates []string
+}
+
+// Preflight checks the underkube serves the KubeVirt API with a supported version, runs the required
+// capabilities, enables the feature gates the VMs need, and has their namespace. Everything missing is
+// reported in a single InfraNotReadyError, with a hint to fix it. Other errors are returned as they are.
+// The detected capabilities are returned once known, even with an InfraNotReadyError.
+func Preflight(c Client, options PreflightOptions) (Capabilities, error) {
+	served, err := servesResource(c, kubevirtapiv1.GroupVersion, "virtualmachines")
+	if err != nil {
+		return nil, err
+	}
+	// Without KubeVirt, there is nothing else to check
+	if !served {
+		return nil, &InfraNotReadyError{Message: fmt.Sprintf("Underkube not ready: the virtualmachines API %v is not served, install KubeVirt on the underkube", kubevirtapiv1.GroupVersion)}
+	}
+
+	var missing []string
+	problem, err := checkKubeVirtVersion(c)
+	if err != nil {
+		return nil, err
+	}
+	if problem != "" {
+		missing = append(missing, problem)
+	}
+
+	capabilities, err := DetectCapabilities(c)
+	if err != nil {
+		return nil, err
+	}
+	for _, capability := range options.Capabilities {
+		if !capabilities[capability] {
+			missing = append(missing, fmt.Sprintf("%s is not available, %s", capability, CapabilityHint(capability)))
+		}
+	}
+
+	if len(options.FeatureGates) > 0 {
+		missingGates, err := missingFeatureGates(c, options.FeatureGates)
+		if err != nil {
+			return capabilities, err
+		}
+		for _, gate := range missingGates {
+			missing = append(missing, fmt.Sprintf("the KubeVirt feature gate %s is not enabled, add it to the %s key of the %s ConfigMap", gate, featureGatesKey, kubevirtConfigMapName))
+		}
+	}
+
+	if options.Namespace != "" {
+		_, err := c.GetNamespace(options.Namespace, k8smetav1.GetOptions{})
+		switch {
+		case apimachineryerrors.IsNotFound(err):
+			missing = append(missing, fmt.Sprintf("the namespace %s does not exist, create it or change the namespace of the machines", options.Namespace))
+		case apimachineryerrors.IsForbidden(err):
+			// Namespaced credentials usually can't read their namespace, its existence is unknown
+			klog.V(3).Infof("Not allowed to check the namespace %s exists: %v", options.Namespace, err)
+		case err != nil:
+			return capabilities, err
+		}
+	}
+
+	if len(missing) > 0 {
+		return capabilities, &InfraNotReadyError{Message: "Underkube not ready: " + strings.Join(missing, "; ")}
+	}
+	return capabilities, nil
+}
+
+// checkKubeVirtVersion returns the version skew problem of the underkube KubeVirt, empty when it is supported.
+// A version newer than the tested ones is let through with a warning, as is a version that doesn't parse,
+// e.g. of a development build.
+func checkKubeVirtVersion(c Client) (string, error) {
+	gitVersion, err := c.GetKubeVirtVersion()
+	if err != nil {
+		return "", err
+	}
+	supported, err := version.IsSupportedKubeVirt(gitVersion)
+	if err != nil {
+		klog.Warningf("Unable to check the underkube KubeVirt version %q is supported: %v", gitVersion, err)
+		return "", nil
+	}
+	if !supported {
+		unsupportedKubeVirtCounter.WithLabelValues(gitVersion).Inc()
+		return fmt.Sprintf("KubeVirt %s is not supported, use a version in the range %s", gitVersion, version.TestedKubeVirt), nil
+	}
+	if tested, _ := version.IsTestedKubeVirt(gitVersion); !tested {
+		klog.Warningf("The underkube KubeVirt %s is newer than the tested versions %s", gitVersion, version.TestedKubeVirt)
+	}
+	return "", nil
+}
+
+// missingFeatureGates returns the required feature gates the underkube KubeVirt doesn't enable. When the
+// configuration of KubeVirt can't be read, e.g. the credentials are restricted to a namespace, the gates
+// aren't checked and the VM creation reports a missing one.
+func missingFeatureGates(c Client, required []string) ([]string, error) {
+	enabled, err := c.GetFeatureGates()
+	if apimachineryerrors.IsForbidden(err) || apimachineryerrors.IsNotFound(err) {
+		klog.Warningf("Unable to check the underkube KubeVirt feature gates: %v", err)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, gate := range required {
+		if !containsString(enabled, gate) {
+			missing = append(missing, gate)
+		}
+	}
+	return missing, nil
+}
+
+func containsString(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+func servesResource(c Client, groupVersion schema.GroupVersion, resource string) (bool, error) {
+	resources, err := c.ServerResourcesForGroupVersion(groupVersion.String())
+	if apimachineryerrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, r := range resources.APIResources {
+		if r.Name == resource {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,156 @@
+package underkube
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPreflight(t *testing.T) {
+	served := func(resources ...string) *k8smetav1.APIResourceList {
+		list := &k8smetav1.APIResourceList{}
+		for _, resource := range resources {
+			list.APIResources = append(list.APIResources, k8smetav1.APIResource{Name: resource})
+		}
+		return list
+	}
+	notFound := func(resource string) error {
+		return apimachineryerrors.NewNotFound(schema.GroupResource{Resource: resource}, "")
+	}
+
+	cases := []struct {
+		name         string
+		options      PreflightOptions
+		kubevirt     *k8smetav1.APIResourceList
+		version      string
+		featureGates []string
+		cdi          *k8smetav1.APIResourceList
+		cdiErr       error
+		namespaceErr error
+		wantNotReady bool
+		wantErr      string
+	}{
+		{
+			name:     "Pass when KubeVirt, CDI and the namespace are there",
+			options:  PreflightOptions{Namespace: "tenant-1"},
+			kubevirt: served("virtualmachines", "virtualmachineinstances"),
+			cdi:      served("datavolumes"),
+			version:  "v0.29.2",
+		},
+		{
+			name:         "Refuse an unsupported KubeVirt version",
+			kubevirt:     served("virtualmachines"),
+			cdi:          served("datavolumes"),
+			version:      "v0.20.8",
+			wantNotReady: true,
+			wantErr:      "Underkube not ready: KubeVirt v0.20.8 is not supported, use a version in the range >=0.26.0 <0.35.0",
+		},
+		{
+			name:         "Name the missing feature gates",
+			options:      PreflightOptions{FeatureGates: []string{"DataVolumes", "Sidecar"}},
+			kubevirt:     served("virtualmachines"),
+			cdi:          served("datavolumes"),
+			version:      "v0.29.2",
+			featureGates: []string{"Sidecar"},
+			wantNotReady: true,
+			wantErr:      "Underkube not ready: the KubeVirt feature gate DataVolumes is not enabled, add it to the feature-gates key of the kubevirt-config ConfigMap",
+		},
+		{
+			name:     "Let a KubeVirt version newer than the tested ones through",
+			kubevirt: served("virtualmachines"),
+			cdi:      served("datavolumes"),
+			version:  "v0.36.0",
+		},
+		{
+			name:     "Let a development build of KubeVirt through",
+			kubevirt: served("virtualmachines"),
+			cdi:      served("datavolumes"),
+			version:  "v0.0.0-master+$Format:%h$",
+		},
+		{
+			name:         "Stop when KubeVirt is not there",
+			options:      PreflightOptions{Namespace: "tenant-1"},
+			kubevirt:     served("kubevirts"),
+			wantNotReady: true,
+			wantErr:      "Underkube not ready: the virtualmachines API kubevirt.io/v1alpha3 is not served, install KubeVirt on the underkube",
+		},
+		{
+			name:         "Report everything missing at once",
+			options:      PreflightOptions{Namespace: "tenant-1", Capabilities: []Capability{CDICapability, MultusCapability}},
+			kubevirt:     served("virtualmachines"),
+			version:      "v0.20.8",
+			cdiErr:       notFound("cdi.kubevirt.io/v1alpha1"),
+			namespaceErr: notFound("namespaces"),
+			wantNotReady: true,
+			wantErr: "Underkube not ready: KubeVirt v0.20.8 is not supported, use a version in the range >=0.26.0 <0.35.0; " +
+				"CDI is not available, install CDI on the underkube; " +
+				"Multus is not available, install Multus on the underkube; " +
+				"the namespace tenant-1 does not exist, create it or change the namespace of the machines",
+		},
+		{
+			name:         "Go on when the namespace can't be read",
+			options:      PreflightOptions{Namespace: "tenant-1"},
+			kubevirt:     served("virtualmachines", "virtualmachineinstances"),
+			cdi:          served("datavolumes"),
+			version:      "v0.29.2",
+			namespaceErr: apimachineryerrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "tenant-1", errors.New("no access")),
+		},
+		{
+			name:     "Return the other errors as they are",
+			kubevirt: served("virtualmachines"),
+			version:  "v0.29.2",
+			cdiErr:   errors.New("connection refused"),
+			wantErr:  "connection refused",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			client := mock.NewMockClient(mockCtrl)
+			client.EXPECT().ServerResourcesForGroupVersion("kubevirt.io/v1alpha3").Return(tc.kubevirt, nil).AnyTimes()
+			client.EXPECT().ServerResourcesForGroupVersion("cdi.kubevirt.io/v1alpha1").Return(tc.cdi, tc.cdiErr).AnyTimes()
+			client.EXPECT().ServerResourcesForGroupVersion(gomock.Any()).Return(nil, notFound("")).AnyTimes()
+			client.EXPECT().GetMutatingWebhookConfiguration(kubeMacPoolWebhookName, gomock.Any()).Return(nil, notFound("mutatingwebhookconfigurations")).AnyTimes()
+			client.EXPECT().GetKubeVirtVersion().Return(tc.version, nil).AnyTimes()
+			client.EXPECT().GetFeatureGates().Return(tc.featureGates, nil).AnyTimes()
+			client.EXPECT().GetNamespace(tc.options.Namespace, gomock.Any()).Return(&corev1.Namespace{}, tc.namespaceErr).AnyTimes()
+
+			_, err := Preflight(client, tc.options)
+			assert.Equal(t, IsInfraNotReady(err), tc.wantNotReady)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	client := mock.NewMockClient(mockCtrl)
+	client.EXPECT().ServerResourcesForGroupVersion("cdi.kubevirt.io/v1alpha1").Return(&k8smetav1.APIResourceList{APIResources: []k8smetav1.APIResource{{Name: "datavolumes"}}}, nil)
+	client.EXPECT().ServerResourcesForGroupVersion("snapshot.storage.k8s.io/v1beta1").Return(&k8smetav1.APIResourceList{APIResources: []k8smetav1.APIResource{{Name: "volumesnapshots"}}}, nil)
+	client.EXPECT().ServerResourcesForGroupVersion(gomock.Any()).Return(nil, apimachineryerrors.NewNotFound(schema.GroupResource{}, "")).Times(2)
+	client.EXPECT().GetMutatingWebhookConfiguration(kubeMacPoolWebhookName, gomock.Any()).Return(nil, apimachineryerrors.NewForbidden(schema.GroupResource{Resource: "mutatingwebhookconfigurations"}, kubeMacPoolWebhookName, errors.New("no access")))
+
+	capabilities, err := DetectCapabilities(client)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, capabilities, Capabilities{
+		CDICapability:         true,
+		MultusCapability:      false,
+		KubeMacPoolCapability: false,
+		SnapshotCapability:    true,
+		MetalLBCapability:     false,
+	})
+	assert.Equal(t, capabilities.String(), "[CDI Snapshot]")
+}
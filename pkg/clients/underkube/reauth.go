@@ -0,0 +1,484 @@
+package underkube
+
+import (
+	"io"
+
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// reauthClient is the client returned by the ClientCache. When the underkube refuses the credentials of a
+// call with a 401, e.g. because its certificates were rotated, the cached client is evicted, rebuilt from
+// the current secret, and the call is retried once before the error is returned. A 403 authenticated the
+// credentials, which aren't allowed the call, and is returned as it is.
+type reauthClient struct {
+	cache                *ClientCache
+	overKubernetesClient overkube.Client
+	key                  types.NamespacedName
+	client               Client
+}
+
+func (c *reauthClient) retry(call func(client Client) error) error {
+	err := call(c.client)
+	if !apimachineryerrors.IsUnauthorized(err) {
+		return err
+	}
+
+	klog.Warningf("The underkube refused the credentials of secret %v: %v, rebuilding its client", c.key, err)
+	c.cache.evict(c.key, c.client)
+	client, buildErr := c.cache.get(c.overKubernetesClient, c.key)
+	if buildErr != nil {
+		klog.Errorf("Failed to rebuild the underkube client of secret %v: %v", c.key, buildErr)
+		return err
+	}
+	c.client = client
+	return call(c.client)
+}
+
+func (c *reauthClient) CreateVirtualMachine(namespace string, newVM *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
+	var result *kubevirtapiv1.VirtualMachine
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.CreateVirtualMachine(namespace, newVM)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) DeleteVirtualMachine(namespace string, name string, options *k8smetav1.DeleteOptions) error {
+	return c.retry(func(client Client) error {
+		return client.DeleteVirtualMachine(namespace, name, options)
+	})
+}
+
+func (c *reauthClient) GetVirtualMachine(namespace string, name string, options *k8smetav1.GetOptions) (*kubevirtapiv1.VirtualMachine, error) {
+	var result *kubevirtapiv1.VirtualMachine
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetVirtualMachine(namespace, name, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetVirtualMachineInstance(namespace string, name string, options *k8smetav1.GetOptions) (*kubevirtapiv1.VirtualMachineInstance, error) {
+	var result *kubevirtapiv1.VirtualMachineInstance
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetVirtualMachineInstance(namespace, name, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListVirtualMachine(namespace string, options *k8smetav1.ListOptions) (*kubevirtapiv1.VirtualMachineList, error) {
+	var result *kubevirtapiv1.VirtualMachineList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListVirtualMachine(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListVirtualMachineInstance(namespace string, options *k8smetav1.ListOptions) (*kubevirtapiv1.VirtualMachineInstanceList, error) {
+	var result *kubevirtapiv1.VirtualMachineInstanceList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListVirtualMachineInstance(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) WatchVirtualMachineInstance(namespace string, options k8smetav1.ListOptions) (watch.Interface, error) {
+	var result watch.Interface
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.WatchVirtualMachineInstance(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) UpdateVirtualMachine(namespace string, vm *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
+	var result *kubevirtapiv1.VirtualMachine
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.UpdateVirtualMachine(namespace, vm)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) PatchVirtualMachine(namespace string, name string, pt types.PatchType, data []byte, subresources ...string) (*kubevirtapiv1.VirtualMachine, error) {
+	var result *kubevirtapiv1.VirtualMachine
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.PatchVirtualMachine(namespace, name, pt, data, subresources...)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) RestartVirtualMachine(namespace string, name string) error {
+	return c.retry(func(client Client) error {
+		return client.RestartVirtualMachine(namespace, name)
+	})
+}
+
+func (c *reauthClient) StartVirtualMachine(namespace string, name string) error {
+	return c.retry(func(client Client) error {
+		return client.StartVirtualMachine(namespace, name)
+	})
+}
+
+func (c *reauthClient) StopVirtualMachine(namespace string, name string) error {
+	return c.retry(func(client Client) error {
+		return client.StopVirtualMachine(namespace, name)
+	})
+}
+
+func (c *reauthClient) PauseVirtualMachineInstance(namespace string, name string) error {
+	return c.retry(func(client Client) error {
+		return client.PauseVirtualMachineInstance(namespace, name)
+	})
+}
+
+func (c *reauthClient) UnpauseVirtualMachineInstance(namespace string, name string) error {
+	return c.retry(func(client Client) error {
+		return client.UnpauseVirtualMachineInstance(namespace, name)
+	})
+}
+
+func (c *reauthClient) PortForwardVirtualMachineInstance(namespace string, name string, port int) (io.ReadWriteCloser, error) {
+	var result io.ReadWriteCloser
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.PortForwardVirtualMachineInstance(namespace, name, port)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) CreateService(service *corev1.Service, namespace string) (*corev1.Service, error) {
+	var result *corev1.Service
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.CreateService(service, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) DeleteService(serviceName string, namespace string, options *k8smetav1.DeleteOptions) error {
+	return c.retry(func(client Client) error {
+		return client.DeleteService(serviceName, namespace, options)
+	})
+}
+
+func (c *reauthClient) UpdateService(service *corev1.Service, namespace string) (*corev1.Service, error) {
+	var result *corev1.Service
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.UpdateService(service, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetService(serviceName string, namespace string, options k8smetav1.GetOptions) (*corev1.Service, error) {
+	var result *corev1.Service
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetService(serviceName, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListServices(namespace string, options k8smetav1.ListOptions) (*corev1.ServiceList, error) {
+	var result *corev1.ServiceList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListServices(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetEndpoints(name string, namespace string, options k8smetav1.GetOptions) (*corev1.Endpoints, error) {
+	var result *corev1.Endpoints
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetEndpoints(name, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) CreateEndpoints(endpoints *corev1.Endpoints, namespace string) (*corev1.Endpoints, error) {
+	var result *corev1.Endpoints
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.CreateEndpoints(endpoints, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) UpdateEndpoints(endpoints *corev1.Endpoints, namespace string) (*corev1.Endpoints, error) {
+	var result *corev1.Endpoints
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.UpdateEndpoints(endpoints, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListPods(namespace string, options k8smetav1.ListOptions) (*corev1.PodList, error) {
+	var result *corev1.PodList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListPods(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListSecrets(namespace string, options k8smetav1.ListOptions) (*corev1.SecretList, error) {
+	var result *corev1.SecretList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListSecrets(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListIngresses(namespace string, options k8smetav1.ListOptions) (*networkingv1beta1.IngressList, error) {
+	var result *networkingv1beta1.IngressList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListIngresses(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListPersistentVolumeClaims(namespace string, options k8smetav1.ListOptions) (*corev1.PersistentVolumeClaimList, error) {
+	var result *corev1.PersistentVolumeClaimList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListPersistentVolumeClaims(namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetPersistentVolumeClaim(pvcName string, namespace string, options k8smetav1.GetOptions) (*corev1.PersistentVolumeClaim, error) {
+	var result *corev1.PersistentVolumeClaim
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetPersistentVolumeClaim(pvcName, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) UpdatePersistentVolumeClaim(pvc *corev1.PersistentVolumeClaim, namespace string) (*corev1.PersistentVolumeClaim, error) {
+	var result *corev1.PersistentVolumeClaim
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.UpdatePersistentVolumeClaim(pvc, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetStorageClass(storageClassName string, options k8smetav1.GetOptions) (*storagev1.StorageClass, error) {
+	var result *storagev1.StorageClass
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetStorageClass(storageClassName, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetNode(nodeName string, options k8smetav1.GetOptions) (*corev1.Node, error) {
+	var result *corev1.Node
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetNode(nodeName, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ListNodes(options k8smetav1.ListOptions) (*corev1.NodeList, error) {
+	var result *corev1.NodeList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ListNodes(options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) CreateSecret(secret *corev1.Secret, namespace string) (*corev1.Secret, error) {
+	var result *corev1.Secret
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.CreateSecret(secret, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) DeleteSecret(secretName string, namespace string, options *k8smetav1.DeleteOptions) error {
+	return c.retry(func(client Client) error {
+		return client.DeleteSecret(secretName, namespace, options)
+	})
+}
+
+func (c *reauthClient) UpdateSecret(secret *corev1.Secret, namespace string) (*corev1.Secret, error) {
+	var result *corev1.Secret
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.UpdateSecret(secret, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetSecret(secretName string, namespace string, options k8smetav1.GetOptions) (*corev1.Secret, error) {
+	var result *corev1.Secret
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetSecret(secretName, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) CreateRole(role *rbacv1.Role, namespace string) (*rbacv1.Role, error) {
+	var result *rbacv1.Role
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.CreateRole(role, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetRole(roleName string, namespace string, options k8smetav1.GetOptions) (*rbacv1.Role, error) {
+	var result *rbacv1.Role
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetRole(roleName, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) CreateRoleBinding(roleBinding *rbacv1.RoleBinding, namespace string) (*rbacv1.RoleBinding, error) {
+	var result *rbacv1.RoleBinding
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.CreateRoleBinding(roleBinding, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetRoleBinding(roleBindingName string, namespace string, options k8smetav1.GetOptions) (*rbacv1.RoleBinding, error) {
+	var result *rbacv1.RoleBinding
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetRoleBinding(roleBindingName, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) DeleteRoleBinding(roleBindingName string, namespace string, options *k8smetav1.DeleteOptions) error {
+	return c.retry(func(client Client) error {
+		return client.DeleteRoleBinding(roleBindingName, namespace, options)
+	})
+}
+
+func (c *reauthClient) CreateIngress(ingress *networkingv1beta1.Ingress, namespace string) (*networkingv1beta1.Ingress, error) {
+	var result *networkingv1beta1.Ingress
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.CreateIngress(ingress, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) DeleteIngress(ingressName string, namespace string, options *k8smetav1.DeleteOptions) error {
+	return c.retry(func(client Client) error {
+		return client.DeleteIngress(ingressName, namespace, options)
+	})
+}
+
+func (c *reauthClient) UpdateIngress(ingress *networkingv1beta1.Ingress, namespace string) (*networkingv1beta1.Ingress, error) {
+	var result *networkingv1beta1.Ingress
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.UpdateIngress(ingress, namespace)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetIngress(ingressName string, namespace string, options k8smetav1.GetOptions) (*networkingv1beta1.Ingress, error) {
+	var result *networkingv1beta1.Ingress
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetIngress(ingressName, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetDataVolume(dataVolumeName string, namespace string, options k8smetav1.GetOptions) (*cdiv1.DataVolume, error) {
+	var result *cdiv1.DataVolume
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetDataVolume(dataVolumeName, namespace, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetNamespace(namespaceName string, options k8smetav1.GetOptions) (*corev1.Namespace, error) {
+	var result *corev1.Namespace
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetNamespace(namespaceName, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) ServerResourcesForGroupVersion(groupVersion string) (*k8smetav1.APIResourceList, error) {
+	var result *k8smetav1.APIResourceList
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.ServerResourcesForGroupVersion(groupVersion)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetKubeVirtVersion() (string, error) {
+	var result string
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetKubeVirtVersion()
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetFeatureGates() ([]string, error) {
+	var result []string
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetFeatureGates()
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) GetMutatingWebhookConfiguration(name string, options k8smetav1.GetOptions) (*admissionregistrationv1beta1.MutatingWebhookConfiguration, error) {
+	var result *admissionregistrationv1beta1.MutatingWebhookConfiguration
+	err := c.retry(func(client Client) (err error) {
+		result, err = client.GetMutatingWebhookConfiguration(name, options)
+		return err
+	})
+	return result, err
+}
+
+func (c *reauthClient) DefaultNamespace() string {
+	return c.client.DefaultNamespace()
+}
+
+func (c *reauthClient) AllowedNamespaces() []string {
+	return c.client.AllowedNamespaces()
+}
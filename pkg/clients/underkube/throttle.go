@@ -0,0 +1,212 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package underkube
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// flowSchemaHeader is set by API Priority and Fairness on every response and
+	// identifies the flow schema the request was classified into.
+	flowSchemaHeader = "X-Kubernetes-PF-FlowSchema-UID"
+	retryAfterHeader = "Retry-After"
+	// unknownFlowSchema is used until the underkube tells us which flow schema a request belongs to,
+	// or when API Priority and Fairness is disabled on the underkube.
+	unknownFlowSchema = "unknown"
+
+	throttleMaxQPS         = 50.0
+	throttleMinQPS         = 1.0
+	throttleBurst          = 10
+	throttleRecoveryStep   = 0.5
+	defaultRetryAfterDelay = time.Second
+)
+
+var (
+	throttleQPSGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "kubevirt_underkube_throttle_qps",
+			Help: "Current request rate allowed towards the underkube API server, per flow schema.",
+		},
+		[]string{"flow_schema"},
+	)
+	throttledRequestsCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "kubevirt_underkube_throttled_requests_total",
+			Help: "Number of underkube requests rejected with 429 Too Many Requests, per flow schema.",
+		},
+		[]string{"flow_schema"},
+	)
+
+	// defaultThrottle is shared by all the underkube clients, since they are rebuilt on every reconcile
+	// and the learned rates must survive them.
+	defaultThrottle = newThrottle()
+)
+
+func init() {
+	metrics.Registry.MustRegister(throttleQPSGauge, throttledRequestsCounter)
+}
+
+// throttle adapts the request rate towards the underkube per flow schema, using an additive increase /
+// multiplicative decrease policy driven by the 429 responses of API Priority and Fairness.
+type throttle struct {
+	lock         sync.Mutex
+	limiters     map[string]*rate.Limiter
+	flowSchemas  map[string]string
+	blockedUntil map[string]time.Time
+	now          func() time.Time
+}
+
+func newThrottle() *throttle {
+	return &throttle{
+		limiters:     map[string]*rate.Limiter{},
+		flowSchemas:  map[string]string{},
+		blockedUntil: map[string]time.Time{},
+		now:          time.Now,
+	}
+}
+
+// wrapTransport returns a transport.WrapperFunc to be set on the underkube rest config.
+func (t *throttle) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return &throttlingRoundTripper{delegate: rt, throttle: t}
+}
+
+// limiterFor returns the limiter of the flow schema the given request was last classified into,
+// and how long the request must be held back because the underkube asked us to retry later.
+func (t *throttle) limiterFor(key string) (*rate.Limiter, time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	flowSchema, ok := t.flowSchemas[key]
+	if !ok {
+		flowSchema = unknownFlowSchema
+	}
+	return t.limiterLocked(flowSchema), t.blockedUntil[flowSchema].Sub(t.now())
+}
+
+func (t *throttle) limiterLocked(flowSchema string) *rate.Limiter {
+	limiter, ok := t.limiters[flowSchema]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(throttleMaxQPS), throttleBurst)
+		t.limiters[flowSchema] = limiter
+		throttleQPSGauge.WithLabelValues(flowSchema).Set(throttleMaxQPS)
+	}
+	return limiter
+}
+
+// observe records the outcome of a request and adapts the rate of its flow schema.
+func (t *throttle) observe(key string, resp *http.Response) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	flowSchema := resp.Header.Get(flowSchemaHeader)
+	if flowSchema == "" {
+		flowSchema = unknownFlowSchema
+	}
+	t.flowSchemas[key] = flowSchema
+	limiter := t.limiterLocked(flowSchema)
+
+	qps := float64(limiter.Limit())
+	if resp.StatusCode == http.StatusTooManyRequests {
+		throttledRequestsCounter.WithLabelValues(flowSchema).Inc()
+		// The requests sent before the underkube asked to retry later are refused together, the rate is
+		// only lowered once for them
+		if t.now().Before(t.blockedUntil[flowSchema]) {
+			return
+		}
+		qps = qps / 2
+		if qps < throttleMinQPS {
+			qps = throttleMinQPS
+		}
+		limiter.SetLimit(rate.Limit(qps))
+		// Don't let any request of that flow schema through before the server asked us to retry
+		t.blockedUntil[flowSchema] = t.now().Add(retryAfter(resp))
+		klog.V(3).Infof("underkube throttled flow schema %s, lowering request rate to %.1f qps", flowSchema, qps)
+	} else if qps < throttleMaxQPS {
+		qps += throttleRecoveryStep
+		if qps > throttleMaxQPS {
+			qps = throttleMaxQPS
+		}
+		limiter.SetLimit(rate.Limit(qps))
+	}
+	throttleQPSGauge.WithLabelValues(flowSchema).Set(qps)
+}
+
+// retryAfter parses the Retry-After header, which API Priority and Fairness sets in seconds.
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(resp.Header.Get(retryAfterHeader))
+	if err != nil || seconds <= 0 {
+		return defaultRetryAfterDelay
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// requestKey identifies the requests that are expected to be classified into the same flow schema:
+// same verb on the same resource of the same namespace.
+func requestKey(req *http.Request) string {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	// /api/v1/<resource>/... or /apis/<group>/<version>/<resource>/...
+	resourceIndex := 2
+	if len(segments) > 0 && segments[0] == "apis" {
+		resourceIndex = 3
+	}
+	if len(segments) > resourceIndex+2 && segments[resourceIndex] == "namespaces" {
+		resourceIndex += 2
+	}
+	if len(segments) > resourceIndex+1 {
+		segments = segments[:resourceIndex+1]
+	}
+	return req.Method + " " + req.URL.Host + "/" + strings.Join(segments, "/")
+}
+
+type throttlingRoundTripper struct {
+	delegate http.RoundTripper
+	throttle *throttle
+}
+
+func (rt *throttlingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := requestKey(req)
+	limiter, delay := rt.throttle.limiterFor(key)
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if err := limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	resp, err := rt.delegate.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	rt.throttle.observe(key, resp)
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package underkube
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+	"gotest.tools/assert"
+)
+
+func TestRequestKey(t *testing.T) {
+	cases := []struct {
+		name    string
+		method  string
+		path    string
+		wantKey string
+	}{
+		{
+			name:    "namespaced custom resource",
+			method:  http.MethodGet,
+			path:    "/apis/kubevirt.io/v1alpha3/namespaces/cluster-1/virtualmachines/machine-1",
+			wantKey: "GET underkube/apis/kubevirt.io/v1alpha3/namespaces/cluster-1/virtualmachines",
+		},
+		{
+			name:    "namespaced core resource",
+			method:  http.MethodDelete,
+			path:    "/api/v1/namespaces/cluster-1/services/machine-1",
+			wantKey: "DELETE underkube/api/v1/namespaces/cluster-1/services",
+		},
+		{
+			name:    "namespace itself",
+			method:  http.MethodGet,
+			path:    "/api/v1/namespaces/cluster-1",
+			wantKey: "GET underkube/api/v1/namespaces",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &http.Request{Method: tc.method, URL: &url.URL{Host: "underkube", Path: tc.path}}
+			assert.Equal(t, tc.wantKey, requestKey(req))
+		})
+	}
+}
+
+func TestThrottleObserve(t *testing.T) {
+	now := time.Now()
+	th := newThrottle()
+	th.now = func() time.Time { return now }
+	key := "GET underkube/api/v1/namespaces/cluster-1/services"
+
+	throttled := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}}
+	throttled.Header.Set(flowSchemaHeader, "workload-low")
+	throttled.Header.Set(retryAfterHeader, "3")
+	th.observe(key, throttled)
+
+	limiter, delay := th.limiterFor(key)
+	assert.Equal(t, rate.Limit(throttleMaxQPS/2), limiter.Limit())
+	assert.Equal(t, 3*time.Second, delay)
+
+	// A request in flight when the underkube asked to retry later doesn't lower the rate again
+	th.observe(key, throttled)
+	limiter, _ = th.limiterFor(key)
+	assert.Equal(t, rate.Limit(throttleMaxQPS/2), limiter.Limit())
+
+	succeeded := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	succeeded.Header.Set(flowSchemaHeader, "workload-low")
+	th.observe(key, succeeded)
+
+	limiter, _ = th.limiterFor(key)
+	assert.Equal(t, rate.Limit(throttleMaxQPS/2+throttleRecoveryStep), limiter.Limit())
+}
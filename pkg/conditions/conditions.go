@@ -0,0 +1,109 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package conditions builds and sets the conditions of the provider status of the machines. Setting a
+// condition only changes the status when the condition does, and the messages of a condition keeping its
+// status and reason are refreshed at most once per MessageUpdateInterval, so a failure repeated on every
+// reconcile with a slightly different message doesn't rewrite the machine status each time.
+package conditions
+
+import (
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MessageUpdateInterval is the minimum time between two updates of the message of a condition whose status
+// and reason don't change
+const MessageUpdateInterval = 5 * time.Minute
+
+// New returns a condition of the type, the reason being the CamelCase machine readable explanation of its
+// status
+func New(conditionType kubevirtproviderv1.KubevirtMachineConditionType, status corev1.ConditionStatus, reason string, message string) kubevirtproviderv1.KubevirtMachineCondition {
+	return kubevirtproviderv1.KubevirtMachineCondition{
+		Type:    conditionType,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+// True returns a condition of the type with the true status
+func True(conditionType kubevirtproviderv1.KubevirtMachineConditionType, reason string) kubevirtproviderv1.KubevirtMachineCondition {
+	return New(conditionType, corev1.ConditionTrue, reason, "")
+}
+
+// False returns a condition of the type with the false status
+func False(conditionType kubevirtproviderv1.KubevirtMachineConditionType, reason string, message string) kubevirtproviderv1.KubevirtMachineCondition {
+	return New(conditionType, corev1.ConditionFalse, reason, message)
+}
+
+// Failed returns a condition of the type with the false status, explained by the error
+func Failed(conditionType kubevirtproviderv1.KubevirtMachineConditionType, reason string, err error) kubevirtproviderv1.KubevirtMachineCondition {
+	return False(conditionType, reason, err.Error())
+}
+
+// Find returns the condition of the type, nil when there's none
+func Find(conditions []kubevirtproviderv1.KubevirtMachineCondition, conditionType kubevirtproviderv1.KubevirtMachineConditionType) *kubevirtproviderv1.KubevirtMachineCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// Set sets the condition in conditions and returns the new slice of conditions.
+// If there's no condition with the same type yet, the condition is appended. Otherwise the existing
+// condition is updated: its LastTransitionTime only changes when its status changes, and its message,
+// when neither its status nor its reason change, only after MessageUpdateInterval.
+func Set(conditions []kubevirtproviderv1.KubevirtMachineCondition, condition kubevirtproviderv1.KubevirtMachineCondition, now time.Time) []kubevirtproviderv1.KubevirtMachineCondition {
+	existingCondition := Find(conditions, condition.Type)
+	if existingCondition == nil {
+		if condition.LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = metav1.NewTime(now)
+		}
+		condition.LastUpdateTime = condition.LastTransitionTime
+		return append(conditions, condition)
+	}
+
+	existingCondition.ObservedGeneration = condition.ObservedGeneration
+	switch {
+	case existingCondition.Status != condition.Status:
+		existingCondition.Status = condition.Status
+		existingCondition.LastTransitionTime = metav1.NewTime(now)
+	case existingCondition.Reason != condition.Reason:
+	case existingCondition.Message == condition.Message:
+		return conditions
+	case now.Sub(lastUpdateTime(existingCondition)) < MessageUpdateInterval:
+		return conditions
+	}
+	existingCondition.Reason = condition.Reason
+	existingCondition.Message = condition.Message
+	existingCondition.LastUpdateTime = metav1.NewTime(now)
+	return conditions
+}
+
+// lastUpdateTime returns the last update of the condition, its transition for the conditions set before
+// the update time was recorded
+func lastUpdateTime(condition *kubevirtproviderv1.KubevirtMachineCondition) time.Time {
+	if condition.LastUpdateTime.IsZero() {
+		return condition.LastTransitionTime.Time
+	}
+	return condition.LastUpdateTime.Time
+}
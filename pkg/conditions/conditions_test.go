@@ -0,0 +1,69 @@
+package conditions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHelpers(t *testing.T) {
+	assert.DeepEqual(t, True(kubevirtproviderv1.VMReadyCondition, "VMReady"), kubevirtproviderv1.KubevirtMachineCondition{
+		Type: kubevirtproviderv1.VMReadyCondition, Status: corev1.ConditionTrue, Reason: "VMReady",
+	})
+	assert.DeepEqual(t, Failed(kubevirtproviderv1.VMProvisionedCondition, "VMCreationFailed", errors.New("client error")), kubevirtproviderv1.KubevirtMachineCondition{
+		Type: kubevirtproviderv1.VMProvisionedCondition, Status: corev1.ConditionFalse, Reason: "VMCreationFailed", Message: "client error",
+	})
+}
+
+func TestSet(t *testing.T) {
+	transitionTime := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := transitionTime.Add(time.Hour)
+	conditions := []kubevirtproviderv1.KubevirtMachineCondition{
+		{Type: kubevirtproviderv1.VMReadyCondition, Status: corev1.ConditionFalse, Reason: "VMNotReady", LastTransitionTime: transitionTime},
+	}
+
+	conditions = Set(conditions, False(kubevirtproviderv1.VMReadyCondition, "Unschedulable", "no node fits"), now)
+	assert.Equal(t, len(conditions), 1)
+	assert.Equal(t, conditions[0].Reason, "Unschedulable")
+	assert.Equal(t, conditions[0].Message, "no node fits")
+	assert.Equal(t, conditions[0].LastTransitionTime, transitionTime, "the transition time moved without a status change")
+	assert.Equal(t, conditions[0].LastUpdateTime.Time, now)
+
+	// The repeated failure with a new message is rate limited
+	conditions = Set(conditions, False(kubevirtproviderv1.VMReadyCondition, "Unschedulable", "no node fits, 3 nodes checked"), now.Add(time.Minute))
+	assert.Equal(t, conditions[0].Message, "no node fits")
+	assert.Equal(t, conditions[0].LastUpdateTime.Time, now)
+	conditions = Set(conditions, False(kubevirtproviderv1.VMReadyCondition, "Unschedulable", "no node fits, 3 nodes checked"), now.Add(MessageUpdateInterval))
+	assert.Equal(t, conditions[0].Message, "no node fits, 3 nodes checked")
+	assert.Equal(t, conditions[0].LastUpdateTime.Time, now.Add(MessageUpdateInterval))
+
+	// A new reason isn't
+	now = now.Add(MessageUpdateInterval + time.Second)
+	conditions = Set(conditions, False(kubevirtproviderv1.VMReadyCondition, "VMStopped", ""), now)
+	assert.Equal(t, conditions[0].Reason, "VMStopped")
+	assert.Equal(t, conditions[0].LastUpdateTime.Time, now)
+
+	now = now.Add(time.Second)
+	conditions = Set(conditions, True(kubevirtproviderv1.VMReadyCondition, "VMReady"), now)
+	assert.Equal(t, conditions[0].Status, corev1.ConditionTrue)
+	assert.Equal(t, conditions[0].LastTransitionTime.Time, now)
+
+	conditions = Set(conditions, False(kubevirtproviderv1.AgentConnectedCondition, "AgentNotConnected", ""), now)
+	assert.Equal(t, len(conditions), 2)
+	assert.Equal(t, conditions[1].LastTransitionTime.Time, now)
+	assert.Equal(t, conditions[1].LastUpdateTime.Time, now)
+}
+
+func TestSetUnchanged(t *testing.T) {
+	transitionTime := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	condition := kubevirtproviderv1.KubevirtMachineCondition{
+		Type: kubevirtproviderv1.VMReadyCondition, Status: corev1.ConditionFalse, Reason: "Unschedulable", Message: "no node fits", LastTransitionTime: transitionTime,
+	}
+	conditions := Set([]kubevirtproviderv1.KubevirtMachineCondition{condition}, False(kubevirtproviderv1.VMReadyCondition, "Unschedulable", "no node fits"), time.Now())
+	assert.DeepEqual(t, conditions, []kubevirtproviderv1.KubevirtMachineCondition{condition})
+}
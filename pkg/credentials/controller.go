@@ -0,0 +1,236 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package credentials watches the underkube credentials secrets labelled with CredentialsLabel. When one
+// the machines use changes, it drops the cached clients built from it, checks the new credentials and makes the machine
+// controller reconcile the machines using them. It also runs the orphan scans requested on the secrets.
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/debug"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/managers/vm"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// ControllerName is the name of the credentials controller in the logs and metrics
+	ControllerName = "credentials-controller"
+
+	// CredentialsLabel marks the underkube credentials secrets the controller watches, so that it doesn't
+	// cache all the secrets of the cluster. The changes of an unlabelled secret are only picked up once the
+	// underkube refuses the credentials the machines use.
+	CredentialsLabel = "kubevirt.io/underkube-credentials"
+
+	// CredentialsVersionAnnotation holds the resource version of the credentials secret a machine was last
+	// enqueued for. Changing it is what makes the machine controller reconcile the machine.
+	CredentialsVersionAnnotation = "kubevirt.io/credentials-version"
+	// deletedSecretVersion is the credentials version of the machines whose secret was deleted
+	deletedSecretVersion = "deleted"
+
+	// OrphanScanAnnotation requests an orphan scan of the underkube of a credentials secret when set, or
+	// changed, on the secret, which must carry the CredentialsLabel. The report is written to the <secret>-orphan-scan ConfigMap, which is
+	// annotated with the value of the scan it reports.
+	OrphanScanAnnotation      = "kubevirt.io/orphan-scan"
+	orphanScanConfigMapSuffix = "-orphan-scan"
+	// orphanScanReportKey holds the vm.OrphanReport in json in the orphan scan ConfigMap
+	orphanScanReportKey = "report.json"
+)
+
+type reconciler struct {
+	client         client.Client
+	overkubeClient overkube.Client
+	clientCache    *underkube.ClientCache
+	providerConfig *providerconfig.Store
+}
+
+// Add creates the credentials controller and adds it to the manager, the limiter bounds how many secrets
+// it reconciles at once. The secrets are read from the API server rather than the cache of the manager,
+// which would hold all of them.
+func Add(mgr manager.Manager, overkubeClient overkube.Client, clientCache *underkube.ClientCache, limiter *debug.Limiter, providerConfig *providerconfig.Store) error {
+	r := &reconciler{
+		client:         mgr.GetClient(),
+		overkubeClient: overkubeClient,
+		clientCache:    clientCache,
+		providerConfig: providerConfig,
+	}
+	c, err := controller.New(ControllerName, mgr, controller.Options{
+		Reconciler:              limiter.Reconciler(r),
+		MaxConcurrentReconciles: limiter.Max(),
+	})
+	if err != nil {
+		return err
+	}
+
+	kubernetesClient, err := kubernetes.NewForConfig(mgr.GetConfig())
+	if err != nil {
+		return err
+	}
+	secrets := toolscache.NewSharedIndexInformer(
+		toolscache.NewFilteredListWatchFromClient(kubernetesClient.CoreV1().RESTClient(), "secrets", metav1.NamespaceAll, func(options *metav1.ListOptions) {
+			options.LabelSelector = CredentialsLabel
+		}),
+		&corev1.Secret{}, 0, toolscache.Indexers{},
+	)
+	if err := mgr.Add(manager.RunnableFunc(func(stop <-chan struct{}) error {
+		secrets.Run(stop)
+		return nil
+	})); err != nil {
+		return err
+	}
+	return c.Watch(&source.Informer{Informer: secrets}, &handler.EnqueueRequestForObject{})
+}
+
+// Reconcile handles a change of a secret. Secrets no machine refers to are ignored, unless they request an
+// orphan scan.
+func (r *reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	machines := &machinev1.MachineList{}
+	if err := r.client.List(context.Background(), machines, client.InNamespace(request.Namespace)); err != nil {
+		return reconcile.Result{}, err
+	}
+	machinesUsingSecret := machinesUsingSecret(machines.Items, request.Name, r.providerConfig.Get())
+
+	secretVersion := deletedSecretVersion
+	secret, err := r.overkubeClient.GetSecret(request.Name, request.Namespace)
+	if err == nil {
+		secretVersion = secret.ResourceVersion
+	} else if apimachineryerrors.IsNotFound(err) {
+		secret = &corev1.Secret{}
+	} else {
+		return reconcile.Result{}, err
+	}
+	scanToken := secret.Annotations[OrphanScanAnnotation]
+	if len(machinesUsingSecret) == 0 && scanToken == "" {
+		return reconcile.Result{}, nil
+	}
+
+	r.clientCache.Invalidate(request.Name, request.Namespace)
+	// Check the new credentials right away, so a broken secret or underkube is reported now rather than
+	// by the next machine reconcile
+	underkubeClient, err := r.clientCache.Get(r.overkubeClient, request.Name, request.Namespace)
+	if err != nil {
+		klog.Errorf("%v: the underkube credentials are not usable: %v", request.NamespacedName, err)
+	} else if capabilities, err := underkube.Preflight(underkubeClient, underkube.PreflightOptions{}); err != nil {
+		klog.Errorf("%v: the underkube preflight checks failed: %v", request.NamespacedName, err)
+	} else {
+		klog.Infof("%v: the underkube runs the capabilities %v", request.NamespacedName, capabilities)
+	}
+
+	if scanToken != "" && underkubeClient != nil {
+		if err := r.scanOrphans(secret, underkubeClient, machinesUsingSecret); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+
+	for _, machine := range machinesUsingSecret {
+		if machine.Annotations[CredentialsVersionAnnotation] == secretVersion {
+			continue
+		}
+		originMachine := machine.DeepCopy()
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[CredentialsVersionAnnotation] = secretVersion
+		klog.Infof("%s: credentials secret %v changed, enqueuing the machine", machine.GetName(), request.NamespacedName)
+		if err := r.client.Patch(context.Background(), machine, client.MergeFrom(originMachine)); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// scanOrphans writes the report of an orphan scan of the underkube of the secret to its orphan scan ConfigMap,
+// unless the ConfigMap already holds the report of the requested scan
+func (r *reconciler) scanOrphans(secret *corev1.Secret, underkubeClient underkube.Client, machines []*machinev1.Machine) error {
+	scanToken := secret.Annotations[OrphanScanAnnotation]
+	configMap := &corev1.ConfigMap{}
+	key := types.NamespacedName{Namespace: secret.Namespace, Name: secret.Name + orphanScanConfigMapSuffix}
+	err := r.client.Get(context.Background(), key, configMap)
+	exists := err == nil
+	switch {
+	case apimachineryerrors.IsNotFound(err):
+		configMap.Namespace = key.Namespace
+		configMap.Name = key.Name
+		configMap.OwnerReferences = []metav1.OwnerReference{{APIVersion: "v1", Kind: "Secret", Name: secret.Name, UID: secret.UID}}
+	case err != nil:
+		return err
+	case configMap.Annotations[OrphanScanAnnotation] == scanToken:
+		return nil
+	}
+
+	klog.Infof("%s/%s: scanning the underkube for orphan resources", secret.Namespace, secret.Name)
+	report, err := vm.ScanOrphans(underkubeClient, machines)
+	if err != nil {
+		return fmt.Errorf("orphan scan failed: %w", err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	klog.Infof("%s/%s: found %d orphan resources, see ConfigMap %v", secret.Namespace, secret.Name, len(report.Resources), key)
+
+	if configMap.Annotations == nil {
+		configMap.Annotations = map[string]string{}
+	}
+	configMap.Annotations[OrphanScanAnnotation] = scanToken
+	configMap.Data = map[string]string{orphanScanReportKey: string(data)}
+	if exists {
+		return r.client.Update(context.Background(), configMap)
+	}
+	return r.client.Create(context.Background(), configMap)
+}
+
+// machinesUsingSecret returns the machines using the credentials secret: the one of their annotation, else
+// the one of their provider spec, else the default one
+func machinesUsingSecret(machines []machinev1.Machine, secretName string, spec kubevirtproviderv1.KubevirtProviderConfigSpec) []*machinev1.Machine {
+	var result []*machinev1.Machine
+	for i := range machines {
+		providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(machines[i].Spec.ProviderSpec.Value)
+		if err != nil {
+			klog.V(3).Infof("%s: failed to get the provider spec: %v", machines[i].GetName(), err)
+			continue
+		}
+		machineSecretName, err := providerconfig.UnderKubeconfigSecretName(&machines[i], providerSpec, spec)
+		if err != nil {
+			klog.V(3).Infof("%s: %v", machines[i].GetName(), err)
+			continue
+		}
+		if machineSecretName == secretName {
+			result = append(result, &machines[i])
+		}
+	}
+	return result
+}
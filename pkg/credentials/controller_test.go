@@ -0,0 +1,56 @@
+package credentials
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestMachinesUsingSecret(t *testing.T) {
+	stubMachine := func(name, secretName string) machinev1.Machine {
+		providerSpec, err := kubevirtproviderv1.RawExtensionFromProviderSpec(&kubevirtproviderv1.KubevirtMachineProviderSpec{UnderKubeconfigSecretName: secretName})
+		assert.NilError(t, err)
+		machine := machinev1.Machine{}
+		machine.Name = name
+		machine.Spec.ProviderSpec.Value = providerSpec
+		return machine
+	}
+	machines := []machinev1.Machine{
+		stubMachine("worker-1", "kubeconfig"),
+		stubMachine("worker-2", "other-kubeconfig"),
+		stubMachine("worker-3", "kubeconfig"),
+		stubMachine("worker-4", ""),
+	}
+	invalid := machinev1.Machine{}
+	invalid.Name = "invalid"
+	invalid.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: []byte("{")}
+	machines = append(machines, invalid)
+
+	var names []string
+	for _, machine := range machinesUsingSecret(machines, "kubeconfig", kubevirtproviderv1.KubevirtProviderConfigSpec{}) {
+		names = append(names, machine.Name)
+	}
+	assert.DeepEqual(t, names, []string{"worker-1", "worker-3"})
+	assert.Assert(t, machinesUsingSecret(machines, "unused", kubevirtproviderv1.KubevirtProviderConfigSpec{}) == nil)
+
+	names = nil
+	for _, machine := range machinesUsingSecret(machines, "kubeconfig", kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultUnderKubeconfigSecretName: "kubeconfig"}) {
+		names = append(names, machine.Name)
+	}
+	assert.DeepEqual(t, names, []string{"worker-1", "worker-3", "worker-4"})
+
+	// worker-1 moves to the other secret, the override of worker-3 isn't allowed
+	machines[0].Annotations = map[string]string{providerconfig.CredentialsSecretAnnotation: "other-kubeconfig"}
+	machines[2].Annotations = map[string]string{providerconfig.CredentialsSecretAnnotation: "unknown"}
+	spec := kubevirtproviderv1.KubevirtProviderConfigSpec{CredentialsOverrideSecrets: []string{"other-kubeconfig"}}
+	names = nil
+	for _, machine := range machinesUsingSecret(machines, "other-kubeconfig", spec) {
+		names = append(names, machine.Name)
+	}
+	assert.DeepEqual(t, names, []string{"worker-1", "worker-2"})
+	assert.Assert(t, machinesUsingSecret(machines, "kubeconfig", spec) == nil)
+}
@@ -0,0 +1,99 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Limiter bounds the number of reconciles a controller runs at once. The controller starts a fixed number of
+// workers, the limiter lets fewer of them work, so the concurrency can be changed without restarting.
+type Limiter struct {
+	lock    sync.Mutex
+	changed *sync.Cond
+	limit   int
+	max     int
+	running int
+}
+
+// NewLimiter creates a limiter allowing limit reconciles at once, and up to max once changed
+func NewLimiter(limit, max int) *Limiter {
+	if max < 1 {
+		max = 1
+	}
+	l := &Limiter{max: max}
+	l.changed = sync.NewCond(&l.lock)
+	l.SetLimit(limit)
+	return l
+}
+
+// Max is the number of workers the controller must start
+func (l *Limiter) Max() int {
+	return l.max
+}
+
+// Limit returns the number of reconciles allowed at once
+func (l *Limiter) Limit() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.limit
+}
+
+// SetLimit changes the number of reconciles allowed at once, it is kept between 1 and the maximum. The
+// reconciles already running above a lowered limit are not interrupted.
+func (l *Limiter) SetLimit(limit int) int {
+	if limit < 1 {
+		limit = 1
+	}
+	if limit > l.max {
+		limit = l.max
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.limit = limit
+	l.changed.Broadcast()
+	return limit
+}
+
+// Acquire waits until a reconcile may run
+func (l *Limiter) Acquire() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for l.running >= l.limit {
+		l.changed.Wait()
+	}
+	l.running++
+}
+
+// Release ends a reconcile started by Acquire
+func (l *Limiter) Release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.running--
+	l.changed.Broadcast()
+}
+
+// Reconciler wraps the reconciler of a controller started with Max workers, so it runs within the limit
+func (l *Limiter) Reconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(request reconcile.Request) (reconcile.Result, error) {
+		l.Acquire()
+		defer l.Release()
+		return r.Reconcile(request)
+	})
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package debug serves the endpoints used to diagnose the provider while it runs: pprof, the metrics,
+// including the workqueue depth and latency and the reconcile duration of every controller, the
+// runtime tuning of the log verbosity and of the controllers concurrency, the resync of machines, and the
+// SSH tunnels to the machines.
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// klogFlags gives access to the klog verbosity, klog doesn't register its flags on the command line
+	klogFlags = flag.NewFlagSet("klog", flag.ContinueOnError)
+)
+
+func init() {
+	klog.InitFlags(klogFlags)
+}
+
+// Server serves the debug endpoints. It must only listen on a local or protected address: the
+// endpoints are not authenticated.
+type Server struct {
+	address       string
+	limiters      map[string]*Limiter
+	portForwarder PortForwarder
+	resync        ResyncFunc
+}
+
+// ResyncFunc forces the resync of the machine of the namespace and name, or when the name is empty of the
+// machines of the namespace using the credentials secret, or when it is empty as well of all of them
+type ResyncFunc func(namespace, name, secretName string)
+
+// New creates a debug server, to be added to the manager as a runnable. The limiters are the ones of
+// the controllers whose concurrency can be changed, by controller name. The port forwarder opens the
+// SSH tunnels, they are refused when it is nil, and so are the resyncs when the resync func is nil.
+func New(address string, limiters map[string]*Limiter, portForwarder PortForwarder, resync ResyncFunc) *Server {
+	return &Server{
+		address:       address,
+		limiters:      limiters,
+		portForwarder: portForwarder,
+		resync:        resync,
+	}
+}
+
+// Start serves the debug endpoints until the stop channel is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	server := &http.Server{Addr: s.address, Handler: s.handler()}
+	errs := make(chan error, 1)
+	go func() {
+		klog.Infof("Serving the debug endpoints on %s", s.address)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return fmt.Errorf("debug server failed: %w", err)
+	case <-stop:
+		return server.Shutdown(context.Background())
+	}
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("/debug/verbosity", serveVerbosity)
+	mux.HandleFunc("/debug/concurrency", s.serveConcurrency)
+	mux.HandleFunc("/debug/resync", s.serveResync)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The tunnels are HTTP CONNECT requests, which have no path to route
+		if r.Method == http.MethodConnect {
+			s.serveTunnel(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// serveVerbosity returns the klog verbosity, and changes it on a PUT with the v parameter
+func serveVerbosity(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		v := r.URL.Query().Get("v")
+		if _, err := strconv.ParseUint(v, 10, 31); err != nil {
+			http.Error(w, fmt.Sprintf("invalid verbosity %q", v), http.StatusBadRequest)
+			return
+		}
+		if err := klogFlags.Set("v", v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		klog.Infof("Log verbosity set to %s", v)
+	default:
+		http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, map[string]string{"v": klogFlags.Lookup("v").Value.String()})
+}
+
+// serveConcurrency returns the concurrency of the controllers, and changes the one of the controller
+// parameter on a PUT with the limit parameter
+func (s *Server) serveConcurrency(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		name := r.URL.Query().Get("controller")
+		limiter, ok := s.limiters[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown controller %q", name), http.StatusNotFound)
+			return
+		}
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid limit: %v", err), http.StatusBadRequest)
+			return
+		}
+		klog.Infof("Concurrency of controller %s set to %d", name, limiter.SetLimit(limit))
+	default:
+		http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	type concurrency struct {
+		Controller string `json:"controller"`
+		Limit      int    `json:"limit"`
+		Max        int    `json:"max"`
+	}
+	result := []concurrency{}
+	for name, limiter := range s.limiters {
+		result = append(result, concurrency{Controller: name, Limit: limiter.Limit(), Max: limiter.Max()})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Controller < result[j].Controller })
+	writeJSON(w, result)
+}
+
+// serveResync requests, on a POST, the resync of the machine of the namespace and name parameters, or of
+// the machines of the namespace using the credentials secret of the secret parameter, or else of all of
+// them
+func (s *Server) serveResync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.resync == nil {
+		http.Error(w, "resync is disabled", http.StatusNotFound)
+		return
+	}
+	query := r.URL.Query()
+	namespace := query.Get("namespace")
+	if namespace == "" {
+		http.Error(w, "missing namespace", http.StatusBadRequest)
+		return
+	}
+	s.resync(namespace, query.Get("name"), query.Get("secret"))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		klog.Errorf("failed to write the debug response: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestServer(t *testing.T) {
+	limiter := NewLimiter(1, 4)
+	var resyncs []string
+	resync := func(namespace, name, secretName string) {
+		resyncs = append(resyncs, namespace+"/"+name+"/"+secretName)
+	}
+	server := New("", map[string]*Limiter{"credentials-controller": limiter}, nil, resync)
+	defer klogFlags.Set("v", klogFlags.Lookup("v").Value.String())
+
+	cases := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "Set the verbosity",
+			method:     http.MethodPut,
+			url:        "/debug/verbosity?v=4",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"v":"4"}`,
+		},
+		{
+			name:       "Invalid verbosity",
+			method:     http.MethodPut,
+			url:        "/debug/verbosity?v=-1",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `invalid verbosity "-1"`,
+		},
+		{
+			name:       "Get the concurrency",
+			method:     http.MethodGet,
+			url:        "/debug/concurrency",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"controller":"credentials-controller","limit":1,"max":4}]`,
+		},
+		{
+			name:       "Raise the concurrency up to the maximum",
+			method:     http.MethodPut,
+			url:        "/debug/concurrency?controller=credentials-controller&limit=8",
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"controller":"credentials-controller","limit":4,"max":4}]`,
+		},
+		{
+			name:       "Unknown controller",
+			method:     http.MethodPut,
+			url:        "/debug/concurrency?controller=machine-controller&limit=2",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `unknown controller "machine-controller"`,
+		},
+		{
+			name:       "Resync the machines of an underkube",
+			method:     http.MethodPost,
+			url:        "/debug/resync?namespace=openshift-machine-api&secret=infra-a",
+			wantStatus: http.StatusAccepted,
+		},
+		{
+			name:       "Resync without namespace",
+			method:     http.MethodPost,
+			url:        "/debug/resync?name=worker-1",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   "missing namespace",
+		},
+		{
+			name:       "Metrics",
+			method:     http.MethodGet,
+			url:        "/metrics",
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			server.handler().ServeHTTP(recorder, httptest.NewRequest(tc.method, tc.url, nil))
+			assert.Equal(t, recorder.Code, tc.wantStatus)
+			if tc.wantBody != "" {
+				assert.Equal(t, strings.TrimSpace(recorder.Body.String()), tc.wantBody)
+			}
+		})
+	}
+	assert.DeepEqual(t, resyncs, []string{"openshift-machine-api//infra-a"})
+}
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(1, 2)
+	limiter.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.Acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired above the limit")
+	default:
+	}
+
+	assert.Equal(t, limiter.SetLimit(2), 2)
+	<-acquired
+	limiter.Release()
+	limiter.Release()
+	assert.Equal(t, limiter.SetLimit(0), 1)
+}
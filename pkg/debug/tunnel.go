@@ -0,0 +1,90 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package debug
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog"
+)
+
+// sshPort is the only port of the machines the tunnels reach
+const sshPort = 22
+
+// PortForwarder opens streams to the ports of the VMs of the machines
+type PortForwarder interface {
+	PortForward(namespace, machineName string, port int) (io.ReadWriteCloser, error)
+}
+
+// serveTunnel tunnels an HTTP CONNECT to <machine>.<namespace>:22 to the SSH port of the VM of the machine,
+// e.g. with ssh -o ProxyCommand='nc -X connect -x <debug address> %h %p' core@<machine>.<namespace>
+func (s *Server) serveTunnel(w http.ResponseWriter, r *http.Request) {
+	if s.portForwarder == nil {
+		http.Error(w, "tunnels are not supported", http.StatusNotFound)
+		return
+	}
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil || port != fmt.Sprint(sshPort) {
+		http.Error(w, fmt.Sprintf("invalid tunnel %q, expected <machine>.<namespace>:%d", r.Host, sshPort), http.StatusBadRequest)
+		return
+	}
+	// The namespaces are DNS labels, the machine names may contain dots
+	i := strings.LastIndex(host, ".")
+	if i <= 0 || i == len(host)-1 {
+		http.Error(w, fmt.Sprintf("invalid tunnel %q, expected <machine>.<namespace>:%d", r.Host, sshPort), http.StatusBadRequest)
+		return
+	}
+	namespace, machineName := host[i+1:], host[:i]
+
+	stream, err := s.portForwarder.PortForward(namespace, machineName, sshPort)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to forward the SSH port of machine %s/%s: %v", namespace, machineName, err), http.StatusBadGateway)
+		return
+	}
+	defer stream.Close()
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "the connection can't be tunneled", http.StatusInternalServerError)
+		return
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		klog.Errorf("failed to tunnel to machine %s/%s: %v", namespace, machineName, err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		return
+	}
+
+	klog.Infof("Tunneling %s to the SSH port of machine %s/%s", conn.RemoteAddr(), namespace, machineName)
+	// The tunnel ends when either side closes, the deferred closes stop the other copy
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(stream, buffered)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, stream)
+		done <- struct{}{}
+	}()
+	<-done
+}
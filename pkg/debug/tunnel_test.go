@@ -0,0 +1,67 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+type fakePortForwarder struct {
+	forwarded string
+}
+
+// PortForward returns a stream to an echo server
+func (f *fakePortForwarder) PortForward(namespace, machineName string, port int) (io.ReadWriteCloser, error) {
+	if machineName == "missing" {
+		return nil, fmt.Errorf("machine %s not found", machineName)
+	}
+	f.forwarded = fmt.Sprintf("%s/%s:%d", namespace, machineName, port)
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		io.Copy(server, server)
+	}()
+	return client, nil
+}
+
+func TestServeTunnel(t *testing.T) {
+	portForwarder := &fakePortForwarder{}
+	server := httptest.NewServer(New("", nil, portForwarder, nil).handler())
+	defer server.Close()
+
+	connect := func(target string) (net.Conn, *bufio.Reader, *http.Response) {
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		assert.NilError(t, err)
+		fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+		reader := bufio.NewReader(conn)
+		response, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+		assert.NilError(t, err)
+		return conn, reader, response
+	}
+
+	conn, reader, response := connect("worker-0.example.com.openshift-machine-api:22")
+	defer conn.Close()
+	assert.Equal(t, response.StatusCode, http.StatusOK)
+	assert.Equal(t, portForwarder.forwarded, "openshift-machine-api/worker-0.example.com:22")
+	_, err := conn.Write([]byte("SSH-2.0-client\r\n"))
+	assert.NilError(t, err)
+	line, err := reader.ReadString('\n')
+	assert.NilError(t, err)
+	assert.Equal(t, line, "SSH-2.0-client\r\n")
+
+	for target, wantStatus := range map[string]int{
+		"worker-0.openshift-machine-api:6443": http.StatusBadRequest,
+		"worker-0:22":                         http.StatusBadRequest,
+		"missing.openshift-machine-api:22":    http.StatusBadGateway,
+	} {
+		conn, _, response := connect(target)
+		conn.Close()
+		assert.Equal(t, response.StatusCode, wantStatus, target)
+	}
+}
@@ -0,0 +1,22 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// validateAnnotationProfiles checks the profiles are known, and listed once
+func validateAnnotationProfiles(machineName string, profiles []kubevirtproviderv1.AnnotationProfile) error {
+	seen := map[kubevirtproviderv1.AnnotationProfile]bool{}
+	for _, profile := range profiles {
+		if !render.IsAnnotationProfile(profile) {
+			return machinecontroller.InvalidMachineConfiguration("%v: unknown annotation profile %q", machineName, profile)
+		}
+		if seen[profile] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate annotation profile %q", machineName, profile)
+		}
+		seen[profile] = true
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateAnnotationProfiles(t *testing.T) {
+	cases := []struct {
+		name     string
+		profiles []kubevirtproviderv1.AnnotationProfile
+		wantErr  string
+	}{
+		{
+			name: "No profile",
+		},
+		{
+			name:     "Known profiles",
+			profiles: []kubevirtproviderv1.AnnotationProfile{kubevirtproviderv1.MigratableBridgeProfile, kubevirtproviderv1.DeschedulerEvictableProfile},
+		},
+		{
+			name:     "Unknown profile",
+			profiles: []kubevirtproviderv1.AnnotationProfile{"numa-passthrough"},
+			wantErr:  `machine-test: unknown annotation profile "numa-passthrough"`,
+		},
+		{
+			name:     "Duplicate profile",
+			profiles: []kubevirtproviderv1.AnnotationProfile{kubevirtproviderv1.MigratableBridgeProfile, kubevirtproviderv1.MigratableBridgeProfile},
+			wantErr:  `machine-test: duplicate annotation profile "migratable-bridge"`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAnnotationProfiles("machine-test", tc.profiles)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
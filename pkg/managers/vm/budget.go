@@ -0,0 +1,150 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// budgetExceededError reports the VM would make its tenant cluster exceed its budget
+type budgetExceededError struct {
+	message string
+}
+
+func (e *budgetExceededError) Error() string {
+	return e.message
+}
+
+// vmUsage is the resources a VM counts against the budget of its tenant cluster
+type vmUsage struct {
+	cpu     apiresource.Quantity
+	memory  apiresource.Quantity
+	storage apiresource.Quantity
+}
+
+func (u *vmUsage) add(other vmUsage) {
+	u.cpu.Add(other.cpu)
+	u.memory.Add(other.memory)
+	u.storage.Add(other.storage)
+}
+
+// checkBudget refuses to create the VM when the VMs of the tenant cluster, with it, would exceed the
+// budget the provider config sets for the cluster. Unlike a resource quota, the budget tells apart the
+// tenant clusters sharing an underkube namespace.
+func (m *manager) checkBudget(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	clusterID, _ := render.ClusterID(machineScope.machine)
+	budget := findBudget(machineScope.providerConfig.Budgets, clusterID)
+	if budget == nil {
+		if machineScope.machineProviderStatus != nil && conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.QuotaExceededCondition) != nil {
+			machineScope.setCondition(conditions.False(kubevirtproviderv1.QuotaExceededCondition, "NoBudget", ""))
+		}
+		return nil
+	}
+
+	vms, err := machineScope.underkubeClient.ListVirtualMachine(vm.Namespace, &k8smetav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + "=" + clusterID})
+	if err != nil {
+		return fmt.Errorf("failed to list the VMs of cluster %s: %w", clusterID, err)
+	}
+	usage := virtualMachineUsage(vm)
+	for i := range vms.Items {
+		if vms.Items[i].Name != vm.Name {
+			usage.add(virtualMachineUsage(&vms.Items[i]))
+		}
+	}
+
+	message, err := budgetExceededMessage(budget, usage)
+	if err != nil {
+		return machinecontroller.InvalidMachineConfiguration("%v: %v", machineScope.getMachineName(), err)
+	}
+	if message != "" {
+		machineScope.setCondition(conditions.New(kubevirtproviderv1.QuotaExceededCondition, corev1.ConditionTrue, "BudgetExceeded", message))
+		return &budgetExceededError{message: fmt.Sprintf("%s: not creating the VM: %s", machineScope.getMachineName(), message)}
+	}
+	machineScope.setCondition(conditions.False(kubevirtproviderv1.QuotaExceededCondition, "WithinBudget", ""))
+	return nil
+}
+
+func findBudget(budgets []kubevirtproviderv1.ClusterBudget, clusterID string) *kubevirtproviderv1.ClusterBudget {
+	for i := range budgets {
+		if budgets[i].ClusterID == clusterID {
+			return &budgets[i]
+		}
+	}
+	return nil
+}
+
+// virtualMachineUsage sums the vCPUs and the memory of the VM and the size of its data volumes. The vCPUs
+// are the most of the CPU request, rounded up, and of the sockets, cores and threads of the CPU topology,
+// one when neither is set. The memory is the memory request, else the guest memory.
+func virtualMachineUsage(vm *kubevirtapiv1.VirtualMachine) vmUsage {
+	usage := vmUsage{}
+	vcpus := int64(1)
+	if vm.Spec.Template != nil {
+		domain := vm.Spec.Template.Spec.Domain
+		if cpu, ok := domain.Resources.Requests[corev1.ResourceCPU]; ok && (cpu.MilliValue()+999)/1000 > vcpus {
+			vcpus = (cpu.MilliValue() + 999) / 1000
+		}
+		if domain.CPU != nil {
+			topology := int64(1)
+			for _, count := range []uint32{domain.CPU.Sockets, domain.CPU.Cores, domain.CPU.Threads} {
+				if count > 0 {
+					topology *= int64(count)
+				}
+			}
+			if topology > vcpus {
+				vcpus = topology
+			}
+		}
+		if memory, ok := domain.Resources.Requests[corev1.ResourceMemory]; ok {
+			usage.memory = memory
+		} else if domain.Memory != nil && domain.Memory.Guest != nil {
+			usage.memory = *domain.Memory.Guest
+		}
+	}
+	usage.cpu = *apiresource.NewQuantity(vcpus, apiresource.DecimalSI)
+	for _, dataVolume := range vm.Spec.DataVolumeTemplates {
+		if dataVolume.Spec.PVC != nil {
+			usage.storage.Add(dataVolume.Spec.PVC.Resources.Requests[corev1.ResourceStorage])
+		}
+	}
+	return usage
+}
+
+// budgetExceededMessage lists the limits of the budget the usage exceeds, it is empty when it fits
+func budgetExceededMessage(budget *kubevirtproviderv1.ClusterBudget, usage vmUsage) (string, error) {
+	limits := []struct {
+		name  string
+		max   string
+		usage apiresource.Quantity
+	}{
+		{"CPU", budget.MaxCPU, usage.cpu},
+		{"memory", budget.MaxMemory, usage.memory},
+		{"storage", budget.MaxStorage, usage.storage},
+	}
+	var exceeded []string
+	for _, limit := range limits {
+		if limit.max == "" {
+			continue
+		}
+		max, err := apiresource.ParseQuantity(limit.max)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s budget %q of cluster %s: %v", limit.name, limit.max, budget.ClusterID, err)
+		}
+		if limit.usage.Cmp(max) > 0 {
+			exceeded = append(exceeded, fmt.Sprintf("%s %s over the budget of %s", limit.name, limit.usage.String(), max.String()))
+		}
+	}
+	if len(exceeded) == 0 {
+		return "", nil
+	}
+	return fmt.Sprintf("the VMs of cluster %s would use %s", budget.ClusterID, strings.Join(exceeded, ", ")), nil
+}
@@ -0,0 +1,147 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestCheckBudget(t *testing.T) {
+	stubClusterVMDomain := func(name string, domain kubevirtapiv1.DomainSpec) kubevirtapiv1.VirtualMachine {
+		return kubevirtapiv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: kubevirtapiv1.VirtualMachineSpec{Template: &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kubevirtapiv1.VirtualMachineInstanceSpec{Domain: domain},
+			}},
+		}
+	}
+	stubClusterVM := func(name, memory string) kubevirtapiv1.VirtualMachine {
+		return stubClusterVMDomain(name, kubevirtapiv1.DomainSpec{Resources: kubevirtapiv1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: apiresource.MustParse(memory)},
+		}})
+	}
+	guestMemory := apiresource.MustParse("4Gi")
+
+	cases := []struct {
+		name          string
+		budgets       []kubevirtproviderv1.ClusterBudget
+		clusterVMs    []kubevirtapiv1.VirtualMachine
+		wantErr       string
+		wantCondition *kubevirtproviderv1.KubevirtMachineCondition
+	}{
+		{
+			name:    "No budget",
+			budgets: []kubevirtproviderv1.ClusterBudget{{ClusterID: "other-cluster", MaxMemory: "1Gi"}},
+		},
+		{
+			name:          "Within budget",
+			budgets:       []kubevirtproviderv1.ClusterBudget{{ClusterID: clusterID, MaxCPU: "2", MaxMemory: "6Gi", MaxStorage: "100Gi"}},
+			clusterVMs:    []kubevirtapiv1.VirtualMachine{stubClusterVM("other-machine", "4Gi"), stubClusterVM(mahcineName, "64Gi")},
+			wantCondition: &kubevirtproviderv1.KubevirtMachineCondition{Status: corev1.ConditionFalse, Reason: "WithinBudget"},
+		},
+		{
+			name:       "Budget exceeded",
+			budgets:    []kubevirtproviderv1.ClusterBudget{{ClusterID: clusterID, MaxCPU: "1", MaxMemory: "4Gi", MaxStorage: "50Gi"}},
+			clusterVMs: []kubevirtapiv1.VirtualMachine{stubClusterVM("other-machine", "4Gi")},
+			wantErr:    mahcineName + ": not creating the VM: the VMs of cluster " + clusterID + " would use CPU 2 over the budget of 1, memory 6342967296 over the budget of 4Gi",
+			wantCondition: &kubevirtproviderv1.KubevirtMachineCondition{
+				Status:  corev1.ConditionTrue,
+				Reason:  "BudgetExceeded",
+				Message: "the VMs of cluster " + clusterID + " would use CPU 2 over the budget of 1, memory 6342967296 over the budget of 4Gi",
+			},
+		},
+		{
+			name:    "CPU topology counted",
+			budgets: []kubevirtproviderv1.ClusterBudget{{ClusterID: clusterID, MaxCPU: "4"}},
+			clusterVMs: []kubevirtapiv1.VirtualMachine{stubClusterVMDomain("other-machine", kubevirtapiv1.DomainSpec{
+				CPU: &kubevirtapiv1.CPU{Sockets: 2, Cores: 8},
+			})},
+			wantErr: mahcineName + ": not creating the VM: the VMs of cluster " + clusterID + " would use CPU 17 over the budget of 4",
+			wantCondition: &kubevirtproviderv1.KubevirtMachineCondition{
+				Status:  corev1.ConditionTrue,
+				Reason:  "BudgetExceeded",
+				Message: "the VMs of cluster " + clusterID + " would use CPU 17 over the budget of 4",
+			},
+		},
+		{
+			name:    "Fractional CPU request rounded up",
+			budgets: []kubevirtproviderv1.ClusterBudget{{ClusterID: clusterID, MaxCPU: "1"}},
+			clusterVMs: []kubevirtapiv1.VirtualMachine{stubClusterVMDomain("other-machine", kubevirtapiv1.DomainSpec{
+				Resources: kubevirtapiv1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("500m")}},
+			})},
+			wantErr: mahcineName + ": not creating the VM: the VMs of cluster " + clusterID + " would use CPU 2 over the budget of 1",
+			wantCondition: &kubevirtproviderv1.KubevirtMachineCondition{
+				Status:  corev1.ConditionTrue,
+				Reason:  "BudgetExceeded",
+				Message: "the VMs of cluster " + clusterID + " would use CPU 2 over the budget of 1",
+			},
+		},
+		{
+			name:    "Guest memory counted without request",
+			budgets: []kubevirtproviderv1.ClusterBudget{{ClusterID: clusterID, MaxMemory: "4Gi"}},
+			clusterVMs: []kubevirtapiv1.VirtualMachine{stubClusterVMDomain("other-machine", kubevirtapiv1.DomainSpec{
+				Memory: &kubevirtapiv1.Memory{Guest: &guestMemory},
+			})},
+			wantErr: mahcineName + ": not creating the VM: the VMs of cluster " + clusterID + " would use memory 6342967296 over the budget of 4Gi",
+			wantCondition: &kubevirtproviderv1.KubevirtMachineCondition{
+				Status:  corev1.ConditionTrue,
+				Reason:  "BudgetExceeded",
+				Message: "the VMs of cluster " + clusterID + " would use memory 6342967296 over the budget of 4Gi",
+			},
+		},
+		{
+			name:    "Invalid budget",
+			budgets: []kubevirtproviderv1.ClusterBudget{{ClusterID: clusterID, MaxMemory: "lots"}},
+			wantErr: mahcineName + `: invalid memory budget "lots" of cluster ` + clusterID + ": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope, err := stubMachineScope(machine, mockOverkube, func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			})
+			assert.NilError(t, err)
+			machineScope.providerConfig.Budgets = tc.budgets
+			vm := stubVirtualMachine(t, machineScope)
+			if findBudget(tc.budgets, clusterID) != nil {
+				mockUnderkube.EXPECT().ListVirtualMachine(vm.Namespace, &metav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + "=" + clusterID}).
+					Return(&kubevirtapiv1.VirtualMachineList{Items: tc.clusterVMs}, nil)
+			}
+
+			err = (&manager{}).checkBudget(vm, machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			condition := conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.QuotaExceededCondition)
+			if tc.wantCondition == nil {
+				assert.Assert(t, condition == nil)
+				return
+			}
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, tc.wantCondition.Status)
+			assert.Equal(t, condition.Reason, tc.wantCondition.Reason)
+			assert.Equal(t, condition.Message, tc.wantCondition.Message)
+		})
+	}
+}
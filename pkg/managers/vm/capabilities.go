@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	corev1 "k8s.io/api/core/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// capabilityNeed is an underkube capability a feature of the machine depends on
+type capabilityNeed struct {
+	capability underkube.Capability
+	feature    string
+	// required needs fail the machine when the capability is missing, the other features degrade
+	required bool
+}
+
+// infraCapabilityNeeds returns the underkube capabilities the features of the machine depend on
+func infraCapabilityNeeds(vm *kubevirtapiv1.VirtualMachine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) []capabilityNeed {
+	var needs []capabilityNeed
+	if len(vm.Spec.DataVolumeTemplates) > 0 {
+		needs = append(needs, capabilityNeed{capability: underkube.CDICapability, feature: "boot volume", required: true})
+	}
+	for _, iface := range providerSpec.Interfaces {
+		if providerSpec.MacAddressAllocation == kubevirtproviderv1.MacAddressAllocationPool && iface.MacAddress == "" {
+			needs = append(needs, capabilityNeed{capability: underkube.KubeMacPoolCapability, feature: "MAC address pool of interface " + iface.Name, required: true})
+		}
+		if iface.NetworkName == "" {
+			continue
+		}
+		needs = append(needs, capabilityNeed{capability: underkube.MultusCapability, feature: "interface " + iface.Name, required: true})
+		// Without KubeMacPool, the NIC gets a new MAC address, and so a new DHCP lease, on every VM start
+		needs = append(needs, capabilityNeed{capability: underkube.KubeMacPoolCapability, feature: "stable MAC address of interface " + iface.Name})
+	}
+	return needs
+}
+
+// requiredCapabilities returns the capabilities of the required needs
+func requiredCapabilities(needs []capabilityNeed) []underkube.Capability {
+	var capabilities []underkube.Capability
+	seen := map[underkube.Capability]bool{}
+	for _, need := range needs {
+		if need.required && !seen[need.capability] {
+			seen[need.capability] = true
+			capabilities = append(capabilities, need.capability)
+		}
+	}
+	return capabilities
+}
+
+// missingCapabilitiesCondition consolidates the needs the underkube capabilities don't meet in a single
+// condition, listing the features each missing capability affects
+func missingCapabilitiesCondition(needs []capabilityNeed, capabilities underkube.Capabilities) kubevirtproviderv1.KubevirtMachineCondition {
+	var missing []underkube.Capability
+	features := map[underkube.Capability][]string{}
+	for _, need := range needs {
+		if capabilities[need.capability] {
+			continue
+		}
+		if _, ok := features[need.capability]; !ok {
+			missing = append(missing, need.capability)
+		}
+		features[need.capability] = append(features[need.capability], need.feature)
+	}
+	if len(missing) == 0 {
+		return conditions.False(kubevirtproviderv1.MissingInfraCapabilitiesCondition, "CapabilitiesAvailable", "")
+	}
+
+	messages := make([]string, 0, len(missing))
+	for _, capability := range missing {
+		messages = append(messages, fmt.Sprintf("%s (%s): %s", capability, strings.Join(features[capability], ", "), underkube.CapabilityHint(capability)))
+	}
+	return conditions.New(kubevirtproviderv1.MissingInfraCapabilitiesCondition, corev1.ConditionTrue, "MissingInfraCapabilities", strings.Join(messages, "; "))
+}
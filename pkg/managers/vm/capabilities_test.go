@@ -0,0 +1,68 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func TestMissingCapabilitiesCondition(t *testing.T) {
+	vm := &kubevirtapiv1.VirtualMachine{}
+	vm.Spec.DataVolumeTemplates = []cdiv1.DataVolume{{}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		Interfaces: []kubevirtproviderv1.NetworkInterface{
+			{Name: "default"},
+			{Name: "storage", NetworkName: "storage-network"},
+			{Name: "workload", NetworkName: "workload-network"},
+		},
+	}
+	needs := infraCapabilityNeeds(vm, providerSpec)
+	assert.DeepEqual(t, requiredCapabilities(needs), []underkube.Capability{underkube.CDICapability, underkube.MultusCapability})
+
+	cases := []struct {
+		name         string
+		capabilities underkube.Capabilities
+		wantStatus   corev1.ConditionStatus
+		wantMessage  string
+	}{
+		{
+			name:         "Nothing missing",
+			capabilities: underkube.Capabilities{underkube.CDICapability: true, underkube.MultusCapability: true, underkube.KubeMacPoolCapability: true},
+			wantStatus:   corev1.ConditionFalse,
+		},
+		{
+			name:         "List the features of every missing capability",
+			capabilities: underkube.Capabilities{underkube.CDICapability: true},
+			wantStatus:   corev1.ConditionTrue,
+			wantMessage: "Multus (interface storage, interface workload): install Multus on the underkube; " +
+				"KubeMacPool (stable MAC address of interface storage, stable MAC address of interface workload): install KubeMacPool on the underkube",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			condition := missingCapabilitiesCondition(needs, tc.capabilities)
+			assert.Equal(t, condition.Type, kubevirtproviderv1.MissingInfraCapabilitiesCondition)
+			assert.Equal(t, condition.Status, tc.wantStatus)
+			assert.Equal(t, condition.Message, tc.wantMessage)
+		})
+	}
+}
+
+func TestMacAddressPoolCapabilityNeeds(t *testing.T) {
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		MacAddressAllocation: kubevirtproviderv1.MacAddressAllocationPool,
+		Interfaces: []kubevirtproviderv1.NetworkInterface{
+			{Name: "default"},
+			{Name: "tenant", NetworkName: "tenant-network", MacAddress: "52:54:00:00:00:01"},
+		},
+	}
+	needs := infraCapabilityNeeds(&kubevirtapiv1.VirtualMachine{}, providerSpec)
+	assert.DeepEqual(t, requiredCapabilities(needs), []underkube.Capability{underkube.KubeMacPoolCapability, underkube.MultusCapability})
+	condition := missingCapabilitiesCondition(needs, underkube.Capabilities{underkube.MultusCapability: true})
+	assert.Equal(t, condition.Message, "KubeMacPool (MAC address pool of interface default, stable MAC address of interface tenant): install KubeMacPool on the underkube")
+}
@@ -0,0 +1,158 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// removeVMDependents removes the underkube resources the provider creates next to the VM: its service, or
+// its endpoint in the shared service, its user data secret and its ingress
+func (m *manager) removeVMDependents(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	if err := m.removeServiceIfNeeded(vm, machineScope); err != nil {
+		return fmt.Errorf("failed to delete the service of VM: %w", err)
+	}
+	if err := m.removeUserDataSecretIfNeeded(vm, machineScope); err != nil {
+		return fmt.Errorf("failed to delete the user data secret of VM: %w", err)
+	}
+	if err := m.removeIngressIfNeeded(vm, machineScope); err != nil {
+		return fmt.Errorf("failed to delete the ingress of VM: %w", err)
+	}
+	if err := m.revokeClones(vm, machineScope); err != nil {
+		return fmt.Errorf("failed to revoke the clone permissions of VM: %w", err)
+	}
+	return nil
+}
+
+// removeDependentsOfDeletedVM removes the dependent resources of the VM once it is gone, and reports the
+// ones remaining
+func (m *manager) removeDependentsOfDeletedVM(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	if err := m.removeVMDependents(vm, machineScope); err != nil {
+		return err
+	}
+	return m.syncCleanupCondition(vm, machineScope)
+}
+
+// existsIfDeleting is the existence of a machine whose VM is gone. A deleted machine keeps existing until
+// the dependent resources of its VM are gone as well, so the machine controller calls Delete again on a half
+// deleted machine instead of removing its finalizer. It only reads, Delete reports the remaining resources.
+func (m *manager) existsIfDeleting(machineScope *machineScope) (bool, error) {
+	if machineScope.machine.DeletionTimestamp == nil {
+		return false, nil
+	}
+	vm, err := machineScope.createVirtualMachineFromMachine()
+	if err != nil {
+		return false, err
+	}
+	remaining, err := m.remainingVMResources(vm, machineScope)
+	if err != nil {
+		return false, err
+	}
+	return len(remaining) > 0, nil
+}
+
+// syncCleanupCondition reports the dependent resources of the deleted VM still in the underkube in the
+// CleanupComplete condition
+func (m *manager) syncCleanupCondition(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	remaining, err := m.remainingVMResources(vm, machineScope)
+	if err != nil {
+		return err
+	}
+	if len(remaining) > 0 {
+		message := fmt.Sprintf("remaining resources: %s", strings.Join(remaining, ", "))
+		klog.Infof("%s: VM deleted, %s", machineScope.getMachineName(), message)
+		machineScope.setCondition(conditions.False(kubevirtproviderv1.CleanupCompleteCondition, "ResourcesRemaining", message))
+	} else {
+		machineScope.setCondition(conditions.True(kubevirtproviderv1.CleanupCompleteCondition, "ResourcesRemoved"))
+	}
+	return nil
+}
+
+// remainingVMResources lists, as kind/name, the dependent resources of the deleted VM still in the underkube.
+// The data volumes are only waited for while the VM owns them, the garbage collector removes them.
+func (m *manager) remainingVMResources(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) ([]string, error) {
+	var remaining []string
+	found := func(kind, name string, err error) error {
+		if apimachineryerrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the %s %s of the VM: %w", kind, name, err)
+		}
+		remaining = append(remaining, kind+"/"+name)
+		return nil
+	}
+
+	if machineScope.isSharedServiceMode() {
+		name := sharedServiceName(machineScope.machine)
+		endpoints, err := machineScope.underkubeClient.GetEndpoints(name, vm.Namespace, k8smetav1.GetOptions{})
+		if err == nil && !hasSharedServiceEndpoint(endpoints, vm.Name) {
+			err = apimachineryerrors.NewNotFound(corev1.Resource("endpoints"), name)
+		}
+		if err := found("Endpoints", name, err); err != nil {
+			return nil, err
+		}
+	}
+	if machineScope.hasMachineService() {
+		_, err := machineScope.underkubeClient.GetService(vm.Name, vm.Namespace, k8smetav1.GetOptions{})
+		if err := found("Service", vm.Name, err); err != nil {
+			return nil, err
+		}
+	}
+
+	secretName := buildUserDataSecretName(vm.Name)
+	_, err := machineScope.underkubeClient.GetSecret(secretName, vm.Namespace, k8smetav1.GetOptions{})
+	if err := found("Secret", secretName, err); err != nil {
+		return nil, err
+	}
+
+	if machineScope.hasIngress() {
+		// An underkube without ingress RBAC can't have the ingress either
+		_, err = machineScope.underkubeClient.GetIngress(vm.Name, vm.Namespace, k8smetav1.GetOptions{})
+		if apimachineryerrors.IsForbidden(err) {
+			err = apimachineryerrors.NewNotFound(networkingv1beta1.Resource("ingresses"), vm.Name)
+		}
+		if err := found("Ingress", vm.Name, err); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, template := range vm.Spec.DataVolumeTemplates {
+		dataVolume, err := machineScope.underkubeClient.GetDataVolume(template.Name, vm.Namespace, k8smetav1.GetOptions{})
+		if err == nil && !isOwnedByVM(dataVolume.OwnerReferences, vm.Name) {
+			continue
+		}
+		if err := found("DataVolume", template.Name, err); err != nil {
+			return nil, err
+		}
+	}
+	return remaining, nil
+}
+
+func hasSharedServiceEndpoint(endpoints *corev1.Endpoints, vmName string) bool {
+	for _, subset := range endpoints.Subsets {
+		for _, address := range append(subset.Addresses, subset.NotReadyAddresses...) {
+			if address.Hostname == vmName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isOwnedByVM(owners []k8smetav1.OwnerReference, vmName string) bool {
+	for _, owner := range owners {
+		if owner.Kind == "VirtualMachine" && owner.Name == vmName {
+			return true
+		}
+	}
+	return false
+}
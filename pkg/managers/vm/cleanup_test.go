@@ -0,0 +1,141 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func TestExistsIfDeleting(t *testing.T) {
+	notFound := apimachineryerrors.NewNotFound(schema.GroupResource{}, "")
+	ownedDataVolume := &cdiv1.DataVolume{ObjectMeta: metav1.ObjectMeta{
+		OwnerReferences: []metav1.OwnerReference{{Kind: "VirtualMachine", Name: mahcineName}},
+	}}
+
+	cases := []struct {
+		name          string
+		notDeleting   bool
+		serviceErr    error
+		secretErr     error
+		ingressErr    error
+		hasIngress    bool
+		dataVolume    *cdiv1.DataVolume
+		dataVolumeErr error
+		wantExists    bool
+		wantMessage   string
+		wantErr       string
+	}{
+		{
+			name:        "Machine not deleted",
+			notDeleting: true,
+		},
+		{
+			name:          "All resources removed",
+			serviceErr:    notFound,
+			secretErr:     notFound,
+			ingressErr:    notFound,
+			dataVolumeErr: notFound,
+		},
+		{
+			name:        "Service and secret remaining",
+			ingressErr:  notFound,
+			dataVolume:  &cdiv1.DataVolume{},
+			wantExists:  true,
+			wantMessage: "remaining resources: Service/machine-test, Secret/machine-test-userdata",
+		},
+		{
+			name:        "Data volume owned by the VM remaining",
+			serviceErr:  notFound,
+			secretErr:   notFound,
+			ingressErr:  notFound,
+			dataVolume:  ownedDataVolume,
+			wantExists:  true,
+			wantMessage: "remaining resources: DataVolume/" + render.BootVolumeName(mahcineName),
+		},
+		{
+			name:          "Ingress remaining",
+			serviceErr:    notFound,
+			secretErr:     notFound,
+			hasIngress:    true,
+			dataVolumeErr: notFound,
+			wantExists:    true,
+			wantMessage:   "remaining resources: Ingress/machine-test",
+		},
+		{
+			name:          "Ingress not readable",
+			serviceErr:    notFound,
+			secretErr:     notFound,
+			hasIngress:    true,
+			ingressErr:    apimachineryerrors.NewForbidden(networkingv1beta1.Resource("ingresses"), mahcineName, errors.New("no RBAC")),
+			dataVolumeErr: notFound,
+		},
+		{
+			name:       "Error getting the service",
+			serviceErr: errors.New("client error"),
+			wantErr:    "failed to get the Service machine-test of the VM: client error",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			if !tc.notDeleting {
+				machine.DeletionTimestamp = &metav1.Time{}
+			}
+			underkubeClientBuilder := func(kubernetesClient overkube.Client, secretName, namespace string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			}
+			machineScope, err := stubMachineScope(machine, mockOverkube, underkubeClientBuilder)
+			assert.NilError(t, err)
+			machineScope.machineProviderStatus.IngressCreated = tc.hasIngress
+
+			mockUnderkube.EXPECT().GetService(mahcineName, clusterID, gomock.Any()).Return(&corev1.Service{}, tc.serviceErr).AnyTimes()
+			mockUnderkube.EXPECT().GetSecret(buildUserDataSecretName(mahcineName), clusterID, gomock.Any()).Return(&corev1.Secret{}, tc.secretErr).AnyTimes()
+			mockUnderkube.EXPECT().GetIngress(mahcineName, clusterID, gomock.Any()).Return(&networkingv1beta1.Ingress{}, tc.ingressErr).AnyTimes()
+			mockUnderkube.EXPECT().GetDataVolume(render.BootVolumeName(mahcineName), clusterID, gomock.Any()).Return(tc.dataVolume, tc.dataVolumeErr).AnyTimes()
+			mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+			mockUnderkube.EXPECT().AllowedNamespaces().Return(nil).AnyTimes()
+
+			exists, err := (&manager{}).existsIfDeleting(machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, exists, tc.wantExists)
+			// Exists only reads, the condition is reported by Delete
+			assert.Assert(t, conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.CleanupCompleteCondition) == nil)
+			if tc.notDeleting {
+				return
+			}
+
+			vm, err := machineScope.createVirtualMachineFromMachine()
+			assert.NilError(t, err)
+			assert.NilError(t, (&manager{}).syncCleanupCondition(vm, machineScope))
+			condition := conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.CleanupCompleteCondition)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status == corev1.ConditionTrue, !tc.wantExists)
+			assert.Equal(t, condition.Message, tc.wantMessage)
+		})
+	}
+}
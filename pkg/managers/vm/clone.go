@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"fmt"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	// CloneAnnotation requests a clone of the machine, named after its value. The clone boots from a clone
+	// of the boot volume of the machine, so a misbehaving machine can be debugged without touching it. The
+	// clone of a machine whose node joined is created stopped, with the power state annotation, as it would
+	// join with the identity of that node: inspect its disk, or isolate it, before starting it. The
+	// annotation is removed once the clone is created, or the request refused. Stop the machine first, with
+	// the power state annotation, for a consistent copy of its disk.
+	CloneAnnotation = "kubevirt.io/clone"
+	// ClonedFromLabel holds the name of the machine a clone was created from
+	ClonedFromLabel = "kubevirt.io/cloned-from"
+
+	// machineSetLabel selects the machines of a machineset, the clones drop it so the machineset doesn't
+	// adopt them
+	machineSetLabel = "machine.openshift.io/cluster-api-machineset"
+)
+
+// cloneIfRequested creates the clone the machine annotation requests. The boot volume of the VM must
+// exist, as it is the source of the boot volume of the clone.
+func (m *manager) cloneIfRequested(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	cloneName, ok := machineScope.machine.Annotations[CloneAnnotation]
+	if !ok {
+		return nil
+	}
+	if errs := validation.IsDNS1123Subdomain(cloneName); len(errs) > 0 || cloneName == machineScope.machine.Name {
+		klog.Errorf("%s: ignoring the clone request, invalid clone name %q", machineScope.getMachineName(), cloneName)
+		delete(machineScope.machine.Annotations, CloneAnnotation)
+		return nil
+	}
+
+	clone, err := buildCloneMachine(machineScope.machine, machineScope.machineProviderSpec, vm, cloneName)
+	if err != nil {
+		return err
+	}
+	if machineScope.machine.Status.NodeRef != nil {
+		klog.Warningf("%s: the node joined the cluster as %s, creating the clone %s stopped", machineScope.getMachineName(),
+			machineScope.machine.Status.NodeRef.Name, cloneName)
+	}
+	if err := machineScope.overkubeClient.CreateMachine(clone); err != nil {
+		if !apimachineryerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create the clone %s: %w", cloneName, err)
+		}
+		klog.Warningf("%s: machine %s already exists, not cloning", machineScope.getMachineName(), cloneName)
+	} else {
+		klog.Infof("%s: created the clone %s", machineScope.getMachineName(), cloneName)
+	}
+	delete(machineScope.machine.Annotations, CloneAnnotation)
+	return nil
+}
+
+// buildCloneMachine returns a machine like the source one, outside of its machineset, whose boot volume
+// is cloned from the boot volume of the source VM. The clone of a source whose node joined is stopped, the
+// disk of the clone carries the hostname and the kubelet credentials of that node.
+func buildCloneMachine(source *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, vm *kubevirtapiv1.VirtualMachine, name string) (*machinev1.Machine, error) {
+	cloneSpec := *providerSpec
+	cloneSpec.SourcePvcName = render.BootVolumeName(vm.Name)
+	cloneSpec.SourcePvcNamespace = vm.Namespace
+	rawProviderSpec, err := kubevirtproviderv1.RawExtensionFromProviderSpec(&cloneSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := map[string]string{}
+	for key, value := range source.Labels {
+		if key != machineSetLabel {
+			labels[key] = value
+		}
+	}
+	labels[ClonedFromLabel] = source.Name
+
+	clone := &machinev1.Machine{
+		ObjectMeta: k8smetav1.ObjectMeta{
+			Name:      name,
+			Namespace: source.Namespace,
+			Labels:    labels,
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	clone.Spec.ProviderID = nil
+	clone.Spec.ProviderSpec.Value = rawProviderSpec
+	if source.Status.NodeRef != nil {
+		clone.Annotations = map[string]string{render.PowerStateAnnotation: render.PowerStateStopped}
+	}
+	return clone, nil
+}
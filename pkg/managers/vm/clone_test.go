@@ -0,0 +1,130 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCloneIfRequested(t *testing.T) {
+	cases := []struct {
+		name           string
+		cloneName      string
+		nodeJoined     bool
+		createErr      error
+		wantCreate     bool
+		wantErr        string
+		wantAnnotation bool
+	}{
+		{
+			name: "No clone requested",
+		},
+		{
+			name:       "Clone created",
+			cloneName:  "machine-debug",
+			wantCreate: true,
+		},
+		{
+			name:       "Clone already exists",
+			cloneName:  "machine-debug",
+			createErr:  apimachineryerrors.NewAlreadyExists(schema.GroupResource{Resource: "machines"}, "machine-debug"),
+			wantCreate: true,
+		},
+		{
+			name:           "Create fails",
+			cloneName:      "machine-debug",
+			createErr:      fmt.Errorf("connection refused"),
+			wantCreate:     true,
+			wantErr:        "failed to create the clone machine-debug: connection refused",
+			wantAnnotation: true,
+		},
+		{
+			name:      "Invalid clone name",
+			cloneName: "Machine_Debug",
+		},
+		{
+			name:      "Clone of itself",
+			cloneName: mahcineName,
+		},
+		{
+			name:       "Node joined",
+			cloneName:  "machine-debug",
+			nodeJoined: true,
+			wantCreate: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(map[string]string{
+				machinev1.MachineClusterIDLabel: clusterID,
+				machineSetLabel:                 "workers",
+			}, "")
+			assert.NilError(t, err)
+			if tc.cloneName != "" {
+				machine.Annotations = map[string]string{CloneAnnotation: tc.cloneName}
+			}
+			if tc.nodeJoined {
+				machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: mahcineName}
+			}
+			machineScope, err := stubMachineScope(machine, mockOverkube, func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			})
+			assert.NilError(t, err)
+			vm := stubVirtualMachine(t, machineScope)
+
+			var clone *machinev1.Machine
+			if tc.wantCreate {
+				mockOverkube.EXPECT().CreateMachine(gomock.Any()).DoAndReturn(func(machine *machinev1.Machine) error {
+					clone = machine
+					return tc.createErr
+				})
+			}
+
+			err = (&manager{}).cloneIfRequested(vm, machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			_, ok := machineScope.machine.Annotations[CloneAnnotation]
+			assert.Equal(t, ok, tc.wantAnnotation)
+
+			if clone != nil {
+				assert.Equal(t, clone.Name, tc.cloneName)
+				assert.Equal(t, clone.Namespace, machine.Namespace)
+				assert.DeepEqual(t, clone.Labels, map[string]string{
+					machinev1.MachineClusterIDLabel: clusterID,
+					ClonedFromLabel:                 mahcineName,
+				})
+				assert.Assert(t, clone.Spec.ProviderID == nil)
+				if tc.nodeJoined {
+					assert.DeepEqual(t, clone.Annotations, map[string]string{render.PowerStateAnnotation: render.PowerStateStopped})
+				} else {
+					assert.Assert(t, clone.Annotations == nil)
+				}
+				providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(clone.Spec.ProviderSpec.Value)
+				assert.NilError(t, err)
+				assert.Equal(t, providerSpec.SourcePvcName, render.BootVolumeName(vm.Name))
+				assert.Equal(t, providerSpec.SourcePvcNamespace, vm.Namespace)
+				assert.Equal(t, providerSpec.IgnitionSecretName, machineScope.machineProviderSpec.IgnitionSecretName)
+			}
+		})
+	}
+}
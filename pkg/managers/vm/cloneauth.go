@@ -0,0 +1,163 @@
+package vm
+
+import (
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	// cloneSourceRoleName is the role, in the namespaces of the source PVCs, allowing to clone their PVCs
+	cloneSourceRoleName = "kubevirt-machine-clone-source"
+	// cloneSourceRoleBindingPrefix names the bindings of the role after the VM namespace they authorize
+	cloneSourceRoleBindingPrefix = "kubevirt-machine-clone-"
+	// cloneServiceAccountName is the service account CDI authorizes the clones of the data volume templates
+	// of a VM for, the VMs of the provider don't set one
+	cloneServiceAccountName = "default"
+)
+
+// authorizeClones grants the VM namespace the permission to clone the source PVCs of the data volumes of the
+// VM living in other namespaces, the create permission on datavolumes/source in the source namespace,
+// which CDI otherwise refuses. Only the source namespaces of the provider config are granted, the clones
+// from the other ones rely on the permission being granted beforehand. The role and its bindings are
+// shared by the VMs of the namespace, revokeClones removes the bindings along with the last of them.
+func (m *manager) authorizeClones(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	for _, dataVolume := range vm.Spec.DataVolumeTemplates {
+		source := dataVolume.Spec.Source.PVC
+		if source == nil || source.Namespace == "" || source.Namespace == vm.Namespace {
+			continue
+		}
+		if !isCloneSourceNamespace(source.Namespace, machineScope) {
+			klog.V(3).Infof("%s: namespace %s is not a clone source namespace of the provider config, the clone of PVC %s/%s relies on the existing permissions",
+				machineScope.getMachineName(), source.Namespace, source.Namespace, source.Name)
+			continue
+		}
+		if err := authorizeCloneSource(source.Namespace, vm.Namespace, machineScope); err != nil {
+			return fmt.Errorf("failed to authorize the clone of PVC %s/%s into namespace %s, the underkube credentials need to grant the create permission on datavolumes/source in %s: %w",
+				source.Namespace, source.Name, vm.Namespace, source.Namespace, err)
+		}
+	}
+	return nil
+}
+
+// revokeClones removes the bindings authorizeClones created for the VM namespace in the source namespaces
+// of the VM, once no other VM of the namespace clones from them
+func (m *manager) revokeClones(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	sourceNamespaces := grantedCloneSourceNamespaces(vm, machineScope)
+	if len(sourceNamespaces) == 0 {
+		return nil
+	}
+	client := machineScope.underkubeClient
+	vms, err := client.ListVirtualMachine(vm.Namespace, &k8smetav1.ListOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to list the VMs of namespace %s: %w", vm.Namespace, err)
+	}
+	inUse := map[string]bool{}
+	for i := range vms.Items {
+		if vms.Items[i].Name == vm.Name {
+			continue
+		}
+		for _, namespace := range grantedCloneSourceNamespaces(&vms.Items[i], machineScope) {
+			inUse[namespace] = true
+		}
+	}
+	for _, namespace := range sourceNamespaces {
+		if inUse[namespace] {
+			continue
+		}
+		klog.Infof("%s: revoking the permission of namespace %s to clone the PVCs of namespace %s", machineScope.getMachineName(), vm.Namespace, namespace)
+		err := client.DeleteRoleBinding(cloneSourceRoleBindingPrefix+vm.Namespace, namespace, &k8smetav1.DeleteOptions{})
+		if apimachineryerrors.IsForbidden(err) {
+			// The deletion of the machine doesn't wait for credentials that can't ever remove the binding
+			klog.Warningf("%s: not allowed to remove the clone permission of namespace %s in %s: %v", machineScope.getMachineName(), vm.Namespace, namespace, err)
+			continue
+		}
+		if err != nil && !apimachineryerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to revoke the permission of namespace %s to clone the PVCs of namespace %s: %w", vm.Namespace, namespace, err)
+		}
+	}
+	return nil
+}
+
+// grantedCloneSourceNamespaces returns the source namespaces of the data volumes of the VM authorizeClones
+// grants the VM namespace
+func grantedCloneSourceNamespaces(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) []string {
+	var namespaces []string
+	for _, dataVolume := range vm.Spec.DataVolumeTemplates {
+		source := dataVolume.Spec.Source.PVC
+		if source != nil && source.Namespace != "" && source.Namespace != vm.Namespace && isCloneSourceNamespace(source.Namespace, machineScope) {
+			namespaces = append(namespaces, source.Namespace)
+		}
+	}
+	return namespaces
+}
+
+// isCloneSourceNamespace returns true when the provider config lets the provider grant the clones from the
+// namespace
+func isCloneSourceNamespace(namespace string, machineScope *machineScope) bool {
+	for _, allowed := range machineScope.providerConfig.CloneSourceNamespaces {
+		if allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeCloneSource creates the role of the source namespace, and its binding to the service account of
+// the VM namespace, when missing
+func authorizeCloneSource(sourceNamespace, vmNamespace string, machineScope *machineScope) error {
+	client := machineScope.underkubeClient
+	_, err := client.GetRole(cloneSourceRoleName, sourceNamespace, k8smetav1.GetOptions{})
+	if apimachineryerrors.IsNotFound(err) {
+		_, err = client.CreateRole(buildCloneSourceRole(), sourceNamespace)
+		if apimachineryerrors.IsAlreadyExists(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	name := cloneSourceRoleBindingPrefix + vmNamespace
+	_, err = client.GetRoleBinding(name, sourceNamespace, k8smetav1.GetOptions{})
+	if !apimachineryerrors.IsNotFound(err) {
+		return err
+	}
+	klog.Infof("%s: allowing namespace %s to clone the PVCs of namespace %s", machineScope.getMachineName(), vmNamespace, sourceNamespace)
+	_, err = client.CreateRoleBinding(buildCloneSourceRoleBinding(name, vmNamespace), sourceNamespace)
+	if apimachineryerrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func buildCloneSourceRole() *rbacv1.Role {
+	return &rbacv1.Role{
+		ObjectMeta: k8smetav1.ObjectMeta{Name: cloneSourceRoleName},
+		Rules: []rbacv1.PolicyRule{{
+			APIGroups: []string{"cdi.kubevirt.io"},
+			Resources: []string{"datavolumes/source"},
+			Verbs:     []string{"create"},
+		}},
+	}
+}
+
+func buildCloneSourceRoleBinding(name, vmNamespace string) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: k8smetav1.ObjectMeta{Name: name},
+		RoleRef: rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "Role",
+			Name:     cloneSourceRoleName,
+		},
+		Subjects: []rbacv1.Subject{{
+			Kind:      rbacv1.ServiceAccountKind,
+			Name:      cloneServiceAccountName,
+			Namespace: vmNamespace,
+		}},
+	}
+}
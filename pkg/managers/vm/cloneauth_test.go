@@ -0,0 +1,188 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"gotest.tools/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func TestAuthorizeClones(t *testing.T) {
+	notFound := apimachineryerrors.NewNotFound(schema.GroupResource{}, "")
+	cases := []struct {
+		name              string
+		sourceNamespace   string
+		notCloneSource    bool
+		roleErr           error
+		roleBindingErr    error
+		createBindingErr  error
+		wantCreateRole    bool
+		wantCreateBinding bool
+		wantErr           string
+	}{
+		{
+			name:            "Source PVC next to the VM",
+			sourceNamespace: "vms",
+		},
+		{
+			name:              "Source namespace not authorized yet",
+			sourceNamespace:   "images",
+			roleErr:           notFound,
+			roleBindingErr:    notFound,
+			wantCreateRole:    true,
+			wantCreateBinding: true,
+		},
+		{
+			name:            "Source namespace not granted by the provider",
+			sourceNamespace: "images",
+			notCloneSource:  true,
+		},
+		{
+			name:            "Source namespace already authorized",
+			sourceNamespace: "images",
+		},
+		{
+			name:              "Credentials not allowed to bind the role",
+			sourceNamespace:   "images",
+			roleBindingErr:    notFound,
+			createBindingErr:  errors.New("forbidden"),
+			wantCreateBinding: true,
+			wantErr:           "failed to authorize the clone of PVC images/rhcos into namespace vms, the underkube credentials need to grant the create permission on datavolumes/source in images: forbidden",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			vm := &kubevirtapiv1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: mahcineName, Namespace: "vms"},
+				Spec: kubevirtapiv1.VirtualMachineSpec{
+					DataVolumeTemplates: []cdiv1.DataVolume{{
+						Spec: cdiv1.DataVolumeSpec{Source: cdiv1.DataVolumeSource{PVC: &cdiv1.DataVolumeSourcePVC{Name: "rhcos", Namespace: tc.sourceNamespace}}},
+					}},
+				},
+			}
+			if !tc.notCloneSource {
+				mockUnderkube.EXPECT().GetRole(cloneSourceRoleName, "images", gomock.Any()).Return(&rbacv1.Role{}, tc.roleErr).AnyTimes()
+				mockUnderkube.EXPECT().GetRoleBinding("kubevirt-machine-clone-vms", "images", gomock.Any()).Return(&rbacv1.RoleBinding{}, tc.roleBindingErr).AnyTimes()
+			}
+			if tc.wantCreateRole {
+				mockUnderkube.EXPECT().CreateRole(buildCloneSourceRole(), "images").Return(&rbacv1.Role{}, nil)
+			}
+			if tc.wantCreateBinding {
+				mockUnderkube.EXPECT().CreateRoleBinding(gomock.Any(), "images").DoAndReturn(func(roleBinding *rbacv1.RoleBinding, namespace string) (*rbacv1.RoleBinding, error) {
+					assert.DeepEqual(t, roleBinding.Subjects, []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: "default", Namespace: "vms"}})
+					assert.Equal(t, roleBinding.RoleRef.Name, cloneSourceRoleName)
+					return roleBinding, tc.createBindingErr
+				})
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope := &machineScope{machine: machine, underkubeClient: mockUnderkube}
+			if !tc.notCloneSource {
+				machineScope.providerConfig.CloneSourceNamespaces = []string{"images"}
+			}
+
+			err = (&manager{}).authorizeClones(vm, machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestRevokeClones(t *testing.T) {
+	cloningVM := func(name, sourceNamespace string) kubevirtapiv1.VirtualMachine {
+		return kubevirtapiv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "vms"},
+			Spec: kubevirtapiv1.VirtualMachineSpec{
+				DataVolumeTemplates: []cdiv1.DataVolume{{
+					Spec: cdiv1.DataVolumeSpec{Source: cdiv1.DataVolumeSource{PVC: &cdiv1.DataVolumeSourcePVC{Name: "rhcos", Namespace: sourceNamespace}}},
+				}},
+			},
+		}
+	}
+	cases := []struct {
+		name            string
+		sourceNamespace string
+		otherVMs        []kubevirtapiv1.VirtualMachine
+		deleteErr       error
+		wantDelete      bool
+		wantErr         string
+	}{
+		{
+			name:            "Source namespace not granted by the provider",
+			sourceNamespace: "shared-images",
+		},
+		{
+			name:            "Last VM cloning from the source namespace",
+			sourceNamespace: "images",
+			otherVMs:        []kubevirtapiv1.VirtualMachine{cloningVM("other", "shared-images")},
+			wantDelete:      true,
+		},
+		{
+			name:            "Source namespace still cloned from",
+			sourceNamespace: "images",
+			otherVMs:        []kubevirtapiv1.VirtualMachine{cloningVM("other", "images")},
+		},
+		{
+			name:            "Binding already removed",
+			sourceNamespace: "images",
+			deleteErr:       apimachineryerrors.NewNotFound(schema.GroupResource{}, ""),
+			wantDelete:      true,
+		},
+		{
+			name:            "Credentials not allowed to remove the binding",
+			sourceNamespace: "images",
+			deleteErr:       apimachineryerrors.NewForbidden(schema.GroupResource{}, "", errors.New("forbidden")),
+			wantDelete:      true,
+		},
+		{
+			name:            "Removal failure",
+			sourceNamespace: "images",
+			deleteErr:       errors.New("connection refused"),
+			wantDelete:      true,
+			wantErr:         "failed to revoke the permission of namespace vms to clone the PVCs of namespace images: connection refused",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			vm := cloningVM(mahcineName, tc.sourceNamespace)
+			if tc.sourceNamespace == "images" {
+				mockUnderkube.EXPECT().ListVirtualMachine("vms", gomock.Any()).Return(&kubevirtapiv1.VirtualMachineList{Items: tc.otherVMs}, nil)
+			}
+			if tc.wantDelete {
+				mockUnderkube.EXPECT().DeleteRoleBinding("kubevirt-machine-clone-vms", "images", gomock.Any()).Return(tc.deleteErr)
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope := &machineScope{machine: machine, underkubeClient: mockUnderkube}
+			machineScope.providerConfig.CloneSourceNamespaces = []string{"images"}
+
+			err = (&manager{}).revokeClones(&vm, machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
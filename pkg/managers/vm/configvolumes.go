@@ -0,0 +1,31 @@
+package vm
+
+import (
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateConfigVolumes validates the names of the config volumes, which name their disks, and that each
+// references a ConfigMap or a Secret
+func validateConfigVolumes(machineName string, configVolumes []kubevirtproviderv1.ConfigVolume) error {
+	seen := map[string]bool{}
+	for _, configVolume := range configVolumes {
+		if configVolume.Name == "" {
+			return machinecontroller.InvalidMachineConfiguration("%v: missing config volume name", machineName)
+		}
+		if errs := validation.IsDNS1123Label(configVolume.Name); len(errs) > 0 {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid config volume name %q: %s", machineName, configVolume.Name, strings.Join(errs, ", "))
+		}
+		if seen[configVolume.Name] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate config volume %s", machineName, configVolume.Name)
+		}
+		seen[configVolume.Name] = true
+		if (configVolume.ConfigMapName == "") == (configVolume.SecretName == "") {
+			return machinecontroller.InvalidMachineConfiguration("%v: config volume %s: expected one of configMapName and secretName", machineName, configVolume.Name)
+		}
+	}
+	return nil
+}
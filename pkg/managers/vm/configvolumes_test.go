@@ -0,0 +1,65 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateConfigVolumes(t *testing.T) {
+	cases := []struct {
+		name          string
+		configVolumes []kubevirtproviderv1.ConfigVolume
+		wantErr       string
+	}{
+		{
+			name: "No config volumes",
+		},
+		{
+			name: "ConfigMap and Secret disks",
+			configVolumes: []kubevirtproviderv1.ConfigVolume{
+				{Name: "agent", ConfigMapName: "agent-config", VolumeLabel: "AGENT"},
+				{Name: "certs", SecretName: "infra-certs"},
+			},
+		},
+		{
+			name:          "Missing name",
+			configVolumes: []kubevirtproviderv1.ConfigVolume{{ConfigMapName: "agent-config"}},
+			wantErr:       "machine-test: missing config volume name",
+		},
+		{
+			name:          "Invalid name",
+			configVolumes: []kubevirtproviderv1.ConfigVolume{{Name: "Agent", ConfigMapName: "agent-config"}},
+			wantErr:       `machine-test: invalid config volume name "Agent": a DNS-1123 label must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character (e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`,
+		},
+		{
+			name: "Duplicate name",
+			configVolumes: []kubevirtproviderv1.ConfigVolume{
+				{Name: "agent", ConfigMapName: "agent-config"},
+				{Name: "agent", SecretName: "infra-certs"},
+			},
+			wantErr: "machine-test: duplicate config volume agent",
+		},
+		{
+			name:          "No source",
+			configVolumes: []kubevirtproviderv1.ConfigVolume{{Name: "agent"}},
+			wantErr:       "machine-test: config volume agent: expected one of configMapName and secretName",
+		},
+		{
+			name:          "Both sources",
+			configVolumes: []kubevirtproviderv1.ConfigVolume{{Name: "agent", ConfigMapName: "agent-config", SecretName: "infra-certs"}},
+			wantErr:       "machine-test: config volume agent: expected one of configMapName and secretName",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateConfigVolumes("machine-test", tc.configVolumes)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// cpuFeaturePolicies are the policies libvirt knows for a CPU feature
+var cpuFeaturePolicies = sets.NewString("force", "require", "optional", "disable", "forbid")
+
+// validateCPU validates the CPU features and topology, and the settings depending on the dedicated CPU
+// placement
+func validateCPU(machineName string, cpu *kubevirtproviderv1.CPU, requestedCPU string) error {
+	if cpu == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, feature := range cpu.Features {
+		if feature.Name == "" {
+			return machinecontroller.InvalidMachineConfiguration("%v: missing CPU feature name", machineName)
+		}
+		if names[feature.Name] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate CPU feature %q", machineName, feature.Name)
+		}
+		names[feature.Name] = true
+		if feature.Policy != "" && !cpuFeaturePolicies.Has(feature.Policy) {
+			return machinecontroller.InvalidMachineConfiguration("%v: CPU feature %s: invalid policy %q, expected one of %v", machineName, feature.Name, feature.Policy, cpuFeaturePolicies.List())
+		}
+	}
+
+	if vcpus := topologyVCPUs(cpu); vcpus > 0 && cpu.DedicatedCPUPlacement && requestedCPU != "" {
+		// The dedicated CPUs are allocated from the CPU request, KubeVirt refuses a topology that doesn't
+		// match it
+		if quantity, err := apiresource.ParseQuantity(requestedCPU); err == nil && quantity.MilliValue() != int64(vcpus)*1000 {
+			return machinecontroller.InvalidMachineConfiguration("%v: the CPU topology has %d vCPUs, the dedicated CPU placement needs as many requested CPUs, not %s", machineName, vcpus, requestedCPU)
+		}
+	}
+
+	if cpu.IsolateEmulatorThread && !cpu.DedicatedCPUPlacement {
+		return machinecontroller.InvalidMachineConfiguration("%v: isolateEmulatorThread needs dedicatedCpuPlacement", machineName)
+	}
+	return nil
+}
+
+// topologyVCPUs returns the vCPUs of the CPU topology, 0 when no topology is set
+func topologyVCPUs(cpu *kubevirtproviderv1.CPU) uint64 {
+	if cpu.Sockets == 0 && cpu.Cores == 0 && cpu.Threads == 0 {
+		return 0
+	}
+	vcpus := uint64(1)
+	for _, count := range []uint32{cpu.Sockets, cpu.Cores, cpu.Threads} {
+		if count > 0 {
+			vcpus *= uint64(count)
+		}
+	}
+	return vcpus
+}
@@ -0,0 +1,86 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateCPU(t *testing.T) {
+	cases := []struct {
+		name         string
+		cpu          *kubevirtproviderv1.CPU
+		requestedCPU string
+		wantErr      string
+	}{
+		{
+			name: "No CPU",
+		},
+		{
+			name: "Host passthrough",
+			cpu:  &kubevirtproviderv1.CPU{Model: "host-passthrough"},
+		},
+		{
+			name: "Model and features",
+			cpu: &kubevirtproviderv1.CPU{
+				Model:    "Skylake-Server",
+				Features: []kubevirtproviderv1.CPUFeature{{Name: "vmx"}, {Name: "pcid", Policy: "disable"}},
+			},
+		},
+		{
+			name:    "Missing feature name",
+			cpu:     &kubevirtproviderv1.CPU{Features: []kubevirtproviderv1.CPUFeature{{Policy: "require"}}},
+			wantErr: "machine-test: missing CPU feature name",
+		},
+		{
+			name:    "Duplicate feature",
+			cpu:     &kubevirtproviderv1.CPU{Features: []kubevirtproviderv1.CPUFeature{{Name: "vmx"}, {Name: "vmx", Policy: "disable"}}},
+			wantErr: `machine-test: duplicate CPU feature "vmx"`,
+		},
+		{
+			name:    "Invalid policy",
+			cpu:     &kubevirtproviderv1.CPU{Features: []kubevirtproviderv1.CPUFeature{{Name: "vmx", Policy: "enable"}}},
+			wantErr: `machine-test: CPU feature vmx: invalid policy "enable", expected one of [disable forbid force optional require]`,
+		},
+		{
+			name:         "Topology",
+			cpu:          &kubevirtproviderv1.CPU{Sockets: 2, Cores: 4, Threads: 2},
+			requestedCPU: "4",
+		},
+		{
+			name: "Sockets only",
+			cpu:  &kubevirtproviderv1.CPU{Sockets: 4},
+		},
+		{
+			name:         "Dedicated CPU placement with a matching topology",
+			cpu:          &kubevirtproviderv1.CPU{Sockets: 2, Cores: 2, DedicatedCPUPlacement: true},
+			requestedCPU: "4",
+		},
+		{
+			name:         "Dedicated CPU placement with a mismatching topology",
+			cpu:          &kubevirtproviderv1.CPU{Sockets: 2, Cores: 2, DedicatedCPUPlacement: true},
+			requestedCPU: "2",
+			wantErr:      "machine-test: the CPU topology has 4 vCPUs, the dedicated CPU placement needs as many requested CPUs, not 2",
+		},
+		{
+			name: "Dedicated CPU placement",
+			cpu:  &kubevirtproviderv1.CPU{DedicatedCPUPlacement: true, IsolateEmulatorThread: true},
+		},
+		{
+			name:    "Emulator thread isolation without dedicated CPU placement",
+			cpu:     &kubevirtproviderv1.CPU{IsolateEmulatorThread: true},
+			wantErr: "machine-test: isolateEmulatorThread needs dedicatedCpuPlacement",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateCPU("machine-test", tc.cpu, tc.requestedCPU)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"fmt"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	defaultImportDeadline   = 30 * time.Minute
+	defaultStartDeadline    = 10 * time.Minute
+	defaultNodeJoinDeadline = 20 * time.Minute
+	defaultDeletionDeadline = 10 * time.Minute
+
+	// defaultDeletionGracePeriod is the grace period of the VM deletion, until the deletion deadline elapses,
+	// when the provider spec sets no termination grace period
+	defaultDeletionGracePeriod = int64(10)
+
+	// machinePhaseFailed is the phase after which the machine controller stops reconciling the machine
+	machinePhaseFailed = "Failed"
+)
+
+// deadlines are the deadlines of the provider spec, with the defaults applied
+type deadlines struct {
+	importDeadline   time.Duration
+	startDeadline    time.Duration
+	nodeJoinDeadline time.Duration
+	deletionDeadline time.Duration
+}
+
+func getDeadlines(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) deadlines {
+	d := deadlines{
+		importDeadline:   defaultImportDeadline,
+		startDeadline:    defaultStartDeadline,
+		nodeJoinDeadline: defaultNodeJoinDeadline,
+		deletionDeadline: defaultDeletionDeadline,
+	}
+	if providerSpec.Deadlines == nil {
+		return d
+	}
+	if providerSpec.Deadlines.Import != nil {
+		d.importDeadline = providerSpec.Deadlines.Import.Duration
+	}
+	if providerSpec.Deadlines.Start != nil {
+		d.startDeadline = providerSpec.Deadlines.Start.Duration
+	}
+	if providerSpec.Deadlines.NodeJoin != nil {
+		d.nodeJoinDeadline = providerSpec.Deadlines.NodeJoin.Duration
+	}
+	if providerSpec.Deadlines.Deletion != nil {
+		d.deletionDeadline = providerSpec.Deadlines.Deletion.Duration
+	}
+	return d
+}
+
+func validateDeadlines(machineName string, deadlines *kubevirtproviderv1.Deadlines) error {
+	if deadlines == nil {
+		return nil
+	}
+	for name, deadline := range map[string]*metav1.Duration{
+		"import":   deadlines.Import,
+		"start":    deadlines.Start,
+		"nodeJoin": deadlines.NodeJoin,
+		"deletion": deadlines.Deletion,
+	} {
+		if deadline != nil && deadline.Duration <= 0 {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid %s deadline %v, it must be positive", machineName, name, deadline.Duration)
+		}
+	}
+	return nil
+}
+
+// validateTerminationGracePeriod checks the guest is given its termination grace period before the deletion
+// deadline forces the deletion of the VM
+func validateTerminationGracePeriod(machineName string, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) error {
+	gracePeriod := providerSpec.TerminationGracePeriodSeconds
+	if gracePeriod == nil {
+		return nil
+	}
+	if *gracePeriod < 0 {
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid termination grace period %d, it can't be negative", machineName, *gracePeriod)
+	}
+	if deadline := getDeadlines(providerSpec).deletionDeadline; time.Duration(*gracePeriod)*time.Second >= deadline {
+		return machinecontroller.InvalidMachineConfiguration("%v: the termination grace period of %ds must be shorter than the deletion deadline %v", machineName, *gracePeriod, deadline)
+	}
+	return nil
+}
+
+// deletionGracePeriod returns the grace period of the VM deletion
+func deletionGracePeriod(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) int64 {
+	if providerSpec.TerminationGracePeriodSeconds != nil {
+		return *providerSpec.TerminationGracePeriodSeconds
+	}
+	return defaultDeletionGracePeriod
+}
+
+// elapsedDeadline returns the failure reason and message of the first start deadline the machine missed,
+// empty when the machine is on time. The start deadline only applies until the VM was ready once, and the
+// node join deadline until the node joined.
+func elapsedDeadline(d deadlines, vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance, machine *machinev1.Machine, bootCompleted bool, now time.Time) (kubevirtproviderv1.FailureReason, string) {
+	if vmi == nil || isVMStopped(vm) {
+		// Without a VMI, only the import deadline applies
+		return "", ""
+	}
+	if isVMIPaused(vmi) {
+		// The guest was frozen on purpose, it can't progress until it is unpaused
+		return "", ""
+	}
+	if vmi.CreationTimestamp.IsZero() || machine.Status.NodeRef != nil {
+		return "", ""
+	}
+	sinceStart := now.Sub(vmi.CreationTimestamp.Time)
+	if !vm.Status.Ready && !bootCompleted && sinceStart > d.startDeadline {
+		return kubevirtproviderv1.BootTimeoutFailure, fmt.Sprintf("the VM is not ready %v after it started", d.startDeadline)
+	}
+	if sinceStart > d.nodeJoinDeadline {
+		return kubevirtproviderv1.NodeJoinTimeoutFailure, fmt.Sprintf("the node did not join %v after the VM started", d.nodeJoinDeadline)
+	}
+	return "", ""
+}
+
+// importDeadlineElapsed returns the failure reason and message when the boot volume of a VM without VMI
+// isn't imported within the import deadline, empty once the boot volume is ready
+func importDeadlineElapsed(d deadlines, vm *kubevirtapiv1.VirtualMachine, dataVolume *cdiv1.DataVolume, now time.Time) (kubevirtproviderv1.FailureReason, string) {
+	if isVMStopped(vm) || dataVolume.Status.Phase == cdiv1.Succeeded || vm.CreationTimestamp.IsZero() {
+		return "", ""
+	}
+	if now.Sub(vm.CreationTimestamp.Time) > d.importDeadline {
+		return kubevirtproviderv1.ImportTimeoutFailure, fmt.Sprintf("the boot volume is not imported %v after the VM creation", d.importDeadline)
+	}
+	return "", ""
+}
+
+// deletionDeadlineElapsed returns true when the machine has been deleted for longer than the deadline
+func deletionDeadlineElapsed(deadline time.Duration, machine *machinev1.Machine, now time.Time) bool {
+	return machine.DeletionTimestamp != nil && now.Sub(machine.DeletionTimestamp.Time) > deadline
+}
@@ -0,0 +1,173 @@
+package vm
+
+import (
+	"testing"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func TestElapsedDeadline(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	createdAgo := func(d time.Duration) metav1.ObjectMeta {
+		return metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-d))}
+	}
+	runHalted := kubevirtapiv1.RunStrategyHalted
+	nodeJoined := &machinev1.Machine{Status: machinev1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: mahcineName}}}
+
+	cases := []struct {
+		name          string
+		providerSpec  kubevirtproviderv1.KubevirtMachineProviderSpec
+		vm            *kubevirtapiv1.VirtualMachine
+		vmi           *kubevirtapiv1.VirtualMachineInstance
+		machine       *machinev1.Machine
+		bootCompleted bool
+		wantReason    kubevirtproviderv1.FailureReason
+		wantMessage   string
+	}{
+		{
+			name:    "No VMI",
+			vm:      &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour)},
+			machine: &machinev1.Machine{},
+		},
+		{
+			name:    "Stopped VM",
+			vm:      &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour), Spec: kubevirtapiv1.VirtualMachineSpec{RunStrategy: &runHalted}},
+			vmi:     &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: createdAgo(time.Hour)},
+			machine: &machinev1.Machine{},
+		},
+		{
+			name:         "Start deadline from the provider spec",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{Deadlines: &kubevirtproviderv1.Deadlines{Start: &metav1.Duration{Duration: time.Minute}}},
+			vm:           &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour)},
+			vmi:          &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: createdAgo(2 * time.Minute)},
+			machine:      &machinev1.Machine{},
+			wantReason:   kubevirtproviderv1.BootTimeoutFailure,
+			wantMessage:  "the VM is not ready 1m0s after it started",
+		},
+		{
+			name:          "Not ready after the first boot",
+			providerSpec:  kubevirtproviderv1.KubevirtMachineProviderSpec{Deadlines: &kubevirtproviderv1.Deadlines{Start: &metav1.Duration{Duration: time.Minute}}},
+			vm:            &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour)},
+			vmi:           &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: createdAgo(2 * time.Minute)},
+			machine:       &machinev1.Machine{},
+			bootCompleted: true,
+		},
+		{
+			name:    "Joined machine not ready",
+			vm:      &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(48 * time.Hour)},
+			vmi:     &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: createdAgo(time.Hour)},
+			machine: nodeJoined,
+		},
+		{
+			name:        "Node join deadline",
+			vm:          &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour), Status: kubevirtapiv1.VirtualMachineStatus{Ready: true}},
+			vmi:         &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: createdAgo(30 * time.Minute)},
+			machine:     &machinev1.Machine{},
+			wantReason:  kubevirtproviderv1.NodeJoinTimeoutFailure,
+			wantMessage: "the node did not join 20m0s after the VM started",
+		},
+		{
+			name:    "Running machine",
+			vm:      &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(48 * time.Hour), Status: kubevirtapiv1.VirtualMachineStatus{Ready: true}},
+			vmi:     &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: createdAgo(48 * time.Hour)},
+			machine: nodeJoined,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, message := elapsedDeadline(getDeadlines(&tc.providerSpec), tc.vm, tc.vmi, tc.machine, tc.bootCompleted, now)
+			assert.Equal(t, reason, tc.wantReason)
+			assert.Equal(t, message, tc.wantMessage)
+		})
+	}
+}
+
+func TestImportDeadlineElapsed(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	createdAgo := func(d time.Duration) metav1.ObjectMeta {
+		return metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(-d))}
+	}
+	runHalted := kubevirtapiv1.RunStrategyHalted
+	importing := &cdiv1.DataVolume{Status: cdiv1.DataVolumeStatus{Phase: cdiv1.ImportInProgress}}
+
+	cases := []struct {
+		name        string
+		vm          *kubevirtapiv1.VirtualMachine
+		dataVolume  *cdiv1.DataVolume
+		wantReason  kubevirtproviderv1.FailureReason
+		wantMessage string
+	}{
+		{
+			name:       "Importing on time",
+			vm:         &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(10 * time.Minute)},
+			dataVolume: importing,
+		},
+		{
+			name:        "Import deadline",
+			vm:          &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour)},
+			dataVolume:  importing,
+			wantReason:  kubevirtproviderv1.ImportTimeoutFailure,
+			wantMessage: "the boot volume is not imported 30m0s after the VM creation",
+		},
+		{
+			name:       "Imported boot volume",
+			vm:         &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour)},
+			dataVolume: &cdiv1.DataVolume{Status: cdiv1.DataVolumeStatus{Phase: cdiv1.Succeeded}},
+		},
+		{
+			name:       "Stopped VM",
+			vm:         &kubevirtapiv1.VirtualMachine{ObjectMeta: createdAgo(time.Hour), Spec: kubevirtapiv1.VirtualMachineSpec{RunStrategy: &runHalted}},
+			dataVolume: importing,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, message := importDeadlineElapsed(getDeadlines(&kubevirtproviderv1.KubevirtMachineProviderSpec{}), tc.vm, tc.dataVolume, now)
+			assert.Equal(t, reason, tc.wantReason)
+			assert.Equal(t, message, tc.wantMessage)
+		})
+	}
+}
+
+func TestDeletionDeadlineElapsed(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	machine := &machinev1.Machine{}
+	assert.Assert(t, !deletionDeadlineElapsed(defaultDeletionDeadline, machine, now))
+
+	deletedAt := metav1.NewTime(now.Add(-5 * time.Minute))
+	machine.DeletionTimestamp = &deletedAt
+	assert.Assert(t, !deletionDeadlineElapsed(defaultDeletionDeadline, machine, now))
+	assert.Assert(t, deletionDeadlineElapsed(time.Minute, machine, now))
+}
+
+func TestValidateDeadlines(t *testing.T) {
+	assert.NilError(t, validateDeadlines("machine-test", nil))
+	assert.NilError(t, validateDeadlines("machine-test", &kubevirtproviderv1.Deadlines{Import: &metav1.Duration{Duration: time.Hour}}))
+	assert.Error(t, validateDeadlines("machine-test", &kubevirtproviderv1.Deadlines{Deletion: &metav1.Duration{}}),
+		"machine-test: invalid deletion deadline 0s, it must be positive")
+}
+
+func TestValidateTerminationGracePeriod(t *testing.T) {
+	gracePeriod := func(seconds int64) *int64 { return &seconds }
+	assert.NilError(t, validateTerminationGracePeriod("machine-test", &kubevirtproviderv1.KubevirtMachineProviderSpec{}))
+	assert.NilError(t, validateTerminationGracePeriod("machine-test", &kubevirtproviderv1.KubevirtMachineProviderSpec{TerminationGracePeriodSeconds: gracePeriod(300)}))
+	assert.Error(t, validateTerminationGracePeriod("machine-test", &kubevirtproviderv1.KubevirtMachineProviderSpec{TerminationGracePeriodSeconds: gracePeriod(-1)}),
+		"machine-test: invalid termination grace period -1, it can't be negative")
+	assert.Error(t, validateTerminationGracePeriod("machine-test", &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		TerminationGracePeriodSeconds: gracePeriod(300),
+		Deadlines:                     &kubevirtproviderv1.Deadlines{Deletion: &metav1.Duration{Duration: 5 * time.Minute}},
+	}), "machine-test: the termination grace period of 300s must be shorter than the deletion deadline 5m0s")
+}
+
+func TestDeletionGracePeriod(t *testing.T) {
+	assert.Equal(t, deletionGracePeriod(&kubevirtproviderv1.KubevirtMachineProviderSpec{}), defaultDeletionGracePeriod)
+	gracePeriod := int64(120)
+	assert.Equal(t, deletionGracePeriod(&kubevirtproviderv1.KubevirtMachineProviderSpec{TerminationGracePeriodSeconds: &gracePeriod}), gracePeriod)
+}
@@ -0,0 +1,72 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// checkDeletionProtection keeps the VM from being deleted while the machine or the VM is annotated protected,
+// e.g. a pet control plane machine a scale down picked by mistake. A VM already being deleted isn't protected
+// anymore.
+func (m *manager) checkDeletionProtection(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	if vm.DeletionTimestamp != nil {
+		return nil
+	}
+	var protected []string
+	if machineScope.machine.Annotations[render.ProtectAnnotation] == "true" {
+		protected = append(protected, "machine "+machineScope.machine.GetName())
+	}
+	if vm.Annotations[render.ProtectAnnotation] == "true" {
+		protected = append(protected, "VM "+vm.Namespace+"/"+vm.Name)
+	}
+	if len(protected) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("%s annotated %s=true", strings.Join(protected, " and "), render.ProtectAnnotation)
+	machineScope.setCondition(conditions.New(kubevirtproviderv1.DeleteBlockedCondition, corev1.ConditionTrue, "DeletionProtected", message))
+	klog.Warningf("%s: not deleting the VM: %s", machineScope.getMachineName(), message)
+	return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+}
+
+// checkDeletionSlot keeps the VM from being deleted while the maximum number of VMs of its tenant cluster
+// are being deleted, so a large scale down doesn't shut all of them down at once. The VMs are deleted in the
+// foreground, a VM is being deleted until its VMI and its disks are gone.
+func (m *manager) checkDeletionSlot(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	maxDeletions := 0
+	if reconcile := machineScope.providerConfig.Reconcile; reconcile != nil {
+		maxDeletions = reconcile.MaxConcurrentDeletions
+	}
+	if maxDeletions == 0 || vm.DeletionTimestamp != nil {
+		return nil
+	}
+
+	clusterID, _ := render.ClusterID(machineScope.machine)
+	vms, err := machineScope.underkubeClient.ListVirtualMachine(vm.Namespace, &k8smetav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + "=" + clusterID})
+	if err != nil {
+		return fmt.Errorf("failed to list the VMs of cluster %s: %w", clusterID, err)
+	}
+	deleting := 0
+	for i := range vms.Items {
+		if vms.Items[i].DeletionTimestamp != nil && vms.Items[i].Name != vm.Name {
+			deleting++
+		}
+	}
+	if deleting >= maxDeletions {
+		message := fmt.Sprintf("%d VMs of cluster %s are being deleted, at most %d are deleted at once", deleting, clusterID, maxDeletions)
+		machineScope.setCondition(conditions.New(kubevirtproviderv1.DeleteBlockedCondition, corev1.ConditionTrue, "DeletionThrottled", message))
+		klog.Infof("%s: not deleting the VM yet: %s", machineScope.getMachineName(), message)
+		return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestCheckDeletionSlot(t *testing.T) {
+	now := metav1.Now()
+	stubClusterVM := func(name string, deleting bool) kubevirtapiv1.VirtualMachine {
+		vm := kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if deleting {
+			vm.DeletionTimestamp = &now
+		}
+		return vm
+	}
+
+	cases := []struct {
+		name          string
+		maxDeletions  int
+		vmDeleting    bool
+		clusterVMs    []kubevirtapiv1.VirtualMachine
+		wantThrottled bool
+	}{
+		{
+			name: "Unlimited",
+		},
+		{
+			name:         "Free slot",
+			maxDeletions: 2,
+			clusterVMs:   []kubevirtapiv1.VirtualMachine{stubClusterVM("worker-1", true), stubClusterVM("worker-2", false)},
+		},
+		{
+			name:          "All slots taken",
+			maxDeletions:  2,
+			clusterVMs:    []kubevirtapiv1.VirtualMachine{stubClusterVM("worker-1", true), stubClusterVM("worker-2", true)},
+			wantThrottled: true,
+		},
+		{
+			name:         "Already being deleted",
+			maxDeletions: 1,
+			vmDeleting:   true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope, err := stubMachineScope(machine, mockOverkube, func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			})
+			assert.NilError(t, err)
+			machineScope.providerConfig.Reconcile = &kubevirtproviderv1.ReconcileTuning{MaxConcurrentDeletions: tc.maxDeletions}
+			vm := stubVirtualMachine(t, machineScope)
+			if tc.vmDeleting {
+				vm.DeletionTimestamp = &now
+			}
+			if tc.maxDeletions > 0 && !tc.vmDeleting {
+				mockUnderkube.EXPECT().ListVirtualMachine(vm.Namespace, &metav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + "=" + clusterID}).
+					Return(&kubevirtapiv1.VirtualMachineList{Items: append(tc.clusterVMs, *vm)}, nil)
+			}
+
+			err = (&manager{}).checkDeletionSlot(vm, machineScope)
+			condition := conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.DeleteBlockedCondition)
+			if tc.wantThrottled {
+				_, ok := err.(*machinecontroller.RequeueAfterError)
+				assert.Assert(t, ok, "unexpected error %v", err)
+				assert.Assert(t, condition != nil)
+				assert.Equal(t, condition.Status, corev1.ConditionTrue)
+				assert.Equal(t, condition.Reason, "DeletionThrottled")
+				assert.Equal(t, condition.Message, "2 VMs of cluster "+clusterID+" are being deleted, at most 2 are deleted at once")
+			} else {
+				assert.NilError(t, err)
+				assert.Assert(t, condition == nil)
+			}
+		})
+	}
+}
+
+func TestCheckDeletionProtection(t *testing.T) {
+	now := metav1.Now()
+	cases := []struct {
+		name             string
+		machineProtected string
+		vmProtected      string
+		vmDeleting       bool
+		wantMessage      string
+	}{
+		{
+			name: "Not protected",
+		},
+		{
+			name:             "Protection disabled",
+			machineProtected: "false",
+		},
+		{
+			name:             "Machine protected",
+			machineProtected: "true",
+			wantMessage:      "machine " + mahcineName + " annotated kubevirt.io/protect=true",
+		},
+		{
+			name:             "Machine and VM protected",
+			machineProtected: "true",
+			vmProtected:      "true",
+			wantMessage:      "machine " + mahcineName + " and VM " + clusterID + "/" + mahcineName + " annotated kubevirt.io/protect=true",
+		},
+		{
+			name:        "VM already being deleted",
+			vmProtected: "true",
+			vmDeleting:  true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			if tc.machineProtected != "" {
+				machine.Annotations = map[string]string{render.ProtectAnnotation: tc.machineProtected}
+			}
+			machineScope, err := stubMachineScope(machine, mockOverkube, func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			})
+			assert.NilError(t, err)
+			vm := stubVirtualMachine(t, machineScope)
+			vm.Annotations = map[string]string{}
+			if tc.vmProtected != "" {
+				vm.Annotations[render.ProtectAnnotation] = tc.vmProtected
+			}
+			if tc.vmDeleting {
+				vm.DeletionTimestamp = &now
+			}
+
+			err = (&manager{}).checkDeletionProtection(vm, machineScope)
+			condition := conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.DeleteBlockedCondition)
+			if tc.wantMessage != "" {
+				_, ok := err.(*machinecontroller.RequeueAfterError)
+				assert.Assert(t, ok, "unexpected error %v", err)
+				assert.Assert(t, condition != nil)
+				assert.Equal(t, condition.Status, corev1.ConditionTrue)
+				assert.Equal(t, condition.Reason, "DeletionProtected")
+				assert.Equal(t, condition.Message, tc.wantMessage)
+			} else {
+				assert.NilError(t, err)
+				assert.Assert(t, condition == nil)
+			}
+		})
+	}
+}
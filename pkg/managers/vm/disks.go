@@ -0,0 +1,81 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// validateDiskBuses validates the buses of the disks, set once per disk. KubeVirt only gives a dedicated IO
+// thread to the virtio disks.
+func validateDiskBuses(machineName string, buses []kubevirtproviderv1.DiskBus, dedicatedIOThreadDisks []kubevirtproviderv1.DiskName) error {
+	dedicated := map[kubevirtproviderv1.DiskName]bool{}
+	for _, disk := range dedicatedIOThreadDisks {
+		dedicated[disk] = true
+	}
+	seen := map[kubevirtproviderv1.DiskName]bool{}
+	for _, bus := range buses {
+		if bus.Disk != kubevirtproviderv1.BootDisk && bus.Disk != kubevirtproviderv1.CloudInitDisk {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid disk %q, expected %s or %s", machineName, bus.Disk, kubevirtproviderv1.BootDisk, kubevirtproviderv1.CloudInitDisk)
+		}
+		if seen[bus.Disk] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate bus of disk %s", machineName, bus.Disk)
+		}
+		seen[bus.Disk] = true
+		switch bus.Bus {
+		case kubevirtproviderv1.VirtioBus:
+		case kubevirtproviderv1.SATABus, kubevirtproviderv1.SCSIBus:
+			if dedicated[bus.Disk] {
+				return machinecontroller.InvalidMachineConfiguration("%v: disk %s: the dedicated IO thread needs the %s bus, not %s", machineName, bus.Disk, kubevirtproviderv1.VirtioBus, bus.Bus)
+			}
+		default:
+			return machinecontroller.InvalidMachineConfiguration("%v: disk %s: invalid bus %q, expected %s, %s or %s", machineName, bus.Disk, bus.Bus, kubevirtproviderv1.VirtioBus, kubevirtproviderv1.SATABus, kubevirtproviderv1.SCSIBus)
+		}
+	}
+	return nil
+}
+
+// validateBootOrders validates the boot orders of the disks and interfaces: positive, and not shared by two
+// devices. Only the boot disk can have one, the config drive isn't bootable, and it must have one as soon as
+// another device has, otherwise the VM would never boot from it.
+func validateBootOrders(machineName string, diskBootOrders []kubevirtproviderv1.DiskBootOrder, interfaces []kubevirtproviderv1.NetworkInterface) error {
+	devices := map[uint]string{}
+	setBootOrder := func(device string, bootOrder uint) error {
+		if bootOrder == 0 {
+			return machinecontroller.InvalidMachineConfiguration("%v: %s: the boot order must be positive", machineName, device)
+		}
+		if other, ok := devices[bootOrder]; ok {
+			return machinecontroller.InvalidMachineConfiguration("%v: %s and %s have the same boot order %d", machineName, other, device, bootOrder)
+		}
+		devices[bootOrder] = device
+		return nil
+	}
+
+	seen := map[kubevirtproviderv1.DiskName]bool{}
+	for _, diskBootOrder := range diskBootOrders {
+		if diskBootOrder.Disk == kubevirtproviderv1.CloudInitDisk {
+			return machinecontroller.InvalidMachineConfiguration("%v: disk %s can't have a boot order, it isn't bootable", machineName, diskBootOrder.Disk)
+		}
+		if diskBootOrder.Disk != kubevirtproviderv1.BootDisk {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid disk %q, expected %s", machineName, diskBootOrder.Disk, kubevirtproviderv1.BootDisk)
+		}
+		if seen[diskBootOrder.Disk] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate boot order of disk %s", machineName, diskBootOrder.Disk)
+		}
+		seen[diskBootOrder.Disk] = true
+		if err := setBootOrder("disk "+string(diskBootOrder.Disk), diskBootOrder.BootOrder); err != nil {
+			return err
+		}
+	}
+	for _, iface := range interfaces {
+		if iface.BootOrder == nil {
+			continue
+		}
+		if err := setBootOrder("interface "+iface.Name, *iface.BootOrder); err != nil {
+			return err
+		}
+	}
+	if len(devices) > 0 && !seen[kubevirtproviderv1.BootDisk] {
+		return machinecontroller.InvalidMachineConfiguration("%v: missing boot order of disk %s, the VM doesn't boot from it once another device has a boot order", machineName, kubevirtproviderv1.BootDisk)
+	}
+	return nil
+}
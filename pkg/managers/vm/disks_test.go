@@ -0,0 +1,132 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateDiskBuses(t *testing.T) {
+	cases := []struct {
+		name      string
+		buses     []kubevirtproviderv1.DiskBus
+		dedicated []kubevirtproviderv1.DiskName
+		wantErr   string
+	}{
+		{
+			name: "Default buses",
+		},
+		{
+			name: "Legacy image",
+			buses: []kubevirtproviderv1.DiskBus{
+				{Disk: kubevirtproviderv1.BootDisk, Bus: kubevirtproviderv1.SATABus},
+				{Disk: kubevirtproviderv1.CloudInitDisk, Bus: kubevirtproviderv1.SCSIBus},
+			},
+		},
+		{
+			name:      "SATA disk with a dedicated IO thread",
+			buses:     []kubevirtproviderv1.DiskBus{{Disk: kubevirtproviderv1.BootDisk, Bus: kubevirtproviderv1.SATABus}},
+			dedicated: []kubevirtproviderv1.DiskName{kubevirtproviderv1.BootDisk},
+			wantErr:   "machine-test: disk Boot: the dedicated IO thread needs the virtio bus, not sata",
+		},
+		{
+			name:      "Virtio disk with a dedicated IO thread",
+			buses:     []kubevirtproviderv1.DiskBus{{Disk: kubevirtproviderv1.BootDisk, Bus: kubevirtproviderv1.VirtioBus}, {Disk: kubevirtproviderv1.CloudInitDisk, Bus: kubevirtproviderv1.SATABus}},
+			dedicated: []kubevirtproviderv1.DiskName{kubevirtproviderv1.BootDisk},
+		},
+		{
+			name:    "Unknown disk",
+			buses:   []kubevirtproviderv1.DiskBus{{Disk: "Data", Bus: kubevirtproviderv1.SATABus}},
+			wantErr: `machine-test: invalid disk "Data", expected Boot or CloudInit`,
+		},
+		{
+			name:    "Duplicate disk",
+			buses:   []kubevirtproviderv1.DiskBus{{Disk: kubevirtproviderv1.BootDisk, Bus: kubevirtproviderv1.SATABus}, {Disk: kubevirtproviderv1.BootDisk, Bus: kubevirtproviderv1.SCSIBus}},
+			wantErr: "machine-test: duplicate bus of disk Boot",
+		},
+		{
+			name:    "Invalid bus",
+			buses:   []kubevirtproviderv1.DiskBus{{Disk: kubevirtproviderv1.BootDisk, Bus: "ide"}},
+			wantErr: `machine-test: disk Boot: invalid bus "ide", expected virtio, sata or scsi`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateDiskBuses("machine-test", tc.buses, tc.dedicated)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateBootOrders(t *testing.T) {
+	first, second := uint(1), uint(2)
+	zero := uint(0)
+	cases := []struct {
+		name       string
+		diskOrders []kubevirtproviderv1.DiskBootOrder
+		interfaces []kubevirtproviderv1.NetworkInterface
+		wantErr    string
+	}{
+		{
+			name:       "Default boot order",
+			interfaces: []kubevirtproviderv1.NetworkInterface{{Name: "default"}},
+		},
+		{
+			name:       "PXE fallback",
+			diskOrders: []kubevirtproviderv1.DiskBootOrder{{Disk: kubevirtproviderv1.BootDisk, BootOrder: first}},
+			interfaces: []kubevirtproviderv1.NetworkInterface{{Name: "default"}, {Name: "provisioning", BootOrder: &second}},
+		},
+		{
+			name:       "Unknown disk",
+			diskOrders: []kubevirtproviderv1.DiskBootOrder{{Disk: "Data", BootOrder: first}},
+			wantErr:    `machine-test: invalid disk "Data", expected Boot`,
+		},
+		{
+			name:       "Config drive boot order",
+			diskOrders: []kubevirtproviderv1.DiskBootOrder{{Disk: kubevirtproviderv1.BootDisk, BootOrder: first}, {Disk: kubevirtproviderv1.CloudInitDisk, BootOrder: second}},
+			wantErr:    "machine-test: disk CloudInit can't have a boot order, it isn't bootable",
+		},
+		{
+			name:       "PXE without boot disk order",
+			interfaces: []kubevirtproviderv1.NetworkInterface{{Name: "provisioning", BootOrder: &first}},
+			wantErr:    "machine-test: missing boot order of disk Boot, the VM doesn't boot from it once another device has a boot order",
+		},
+		{
+			name:       "Duplicate disk",
+			diskOrders: []kubevirtproviderv1.DiskBootOrder{{Disk: kubevirtproviderv1.BootDisk, BootOrder: first}, {Disk: kubevirtproviderv1.BootDisk, BootOrder: second}},
+			wantErr:    "machine-test: duplicate boot order of disk Boot",
+		},
+		{
+			name:       "Zero disk boot order",
+			diskOrders: []kubevirtproviderv1.DiskBootOrder{{Disk: kubevirtproviderv1.BootDisk}},
+			wantErr:    "machine-test: disk Boot: the boot order must be positive",
+		},
+		{
+			name:       "Zero interface boot order",
+			diskOrders: []kubevirtproviderv1.DiskBootOrder{{Disk: kubevirtproviderv1.BootDisk, BootOrder: first}},
+			interfaces: []kubevirtproviderv1.NetworkInterface{{Name: "default", BootOrder: &zero}},
+			wantErr:    "machine-test: interface default: the boot order must be positive",
+		},
+		{
+			name:       "Shared boot order",
+			diskOrders: []kubevirtproviderv1.DiskBootOrder{{Disk: kubevirtproviderv1.BootDisk, BootOrder: first}},
+			interfaces: []kubevirtproviderv1.NetworkInterface{{Name: "default", BootOrder: &first}},
+			wantErr:    "machine-test: disk Boot and interface default have the same boot order 1",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateBootOrders("machine-test", tc.diskOrders, tc.interfaces)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+const (
+	// maxListedPods is the number of pods listed in the DeleteBlocked condition message
+	maxListedPods = 10
+)
+
+// checkNodeDrained keeps the VM from being deleted while the node of the machine still runs workloads, which
+// would be lost with the VM. The check is skipped when the draining of the node was excluded, and when the
+// machine has no node.
+func (m *manager) checkNodeDrained(machineScope *machineScope) error {
+	machine := machineScope.machine
+	if _, ok := machine.Annotations[machinecontroller.ExcludeNodeDrainingAnnotation]; ok {
+		klog.Infof("%s: node draining is excluded, not checking the node is drained", machineScope.getMachineName())
+		return nil
+	}
+	if machine.Status.NodeRef == nil {
+		return nil
+	}
+
+	nodeName := machine.Status.NodeRef.Name
+	node, err := machineScope.overkubeClient.GetNode(nodeName)
+	if apimachineryerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+	pods, err := machineScope.overkubeClient.ListNodePods(nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to list the pods of node %s: %w", nodeName, err)
+	}
+
+	if message := nodeNotDrainedMessage(node, pods.Items); message != "" {
+		machineScope.setCondition(conditions.New(kubevirtproviderv1.DeleteBlockedCondition, corev1.ConditionTrue, "NodeNotDrained", message))
+		klog.Warningf("%s: not deleting the VM: %s", machineScope.getMachineName(), message)
+		return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+	}
+	machineScope.setCondition(conditions.False(kubevirtproviderv1.DeleteBlockedCondition, "NodeDrained", ""))
+	return nil
+}
+
+// nodeNotDrainedMessage explains why the node isn't drained, it is empty when the node is cordoned and only
+// runs pods a drain leaves behind: the DaemonSet and mirror pods, and the pods that completed
+func nodeNotDrainedMessage(node *corev1.Node, pods []corev1.Pod) string {
+	var reasons []string
+	if !node.Spec.Unschedulable {
+		reasons = append(reasons, fmt.Sprintf("node %s is not cordoned", node.Name))
+	}
+
+	var running []string
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+			continue
+		}
+		if owner := k8smetav1.GetControllerOf(&pod); owner != nil && owner.Kind == "DaemonSet" {
+			continue
+		}
+		running = append(running, pod.Namespace+"/"+pod.Name)
+	}
+	if len(running) > 0 {
+		sort.Strings(running)
+		listed := running
+		if len(listed) > maxListedPods {
+			listed = append(listed[:maxListedPods:maxListedPods], fmt.Sprintf("and %d more", len(running)-maxListedPods))
+		}
+		reasons = append(reasons, fmt.Sprintf("%d pods still running on node %s: %s", len(running), node.Name, strings.Join(listed, ", ")))
+	}
+	return strings.Join(reasons, "; ")
+}
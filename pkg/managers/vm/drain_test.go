@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNodeNotDrainedMessage(t *testing.T) {
+	cordoned := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: mahcineName}, Spec: corev1.NodeSpec{Unschedulable: true}}
+	pod := func(name string, phase corev1.PodPhase) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "tenant", Name: name}, Status: corev1.PodStatus{Phase: phase}}
+	}
+	isController := true
+	daemonSetPod := pod("node-exporter", corev1.PodRunning)
+	daemonSetPod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "node-exporter", Controller: &isController}}
+	mirrorPod := pod("etcd", corev1.PodRunning)
+	mirrorPod.Annotations = map[string]string{corev1.MirrorPodAnnotationKey: "mirror"}
+	var manyPods []corev1.Pod
+	for i := 0; i < 12; i++ {
+		manyPods = append(manyPods, pod(fmt.Sprintf("app-%02d", i), corev1.PodRunning))
+	}
+
+	cases := []struct {
+		name        string
+		node        *corev1.Node
+		pods        []corev1.Pod
+		wantMessage string
+	}{
+		{
+			name: "Drained",
+			node: cordoned,
+			pods: []corev1.Pod{daemonSetPod, mirrorPod, pod("job", corev1.PodSucceeded), pod("crashed", corev1.PodFailed)},
+		},
+		{
+			name:        "Not cordoned",
+			node:        &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: mahcineName}},
+			pods:        []corev1.Pod{pod("app", corev1.PodPending)},
+			wantMessage: "node " + mahcineName + " is not cordoned; 1 pods still running on node " + mahcineName + ": tenant/app",
+		},
+		{
+			name: "Too many pods to list",
+			node: cordoned,
+			pods: manyPods,
+			wantMessage: "12 pods still running on node " + mahcineName + ": tenant/app-00, tenant/app-01, tenant/app-02, tenant/app-03, " +
+				"tenant/app-04, tenant/app-05, tenant/app-06, tenant/app-07, tenant/app-08, tenant/app-09, and 2 more",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, nodeNotDrainedMessage(tc.node, tc.pods), tc.wantMessage)
+		})
+	}
+}
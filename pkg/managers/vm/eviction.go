@@ -0,0 +1,41 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// validateEvictionStrategy validates the eviction strategy, and that KubeVirt can live migrate the VM of a
+// LiveMigrate one. Otherwise the drain of its underkube node would wait on a migration that never starts.
+func validateEvictionStrategy(machineName string, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) error {
+	switch providerSpec.EvictionStrategy {
+	case "", kubevirtproviderv1.EvictionStrategyNone:
+		return nil
+	case kubevirtproviderv1.EvictionStrategyLiveMigrate:
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid eviction strategy %q, expected %s or %s", machineName, providerSpec.EvictionStrategy, kubevirtproviderv1.EvictionStrategyNone, kubevirtproviderv1.EvictionStrategyLiveMigrate)
+	}
+
+	if len(providerSpec.GPUs) > 0 || len(providerSpec.MediatedDevices) > 0 {
+		return machinecontroller.InvalidMachineConfiguration("%v: the %s eviction strategy can't migrate the GPUs and mediated devices of the VM", machineName, providerSpec.EvictionStrategy)
+	}
+	migratableBridge := false
+	for _, profile := range providerSpec.AnnotationProfiles {
+		if profile == kubevirtproviderv1.MigratableBridgeProfile {
+			migratableBridge = true
+		}
+	}
+	// Without interfaces, KubeVirt bridges the NIC of the VM to the pod network
+	if len(providerSpec.Interfaces) == 0 && !migratableBridge {
+		return machinecontroller.InvalidMachineConfiguration("%v: the %s eviction strategy needs the default NIC, bridged to the pod network, to be allowed by the %s annotation profile", machineName, providerSpec.EvictionStrategy, kubevirtproviderv1.MigratableBridgeProfile)
+	}
+	for _, iface := range providerSpec.Interfaces {
+		switch {
+		case iface.Binding == kubevirtproviderv1.InterfaceBindingSRIOV:
+			return machinecontroller.InvalidMachineConfiguration("%v: the %s eviction strategy can't migrate the %s interface %q", machineName, providerSpec.EvictionStrategy, iface.Binding, iface.Name)
+		case iface.NetworkName == "" && (iface.Binding == "" || iface.Binding == kubevirtproviderv1.InterfaceBindingBridge) && !migratableBridge:
+			return machinecontroller.InvalidMachineConfiguration("%v: the %s eviction strategy needs the interface %q, bridged to the pod network, to be masquerade or allowed by the %s annotation profile", machineName, providerSpec.EvictionStrategy, iface.Name, kubevirtproviderv1.MigratableBridgeProfile)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateEvictionStrategy(t *testing.T) {
+	masquerade := []kubevirtproviderv1.NetworkInterface{{Name: "default", Binding: kubevirtproviderv1.InterfaceBindingMasquerade}}
+	cases := []struct {
+		name         string
+		providerSpec kubevirtproviderv1.KubevirtMachineProviderSpec
+		wantErr      string
+	}{
+		{
+			name: "No eviction strategy",
+		},
+		{
+			name:         "None",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{EvictionStrategy: kubevirtproviderv1.EvictionStrategyNone},
+		},
+		{
+			name:         "Unknown strategy",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{EvictionStrategy: "Migrate"},
+			wantErr:      `machine-test: invalid eviction strategy "Migrate", expected None or LiveMigrate`,
+		},
+		{
+			name: "LiveMigrate with a masquerade NIC",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{
+				EvictionStrategy: kubevirtproviderv1.EvictionStrategyLiveMigrate,
+				Interfaces:       append(masquerade, kubevirtproviderv1.NetworkInterface{Name: "storage", NetworkName: "storage-net"}),
+			},
+		},
+		{
+			name: "LiveMigrate with the default NIC allowed by the profile",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{
+				EvictionStrategy:   kubevirtproviderv1.EvictionStrategyLiveMigrate,
+				AnnotationProfiles: []kubevirtproviderv1.AnnotationProfile{kubevirtproviderv1.MigratableBridgeProfile},
+			},
+		},
+		{
+			name:         "LiveMigrate with the default NIC",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{EvictionStrategy: kubevirtproviderv1.EvictionStrategyLiveMigrate},
+			wantErr:      "machine-test: the LiveMigrate eviction strategy needs the default NIC, bridged to the pod network, to be allowed by the migratable-bridge annotation profile",
+		},
+		{
+			name: "LiveMigrate with a NIC bridged to the pod network",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{
+				EvictionStrategy: kubevirtproviderv1.EvictionStrategyLiveMigrate,
+				Interfaces:       []kubevirtproviderv1.NetworkInterface{{Name: "default"}},
+			},
+			wantErr: `machine-test: the LiveMigrate eviction strategy needs the interface "default", bridged to the pod network, to be masquerade or allowed by the migratable-bridge annotation profile`,
+		},
+		{
+			name: "LiveMigrate with an sriov NIC",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{
+				EvictionStrategy: kubevirtproviderv1.EvictionStrategyLiveMigrate,
+				Interfaces:       append(masquerade, kubevirtproviderv1.NetworkInterface{Name: "fast", NetworkName: "sriov-net", Binding: kubevirtproviderv1.InterfaceBindingSRIOV}),
+			},
+			wantErr: `machine-test: the LiveMigrate eviction strategy can't migrate the sriov interface "fast"`,
+		},
+		{
+			name: "LiveMigrate with a GPU",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{
+				EvictionStrategy: kubevirtproviderv1.EvictionStrategyLiveMigrate,
+				Interfaces:       masquerade,
+				GPUs:             []kubevirtproviderv1.GPU{{Name: "gpu1", DeviceName: "nvidia.com/TU104GL_Tesla_T4"}},
+			},
+			wantErr: "machine-test: the LiveMigrate eviction strategy can't migrate the GPUs and mediated devices of the VM",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateEvictionStrategy("machine-test", &tc.providerSpec)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package vm
+
+import (
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const defaultExposedPath = "/"
+
+func validateExpose(machineName string, expose *kubevirtproviderv1.Expose) error {
+	if expose == nil {
+		return nil
+	}
+	names := map[string]bool{}
+	for _, port := range expose.Ports {
+		if port.Name == "" {
+			return machinecontroller.InvalidMachineConfiguration("%v: missing name for exposed port %d", machineName, port.Port)
+		}
+		if names[port.Name] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate exposed port %q", machineName, port.Name)
+		}
+		names[port.Name] = true
+		if port.Port < 1 || port.Port > 65535 {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid exposed port %d", machineName, port.Port)
+		}
+		if port.Path != "" && !strings.HasPrefix(port.Path, "/") {
+			return machinecontroller.InvalidMachineConfiguration("%v: exposed path %q must start with /", machineName, port.Path)
+		}
+	}
+	return nil
+}
+
+// buildServicePorts returns the ports of the machine service, one per exposed port, which the Ingress
+// rules point to
+func buildServicePorts(expose *kubevirtproviderv1.Expose) []corev1.ServicePort {
+	if expose == nil {
+		return nil
+	}
+	var ports []corev1.ServicePort
+	for _, port := range expose.Ports {
+		ports = append(ports, corev1.ServicePort{
+			Name:       port.Name,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       port.Port,
+			TargetPort: intstr.FromInt(int(port.Port)),
+		})
+	}
+	return ports
+}
+
+// buildIngress returns the Ingress publishing the exposed ports through the machine service, nil when
+// no port is exposed
+func buildIngress(vmName string, labels map[string]string, expose *kubevirtproviderv1.Expose) *networkingv1beta1.Ingress {
+	if expose == nil || len(expose.Ports) == 0 {
+		return nil
+	}
+
+	ingress := &networkingv1beta1.Ingress{}
+	ingress.Name = vmName
+	ingress.Labels = labels
+	for _, port := range expose.Ports {
+		path := port.Path
+		if path == "" {
+			path = defaultExposedPath
+		}
+		ingress.Spec.Rules = append(ingress.Spec.Rules, networkingv1beta1.IngressRule{
+			Host: port.Host,
+			IngressRuleValue: networkingv1beta1.IngressRuleValue{
+				HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+					Paths: []networkingv1beta1.HTTPIngressPath{
+						{
+							Path: path,
+							Backend: networkingv1beta1.IngressBackend{
+								ServiceName: vmName,
+								ServicePort: intstr.FromString(port.Name),
+							},
+						},
+					},
+				},
+			},
+		})
+	}
+	return ingress
+}
+
+// hasIngress returns true when the machine exposes ports, or the provider created an Ingress for the ports
+// it exposed before. The machines that never had one don't need the ingress RBAC in the underkube.
+func (s *machineScope) hasIngress() bool {
+	return s.machineProviderSpec.Expose != nil || s.machineProviderStatus.IngressCreated
+}
+
+// syncIngress creates, updates or deletes the Ingress of the VM to match the exposed ports
+func (m *manager) syncIngress(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	desired := buildIngress(vm.Name, buildVMResourceLabels(vm.Name, machineScope.machine), machineScope.machineProviderSpec.Expose)
+	ingress, err := machineScope.underkubeClient.GetIngress(vm.Name, vm.Namespace, k8smetav1.GetOptions{})
+	switch {
+	case apimachineryerrors.IsNotFound(err):
+		if desired == nil {
+			machineScope.machineProviderStatus.IngressCreated = false
+			return nil
+		}
+		klog.Infof("%s: creating ingress", machineScope.getMachineName())
+		if _, err := machineScope.underkubeClient.CreateIngress(desired, vm.Namespace); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case desired == nil:
+		return m.removeIngressIfNeeded(vm, machineScope)
+	case !equality.Semantic.DeepEqual(ingress.Spec, desired.Spec):
+		klog.Infof("%s: updating ingress", machineScope.getMachineName())
+		ingress.Spec = desired.Spec
+		if _, err := machineScope.underkubeClient.UpdateIngress(ingress, vm.Namespace); err != nil {
+			return err
+		}
+	}
+	machineScope.machineProviderStatus.IngressCreated = true
+	return nil
+}
+
+func (m *manager) removeIngressIfNeeded(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	if !machineScope.hasIngress() {
+		return nil
+	}
+	err := machineScope.underkubeClient.DeleteIngress(vm.Name, vm.Namespace, &k8smetav1.DeleteOptions{})
+	if err != nil && !apimachineryerrors.IsNotFound(err) {
+		return err
+	}
+	machineScope.machineProviderStatus.IngressCreated = false
+	return nil
+}
@@ -0,0 +1,171 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	"gotest.tools/assert"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestBuildIngress(t *testing.T) {
+	cases := []struct {
+		name      string
+		expose    *kubevirtproviderv1.Expose
+		wantRules []networkingv1beta1.IngressRule
+	}{
+		{
+			name: "No ingress without exposed ports",
+		},
+		{
+			name: "One rule per exposed port",
+			expose: &kubevirtproviderv1.Expose{Ports: []kubevirtproviderv1.ExposedPort{
+				{Name: "https", Port: 443, Host: "apps.example.com"},
+				{Name: "metrics", Port: 9100, Path: "/metrics"},
+			}},
+			wantRules: []networkingv1beta1.IngressRule{
+				stubIngressRule("apps.example.com", "/", "https"),
+				stubIngressRule("", "/metrics", "metrics"),
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ingress := buildIngress(mahcineName, map[string]string{render.VMLabel: mahcineName}, tc.expose)
+			if tc.wantRules == nil {
+				assert.Assert(t, ingress == nil)
+				return
+			}
+			assert.Equal(t, ingress.Name, mahcineName)
+			assert.Equal(t, ingress.Labels[render.VMLabel], mahcineName)
+			assert.DeepEqual(t, ingress.Spec.Rules, tc.wantRules)
+			assert.Equal(t, len(buildServicePorts(tc.expose)), len(tc.wantRules))
+		})
+	}
+}
+
+func TestSyncIngress(t *testing.T) {
+	exposed := &kubevirtproviderv1.Expose{Ports: []kubevirtproviderv1.ExposedPort{{Name: "https", Port: 443}}}
+	notFound := apimachineryerrors.NewNotFound(networkingv1beta1.Resource("ingresses"), mahcineName)
+	cases := []struct {
+		name        string
+		expose      *kubevirtproviderv1.Expose
+		created     bool
+		getErr      error
+		wantCreate  bool
+		wantDelete  bool
+		wantCreated bool
+	}{
+		{
+			name:        "Ingress created",
+			expose:      exposed,
+			getErr:      notFound,
+			wantCreate:  true,
+			wantCreated: true,
+		},
+		{
+			name:       "Ingress removed once the ports are no longer exposed",
+			created:    true,
+			wantDelete: true,
+		},
+		{
+			name:    "Ingress already gone",
+			created: true,
+			getErr:  notFound,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope := &machineScope{
+				machine:               machine,
+				underkubeClient:       mockUnderkube,
+				machineProviderSpec:   &kubevirtproviderv1.KubevirtMachineProviderSpec{Expose: tc.expose},
+				machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{IngressCreated: tc.created},
+			}
+			vm := &kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: mahcineName, Namespace: clusterID}}
+
+			assert.Assert(t, machineScope.hasIngress())
+			mockUnderkube.EXPECT().GetIngress(mahcineName, clusterID, gomock.Any()).Return(&networkingv1beta1.Ingress{}, tc.getErr)
+			if tc.wantCreate {
+				mockUnderkube.EXPECT().CreateIngress(gomock.Any(), clusterID).Return(&networkingv1beta1.Ingress{}, nil)
+			}
+			if tc.wantDelete {
+				mockUnderkube.EXPECT().DeleteIngress(mahcineName, clusterID, gomock.Any()).Return(nil)
+			}
+
+			assert.NilError(t, (&manager{}).syncIngress(vm, machineScope))
+			assert.Equal(t, machineScope.machineProviderStatus.IngressCreated, tc.wantCreated)
+			assert.Equal(t, machineScope.hasIngress(), tc.wantCreated)
+		})
+	}
+}
+
+func TestValidateExpose(t *testing.T) {
+	cases := []struct {
+		name    string
+		ports   []kubevirtproviderv1.ExposedPort
+		wantErr string
+	}{
+		{
+			name:  "Valid ports",
+			ports: []kubevirtproviderv1.ExposedPort{{Name: "https", Port: 443}, {Name: "http", Port: 80, Path: "/"}},
+		},
+		{
+			name:    "Missing name",
+			ports:   []kubevirtproviderv1.ExposedPort{{Port: 443}},
+			wantErr: "machine-test: missing name for exposed port 443",
+		},
+		{
+			name:    "Duplicate name",
+			ports:   []kubevirtproviderv1.ExposedPort{{Name: "https", Port: 443}, {Name: "https", Port: 8443}},
+			wantErr: `machine-test: duplicate exposed port "https"`,
+		},
+		{
+			name:    "Port out of range",
+			ports:   []kubevirtproviderv1.ExposedPort{{Name: "https", Port: 70000}},
+			wantErr: "machine-test: invalid exposed port 70000",
+		},
+		{
+			name:    "Relative path",
+			ports:   []kubevirtproviderv1.ExposedPort{{Name: "https", Port: 443, Path: "healthz"}},
+			wantErr: `machine-test: exposed path "healthz" must start with /`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateExpose("machine-test", &kubevirtproviderv1.Expose{Ports: tc.ports})
+			if tc.wantErr == "" {
+				assert.NilError(t, err)
+			} else {
+				assert.Error(t, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func stubIngressRule(host, path, portName string) networkingv1beta1.IngressRule {
+	return networkingv1beta1.IngressRule{
+		Host: host,
+		IngressRuleValue: networkingv1beta1.IngressRuleValue{
+			HTTP: &networkingv1beta1.HTTPIngressRuleValue{
+				Paths: []networkingv1beta1.HTTPIngressPath{{
+					Path:    path,
+					Backend: networkingv1beta1.IngressBackend{ServiceName: mahcineName, ServicePort: intstr.FromString(portName)},
+				}},
+			},
+		},
+	}
+}
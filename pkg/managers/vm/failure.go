@@ -0,0 +1,112 @@
+package vm
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var machineFailuresCounter = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "kubevirt_machine_failures_total",
+		Help: "Number of machine failures, per failure reason.",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(machineFailuresCounter)
+}
+
+// errorFailureReason maps the error of an operation to a failure reason, empty when the error doesn't
+// fit any of them
+func errorFailureReason(err error) kubevirtproviderv1.FailureReason {
+	if err == nil {
+		return ""
+	}
+
+	// The apimachinery helpers don't unwrap the errors, so look for the API status ourselves
+	var status apimachineryerrors.APIStatus
+	if errors.As(err, &status) {
+		switch status.Status().Reason {
+		case k8smetav1.StatusReasonForbidden:
+			if strings.Contains(status.Status().Message, "exceeded quota") {
+				return kubevirtproviderv1.QuotaExceededFailure
+			}
+		case k8smetav1.StatusReasonInvalid:
+			return kubevirtproviderv1.InvalidConfigurationFailure
+		case k8smetav1.StatusReasonServiceUnavailable, k8smetav1.StatusReasonServerTimeout, k8smetav1.StatusReasonTimeout:
+			return kubevirtproviderv1.InfraUnreachableFailure
+		}
+		return ""
+	}
+
+	if underkube.IsInfraNotReady(err) {
+		return kubevirtproviderv1.InfraNotReadyFailure
+	}
+	var budgetErr *budgetExceededError
+	if errors.As(err, &budgetErr) {
+		return kubevirtproviderv1.QuotaExceededFailure
+	}
+	var machineErr *machinecontroller.MachineError
+	if underkube.IsCredentialsError(err) || (errors.As(err, &machineErr) && machineErr.Reason == machinev1.InvalidConfigurationMachineError) {
+		return kubevirtproviderv1.InvalidConfigurationFailure
+	}
+
+	// Connection refused, DNS and dial timeouts
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return kubevirtproviderv1.InfraUnreachableFailure
+	}
+	return ""
+}
+
+// vmFailureReason maps the state of the VM and its VMI to a failure reason and message, empty when the
+// VM is healthy or still making progress. The timeouts are handled by the deadlines.
+func vmFailureReason(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance) (kubevirtproviderv1.FailureReason, string) {
+	for _, c := range vm.Status.Conditions {
+		if c.Type != kubevirtapiv1.VirtualMachineFailure || c.Status != corev1.ConditionTrue {
+			continue
+		}
+		if strings.Contains(c.Message, "exceeded quota") {
+			return kubevirtproviderv1.QuotaExceededFailure, c.Message
+		}
+		if isImagePullFailure(c.Reason, c.Message) {
+			return kubevirtproviderv1.ImagePullFailure, c.Message
+		}
+	}
+
+	if vmi == nil {
+		return "", ""
+	}
+	for _, c := range vmi.Status.Conditions {
+		if c.Type == kubevirtapiv1.VirtualMachineInstanceConditionType(corev1.PodScheduled) && c.Status == corev1.ConditionFalse &&
+			c.Reason == corev1.PodReasonUnschedulable && strings.Contains(c.Message, "Insufficient") {
+			return kubevirtproviderv1.InsufficientResourcesFailure, c.Message
+		}
+		if isImagePullFailure(c.Reason, c.Message) {
+			return kubevirtproviderv1.ImagePullFailure, c.Message
+		}
+	}
+	return "", ""
+}
+
+func isImagePullFailure(reason, message string) bool {
+	for _, s := range []string{"ErrImagePull", "ImagePullBackOff"} {
+		if reason == s || strings.Contains(message, s) {
+			return true
+		}
+	}
+	return false
+}
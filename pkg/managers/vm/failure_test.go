@@ -0,0 +1,115 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestErrorFailureReason(t *testing.T) {
+	vmResource := schema.GroupResource{Group: "kubevirt.io", Resource: "virtualmachines"}
+	cases := []struct {
+		name       string
+		err        error
+		wantReason kubevirtproviderv1.FailureReason
+	}{
+		{
+			name:       "Quota",
+			err:        fmt.Errorf("failed to create virtual machine: %w", apimachineryerrors.NewForbidden(vmResource, "vm", errors.New("exceeded quota: compute, requested: requests.memory=2Gi"))),
+			wantReason: kubevirtproviderv1.QuotaExceededFailure,
+		},
+		{
+			name:       "Budget",
+			err:        &budgetExceededError{message: "machine-test: not creating the VM: the VMs of cluster cluster-test would use memory 8Gi over the budget of 4Gi"},
+			wantReason: kubevirtproviderv1.QuotaExceededFailure,
+		},
+		{
+			name:       "Invalid VM",
+			err:        fmt.Errorf("failed to create virtual machine: %w", apimachineryerrors.NewInvalid(schema.GroupKind{Kind: "VirtualMachine"}, "vm", nil)),
+			wantReason: kubevirtproviderv1.InvalidConfigurationFailure,
+		},
+		{
+			name:       "Invalid machine configuration",
+			err:        machinecontroller.InvalidMachineConfiguration("machine-test: invalid exposed port 0"),
+			wantReason: kubevirtproviderv1.InvalidConfigurationFailure,
+		},
+		{
+			name:       "Unreachable API server",
+			err:        fmt.Errorf("failed to create virtual machine: %w", &url.Error{Op: "Post", URL: "https://underkube:6443", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}),
+			wantReason: kubevirtproviderv1.InfraUnreachableFailure,
+		},
+		{
+			name:       "Unavailable API server",
+			err:        apimachineryerrors.NewServiceUnavailable("the server is currently unable to handle the request"),
+			wantReason: kubevirtproviderv1.InfraUnreachableFailure,
+		},
+		{
+			name: "Other errors",
+			err:  apimachineryerrors.NewConflict(vmResource, "vm", errors.New("the object has been modified")),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, errorFailureReason(tc.err), tc.wantReason)
+		})
+	}
+}
+
+func TestVMFailureReason(t *testing.T) {
+	cases := []struct {
+		name        string
+		vm          *kubevirtapiv1.VirtualMachine
+		vmi         *kubevirtapiv1.VirtualMachineInstance
+		wantReason  kubevirtproviderv1.FailureReason
+		wantMessage string
+	}{
+		{
+			name: "Healthy VM",
+			vm:   &kubevirtapiv1.VirtualMachine{Status: kubevirtapiv1.VirtualMachineStatus{Ready: true}},
+			vmi:  &kubevirtapiv1.VirtualMachineInstance{},
+		},
+		{
+			name: "Quota",
+			vm: &kubevirtapiv1.VirtualMachine{Status: kubevirtapiv1.VirtualMachineStatus{Conditions: []kubevirtapiv1.VirtualMachineCondition{
+				{Type: kubevirtapiv1.VirtualMachineFailure, Status: corev1.ConditionTrue, Reason: "FailedCreate", Message: "exceeded quota: compute"},
+			}}},
+			wantReason:  kubevirtproviderv1.QuotaExceededFailure,
+			wantMessage: "exceeded quota: compute",
+		},
+		{
+			name: "Unschedulable VMI",
+			vm:   &kubevirtapiv1.VirtualMachine{},
+			vmi: &kubevirtapiv1.VirtualMachineInstance{Status: kubevirtapiv1.VirtualMachineInstanceStatus{Conditions: []kubevirtapiv1.VirtualMachineInstanceCondition{
+				{Type: kubevirtapiv1.VirtualMachineInstanceConditionType(corev1.PodScheduled), Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable, Message: "0/3 nodes are available: 3 Insufficient memory."},
+			}}},
+			wantReason:  kubevirtproviderv1.InsufficientResourcesFailure,
+			wantMessage: "0/3 nodes are available: 3 Insufficient memory.",
+		},
+		{
+			name: "Image pull",
+			vm:   &kubevirtapiv1.VirtualMachine{},
+			vmi: &kubevirtapiv1.VirtualMachineInstance{Status: kubevirtapiv1.VirtualMachineInstanceStatus{Conditions: []kubevirtapiv1.VirtualMachineInstanceCondition{
+				{Type: kubevirtapiv1.VirtualMachineInstanceReady, Status: corev1.ConditionFalse, Reason: "ImagePullBackOff", Message: "Back-off pulling image"},
+			}}},
+			wantReason:  kubevirtproviderv1.ImagePullFailure,
+			wantMessage: "Back-off pulling image",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			reason, message := vmFailureReason(tc.vm, tc.vmi)
+			assert.Equal(t, reason, tc.wantReason)
+			assert.Equal(t, message, tc.wantMessage)
+		})
+	}
+}
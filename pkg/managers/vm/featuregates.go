@@ -0,0 +1,55 @@
+package vm
+
+import (
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	// dataVolumesFeatureGate enables the data volume templates and volumes of the VMs
+	dataVolumesFeatureGate = "DataVolumes"
+	// gpuFeatureGate enables the GPU devices of the VMs, which also carry the mediated devices
+	gpuFeatureGate = "GPU"
+	// cpuManagerFeatureGate enables the dedicated CPU placement of the VMs
+	cpuManagerFeatureGate = "CPUManager"
+	// sriovFeatureGate enables the sriov binding of the interfaces of the VMs
+	sriovFeatureGate = "SRIOV"
+	// liveMigrationFeatureGate enables the live migration of the VMs, which the LiveMigrate eviction
+	// strategy needs
+	liveMigrationFeatureGate = "LiveMigration"
+)
+
+// requiredFeatureGates returns the KubeVirt feature gates the VM needs. The features of newer KubeVirt
+// releases, like volume or CPU hotplug, aren't served by the v1alpha3 API the provider uses.
+func requiredFeatureGates(vm *kubevirtapiv1.VirtualMachine) []string {
+	usesDataVolumes := len(vm.Spec.DataVolumeTemplates) > 0
+	if vm.Spec.Template != nil {
+		for _, volume := range vm.Spec.Template.Spec.Volumes {
+			if volume.DataVolume != nil {
+				usesDataVolumes = true
+			}
+		}
+	}
+
+	var gates []string
+	if usesDataVolumes {
+		gates = append(gates, dataVolumesFeatureGate)
+	}
+	if vm.Spec.Template != nil && len(vm.Spec.Template.Spec.Domain.Devices.GPUs) > 0 {
+		gates = append(gates, gpuFeatureGate)
+	}
+	if vm.Spec.Template != nil && vm.Spec.Template.Spec.Domain.CPU != nil && vm.Spec.Template.Spec.Domain.CPU.DedicatedCPUPlacement {
+		gates = append(gates, cpuManagerFeatureGate)
+	}
+	if vm.Spec.Template != nil {
+		for _, iface := range vm.Spec.Template.Spec.Domain.Devices.Interfaces {
+			if iface.SRIOV != nil {
+				gates = append(gates, sriovFeatureGate)
+				break
+			}
+		}
+	}
+	if vm.Spec.Template != nil && vm.Spec.Template.Spec.EvictionStrategy != nil {
+		gates = append(gates, liveMigrationFeatureGate)
+	}
+	return gates
+}
@@ -0,0 +1,40 @@
+package vm
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func TestRequiredFeatureGates(t *testing.T) {
+	vm := &kubevirtapiv1.VirtualMachine{}
+	vm.Spec.Template = &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{}
+	assert.Assert(t, requiredFeatureGates(vm) == nil)
+
+	vm.Spec.Template.Spec.Volumes = []kubevirtapiv1.Volume{
+		{Name: "bootvolume", VolumeSource: kubevirtapiv1.VolumeSource{DataVolume: &kubevirtapiv1.DataVolumeSource{Name: "bootvolume"}}},
+	}
+	assert.DeepEqual(t, requiredFeatureGates(vm), []string{dataVolumesFeatureGate})
+
+	vm.Spec.Template.Spec.Domain.Devices.GPUs = []kubevirtapiv1.GPU{{Name: "gpu1", DeviceName: "nvidia.com/GRID_T4-1Q"}}
+	assert.DeepEqual(t, requiredFeatureGates(vm), []string{dataVolumesFeatureGate, gpuFeatureGate})
+
+	vm.Spec.Template.Spec.Domain.CPU = &kubevirtapiv1.CPU{DedicatedCPUPlacement: true}
+	assert.DeepEqual(t, requiredFeatureGates(vm), []string{dataVolumesFeatureGate, gpuFeatureGate, cpuManagerFeatureGate})
+
+	vm.Spec.Template.Spec.Domain.Devices.Interfaces = []kubevirtapiv1.Interface{
+		{Name: "default", InterfaceBindingMethod: kubevirtapiv1.InterfaceBindingMethod{Bridge: &kubevirtapiv1.InterfaceBridge{}}},
+		{Name: "fast", InterfaceBindingMethod: kubevirtapiv1.InterfaceBindingMethod{SRIOV: &kubevirtapiv1.InterfaceSRIOV{}}},
+	}
+	assert.DeepEqual(t, requiredFeatureGates(vm), []string{dataVolumesFeatureGate, gpuFeatureGate, cpuManagerFeatureGate, sriovFeatureGate})
+
+	evictionStrategy := kubevirtapiv1.EvictionStrategyLiveMigrate
+	vm.Spec.Template.Spec.EvictionStrategy = &evictionStrategy
+	assert.DeepEqual(t, requiredFeatureGates(vm), []string{dataVolumesFeatureGate, gpuFeatureGate, cpuManagerFeatureGate, sriovFeatureGate, liveMigrationFeatureGate})
+
+	vm.Spec.Template = nil
+	vm.Spec.DataVolumeTemplates = []cdiv1.DataVolume{{}}
+	assert.DeepEqual(t, requiredFeatureGates(vm), []string{dataVolumesFeatureGate})
+}
@@ -0,0 +1,19 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// validateFirmware validates the bootloader
+func validateFirmware(machineName string, firmware *kubevirtproviderv1.Firmware) error {
+	if firmware == nil {
+		return nil
+	}
+	switch firmware.Bootloader {
+	case "", kubevirtproviderv1.BIOSBootloader, kubevirtproviderv1.EFIBootloader:
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid bootloader %q, expected %s or %s", machineName, firmware.Bootloader, kubevirtproviderv1.BIOSBootloader, kubevirtproviderv1.EFIBootloader)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateFirmware(t *testing.T) {
+	cases := []struct {
+		name     string
+		firmware *kubevirtproviderv1.Firmware
+		wantErr  string
+	}{
+		{
+			name: "No firmware",
+		},
+		{
+			name:     "BIOS",
+			firmware: &kubevirtproviderv1.Firmware{Bootloader: kubevirtproviderv1.BIOSBootloader},
+		},
+		{
+			name:     "EFI with SMM",
+			firmware: &kubevirtproviderv1.Firmware{Bootloader: kubevirtproviderv1.EFIBootloader, SMM: true},
+		},
+		{
+			name:     "Invalid bootloader",
+			firmware: &kubevirtproviderv1.Firmware{Bootloader: "UEFI"},
+			wantErr:  `machine-test: invalid bootloader "UEFI", expected BIOS or EFI`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateFirmware("machine-test", tc.firmware)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
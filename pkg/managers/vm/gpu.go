@@ -0,0 +1,91 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
+)
+
+// validateGPUs validates the GPUs and the mediated devices, which share the GPU devices of the VM
+func validateGPUs(machineName string, gpus []kubevirtproviderv1.GPU, mediatedDevices []kubevirtproviderv1.MediatedDevice) error {
+	type device struct{ kind, name, deviceName string }
+	var devices []device
+	for _, gpu := range gpus {
+		devices = append(devices, device{"GPU", gpu.Name, gpu.DeviceName})
+	}
+	for _, mediatedDevice := range mediatedDevices {
+		devices = append(devices, device{"mediated device", mediatedDevice.Name, mediatedDevice.DeviceName})
+	}
+
+	names := map[string]bool{}
+	for _, d := range devices {
+		if d.name == "" {
+			return machinecontroller.InvalidMachineConfiguration("%v: missing %s name", machineName, d.kind)
+		}
+		if names[d.name] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate %s name %q", machineName, d.kind, d.name)
+		}
+		names[d.name] = true
+		if errs := validation.IsQualifiedName(d.deviceName); len(errs) > 0 || !strings.Contains(d.deviceName, "/") {
+			return machinecontroller.InvalidMachineConfiguration("%v: %s %s: invalid device name %q, expected the resource name of a device plugin, e.g. nvidia.com/GV100GL_Tesla_V100", machineName, d.kind, d.name, d.deviceName)
+		}
+	}
+	return nil
+}
+
+// checkMediatedDevices fails the machine when no underkube node offers one of its mediated device types,
+// the VM would never be scheduled otherwise. The check is skipped when the credentials can't list the
+// nodes.
+func (m *manager) checkMediatedDevices(machineScope *machineScope) error {
+	mediatedDevices := machineScope.machineProviderSpec.MediatedDevices
+	if len(mediatedDevices) == 0 {
+		return nil
+	}
+	nodes, err := machineScope.underkubeClient.ListNodes(k8smetav1.ListOptions{})
+	if apimachineryerrors.IsForbidden(err) {
+		klog.Warningf("%s: the underkube credentials can't list the nodes, not checking the mediated devices are available", machineScope.getMachineName())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list the underkube nodes: %w", err)
+	}
+
+	if missing := missingMediatedDevices(mediatedDevices, nodes.Items); len(missing) > 0 {
+		message := fmt.Sprintf("no underkube node offers the mediated device types %s", strings.Join(missing, ", "))
+		machineScope.setCondition(conditions.False(kubevirtproviderv1.MediatedDevicesAvailableCondition, "MediatedDeviceNotFound", message))
+		return machinecontroller.InvalidMachineConfiguration("%v: %s", machineScope.getMachineName(), message)
+	}
+	machineScope.setCondition(conditions.True(kubevirtproviderv1.MediatedDevicesAvailableCondition, "MediatedDevicesFound"))
+	return nil
+}
+
+// missingMediatedDevices returns the sorted device names of the mediated devices no node has allocatable
+func missingMediatedDevices(mediatedDevices []kubevirtproviderv1.MediatedDevice, nodes []corev1.Node) []string {
+	missing := map[string]bool{}
+	for _, mediatedDevice := range mediatedDevices {
+		missing[mediatedDevice.DeviceName] = true
+	}
+	for _, node := range nodes {
+		for deviceName := range missing {
+			if quantity, ok := node.Status.Allocatable[corev1.ResourceName(deviceName)]; ok && !quantity.IsZero() {
+				delete(missing, deviceName)
+			}
+		}
+	}
+
+	var result []string
+	for deviceName := range missing {
+		result = append(result, deviceName)
+	}
+	sort.Strings(result)
+	return result
+}
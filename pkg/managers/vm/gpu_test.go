@@ -0,0 +1,121 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateGPUs(t *testing.T) {
+	cases := []struct {
+		name            string
+		gpus            []kubevirtproviderv1.GPU
+		mediatedDevices []kubevirtproviderv1.MediatedDevice
+		wantErr         string
+	}{
+		{
+			name: "No GPU",
+		},
+		{
+			name: "Valid",
+			gpus: []kubevirtproviderv1.GPU{{Name: "gpu1", DeviceName: "nvidia.com/GV100GL_Tesla_V100"}, {Name: "gpu2", DeviceName: "nvidia.com/GV100GL_Tesla_V100"}},
+		},
+		{
+			name:    "Missing name",
+			gpus:    []kubevirtproviderv1.GPU{{DeviceName: "nvidia.com/GV100GL_Tesla_V100"}},
+			wantErr: "machine-test: missing GPU name",
+		},
+		{
+			name:    "Duplicate name",
+			gpus:    []kubevirtproviderv1.GPU{{Name: "gpu1", DeviceName: "nvidia.com/GV100GL_Tesla_V100"}, {Name: "gpu1", DeviceName: "nvidia.com/TU104GL_Tesla_T4"}},
+			wantErr: `machine-test: duplicate GPU name "gpu1"`,
+		},
+		{
+			name:            "Mediated device named like a GPU",
+			gpus:            []kubevirtproviderv1.GPU{{Name: "gpu1", DeviceName: "nvidia.com/GV100GL_Tesla_V100"}},
+			mediatedDevices: []kubevirtproviderv1.MediatedDevice{{Name: "gpu1", DeviceName: "nvidia.com/GRID_T4-1Q"}},
+			wantErr:         `machine-test: duplicate mediated device name "gpu1"`,
+		},
+		{
+			name:    "Device name without vendor",
+			gpus:    []kubevirtproviderv1.GPU{{Name: "gpu1", DeviceName: "GV100GL_Tesla_V100"}},
+			wantErr: `machine-test: GPU gpu1: invalid device name "GV100GL_Tesla_V100", expected the resource name of a device plugin, e.g. nvidia.com/GV100GL_Tesla_V100`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateGPUs("machine-test", tc.gpus, tc.mediatedDevices)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckMediatedDevices(t *testing.T) {
+	node := func(allocatable corev1.ResourceList) corev1.Node {
+		return corev1.Node{Status: corev1.NodeStatus{Allocatable: allocatable}}
+	}
+	nodes := &corev1.NodeList{Items: []corev1.Node{
+		node(corev1.ResourceList{"nvidia.com/GRID_T4-1Q": apiresource.MustParse("4")}),
+		node(corev1.ResourceList{"nvidia.com/GRID_T4-2Q": apiresource.MustParse("0")}),
+	}}
+
+	cases := []struct {
+		name            string
+		mediatedDevices []kubevirtproviderv1.MediatedDevice
+		wantErr         string
+		wantStatus      corev1.ConditionStatus
+	}{
+		{
+			name:            "Available",
+			mediatedDevices: []kubevirtproviderv1.MediatedDevice{{Name: "vgpu1", DeviceName: "nvidia.com/GRID_T4-1Q"}},
+			wantStatus:      corev1.ConditionTrue,
+		},
+		{
+			name: "Missing",
+			mediatedDevices: []kubevirtproviderv1.MediatedDevice{
+				{Name: "vgpu1", DeviceName: "nvidia.com/GRID_T4-1Q"},
+				{Name: "vgpu2", DeviceName: "nvidia.com/GRID_T4-2Q"},
+				{Name: "vgpu3", DeviceName: "nvidia.com/GRID_T4-4Q"},
+			},
+			wantErr:    mahcineName + ": no underkube node offers the mediated device types nvidia.com/GRID_T4-2Q, nvidia.com/GRID_T4-4Q",
+			wantStatus: corev1.ConditionFalse,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			mockUnderkube.EXPECT().ListNodes(metav1.ListOptions{}).Return(nodes, nil)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope := &machineScope{
+				machine:               machine,
+				underkubeClient:       mockUnderkube,
+				machineProviderSpec:   &kubevirtproviderv1.KubevirtMachineProviderSpec{MediatedDevices: tc.mediatedDevices},
+				machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{},
+			}
+			err = (&manager{}).checkMediatedDevices(machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			condition := conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.MediatedDevicesAvailableCondition)
+			assert.Assert(t, condition != nil)
+			assert.Equal(t, condition.Status, tc.wantStatus)
+		})
+	}
+}
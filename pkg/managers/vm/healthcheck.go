@@ -0,0 +1,24 @@
+package vm
+
+import (
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+func validateHealthCheck(machineName string, healthCheck *kubevirtproviderv1.HealthCheck) error {
+	if healthCheck == nil {
+		return nil
+	}
+	if healthCheck.Port < 1 || healthCheck.Port > 65535 {
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid health check port %d", machineName, healthCheck.Port)
+	}
+	if healthCheck.Path != "" && !strings.HasPrefix(healthCheck.Path, "/") {
+		return machinecontroller.InvalidMachineConfiguration("%v: health check path %q must start with /", machineName, healthCheck.Path)
+	}
+	if healthCheck.IntervalSeconds < 0 || healthCheck.TimeoutSeconds < 0 || healthCheck.FailureThreshold < 0 {
+		return machinecontroller.InvalidMachineConfiguration("%v: health check interval, timeout and failure threshold can't be negative", machineName)
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateHealthCheck(t *testing.T) {
+	assert.NilError(t, validateHealthCheck("machine-test", nil))
+	assert.Error(t, validateHealthCheck("machine-test", &kubevirtproviderv1.HealthCheck{Port: 0}), "machine-test: invalid health check port 0")
+	assert.Error(t, validateHealthCheck("machine-test", &kubevirtproviderv1.HealthCheck{Port: 80, Path: "healthz"}), `machine-test: health check path "healthz" must start with /`)
+}
@@ -0,0 +1,33 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+// hugepagesPageSizes are the hugepages sizes of x86_64
+var hugepagesPageSizes = map[string]int64{
+	"2Mi": 2 << 20,
+	"1Gi": 1 << 30,
+}
+
+// validateHugepages validates the page size, and that the memory of the VM is made of whole pages as
+// KubeVirt requires
+func validateHugepages(machineName string, hugepages *kubevirtproviderv1.Hugepages, requestedMemory string) error {
+	if hugepages == nil {
+		return nil
+	}
+	pageSize, ok := hugepagesPageSizes[hugepages.PageSize]
+	if !ok {
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid hugepages page size %q, expected 2Mi or 1Gi", machineName, hugepages.PageSize)
+	}
+	memory, err := apiresource.ParseQuantity(requestedMemory)
+	if err != nil {
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid requested memory %q: %v", machineName, requestedMemory, err)
+	}
+	if memory.Value()%pageSize != 0 {
+		return machinecontroller.InvalidMachineConfiguration("%v: the requested memory %s is not a multiple of the hugepages page size %s", machineName, requestedMemory, hugepages.PageSize)
+	}
+	return nil
+}
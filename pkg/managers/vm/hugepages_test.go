@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateHugepages(t *testing.T) {
+	cases := []struct {
+		name            string
+		hugepages       *kubevirtproviderv1.Hugepages
+		requestedMemory string
+		wantErr         string
+	}{
+		{
+			name:            "No hugepages",
+			requestedMemory: "4097M",
+		},
+		{
+			name:            "2Mi pages",
+			hugepages:       &kubevirtproviderv1.Hugepages{PageSize: "2Mi"},
+			requestedMemory: "4098Mi",
+		},
+		{
+			name:            "1Gi pages",
+			hugepages:       &kubevirtproviderv1.Hugepages{PageSize: "1Gi"},
+			requestedMemory: "16Gi",
+		},
+		{
+			name:            "Invalid page size",
+			hugepages:       &kubevirtproviderv1.Hugepages{PageSize: "4Ki"},
+			requestedMemory: "16Gi",
+			wantErr:         `machine-test: invalid hugepages page size "4Ki", expected 2Mi or 1Gi`,
+		},
+		{
+			name:            "Partial page",
+			hugepages:       &kubevirtproviderv1.Hugepages{PageSize: "1Gi"},
+			requestedMemory: "4096M",
+			wantErr:         "machine-test: the requested memory 4096M is not a multiple of the hugepages page size 1Gi",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateHugepages("machine-test", tc.hugepages, tc.requestedMemory)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
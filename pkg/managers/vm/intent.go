@@ -0,0 +1,56 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// CreationIntentAnnotation holds the UID of the machine the provider created the VM for. A VM that already
+// exists with the UID of the machine was created by an attempt that didn't get to patch the machine, and is
+// adopted instead of failing the creation. A VM left behind by a former machine of the same name carries
+// another UID and isn't.
+const CreationIntentAnnotation = "kubevirt.io/creation-intent"
+
+// setCreationIntent sets the UID of the machine on the VM about to be created. The machines without UID,
+// which the API server never serves, don't get one.
+func (s *machineScope) setCreationIntent(vm *kubevirtapiv1.VirtualMachine) error {
+	intent := string(s.machine.UID)
+	if intent == "" || vm.Annotations[CreationIntentAnnotation] == intent {
+		return nil
+	}
+	if vm.Annotations == nil {
+		vm.Annotations = map[string]string{}
+	}
+	vm.Annotations[CreationIntentAnnotation] = intent
+	if err := render.SetLastAppliedConfiguration(vm); err != nil {
+		return fmt.Errorf("failed to set the creation intent of VM %s/%s: %w", vm.Namespace, vm.Name, err)
+	}
+	return nil
+}
+
+// createOrAdoptVM creates the VM, or returns the existing one when a previous attempt of the machine
+// created it. A VM without the creation intent of the machine isn't the one of the machine and fails the
+// creation.
+func (m *manager) createOrAdoptVM(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) (*kubevirtapiv1.VirtualMachine, error) {
+	createdVM, err := m.createUnderkubeVM(vm, machineScope)
+	if !apimachineryerrors.IsAlreadyExists(err) {
+		return createdVM, err
+	}
+	intent := vm.Annotations[CreationIntentAnnotation]
+	if intent == "" {
+		return nil, err
+	}
+	existingVM, getErr := m.getUnderkubeVM(vm.Name, vm.Namespace, machineScope)
+	if getErr != nil {
+		return nil, fmt.Errorf("VM %s/%s already exists, failed to get its creation intent: %w", vm.Namespace, vm.Name, getErr)
+	}
+	if existingIntent := existingVM.Annotations[CreationIntentAnnotation]; existingIntent != intent {
+		return nil, fmt.Errorf("VM %s/%s already exists with creation intent %q, not the %q of the machine: %w", vm.Namespace, vm.Name, existingIntent, intent, err)
+	}
+	klog.Infof("%s: VM %s/%s was created by a previous attempt, adopting it", machineScope.getMachineName(), vm.Namespace, vm.Name)
+	return existingVM, nil
+}
@@ -0,0 +1,220 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestSetCreationIntent(t *testing.T) {
+	cases := []struct {
+		name       string
+		uid        types.UID
+		wantIntent string
+	}{
+		{
+			name: "Machine without UID",
+		},
+		{
+			name:       "Machine UID",
+			uid:        "machine-uid",
+			wantIntent: "machine-uid",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			underkubeClientBuilder := func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockunderkube.NewMockClient(mockCtrl), nil
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.UID = tc.uid
+			machineScope, err := stubMachineScope(machine, mockoverkube.NewMockClient(mockCtrl), underkubeClientBuilder)
+			assert.NilError(t, err)
+			vm := stubVirtualMachine(t, machineScope)
+
+			assert.NilError(t, machineScope.setCreationIntent(vm))
+			assert.Equal(t, vm.Annotations[CreationIntentAnnotation], tc.wantIntent)
+			assert.Equal(t, render.AppliedConfiguration(vm).Annotations[CreationIntentAnnotation], tc.wantIntent)
+		})
+	}
+}
+
+func TestCreateOrAdoptVM(t *testing.T) {
+	alreadyExists := apimachineryerrors.NewAlreadyExists(schema.GroupResource{Resource: "virtualmachines"}, mahcineName)
+	cases := []struct {
+		name           string
+		createErr      error
+		noIntent       bool
+		existingIntent string
+		getErr         error
+		wantAdopted    bool
+		wantErr        string
+	}{
+		{
+			name: "Create the VM",
+		},
+		{
+			name:      "Fail on an existing VM without creation intent",
+			createErr: alreadyExists,
+			noIntent:  true,
+			wantErr:   `virtualmachines "machine-test" already exists`,
+		},
+		{
+			name:           "Fail to get the existing VM",
+			createErr:      alreadyExists,
+			existingIntent: "intent-test",
+			getErr:         errors.New("client error"),
+			wantErr:        "VM kubevirt-actuator-cluster/machine-test already exists, failed to get its creation intent: client error",
+		},
+		{
+			name:      "Fail on other errors",
+			createErr: errors.New("client error"),
+			wantErr:   "client error",
+		},
+		{
+			name:           "Adopt the VM of a previous attempt",
+			createErr:      alreadyExists,
+			existingIntent: "intent-test",
+			wantAdopted:    true,
+		},
+		{
+			name:           "Refuse the VM of another intent",
+			createErr:      alreadyExists,
+			existingIntent: "other-intent",
+			wantErr:        `VM kubevirt-actuator-cluster/machine-test already exists with creation intent "other-intent", not the "intent-test" of the machine: virtualmachines "machine-test" already exists`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			underkubeClientBuilder := func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope, err := stubMachineScope(machine, mockOverkube, underkubeClientBuilder)
+			assert.NilError(t, err)
+			vm := stubVirtualMachine(t, machineScope)
+			if !tc.noIntent {
+				vm.Annotations = map[string]string{CreationIntentAnnotation: "intent-test"}
+			}
+			existingVM := vm.DeepCopy()
+			existingVM.Annotations = map[string]string{CreationIntentAnnotation: tc.existingIntent}
+			existingVM.Status.Ready = true
+
+			if tc.createErr != nil {
+				mockUnderkube.EXPECT().CreateVirtualMachine(clusterID, vm).Return(nil, tc.createErr)
+			} else {
+				mockUnderkube.EXPECT().CreateVirtualMachine(clusterID, vm).Return(vm, nil)
+			}
+			if tc.getErr != nil {
+				mockUnderkube.EXPECT().GetVirtualMachine(clusterID, mahcineName, gomock.Any()).Return(nil, tc.getErr)
+			} else if tc.existingIntent != "" {
+				mockUnderkube.EXPECT().GetVirtualMachine(clusterID, mahcineName, gomock.Any()).Return(existingVM, nil)
+			}
+
+			m := &manager{}
+			createdVM, err := m.createOrAdoptVM(vm, machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, createdVM.Status.Ready, tc.wantAdopted)
+		})
+	}
+}
+
+func TestCreateAdoptsVMOfPreviousAttempt(t *testing.T) {
+	cases := []struct {
+		name           string
+		existingIntent string
+		wantErr        string
+	}{
+		{
+			name:           "Adopt the VM created for the machine",
+			existingIntent: "machine-uid",
+		},
+		{
+			name:           "Refuse the VM of a former machine",
+			existingIntent: "former-machine-uid",
+			wantErr:        `failed to create virtual machine: VM kubevirt-actuator-cluster/machine-test already exists with creation intent "former-machine-uid", not the "machine-uid" of the machine: virtualmachines "machine-test" already exists`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+			underkubeClientBuilder := func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.UID = "machine-uid"
+			machineScope, err := stubMachineScope(machine.DeepCopy(), mockOverkube, underkubeClientBuilder)
+			assert.NilError(t, err)
+			existingVM := stubVirtualMachine(t, machineScope)
+			existingVM.Annotations = map[string]string{CreationIntentAnnotation: tc.existingIntent}
+			vmi, _ := stubVmi(existingVM)
+
+			var created *kubevirtapiv1.VirtualMachine
+			mockUnderkube.EXPECT().CreateVirtualMachine(clusterID, gomock.Any()).DoAndReturn(func(_ string, vm *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
+				created = vm
+				return nil, apimachineryerrors.NewAlreadyExists(schema.GroupResource{Resource: "virtualmachines"}, mahcineName)
+			})
+			mockUnderkube.EXPECT().GetVirtualMachine(clusterID, mahcineName, gomock.Any()).Return(existingVM, nil)
+			mockUnderkube.EXPECT().ServerResourcesForGroupVersion(gomock.Any()).Return(stubAPIResources(), nil).AnyTimes()
+			mockUnderkube.EXPECT().GetNamespace(clusterID, gomock.Any()).Return(&corev1.Namespace{}, nil).AnyTimes()
+			mockUnderkube.EXPECT().GetKubeVirtVersion().Return("v0.29.2", nil).AnyTimes()
+			mockUnderkube.EXPECT().GetFeatureGates().Return([]string{dataVolumesFeatureGate}, nil).AnyTimes()
+			mockUnderkube.EXPECT().GetMutatingWebhookConfiguration(gomock.Any(), gomock.Any()).Return(&admissionregistrationv1beta1.MutatingWebhookConfiguration{}, nil).AnyTimes()
+			mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).Return(vmi, nil).AnyTimes()
+			mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+			mockUnderkube.EXPECT().AllowedNamespaces().Return(nil).AnyTimes()
+			mockUnderkube.EXPECT().ListPods(clusterID, gomock.Any()).Return(&corev1.PodList{}, nil).AnyTimes()
+			mockUnderkube.EXPECT().CreateService(gomock.Any(), clusterID).Return(stubService(mahcineName), nil).AnyTimes()
+			mockOverkube.EXPECT().GetSecret(workerUserDataSecretName, machine.Namespace).Return(stubSecret(), nil).AnyTimes()
+			var patched *machinev1.Machine
+			mockOverkube.EXPECT().PatchMachine(gomock.Any(), gomock.Any()).DoAndReturn(func(machine, _ *machinev1.Machine) error {
+				patched = machine.DeepCopy()
+				return nil
+			}).AnyTimes()
+			mockOverkube.EXPECT().StatusPatchMachine(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+			err = New(underkubeClientBuilder, mockOverkube, nil, true).Create(machine)
+			assert.Equal(t, created.Annotations[CreationIntentAnnotation], "machine-uid")
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+				assert.Assert(t, patched == nil || patched.Spec.ProviderID == nil)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Equal(t, *patched.Spec.ProviderID, "kubevirt:///"+machine.Namespace+"/"+mahcineName)
+		})
+	}
+}
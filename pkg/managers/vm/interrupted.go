@@ -0,0 +1,29 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// interruptedOperationMessage explains the interrupted outcome in the last operation of the machine
+const interruptedOperationMessage = "the provider shut down before the operation finished, it resumes on the next reconcile of the machine"
+
+// RecordInterruptedOperation records in the provider status that the provider shut down in the middle of
+// the operation, so the half-done operation shows until the next reconcile resumes it. The operations
+// only create and delete what is missing or left, so resuming one is running it again.
+func (m *manager) RecordInterruptedOperation(machine *machinev1.Machine, operationType kubevirtproviderv1.OperationType) error {
+	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder, m.providerConfig.Get(), m.credentialsFromSecrets)
+	if err != nil {
+		return err
+	}
+	klog.Warningf("%s: %s operation interrupted by the shutdown of the provider", machineScope.getMachineName(), operationType)
+	machineScope.machineProviderStatus.LastOperation = &kubevirtproviderv1.LastOperation{
+		Type:    operationType,
+		Time:    k8smetav1.Now(),
+		Outcome: kubevirtproviderv1.OperationInterrupted,
+		Error:   interruptedOperationMessage,
+	}
+	return machineScope.patchMachine()
+}
@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+)
+
+func TestRecordInterruptedOperation(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+	underkubeClientBuilder := func(overkube.Client, string, string) (underkube.Client, error) {
+		return mockUnderkube, nil
+	}
+
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	var statusPatched *machinev1.Machine
+	mockOverkube.EXPECT().StatusPatchMachine(gomock.Any(), gomock.Any()).DoAndReturn(func(machine, _ *machinev1.Machine) error {
+		statusPatched = machine.DeepCopy()
+		return nil
+	})
+
+	m := New(underkubeClientBuilder, mockOverkube, nil, true)
+	assert.NilError(t, m.RecordInterruptedOperation(machine, kubevirtproviderv1.CreateOperation))
+
+	providerStatus, err := kubevirtproviderv1.ProviderStatusFromRawExtension(statusPatched.Status.ProviderStatus)
+	assert.NilError(t, err)
+	assert.Equal(t, providerStatus.LastOperation.Type, kubevirtproviderv1.CreateOperation)
+	assert.Equal(t, providerStatus.LastOperation.Outcome, kubevirtproviderv1.OperationInterrupted)
+	assert.Equal(t, providerStatus.LastOperation.Error, interruptedOperationMessage)
+}
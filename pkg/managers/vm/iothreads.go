@@ -0,0 +1,27 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// validateIOThreads validates the IO threads policy, and that the dedicated IO thread disks are disks of
+// the VM listed once
+func validateIOThreads(machineName string, policy kubevirtproviderv1.IOThreadsPolicy, disks []kubevirtproviderv1.DiskName) error {
+	switch policy {
+	case "", kubevirtproviderv1.IOThreadsPolicyShared, kubevirtproviderv1.IOThreadsPolicyAuto:
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid IO threads policy %q, expected %s or %s", machineName, policy, kubevirtproviderv1.IOThreadsPolicyShared, kubevirtproviderv1.IOThreadsPolicyAuto)
+	}
+	seen := map[kubevirtproviderv1.DiskName]bool{}
+	for _, disk := range disks {
+		if disk != kubevirtproviderv1.BootDisk && disk != kubevirtproviderv1.CloudInitDisk {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid dedicated IO thread disk %q, expected %s or %s", machineName, disk, kubevirtproviderv1.BootDisk, kubevirtproviderv1.CloudInitDisk)
+		}
+		if seen[disk] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate dedicated IO thread disk %s", machineName, disk)
+		}
+		seen[disk] = true
+	}
+	return nil
+}
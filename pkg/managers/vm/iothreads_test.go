@@ -0,0 +1,56 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateIOThreads(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  kubevirtproviderv1.IOThreadsPolicy
+		disks   []kubevirtproviderv1.DiskName
+		wantErr string
+	}{
+		{
+			name: "No IO threads",
+		},
+		{
+			name:   "Shared",
+			policy: kubevirtproviderv1.IOThreadsPolicyShared,
+			disks:  []kubevirtproviderv1.DiskName{kubevirtproviderv1.BootDisk},
+		},
+		{
+			name:  "Dedicated without policy",
+			disks: []kubevirtproviderv1.DiskName{kubevirtproviderv1.BootDisk, kubevirtproviderv1.CloudInitDisk},
+		},
+		{
+			name:    "Invalid policy",
+			policy:  "dedicated",
+			wantErr: `machine-test: invalid IO threads policy "dedicated", expected shared or auto`,
+		},
+		{
+			name:    "Unknown disk",
+			policy:  kubevirtproviderv1.IOThreadsPolicyAuto,
+			disks:   []kubevirtproviderv1.DiskName{"Data"},
+			wantErr: `machine-test: invalid dedicated IO thread disk "Data", expected Boot or CloudInit`,
+		},
+		{
+			name:    "Duplicate disk",
+			disks:   []kubevirtproviderv1.DiskName{kubevirtproviderv1.BootDisk, kubevirtproviderv1.BootDisk},
+			wantErr: "machine-test: duplicate dedicated IO thread disk Boot",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateIOThreads("machine-test", tc.policy, tc.disks)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package vm
+
+import (
+	"fmt"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	corev1 "k8s.io/api/core/v1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// launcherCreatedByLabel is set by KubeVirt on the virt-launcher pods to the UID of their VMI
+const launcherCreatedByLabel = "kubevirt.io/created-by"
+
+// getLauncherPod returns the newest virt-launcher pod of the VMI, there are two of them during a
+// migration. It returns nil if the pod doesn't exist yet.
+func (m *manager) getLauncherPod(vmi *kubevirtapiv1.VirtualMachineInstance, machineScope *machineScope) (*corev1.Pod, error) {
+	pods, err := machineScope.underkubeClient.ListPods(vmi.Namespace, k8smetav1.ListOptions{
+		LabelSelector: labels.Set{launcherCreatedByLabel: string(vmi.UID)}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var launcherPod *corev1.Pod
+	for i := range pods.Items {
+		if launcherPod == nil || launcherPod.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) {
+			launcherPod = &pods.Items[i]
+		}
+	}
+	return launcherPod, nil
+}
+
+// launcherPodConditions maps the scheduling failures, image pull failures and OOM kills of the
+// virt-launcher pod to machine conditions, with the message of the pod
+func launcherPodConditions(pod *corev1.Pod) []kubevirtproviderv1.KubevirtMachineCondition {
+	scheduled := conditions.True(kubevirtproviderv1.LauncherScheduledCondition, "Scheduled")
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse {
+			scheduled = conditions.False(kubevirtproviderv1.LauncherScheduledCondition, "FailedScheduling", c.Message)
+		}
+	}
+
+	containerStatuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+
+	imagePulled := conditions.New(kubevirtproviderv1.LauncherImagePulledCondition, corev1.ConditionUnknown, "ContainersNotCreated", "")
+	if len(containerStatuses) > 0 {
+		imagePulled = conditions.True(kubevirtproviderv1.LauncherImagePulledCondition, "ImagePulled")
+	}
+
+	running := conditions.False(kubevirtproviderv1.LauncherRunningCondition, string(pod.Status.Phase), pod.Status.Message)
+	if pod.Status.Phase == corev1.PodRunning {
+		running = conditions.True(kubevirtproviderv1.LauncherRunningCondition, "Running")
+	}
+
+	for _, status := range containerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && isImagePullFailure(waiting.Reason, "") {
+			imagePulled = conditions.False(kubevirtproviderv1.LauncherImagePulledCondition, waiting.Reason, waiting.Message)
+		}
+		for _, terminated := range []*corev1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+			if terminated != nil && terminated.Reason == "OOMKilled" {
+				running = conditions.False(kubevirtproviderv1.LauncherRunningCondition, "OOMKilled",
+					fmt.Sprintf("container %s was OOMKilled", status.Name))
+			}
+		}
+	}
+
+	return []kubevirtproviderv1.KubevirtMachineCondition{scheduled, imagePulled, running}
+}
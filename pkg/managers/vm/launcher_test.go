@@ -0,0 +1,79 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestLauncherPodConditions(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   corev1.PodStatus
+		expected []string
+	}{
+		{
+			name:   "Running pod",
+			status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{{Name: "compute"}}},
+			expected: []string{
+				"True/Scheduled/",
+				"True/ImagePulled/",
+				"True/Running/",
+			},
+		},
+		{
+			name: "Unschedulable pod",
+			status: corev1.PodStatus{Phase: corev1.PodPending, Conditions: []corev1.PodCondition{
+				{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable, Message: "0/3 nodes are available: 3 Insufficient memory."},
+			}},
+			expected: []string{
+				"False/FailedScheduling/0/3 nodes are available: 3 Insufficient memory.",
+				"Unknown/ContainersNotCreated/",
+				"False/Pending/",
+			},
+		},
+		{
+			name: "Image pull back-off",
+			status: corev1.PodStatus{Phase: corev1.PodPending, ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "compute", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image \"virt-launcher\""}}},
+			}},
+			expected: []string{
+				"True/Scheduled/",
+				"False/ImagePullBackOff/Back-off pulling image \"virt-launcher\"",
+				"False/Pending/",
+			},
+		},
+		{
+			name: "OOM killed",
+			status: corev1.PodStatus{Phase: corev1.PodFailed, ContainerStatuses: []corev1.ContainerStatus{
+				{Name: "compute", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}},
+			}},
+			expected: []string{
+				"True/Scheduled/",
+				"True/ImagePulled/",
+				"False/OOMKilled/container compute was OOMKilled",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var conditions []string
+			for _, c := range launcherPodConditions(&corev1.Pod{Status: tc.status}) {
+				conditions = append(conditions, string(c.Status)+"/"+c.Reason+"/"+c.Message)
+			}
+			assert.DeepEqual(t, conditions, tc.expected)
+		})
+	}
+
+	types := []kubevirtproviderv1.KubevirtMachineConditionType{}
+	for _, c := range launcherPodConditions(&corev1.Pod{}) {
+		types = append(types, c.Type)
+	}
+	assert.DeepEqual(t, types, []kubevirtproviderv1.KubevirtMachineConditionType{
+		kubevirtproviderv1.LauncherScheduledCondition,
+		kubevirtproviderv1.LauncherImagePulledCondition,
+		kubevirtproviderv1.LauncherRunningCondition,
+	})
+}
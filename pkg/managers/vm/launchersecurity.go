@@ -0,0 +1,33 @@
+package vm
+
+import (
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+const (
+	seccompProfileRuntimeDefault  = "runtime/default"
+	seccompProfileUnconfined      = "unconfined"
+	seccompProfileLocalhostPrefix = "localhost/"
+)
+
+// validateLauncherSecurity validates the seccomp profile of the virt-launcher pod the way the underkube
+// validates the seccomp annotation
+func validateLauncherSecurity(machineName string, security *kubevirtproviderv1.LauncherSecurity) error {
+	if security == nil {
+		return nil
+	}
+	switch profile := security.SeccompProfile; {
+	case profile == "", profile == seccompProfileRuntimeDefault, profile == seccompProfileUnconfined:
+	case strings.HasPrefix(profile, seccompProfileLocalhostPrefix):
+		name := strings.TrimPrefix(profile, seccompProfileLocalhostPrefix)
+		if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, "..") {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid seccomp profile %q, the localhost profile must be a relative path without ..", machineName, profile)
+		}
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid seccomp profile %q, expected %s, %s or %s<profile>", machineName, profile, seccompProfileRuntimeDefault, seccompProfileUnconfined, seccompProfileLocalhostPrefix)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateLauncherSecurity(t *testing.T) {
+	cases := []struct {
+		name     string
+		security *kubevirtproviderv1.LauncherSecurity
+		wantErr  string
+	}{
+		{
+			name: "No launcher security",
+		},
+		{
+			name:     "Runtime default seccomp profile",
+			security: &kubevirtproviderv1.LauncherSecurity{SeccompProfile: "runtime/default"},
+		},
+		{
+			name:     "Localhost seccomp profile",
+			security: &kubevirtproviderv1.LauncherSecurity{SeccompProfile: "localhost/profiles/kubevirt.json"},
+		},
+		{
+			name:     "Unknown seccomp profile",
+			security: &kubevirtproviderv1.LauncherSecurity{SeccompProfile: "docker/strict"},
+			wantErr:  `machine-test: invalid seccomp profile "docker/strict", expected runtime/default, unconfined or localhost/<profile>`,
+		},
+		{
+			name:     "Localhost seccomp profile out of the profiles directory",
+			security: &kubevirtproviderv1.LauncherSecurity{SeccompProfile: "localhost/../etc/profile.json"},
+			wantErr:  `machine-test: invalid seccomp profile "localhost/../etc/profile.json", the localhost profile must be a relative path without ..`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLauncherSecurity("machine-test", tc.security)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
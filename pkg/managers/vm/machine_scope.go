@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/equality"
 	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
 
 	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
@@ -11,6 +12,9 @@ import (
 	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	apiresource "k8s.io/apimachinery/pkg/api/resource"
@@ -18,7 +22,6 @@ import (
 
 	"k8s.io/klog"
 	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
-	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 )
 
 type machineState string
@@ -27,20 +30,18 @@ const (
 	vmNotCreated      machineState = "vmNotCreated"
 	vmCreatedNotReady machineState = "vmWasCreatedButNotReady"
 	vmCreatedAndReady machineState = "vmWasCreatedAndReady"
+	vmPaused          machineState = "Paused"
 )
 
+// invalidCredentialsMachineError is the machine error reason used when the underkube kubeconfig secret is
+// missing or invalid, so it can be told apart from the errors of the VM
+const invalidCredentialsMachineError machinev1.MachineStatusError = "InvalidCredentials"
+
 const (
-	pvcRequestsStorage                = "35Gi"
-	defaultRequestedMemory            = "2048M"
-	defaultPersistentVolumeAccessMode = corev1.ReadWriteOnce
-	defaultDataVolumeDiskName         = "datavolumedisk1"
-	defaultCloudInitVolumeDiskName    = "cloudinitdisk"
-	defaultBootVolumeDiskName         = "bootvolume"
-	kubevirtIdAnnotationKey           = "VmId"
-	userDataKey                       = "userData"
-	defaultBus                        = "virtio"
-	APIVersion                        = "kubevirt.io/v1alpha3"
-	Kind                              = "VirtualMachine"
+	kubevirtIdAnnotationKey = "VmId"
+	userDataKey             = "userData"
+	// defaultServiceDomain is the DNS domain of the underkube services when the provider config sets none
+	defaultServiceDomain = "cluster.local"
 )
 
 type machineScope struct {
@@ -50,9 +51,13 @@ type machineScope struct {
 	originMachineCopy     *machinev1.Machine
 	machineProviderSpec   *kubevirtproviderv1.KubevirtMachineProviderSpec
 	machineProviderStatus *kubevirtproviderv1.KubevirtMachineProviderStatus
+	// providerConfig holds the defaults of the provider spec
+	providerConfig kubevirtproviderv1.KubevirtProviderConfigSpec
+	// credentialsFromSecrets is true when the machines need a credentials secret
+	credentialsFromSecrets bool
 }
 
-func newMachineScope(machine *machinev1.Machine, overkubeClient overkube.Client, underkubeClientBuilder underkube.ClientBuilderFuncType) (*machineScope, error) {
+func newMachineScope(machine *machinev1.Machine, overkubeClient overkube.Client, underkubeClientBuilder underkube.ClientBuilderFuncType, providerConfig kubevirtproviderv1.KubevirtProviderConfigSpec, credentialsFromSecrets bool) (*machineScope, error) {
 	if err := validateMachine(*machine); err != nil {
 		return nil, fmt.Errorf("%v: failed validating machine provider spec: %w", machine.GetName(), err)
 	}
@@ -67,75 +72,234 @@ func newMachineScope(machine *machinev1.Machine, overkubeClient overkube.Client,
 		return nil, machinecontroller.InvalidMachineConfiguration("failed to get machine provider status: %v", err.Error())
 	}
 
-	kubevirtClient, err := underkubeClientBuilder(overkubeClient, providerSpec.UnderKubeconfigSecretName, machine.GetNamespace())
+	scope := &machineScope{
+		overkubeClient:         overkubeClient,
+		machine:                machine,
+		originMachineCopy:      machine.DeepCopy(),
+		machineProviderSpec:    providerSpec,
+		machineProviderStatus:  providerStatus,
+		providerConfig:         providerConfig,
+		credentialsFromSecrets: credentialsFromSecrets,
+	}
+
+	secretName, err := providerconfig.UnderKubeconfigSecretName(machine, providerSpec, providerConfig)
+	if err != nil {
+		return nil, machinecontroller.InvalidMachineConfiguration("%v: %v", machine.GetName(), err)
+	}
+	// The VM of a machine with a provider ID is on the underkube of the secret it was created with
+	recordedSecretName := providerStatus.UnderKubeconfigSecretName
+	if machine.Spec.ProviderID != nil && *machine.Spec.ProviderID != "" && recordedSecretName != "" && recordedSecretName != secretName {
+		return nil, machinecontroller.InvalidMachineConfiguration("%v: the credentials secret can't change from %s to %s once the machine has a provider ID, revert the %s annotation",
+			machine.GetName(), recordedSecretName, secretName, providerconfig.CredentialsSecretAnnotation)
+	}
+	providerStatus.UnderKubeconfigSecretName = secretName
+	kubevirtClient, err := underkubeClientBuilder(overkubeClient, secretName, machine.GetNamespace())
+	if underkube.IsCredentialsError(err) {
+		scope.setInvalidCredentials(err)
+		return nil, fmt.Errorf("failed to create a KubeVirt client: %w", err)
+	}
 	if err != nil {
 		return nil, machinecontroller.InvalidMachineConfiguration("failed to create aKubeVirt client: %v", err.Error())
 	}
+	scope.underkubeClient = kubevirtClient
+	scope.clearInvalidCredentials()
 
-	return &machineScope{
-		underkubeClient:       kubevirtClient,
-		overkubeClient:        overkubeClient,
-		machine:               machine,
-		originMachineCopy:     machine.DeepCopy(),
-		machineProviderSpec:   providerSpec,
-		machineProviderStatus: providerStatus,
-	}, nil
+	return scope, nil
 }
-func getVMNamespace(machine *machinev1.Machine) string {
-	namespace, ok := getClusterID(machine)
-	if !ok {
-		namespace = machine.Namespace
+
+// setInvalidCredentials records the credentials error in the machine status right away, since there is
+// no operation to run, and so no deferred patch, without a client
+func (s *machineScope) setInvalidCredentials(err error) {
+	s.setCondition(conditions.Failed(kubevirtproviderv1.CredentialsValidCondition, string(invalidCredentialsMachineError), err))
+	reason := invalidCredentialsMachineError
+	message := err.Error()
+	s.machine.Status.ErrorReason = &reason
+	s.machine.Status.ErrorMessage = &message
+	if patchErr := s.patchMachine(); patchErr != nil {
+		klog.Errorf("%s: failed to record the invalid credentials: %v", s.getMachineName(), patchErr)
+	}
+}
+
+// clearInvalidCredentials resets the credentials error once the secret is fixed
+func (s *machineScope) clearInvalidCredentials() {
+	if condition := conditions.Find(s.machineProviderStatus.MachineConditions, kubevirtproviderv1.CredentialsValidCondition); condition != nil {
+		s.setCondition(conditions.True(kubevirtproviderv1.CredentialsValidCondition, "CredentialsValid"))
+	}
+	if s.machine.Status.ErrorReason != nil && *s.machine.Status.ErrorReason == invalidCredentialsMachineError {
+		s.machine.Status.ErrorReason = nil
+		s.machine.Status.ErrorMessage = nil
+	}
+}
+
+// getVMNamespace returns the underkube namespace of the VM, the one recorded once it was created
+func (s *machineScope) getVMNamespace() string {
+	return render.MachineVMNamespace(s.machine, s.machineProviderSpec, s.machineProviderStatus, s.underkubeClient.DefaultNamespace())
+}
+
+// renderDefaults returns the defaults the VM of the machine is rendered with
+func (s *machineScope) renderDefaults() render.Defaults {
+	return render.Defaults{
+		Namespace:        s.getVMNamespace(),
+		RequestedMemory:  s.providerConfig.DefaultRequestedMemory,
+		RequestedStorage: s.providerConfig.DefaultRequestedStorage,
+		StorageClassName: s.providerConfig.DefaultStorageClassName,
+	}
+}
+
+// validateVMNamespace rejects a provider spec namespace the credentials don't allow, so a machine can't create
+// its VM in the namespace of another tenant sharing the underkube
+func (s *machineScope) validateVMNamespace() error {
+	namespace := s.machineProviderSpec.Namespace
+	if namespace == "" || namespace == s.underkubeClient.DefaultNamespace() {
+		return nil
+	}
+	for _, allowed := range s.underkubeClient.AllowedNamespaces() {
+		if allowed == namespace || allowed == "*" {
+			return nil
+		}
+	}
+	return machinecontroller.InvalidMachineConfiguration("%v: namespace %q is not allowed by the underkube credentials, it must be listed in the %s annotation of the credentials secret",
+		s.machine.GetName(), namespace, underkube.AllowedNamespacesAnnotation)
+}
+
+// assertCredentialsSecret checks a credentials secret resolves for the machine, unless the underkube clients
+// are built without one
+func (s *machineScope) assertCredentialsSecret() error {
+	if !s.credentialsFromSecrets {
+		return nil
+	}
+	secretName, err := providerconfig.UnderKubeconfigSecretName(s.machine, s.machineProviderSpec, s.providerConfig)
+	if err != nil {
+		return machinecontroller.InvalidMachineConfiguration("%v: %v", s.machine.GetName(), err)
 	}
-	return namespace
+	if secretName == "" {
+		return machinecontroller.InvalidMachineConfiguration("%v: missing value for UnderKubeconfigSecretName, and the KubevirtProviderConfig sets no defaultUnderKubeconfigSecretName", s.machine.GetName())
+	}
+	return nil
 }
+
 func (s *machineScope) assertMandatoryParams() error {
 	switch {
 	case s.machineProviderSpec.SourcePvcName == "":
 		return machinecontroller.InvalidMachineConfiguration("%v: missing value for SourcePvcName", s.machine.GetName())
-	case s.machineProviderSpec.UnderKubeconfigSecretName == "":
-		return machinecontroller.InvalidMachineConfiguration("%v: missing value for UnderKubeconfigSecretName", s.machine.GetName())
 	case s.machineProviderSpec.IgnitionSecretName == "":
 		return machinecontroller.InvalidMachineConfiguration("%v: missing value for IgnitionSecretName", s.machine.GetName())
 	default:
-		return nil
+		if err := s.assertCredentialsSecret(); err != nil {
+			return err
+		}
+		if err := s.validateVMNamespace(); err != nil {
+			return err
+		}
+		if err := s.validateResourceOverrides(); err != nil {
+			return err
+		}
+		if err := validateInterfaces(s.machine.GetName(), s.machineProviderSpec.Interfaces, s.machineProviderSpec.PrimaryInterface); err != nil {
+			return err
+		}
+		if err := validateMacAddresses(s.machine.GetName(), s.machineProviderSpec.MacAddressAllocation, s.machineProviderSpec.Interfaces); err != nil {
+			return err
+		}
+		if err := validateNetworkData(s.machine.GetName(), s.machineProviderSpec.NetworkData, s.machineProviderSpec.NetworkDataSecretName); err != nil {
+			return err
+		}
+		if err := validateIPFamilies(s.machine.GetName(), s.machineProviderSpec.IPFamilies); err != nil {
+			return err
+		}
+		if err := validateHealthCheck(s.machine.GetName(), s.machineProviderSpec.HealthCheck); err != nil {
+			return err
+		}
+		if err := validateExpose(s.machine.GetName(), s.machineProviderSpec.Expose); err != nil {
+			return err
+		}
+		if err := validateDeadlines(s.machine.GetName(), s.machineProviderSpec.Deadlines); err != nil {
+			return err
+		}
+		if err := validateTerminationGracePeriod(s.machine.GetName(), s.machineProviderSpec); err != nil {
+			return err
+		}
+		if err := validateGPUs(s.machine.GetName(), s.machineProviderSpec.GPUs, s.machineProviderSpec.MediatedDevices); err != nil {
+			return err
+		}
+		if err := validateCPU(s.machine.GetName(), s.machineProviderSpec.CPU, render.RequestedCPU(s.machine, s.machineProviderSpec)); err != nil {
+			return err
+		}
+		if err := validateIOThreads(s.machine.GetName(), s.machineProviderSpec.IOThreadsPolicy, s.machineProviderSpec.DedicatedIOThreadDisks); err != nil {
+			return err
+		}
+		if err := validateDiskBuses(s.machine.GetName(), s.machineProviderSpec.DiskBuses, s.machineProviderSpec.DedicatedIOThreadDisks); err != nil {
+			return err
+		}
+		if err := validateConfigVolumes(s.machine.GetName(), s.machineProviderSpec.ConfigVolumes); err != nil {
+			return err
+		}
+		if err := validateBootOrders(s.machine.GetName(), s.machineProviderSpec.DiskBootOrders, s.machineProviderSpec.Interfaces); err != nil {
+			return err
+		}
+		if err := validateHugepages(s.machine.GetName(), s.machineProviderSpec.Hugepages, render.RequestedMemory(s.machine, s.machineProviderSpec, render.Defaults{RequestedMemory: s.providerConfig.DefaultRequestedMemory})); err != nil {
+			return err
+		}
+		if err := validateMachineType(s.machine.GetName(), s.machineProviderSpec.MachineType); err != nil {
+			return err
+		}
+		if err := validateFirmware(s.machine.GetName(), s.machineProviderSpec.Firmware); err != nil {
+			return err
+		}
+		if err := validateTolerations(s.machine.GetName(), s.machineProviderSpec.Tolerations); err != nil {
+			return err
+		}
+		if err := validateAffinity(s.machine.GetName(), s.machineProviderSpec.Affinity); err != nil {
+			return err
+		}
+		if err := validateEvictionStrategy(s.machine.GetName(), s.machineProviderSpec); err != nil {
+			return err
+		}
+		if err := validateRunStrategy(s.machine.GetName(), s.machineProviderSpec.RunStrategy); err != nil {
+			return err
+		}
+		if err := validateAnnotationProfiles(s.machine.GetName(), s.machineProviderSpec.AnnotationProfiles); err != nil {
+			return err
+		}
+		if err := validateLauncherSecurity(s.machine.GetName(), s.machineProviderSpec.LauncherSecurity); err != nil {
+			return err
+		}
+		if err := validateMetadataPropagation(s.machine.GetName(), s.machineProviderSpec.MetadataPropagation); err != nil {
+			return err
+		}
+		if err := validateServiceMode(s.machine.GetName(), s.serviceMode(), s.machine.Labels[machineSetLabel], s.machineProviderSpec.Expose); err != nil {
+			return err
+		}
+		if err := validateReadinessGates(s.machine.GetName(), s.machineProviderSpec.ReadinessGates, s.serviceMode()); err != nil {
+			return err
+		}
+		return validateIgnoredFields(s.machine.GetName(), s.machineProviderSpec.IgnoredFields)
 	}
 }
-func (s *machineScope) createVirtualMachineFromMachine() (*kubevirtapiv1.VirtualMachine, error) {
-	if err := s.assertMandatoryParams(); err != nil {
-		return nil, err
-	}
-	runAlways := kubevirtapiv1.RunStrategyAlways
-	// use getClusterID as a namespace
-	// TODO: if there isnt a cluster id - need to return an error
-	namespace := getVMNamespace(s.machine)
 
-	vmiTemplate, err := s.buildVMITemplate(namespace)
-	if err != nil {
-		return nil, err
+func (s *machineScope) validateResourceOverrides() error {
+	if s.machineProviderSpec.RequestedStorage != "" {
+		if _, err := apiresource.ParseQuantity(s.machineProviderSpec.RequestedStorage); err != nil {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid value %q for RequestedStorage: %v", s.machine.GetName(), s.machineProviderSpec.RequestedStorage, err)
+		}
 	}
 
-	virtualMachine := kubevirtapiv1.VirtualMachine{
-		Spec: kubevirtapiv1.VirtualMachineSpec{
-			RunStrategy: &runAlways,
-			DataVolumeTemplates: []cdiv1.DataVolume{
-				*buildBootVolumeDataVolumeTemplate(s.machine.GetName(), s.machineProviderSpec.SourcePvcName, namespace, s.machineProviderSpec.SourcePvcNamespace, s.machineProviderSpec.StorageClassName),
-			},
-			Template: vmiTemplate,
-		},
+	for _, annotation := range []string{render.RequestedCPUAnnotation, render.RequestedMemoryAnnotation} {
+		value, ok := s.machine.Annotations[annotation]
+		if !ok {
+			continue
+		}
+		if _, err := apiresource.ParseQuantity(value); err != nil {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid value %q for annotation %s: %v", s.machine.GetName(), value, annotation, err)
+		}
 	}
+	return nil
+}
 
-	virtualMachine.APIVersion = APIVersion
-	virtualMachine.Kind = Kind
-	virtualMachine.ObjectMeta = metav1.ObjectMeta{
-		Name:            s.machine.Name,
-		Namespace:       namespace,
-		Labels:          s.machine.Labels,
-		Annotations:     s.machine.Annotations,
-		OwnerReferences: nil,
-		ClusterName:     s.machine.ClusterName,
+func (s *machineScope) createVirtualMachineFromMachine() (*kubevirtapiv1.VirtualMachine, error) {
+	if err := s.assertMandatoryParams(); err != nil {
+		return nil, err
 	}
-
-	return &virtualMachine, nil
+	return render.RenderVirtualMachine(s.machine, s.machineProviderSpec, s.renderDefaults())
 }
 
 func (s *machineScope) getMachineName() string {
@@ -171,98 +335,12 @@ func (s *machineScope) updateAllowed() bool {
 	return s.machine.Spec.ProviderID != nil && *s.machine.Spec.ProviderID != "" && (s.machine.Status.LastUpdated == nil || s.machine.Status.LastUpdated.Add(requeueAfterSeconds*time.Second).After(time.Now()))
 }
 
-func buildDataVolumeDiskName(virtualMachineName string) string {
-	return buildVolumeName(virtualMachineName, defaultDataVolumeDiskName)
-}
-func buildCloudInitVolumeDiskName(virtualMachineName string) string {
-	return buildVolumeName(virtualMachineName, defaultCloudInitVolumeDiskName)
-}
-
-func buildBootVolumeName(virtualMachineName string) string {
-	return buildVolumeName(virtualMachineName, defaultBootVolumeDiskName)
-}
-
-func buildVolumeName(virtualMachineName, suffixVolumeName string) string {
-	return fmt.Sprintf("%s-%s", virtualMachineName, suffixVolumeName)
-}
-
-func (s *machineScope) buildVMITemplate(namespace string) (*kubevirtapiv1.VirtualMachineInstanceTemplateSpec, error) {
-	virtualMachineName := s.machine.GetName()
-
-	template := &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{}
-
-	template.ObjectMeta = metav1.ObjectMeta{
-		Labels: map[string]string{"kubevirt.io/vm": virtualMachineName, "name": virtualMachineName},
-	}
-
-	//userData, err := s.getUserData(namespace)
-	//if err != nil {
-	//	return nil, err
-	//}
-
-	template.Spec = kubevirtapiv1.VirtualMachineInstanceSpec{}
-	template.Spec.Volumes = []kubevirtapiv1.Volume{
-		{
-			Name: buildDataVolumeDiskName(virtualMachineName),
-			VolumeSource: kubevirtapiv1.VolumeSource{
-				DataVolume: &kubevirtapiv1.DataVolumeSource{
-					Name: buildBootVolumeName(virtualMachineName),
-				},
-			},
-		},
-		{
-			Name: buildCloudInitVolumeDiskName(virtualMachineName),
-			VolumeSource: kubevirtapiv1.VolumeSource{
-				CloudInitConfigDrive: &kubevirtapiv1.CloudInitConfigDriveSource{
-					UserDataSecretRef: &corev1.LocalObjectReference{
-						Name: s.machineProviderSpec.IgnitionSecretName,
-					},
-					// TODO: Use UserData after fixing the blocking port
-					//UserData: userData,
-				},
-			},
-		},
-	}
-
-	template.Spec.Domain = kubevirtapiv1.DomainSpec{}
-
-	requests := corev1.ResourceList{}
-
-	requestedMemory := s.machineProviderSpec.RequestedMemory
-	if requestedMemory == "" {
-		requestedMemory = defaultRequestedMemory
-	}
-	requests[corev1.ResourceMemory] = apiresource.MustParse(requestedMemory)
-
-	if s.machineProviderSpec.RequestedCPU != "" {
-		requests[corev1.ResourceCPU] = apiresource.MustParse(s.machineProviderSpec.RequestedCPU)
-	}
-
-	template.Spec.Domain.Resources = kubevirtapiv1.ResourceRequirements{
-		Requests: requests,
-	}
-	template.Spec.Domain.Devices = kubevirtapiv1.Devices{
-		Disks: []kubevirtapiv1.Disk{
-			{
-				Name: buildDataVolumeDiskName(virtualMachineName),
-				DiskDevice: kubevirtapiv1.DiskDevice{
-					Disk: &kubevirtapiv1.DiskTarget{
-						Bus: defaultBus,
-					},
-				},
-			},
-			{
-				Name: buildCloudInitVolumeDiskName(virtualMachineName),
-				DiskDevice: kubevirtapiv1.DiskDevice{
-					Disk: &kubevirtapiv1.DiskTarget{
-						Bus: defaultBus,
-					},
-				},
-			},
-		},
-	}
-
-	return template, nil
+func (s *machineScope) buildServiceDNSName(serviceName, namespace string) string {
+	serviceDomain := s.providerConfig.ServiceDomain
+	if serviceDomain == "" {
+		serviceDomain = defaultServiceDomain
+	}
+	return fmt.Sprintf("%s.%s.svc.%s", serviceName, namespace, serviceDomain)
 }
 
 func (s *machineScope) getUserData(namespace string) (string, error) {
@@ -282,51 +360,14 @@ func (s *machineScope) getUserData(namespace string) (string, error) {
 	return userData, nil
 }
 
-func buildBootVolumeDataVolumeTemplate(virtualMachineName, pvcName, dvNamespace, pvcNamespace, storageClassName string) *cdiv1.DataVolume {
-
-	persistentVolumeClaimSpec := corev1.PersistentVolumeClaimSpec{
-		// TODO: Need to determine it by the type of storage class: pvc.Spec.StorageClassName
-		AccessModes: []corev1.PersistentVolumeAccessMode{
-			defaultPersistentVolumeAccessMode,
-		},
-		// TODO: Where to get it?? - add as a list
-		Resources: corev1.ResourceRequirements{
-			Requests: corev1.ResourceList{
-				corev1.ResourceStorage: apiresource.MustParse(pvcRequestsStorage),
-			},
-		},
-	}
-	if storageClassName != "" {
-		persistentVolumeClaimSpec.StorageClassName = &storageClassName
-	}
-
-	return &cdiv1.DataVolume{
-		TypeMeta: metav1.TypeMeta{APIVersion: cdiv1.SchemeGroupVersion.String()},
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      buildBootVolumeName(virtualMachineName),
-			Namespace: dvNamespace,
-		},
-		Spec: cdiv1.DataVolumeSpec{
-			Source: cdiv1.DataVolumeSource{
-				PVC: &cdiv1.DataVolumeSourcePVC{
-					Name:      pvcName,
-					Namespace: dvNamespace,
-					//Namespace: pvcNamespace,
-				},
-			},
-			PVC: &persistentVolumeClaimSpec,
-		},
-	}
-}
-
-func (s *machineScope) SyncMachineFromVm(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance) error {
+func (s *machineScope) SyncMachineFromVm(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance, service *corev1.Service) error {
 	s.setProviderID(vm)
 
-	if err := s.setMachineAnnotationsAndLabels(vm); err != nil {
+	if err := s.setMachineAnnotationsAndLabels(vm, vmi); err != nil {
 		return fmt.Errorf("failed to set machine cloud provider specifics: %w", err)
 	}
 
-	if err := s.setProviderStatus(vm, vmi, conditionSuccess()); err != nil {
+	if err := s.setProviderStatus(vm, vmi, service); err != nil {
 		return machinecontroller.InvalidMachineConfiguration("failed to set machine provider status: %v", err.Error())
 	}
 
@@ -334,7 +375,7 @@ func (s *machineScope) SyncMachineFromVm(vm *kubevirtapiv1.VirtualMachine, vmi *
 	return nil
 }
 
-func (s *machineScope) setMachineAnnotationsAndLabels(vm *kubevirtapiv1.VirtualMachine) error {
+func (s *machineScope) setMachineAnnotationsAndLabels(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance) error {
 	if vm == nil {
 		return nil
 	}
@@ -359,6 +400,9 @@ func (s *machineScope) setMachineAnnotationsAndLabels(vm *kubevirtapiv1.VirtualM
 		if vm.Status.Ready {
 			vmState = vmCreatedAndReady
 		}
+		if isVMIPaused(vmi) {
+			vmState = vmPaused
+		}
 	}
 
 	s.machine.ObjectMeta.Annotations[kubevirtIdAnnotationKey] = string(vmId)
@@ -379,63 +423,177 @@ func (s *machineScope) patchMachine() error {
 	}
 	s.machine.Status.ProviderStatus = providerStatus
 
-	// patch machine
+	// The spec/metadata and the status are written separately, and only when they changed, so a status
+	// update doesn't race with the machineset and node-link controllers writing the labels and annotations
 	statusCopy := *s.machine.Status.DeepCopy()
-	if err := s.overkubeClient.PatchMachine(s.machine, s.originMachineCopy); err != nil {
-		klog.Errorf("Failed to patch machine %q: %v", s.machine.GetName(), err)
-		return err
+	if !equality.Semantic.DeepEqual(s.machine.ObjectMeta, s.originMachineCopy.ObjectMeta) ||
+		!equality.Semantic.DeepEqual(s.machine.Spec, s.originMachineCopy.Spec) {
+		// Leave the status out of the patch, the main resource ignores it anyway
+		s.machine.Status = *s.originMachineCopy.Status.DeepCopy()
+		if err := s.overkubeClient.PatchMachine(s.machine, s.originMachineCopy); err != nil {
+			klog.Errorf("Failed to patch machine %q: %v", s.machine.GetName(), err)
+			s.machine.Status = statusCopy
+			return err
+		}
 	}
 
 	s.machine.Status = statusCopy
 
-	// patch status
-	if err := s.overkubeClient.StatusPatchMachine(s.machine, s.originMachineCopy); err != nil {
-		klog.Errorf("Failed to patch machine status %q: %v", s.machine.GetName(), err)
-		return err
+	if !equality.Semantic.DeepEqual(s.machine.Status, s.originMachineCopy.Status) {
+		if err := s.overkubeClient.StatusPatchMachine(s.machine, s.originMachineCopy); err != nil {
+			klog.Errorf("Failed to patch machine status %q: %v", s.machine.GetName(), err)
+			return err
+		}
+		s.observeProvisioningPhases()
 	}
 
 	return nil
 }
 
-func machineProviderStatusFromVirtualMachine(virtualMachine *kubevirtapiv1.VirtualMachine) *kubevirtproviderv1.KubevirtMachineProviderStatus {
-	return &kubevirtproviderv1.KubevirtMachineProviderStatus{
-		VirtualMachineStatus: virtualMachine.Status,
-	}
-}
-
-func (s *machineScope) setProviderStatus(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance, condition kubevirtapiv1.VirtualMachineCondition) error {
+func (s *machineScope) setProviderStatus(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance, service *corev1.Service) error {
 	if vm == nil {
 		klog.Infof("%s: couldn't calculate KubeVirt status - the provided vm is empty", s.machine.GetName())
 		return nil
 	}
 	klog.Infof("%s: Updating status", s.machine.GetName())
 	var networkAddresses []corev1.NodeAddress
-	s.machineProviderStatus = machineProviderStatusFromVirtualMachine(vm)
+	// Only the VM status is replaced, the conditions and the failure are carried over so their transition
+	// times survive the sync
+	if s.machineProviderStatus == nil {
+		s.machineProviderStatus = &kubevirtproviderv1.KubevirtMachineProviderStatus{}
+	}
+	s.machineProviderStatus.VirtualMachineStatus = vm.Status
+	s.machineProviderStatus.VMNamespace = vm.Namespace
+	for _, condition := range vmConditions(vm, vmi) {
+		s.setCondition(condition)
+	}
+	s.setFailure(vmFailureReason(vm, vmi))
+	if vm.Status.Ready {
+		s.machineProviderStatus.BootCompleted = true
+	}
+	if reason, message := elapsedDeadline(getDeadlines(s.machineProviderSpec), vm, vmi, s.machine, s.machineProviderStatus.BootCompleted, time.Now()); reason != "" {
+		s.setFailed(reason, message)
+	}
+	if vmi != nil {
+		s.setBootProgress(vmi)
+	}
+	s.setProvisioningTimeline(vmi)
 
 	// update nodeAddresses
 	networkAddresses = append(networkAddresses, corev1.NodeAddress{Address: vm.Name, Type: corev1.NodeInternalDNS})
+	// The per-machine service gives the VM a stable name in the underkube cluster DNS, the shared service
+	// of the machineset under the hostname of its endpoint
+	if service != nil && s.isSharedServiceMode() {
+		networkAddresses = append(networkAddresses, corev1.NodeAddress{Address: s.buildServiceDNSName(vm.Name+"."+service.Name, vm.Namespace), Type: corev1.NodeInternalDNS})
+	} else if service != nil {
+		networkAddresses = append(networkAddresses, corev1.NodeAddress{Address: s.buildServiceDNSName(service.Name, vm.Namespace), Type: corev1.NodeInternalDNS})
+	}
 
 	// VMI might be nil while the vm is in creating state but the vmi wasn't created yet.
 	//For example when colning the VM's dv
 	if vmi != nil {
 		// Copy specific addresses - only node addresses.
-		addresses, err := extractNodeAddresses(vmi)
+		primaryInterfaceName := ""
+		if primaryInterface := getPrimaryInterface(s.machineProviderSpec); primaryInterface != nil {
+			primaryInterfaceName = primaryInterface.Name
+		}
+		addresses, err := extractNodeAddresses(vmi, primaryInterfaceName)
 		if err != nil {
 			klog.Errorf("%s: Error extracting vm IP addresses: %v", s.machine.GetName(), err)
 			return err
 		}
+		sortAddressesByIPFamily(addresses, s.machineProviderSpec.IPFamilies)
 		networkAddresses = append(networkAddresses, addresses...)
 	}
 
 	klog.Infof("%s: finished calculating KubeVirt status", s.machine.GetName())
 
 	s.machine.Status.Addresses = networkAddresses
-	// TODO: update the phase of the machine
-	//s.machine.Status.Phase = setKubevirtMachineProviderCondition(condition, vm.Status.Conditions)
 
 	return nil
 }
 
+// setLastOperation records the outcome of the operation in the provider status. The time is only stamped
+// when the type or the outcome of the operation changed, so repeating the same operation doesn't change the
+// status and patch it on every reconcile.
+func (s *machineScope) setLastOperation(operationType kubevirtproviderv1.OperationType, err error) {
+	if s.machineProviderStatus == nil {
+		s.machineProviderStatus = &kubevirtproviderv1.KubevirtMachineProviderStatus{}
+	}
+	lastOperation := &kubevirtproviderv1.LastOperation{
+		Type:    operationType,
+		Outcome: kubevirtproviderv1.OperationSucceeded,
+	}
+	if err != nil {
+		lastOperation.Outcome = kubevirtproviderv1.OperationFailed
+		lastOperation.Error = err.Error()
+		if reason := errorFailureReason(err); reason != "" {
+			s.setFailure(reason, err.Error())
+		}
+	}
+	if previous := s.machineProviderStatus.LastOperation; previous != nil && previous.Type == lastOperation.Type &&
+		previous.Outcome == lastOperation.Outcome && previous.Error == lastOperation.Error {
+		return
+	}
+	lastOperation.Time = metav1.Now()
+	s.machineProviderStatus.LastOperation = lastOperation
+}
+
+// setBootProgress records the boot milestones the VMI reached since the last sync
+func (s *machineScope) setBootProgress(vmi *kubevirtapiv1.VirtualMachineInstance) {
+	bootProgress := s.machineProviderStatus.BootProgress
+	if bootProgress == nil || bootProgress.VMIUID != string(vmi.UID) {
+		bootProgress = &kubevirtproviderv1.BootProgress{VMIUID: string(vmi.UID)}
+	}
+
+	now := metav1.Now()
+	for _, name := range reachedBootMilestones(vmi, s.machine) {
+		reached := false
+		for _, milestone := range bootProgress.Milestones {
+			reached = reached || milestone.Name == name
+		}
+		if !reached {
+			klog.Infof("%s: boot milestone %s reached", s.getMachineName(), name)
+			bootProgress.Milestones = append(bootProgress.Milestones, kubevirtproviderv1.BootMilestone{Name: name, Time: now})
+		}
+	}
+	s.machineProviderStatus.BootProgress = bootProgress
+}
+
+// setFailed moves the machine to the Failed phase, after which the machine controller stops reconciling it
+func (s *machineScope) setFailed(reason kubevirtproviderv1.FailureReason, message string) {
+	klog.Errorf("%s: machine failed: %s", s.getMachineName(), message)
+	s.setFailure(reason, message)
+	phase := machinePhaseFailed
+	errorReason := machinev1.MachineStatusError(reason)
+	s.machine.Status.Phase = &phase
+	s.machine.Status.ErrorReason = &errorReason
+	s.machine.Status.ErrorMessage = &message
+}
+
+// isFailed returns true once the machine is in the Failed phase
+func (s *machineScope) isFailed() bool {
+	return s.machine.Status.Phase != nil && *s.machine.Status.Phase == machinePhaseFailed
+}
+
+// setFailure sets the failure of the machine in the provider status, an empty reason clears it
+func (s *machineScope) setFailure(reason kubevirtproviderv1.FailureReason, message string) {
+	if reason != "" && reason != s.machineProviderStatus.FailureReason {
+		machineFailuresCounter.WithLabelValues(string(reason)).Inc()
+	}
+	s.machineProviderStatus.FailureReason = reason
+	s.machineProviderStatus.FailureMessage = message
+}
+
+// setCondition sets the condition in the provider status, observed at the current generation of the machine
+func (s *machineScope) setCondition(condition kubevirtproviderv1.KubevirtMachineCondition) {
+	if s.machineProviderStatus == nil {
+		s.machineProviderStatus = &kubevirtproviderv1.KubevirtMachineProviderStatus{}
+	}
+	condition.ObservedGeneration = s.machine.Generation
+	s.machineProviderStatus.MachineConditions = conditions.Set(s.machineProviderStatus.MachineConditions, condition, time.Now())
+}
+
 // GetMachineName return the name of the provided Machine
 func GetMachineName(machine *machinev1.Machine) string {
 	return machine.GetName()
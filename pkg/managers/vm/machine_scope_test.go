@@ -1,7 +1,24 @@
 package vm
 
 import (
+	"errors"
 	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
 )
 
 const testNamespace = "underkube-test"
@@ -11,5 +28,435 @@ func TestGetUserData(t *testing.T) {
 }
 
 func TestPatchMachine(t *testing.T) {
+	cases := []struct {
+		name            string
+		mutate          func(machine *machinev1.Machine, providerStatus *kubevirtproviderv1.KubevirtMachineProviderStatus)
+		wantPatch       int
+		wantStatusPatch int
+	}{
+		{
+			name:   "Skip both patches when nothing changed",
+			mutate: func(*machinev1.Machine, *kubevirtproviderv1.KubevirtMachineProviderStatus) {},
+		},
+		{
+			name: "Patch only the machine when the labels changed",
+			mutate: func(machine *machinev1.Machine, _ *kubevirtproviderv1.KubevirtMachineProviderStatus) {
+				machine.Labels["machine.openshift.io/instance-type"] = "kubevirt"
+			},
+			wantPatch: 1,
+		},
+		{
+			name: "Patch only the status when the provider status changed",
+			mutate: func(_ *machinev1.Machine, providerStatus *kubevirtproviderv1.KubevirtMachineProviderStatus) {
+				providerStatus.Ready = true
+			},
+			wantStatusPatch: 1,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			providerStatus := &kubevirtproviderv1.KubevirtMachineProviderStatus{}
+			machine.Status.ProviderStatus, err = kubevirtproviderv1.RawExtensionFromProviderStatus(providerStatus)
+			assert.NilError(t, err)
+
+			scope := &machineScope{
+				overkubeClient:        mockOverkube,
+				machine:               machine,
+				originMachineCopy:     machine.DeepCopy(),
+				machineProviderStatus: providerStatus,
+			}
+			tc.mutate(machine, providerStatus)
+
+			mockOverkube.EXPECT().PatchMachine(machine, scope.originMachineCopy).Return(nil).Times(tc.wantPatch)
+			mockOverkube.EXPECT().StatusPatchMachine(machine, scope.originMachineCopy).Return(nil).Times(tc.wantStatusPatch)
+
+			assert.NilError(t, scope.patchMachine())
+		})
+	}
+}
+
+func TestResourceOverrides(t *testing.T) {
+	cases := []struct {
+		name            string
+		annotations     map[string]string
+		providerSpec    kubevirtproviderv1.KubevirtMachineProviderSpec
+		wantMemory      string
+		wantCPU         string
+		wantValidateErr string
+	}{
+		{
+			name:       "Use the defaults",
+			wantMemory: render.DefaultRequestedMemory,
+		},
+		{
+			name:         "Use the provider spec",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{RequestedMemory: "4Gi", RequestedCPU: "2"},
+			wantMemory:   "4Gi",
+			wantCPU:      "2",
+		},
+		{
+			name:         "Annotations override the provider spec",
+			annotations:  map[string]string{render.RequestedMemoryAnnotation: "16Gi", render.RequestedCPUAnnotation: "8"},
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{RequestedMemory: "4Gi", RequestedCPU: "2"},
+			wantMemory:   "16Gi",
+			wantCPU:      "8",
+		},
+		{
+			name:            "Reject an invalid override",
+			annotations:     map[string]string{render.RequestedCPUAnnotation: "lots"},
+			wantMemory:      render.DefaultRequestedMemory,
+			wantCPU:         "lots",
+			wantValidateErr: `machine-test: invalid value "lots" for annotation kubevirt.io/requested-cpu: quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.Annotations = tc.annotations
+			scope := &machineScope{machine: machine, machineProviderSpec: &tc.providerSpec}
+
+			assert.Equal(t, tc.wantMemory, render.RequestedMemory(machine, &tc.providerSpec, render.Defaults{}))
+			assert.Equal(t, tc.wantCPU, render.RequestedCPU(machine, &tc.providerSpec))
+			if tc.wantValidateErr != "" {
+				assert.Error(t, scope.validateResourceOverrides(), tc.wantValidateErr)
+			} else {
+				assert.NilError(t, scope.validateResourceOverrides())
+			}
+		})
+	}
+}
+
+func TestNewMachineScopeInvalidCredentials(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	credentialsErr := &underkube.CredentialsError{Message: "Underkube credentials secret kubeconfig did not contain key kubeconfig"}
+	underkubeClientBuilder := func(overkube.Client, string, string) (underkube.Client, error) {
+		return nil, credentialsErr
+	}
+
+	mockOverkube.EXPECT().PatchMachine(gomock.Any(), gomock.Any()).Times(0)
+	mockOverkube.EXPECT().StatusPatchMachine(machine, gomock.Any()).Return(nil).Times(1)
+
+	_, err = newMachineScope(machine, mockOverkube, underkubeClientBuilder, kubevirtproviderv1.KubevirtProviderConfigSpec{}, true)
+	assert.Assert(t, underkube.IsCredentialsError(err))
+	assert.Equal(t, *machine.Status.ErrorReason, invalidCredentialsMachineError)
+	assert.Equal(t, *machine.Status.ErrorMessage, credentialsErr.Message)
+
+	providerStatus, err := kubevirtproviderv1.ProviderStatusFromRawExtension(machine.Status.ProviderStatus)
+	assert.NilError(t, err)
+	condition := conditions.Find(providerStatus.MachineConditions, kubevirtproviderv1.CredentialsValidCondition)
+	assert.Assert(t, condition != nil)
+	assert.Equal(t, condition.Status, corev1.ConditionFalse)
+	assert.Equal(t, condition.Reason, "InvalidCredentials")
+}
+
+func TestNewMachineScopeDefaultCredentials(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+	cases := []struct {
+		name           string
+		secretName     string
+		wantSecretName string
+	}{
+		{
+			name:           "Machine secret",
+			secretName:     "kubeconfig",
+			wantSecretName: "kubeconfig",
+		},
+		{
+			name:           "Default secret",
+			wantSecretName: "default-kubeconfig",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.Spec.ProviderSpec.Value, err = kubevirtproviderv1.RawExtensionFromProviderSpec(&kubevirtproviderv1.KubevirtMachineProviderSpec{UnderKubeconfigSecretName: tc.secretName})
+			assert.NilError(t, err)
+
+			var gotSecretName string
+			underkubeClientBuilder := func(_ overkube.Client, secretName, _ string) (underkube.Client, error) {
+				gotSecretName = secretName
+				return mockUnderkube, nil
+			}
+			config := kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultUnderKubeconfigSecretName: "default-kubeconfig"}
+			_, err = newMachineScope(machine, mockOverkube, underkubeClientBuilder, config, true)
+			assert.NilError(t, err)
+			assert.Equal(t, gotSecretName, tc.wantSecretName)
+		})
+	}
+}
+
+func TestNewMachineScopeCredentialsChange(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+	underkubeClientBuilder := func(_ overkube.Client, _, _ string) (underkube.Client, error) {
+		return mockUnderkube, nil
+	}
+	config := kubevirtproviderv1.KubevirtProviderConfigSpec{CredentialsOverrideSecrets: []string{"new-infra-kubeconfig"}}
+
+	machine, err := stubMachine(nil, "kubevirt://cluster-test/machine-test")
+	assert.NilError(t, err)
+	scope, err := newMachineScope(machine, mockOverkube, underkubeClientBuilder, config, true)
+	assert.NilError(t, err)
+	assert.Equal(t, scope.machineProviderStatus.UnderKubeconfigSecretName, workerUserDataSecretName)
+
+	machine.Annotations = map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"}
+	machine.Status.ProviderStatus, err = kubevirtproviderv1.RawExtensionFromProviderStatus(scope.machineProviderStatus)
+	assert.NilError(t, err)
+	_, err = newMachineScope(machine, mockOverkube, underkubeClientBuilder, config, true)
+	assert.Error(t, err, "machine-test: the credentials secret can't change from worker-user-data to new-infra-kubeconfig once the machine has a provider ID, revert the kubevirt.io/underkube-credentials-secret annotation")
+}
+
+func TestAssertCredentialsSecret(t *testing.T) {
+	cases := []struct {
+		name                   string
+		secretName             string
+		annotations            map[string]string
+		config                 kubevirtproviderv1.KubevirtProviderConfigSpec
+		credentialsFromSecrets bool
+		wantErr                string
+	}{
+		{
+			name:                   "Machine secret",
+			secretName:             "kubeconfig",
+			credentialsFromSecrets: true,
+		},
+		{
+			name:                   "Default secret",
+			config:                 kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultUnderKubeconfigSecretName: "default-kubeconfig"},
+			credentialsFromSecrets: true,
+		},
+		{
+			name:                   "No secret",
+			credentialsFromSecrets: true,
+			wantErr:                "machine-test: missing value for UnderKubeconfigSecretName, and the KubevirtProviderConfig sets no defaultUnderKubeconfigSecretName",
+		},
+		{
+			name:                   "Override not allowed",
+			secretName:             "kubeconfig",
+			annotations:            map[string]string{providerconfig.CredentialsSecretAnnotation: "other-kubeconfig"},
+			credentialsFromSecrets: true,
+			wantErr:                `machine-test: the credentials secret "other-kubeconfig" of the kubevirt.io/underkube-credentials-secret annotation isn't one of the credentialsOverrideSecrets of the KubevirtProviderConfig`,
+		},
+		{
+			name: "Kubeconfig file",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.Annotations = tc.annotations
+			scope := &machineScope{
+				machine:                machine,
+				machineProviderSpec:    &kubevirtproviderv1.KubevirtMachineProviderSpec{UnderKubeconfigSecretName: tc.secretName},
+				providerConfig:         tc.config,
+				credentialsFromSecrets: tc.credentialsFromSecrets,
+			}
+
+			err = scope.assertCredentialsSecret()
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestSetLastOperation(t *testing.T) {
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	scope := &machineScope{machine: machine}
+
+	scope.setLastOperation(kubevirtproviderv1.CreateOperation, errors.New("failed to create virtual machine"))
+	lastOperation := scope.machineProviderStatus.LastOperation
+	assert.Equal(t, lastOperation.Type, kubevirtproviderv1.CreateOperation)
+	assert.Equal(t, lastOperation.Outcome, kubevirtproviderv1.OperationFailed)
+	assert.Equal(t, lastOperation.Error, "failed to create virtual machine")
+	assert.Assert(t, !lastOperation.Time.IsZero())
+
+	scope.setLastOperation(kubevirtproviderv1.UpdateOperation, nil)
+	lastOperation = scope.machineProviderStatus.LastOperation
+	assert.Equal(t, lastOperation.Type, kubevirtproviderv1.UpdateOperation)
+	assert.Equal(t, lastOperation.Outcome, kubevirtproviderv1.OperationSucceeded)
+	assert.Equal(t, lastOperation.Error, "")
+
+	// Repeating the operation with the same outcome keeps the status as is
+	lastOperation.Time = metav1.NewTime(lastOperation.Time.Add(-time.Hour))
+	updatedAt := lastOperation.Time
+	scope.setLastOperation(kubevirtproviderv1.UpdateOperation, nil)
+	assert.Equal(t, scope.machineProviderStatus.LastOperation.Time, updatedAt)
+
+	scope.setLastOperation(kubevirtproviderv1.UpdateOperation, errors.New("failed to get VM"))
+	lastOperation = scope.machineProviderStatus.LastOperation
+	assert.Equal(t, lastOperation.Outcome, kubevirtproviderv1.OperationFailed)
+	assert.Assert(t, lastOperation.Time.After(updatedAt.Time))
+}
+
+func TestSetBootProgress(t *testing.T) {
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	scope := &machineScope{machine: machine, machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{}}
+	milestoneNames := func() []kubevirtproviderv1.BootMilestoneName {
+		var names []kubevirtproviderv1.BootMilestoneName
+		for _, milestone := range scope.machineProviderStatus.BootProgress.Milestones {
+			names = append(names, milestone.Name)
+		}
+		return names
+	}
+
+	vmi := &kubevirtapiv1.VirtualMachineInstance{}
+	vmi.UID = "vmi-1"
+	vmi.Status.Phase = kubevirtapiv1.Scheduling
+	scope.setBootProgress(vmi)
+	assert.Assert(t, milestoneNames() == nil)
+
+	vmi.Status.Phase = kubevirtapiv1.Running
+	vmi.Status.Conditions = []kubevirtapiv1.VirtualMachineInstanceCondition{
+		{Type: kubevirtapiv1.VirtualMachineInstanceAgentConnected, Status: corev1.ConditionTrue},
+	}
+	scope.setBootProgress(vmi)
+	assert.DeepEqual(t, milestoneNames(), []kubevirtproviderv1.BootMilestoneName{
+		kubevirtproviderv1.VMIStartedMilestone,
+		kubevirtproviderv1.GuestAgentConnectedMilestone,
+	})
+	startedAt := scope.machineProviderStatus.BootProgress.Milestones[0].Time
+
+	machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: mahcineName}
+	scope.setBootProgress(vmi)
+	assert.DeepEqual(t, milestoneNames(), []kubevirtproviderv1.BootMilestoneName{
+		kubevirtproviderv1.VMIStartedMilestone,
+		kubevirtproviderv1.GuestAgentConnectedMilestone,
+		kubevirtproviderv1.NodeJoinedMilestone,
+	})
+	assert.Equal(t, scope.machineProviderStatus.BootProgress.Milestones[0].Time, startedAt)
+
+	// A restarted VM gets a new VMI, and starts over
+	vmi.UID = "vmi-2"
+	vmi.Status.Phase = kubevirtapiv1.Scheduled
+	scope.setBootProgress(vmi)
+	assert.Equal(t, scope.machineProviderStatus.BootProgress.VMIUID, "vmi-2")
+	assert.Assert(t, milestoneNames() == nil)
+}
+
+func TestVMNamespace(t *testing.T) {
+	cases := []struct {
+		name              string
+		specNamespace     string
+		defaultNamespace  string
+		allowedNamespaces []string
+		recordedNamespace string
+		wantNamespace     string
+		wantErr           string
+	}{
+		{
+			name:          "Default to the cluster ID",
+			wantNamespace: clusterID,
+		},
+		{
+			name:              "Keep the namespace the VM was created in",
+			defaultNamespace:  "tenant-1",
+			recordedNamespace: clusterID,
+			wantNamespace:     clusterID,
+		},
+		{
+			name:             "Default to the namespace of the kubeconfig context",
+			defaultNamespace: "tenant-1",
+			wantNamespace:    "tenant-1",
+		},
+		{
+			name:             "Allow the namespace of the kubeconfig context",
+			specNamespace:    "tenant-1",
+			defaultNamespace: "tenant-1",
+			wantNamespace:    "tenant-1",
+		},
+		{
+			name:              "Allow a namespace of the credentials allow-list",
+			specNamespace:     "tenant-1-vms",
+			defaultNamespace:  "tenant-1",
+			allowedNamespaces: []string{"tenant-1-vms"},
+			wantNamespace:     "tenant-1-vms",
+		},
+		{
+			name:              "Allow any namespace",
+			specNamespace:     "tenant-2",
+			allowedNamespaces: []string{"*"},
+			wantNamespace:     "tenant-2",
+		},
+		{
+			name:             "Reject a namespace the credentials don't allow",
+			specNamespace:    "tenant-2",
+			defaultNamespace: "tenant-1",
+			wantNamespace:    "tenant-2",
+			wantErr:          `machine-test: namespace "tenant-2" is not allowed by the underkube credentials, it must be listed in the kubevirt.io/allowed-namespaces annotation of the credentials secret`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			mockUnderkube.EXPECT().DefaultNamespace().Return(tc.defaultNamespace).AnyTimes()
+			mockUnderkube.EXPECT().AllowedNamespaces().Return(tc.allowedNamespaces).AnyTimes()
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			scope := &machineScope{
+				underkubeClient:       mockUnderkube,
+				machine:               machine,
+				machineProviderSpec:   &kubevirtproviderv1.KubevirtMachineProviderSpec{Namespace: tc.specNamespace},
+				machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{VMNamespace: tc.recordedNamespace},
+			}
+
+			assert.Equal(t, scope.getVMNamespace(), tc.wantNamespace)
+			if tc.wantErr != "" {
+				assert.Error(t, scope.validateVMNamespace(), tc.wantErr)
+			} else {
+				assert.NilError(t, scope.validateVMNamespace())
+			}
+		})
+	}
+}
 
+func TestBuildServiceDNSName(t *testing.T) {
+	cases := []struct {
+		name          string
+		serviceDomain string
+		wantName      string
+	}{
+		{
+			name:     "Default service domain",
+			wantName: "machine-test.tenant-1.svc.cluster.local",
+		},
+		{
+			name:          "Service domain of the provider config",
+			serviceDomain: "underkube.example.com",
+			wantName:      "machine-test.tenant-1.svc.underkube.example.com",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			scope := &machineScope{providerConfig: kubevirtproviderv1.KubevirtProviderConfigSpec{ServiceDomain: tc.serviceDomain}}
+			assert.Equal(t, scope.buildServiceDNSName("machine-test", "tenant-1"), tc.wantName)
+		})
+	}
 }
@@ -0,0 +1,20 @@
+package vm
+
+import (
+	"regexp"
+
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// machineTypePattern matches the x86_64 QEMU machine types, the q35 and i440fx (pc) chipsets, unversioned
+// or versioned
+var machineTypePattern = regexp.MustCompile(`^(q35|pc|pc-q35-[a-z0-9.-]+|pc-i440fx-[a-z0-9.-]+)$`)
+
+// validateMachineType validates the machine type is a q35 or pc one. Whether the underkube emulates it is
+// only known once KubeVirt starts the VM.
+func validateMachineType(machineName, machineType string) error {
+	if machineType == "" || machineTypePattern.MatchString(machineType) {
+		return nil
+	}
+	return machinecontroller.InvalidMachineConfiguration("%v: invalid machine type %q, expected q35, pc, or a versioned pc-q35 or pc-i440fx type", machineName, machineType)
+}
@@ -0,0 +1,38 @@
+package vm
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestValidateMachineType(t *testing.T) {
+	cases := []struct {
+		machineType string
+		wantErr     string
+	}{
+		{machineType: ""},
+		{machineType: "q35"},
+		{machineType: "pc"},
+		{machineType: "pc-q35-rhel8.2.0"},
+		{machineType: "pc-i440fx-4.2"},
+		{
+			machineType: "virt",
+			wantErr:     `machine-test: invalid machine type "virt", expected q35, pc, or a versioned pc-q35 or pc-i440fx type`,
+		},
+		{
+			machineType: "Q35",
+			wantErr:     `machine-test: invalid machine type "Q35", expected q35, pc, or a versioned pc-q35 or pc-i440fx type`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.machineType, func(t *testing.T) {
+			err := validateMachineType("machine-test", tc.machineType)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
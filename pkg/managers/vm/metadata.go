@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+)
+
+// kubevirtMetadataPrefix is the prefix of the labels and annotations KubeVirt sets on the VMIs and reads from
+// them, the provider doesn't propagate the machine ones over them
+const kubevirtMetadataPrefix = "kubevirt.io/"
+
+// validateMetadataPropagation checks the prefixes are set, listed once, and don't select the KubeVirt labels
+// and annotations
+func validateMetadataPropagation(machineName string, propagation *kubevirtproviderv1.MetadataPropagation) error {
+	if propagation == nil {
+		return nil
+	}
+	if err := validatePropagatedPrefixes(machineName, "label", propagation.LabelPrefixes); err != nil {
+		return err
+	}
+	return validatePropagatedPrefixes(machineName, "annotation", propagation.AnnotationPrefixes)
+}
+
+func validatePropagatedPrefixes(machineName, kind string, prefixes []string) error {
+	seen := map[string]bool{}
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			return machinecontroller.InvalidMachineConfiguration("%v: empty propagated %s prefix", machineName, kind)
+		}
+		if strings.HasPrefix(prefix, kubevirtMetadataPrefix) || strings.HasPrefix(kubevirtMetadataPrefix, prefix) {
+			return machinecontroller.InvalidMachineConfiguration("%v: propagated %s prefix %q selects the %s keys KubeVirt owns", machineName, kind, prefix, kubevirtMetadataPrefix)
+		}
+		if seen[prefix] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate propagated %s prefix %q", machineName, kind, prefix)
+		}
+		seen[prefix] = true
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+)
+
+func TestValidateMetadataPropagation(t *testing.T) {
+	cases := []struct {
+		name        string
+		propagation *kubevirtproviderv1.MetadataPropagation
+		wantErr     string
+	}{
+		{
+			name: "No propagation",
+		},
+		{
+			name: "Prefixes and keys",
+			propagation: &kubevirtproviderv1.MetadataPropagation{
+				LabelPrefixes:      []string{"backup.example.com/", "monitoring"},
+				AnnotationPrefixes: []string{"backup.example.com/"},
+			},
+		},
+		{
+			name:        "Empty prefix",
+			propagation: &kubevirtproviderv1.MetadataPropagation{LabelPrefixes: []string{""}},
+			wantErr:     "machine-test: empty propagated label prefix",
+		},
+		{
+			name:        "KubeVirt label",
+			propagation: &kubevirtproviderv1.MetadataPropagation{LabelPrefixes: []string{"kubevirt.io/infra-hostname"}},
+			wantErr:     `machine-test: propagated label prefix "kubevirt.io/infra-hostname" selects the kubevirt.io/ keys KubeVirt owns`,
+		},
+		{
+			name:        "Prefix of the KubeVirt annotations",
+			propagation: &kubevirtproviderv1.MetadataPropagation{AnnotationPrefixes: []string{"kube"}},
+			wantErr:     `machine-test: propagated annotation prefix "kube" selects the kubevirt.io/ keys KubeVirt owns`,
+		},
+		{
+			name:        "Duplicate prefix",
+			propagation: &kubevirtproviderv1.MetadataPropagation{AnnotationPrefixes: []string{"backup.example.com/", "backup.example.com/"}},
+			wantErr:     `machine-test: duplicate propagated annotation prefix "backup.example.com/"`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMetadataPropagation("machine-test", tc.propagation)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package vm
+
+import (
+	"encoding/json"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	// maxVMMutations bounds the history of the VM mutations kept in the provider status
+	maxVMMutations = 10
+	// maxVMMutationDiffLength bounds the diff of a VM mutation, the provider status is stored with the
+	// machine
+	maxVMMutationDiffLength = 2048
+)
+
+// recordVMMutation appends the change made to the VM to the history of the provider status, dropping the
+// oldest changes past maxVMMutations
+func (s *machineScope) recordVMMutation(mutationType kubevirtproviderv1.VMMutationType, diff string) {
+	if s.machineProviderStatus == nil {
+		s.machineProviderStatus = &kubevirtproviderv1.KubevirtMachineProviderStatus{}
+	}
+	if len(diff) > maxVMMutationDiffLength {
+		diff = diff[:maxVMMutationDiffLength] + "...(truncated)"
+	}
+	mutations := append(s.machineProviderStatus.VMMutations, kubevirtproviderv1.VMMutation{
+		Type:              mutationType,
+		Time:              metav1.Now(),
+		MachineGeneration: s.machine.Generation,
+		Diff:              diff,
+	})
+	if len(mutations) > maxVMMutations {
+		mutations = mutations[len(mutations)-maxVMMutations:]
+	}
+	s.machineProviderStatus.VMMutations = mutations
+}
+
+// vmSpecDiff returns the strategic merge patch from the spec of the live VM to the updated one. The diff is
+// informational, an error computing it is logged and an empty diff returned.
+func vmSpecDiff(live, updated *kubevirtapiv1.VirtualMachine) string {
+	original, err := json.Marshal(live.Spec)
+	if err != nil {
+		klog.Errorf("%s: failed to compute the VM diff: %v", live.Name, err)
+		return ""
+	}
+	modified, err := json.Marshal(updated.Spec)
+	if err != nil {
+		klog.Errorf("%s: failed to compute the VM diff: %v", live.Name, err)
+		return ""
+	}
+	patch, err := strategicpatch.CreateTwoWayMergePatch(original, modified, kubevirtapiv1.VirtualMachineSpec{})
+	if err != nil {
+		klog.Errorf("%s: failed to compute the VM diff: %v", live.Name, err)
+		return ""
+	}
+	return string(patch)
+}
@@ -0,0 +1,49 @@
+package vm
+
+import (
+	"strings"
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestRecordVMMutation(t *testing.T) {
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	machine.Generation = 3
+	machineScope := &machineScope{machine: machine}
+
+	machineScope.recordVMMutation(kubevirtproviderv1.VMCreatedMutation, "")
+	assert.Equal(t, len(machineScope.machineProviderStatus.VMMutations), 1)
+	mutation := machineScope.machineProviderStatus.VMMutations[0]
+	assert.Equal(t, mutation.Type, kubevirtproviderv1.VMCreatedMutation)
+	assert.Equal(t, mutation.MachineGeneration, int64(3))
+	assert.Assert(t, !mutation.Time.IsZero())
+
+	for i := 0; i < maxVMMutations; i++ {
+		machineScope.recordVMMutation(kubevirtproviderv1.VMUpdatedMutation, `{"running":true}`)
+	}
+	mutations := machineScope.machineProviderStatus.VMMutations
+	assert.Equal(t, len(mutations), maxVMMutations)
+	for _, mutation := range mutations {
+		assert.Equal(t, mutation.Type, kubevirtproviderv1.VMUpdatedMutation)
+	}
+
+	machineScope.recordVMMutation(kubevirtproviderv1.VMUpdatedMutation, strings.Repeat("x", maxVMMutationDiffLength+1))
+	mutations = machineScope.machineProviderStatus.VMMutations
+	assert.Equal(t, mutations[len(mutations)-1].Diff, strings.Repeat("x", maxVMMutationDiffLength)+"...(truncated)")
+}
+
+func TestVMSpecDiff(t *testing.T) {
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	live := stubVirtualMachine(t, &machineScope{machine: machine, machineProviderSpec: &kubevirtproviderv1.KubevirtMachineProviderSpec{SourcePvcName: SourceTestPvcName}})
+	updated := live.DeepCopy()
+	assert.Equal(t, vmSpecDiff(live, updated), "{}")
+
+	runStrategy := kubevirtapiv1.RunStrategyHalted
+	updated.Spec.RunStrategy = &runStrategy
+	assert.Equal(t, vmSpecDiff(live, updated), `{"runStrategy":"Halted"}`)
+}
@@ -0,0 +1,272 @@
+package vm
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// validateInterfaces checks the interfaces of the provider spec can be turned into KubeVirt networks
+func validateInterfaces(machineName string, interfaces []kubevirtproviderv1.NetworkInterface, primaryInterface string) error {
+	names := map[string]bool{}
+	podNetworks, primaries := 0, 0
+	for _, iface := range interfaces {
+		if iface.Name == "" {
+			return machinecontroller.InvalidMachineConfiguration("%v: missing name for interface", machineName)
+		}
+		if names[iface.Name] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate interface %q", machineName, iface.Name)
+		}
+		names[iface.Name] = true
+
+		if iface.NetworkName == "" {
+			podNetworks++
+		} else if err := validateNetworkName(iface.NetworkName); err != nil {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid network %q for interface %q: %v", machineName, iface.NetworkName, iface.Name, err)
+		}
+		switch iface.Role {
+		case kubevirtproviderv1.InterfaceRolePrimary:
+			primaries++
+		case "", kubevirtproviderv1.InterfaceRoleStorage, kubevirtproviderv1.InterfaceRoleWorkload:
+		default:
+			return machinecontroller.InvalidMachineConfiguration("%v: unknown role %q for interface %q", machineName, iface.Role, iface.Name)
+		}
+
+		if err := validateInterfaceBinding(machineName, iface); err != nil {
+			return err
+		}
+
+		for _, address := range iface.Addresses {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				return machinecontroller.InvalidMachineConfiguration("%v: invalid address %q for interface %q: %v", machineName, address, iface.Name, err)
+			}
+		}
+		if iface.Gateway != "" && net.ParseIP(iface.Gateway) == nil {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid gateway %q for interface %q", machineName, iface.Gateway, iface.Name)
+		}
+		for _, nameserver := range iface.Nameservers {
+			if net.ParseIP(nameserver) == nil {
+				return machinecontroller.InvalidMachineConfiguration("%v: invalid nameserver %q for interface %q", machineName, nameserver, iface.Name)
+			}
+		}
+	}
+	if primaryInterface != "" && !names[primaryInterface] {
+		return machinecontroller.InvalidMachineConfiguration("%v: primary interface %q is not declared", machineName, primaryInterface)
+	}
+	if podNetworks > 1 {
+		return machinecontroller.InvalidMachineConfiguration("%v: only one interface can be connected to the pod network", machineName)
+	}
+	if primaries > 1 {
+		return machinecontroller.InvalidMachineConfiguration("%v: only one interface can have the %s role", machineName, kubevirtproviderv1.InterfaceRolePrimary)
+	}
+	return nil
+}
+
+// validateNetworkData checks the network data of the provider spec is set once, inline or from a secret, and
+// that the inline one is a cloud-init network config, whether under a network key or not
+func validateNetworkData(machineName, networkData, secretName string) error {
+	if networkData != "" && secretName != "" {
+		return machinecontroller.InvalidMachineConfiguration("%v: networkData and networkDataSecretName are mutually exclusive", machineName)
+	}
+	if secretName != "" {
+		if errs := validation.IsDNS1123Subdomain(secretName); len(errs) > 0 {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid network data secret name %q: %s", machineName, secretName, strings.Join(errs, ", "))
+		}
+		return nil
+	}
+	if networkData == "" {
+		return nil
+	}
+
+	var config map[string]interface{}
+	if err := yaml.Unmarshal([]byte(networkData), &config); err != nil {
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid network data: %v", machineName, err)
+	}
+	if network, ok := config["network"].(map[string]interface{}); ok {
+		config = network
+	}
+	switch version := config["version"]; version {
+	case float64(1), float64(2):
+	case nil:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid network data: missing version", machineName)
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid network data: unsupported version %v, expected 1 or 2", machineName, version)
+	}
+	return nil
+}
+
+// validateMacAddresses checks the MAC address allocation, and that the static MAC addresses are unicast and
+// unique. The network data can't match the NICs KubeMacPool allocates the MAC address of, so they can't
+// have a static guest configuration.
+func validateMacAddresses(machineName string, allocation kubevirtproviderv1.MacAddressAllocation, interfaces []kubevirtproviderv1.NetworkInterface) error {
+	switch allocation {
+	case "", kubevirtproviderv1.MacAddressAllocationDerived, kubevirtproviderv1.MacAddressAllocationPool:
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid MAC address allocation %q, expected %s or %s", machineName, allocation, kubevirtproviderv1.MacAddressAllocationDerived, kubevirtproviderv1.MacAddressAllocationPool)
+	}
+
+	seen := map[string]string{}
+	for _, iface := range interfaces {
+		if iface.MacAddress == "" {
+			if allocation == kubevirtproviderv1.MacAddressAllocationPool && (len(iface.Addresses) > 0 || iface.Gateway != "" || len(iface.Nameservers) > 0) {
+				return machinecontroller.InvalidMachineConfiguration("%v: interface %q needs a static MAC address for its static configuration with the %s MAC address allocation", machineName, iface.Name, allocation)
+			}
+			continue
+		}
+		mac, err := net.ParseMAC(iface.MacAddress)
+		if err != nil || len(mac) != 6 {
+			return machinecontroller.InvalidMachineConfiguration("%v: invalid MAC address %q for interface %q, expected a 48-bit MAC address", machineName, iface.MacAddress, iface.Name)
+		}
+		if mac[0]&1 != 0 {
+			return machinecontroller.InvalidMachineConfiguration("%v: MAC address %q of interface %q is a multicast address", machineName, iface.MacAddress, iface.Name)
+		}
+		if other, ok := seen[mac.String()]; ok {
+			return machinecontroller.InvalidMachineConfiguration("%v: interfaces %q and %q have the same MAC address %s", machineName, other, iface.Name, mac)
+		}
+		seen[mac.String()] = iface.Name
+	}
+	return nil
+}
+
+// validateNetworkName checks the network is a NetworkAttachmentDefinition reference Multus resolves: its
+// name, or namespace/name for a definition outside of the VM namespace
+func validateNetworkName(networkName string) error {
+	parts := strings.Split(networkName, "/")
+	if len(parts) > 2 {
+		return fmt.Errorf("expected name or namespace/name")
+	}
+	for _, part := range parts {
+		if errs := validation.IsDNS1123Label(part); len(errs) > 0 {
+			return fmt.Errorf("%s", strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// validateInterfaceBinding checks the binding suits the network of the NIC: masquerade is a NAT of the pod
+// network, and an sriov NIC is on a Multus network and names the device plugin resource of its virtual
+// functions
+func validateInterfaceBinding(machineName string, iface kubevirtproviderv1.NetworkInterface) error {
+	if iface.ResourceName != "" && iface.Binding != kubevirtproviderv1.InterfaceBindingSRIOV {
+		return machinecontroller.InvalidMachineConfiguration("%v: resourceName is only valid for the %s binding of interface %q", machineName, kubevirtproviderv1.InterfaceBindingSRIOV, iface.Name)
+	}
+
+	switch iface.Binding {
+	case "", kubevirtproviderv1.InterfaceBindingBridge:
+		return nil
+	case kubevirtproviderv1.InterfaceBindingMasquerade:
+		if iface.NetworkName != "" {
+			return machinecontroller.InvalidMachineConfiguration("%v: the %s interface %q must be on the pod network", machineName, iface.Binding, iface.Name)
+		}
+		// KubeVirt serves the NATed address of the guest over DHCP
+		if len(iface.Addresses) > 0 {
+			return machinecontroller.InvalidMachineConfiguration("%v: the %s interface %q can't have static addresses", machineName, iface.Binding, iface.Name)
+		}
+		return nil
+	case kubevirtproviderv1.InterfaceBindingSRIOV:
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: unknown binding %q for interface %q", machineName, iface.Binding, iface.Name)
+	}
+	if iface.NetworkName == "" {
+		return machinecontroller.InvalidMachineConfiguration("%v: the %s interface %q needs a Multus network", machineName, iface.Binding, iface.Name)
+	}
+	if iface.ResourceName == "" {
+		return machinecontroller.InvalidMachineConfiguration("%v: missing resourceName for the %s interface %q", machineName, iface.Binding, iface.Name)
+	}
+	// Device plugin resources are extended resources, whose names are domain qualified
+	if errs := validation.IsQualifiedName(iface.ResourceName); len(errs) > 0 || !strings.Contains(iface.ResourceName, "/") {
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid resourceName %q for interface %q, expected a domain qualified device plugin resource", machineName, iface.ResourceName, iface.Name)
+	}
+	return nil
+}
+
+// getPrimaryInterface returns the interface the node registers with: the one named by the provider spec,
+// or else the one with the primary role, or else the first one. It returns nil when the provider spec
+// declares no interface.
+func getPrimaryInterface(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) *kubevirtproviderv1.NetworkInterface {
+	interfaces := providerSpec.Interfaces
+	for i := range interfaces {
+		if providerSpec.PrimaryInterface != "" && interfaces[i].Name == providerSpec.PrimaryInterface {
+			return &interfaces[i]
+		}
+	}
+	if providerSpec.PrimaryInterface == "" {
+		for i := range interfaces {
+			if interfaces[i].Role == kubevirtproviderv1.InterfaceRolePrimary {
+				return &interfaces[i]
+			}
+		}
+	}
+	if len(interfaces) > 0 {
+		return &interfaces[0]
+	}
+	return nil
+}
+
+// getNodeIPHint returns the static IP kubelet should register the node with, of the most preferred IP
+// family. It returns an empty string when the primary interface gets its address from DHCP.
+func getNodeIPHint(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) string {
+	primaryInterface := getPrimaryInterface(providerSpec)
+	if primaryInterface == nil {
+		return ""
+	}
+	var ips []net.IP
+	for _, address := range primaryInterface.Addresses {
+		if ip, _, err := net.ParseCIDR(address); err == nil {
+			ips = append(ips, ip)
+		}
+	}
+	if len(ips) == 0 {
+		return ""
+	}
+	sort.SliceStable(ips, func(i, j int) bool {
+		return ipFamilyRank(ips[i], providerSpec.IPFamilies) < ipFamilyRank(ips[j], providerSpec.IPFamilies)
+	})
+	return ips[0].String()
+}
+
+// validateIPFamilies checks the IP families preference only holds known families, once
+func validateIPFamilies(machineName string, ipFamilies []corev1.IPFamily) error {
+	seen := map[corev1.IPFamily]bool{}
+	for _, family := range ipFamilies {
+		if family != corev1.IPv4Protocol && family != corev1.IPv6Protocol {
+			return machinecontroller.InvalidMachineConfiguration("%v: unknown IP family %q", machineName, family)
+		}
+		if seen[family] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate IP family %q", machineName, family)
+		}
+		seen[family] = true
+	}
+	return nil
+}
+
+// sortAddressesByIPFamily orders the addresses following the IP families preference, keeping their
+// order within a family. Addresses of a family missing from the preference come last.
+func sortAddressesByIPFamily(addresses []corev1.NodeAddress, ipFamilies []corev1.IPFamily) {
+	if len(ipFamilies) == 0 {
+		return
+	}
+	sort.SliceStable(addresses, func(i, j int) bool {
+		return ipFamilyRank(net.ParseIP(addresses[i].Address), ipFamilies) < ipFamilyRank(net.ParseIP(addresses[j].Address), ipFamilies)
+	})
+}
+
+func ipFamilyRank(ip net.IP, ipFamilies []corev1.IPFamily) int {
+	family := corev1.IPv6Protocol
+	if ip.To4() != nil {
+		family = corev1.IPv4Protocol
+	}
+	for rank, preferred := range ipFamilies {
+		if preferred == family {
+			return rank
+		}
+	}
+	return len(ipFamilies)
+}
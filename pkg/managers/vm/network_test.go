@@ -0,0 +1,370 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestValidateInterfaces(t *testing.T) {
+	cases := []struct {
+		name       string
+		interfaces []kubevirtproviderv1.NetworkInterface
+		primary    string
+		wantErr    string
+	}{
+		{
+			name:       "Accept no interface",
+			interfaces: nil,
+		},
+		{
+			name: "Reject two interfaces on the pod network",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default"},
+				{Name: "other"},
+			},
+			wantErr: "machine-test: only one interface can be connected to the pod network",
+		},
+		{
+			name: "Reject two primary interfaces",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", Role: kubevirtproviderv1.InterfaceRolePrimary},
+				{Name: "other", NetworkName: "net", Role: kubevirtproviderv1.InterfaceRolePrimary},
+			},
+			wantErr: "machine-test: only one interface can have the primary role",
+		},
+		{
+			name:       "Reject an undeclared primary interface",
+			interfaces: []kubevirtproviderv1.NetworkInterface{{Name: "default"}},
+			primary:    "storage",
+			wantErr:    `machine-test: primary interface "storage" is not declared`,
+		},
+		{
+			name: "Reject an invalid address",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", Addresses: []string{"192.168.10.5"}},
+			},
+			wantErr: `machine-test: invalid address "192.168.10.5" for interface "default": invalid CIDR address: 192.168.10.5`,
+		},
+		{
+			name: "Accept networks of the VM namespace and of another namespace",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default"},
+				{Name: "storage", NetworkName: "storage-vlan"},
+				{Name: "replication", NetworkName: "infra-networks/replication-vlan"},
+			},
+		},
+		{
+			name: "Reject an invalid network reference",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "storage", NetworkName: "infra/networks/storage"},
+			},
+			wantErr: `machine-test: invalid network "infra/networks/storage" for interface "storage": expected name or namespace/name`,
+		},
+		{
+			name: "Accept an sriov interface",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default"},
+				{Name: "fast", NetworkName: "sriov-net", Binding: kubevirtproviderv1.InterfaceBindingSRIOV, ResourceName: "intel.com/sriov_netdevice"},
+			},
+		},
+		{
+			name: "Reject an sriov interface on the pod network",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "fast", Binding: kubevirtproviderv1.InterfaceBindingSRIOV, ResourceName: "intel.com/sriov_netdevice"},
+			},
+			wantErr: `machine-test: the sriov interface "fast" needs a Multus network`,
+		},
+		{
+			name: "Reject an sriov interface without resource",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "fast", NetworkName: "sriov-net", Binding: kubevirtproviderv1.InterfaceBindingSRIOV},
+			},
+			wantErr: `machine-test: missing resourceName for the sriov interface "fast"`,
+		},
+		{
+			name: "Reject an unqualified resource",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "fast", NetworkName: "sriov-net", Binding: kubevirtproviderv1.InterfaceBindingSRIOV, ResourceName: "sriov_netdevice"},
+			},
+			wantErr: `machine-test: invalid resourceName "sriov_netdevice" for interface "fast", expected a domain qualified device plugin resource`,
+		},
+		{
+			name: "Reject a resource on a bridge interface",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", ResourceName: "intel.com/sriov_netdevice"},
+			},
+			wantErr: `machine-test: resourceName is only valid for the sriov binding of interface "default"`,
+		},
+		{
+			name: "Accept a masquerade interface on the pod network",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", Binding: kubevirtproviderv1.InterfaceBindingMasquerade},
+			},
+		},
+		{
+			name: "Reject a masquerade interface on a Multus network",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "storage", NetworkName: "storage-vlan", Binding: kubevirtproviderv1.InterfaceBindingMasquerade},
+			},
+			wantErr: `machine-test: the masquerade interface "storage" must be on the pod network`,
+		},
+		{
+			name: "Reject a masquerade interface with static addresses",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", Binding: kubevirtproviderv1.InterfaceBindingMasquerade, Addresses: []string{"10.0.2.2/24"}},
+			},
+			wantErr: `machine-test: the masquerade interface "default" can't have static addresses`,
+		},
+		{
+			name: "Reject an unknown binding",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", Binding: "slirp"},
+			},
+			wantErr: `machine-test: unknown binding "slirp" for interface "default"`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateInterfaces("machine-test", tc.interfaces, tc.primary)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateMacAddresses(t *testing.T) {
+	cases := []struct {
+		name       string
+		allocation kubevirtproviderv1.MacAddressAllocation
+		interfaces []kubevirtproviderv1.NetworkInterface
+		wantErr    string
+	}{
+		{
+			name: "Accept static MAC addresses",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", MacAddress: "52:54:00:00:00:01"},
+				{Name: "tenant", NetworkName: "tenant-net", MacAddress: "52-54-00-00-00-02"},
+			},
+		},
+		{
+			name:       "Accept the pool allocation of NICs configured by DHCP",
+			allocation: kubevirtproviderv1.MacAddressAllocationPool,
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default"},
+				{Name: "tenant", NetworkName: "tenant-net", MacAddress: "52:54:00:00:00:02", Addresses: []string{"10.0.0.5/24"}},
+			},
+		},
+		{
+			name:       "Reject an unknown allocation",
+			allocation: "Random",
+			wantErr:    `machine-test: invalid MAC address allocation "Random", expected Derived or Pool`,
+		},
+		{
+			name:       "Reject the pool allocation of a NIC with static addresses",
+			allocation: kubevirtproviderv1.MacAddressAllocationPool,
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "tenant", NetworkName: "tenant-net", Addresses: []string{"10.0.0.5/24"}},
+			},
+			wantErr: `machine-test: interface "tenant" needs a static MAC address for its static configuration with the Pool MAC address allocation`,
+		},
+		{
+			name: "Reject an invalid MAC address",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", MacAddress: "52:54:00:00:00"},
+			},
+			wantErr: `machine-test: invalid MAC address "52:54:00:00:00" for interface "default", expected a 48-bit MAC address`,
+		},
+		{
+			name: "Reject a 64-bit MAC address",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", MacAddress: "02:00:5e:10:00:00:00:01"},
+			},
+			wantErr: `machine-test: invalid MAC address "02:00:5e:10:00:00:00:01" for interface "default", expected a 48-bit MAC address`,
+		},
+		{
+			name: "Reject a multicast MAC address",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", MacAddress: "01:00:5e:00:00:01"},
+			},
+			wantErr: `machine-test: MAC address "01:00:5e:00:00:01" of interface "default" is a multicast address`,
+		},
+		{
+			name: "Reject a MAC address used twice",
+			interfaces: []kubevirtproviderv1.NetworkInterface{
+				{Name: "default", MacAddress: "52:54:00:00:00:01"},
+				{Name: "tenant", NetworkName: "tenant-net", MacAddress: "52:54:00:00:00:01"},
+			},
+			wantErr: `machine-test: interfaces "default" and "tenant" have the same MAC address 52:54:00:00:00:01`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMacAddresses("machine-test", tc.allocation, tc.interfaces)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateNetworkData(t *testing.T) {
+	cases := []struct {
+		name        string
+		networkData string
+		secretName  string
+		wantErr     string
+	}{
+		{
+			name: "Accept no network data",
+		},
+		{
+			name: "Accept a version 2 bond with a VLAN",
+			networkData: `version: 2
+ethernets:
+  eth0: {}
+  eth1: {}
+bonds:
+  bond0:
+    interfaces: [eth0, eth1]
+vlans:
+  bond0.100:
+    id: 100
+    link: bond0
+    addresses: [10.0.100.5/24]
+`,
+		},
+		{
+			name: "Accept a version 1 config under a network key",
+			networkData: `network:
+  version: 1
+  config:
+  - type: physical
+    name: eth0
+`,
+		},
+		{
+			name:       "Accept a secret",
+			secretName: "worker-network-data",
+		},
+		{
+			name:        "Reject both inline and from a secret",
+			networkData: "version: 2\n",
+			secretName:  "worker-network-data",
+			wantErr:     "machine-test: networkData and networkDataSecretName are mutually exclusive",
+		},
+		{
+			name:       "Reject an invalid secret name",
+			secretName: "Worker_Network",
+			wantErr:    `machine-test: invalid network data secret name "Worker_Network": a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		{
+			name:        "Reject invalid YAML",
+			networkData: "version: [2",
+			wantErr:     "machine-test: invalid network data: error converting YAML to JSON: yaml: line 1: did not find expected ',' or ']'",
+		},
+		{
+			name:        "Reject a missing version",
+			networkData: "ethernets:\n  eth0: {}\n",
+			wantErr:     "machine-test: invalid network data: missing version",
+		},
+		{
+			name:        "Reject an unsupported version",
+			networkData: "version: 3\n",
+			wantErr:     "machine-test: invalid network data: unsupported version 3, expected 1 or 2",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateNetworkData("machine-test", tc.networkData, tc.secretName)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetNodeIPHint(t *testing.T) {
+	interfaces := []kubevirtproviderv1.NetworkInterface{
+		{Name: "default"},
+		{Name: "node", NetworkName: "node-net", Role: kubevirtproviderv1.InterfaceRolePrimary, Addresses: []string{"10.0.0.5/24"}},
+		{Name: "storage", NetworkName: "storage-net", Addresses: []string{"192.168.10.5/24"}},
+	}
+	cases := []struct {
+		name         string
+		providerSpec kubevirtproviderv1.KubevirtMachineProviderSpec
+		wantPrimary  string
+		wantNodeIP   string
+	}{
+		{
+			name:         "No hint without interfaces",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{},
+		},
+		{
+			name:         "Use the interface with the primary role",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{Interfaces: interfaces},
+			wantPrimary:  "node",
+			wantNodeIP:   "10.0.0.5",
+		},
+		{
+			name:         "Use the primary interface of the provider spec",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{Interfaces: interfaces, PrimaryInterface: "storage"},
+			wantPrimary:  "storage",
+			wantNodeIP:   "192.168.10.5",
+		},
+		{
+			name: "Prefer the IP family of the provider spec",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{
+				Interfaces: []kubevirtproviderv1.NetworkInterface{
+					{Name: "node", Addresses: []string{"10.0.0.5/24", "fd00::5/64"}},
+				},
+				IPFamilies: []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol},
+			},
+			wantPrimary: "node",
+			wantNodeIP:  "fd00::5",
+		},
+		{
+			name:         "No hint for a DHCP interface",
+			providerSpec: kubevirtproviderv1.KubevirtMachineProviderSpec{Interfaces: interfaces, PrimaryInterface: "default"},
+			wantPrimary:  "default",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			primaryInterface := getPrimaryInterface(&tc.providerSpec)
+			if tc.wantPrimary == "" {
+				assert.Assert(t, primaryInterface == nil)
+			} else {
+				assert.Equal(t, tc.wantPrimary, primaryInterface.Name)
+			}
+			assert.Equal(t, tc.wantNodeIP, getNodeIPHint(&tc.providerSpec))
+		})
+	}
+}
+
+func TestSortAddressesByIPFamily(t *testing.T) {
+	addresses := []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.5"},
+		{Type: corev1.NodeInternalIP, Address: "fd00::5"},
+		{Type: corev1.NodeInternalIP, Address: "10.128.0.5"},
+		{Type: corev1.NodeInternalIP, Address: "fd01::5"},
+	}
+	sortAddressesByIPFamily(addresses, []corev1.IPFamily{corev1.IPv6Protocol})
+	assert.DeepEqual(t, []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "fd00::5"},
+		{Type: corev1.NodeInternalIP, Address: "fd01::5"},
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.5"},
+		{Type: corev1.NodeInternalIP, Address: "10.128.0.5"},
+	}, addresses)
+
+	assert.Error(t, validateIPFamilies("machine-test", []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv4Protocol}), `machine-test: duplicate IP family "IPv4"`)
+}
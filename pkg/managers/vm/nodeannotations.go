@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	// NodeVMNamespaceAnnotation and NodeVMNameAnnotation point the node of a machine at its underkube VM,
+	// so debugging can start from the node
+	NodeVMNamespaceAnnotation = "kubevirt.io/vm-namespace"
+	NodeVMNameAnnotation      = "kubevirt.io/vm-name"
+	// NodeVMHostAnnotation holds the underkube node running the VM of the node, it is removed while the VM
+	// doesn't run
+	NodeVMHostAnnotation = "kubevirt.io/vm-host"
+)
+
+// annotateNode annotates the node of the machine with its VM and the underkube node running it, once the
+// node registered and the machine is linked to it
+func (m *manager) annotateNode(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance, machineScope *machineScope) error {
+	if machineScope.machine.Status.NodeRef == nil {
+		return nil
+	}
+	nodeName := machineScope.machine.Status.NodeRef.Name
+	node, err := machineScope.overkubeClient.GetNode(nodeName)
+	if apimachineryerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+
+	originNode := node.DeepCopy()
+	if node.Annotations == nil {
+		node.Annotations = map[string]string{}
+	}
+	node.Annotations[NodeVMNamespaceAnnotation] = vm.Namespace
+	node.Annotations[NodeVMNameAnnotation] = vm.Name
+	if vmi != nil && vmi.Status.NodeName != "" {
+		node.Annotations[NodeVMHostAnnotation] = vmi.Status.NodeName
+	} else {
+		delete(node.Annotations, NodeVMHostAnnotation)
+	}
+	if equality.Semantic.DeepEqual(node.Annotations, originNode.Annotations) {
+		return nil
+	}
+
+	klog.Infof("%s: annotating node %s with VM %s/%s", machineScope.getMachineName(), nodeName, vm.Namespace, vm.Name)
+	if err := machineScope.overkubeClient.PatchNode(node, originNode); err != nil {
+		return fmt.Errorf("failed to annotate node %s: %w", nodeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestAnnotateNode(t *testing.T) {
+	const nodeName = "worker-0"
+	annotated := map[string]string{
+		NodeVMNamespaceAnnotation: clusterID,
+		NodeVMNameAnnotation:      mahcineName,
+		NodeVMHostAnnotation:      "host-1",
+	}
+
+	cases := []struct {
+		name            string
+		noNodeRef       bool
+		nodeAnnotations map[string]string
+		getErr          error
+		hostNode        string
+		wantAnnotations map[string]string
+		wantErr         string
+	}{
+		{
+			name:      "Machine without node",
+			noNodeRef: true,
+		},
+		{
+			name:   "Node not found",
+			getErr: apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, nodeName),
+		},
+		{
+			name:    "Get fails",
+			getErr:  fmt.Errorf("connection refused"),
+			wantErr: "failed to get node worker-0: connection refused",
+		},
+		{
+			name:            "Node registered",
+			nodeAnnotations: map[string]string{"machine.openshift.io/machine": "default/" + mahcineName},
+			hostNode:        "host-1",
+			wantAnnotations: map[string]string{
+				"machine.openshift.io/machine": "default/" + mahcineName,
+				NodeVMNamespaceAnnotation:      clusterID,
+				NodeVMNameAnnotation:           mahcineName,
+				NodeVMHostAnnotation:           "host-1",
+			},
+		},
+		{
+			name:            "Already annotated",
+			nodeAnnotations: annotated,
+			hostNode:        "host-1",
+		},
+		{
+			name:            "VM migrated",
+			nodeAnnotations: annotated,
+			hostNode:        "host-2",
+			wantAnnotations: map[string]string{
+				NodeVMNamespaceAnnotation: clusterID,
+				NodeVMNameAnnotation:      mahcineName,
+				NodeVMHostAnnotation:      "host-2",
+			},
+		},
+		{
+			name:            "VM not running",
+			nodeAnnotations: annotated,
+			wantAnnotations: map[string]string{
+				NodeVMNamespaceAnnotation: clusterID,
+				NodeVMNameAnnotation:      mahcineName,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			if !tc.noNodeRef {
+				machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: nodeName}
+				node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName, Annotations: tc.nodeAnnotations}}
+				mockOverkube.EXPECT().GetNode(nodeName).Return(node.DeepCopy(), tc.getErr)
+			}
+			var patched *corev1.Node
+			if tc.wantAnnotations != nil {
+				mockOverkube.EXPECT().PatchNode(gomock.Any(), gomock.Any()).DoAndReturn(func(node, _ *corev1.Node) error {
+					patched = node
+					return nil
+				})
+			}
+			machineScope := &machineScope{machine: machine, overkubeClient: mockOverkube}
+			vm := &kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName}}
+			var vmi *kubevirtapiv1.VirtualMachineInstance
+			if tc.hostNode != "" {
+				vmi = &kubevirtapiv1.VirtualMachineInstance{Status: kubevirtapiv1.VirtualMachineInstanceStatus{NodeName: tc.hostNode}}
+			}
+
+			err = (&manager{}).annotateNode(vm, vmi, machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			if tc.wantAnnotations != nil {
+				assert.DeepEqual(t, patched.Annotations, tc.wantAnnotations)
+			}
+		})
+	}
+}
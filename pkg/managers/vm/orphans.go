@@ -0,0 +1,137 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+)
+
+// OrphanResource is an underkube resource the provider created for a machine that doesn't exist anymore
+type OrphanResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	// Machine is the name of the missing machine the resource was created for
+	Machine string `json:"machine"`
+	// ClusterID is the cluster of the resource, empty when the resource isn't labeled with it
+	ClusterID string `json:"clusterID,omitempty"`
+}
+
+// OrphanReport lists the orphan resources found in the scanned underkube namespaces
+type OrphanReport struct {
+	ScanTime   k8smetav1.Time   `json:"scanTime"`
+	Namespaces []string         `json:"namespaces"`
+	Resources  []OrphanResource `json:"resources"`
+}
+
+// ScanOrphans looks for the underkube resources created for missing machines: the VMs, services, secrets
+// and ingresses labeled with the cluster of the machines, and the boot volumes no VM owns anymore. It scans
+// the VM namespaces of the machines and the default namespace of the client. When no machine is given,
+// the resources of any cluster are reported. Nothing is deleted.
+func ScanOrphans(underkubeClient underkube.Client, machines []*machinev1.Machine) (*OrphanReport, error) {
+	machineNames := map[string]bool{}
+	namespaces := map[string]bool{}
+	clusterIDSet := map[string]bool{}
+	for _, machine := range machines {
+		machineNames[machine.Name] = true
+		providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(machine.Spec.ProviderSpec.Value)
+		if err != nil {
+			klog.V(3).Infof("%s: failed to get the provider spec: %v", machine.GetName(), err)
+			continue
+		}
+		providerStatus, err := kubevirtproviderv1.ProviderStatusFromRawExtension(machine.Status.ProviderStatus)
+		if err != nil {
+			klog.V(3).Infof("%s: failed to get the provider status: %v", machine.GetName(), err)
+		}
+		namespaces[render.MachineVMNamespace(machine, providerSpec, providerStatus, underkubeClient.DefaultNamespace())] = true
+		if clusterID, ok := render.ClusterID(machine); ok {
+			clusterIDSet[clusterID] = true
+		}
+	}
+	if namespace := underkubeClient.DefaultNamespace(); namespace != "" {
+		namespaces[namespace] = true
+	}
+
+	clusterSelector := machinev1.MachineClusterIDLabel
+	if len(clusterIDSet) > 0 {
+		var clusterIDs []string
+		for clusterID := range clusterIDSet {
+			clusterIDs = append(clusterIDs, clusterID)
+		}
+		sort.Strings(clusterIDs)
+		clusterSelector = fmt.Sprintf("%s in (%s)", machinev1.MachineClusterIDLabel, strings.Join(clusterIDs, ","))
+	}
+	resourceOptions := k8smetav1.ListOptions{LabelSelector: clusterSelector + "," + render.VMLabel}
+
+	report := &OrphanReport{ScanTime: k8smetav1.Now(), Resources: []OrphanResource{}}
+	for namespace := range namespaces {
+		report.Namespaces = append(report.Namespaces, namespace)
+	}
+	sort.Strings(report.Namespaces)
+
+	for _, namespace := range report.Namespaces {
+		orphan := func(kind string, meta k8smetav1.ObjectMeta, machine string) {
+			if !machineNames[machine] {
+				report.Resources = append(report.Resources, OrphanResource{
+					Kind:      kind,
+					Namespace: namespace,
+					Name:      meta.Name,
+					Machine:   machine,
+					ClusterID: meta.Labels[machinev1.MachineClusterIDLabel],
+				})
+			}
+		}
+
+		vms, err := underkubeClient.ListVirtualMachine(namespace, &k8smetav1.ListOptions{LabelSelector: clusterSelector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the VMs of namespace %s: %w", namespace, err)
+		}
+		for _, vm := range vms.Items {
+			orphan("VirtualMachine", vm.ObjectMeta, vm.Name)
+		}
+
+		services, err := underkubeClient.ListServices(namespace, resourceOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the services of namespace %s: %w", namespace, err)
+		}
+		for _, service := range services.Items {
+			orphan("Service", service.ObjectMeta, service.Labels[render.VMLabel])
+		}
+
+		secrets, err := underkubeClient.ListSecrets(namespace, resourceOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the secrets of namespace %s: %w", namespace, err)
+		}
+		for _, secret := range secrets.Items {
+			orphan("Secret", secret.ObjectMeta, secret.Labels[render.VMLabel])
+		}
+
+		ingresses, err := underkubeClient.ListIngresses(namespace, resourceOptions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the ingresses of namespace %s: %w", namespace, err)
+		}
+		for _, ingress := range ingresses.Items {
+			orphan("Ingress", ingress.ObjectMeta, ingress.Labels[render.VMLabel])
+		}
+
+		// The boot volumes aren't labeled, but are owned by their data volume as long as the VM exists
+		pvcs, err := underkubeClient.ListPersistentVolumeClaims(namespace, k8smetav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the persistent volume claims of namespace %s: %w", namespace, err)
+		}
+		bootVolumeSuffix := render.BootVolumeName("")
+		for _, pvc := range pvcs.Items {
+			if len(pvc.OwnerReferences) == 0 && strings.HasSuffix(pvc.Name, bootVolumeSuffix) {
+				orphan("PersistentVolumeClaim", pvc.ObjectMeta, strings.TrimSuffix(pvc.Name, bootVolumeSuffix))
+			}
+		}
+	}
+	return report, nil
+}
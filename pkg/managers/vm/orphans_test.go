@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestScanOrphans(t *testing.T) {
+	machine, err := stubMachine(nil, "")
+	if err != nil {
+		t.Fatalf("stubMachine failed: %v", err)
+	}
+	labeled := func(name, vmName string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Labels: map[string]string{machinev1.MachineClusterIDLabel: clusterID, render.VMLabel: vmName}}
+	}
+
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+	mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+	resourceOptions := metav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + " in (" + clusterID + ")," + render.VMLabel}
+	mockUnderkube.EXPECT().ListVirtualMachine(clusterID, &metav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + " in (" + clusterID + ")"}).Return(&kubevirtapiv1.VirtualMachineList{
+		Items: []kubevirtapiv1.VirtualMachine{{ObjectMeta: labeled(mahcineName, "")}, {ObjectMeta: labeled("deleted-machine", "")}},
+	}, nil)
+	mockUnderkube.EXPECT().ListServices(clusterID, resourceOptions).Return(&corev1.ServiceList{
+		Items: []corev1.Service{{ObjectMeta: labeled(mahcineName, mahcineName)}, {ObjectMeta: labeled("deleted-machine", "deleted-machine")}},
+	}, nil)
+	mockUnderkube.EXPECT().ListSecrets(clusterID, resourceOptions).Return(&corev1.SecretList{
+		Items: []corev1.Secret{{ObjectMeta: labeled(buildUserDataSecretName(mahcineName), mahcineName)}},
+	}, nil)
+	mockUnderkube.EXPECT().ListIngresses(clusterID, resourceOptions).Return(&networkingv1beta1.IngressList{
+		Items: []networkingv1beta1.Ingress{{ObjectMeta: labeled("deleted-machine", "deleted-machine")}},
+	}, nil)
+	ownedVolume := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+		Name:            render.BootVolumeName(mahcineName),
+		OwnerReferences: []metav1.OwnerReference{{Kind: "DataVolume", Name: render.BootVolumeName(mahcineName)}},
+	}}
+	leakedVolume := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: render.BootVolumeName("deleted-machine")}}
+	otherVolume := corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "data"}}
+	mockUnderkube.EXPECT().ListPersistentVolumeClaims(clusterID, metav1.ListOptions{}).Return(&corev1.PersistentVolumeClaimList{
+		Items: []corev1.PersistentVolumeClaim{ownedVolume, leakedVolume, otherVolume},
+	}, nil)
+
+	report, err := ScanOrphans(mockUnderkube, []*machinev1.Machine{machine})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, report.Namespaces, []string{clusterID})
+	assert.DeepEqual(t, report.Resources, []OrphanResource{
+		{Kind: "VirtualMachine", Namespace: clusterID, Name: "deleted-machine", Machine: "deleted-machine", ClusterID: clusterID},
+		{Kind: "Service", Namespace: clusterID, Name: "deleted-machine", Machine: "deleted-machine", ClusterID: clusterID},
+		{Kind: "Ingress", Namespace: clusterID, Name: "deleted-machine", Machine: "deleted-machine", ClusterID: clusterID},
+		{Kind: "PersistentVolumeClaim", Namespace: clusterID, Name: render.BootVolumeName("deleted-machine"), Machine: "deleted-machine"},
+	})
+}
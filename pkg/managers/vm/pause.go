@@ -0,0 +1,62 @@
+package vm
+
+import (
+	"fmt"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// PauseAnnotation pauses the guest of the machine while it is "true": its CPUs are frozen and its memory
+// and disks are kept as they are, for forensics. Removing the annotation unpauses the guest.
+const PauseAnnotation = "kubevirt.io/paused"
+
+// syncPause pauses or unpauses the VMI as the machine requests, through the KubeVirt pause and unpause
+// subresources. A VM that isn't running has nothing to pause.
+func (m *manager) syncPause(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	if isVMStopped(vm) {
+		return nil
+	}
+	vmi, err := m.getUnderkubeVMI(vm.Name, vm.Namespace, machineScope)
+	if apimachineryerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	pause := machineScope.machine.Annotations[PauseAnnotation] == "true"
+	if pause == isVMIPaused(vmi) {
+		return nil
+	}
+	if pause {
+		klog.Infof("%s: pausing the VM", machineScope.getMachineName())
+		if err := machineScope.underkubeClient.PauseVirtualMachineInstance(vmi.Namespace, vmi.Name); err != nil {
+			return fmt.Errorf("failed to pause the VM: %w", err)
+		}
+		machineScope.recordVMMutation(kubevirtproviderv1.VMPausedMutation, "")
+		return nil
+	}
+	klog.Infof("%s: unpausing the VM", machineScope.getMachineName())
+	if err := machineScope.underkubeClient.UnpauseVirtualMachineInstance(vmi.Namespace, vmi.Name); err != nil {
+		return fmt.Errorf("failed to unpause the VM: %w", err)
+	}
+	machineScope.recordVMMutation(kubevirtproviderv1.VMUnpausedMutation, "")
+	return nil
+}
+
+// isVMIPaused returns true when the guest of the VMI is paused
+func isVMIPaused(vmi *kubevirtapiv1.VirtualMachineInstance) bool {
+	if vmi == nil {
+		return false
+	}
+	for _, c := range vmi.Status.Conditions {
+		if c.Type == kubevirtapiv1.VirtualMachineInstancePaused {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
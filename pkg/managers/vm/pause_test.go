@@ -0,0 +1,87 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestSyncPause(t *testing.T) {
+	stubVMI := func(paused bool) *kubevirtapiv1.VirtualMachineInstance {
+		vmi := &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName}}
+		if paused {
+			vmi.Status.Conditions = []kubevirtapiv1.VirtualMachineInstanceCondition{
+				{Type: kubevirtapiv1.VirtualMachineInstancePaused, Status: corev1.ConditionTrue},
+			}
+		}
+		return vmi
+	}
+
+	cases := []struct {
+		name        string
+		annotation  string
+		vmi         *kubevirtapiv1.VirtualMachineInstance
+		wantPause   bool
+		wantUnpause bool
+	}{
+		{
+			name: "Running",
+			vmi:  stubVMI(false),
+		},
+		{
+			name:       "Pause",
+			annotation: "true",
+			vmi:        stubVMI(false),
+			wantPause:  true,
+		},
+		{
+			name:       "Paused",
+			annotation: "true",
+			vmi:        stubVMI(true),
+		},
+		{
+			name:        "Unpause",
+			vmi:         stubVMI(true),
+			wantUnpause: true,
+		},
+		{
+			name:       "No VMI",
+			annotation: "true",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			if tc.vmi != nil {
+				mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).Return(tc.vmi, nil)
+			} else {
+				mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).
+					Return(&kubevirtapiv1.VirtualMachineInstance{}, apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "virtualmachineinstances"}, mahcineName))
+			}
+			if tc.wantPause {
+				mockUnderkube.EXPECT().PauseVirtualMachineInstance(clusterID, mahcineName).Return(nil)
+			}
+			if tc.wantUnpause {
+				mockUnderkube.EXPECT().UnpauseVirtualMachineInstance(clusterID, mahcineName).Return(nil)
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			if tc.annotation != "" {
+				machine.Annotations = map[string]string{PauseAnnotation: tc.annotation}
+			}
+			machineScope := &machineScope{machine: machine, underkubeClient: mockUnderkube}
+			vm := &kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName}}
+			assert.NilError(t, (&manager{}).syncPause(vm, machineScope))
+		})
+	}
+}
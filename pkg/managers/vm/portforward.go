@@ -0,0 +1,27 @@
+package vm
+
+import (
+	"io"
+
+	"k8s.io/klog"
+)
+
+// PortForward opens a stream to a port of the VM of the machine through the underkube API, so a node can be
+// reached, e.g. with SSH, by its machine name without knowing its VMI or having a route to the underkube
+// pod network
+func (m *manager) PortForward(namespace, machineName string, port int) (io.ReadWriteCloser, error) {
+	machine, err := m.overkubeClient.GetMachine(namespace, machineName)
+	if err != nil {
+		return nil, err
+	}
+	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder, m.providerConfig.Get(), m.credentialsFromSecrets)
+	if err != nil {
+		return nil, err
+	}
+	if err := machineScope.validateVMNamespace(); err != nil {
+		return nil, err
+	}
+
+	klog.Infof("%s: forwarding port %d of the VM", machineScope.getMachineName(), port)
+	return machineScope.underkubeClient.PortForwardVirtualMachineInstance(machineScope.getVMNamespace(), machine.GetName(), port)
+}
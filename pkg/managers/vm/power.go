@@ -0,0 +1,52 @@
+package vm
+
+import (
+	"fmt"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// validateRunStrategy validates the run strategy of the provider spec
+func validateRunStrategy(machineName string, runStrategy kubevirtproviderv1.RunStrategy) error {
+	switch runStrategy {
+	case "", kubevirtproviderv1.RunStrategyAlways, kubevirtproviderv1.RunStrategyRerunOnFailure, kubevirtproviderv1.RunStrategyManual, kubevirtproviderv1.RunStrategyHalted:
+		return nil
+	}
+	return machinecontroller.InvalidMachineConfiguration("%v: invalid run strategy %q, expected %s, %s, %s or %s", machineName, runStrategy,
+		kubevirtproviderv1.RunStrategyAlways, kubevirtproviderv1.RunStrategyRerunOnFailure, kubevirtproviderv1.RunStrategyManual, kubevirtproviderv1.RunStrategyHalted)
+}
+
+// syncPowerState stops or starts the VM when the power state of the machine changed. It goes through the
+// KubeVirt stop and start subresources, which shut the guest down and keep the disks, rather than through
+// the update of the VM. It returns true when the VM was stopped or started.
+func (m *manager) syncPowerState(desired, live *kubevirtapiv1.VirtualMachine, machineScope *machineScope) (bool, error) {
+	stopped := isVMStopped(desired)
+	if stopped == isVMStopped(live) {
+		return false, nil
+	}
+	if stopped {
+		klog.Infof("%s: stopping the VM", machineScope.getMachineName())
+		if err := machineScope.underkubeClient.StopVirtualMachine(live.Namespace, live.Name); err != nil {
+			return false, fmt.Errorf("failed to stop the VM: %w", err)
+		}
+		machineScope.recordVMMutation(kubevirtproviderv1.VMStoppedMutation, "")
+		return true, nil
+	}
+	klog.Infof("%s: starting the VM", machineScope.getMachineName())
+	if err := machineScope.underkubeClient.StartVirtualMachine(live.Namespace, live.Name); err != nil {
+		return false, fmt.Errorf("failed to start the VM: %w", err)
+	}
+	machineScope.recordVMMutation(kubevirtproviderv1.VMStartedMutation, "")
+	return true, nil
+}
+
+// isVMStopped returns true when the VM is halted, rather than being stopped by a failure
+func isVMStopped(vm *kubevirtapiv1.VirtualMachine) bool {
+	if vm.Spec.RunStrategy != nil {
+		return *vm.Spec.RunStrategy == kubevirtapiv1.RunStrategyHalted
+	}
+	return vm.Spec.Running != nil && !*vm.Spec.Running
+}
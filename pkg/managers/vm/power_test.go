@@ -0,0 +1,94 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"gotest.tools/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestSyncPowerState(t *testing.T) {
+	stubVM := func(runStrategy kubevirtapiv1.VirtualMachineRunStrategy) *kubevirtapiv1.VirtualMachine {
+		return &kubevirtapiv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName},
+			Spec:       kubevirtapiv1.VirtualMachineSpec{RunStrategy: &runStrategy},
+		}
+	}
+
+	cases := []struct {
+		name        string
+		desired     kubevirtapiv1.VirtualMachineRunStrategy
+		live        kubevirtapiv1.VirtualMachineRunStrategy
+		wantStop    bool
+		wantStart   bool
+		wantChanged bool
+	}{
+		{
+			name:    "Running",
+			desired: kubevirtapiv1.RunStrategyAlways,
+			live:    kubevirtapiv1.RunStrategyAlways,
+		},
+		{
+			name:        "Stop",
+			desired:     kubevirtapiv1.RunStrategyHalted,
+			live:        kubevirtapiv1.RunStrategyAlways,
+			wantStop:    true,
+			wantChanged: true,
+		},
+		{
+			name:        "Start",
+			desired:     kubevirtapiv1.RunStrategyAlways,
+			live:        kubevirtapiv1.RunStrategyHalted,
+			wantStart:   true,
+			wantChanged: true,
+		},
+		{
+			name:    "Stopped",
+			desired: kubevirtapiv1.RunStrategyHalted,
+			live:    kubevirtapiv1.RunStrategyHalted,
+		},
+		{
+			name:        "Start with another run strategy",
+			desired:     kubevirtapiv1.RunStrategyRerunOnFailure,
+			live:        kubevirtapiv1.RunStrategyHalted,
+			wantStart:   true,
+			wantChanged: true,
+		},
+		{
+			name:    "Run strategy changed while running",
+			desired: kubevirtapiv1.RunStrategyRerunOnFailure,
+			live:    kubevirtapiv1.RunStrategyAlways,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			if tc.wantStop {
+				mockUnderkube.EXPECT().StopVirtualMachine(clusterID, mahcineName).Return(nil)
+			}
+			if tc.wantStart {
+				mockUnderkube.EXPECT().StartVirtualMachine(clusterID, mahcineName).Return(nil)
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope := &machineScope{machine: machine, underkubeClient: mockUnderkube}
+			changed, err := (&manager{}).syncPowerState(stubVM(tc.desired), stubVM(tc.live), machineScope)
+			assert.NilError(t, err)
+			assert.Equal(t, changed, tc.wantChanged)
+		})
+	}
+}
+
+func TestValidateRunStrategy(t *testing.T) {
+	assert.NilError(t, validateRunStrategy("machine-test", ""))
+	assert.NilError(t, validateRunStrategy("machine-test", kubevirtproviderv1.RunStrategyRerunOnFailure))
+	assert.Error(t, validateRunStrategy("machine-test", "OnFailure"),
+		`machine-test: invalid run strategy "OnFailure", expected Always, RerunOnFailure, Manual or Halted`)
+}
@@ -0,0 +1,138 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// machinePhaseProvisioning is the phase of a machine until the first update of the provider succeeds
+const machinePhaseProvisioning = "Provisioning"
+
+// defaultReadinessGates are the readiness gates of the provider specs listing none
+var defaultReadinessGates = []kubevirtproviderv1.ReadinessGate{{Type: kubevirtproviderv1.VMReadyGate}}
+
+// validateReadinessGates validates the gate types, and that a gate isn't listed twice. The ServiceEndpointReady
+// gate of a machine without service must name one.
+func validateReadinessGates(machineName string, gates []kubevirtproviderv1.ReadinessGate, serviceMode kubevirtproviderv1.ServiceMode) error {
+	seen := map[kubevirtproviderv1.ReadinessGate]bool{}
+	for _, gate := range gates {
+		switch gate.Type {
+		case kubevirtproviderv1.VMReadyGate, kubevirtproviderv1.AgentConnectedGate, kubevirtproviderv1.AddressPublishedGate:
+			if gate.ServiceName != "" {
+				return machinecontroller.InvalidMachineConfiguration("%v: readiness gate %s: serviceName is only valid for the %s gate", machineName, gate.Type, kubevirtproviderv1.ServiceEndpointReadyGate)
+			}
+		case kubevirtproviderv1.ServiceEndpointReadyGate:
+			if gate.ServiceName == "" && serviceMode == kubevirtproviderv1.NoServiceMode {
+				return machinecontroller.InvalidMachineConfiguration("%v: readiness gate %s: missing serviceName, the %s service mode creates no service", machineName, gate.Type, serviceMode)
+			}
+		default:
+			return machinecontroller.InvalidMachineConfiguration("%v: unknown readiness gate %q", machineName, gate.Type)
+		}
+		if seen[gate] {
+			return machinecontroller.InvalidMachineConfiguration("%v: duplicate readiness gate %s", machineName, gate.Type)
+		}
+		seen[gate] = true
+	}
+	return nil
+}
+
+// isProvisioning returns true until the machine controller moved the machine to the Provisioned phase
+func (s *machineScope) isProvisioning() bool {
+	return s.machine.Status.Phase == nil || *s.machine.Status.Phase == machinePhaseProvisioning
+}
+
+// requeueIfInstancePending keeps the update failing until the readiness gates of the machine pass, so the
+// machine controller doesn't report the machine provisioned before. The gates that didn't pass are
+// reported in the ReadinessGatesPassed condition.
+func (m *manager) requeueIfInstancePending(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	gates := machineScope.machineProviderSpec.ReadinessGates
+	if len(gates) == 0 {
+		gates = defaultReadinessGates
+	}
+
+	var pending []string
+	for _, gate := range gates {
+		passed, err := m.readinessGatePassed(gate, vm, machineScope)
+		if err != nil {
+			return fmt.Errorf("failed to check the readiness gate %s: %w", gate.Type, err)
+		}
+		if !passed {
+			pending = append(pending, string(gate.Type))
+		}
+	}
+
+	if len(pending) > 0 {
+		message := fmt.Sprintf("pending readiness gates: %s", strings.Join(pending, ", "))
+		machineScope.setCondition(conditions.False(kubevirtproviderv1.ReadinessGatesPassedCondition, "GatesPending", message))
+		klog.Infof("%s: %s, returning an error to requeue", machineScope.getMachineName(), message)
+		return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
+	}
+	machineScope.setCondition(conditions.True(kubevirtproviderv1.ReadinessGatesPassedCondition, "GatesPassed"))
+	return nil
+}
+
+func (m *manager) readinessGatePassed(gate kubevirtproviderv1.ReadinessGate, vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) (bool, error) {
+	switch gate.Type {
+	case kubevirtproviderv1.VMReadyGate:
+		return vm.Status.Ready, nil
+	case kubevirtproviderv1.AgentConnectedGate:
+		condition := conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.AgentConnectedCondition)
+		return condition != nil && condition.Status == corev1.ConditionTrue, nil
+	case kubevirtproviderv1.AddressPublishedGate:
+		for _, address := range machineScope.machine.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				return true, nil
+			}
+		}
+		return false, nil
+	case kubevirtproviderv1.ServiceEndpointReadyGate:
+		return m.serviceEndpointReady(gate.ServiceName, vm, machineScope)
+	}
+	return false, fmt.Errorf("unknown readiness gate %q", gate.Type)
+}
+
+// serviceEndpointReady returns true when the virt-launcher pod of the VM is a ready endpoint of the
+// service, the service publishing the machine when the name is empty
+func (m *manager) serviceEndpointReady(serviceName string, vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) (bool, error) {
+	if serviceName == "" && machineScope.isSharedServiceMode() {
+		serviceName = sharedServiceName(machineScope.machine)
+	} else if serviceName == "" {
+		serviceName = vm.Name
+	}
+	vmi, err := m.getUnderkubeVMI(vm.Name, vm.Namespace, machineScope)
+	if apimachineryerrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	pod, err := m.getLauncherPod(vmi, machineScope)
+	if err != nil || pod == nil {
+		return false, err
+	}
+	endpoints, err := machineScope.underkubeClient.GetEndpoints(serviceName, vm.Namespace, k8smetav1.GetOptions{})
+	if apimachineryerrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			if address.TargetRef != nil && address.TargetRef.Kind == "Pod" && address.TargetRef.Name == pod.Name {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,184 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestValidateReadinessGates(t *testing.T) {
+	cases := []struct {
+		name        string
+		gates       []kubevirtproviderv1.ReadinessGate
+		serviceMode kubevirtproviderv1.ServiceMode
+		wantErr     string
+	}{
+		{
+			name: "No gates",
+		},
+		{
+			name: "All gates",
+			gates: []kubevirtproviderv1.ReadinessGate{
+				{Type: kubevirtproviderv1.VMReadyGate},
+				{Type: kubevirtproviderv1.AgentConnectedGate},
+				{Type: kubevirtproviderv1.AddressPublishedGate},
+				{Type: kubevirtproviderv1.ServiceEndpointReadyGate},
+				{Type: kubevirtproviderv1.ServiceEndpointReadyGate, ServiceName: "ingress"},
+			},
+		},
+		{
+			name:    "Unknown gate",
+			gates:   []kubevirtproviderv1.ReadinessGate{{Type: "NodeReady"}},
+			wantErr: `machine-test: unknown readiness gate "NodeReady"`,
+		},
+		{
+			name:    "Service name of another gate",
+			gates:   []kubevirtproviderv1.ReadinessGate{{Type: kubevirtproviderv1.VMReadyGate, ServiceName: "ingress"}},
+			wantErr: "machine-test: readiness gate VMReady: serviceName is only valid for the ServiceEndpointReady gate",
+		},
+		{
+			name:    "Duplicate gate",
+			gates:   []kubevirtproviderv1.ReadinessGate{{Type: kubevirtproviderv1.VMReadyGate}, {Type: kubevirtproviderv1.VMReadyGate}},
+			wantErr: "machine-test: duplicate readiness gate VMReady",
+		},
+		{
+			name:        "Service endpoint of a machine without service",
+			gates:       []kubevirtproviderv1.ReadinessGate{{Type: kubevirtproviderv1.ServiceEndpointReadyGate, ServiceName: "ingress"}},
+			serviceMode: kubevirtproviderv1.NoServiceMode,
+		},
+		{
+			name:        "Service endpoint of the missing service of the machine",
+			gates:       []kubevirtproviderv1.ReadinessGate{{Type: kubevirtproviderv1.ServiceEndpointReadyGate}},
+			serviceMode: kubevirtproviderv1.NoServiceMode,
+			wantErr:     "machine-test: readiness gate ServiceEndpointReady: missing serviceName, the None service mode creates no service",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateReadinessGates("machine-test", tc.gates, tc.serviceMode)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestRequeueIfInstancePending(t *testing.T) {
+	const launcherPodName = "virt-launcher-machine-test-abcde"
+	endpoints := func(podName string) *corev1.Endpoints {
+		return &corev1.Endpoints{Subsets: []corev1.EndpointSubset{{
+			Addresses: []corev1.EndpointAddress{{IP: "10.128.0.10", TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: podName}}},
+		}}}
+	}
+
+	cases := []struct {
+		name          string
+		gates         []kubevirtproviderv1.ReadinessGate
+		vmReady       bool
+		agent         bool
+		addresses     []corev1.NodeAddress
+		endpoints     *corev1.Endpoints
+		wantEndpoints string
+		wantPending   string
+	}{
+		{
+			name:        "Default gate pending",
+			wantPending: "pending readiness gates: VMReady",
+		},
+		{
+			name:    "Default gate passed",
+			vmReady: true,
+		},
+		{
+			name: "All gates pending",
+			gates: []kubevirtproviderv1.ReadinessGate{
+				{Type: kubevirtproviderv1.VMReadyGate},
+				{Type: kubevirtproviderv1.AgentConnectedGate},
+				{Type: kubevirtproviderv1.AddressPublishedGate},
+			},
+			addresses:   []corev1.NodeAddress{{Type: corev1.NodeInternalDNS, Address: mahcineName}},
+			wantPending: "pending readiness gates: VMReady, AgentConnected, AddressPublished",
+		},
+		{
+			name: "All gates passed",
+			gates: []kubevirtproviderv1.ReadinessGate{
+				{Type: kubevirtproviderv1.VMReadyGate},
+				{Type: kubevirtproviderv1.AgentConnectedGate},
+				{Type: kubevirtproviderv1.AddressPublishedGate},
+			},
+			vmReady:   true,
+			agent:     true,
+			addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "10.0.0.5"}},
+		},
+		{
+			name:          "Machine service endpoint ready",
+			gates:         []kubevirtproviderv1.ReadinessGate{{Type: kubevirtproviderv1.ServiceEndpointReadyGate}},
+			endpoints:     endpoints(launcherPodName),
+			wantEndpoints: mahcineName,
+		},
+		{
+			name:          "Custom service endpoint of another VM",
+			gates:         []kubevirtproviderv1.ReadinessGate{{Type: kubevirtproviderv1.ServiceEndpointReadyGate, ServiceName: "ingress"}},
+			endpoints:     endpoints("virt-launcher-other-machine-fghij"),
+			wantEndpoints: "ingress",
+			wantPending:   "pending readiness gates: ServiceEndpointReady",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.Status.Addresses = tc.addresses
+			providerStatus := &kubevirtproviderv1.KubevirtMachineProviderStatus{}
+			if tc.agent {
+				providerStatus.MachineConditions = []kubevirtproviderv1.KubevirtMachineCondition{
+					conditions.True(kubevirtproviderv1.AgentConnectedCondition, "AgentConnected"),
+				}
+			}
+			machineScope := &machineScope{
+				machine:               machine,
+				underkubeClient:       mockUnderkube,
+				machineProviderSpec:   &kubevirtproviderv1.KubevirtMachineProviderSpec{ReadinessGates: tc.gates},
+				machineProviderStatus: providerStatus,
+			}
+			vm := &kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName}}
+			vm.Status.Ready = tc.vmReady
+
+			if tc.wantEndpoints != "" {
+				vmi := &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName, UID: "vmi-uid"}}
+				mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).Return(vmi, nil)
+				mockUnderkube.EXPECT().ListPods(clusterID, gomock.Any()).Return(&corev1.PodList{Items: []corev1.Pod{
+					{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: launcherPodName}},
+				}}, nil)
+				mockUnderkube.EXPECT().GetEndpoints(tc.wantEndpoints, clusterID, gomock.Any()).Return(tc.endpoints, nil)
+			}
+
+			err = (&manager{}).requeueIfInstancePending(vm, machineScope)
+			condition := conditions.Find(machineScope.machineProviderStatus.MachineConditions, kubevirtproviderv1.ReadinessGatesPassedCondition)
+			assert.Assert(t, condition != nil)
+			if tc.wantPending != "" {
+				_, ok := err.(*machinecontroller.RequeueAfterError)
+				assert.Assert(t, ok, "unexpected error %v", err)
+				assert.Equal(t, condition.Status, corev1.ConditionFalse)
+				assert.Equal(t, condition.Message, tc.wantPending)
+			} else {
+				assert.NilError(t, err)
+				assert.Equal(t, condition.Status, corev1.ConditionTrue)
+			}
+		})
+	}
+}
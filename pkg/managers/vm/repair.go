@@ -0,0 +1,128 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// instanceNotFoundMessage is the error message the machine controller sets when it moves a provisioned
+// machine to the Failed phase because Exists didn't find its VM
+const instanceNotFoundMessage = "Can't find created instance."
+
+// RepairProviderID brings back a machine the machine controller failed because it didn't find its VM, when
+// the VM actually exists, e.g. after the providerID was left pointing at a renamed VM or the underkube was
+// briefly unreachable. The VM must be the one of the machine: named after it, carrying its cluster ID, and
+// created for it when it carries a creation intent.
+// When it isn't in the VM namespace of the machine, e.g. the namespace of the kubeconfig context changed
+// before the namespace was recorded, it is looked up by the cluster ID label in the other namespaces the
+// credentials allow, and its namespace is recorded. The providerID is reset and the Failed phase cleared,
+// so the machine controller reconciles the machine again. It returns true when the machine was repaired.
+func (m *manager) RepairProviderID(machine *machinev1.Machine) (bool, error) {
+	if !isInstanceNotFound(machine) {
+		return false, nil
+	}
+	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder, m.providerConfig.Get(), m.credentialsFromSecrets)
+	if err != nil {
+		return false, err
+	}
+	if err := machineScope.validateVMNamespace(); err != nil {
+		return false, err
+	}
+
+	vm, err := m.getUnderkubeVM(machine.GetName(), machineScope.getVMNamespace(), machineScope)
+	if apimachineryerrors.IsNotFound(err) {
+		vm, err = findVMByLabels(machineScope)
+	}
+	if err != nil {
+		return false, err
+	}
+	if vm == nil {
+		return false, nil
+	}
+	if err := vmBelongsToMachine(vm, machine); err != nil {
+		klog.Warningf("%s: not repairing the failed machine: %v", machineScope.getMachineName(), err)
+		return false, nil
+	}
+
+	klog.Infof("%s: VM %s/%s still exists, repairing the providerID of the failed machine", machineScope.getMachineName(), vm.Namespace, vm.Name)
+	machineScope.setProviderID(vm)
+	machineScope.machineProviderStatus.VMNamespace = vm.Namespace
+	machineScope.machine.Status.Phase = nil
+	machineScope.machine.Status.ErrorReason = nil
+	machineScope.machine.Status.ErrorMessage = nil
+	if err := machineScope.patchMachine(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isInstanceNotFound returns true when the machine controller failed the machine because it didn't find its
+// VM. The machines the provider failed itself carry an error reason and are left alone.
+func isInstanceNotFound(machine *machinev1.Machine) bool {
+	status := machine.Status
+	return status.Phase != nil && *status.Phase == machinePhaseFailed &&
+		status.ErrorReason == nil &&
+		status.ErrorMessage != nil && *status.ErrorMessage == instanceNotFoundMessage
+}
+
+// findVMByLabels looks the VM of the machine up by its cluster ID label in the namespaces the credentials
+// allow besides the VM namespace of the machine. It returns nil when none, or more than one, is found, and
+// skips the namespaces the credentials can't list.
+func findVMByLabels(machineScope *machineScope) (*kubevirtapiv1.VirtualMachine, error) {
+	clusterID, ok := render.ClusterID(machineScope.machine)
+	if !ok {
+		return nil, nil
+	}
+	searched := map[string]bool{machineScope.getVMNamespace(): true, "*": true}
+	var found []kubevirtapiv1.VirtualMachine
+	for _, namespace := range append([]string{machineScope.underkubeClient.DefaultNamespace()}, machineScope.underkubeClient.AllowedNamespaces()...) {
+		if namespace == "" || searched[namespace] {
+			continue
+		}
+		searched[namespace] = true
+		vms, err := machineScope.underkubeClient.ListVirtualMachine(namespace, &k8smetav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + "=" + clusterID})
+		if apimachineryerrors.IsForbidden(err) {
+			klog.V(3).Infof("%s: can't list the VMs of namespace %s: %v", machineScope.getMachineName(), namespace, err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the VMs of namespace %s: %w", namespace, err)
+		}
+		for _, vm := range vms.Items {
+			if vm.Name == machineScope.getMachineName() && vmBelongsToMachine(&vm, machineScope.machine) == nil {
+				found = append(found, vm)
+			}
+		}
+	}
+	if len(found) > 1 {
+		klog.Warningf("%s: not repairing the failed machine, %d VMs of namespaces %s and %s belong to it", machineScope.getMachineName(), len(found), found[0].Namespace, found[1].Namespace)
+		return nil, nil
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return &found[0], nil
+}
+
+// vmBelongsToMachine checks that the VM carries the labels the provider renders for the machine, and the
+// creation intent of the machine when it carries one. A VM left behind by a former machine of the same name
+// carries the UID of that machine.
+func vmBelongsToMachine(vm *kubevirtapiv1.VirtualMachine, machine *machinev1.Machine) error {
+	clusterID, _ := render.ClusterID(machine)
+	if vmClusterID, _ := render.ClusterID(&machinev1.Machine{ObjectMeta: vm.ObjectMeta}); vmClusterID != clusterID {
+		return fmt.Errorf("VM %s/%s belongs to cluster %q, not %q", vm.Namespace, vm.Name, vmClusterID, clusterID)
+	}
+	if vm.Spec.Template == nil || vm.Spec.Template.ObjectMeta.Labels[render.VMLabel] != machine.GetName() {
+		return fmt.Errorf("VM %s/%s isn't labeled %s=%s", vm.Namespace, vm.Name, render.VMLabel, machine.GetName())
+	}
+	if intent, ok := vm.Annotations[CreationIntentAnnotation]; ok && intent != string(machine.UID) {
+		return fmt.Errorf("VM %s/%s was created for machine %q, not %q", vm.Namespace, vm.Name, intent, machine.UID)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestRepairProviderID(t *testing.T) {
+	failed := machinePhaseFailed
+	running := "Running"
+	notFoundMessage := instanceNotFoundMessage
+	otherMessage := "can't create the VM"
+	providerReason := machinev1.MachineStatusError("InvalidConfiguration")
+
+	cases := []struct {
+		name         string
+		phase        *string
+		errorReason  *machinev1.MachineStatusError
+		errorMessage *string
+		vmMissing    bool
+		vmClusterID  string
+		vmLabel      string
+		vmIntent     string
+		// allowedNamespaces are searched when the VM is missing, listVMs lists the VM in all of them
+		allowedNamespaces []string
+		listVMs           bool
+		listErr           error
+		wantGet           bool
+		wantRepaired      bool
+		wantVMNamespace   string
+	}{
+		{
+			name:  "Running machine",
+			phase: &running,
+		},
+		{
+			name:         "Failed for another reason",
+			phase:        &failed,
+			errorMessage: &otherMessage,
+		},
+		{
+			name:         "Failed by the provider",
+			phase:        &failed,
+			errorReason:  &providerReason,
+			errorMessage: &notFoundMessage,
+		},
+		{
+			name:         "VM is gone",
+			phase:        &failed,
+			errorMessage: &notFoundMessage,
+			vmMissing:    true,
+			wantGet:      true,
+		},
+		{
+			name:              "VM is gone from the allowed namespaces",
+			phase:             &failed,
+			errorMessage:      &notFoundMessage,
+			vmMissing:         true,
+			allowedNamespaces: []string{"tenant-vms", "*"},
+			wantGet:           true,
+		},
+		{
+			name:              "VM in another allowed namespace",
+			phase:             &failed,
+			errorMessage:      &notFoundMessage,
+			vmMissing:         true,
+			allowedNamespaces: []string{"tenant-vms"},
+			listVMs:           true,
+			wantGet:           true,
+			wantRepaired:      true,
+			wantVMNamespace:   "tenant-vms",
+		},
+		{
+			name:              "VM in several allowed namespaces",
+			phase:             &failed,
+			errorMessage:      &notFoundMessage,
+			vmMissing:         true,
+			allowedNamespaces: []string{"tenant-vms", "tenant-vms-2"},
+			listVMs:           true,
+			wantGet:           true,
+		},
+		{
+			name:              "Allowed namespace can't be listed",
+			phase:             &failed,
+			errorMessage:      &notFoundMessage,
+			vmMissing:         true,
+			allowedNamespaces: []string{"tenant-vms"},
+			listErr:           apimachineryerrors.NewForbidden(schema.GroupResource{Resource: "virtualmachines"}, "", nil),
+			wantGet:           true,
+		},
+		{
+			name:         "VM of another cluster",
+			phase:        &failed,
+			errorMessage: &notFoundMessage,
+			vmClusterID:  "other-cluster",
+			wantGet:      true,
+		},
+		{
+			name:         "VM of another machine",
+			phase:        &failed,
+			errorMessage: &notFoundMessage,
+			vmLabel:      "other-machine",
+			wantGet:      true,
+		},
+		{
+			name:         "VM created for another machine",
+			phase:        &failed,
+			errorMessage: &notFoundMessage,
+			vmIntent:     "other-machine-uid",
+			wantGet:      true,
+		},
+		{
+			name:              "VM created for another machine in another allowed namespace",
+			phase:             &failed,
+			errorMessage:      &notFoundMessage,
+			vmMissing:         true,
+			vmIntent:          "other-machine-uid",
+			allowedNamespaces: []string{"tenant-vms"},
+			listVMs:           true,
+			wantGet:           true,
+		},
+		{
+			name:            "VM created for the machine",
+			phase:           &failed,
+			errorMessage:    &notFoundMessage,
+			vmIntent:        "machine-uid",
+			wantGet:         true,
+			wantRepaired:    true,
+			wantVMNamespace: clusterID,
+		},
+		{
+			name:            "VM exists",
+			phase:           &failed,
+			errorMessage:    &notFoundMessage,
+			wantGet:         true,
+			wantRepaired:    true,
+			wantVMNamespace: clusterID,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "kubevirt:///default/renamed-vm")
+			assert.NilError(t, err)
+			machine.UID = "machine-uid"
+			machine.Status.Phase = tc.phase
+			machine.Status.ErrorReason = tc.errorReason
+			machine.Status.ErrorMessage = tc.errorMessage
+			underkubeClientBuilder := func(overkube.Client, string, string) (underkube.Client, error) {
+				return mockUnderkube, nil
+			}
+			machineScope, err := stubMachineScope(machine.DeepCopy(), mockOverkube, underkubeClientBuilder)
+			assert.NilError(t, err)
+			vm := stubVirtualMachine(t, machineScope)
+			if tc.vmClusterID != "" {
+				vm.Labels = map[string]string{machinev1.MachineClusterIDLabel: tc.vmClusterID}
+			}
+			if tc.vmLabel != "" {
+				vm.Spec.Template.ObjectMeta.Labels[render.VMLabel] = tc.vmLabel
+			}
+			if tc.vmIntent != "" {
+				vm.Annotations = map[string]string{CreationIntentAnnotation: tc.vmIntent}
+			}
+
+			if tc.wantGet {
+				mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+				mockUnderkube.EXPECT().AllowedNamespaces().Return(tc.allowedNamespaces).AnyTimes()
+				if tc.vmMissing {
+					mockUnderkube.EXPECT().GetVirtualMachine(clusterID, mahcineName, gomock.Any()).
+						Return(nil, apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "virtualmachines"}, mahcineName))
+					for _, namespace := range tc.allowedNamespaces {
+						if namespace == "*" {
+							continue
+						}
+						namespaceVM := *vm.DeepCopy()
+						namespaceVM.Namespace = namespace
+						list := &kubevirtapiv1.VirtualMachineList{}
+						if tc.listVMs {
+							list.Items = append(list.Items, namespaceVM)
+						}
+						mockUnderkube.EXPECT().ListVirtualMachine(namespace, &k8smetav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + "=" + clusterID}).Return(list, tc.listErr)
+					}
+				} else {
+					mockUnderkube.EXPECT().GetVirtualMachine(clusterID, mahcineName, gomock.Any()).Return(vm, nil)
+				}
+			}
+			var patched, statusPatched *machinev1.Machine
+			if tc.wantRepaired {
+				mockOverkube.EXPECT().PatchMachine(gomock.Any(), gomock.Any()).DoAndReturn(func(machine, _ *machinev1.Machine) error {
+					patched = machine.DeepCopy()
+					return nil
+				})
+				mockOverkube.EXPECT().StatusPatchMachine(gomock.Any(), gomock.Any()).DoAndReturn(func(machine, _ *machinev1.Machine) error {
+					statusPatched = machine.DeepCopy()
+					return nil
+				})
+			}
+
+			m := New(underkubeClientBuilder, mockOverkube, nil, true)
+			repaired, err := m.RepairProviderID(machine)
+			assert.NilError(t, err)
+			assert.Equal(t, repaired, tc.wantRepaired)
+			if tc.wantRepaired {
+				assert.Equal(t, *patched.Spec.ProviderID, "kubevirt:///"+machine.Namespace+"/"+mahcineName)
+				assert.Assert(t, statusPatched.Status.Phase == nil)
+				assert.Assert(t, statusPatched.Status.ErrorMessage == nil)
+				providerStatus, err := kubevirtproviderv1.ProviderStatusFromRawExtension(statusPatched.Status.ProviderStatus)
+				assert.NilError(t, err)
+				assert.Equal(t, providerStatus.VMNamespace, tc.wantVMNamespace)
+			}
+		})
+	}
+}
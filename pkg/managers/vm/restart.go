@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"fmt"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// restartingVMIAnnotation holds the UID of the VMI the provider restarted, until the VM runs a new VMI and
+// is ready again
+const restartingVMIAnnotation = "kubevirt.io/restarting-vmi"
+
+// syncRestart restarts the VM once per value of the restart annotation of the machine, through the KubeVirt
+// restart subresource, and reports the restart done once the VM runs a new VMI and is ready. A stopped VM
+// has nothing to restart, the request is done right away.
+func (m *manager) syncRestart(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	annotations := machineScope.machine.Annotations
+	token := annotations[render.RestartAnnotation]
+	if token == "" || annotations[render.RestartedAnnotation] == token {
+		return nil
+	}
+
+	if isVMStopped(vm) {
+		klog.Infof("%s: the VM is stopped, nothing to restart", machineScope.getMachineName())
+		delete(annotations, restartingVMIAnnotation)
+		annotations[render.RestartedAnnotation] = token
+		return nil
+	}
+	vmi, err := m.getUnderkubeVMI(vm.Name, vm.Namespace, machineScope)
+	if err != nil && !apimachineryerrors.IsNotFound(err) {
+		return err
+	}
+
+	restartingVMI, restarting := annotations[restartingVMIAnnotation]
+	switch {
+	case err != nil:
+		klog.V(3).Infof("%s: waiting for the VMI before restarting the VM", machineScope.getMachineName())
+		return nil
+	case !restarting:
+		klog.Infof("%s: restarting the VM, as requested by %s", machineScope.getMachineName(), token)
+		if err := machineScope.underkubeClient.RestartVirtualMachine(vm.Namespace, vm.Name); err != nil {
+			return fmt.Errorf("failed to restart the VM: %w", err)
+		}
+		annotations[restartingVMIAnnotation] = string(vmi.UID)
+		machineScope.recordVMMutation(kubevirtproviderv1.VMRestartedMutation, "")
+		return nil
+	case string(vmi.UID) == restartingVMI || !vm.Status.Ready:
+		klog.V(3).Infof("%s: waiting for the VM to be ready after its restart", machineScope.getMachineName())
+		return nil
+	}
+	klog.Infof("%s: the VM restarted", machineScope.getMachineName())
+	delete(annotations, restartingVMIAnnotation)
+	annotations[render.RestartedAnnotation] = token
+	return nil
+}
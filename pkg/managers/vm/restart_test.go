@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	"gotest.tools/assert"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestSyncRestart(t *testing.T) {
+	const token = "2026-10-15T10:00:00Z"
+	stubVMI := func(uid types.UID) *kubevirtapiv1.VirtualMachineInstance {
+		return &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName, UID: uid}}
+	}
+
+	cases := []struct {
+		name            string
+		annotations     map[string]string
+		stopped         bool
+		ready           bool
+		vmi             *kubevirtapiv1.VirtualMachineInstance
+		wantRestart     bool
+		wantAnnotations map[string]string
+	}{
+		{
+			name:            "Not requested",
+			wantAnnotations: map[string]string{},
+		},
+		{
+			name:            "Already restarted",
+			annotations:     map[string]string{render.RestartAnnotation: token, render.RestartedAnnotation: token},
+			wantAnnotations: map[string]string{render.RestartAnnotation: token, render.RestartedAnnotation: token},
+		},
+		{
+			name:            "Restart",
+			annotations:     map[string]string{render.RestartAnnotation: token},
+			ready:           true,
+			vmi:             stubVMI("vmi-1"),
+			wantRestart:     true,
+			wantAnnotations: map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+		},
+		{
+			name:            "Restart requested again",
+			annotations:     map[string]string{render.RestartAnnotation: "new", render.RestartedAnnotation: token},
+			ready:           true,
+			vmi:             stubVMI("vmi-1"),
+			wantRestart:     true,
+			wantAnnotations: map[string]string{render.RestartAnnotation: "new", render.RestartedAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+		},
+		{
+			name:            "Old VMI still running",
+			annotations:     map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+			ready:           true,
+			vmi:             stubVMI("vmi-1"),
+			wantAnnotations: map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+		},
+		{
+			name:            "No VMI",
+			annotations:     map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+			wantAnnotations: map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+		},
+		{
+			name:            "New VMI not ready",
+			annotations:     map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+			vmi:             stubVMI("vmi-2"),
+			wantAnnotations: map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+		},
+		{
+			name:            "Restarted",
+			annotations:     map[string]string{render.RestartAnnotation: token, restartingVMIAnnotation: "vmi-1"},
+			ready:           true,
+			vmi:             stubVMI("vmi-2"),
+			wantAnnotations: map[string]string{render.RestartAnnotation: token, render.RestartedAnnotation: token},
+		},
+		{
+			name:            "Stopped",
+			annotations:     map[string]string{render.RestartAnnotation: token},
+			stopped:         true,
+			wantAnnotations: map[string]string{render.RestartAnnotation: token, render.RestartedAnnotation: token},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			pending := tc.annotations[render.RestartAnnotation] != "" && tc.annotations[render.RestartAnnotation] != tc.annotations[render.RestartedAnnotation]
+			if pending && !tc.stopped && tc.vmi != nil {
+				mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).Return(tc.vmi, nil)
+			} else if pending && !tc.stopped {
+				mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).
+					Return(&kubevirtapiv1.VirtualMachineInstance{}, apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "virtualmachineinstances"}, mahcineName))
+			}
+			if tc.wantRestart {
+				mockUnderkube.EXPECT().RestartVirtualMachine(clusterID, mahcineName).Return(nil)
+			}
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.Annotations = map[string]string{}
+			for key, value := range tc.annotations {
+				machine.Annotations[key] = value
+			}
+			machineScope := &machineScope{machine: machine, underkubeClient: mockUnderkube}
+			running := !tc.stopped
+			vm := &kubevirtapiv1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName},
+				Spec:       kubevirtapiv1.VirtualMachineSpec{Running: &running},
+				Status:     kubevirtapiv1.VirtualMachineStatus{Ready: tc.ready},
+			}
+			assert.NilError(t, (&manager{}).syncRestart(vm, machineScope))
+			assert.DeepEqual(t, machine.Annotations, tc.wantAnnotations)
+			if tc.wantRestart {
+				assert.Equal(t, len(machineScope.machineProviderStatus.VMMutations), 1)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// validateTolerations validates the tolerations the way the underkube validates them on the virt-launcher
+// pod, so a machine with an invalid one fails instead of its VMI never getting a pod
+func validateTolerations(machineName string, tolerations []corev1.Toleration) error {
+	for i, toleration := range tolerations {
+		if toleration.Key != "" {
+			if errs := validation.IsQualifiedName(toleration.Key); len(errs) > 0 {
+				return machinecontroller.InvalidMachineConfiguration("%v: tolerations[%d]: invalid key %q: %s", machineName, i, toleration.Key, strings.Join(errs, ", "))
+			}
+		}
+		switch toleration.Operator {
+		case "", corev1.TolerationOpEqual:
+			if toleration.Key == "" {
+				return machinecontroller.InvalidMachineConfiguration("%v: tolerations[%d]: an empty key needs the %s operator", machineName, i, corev1.TolerationOpExists)
+			}
+			if errs := validation.IsValidLabelValue(toleration.Value); len(errs) > 0 {
+				return machinecontroller.InvalidMachineConfiguration("%v: tolerations[%d]: invalid value %q: %s", machineName, i, toleration.Value, strings.Join(errs, ", "))
+			}
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				return machinecontroller.InvalidMachineConfiguration("%v: tolerations[%d]: the %s operator takes no value", machineName, i, corev1.TolerationOpExists)
+			}
+		default:
+			return machinecontroller.InvalidMachineConfiguration("%v: tolerations[%d]: unknown operator %q", machineName, i, toleration.Operator)
+		}
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return machinecontroller.InvalidMachineConfiguration("%v: tolerations[%d]: unknown effect %q", machineName, i, toleration.Effect)
+		}
+		if toleration.TolerationSeconds != nil && toleration.Effect != corev1.TaintEffectNoExecute {
+			return machinecontroller.InvalidMachineConfiguration("%v: tolerations[%d]: tolerationSeconds is only valid for the %s effect", machineName, i, corev1.TaintEffectNoExecute)
+		}
+	}
+	return nil
+}
+
+// validateAffinity validates what the underkube would refuse on the virt-launcher pod: the weights of the
+// preferred terms, the topology keys and label selectors of the pod terms, and an empty required node
+// affinity, which matches no node
+func validateAffinity(machineName string, affinity *corev1.Affinity) error {
+	if affinity == nil {
+		return nil
+	}
+	if nodeAffinity := affinity.NodeAffinity; nodeAffinity != nil {
+		if required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil && len(required.NodeSelectorTerms) == 0 {
+			return machinecontroller.InvalidMachineConfiguration("%v: affinity.nodeAffinity: the required node selector has no term", machineName)
+		}
+		for i, term := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+			if err := validateAffinityWeight(term.Weight); err != nil {
+				return machinecontroller.InvalidMachineConfiguration("%v: affinity.nodeAffinity: preferred term %d: %v", machineName, i, err)
+			}
+		}
+	}
+	if podAffinity := affinity.PodAffinity; podAffinity != nil {
+		if err := validatePodAffinityTerms(podAffinity.RequiredDuringSchedulingIgnoredDuringExecution, podAffinity.PreferredDuringSchedulingIgnoredDuringExecution); err != nil {
+			return machinecontroller.InvalidMachineConfiguration("%v: affinity.podAffinity: %v", machineName, err)
+		}
+	}
+	if podAntiAffinity := affinity.PodAntiAffinity; podAntiAffinity != nil {
+		if err := validatePodAffinityTerms(podAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, podAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution); err != nil {
+			return machinecontroller.InvalidMachineConfiguration("%v: affinity.podAntiAffinity: %v", machineName, err)
+		}
+	}
+	return nil
+}
+
+func validatePodAffinityTerms(required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm) error {
+	for i, term := range required {
+		if err := validatePodAffinityTerm(term); err != nil {
+			return fmt.Errorf("required term %d: %v", i, err)
+		}
+	}
+	for i, term := range preferred {
+		if err := validateAffinityWeight(term.Weight); err != nil {
+			return fmt.Errorf("preferred term %d: %v", i, err)
+		}
+		if err := validatePodAffinityTerm(term.PodAffinityTerm); err != nil {
+			return fmt.Errorf("preferred term %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+func validatePodAffinityTerm(term corev1.PodAffinityTerm) error {
+	if term.TopologyKey == "" {
+		return fmt.Errorf("missing topologyKey")
+	}
+	if errs := validation.IsQualifiedName(term.TopologyKey); len(errs) > 0 {
+		return fmt.Errorf("invalid topologyKey %q: %s", term.TopologyKey, strings.Join(errs, ", "))
+	}
+	if _, err := k8smetav1.LabelSelectorAsSelector(term.LabelSelector); err != nil {
+		return fmt.Errorf("invalid labelSelector: %v", err)
+	}
+	return nil
+}
+
+func validateAffinityWeight(weight int32) error {
+	if weight < 1 || weight > 100 {
+		return fmt.Errorf("weight %d isn't in the 1-100 range", weight)
+	}
+	return nil
+}
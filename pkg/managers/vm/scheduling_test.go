@@ -0,0 +1,136 @@
+package vm
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateTolerations(t *testing.T) {
+	seconds := int64(300)
+	cases := []struct {
+		name        string
+		tolerations []corev1.Toleration
+		wantErr     string
+	}{
+		{
+			name: "Accept the tolerations of reserved nodes",
+			tolerations: []corev1.Toleration{
+				{Key: "example.com/tenant-capacity", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule},
+				{Key: "node.kubernetes.io/unreachable", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute, TolerationSeconds: &seconds},
+				{Operator: corev1.TolerationOpExists},
+			},
+		},
+		{
+			name:        "Reject an invalid key",
+			tolerations: []corev1.Toleration{{Key: "tenant capacity", Operator: corev1.TolerationOpExists}},
+			wantErr:     `machine-test: tolerations[0]: invalid key "tenant capacity": name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')`,
+		},
+		{
+			name:        "Reject an empty key with the Equal operator",
+			tolerations: []corev1.Toleration{{Value: "true"}},
+			wantErr:     "machine-test: tolerations[0]: an empty key needs the Exists operator",
+		},
+		{
+			name:        "Reject a value with the Exists operator",
+			tolerations: []corev1.Toleration{{Key: "tenant", Operator: corev1.TolerationOpExists, Value: "true"}},
+			wantErr:     "machine-test: tolerations[0]: the Exists operator takes no value",
+		},
+		{
+			name:        "Reject an unknown operator",
+			tolerations: []corev1.Toleration{{Key: "tenant", Operator: "In"}},
+			wantErr:     `machine-test: tolerations[0]: unknown operator "In"`,
+		},
+		{
+			name:        "Reject an unknown effect",
+			tolerations: []corev1.Toleration{{Key: "tenant", Effect: "NoStart"}},
+			wantErr:     `machine-test: tolerations[0]: unknown effect "NoStart"`,
+		},
+		{
+			name:        "Reject tolerationSeconds without the NoExecute effect",
+			tolerations: []corev1.Toleration{{Key: "tenant", Effect: corev1.TaintEffectNoSchedule, TolerationSeconds: &seconds}},
+			wantErr:     "machine-test: tolerations[0]: tolerationSeconds is only valid for the NoExecute effect",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateTolerations("machine-test", tc.tolerations)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateAffinity(t *testing.T) {
+	controlPlaneTerm := corev1.PodAffinityTerm{
+		LabelSelector: &k8smetav1.LabelSelector{MatchLabels: map[string]string{
+			"machine.openshift.io/cluster-api-cluster":      "tenant-a",
+			"machine.openshift.io/cluster-api-machine-role": "master",
+		}},
+		TopologyKey: "kubernetes.io/hostname",
+	}
+	cases := []struct {
+		name     string
+		affinity *corev1.Affinity
+		wantErr  string
+	}{
+		{
+			name: "Accept no affinity",
+		},
+		{
+			name: "Accept the control plane spread on distinct nodes",
+			affinity: &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{controlPlaneTerm}},
+				NodeAffinity: &corev1.NodeAffinity{PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{
+					{Weight: 50, Preference: corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "example.com/tenant-capacity", Operator: corev1.NodeSelectorOpExists}}}},
+				}},
+			},
+		},
+		{
+			name: "Reject a required node affinity without term",
+			affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{}},
+			},
+			wantErr: "machine-test: affinity.nodeAffinity: the required node selector has no term",
+		},
+		{
+			name: "Reject a weight out of range",
+			affinity: &corev1.Affinity{
+				PodAffinity: &corev1.PodAffinity{PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{Weight: 0, PodAffinityTerm: controlPlaneTerm}}},
+			},
+			wantErr: "machine-test: affinity.podAffinity: preferred term 0: weight 0 isn't in the 1-100 range",
+		},
+		{
+			name: "Reject a pod term without topology key",
+			affinity: &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{LabelSelector: controlPlaneTerm.LabelSelector}}},
+			},
+			wantErr: "machine-test: affinity.podAntiAffinity: required term 0: missing topologyKey",
+		},
+		{
+			name: "Reject an invalid label selector",
+			affinity: &corev1.Affinity{
+				PodAntiAffinity: &corev1.PodAntiAffinity{RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+					LabelSelector: &k8smetav1.LabelSelector{MatchExpressions: []k8smetav1.LabelSelectorRequirement{{Key: "role", Operator: "Matches"}}},
+					TopologyKey:   "kubernetes.io/hostname",
+				}}},
+			},
+			wantErr: `machine-test: affinity.podAntiAffinity: required term 0: invalid labelSelector: "Matches" is not a valid pod selector operator`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateAffinity("machine-test", tc.affinity)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
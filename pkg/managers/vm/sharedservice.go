@@ -0,0 +1,231 @@
+package vm
+
+import (
+	"fmt"
+	"sort"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+// sharedServiceSuffix names the service shared by the machines of a machineset after it
+const sharedServiceSuffix = "-machines"
+
+// validateServiceMode validates the service mode. The shared service is named after the machineset, and
+// the endpoints of the machines after the machines, so both must make valid names. The Ingress of the
+// exposed ports needs the per-machine service.
+func validateServiceMode(machineName string, mode kubevirtproviderv1.ServiceMode, machineSetName string, expose *kubevirtproviderv1.Expose) error {
+	switch mode {
+	case "", kubevirtproviderv1.PerMachineServiceMode:
+		return nil
+	case kubevirtproviderv1.NoServiceMode:
+		if expose != nil {
+			return machinecontroller.InvalidMachineConfiguration("%v: the %s service mode can't expose ports", machineName, mode)
+		}
+		return nil
+	case kubevirtproviderv1.MachineSetServiceMode:
+	default:
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid service mode %q, expected %s, %s or %s", machineName, mode, kubevirtproviderv1.PerMachineServiceMode, kubevirtproviderv1.MachineSetServiceMode, kubevirtproviderv1.NoServiceMode)
+	}
+	if machineSetName == "" {
+		return machinecontroller.InvalidMachineConfiguration("%v: the %s service mode needs the machine to belong to a machineset", machineName, mode)
+	}
+	if errs := validation.IsDNS1035Label(machineSetName + sharedServiceSuffix); len(errs) > 0 {
+		return machinecontroller.InvalidMachineConfiguration("%v: invalid shared service name %s: %v", machineName, machineSetName+sharedServiceSuffix, errs)
+	}
+	if errs := validation.IsDNS1123Label(machineName); len(errs) > 0 {
+		return machinecontroller.InvalidMachineConfiguration("%v: the machine name isn't a valid endpoint hostname: %v", machineName, errs)
+	}
+	if expose != nil {
+		return machinecontroller.InvalidMachineConfiguration("%v: the %s service mode can't expose ports", machineName, mode)
+	}
+	return nil
+}
+
+// serviceMode returns the service mode of the machine: the one of its provider spec, else the default one
+// of the configuration, else PerMachine
+func (s *machineScope) serviceMode() kubevirtproviderv1.ServiceMode {
+	if s.machineProviderSpec.ServiceMode != "" {
+		return s.machineProviderSpec.ServiceMode
+	}
+	if s.providerConfig.DefaultServiceMode != "" {
+		return s.providerConfig.DefaultServiceMode
+	}
+	return kubevirtproviderv1.PerMachineServiceMode
+}
+
+// isSharedServiceMode returns true when the machine is published by the service of its machineset
+func (s *machineScope) isSharedServiceMode() bool {
+	return s.serviceMode() == kubevirtproviderv1.MachineSetServiceMode
+}
+
+// hasService returns false when the provider manages no service for the machine
+func (s *machineScope) hasService() bool {
+	return s.serviceMode() != kubevirtproviderv1.NoServiceMode
+}
+
+// hasMachineService returns true when the machine is published by a service of its own, or the provider
+// created one before the service mode of the machine changed, which is then to remove
+func (s *machineScope) hasMachineService() bool {
+	return s.serviceMode() == kubevirtproviderv1.PerMachineServiceMode || s.machineProviderStatus.MachineServiceCreated
+}
+
+// sharedServiceName returns the name of the service shared by the machines of the machineset of the machine
+func sharedServiceName(machine *machinev1.Machine) string {
+	return machine.Labels[machineSetLabel] + sharedServiceSuffix
+}
+
+// syncSharedService creates the service shared by the machines of the machineset when missing, and sets the
+// endpoint of the VM: the IP of its virt-launcher pod, ready when the VM is
+func (m *manager) syncSharedService(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) (*corev1.Service, error) {
+	name := sharedServiceName(machineScope.machine)
+	service, err := machineScope.underkubeClient.GetService(name, vm.Namespace, k8smetav1.GetOptions{})
+	if apimachineryerrors.IsNotFound(err) {
+		klog.Infof("%s: creating the shared service %s", machineScope.getMachineName(), name)
+		service, err = machineScope.underkubeClient.CreateService(buildSharedService(name, machineScope.machine), vm.Namespace)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the shared service %s: %w", name, err)
+	}
+
+	address, err := m.sharedServiceAddress(vm, machineScope)
+	if err != nil {
+		return nil, err
+	}
+	if err := setSharedServiceEndpoint(name, vm.Namespace, vm.Name, address, vm.Status.Ready, machineScope); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// removeSharedServiceEndpoint removes the endpoint of the VM from the shared service, and the service once
+// no machine is left behind it
+func (m *manager) removeSharedServiceEndpoint(vmName, namespace string, machineScope *machineScope) error {
+	name := sharedServiceName(machineScope.machine)
+	if err := setSharedServiceEndpoint(name, namespace, vmName, nil, false, machineScope); err != nil {
+		return err
+	}
+	endpoints, err := machineScope.underkubeClient.GetEndpoints(name, namespace, k8smetav1.GetOptions{})
+	if err != nil && !apimachineryerrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil && len(endpoints.Subsets) > 0 {
+		return nil
+	}
+	klog.Infof("%s: no machine left behind the shared service %s, deleting it", machineScope.getMachineName(), name)
+	if err := machineScope.underkubeClient.DeleteService(name, namespace, &k8smetav1.DeleteOptions{}); err != nil && !apimachineryerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the shared service %s: %w", name, err)
+	}
+	return nil
+}
+
+// sharedServiceAddress returns the endpoint address of the VM, nil while its virt-launcher pod has no IP
+func (m *manager) sharedServiceAddress(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) (*corev1.EndpointAddress, error) {
+	vmi, err := m.getUnderkubeVMI(vm.Name, vm.Namespace, machineScope)
+	if apimachineryerrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pod, err := m.getLauncherPod(vmi, machineScope)
+	if err != nil || pod == nil || pod.Status.PodIP == "" {
+		return nil, err
+	}
+	return &corev1.EndpointAddress{
+		IP:        pod.Status.PodIP,
+		Hostname:  vm.Name,
+		NodeName:  &pod.Spec.NodeName,
+		TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, UID: pod.UID},
+	}, nil
+}
+
+// setSharedServiceEndpoint replaces the endpoint of the VM, identified by its hostname, in the endpoints of
+// the shared service. A nil address removes it.
+func setSharedServiceEndpoint(name, namespace, vmName string, address *corev1.EndpointAddress, ready bool, machineScope *machineScope) error {
+	endpoints, err := machineScope.underkubeClient.GetEndpoints(name, namespace, k8smetav1.GetOptions{})
+	exists := err == nil
+	if apimachineryerrors.IsNotFound(err) {
+		if address == nil {
+			return nil
+		}
+		endpoints = &corev1.Endpoints{ObjectMeta: k8smetav1.ObjectMeta{Name: name, Namespace: namespace, Labels: sharedServiceLabels(machineScope.machine)}}
+	} else if err != nil {
+		return fmt.Errorf("failed to get the endpoints of the shared service %s: %w", name, err)
+	}
+
+	subsets := buildSharedServiceSubsets(endpoints.Subsets, vmName, address, ready)
+	if exists && equality.Semantic.DeepEqual(subsets, endpoints.Subsets) {
+		return nil
+	}
+	endpoints.Subsets = subsets
+	if !exists {
+		_, err = machineScope.underkubeClient.CreateEndpoints(endpoints, namespace)
+	} else {
+		_, err = machineScope.underkubeClient.UpdateEndpoints(endpoints, namespace)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set the endpoint of the VM in the shared service %s: %w", name, err)
+	}
+	klog.Infof("%s: set the endpoint of the VM in the shared service %s", machineScope.getMachineName(), name)
+	return nil
+}
+
+// buildSharedServiceSubsets returns the endpoint subsets with the address of the VM replaced, sorted by
+// hostname so an unchanged set of endpoints compares equal
+func buildSharedServiceSubsets(subsets []corev1.EndpointSubset, vmName string, address *corev1.EndpointAddress, ready bool) []corev1.EndpointSubset {
+	var readyAddresses, notReadyAddresses []corev1.EndpointAddress
+	for _, subset := range subsets {
+		for _, a := range subset.Addresses {
+			if a.Hostname != vmName {
+				readyAddresses = append(readyAddresses, a)
+			}
+		}
+		for _, a := range subset.NotReadyAddresses {
+			if a.Hostname != vmName {
+				notReadyAddresses = append(notReadyAddresses, a)
+			}
+		}
+	}
+	if address != nil && ready {
+		readyAddresses = append(readyAddresses, *address)
+	} else if address != nil {
+		notReadyAddresses = append(notReadyAddresses, *address)
+	}
+	if len(readyAddresses) == 0 && len(notReadyAddresses) == 0 {
+		return nil
+	}
+	sort.Slice(readyAddresses, func(i, j int) bool { return readyAddresses[i].Hostname < readyAddresses[j].Hostname })
+	sort.Slice(notReadyAddresses, func(i, j int) bool { return notReadyAddresses[i].Hostname < notReadyAddresses[j].Hostname })
+	return []corev1.EndpointSubset{{Addresses: readyAddresses, NotReadyAddresses: notReadyAddresses}}
+}
+
+// buildSharedService returns the headless service of the machineset. It has no selector, the provider
+// manages its endpoints.
+func buildSharedService(name string, machine *machinev1.Machine) *corev1.Service {
+	service := &corev1.Service{}
+	service.Name = name
+	service.Labels = sharedServiceLabels(machine)
+	service.Spec = corev1.ServiceSpec{
+		ClusterIP: "None",
+		Type:      corev1.ServiceTypeClusterIP,
+	}
+	return service
+}
+
+func sharedServiceLabels(machine *machinev1.Machine) map[string]string {
+	labels := map[string]string{machineSetLabel: machine.Labels[machineSetLabel]}
+	if clusterID, ok := render.ClusterID(machine); ok {
+		labels[machinev1.MachineClusterIDLabel] = clusterID
+	}
+	return labels
+}
@@ -0,0 +1,232 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestValidateServiceMode(t *testing.T) {
+	cases := []struct {
+		name           string
+		mode           kubevirtproviderv1.ServiceMode
+		machineSetName string
+		expose         *kubevirtproviderv1.Expose
+		wantErr        string
+	}{
+		{
+			name: "Default",
+		},
+		{
+			name: "Per machine",
+			mode: kubevirtproviderv1.PerMachineServiceMode,
+		},
+		{
+			name:           "Machineset",
+			mode:           kubevirtproviderv1.MachineSetServiceMode,
+			machineSetName: "workers",
+		},
+		{
+			name:    "Invalid mode",
+			mode:    "Shared",
+			wantErr: `machine-test: invalid service mode "Shared", expected PerMachine, MachineSet or None`,
+		},
+		{
+			name:    "Machine outside of a machineset",
+			mode:    kubevirtproviderv1.MachineSetServiceMode,
+			wantErr: "machine-test: the MachineSet service mode needs the machine to belong to a machineset",
+		},
+		{
+			name:           "Exposed ports",
+			mode:           kubevirtproviderv1.MachineSetServiceMode,
+			machineSetName: "workers",
+			expose:         &kubevirtproviderv1.Expose{},
+			wantErr:        "machine-test: the MachineSet service mode can't expose ports",
+		},
+		{
+			name: "No service",
+			mode: kubevirtproviderv1.NoServiceMode,
+		},
+		{
+			name:    "Exposed ports without service",
+			mode:    kubevirtproviderv1.NoServiceMode,
+			expose:  &kubevirtproviderv1.Expose{},
+			wantErr: "machine-test: the None service mode can't expose ports",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateServiceMode("machine-test", tc.mode, tc.machineSetName, tc.expose)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestServiceMode(t *testing.T) {
+	cases := []struct {
+		name        string
+		specMode    kubevirtproviderv1.ServiceMode
+		defaultMode kubevirtproviderv1.ServiceMode
+		wantMode    kubevirtproviderv1.ServiceMode
+		wantService bool
+	}{
+		{
+			name:        "Default",
+			wantMode:    kubevirtproviderv1.PerMachineServiceMode,
+			wantService: true,
+		},
+		{
+			name:        "Default of the configuration",
+			defaultMode: kubevirtproviderv1.NoServiceMode,
+			wantMode:    kubevirtproviderv1.NoServiceMode,
+		},
+		{
+			name:        "Provider spec over the configuration",
+			specMode:    kubevirtproviderv1.MachineSetServiceMode,
+			defaultMode: kubevirtproviderv1.NoServiceMode,
+			wantMode:    kubevirtproviderv1.MachineSetServiceMode,
+			wantService: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machineScope := &machineScope{
+				machineProviderSpec: &kubevirtproviderv1.KubevirtMachineProviderSpec{ServiceMode: tc.specMode},
+				providerConfig:      kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultServiceMode: tc.defaultMode},
+			}
+			assert.Equal(t, machineScope.serviceMode(), tc.wantMode)
+			assert.Equal(t, machineScope.hasService(), tc.wantService)
+		})
+	}
+}
+
+func TestBuildSharedServiceSubsets(t *testing.T) {
+	address := func(hostname, ip string) corev1.EndpointAddress {
+		return corev1.EndpointAddress{Hostname: hostname, IP: ip}
+	}
+	current := []corev1.EndpointSubset{{
+		Addresses:         []corev1.EndpointAddress{address("worker-a", "10.0.0.1"), address("worker-c", "10.0.0.3")},
+		NotReadyAddresses: []corev1.EndpointAddress{address("worker-b", "10.0.0.2")},
+	}}
+	newAddress := address("worker-b", "10.0.0.4")
+
+	cases := []struct {
+		name     string
+		subsets  []corev1.EndpointSubset
+		address  *corev1.EndpointAddress
+		ready    bool
+		expected []corev1.EndpointSubset
+	}{
+		{
+			name:     "First endpoint",
+			address:  &newAddress,
+			expected: []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{newAddress}}},
+		},
+		{
+			name:    "Endpoint becomes ready",
+			subsets: current,
+			address: &newAddress,
+			ready:   true,
+			expected: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{address("worker-a", "10.0.0.1"), newAddress, address("worker-c", "10.0.0.3")},
+			}},
+		},
+		{
+			name:    "Endpoint removed",
+			subsets: current,
+			expected: []corev1.EndpointSubset{{
+				Addresses: []corev1.EndpointAddress{address("worker-a", "10.0.0.1"), address("worker-c", "10.0.0.3")},
+			}},
+		},
+		{
+			name:    "Last endpoint removed",
+			subsets: []corev1.EndpointSubset{{NotReadyAddresses: []corev1.EndpointAddress{address("worker-b", "10.0.0.2")}}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.DeepEqual(t, buildSharedServiceSubsets(tc.subsets, "worker-b", tc.address, tc.ready), tc.expected)
+		})
+	}
+}
+
+func TestSyncSharedService(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+	machine, err := stubMachine(map[string]string{
+		machinev1.MachineClusterIDLabel: clusterID,
+		machineSetLabel:                 "workers",
+	}, "")
+	assert.NilError(t, err)
+	machineScope := &machineScope{
+		machine:             machine,
+		underkubeClient:     mockUnderkube,
+		machineProviderSpec: &kubevirtproviderv1.KubevirtMachineProviderSpec{ServiceMode: kubevirtproviderv1.MachineSetServiceMode},
+	}
+	vm := &kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName}}
+	vm.Status.Ready = true
+	vmi := &kubevirtapiv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName, UID: "vmi-uid"}}
+	pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: "virt-launcher-machine-test-abcde", UID: "pod-uid"}}
+	pod.Spec.NodeName = "host-1"
+	pod.Status.PodIP = "10.128.0.10"
+	notFound := func(resource, name string) error {
+		return apimachineryerrors.NewNotFound(schema.GroupResource{Resource: resource}, name)
+	}
+
+	mockUnderkube.EXPECT().GetService("workers-machines", clusterID, gomock.Any()).Return(nil, notFound("services", "workers-machines"))
+	mockUnderkube.EXPECT().CreateService(gomock.Any(), clusterID).DoAndReturn(func(service *corev1.Service, _ string) (*corev1.Service, error) {
+		assert.Equal(t, service.Name, "workers-machines")
+		assert.Equal(t, service.Spec.ClusterIP, "None")
+		assert.Assert(t, service.Spec.Selector == nil)
+		assert.DeepEqual(t, service.Labels, map[string]string{machinev1.MachineClusterIDLabel: clusterID, machineSetLabel: "workers"})
+		return service, nil
+	})
+	mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).Return(vmi, nil)
+	mockUnderkube.EXPECT().ListPods(clusterID, gomock.Any()).Return(&corev1.PodList{Items: []corev1.Pod{pod}}, nil)
+	mockUnderkube.EXPECT().GetEndpoints("workers-machines", clusterID, gomock.Any()).Return(nil, notFound("endpoints", "workers-machines"))
+	var created *corev1.Endpoints
+	mockUnderkube.EXPECT().CreateEndpoints(gomock.Any(), clusterID).DoAndReturn(func(endpoints *corev1.Endpoints, _ string) (*corev1.Endpoints, error) {
+		created = endpoints
+		return endpoints, nil
+	})
+
+	service, err := (&manager{}).syncSharedService(vm, machineScope)
+	assert.NilError(t, err)
+	assert.Equal(t, service.Name, "workers-machines")
+	assert.Equal(t, created.Name, "workers-machines")
+	nodeName := "host-1"
+	assert.DeepEqual(t, created.Subsets, []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{
+		IP:        "10.128.0.10",
+		Hostname:  mahcineName,
+		NodeName:  &nodeName,
+		TargetRef: &corev1.ObjectReference{Kind: "Pod", Namespace: clusterID, Name: pod.Name, UID: "pod-uid"},
+	}}}})
+
+	// The last machine of the machineset removes the shared service
+	mockUnderkube.EXPECT().GetEndpoints("workers-machines", clusterID, gomock.Any()).Return(created.DeepCopy(), nil)
+	mockUnderkube.EXPECT().UpdateEndpoints(gomock.Any(), clusterID).DoAndReturn(func(endpoints *corev1.Endpoints, _ string) (*corev1.Endpoints, error) {
+		assert.Assert(t, endpoints.Subsets == nil)
+		created = endpoints
+		return endpoints, nil
+	})
+	mockUnderkube.EXPECT().GetEndpoints("workers-machines", clusterID, gomock.Any()).DoAndReturn(func(string, string, metav1.GetOptions) (*corev1.Endpoints, error) {
+		return created, nil
+	})
+	mockUnderkube.EXPECT().DeleteService("workers-machines", clusterID, gomock.Any()).Return(nil)
+	assert.NilError(t, (&manager{}).removeSharedServiceEndpoint(mahcineName, clusterID, machineScope))
+}
@@ -0,0 +1,26 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"k8s.io/klog"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+// setBootVolumeCloneStrategy records the clone strategy of the boot volume, from the phase of its data volume
+// while it is cloned. The strategy observed first is kept.
+func (s *machineScope) setBootVolumeCloneStrategy(dataVolume *cdiv1.DataVolume) {
+	if s.machineProviderStatus.BootVolumeCloneStrategy != "" {
+		return
+	}
+	var strategy kubevirtproviderv1.CloneStrategy
+	switch dataVolume.Status.Phase {
+	case cdiv1.SnapshotForSmartCloneInProgress, cdiv1.SmartClonePVCInProgress:
+		strategy = kubevirtproviderv1.CloneStrategySnapshot
+	case cdiv1.CloneScheduled, cdiv1.CloneInProgress:
+		strategy = kubevirtproviderv1.CloneStrategyHostAssisted
+	default:
+		return
+	}
+	klog.Infof("%s: the boot volume is cloned with the %s strategy", s.getMachineName(), strategy)
+	s.machineProviderStatus.BootVolumeCloneStrategy = strategy
+}
@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func TestSetBootVolumeCloneStrategy(t *testing.T) {
+	cases := []struct {
+		name   string
+		phases []cdiv1.DataVolumePhase
+		want   kubevirtproviderv1.CloneStrategy
+	}{
+		{
+			name:   "Snapshot clone",
+			phases: []cdiv1.DataVolumePhase{cdiv1.Pending, cdiv1.SnapshotForSmartCloneInProgress, cdiv1.SmartClonePVCInProgress, cdiv1.Succeeded},
+			want:   kubevirtproviderv1.CloneStrategySnapshot,
+		},
+		{
+			name:   "Host-assisted clone",
+			phases: []cdiv1.DataVolumePhase{cdiv1.CloneScheduled, cdiv1.CloneInProgress, cdiv1.Succeeded},
+			want:   kubevirtproviderv1.CloneStrategyHostAssisted,
+		},
+		{
+			name:   "Clone not observed",
+			phases: []cdiv1.DataVolumePhase{cdiv1.Pending, cdiv1.Succeeded},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope := &machineScope{machine: machine, machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{}}
+			for _, phase := range tc.phases {
+				machineScope.setBootVolumeCloneStrategy(&cdiv1.DataVolume{Status: cdiv1.DataVolumeStatus{Phase: phase}})
+			}
+			assert.Equal(t, machineScope.machineProviderStatus.BootVolumeCloneStrategy, tc.want)
+		})
+	}
+}
@@ -2,10 +2,11 @@ package vm
 
 import (
 	"fmt"
+	"testing"
 
-	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	"gotest.tools/assert"
 
-	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
 
 	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
@@ -56,6 +58,16 @@ func stubService(vmName string) *corev1.Service {
 	}
 	return service
 }
+func stubBootVolumePVC(storage, storageClassName string) *corev1.PersistentVolumeClaim {
+	pvc := &corev1.PersistentVolumeClaim{}
+	pvc.Name = render.BootVolumeName(mahcineName)
+	pvc.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: apiresource.MustParse(storage)}
+	if storageClassName != "" {
+		pvc.Spec.StorageClassName = &storageClassName
+	}
+	return pvc
+}
+
 func stubMachineScope(machine *machinev1.Machine, overkubeClient overkube.Client, underkubeClientBuilder underkube.ClientBuilderFuncType) (*machineScope, error) {
 	providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(machine.Spec.ProviderSpec.Value)
 	if err != nil {
@@ -88,106 +100,16 @@ func stubSecret() *corev1.Secret {
 	}
 	return &secret
 }
-func stubBuildVMITemplate(s *machineScope) *kubevirtapiv1.VirtualMachineInstanceTemplateSpec {
-	virtualMachineName := s.machine.GetName()
-
-	template := &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{}
-
-	template.ObjectMeta = metav1.ObjectMeta{
-		Labels: map[string]string{"kubevirt.io/vm": virtualMachineName, "name": virtualMachineName},
-	}
-
-	template.Spec = kubevirtapiv1.VirtualMachineInstanceSpec{}
-	template.Spec.Volumes = []kubevirtapiv1.Volume{
-		{
-			Name: buildDataVolumeDiskName(virtualMachineName),
-			VolumeSource: kubevirtapiv1.VolumeSource{
-				DataVolume: &kubevirtapiv1.DataVolumeSource{
-					Name: buildBootVolumeName(virtualMachineName),
-				},
-			},
-		},
-		{
-			Name: buildCloudInitVolumeDiskName(virtualMachineName),
-			VolumeSource: kubevirtapiv1.VolumeSource{
-				CloudInitConfigDrive: &kubevirtapiv1.CloudInitConfigDriveSource{
-					UserDataSecretRef: &corev1.LocalObjectReference{
-						Name: s.machineProviderSpec.IgnitionSecretName,
-					},
-				},
-			},
-		},
-	}
-
-	template.Spec.Domain = kubevirtapiv1.DomainSpec{}
-
-	requests := corev1.ResourceList{}
-
-	requestedMemory := s.machineProviderSpec.RequestedMemory
-	if requestedMemory == "" {
-		requestedMemory = defaultRequestedMemory
-	}
-	requests[corev1.ResourceMemory] = apiresource.MustParse(requestedMemory)
 
-	if s.machineProviderSpec.RequestedCPU != "" {
-		requests[corev1.ResourceCPU] = apiresource.MustParse(s.machineProviderSpec.RequestedCPU)
-	}
-
-	template.Spec.Domain.Resources = kubevirtapiv1.ResourceRequirements{
-		Requests: requests,
-	}
-	template.Spec.Domain.Devices = kubevirtapiv1.Devices{
-		Disks: []kubevirtapiv1.Disk{
-			{
-				Name: buildDataVolumeDiskName(virtualMachineName),
-				DiskDevice: kubevirtapiv1.DiskDevice{
-					Disk: &kubevirtapiv1.DiskTarget{
-						Bus: defaultBus,
-					},
-				},
-			},
-			{
-				Name: buildCloudInitVolumeDiskName(virtualMachineName),
-				DiskDevice: kubevirtapiv1.DiskDevice{
-					Disk: &kubevirtapiv1.DiskTarget{
-						Bus: defaultBus,
-					},
-				},
-			},
-		},
-	}
-
-	return template
+// stubVirtualMachine renders the VM of the machine, the shape of the VM itself is tested in the render
+// package
+func stubVirtualMachine(t *testing.T, machineScope *machineScope) *kubevirtapiv1.VirtualMachine {
+	t.Helper()
+	virtualMachine, err := render.RenderVirtualMachine(machineScope.machine, machineScope.machineProviderSpec, render.Defaults{})
+	assert.NilError(t, err)
+	return virtualMachine
 }
 
-func stubVirtualMachine(machineScope *machineScope) *kubevirtapiv1.VirtualMachine {
-	runAlways := kubevirtapiv1.RunStrategyAlways
-	namespace := machineScope.machine.Labels[machinev1.MachineClusterIDLabel]
-	vmiTemplate := stubBuildVMITemplate(machineScope)
-	storageClassName := ""
-	virtualMachine := kubevirtapiv1.VirtualMachine{
-		Spec: kubevirtapiv1.VirtualMachineSpec{
-			RunStrategy: &runAlways,
-			DataVolumeTemplates: []cdiv1.DataVolume{
-				*buildBootVolumeDataVolumeTemplate(machineScope.machine.GetName(), machineScope.machineProviderSpec.SourcePvcName, namespace, machineScope.machineProviderSpec.SourcePvcNamespace, storageClassName),
-			},
-			Template: vmiTemplate,
-		},
-	}
-
-	virtualMachine.APIVersion = APIVersion
-	virtualMachine.Kind = Kind
-	virtualMachine.ObjectMeta = metav1.ObjectMeta{
-		Name:            machineScope.machine.Name,
-		Namespace:       namespace,
-		Labels:          machineScope.machine.Labels,
-		Annotations:     machineScope.machine.Annotations,
-		OwnerReferences: nil,
-		ClusterName:     machineScope.machine.ClusterName,
-	}
-
-	return &virtualMachine
-}
 func stubMachine(labels map[string]string, providerID string) (*machinev1.Machine, error) {
 	providerSpecValue, providerSpecValueErr := kubevirtproviderv1.RawExtensionFromProviderSpec(&kubevirtproviderv1.KubevirtMachineProviderSpec{
 		SourcePvcName:             SourceTestPvcName,
@@ -224,3 +146,10 @@ func stubMachine(labels map[string]string, providerID string) (*machinev1.Machin
 
 	return machine, nil
 }
+
+// stubAPIResources is the discovery of any group version of an underkube running KubeVirt and CDI
+func stubAPIResources() *metav1.APIResourceList {
+	return &metav1.APIResourceList{
+		APIResources: []metav1.APIResource{{Name: "virtualmachines"}, {Name: "datavolumes"}},
+	}
+}
@@ -0,0 +1,93 @@
+package vm
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var provisioningPhaseSeconds = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name: "kubevirt_machine_provisioning_phase_seconds",
+		Help: "Time the machines took to reach each provisioning phase from the previous one, or from their creation for the first one, per phase.",
+		// From 10s to about 85m
+		Buckets: prometheus.ExponentialBuckets(10, 2, 10),
+	},
+	[]string{"phase"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(provisioningPhaseSeconds)
+}
+
+// setProvisioningTimeline records the provisioning phases the machine reached since the last sync. The timeline only starts while the machine is provisioning, so the machines
+// provisioned before don't report the phases of a restart, and it stops once the node joined.
+func (s *machineScope) setProvisioningTimeline(vmi *kubevirtapiv1.VirtualMachineInstance) {
+	timeline := s.machineProviderStatus.ProvisioningTimeline
+	if len(timeline) == 0 && !s.isProvisioning() {
+		return
+	}
+	for _, phase := range timeline {
+		if phase.Name == kubevirtproviderv1.NodeJoinedPhase {
+			return
+		}
+	}
+
+	now := metav1.Now()
+	for _, name := range s.reachedProvisioningPhases(vmi) {
+		reached := false
+		for _, phase := range timeline {
+			reached = reached || phase.Name == name
+		}
+		if reached {
+			continue
+		}
+		klog.Infof("%s: provisioning phase %s reached", s.getMachineName(), name)
+		timeline = append(timeline, kubevirtproviderv1.ProvisioningPhase{Name: name, Time: now})
+	}
+	s.machineProviderStatus.ProvisioningTimeline = timeline
+}
+
+// observeProvisioningPhases observes the time the phases added to the timeline since the machine was read
+// took. It's called once the status holding them is written, the phases of a status that failed to be
+// written are reached again by the next sync.
+func (s *machineScope) observeProvisioningPhases() {
+	recorded := map[kubevirtproviderv1.ProvisioningPhaseName]bool{}
+	if originStatus, err := kubevirtproviderv1.ProviderStatusFromRawExtension(s.originMachineCopy.Status.ProviderStatus); err == nil {
+		for _, phase := range originStatus.ProvisioningTimeline {
+			recorded[phase.Name] = true
+		}
+	}
+	previous := s.machine.CreationTimestamp.Time
+	for _, phase := range s.machineProviderStatus.ProvisioningTimeline {
+		if !recorded[phase.Name] {
+			provisioningPhaseSeconds.WithLabelValues(string(phase.Name)).Observe(phase.Time.Sub(previous).Seconds())
+		}
+		previous = phase.Time.Time
+	}
+}
+
+// reachedProvisioningPhases returns the provisioning phases the machine reached, from its conditions, its
+// VMI and its node. The VM exists, the spec was accepted.
+func (s *machineScope) reachedProvisioningPhases(vmi *kubevirtapiv1.VirtualMachineInstance) []kubevirtproviderv1.ProvisioningPhaseName {
+	phases := []kubevirtproviderv1.ProvisioningPhaseName{kubevirtproviderv1.SpecAcceptedPhase}
+	machineConditions := s.machineProviderStatus.MachineConditions
+	if condition := conditions.Find(machineConditions, kubevirtproviderv1.VolumesReadyCondition); condition != nil && condition.Status == corev1.ConditionTrue {
+		phases = append(phases, kubevirtproviderv1.VolumesReadyPhase)
+	}
+	if vmi != nil && vmi.Status.Phase == kubevirtapiv1.Running {
+		phases = append(phases, kubevirtproviderv1.VMIStartedPhase)
+	}
+	if condition := conditions.Find(machineConditions, kubevirtproviderv1.AgentConnectedCondition); condition != nil && condition.Status == corev1.ConditionTrue {
+		phases = append(phases, kubevirtproviderv1.AgentConnectedPhase)
+	}
+	if s.machine.Status.NodeRef != nil {
+		phases = append(phases, kubevirtproviderv1.NodeJoinedPhase)
+	}
+	return phases
+}
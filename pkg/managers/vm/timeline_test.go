@@ -0,0 +1,159 @@
+package vm
+
+import (
+	"testing"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestSetProvisioningTimeline(t *testing.T) {
+	earlier := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	provisioned := "Provisioned"
+	running := &kubevirtapiv1.VirtualMachineInstance{Status: kubevirtapiv1.VirtualMachineInstanceStatus{Phase: kubevirtapiv1.Running}}
+	volumesReady := conditions.True(kubevirtproviderv1.VolumesReadyCondition, "DataVolumesImported")
+	agentConnected := conditions.True(kubevirtproviderv1.AgentConnectedCondition, "AgentConnected")
+
+	cases := []struct {
+		name       string
+		phase      *string
+		nodeJoined bool
+		vmi        *kubevirtapiv1.VirtualMachineInstance
+		conditions []kubevirtproviderv1.KubevirtMachineCondition
+		timeline   []kubevirtproviderv1.ProvisioningPhase
+		wantPhases []kubevirtproviderv1.ProvisioningPhaseName
+		// wantKept is the number of phases of the timeline kept with their time
+		wantKept int
+	}{
+		{
+			name:       "VM created",
+			wantPhases: []kubevirtproviderv1.ProvisioningPhaseName{kubevirtproviderv1.SpecAcceptedPhase},
+		},
+		{
+			name:       "VMI started",
+			vmi:        running,
+			conditions: []kubevirtproviderv1.KubevirtMachineCondition{volumesReady},
+			timeline:   []kubevirtproviderv1.ProvisioningPhase{{Name: kubevirtproviderv1.SpecAcceptedPhase, Time: earlier}},
+			wantPhases: []kubevirtproviderv1.ProvisioningPhaseName{kubevirtproviderv1.SpecAcceptedPhase, kubevirtproviderv1.VolumesReadyPhase, kubevirtproviderv1.VMIStartedPhase},
+			wantKept:   1,
+		},
+		{
+			name:       "Node joined without agent",
+			phase:      &provisioned,
+			nodeJoined: true,
+			vmi:        running,
+			conditions: []kubevirtproviderv1.KubevirtMachineCondition{volumesReady},
+			timeline: []kubevirtproviderv1.ProvisioningPhase{
+				{Name: kubevirtproviderv1.SpecAcceptedPhase, Time: earlier},
+				{Name: kubevirtproviderv1.VolumesReadyPhase, Time: earlier},
+				{Name: kubevirtproviderv1.VMIStartedPhase, Time: earlier},
+			},
+			wantPhases: []kubevirtproviderv1.ProvisioningPhaseName{kubevirtproviderv1.SpecAcceptedPhase, kubevirtproviderv1.VolumesReadyPhase, kubevirtproviderv1.VMIStartedPhase, kubevirtproviderv1.NodeJoinedPhase},
+			wantKept:   3,
+		},
+		{
+			name:       "Complete timeline",
+			phase:      &provisioned,
+			nodeJoined: true,
+			vmi:        running,
+			conditions: []kubevirtproviderv1.KubevirtMachineCondition{volumesReady, agentConnected},
+			timeline: []kubevirtproviderv1.ProvisioningPhase{
+				{Name: kubevirtproviderv1.SpecAcceptedPhase, Time: earlier},
+				{Name: kubevirtproviderv1.NodeJoinedPhase, Time: earlier},
+			},
+			wantPhases: []kubevirtproviderv1.ProvisioningPhaseName{kubevirtproviderv1.SpecAcceptedPhase, kubevirtproviderv1.NodeJoinedPhase},
+			wantKept:   2,
+		},
+		{
+			name:       "Machine provisioned before the timeline",
+			phase:      &provisioned,
+			nodeJoined: true,
+			vmi:        running,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.Status.Phase = tc.phase
+			if tc.nodeJoined {
+				machine.Status.NodeRef = &corev1.ObjectReference{Kind: "Node", Name: mahcineName}
+			}
+			machineScope := &machineScope{
+				machine: machine,
+				machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{
+					MachineConditions:    tc.conditions,
+					ProvisioningTimeline: tc.timeline,
+				},
+			}
+
+			machineScope.setProvisioningTimeline(tc.vmi)
+			timeline := machineScope.machineProviderStatus.ProvisioningTimeline
+			assert.Equal(t, len(timeline), len(tc.wantPhases))
+			for i, name := range tc.wantPhases {
+				assert.Equal(t, timeline[i].Name, name)
+				if i < tc.wantKept {
+					assert.Equal(t, timeline[i].Time, earlier)
+				} else {
+					assert.Assert(t, timeline[i].Time.After(earlier.Time))
+				}
+			}
+		})
+	}
+}
+
+func TestObserveProvisioningPhases(t *testing.T) {
+	created := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	at := func(d time.Duration) metav1.Time {
+		return metav1.NewTime(created.Add(d))
+	}
+	recorded := []kubevirtproviderv1.ProvisioningPhase{
+		{Name: kubevirtproviderv1.SpecAcceptedPhase, Time: at(10 * time.Second)},
+	}
+	origin, err := kubevirtproviderv1.RawExtensionFromProviderStatus(&kubevirtproviderv1.KubevirtMachineProviderStatus{ProvisioningTimeline: recorded})
+	assert.NilError(t, err)
+
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	machine.CreationTimestamp = metav1.NewTime(created)
+	originMachine := machine.DeepCopy()
+	originMachine.Status.ProviderStatus = origin
+	machineScope := &machineScope{
+		machine:           machine,
+		originMachineCopy: originMachine,
+		machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{
+			ProvisioningTimeline: append(recorded,
+				kubevirtproviderv1.ProvisioningPhase{Name: kubevirtproviderv1.VolumesReadyPhase, Time: at(70 * time.Second)},
+				kubevirtproviderv1.ProvisioningPhase{Name: kubevirtproviderv1.VMIStartedPhase, Time: at(100 * time.Second)},
+			),
+		},
+	}
+
+	observed := func(phase kubevirtproviderv1.ProvisioningPhaseName) (uint64, float64) {
+		metric := &dto.Metric{}
+		assert.NilError(t, provisioningPhaseSeconds.WithLabelValues(string(phase)).(prometheus.Histogram).Write(metric))
+		return metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+	}
+	specAcceptedCount, _ := observed(kubevirtproviderv1.SpecAcceptedPhase)
+	volumesReadyCount, volumesReadySum := observed(kubevirtproviderv1.VolumesReadyPhase)
+	vmiStartedCount, vmiStartedSum := observed(kubevirtproviderv1.VMIStartedPhase)
+
+	machineScope.observeProvisioningPhases()
+
+	// The phase already recorded isn't observed again, the new ones are observed from the previous phase
+	count, _ := observed(kubevirtproviderv1.SpecAcceptedPhase)
+	assert.Equal(t, count, specAcceptedCount)
+	count, sum := observed(kubevirtproviderv1.VolumesReadyPhase)
+	assert.Equal(t, count, volumesReadyCount+1)
+	assert.Equal(t, sum, volumesReadySum+60)
+	count, sum = observed(kubevirtproviderv1.VMIStartedPhase)
+	assert.Equal(t, count, vmiStartedCount+1)
+	assert.Equal(t, sum, vmiStartedSum+30)
+}
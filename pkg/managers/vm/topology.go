@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	// topologyZoneLabel and topologyRegionLabel are the stable topology labels, which the vendored API
+	// doesn't define yet
+	topologyZoneLabel   = "topology.kubernetes.io/zone"
+	topologyRegionLabel = "topology.kubernetes.io/region"
+	// InfraHostnameLabel holds the hostname of the underkube node running the VM of the machine. The
+	// kubernetes.io/hostname label of the node is its own, the infra one can't be mirrored under it.
+	InfraHostnameLabel = "kubevirt.io/infra-hostname"
+)
+
+// infraTopologyLabels maps the labels of the underkube nodes to the labels of the machines mirroring them
+var infraTopologyLabels = map[string]string{
+	topologyZoneLabel:             topologyZoneLabel,
+	topologyRegionLabel:           topologyRegionLabel,
+	corev1.LabelZoneFailureDomain: corev1.LabelZoneFailureDomain,
+	corev1.LabelZoneRegion:        corev1.LabelZoneRegion,
+	corev1.LabelHostname:          InfraHostnameLabel,
+}
+
+// syncInfraTopologyLabels mirrors the topology labels of the underkube node running the VMI to the labels
+// of the machine, and to the labels the machine controller sets on its node. The labels are kept while the
+// VMI doesn't run, they are updated once it runs again, possibly on another node.
+func (m *manager) syncInfraTopologyLabels(vmi *kubevirtapiv1.VirtualMachineInstance, machineScope *machineScope) error {
+	if !machineScope.machineProviderSpec.InfraTopologyLabels || vmi == nil || vmi.Status.NodeName == "" {
+		return nil
+	}
+	nodeName := vmi.Status.NodeName
+	node, err := machineScope.underkubeClient.GetNode(nodeName, k8smetav1.GetOptions{})
+	if apimachineryerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get underkube node %s: %w", nodeName, err)
+	}
+
+	machine := machineScope.machine
+	if machine.Labels == nil {
+		machine.Labels = map[string]string{}
+	}
+	if machine.Spec.Labels == nil {
+		machine.Spec.Labels = map[string]string{}
+	}
+	for nodeLabel, machineLabel := range infraTopologyLabels {
+		value, ok := node.Labels[nodeLabel]
+		if !ok {
+			delete(machine.Labels, machineLabel)
+			delete(machine.Spec.Labels, machineLabel)
+			continue
+		}
+		machine.Labels[machineLabel] = value
+		machine.Spec.Labels[machineLabel] = value
+	}
+	klog.V(3).Infof("%s: mirrored the topology labels of the underkube node %s", machineScope.getMachineName(), nodeName)
+	return nil
+}
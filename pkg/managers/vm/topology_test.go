@@ -0,0 +1,118 @@
+package vm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestSyncInfraTopologyLabels(t *testing.T) {
+	const hostNode = "host-1"
+	infraLabels := map[string]string{
+		topologyZoneLabel:                "zone-a",
+		topologyRegionLabel:              "region-1",
+		corev1.LabelHostname:             hostNode,
+		"node-role.kubernetes.io/worker": "",
+	}
+	mirrored := map[string]string{
+		topologyZoneLabel:   "zone-a",
+		topologyRegionLabel: "region-1",
+		InfraHostnameLabel:  hostNode,
+	}
+
+	cases := []struct {
+		name          string
+		disabled      bool
+		hostNode      string
+		nodeLabels    map[string]string
+		getErr        error
+		machineLabels map[string]string
+		wantLabels    map[string]string
+		wantErr       string
+	}{
+		{
+			name:       "Disabled",
+			disabled:   true,
+			hostNode:   hostNode,
+			wantLabels: map[string]string{},
+		},
+		{
+			name:       "VMI not running",
+			wantLabels: map[string]string{},
+		},
+		{
+			name:       "Node not found",
+			hostNode:   hostNode,
+			getErr:     apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "nodes"}, hostNode),
+			wantLabels: map[string]string{},
+		},
+		{
+			name:       "Get fails",
+			hostNode:   hostNode,
+			getErr:     fmt.Errorf("nodes is forbidden"),
+			wantErr:    "failed to get underkube node host-1: nodes is forbidden",
+			wantLabels: map[string]string{},
+		},
+		{
+			name:       "Labels mirrored",
+			hostNode:   hostNode,
+			nodeLabels: infraLabels,
+			wantLabels: mirrored,
+		},
+		{
+			name:          "VM moved to a node without zone",
+			hostNode:      "host-2",
+			nodeLabels:    map[string]string{corev1.LabelHostname: "host-2"},
+			machineLabels: mirrored,
+			wantLabels:    map[string]string{InfraHostnameLabel: "host-2"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machine.Spec.Labels = map[string]string{}
+			for key, value := range tc.machineLabels {
+				machine.Labels[key] = value
+				machine.Spec.Labels[key] = value
+			}
+			if !tc.disabled && tc.hostNode != "" {
+				node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: tc.hostNode, Labels: tc.nodeLabels}}
+				mockUnderkube.EXPECT().GetNode(tc.hostNode, metav1.GetOptions{}).Return(node, tc.getErr)
+			}
+			machineScope := &machineScope{
+				machine:             machine,
+				underkubeClient:     mockUnderkube,
+				machineProviderSpec: &kubevirtproviderv1.KubevirtMachineProviderSpec{InfraTopologyLabels: !tc.disabled},
+			}
+			var vmi *kubevirtapiv1.VirtualMachineInstance
+			if tc.hostNode != "" {
+				vmi = &kubevirtapiv1.VirtualMachineInstance{Status: kubevirtapiv1.VirtualMachineInstanceStatus{NodeName: tc.hostNode}}
+			}
+
+			err = (&manager{}).syncInfraTopologyLabels(vmi, machineScope)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.DeepEqual(t, machine.Spec.Labels, tc.wantLabels)
+			delete(machine.Labels, machinev1.MachineClusterIDLabel)
+			assert.DeepEqual(t, machine.Labels, tc.wantLabels)
+		})
+	}
+}
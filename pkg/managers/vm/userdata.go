@@ -0,0 +1,63 @@
+package vm
+
+import (
+	"bytes"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	cloudConfigHeader = "#cloud-config"
+	// userDataSecretSuffix names the underkube secret holding the rendered user data of a VM
+	userDataSecretSuffix = "userdata"
+	// renderedUserDataKey is the key KubeVirt reads the cloud-init user data from
+	renderedUserDataKey = "userdata"
+	// nodeIPDropInPath is a kubelet systemd drop-in setting the KUBELET_NODE_IP environment variable,
+	// which the kubelet unit passes to --node-ip
+	nodeIPDropInPath = "/etc/systemd/system/kubelet.service.d/20-kubevirt-node-ip.conf"
+)
+
+func buildUserDataSecretName(virtualMachineName string) string {
+	return fmt.Sprintf("%s-%s", virtualMachineName, userDataSecretSuffix)
+}
+
+// renderUserData adds the cloud-init directives growing the root partition and filesystem on boot,
+// so an expanded boot volume translates into a larger guest filesystem. Directives already set by
+// the user are kept. When nodeIP is set, a kubelet drop-in registering the node with that IP is
+// written as well. Only cloud-config user data is rendered, anything else (e.g. Ignition, whose
+// hosts grow their root filesystem by themselves) is returned as is with rendered set to false.
+func renderUserData(userData []byte, nodeIP string) (result []byte, rendered bool, err error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(userData), []byte(cloudConfigHeader)) {
+		return userData, false, nil
+	}
+
+	cloudConfig := map[string]interface{}{}
+	if err := yaml.Unmarshal(userData, &cloudConfig); err != nil {
+		return nil, false, err
+	}
+	if _, ok := cloudConfig["growpart"]; !ok {
+		cloudConfig["growpart"] = map[string]interface{}{
+			"mode":    "auto",
+			"devices": []string{"/"},
+		}
+	}
+	if _, ok := cloudConfig["resize_rootfs"]; !ok {
+		cloudConfig["resize_rootfs"] = true
+	}
+
+	if nodeIP != "" {
+		writeFiles, _ := cloudConfig["write_files"].([]interface{})
+		cloudConfig["write_files"] = append(writeFiles, map[string]interface{}{
+			"path":        nodeIPDropInPath,
+			"permissions": "0644",
+			"content":     "[Service]\nEnvironment=\"KUBELET_NODE_IP=" + nodeIP + "\"\n",
+		})
+	}
+
+	body, err := yaml.Marshal(cloudConfig)
+	if err != nil {
+		return nil, false, err
+	}
+	return append([]byte(cloudConfigHeader+"\n"), body...), true, nil
+}
@@ -0,0 +1,75 @@
+package vm
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestRenderUserData(t *testing.T) {
+	cases := []struct {
+		name         string
+		userData     string
+		nodeIP       string
+		wantUserData string
+		wantRendered bool
+	}{
+		{
+			name:         "Leave ignition user data as is",
+			userData:     `{"ignition":{"version":"2.2.0"}}`,
+			wantUserData: `{"ignition":{"version":"2.2.0"}}`,
+		},
+		{
+			name:     "Add the growth directives to cloud-config",
+			userData: "#cloud-config\npassword: fedora\n",
+			wantUserData: `#cloud-config
+growpart:
+  devices:
+  - /
+  mode: auto
+password: fedora
+resize_rootfs: true
+`,
+			wantRendered: true,
+		},
+		{
+			name:     "Keep the growth directives set by the user",
+			userData: "#cloud-config\ngrowpart:\n  mode: \"off\"\nresize_rootfs: false\n",
+			wantUserData: `#cloud-config
+growpart:
+  mode: "off"
+resize_rootfs: false
+`,
+			wantRendered: true,
+		},
+		{
+			name:     "Hint the node IP to kubelet",
+			userData: "#cloud-config\nwrite_files:\n- path: /etc/motd\n  content: hello\n",
+			nodeIP:   "10.0.0.5",
+			wantUserData: `#cloud-config
+growpart:
+  devices:
+  - /
+  mode: auto
+resize_rootfs: true
+write_files:
+- content: hello
+  path: /etc/motd
+- content: |
+    [Service]
+    Environment="KUBELET_NODE_IP=10.0.0.5"
+  path: /etc/systemd/system/kubelet.service.d/20-kubevirt-node-ip.conf
+  permissions: "0644"
+`,
+			wantRendered: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			userData, rendered, err := renderUserData([]byte(tc.userData), tc.nodeIP)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantRendered, rendered)
+			assert.Equal(t, tc.wantUserData, string(userData))
+		})
+	}
+}
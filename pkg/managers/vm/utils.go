@@ -17,19 +17,25 @@ limitations under the License.
 package vm
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
 
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 )
 
-// upstreamMachineClusterIDLabel is the label that a machine must have to identify the cluster to which it belongs
-const upstreamMachineClusterIDLabel = "sigs.k8s.io/cluster-api-cluster"
-
 // existingInstanceStates returns the list of states an EC2 instance can be in
 // while being considered "existing", i.e. mostly anything but "Terminated".
 // func existingInstanceStates() []*string {
@@ -45,109 +51,339 @@ const upstreamMachineClusterIDLabel = "sigs.k8s.io/cluster-api-cluster"
 // 	}
 // }
 
-// setKubevirtMachineProviderCondition sets the condition for the machine and
-// returns the new slice of conditions.
-// If the machine does not already have a condition with the specified type,
-// a condition will be added to the slice
-// If the machine does already have a condition with the specified type,
-// the condition will be updated if either of the following are true.
-func setKubevirtMachineProviderCondition(condition kubevirtapiv1.VirtualMachineCondition, conditions []kubevirtapiv1.VirtualMachineCondition) []kubevirtapiv1.VirtualMachineCondition {
-	now := metav1.Now()
+// vmConditions computes the conditions of the machine from its VM and VMI. The VMI is nil until
+// KubeVirt starts it, which only happens once the data volumes of the VM are imported.
+func vmConditions(vm *kubevirtapiv1.VirtualMachine, vmi *kubevirtapiv1.VirtualMachineInstance) []kubevirtproviderv1.KubevirtMachineCondition {
+	result := []kubevirtproviderv1.KubevirtMachineCondition{
+		conditions.True(kubevirtproviderv1.VMProvisionedCondition, "VMCreated"),
+	}
 
-	if existingCondition := findProviderCondition(conditions, condition.Type); existingCondition == nil {
-		condition.LastProbeTime = now
-		condition.LastTransitionTime = now
-		conditions = append(conditions, condition)
-	} else {
-		updateExistingCondition(&condition, existingCondition)
+	switch failure := findVMCondition(vm, kubevirtapiv1.VirtualMachineFailure); {
+	case isVMIPaused(vmi):
+		result = append(result, conditions.False(kubevirtproviderv1.VMReadyCondition, "VMPaused", ""))
+	case vm.Status.Ready:
+		result = append(result, conditions.True(kubevirtproviderv1.VMReadyCondition, "VMReady"))
+	case isVMStopped(vm):
+		result = append(result, conditions.False(kubevirtproviderv1.VMReadyCondition, "VMStopped", ""))
+	case failure != nil && failure.Status == corev1.ConditionTrue:
+		result = append(result, conditions.False(kubevirtproviderv1.VMReadyCondition, string(failure.Reason), failure.Message))
+	default:
+		result = append(result, conditions.False(kubevirtproviderv1.VMReadyCondition, "VMNotReady", ""))
 	}
 
-	return conditions
-}
+	if vmi == nil {
+		return append(result,
+			conditions.False(kubevirtproviderv1.VolumesReadyCondition, "WaitingForDataVolumes", ""),
+			conditions.False(kubevirtproviderv1.NetworkReadyCondition, "WaitingForVMI", ""),
+			conditions.False(kubevirtproviderv1.AgentConnectedCondition, "WaitingForVMI", ""),
+		)
+	}
+	result = append(result, conditions.True(kubevirtproviderv1.VolumesReadyCondition, "DataVolumesImported"))
 
-func findProviderCondition(conditions []kubevirtapiv1.VirtualMachineCondition, conditionType kubevirtapiv1.VirtualMachineConditionType) *kubevirtapiv1.VirtualMachineCondition {
-	for i := range conditions {
-		if conditions[i].Type == conditionType {
-			return &conditions[i]
+	networkReady := conditions.False(kubevirtproviderv1.NetworkReadyCondition, "WaitingForIP", "")
+	for _, i := range vmi.Status.Interfaces {
+		if i.IP != "" || len(i.IPs) > 0 {
+			networkReady = conditions.True(kubevirtproviderv1.NetworkReadyCondition, "IPAssigned")
+			break
 		}
 	}
-	return nil
+	result = append(result, networkReady)
+
+	agentConnected := conditions.False(kubevirtproviderv1.AgentConnectedCondition, "AgentNotConnected", "")
+	for _, c := range vmi.Status.Conditions {
+		if c.Type == kubevirtapiv1.VirtualMachineInstanceAgentConnected && c.Status == corev1.ConditionTrue {
+			agentConnected = conditions.True(kubevirtproviderv1.AgentConnectedCondition, "AgentConnected")
+		}
+	}
+	return append(result, agentConnected)
+}
+
+// dataVolumeCondition reports the import or clone progress of the data volume in the VolumesReady condition
+func dataVolumeCondition(dataVolume *cdiv1.DataVolume) kubevirtproviderv1.KubevirtMachineCondition {
+	status := corev1.ConditionFalse
+	if dataVolume.Status.Phase == cdiv1.Succeeded {
+		status = corev1.ConditionTrue
+	}
+
+	var message string
+	switch dataVolume.Status.Phase {
+	case cdiv1.ImportInProgress:
+		message = fmt.Sprintf("Importing image: %s", dataVolume.Status.Progress)
+	case cdiv1.CloneInProgress, cdiv1.SmartClonePVCInProgress:
+		message = fmt.Sprintf("Cloning image: %s", dataVolume.Status.Progress)
+	case cdiv1.PhaseUnset:
+		return conditions.New(kubevirtproviderv1.VolumesReadyCondition, status, "WaitingForDataVolumes", fmt.Sprintf("Waiting for data volume %s", dataVolume.Name))
+	default:
+		message = fmt.Sprintf("Data volume %s is %s", dataVolume.Name, dataVolume.Status.Phase)
+	}
+
+	return conditions.New(kubevirtproviderv1.VolumesReadyCondition, status, string(dataVolume.Status.Phase), message)
 }
 
-func updateExistingCondition(newCondition, existingCondition *kubevirtapiv1.VirtualMachineCondition) {
-	if !shouldUpdateCondition(newCondition, existingCondition) {
-		return
+// reachedBootMilestones returns the boot milestones the VMI reached, in boot order
+func reachedBootMilestones(vmi *kubevirtapiv1.VirtualMachineInstance, machine *machinev1.Machine) []kubevirtproviderv1.BootMilestoneName {
+	var milestones []kubevirtproviderv1.BootMilestoneName
+	if vmi.Status.Phase != kubevirtapiv1.Running {
+		return milestones
 	}
+	milestones = append(milestones, kubevirtproviderv1.VMIStartedMilestone)
 
-	if existingCondition.Status != newCondition.Status {
-		existingCondition.LastTransitionTime = metav1.Now()
+	for _, c := range vmi.Status.Conditions {
+		if c.Type == kubevirtapiv1.VirtualMachineInstanceAgentConnected && c.Status == corev1.ConditionTrue {
+			milestones = append(milestones, kubevirtproviderv1.GuestAgentConnectedMilestone)
+		}
 	}
-	existingCondition.Status = newCondition.Status
-	existingCondition.Reason = newCondition.Reason
-	existingCondition.Message = newCondition.Message
-	existingCondition.LastProbeTime = newCondition.LastProbeTime
+
+	if machine.Status.NodeRef != nil {
+		milestones = append(milestones, kubevirtproviderv1.NodeJoinedMilestone)
+	}
+	return milestones
 }
 
-func shouldUpdateCondition(newCondition, existingCondition *kubevirtapiv1.VirtualMachineCondition) bool {
-	return newCondition.Reason != existingCondition.Reason || newCondition.Message != existingCondition.Message
+func findVMCondition(vm *kubevirtapiv1.VirtualMachine, conditionType kubevirtapiv1.VirtualMachineConditionType) *kubevirtapiv1.VirtualMachineCondition {
+	for i := range vm.Status.Conditions {
+		if vm.Status.Conditions[i].Type == conditionType {
+			return &vm.Status.Conditions[i]
+		}
+	}
+	return nil
 }
 
 // The network info is saved in the vmi
-// extractNodeAddresses maps the instance information from Vmi to an array of NodeAddresses
-func extractNodeAddresses(vmi *kubevirtapiv1.VirtualMachineInstance) ([]corev1.NodeAddress, error) {
-	// Not clear if the order matters here, but we might as well indicate a sensible preference order
-
+// extractNodeAddresses maps the instance information from Vmi to an array of NodeAddresses.
+// The addresses of the primary interface come first, since the first internal IP is the one the
+// node is expected to register with.
+func extractNodeAddresses(vmi *kubevirtapiv1.VirtualMachineInstance, primaryInterfaceName string) ([]corev1.NodeAddress, error) {
 	if vmi == nil {
 		return nil, fmt.Errorf("nil vmi passed to extractNodeAddresses")
 	}
 
+	interfaces := make([]kubevirtapiv1.VirtualMachineInstanceNetworkInterface, 0, len(vmi.Status.Interfaces))
+	for _, i := range vmi.Status.Interfaces {
+		if primaryInterfaceName != "" && i.Name == primaryInterfaceName {
+			interfaces = append([]kubevirtapiv1.VirtualMachineInstanceNetworkInterface{i}, interfaces...)
+		} else {
+			interfaces = append(interfaces, i)
+		}
+	}
+
 	addresses := []corev1.NodeAddress{}
-	interfaces := vmi.Status.Interfaces
 	for _, i := range interfaces {
-		if i.IP != "" {
-			addresses = append(addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: i.IP})
+		ips := i.IPs
+		if len(ips) == 0 && i.IP != "" {
+			ips = []string{i.IP}
+		}
+		for _, ip := range ips {
+			addresses = append(addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: ip})
 		}
 	}
 
 	return addresses, nil
 }
 
-// TODO There is only one kind of VirtualMachineConditionType: VirtualMachineFailure
-//      How should report on success?
-//      Is Failure/false is good enough or need to add type to client-go?
-func conditionSuccess() kubevirtapiv1.VirtualMachineCondition {
-	return kubevirtapiv1.VirtualMachineCondition{
-		Type:    kubevirtapiv1.VirtualMachineFailure,
-		Status:  corev1.ConditionFalse,
-		Reason:  "MachineCreationSucceeded",
-		Message: "Machine successfully created",
+// validateMachine check the label that a machine must have to identify the cluster to which it belongs is present.
+func validateMachine(machine machinev1.Machine) error {
+	// TODO: insert a validation on machine labels
+	if machine.Labels[machinev1.MachineClusterIDLabel] == "" {
+		return machinecontroller.InvalidMachineConfiguration("%v: missing %q label", machine.GetName(), machinev1.MachineClusterIDLabel)
 	}
+
+	return nil
 }
 
-func conditionFailed() kubevirtapiv1.VirtualMachineCondition {
-	return kubevirtapiv1.VirtualMachineCondition{
-		Type:   kubevirtapiv1.VirtualMachineFailure,
-		Status: corev1.ConditionTrue,
-		Reason: "MachineCreationFailed",
+// isVirtualMachineUpToDate returns true if every field the provider sets on the desired VM already has
+// the same value in the live VM. Fields only present in the live VM (defaults and fields set by the
+// underkube controllers) are ignored, so they don't cause a write on every sync.
+func isVirtualMachineUpToDate(desired, live *kubevirtapiv1.VirtualMachine) (bool, error) {
+	if !isStringMapSubset(desired.Labels, live.Labels) || !isStringMapSubset(desired.Annotations, live.Annotations) {
+		return false, nil
 	}
+
+	desiredSpec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&desired.Spec)
+	if err != nil {
+		return false, err
+	}
+	liveSpec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&live.Spec)
+	if err != nil {
+		return false, err
+	}
+	return isUnstructuredSubset(desiredSpec, liveSpec), nil
 }
 
-// validateMachine check the label that a machine must have to identify the cluster to which it belongs is present.
-func validateMachine(machine machinev1.Machine) error {
-	// TODO: insert a validation on machine labels
-	if machine.Labels[machinev1.MachineClusterIDLabel] == "" {
-		return machinecontroller.InvalidMachineConfiguration("%v: missing %q label", machine.GetName(), machinev1.MachineClusterIDLabel)
+func isStringMapSubset(subset, set map[string]string) bool {
+	for key, value := range subset {
+		if setValue, ok := set[key]; !ok || setValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// isUnstructuredSubset walks the desired object and checks that the live object holds the same values.
+// Lists must have the same length, and their items are compared pairwise. Empty strings are fields
+// the provider doesn't set (e.g. a machine type left for the underkube to default).
+func isUnstructuredSubset(desired, live interface{}) bool {
+	switch desiredValue := desired.(type) {
+	case nil:
+		return true
+	case string:
+		return desiredValue == "" || desiredValue == live
+	case map[string]interface{}:
+		liveValue, ok := live.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key, value := range desiredValue {
+			if !isUnstructuredSubset(value, liveValue[key]) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		liveValue, ok := live.([]interface{})
+		if !ok || len(liveValue) != len(desiredValue) {
+			return false
+		}
+		for i := range desiredValue {
+			if !isUnstructuredSubset(desiredValue[i], liveValue[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return equality.Semantic.DeepEqual(desired, live)
+	}
+}
+
+// mergeVirtualMachine applies the changes between the last applied and the desired VM on top of the
+// live VM, with a three-way strategic merge. Fields the provider never set, like annotations or
+// tolerations added by an infra admin, are kept as they are in the live VM, while fields the provider
+// stopped setting are removed.
+func mergeVirtualMachine(desired, live *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
+	patchMeta, err := strategicpatch.NewPatchMetaFromStruct(kubevirtapiv1.VirtualMachine{})
+	if err != nil {
+		return nil, err
+	}
+
+	// A VM created before the provider recorded its configuration has no original, which makes
+	// the merge a plain overlay of the desired VM on the live one.
+	original := []byte(live.Annotations[render.LastAppliedConfigurationAnnotation])
+	modified, err := json.Marshal(render.AppliedConfiguration(desired))
+	if err != nil {
+		return nil, err
+	}
+	current, err := json.Marshal(live)
+	if err != nil {
+		return nil, err
+	}
+
+	patch, err := strategicpatch.CreateThreeWayMergePatch(original, modified, current, patchMeta, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute the VM patch: %w", err)
+	}
+	mergedJSON, err := strategicpatch.StrategicMergePatchUsingLookupPatchMeta(current, patch, patchMeta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply the VM patch: %w", err)
 	}
 
+	merged := &kubevirtapiv1.VirtualMachine{}
+	if err := json.Unmarshal(mergedJSON, merged); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// validateIgnoredFields checks the ignored fields only point to the parts of the VM the provider sets.
+func validateIgnoredFields(machineName string, paths []string) error {
+	for _, path := range paths {
+		if !strings.HasPrefix(path, "spec.") && path != "metadata.labels" && path != "metadata.annotations" &&
+			!strings.HasPrefix(path, "metadata.labels.") && !strings.HasPrefix(path, "metadata.annotations.") {
+			return machinecontroller.InvalidMachineConfiguration("%v: ignored field %q must be under spec, metadata.labels or metadata.annotations", machineName, path)
+		}
+	}
 	return nil
 }
 
-// getClusterID get cluster ID by machine.openshift.io/cluster-api-cluster label
-func getClusterID(machine *machinev1.Machine) (string, bool) {
-	clusterID, ok := machine.Labels[machinev1.MachineClusterIDLabel]
-	// NOTE: This block can be removed after the label renaming transition to machine.openshift.io
-	if !ok {
-		clusterID, ok = machine.Labels[upstreamMachineClusterIDLabel]
+// applyIgnoredFields sets the ignored fields of the desired VM to their value in the live VM, or removes
+// them when the live VM doesn't have them, so the update leaves them untouched.
+func applyIgnoredFields(paths []string, desired, live *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
+	if len(paths) == 0 {
+		return desired, nil
+	}
+
+	desiredObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return nil, err
+	}
+	liveObject, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		fields := strings.Split(path, ".")
+		value, found, err := unstructured.NestedFieldNoCopy(liveObject, fields...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ignored field %q: %w", path, err)
+		}
+		if !found {
+			unstructured.RemoveNestedField(desiredObject, fields...)
+			continue
+		}
+		if err := unstructured.SetNestedField(desiredObject, value, fields...); err != nil {
+			return nil, fmt.Errorf("failed to set ignored field %q: %w", path, err)
+		}
+	}
+
+	result := &kubevirtapiv1.VirtualMachine{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(desiredObject, result); err != nil {
+		return nil, err
+	}
+	// The ignored fields hold the live values now, record them so the merge sees no change on them
+	if err := render.SetLastAppliedConfiguration(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// keepDataVolumeAccessModes sets the access modes of the data volume templates of the live VM on the desired
+// one, as they can't change once the data volumes exist: a change of the eviction strategy only changes the
+// access mode of the boot volumes of the new VMs.
+func keepDataVolumeAccessModes(desired, live *kubevirtapiv1.VirtualMachine) (*kubevirtapiv1.VirtualMachine, error) {
+	liveAccessModes := map[string][]corev1.PersistentVolumeAccessMode{}
+	for _, dataVolume := range live.Spec.DataVolumeTemplates {
+		if dataVolume.Spec.PVC != nil {
+			liveAccessModes[dataVolume.Name] = dataVolume.Spec.PVC.AccessModes
+		}
+	}
+
+	result := desired.DeepCopy()
+	changed := false
+	for i := range result.Spec.DataVolumeTemplates {
+		dataVolume := &result.Spec.DataVolumeTemplates[i]
+		accessModes, ok := liveAccessModes[dataVolume.Name]
+		if !ok || dataVolume.Spec.PVC == nil || equality.Semantic.DeepEqual(dataVolume.Spec.PVC.AccessModes, accessModes) {
+			continue
+		}
+		dataVolume.Spec.PVC.AccessModes = accessModes
+		changed = true
+	}
+	if !changed {
+		return desired, nil
+	}
+	// Record the live access modes, so the merge sees no change on them
+	if err := render.SetLastAppliedConfiguration(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// buildVMResourceLabels returns the labels of the underkube resources created for the VM of the machine
+// besides the VM itself, so the orphan scan finds them
+func buildVMResourceLabels(vmName string, machine *machinev1.Machine) map[string]string {
+	labels := map[string]string{render.VMLabel: vmName}
+	if clusterID, ok := render.ClusterID(machine); ok {
+		labels[machinev1.MachineClusterIDLabel] = clusterID
 	}
-	return clusterID, ok
+	return labels
 }
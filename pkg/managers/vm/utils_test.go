@@ -2,7 +2,316 @@ package vm
 
 import (
 	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 )
 
 func TestExtractNodeAddresses(t *testing.T) {
+	vmi := &kubevirtapiv1.VirtualMachineInstance{
+		Status: kubevirtapiv1.VirtualMachineInstanceStatus{
+			Interfaces: []kubevirtapiv1.VirtualMachineInstanceNetworkInterface{
+				{Name: "default", IP: "10.128.0.5"},
+				{Name: "node", IP: "10.0.0.5", IPs: []string{"10.0.0.5", "fd00::5"}},
+			},
+		},
+	}
+
+	addresses, err := extractNodeAddresses(vmi, "node")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, []corev1.NodeAddress{
+		{Type: corev1.NodeInternalIP, Address: "10.0.0.5"},
+		{Type: corev1.NodeInternalIP, Address: "fd00::5"},
+		{Type: corev1.NodeInternalIP, Address: "10.128.0.5"},
+	}, addresses)
+
+	_, err = extractNodeAddresses(nil, "")
+	assert.Error(t, err, "nil vmi passed to extractNodeAddresses")
+}
+
+func TestIsVirtualMachineUpToDate(t *testing.T) {
+	runAlways := kubevirtapiv1.RunStrategyAlways
+	runHalted := kubevirtapiv1.RunStrategyHalted
+	desired := &kubevirtapiv1.VirtualMachine{
+		Spec: kubevirtapiv1.VirtualMachineSpec{
+			RunStrategy: &runAlways,
+			Template: &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kubevirtapiv1.VirtualMachineInstanceSpec{
+					Domain: kubevirtapiv1.DomainSpec{
+						Devices: kubevirtapiv1.Devices{
+							Disks: []kubevirtapiv1.Disk{{Name: "bootvolume"}},
+						},
+					},
+				},
+			},
+		},
+	}
+	desired.Labels = map[string]string{"name": "machine-test"}
+
+	cases := []struct {
+		name         string
+		mutateLive   func(vm *kubevirtapiv1.VirtualMachine)
+		wantUpToDate bool
+	}{
+		{
+			name:         "identical VM",
+			mutateLive:   func(vm *kubevirtapiv1.VirtualMachine) {},
+			wantUpToDate: true,
+		},
+		{
+			name: "live VM with server side defaults and extra labels",
+			mutateLive: func(vm *kubevirtapiv1.VirtualMachine) {
+				vm.Labels["kubevirt.io/extra"] = "true"
+				vm.Spec.Template.Spec.Domain.Machine.Type = "q35"
+			},
+			wantUpToDate: true,
+		},
+		{
+			name: "changed run strategy",
+			mutateLive: func(vm *kubevirtapiv1.VirtualMachine) {
+				vm.Spec.RunStrategy = &runHalted
+			},
+			wantUpToDate: false,
+		},
+		{
+			name: "removed label",
+			mutateLive: func(vm *kubevirtapiv1.VirtualMachine) {
+				delete(vm.Labels, "name")
+			},
+			wantUpToDate: false,
+		},
+		{
+			name: "extra disk",
+			mutateLive: func(vm *kubevirtapiv1.VirtualMachine) {
+				vm.Spec.Template.Spec.Domain.Devices.Disks = append(vm.Spec.Template.Spec.Domain.Devices.Disks, kubevirtapiv1.Disk{Name: "extra"})
+			},
+			wantUpToDate: false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			live := desired.DeepCopy()
+			tc.mutateLive(live)
+			upToDate, err := isVirtualMachineUpToDate(desired, live)
+			assert.NilError(t, err)
+			assert.Equal(t, tc.wantUpToDate, upToDate)
+		})
+	}
+}
+
+func TestMergeVirtualMachine(t *testing.T) {
+	runAlways := kubevirtapiv1.RunStrategyAlways
+	runHalted := kubevirtapiv1.RunStrategyHalted
+	applied := &kubevirtapiv1.VirtualMachine{
+		Spec: kubevirtapiv1.VirtualMachineSpec{
+			RunStrategy: &runHalted,
+			Template:    &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{},
+		},
+	}
+	applied.Name = "machine-test"
+	applied.Labels = map[string]string{"name": "machine-test", "role": "worker"}
+	assert.NilError(t, render.SetLastAppliedConfiguration(applied))
+
+	live := applied.DeepCopy()
+	live.ResourceVersion = "42"
+	live.Annotations["infra.example.com/owner"] = "admin"
+	live.Spec.Template.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+	live.Status.Ready = true
+
+	desired := applied.DeepCopy()
+	desired.Spec.RunStrategy = &runAlways
+	delete(desired.Labels, "role")
+	assert.NilError(t, render.SetLastAppliedConfiguration(desired))
+
+	merged, err := mergeVirtualMachine(desired, live)
+	assert.NilError(t, err)
+	assert.Equal(t, runAlways, *merged.Spec.RunStrategy)
+	assert.DeepEqual(t, map[string]string{"name": "machine-test"}, merged.Labels)
+	assert.Equal(t, "admin", merged.Annotations["infra.example.com/owner"])
+	assert.Equal(t, desired.Annotations[render.LastAppliedConfigurationAnnotation], merged.Annotations[render.LastAppliedConfigurationAnnotation])
+	assert.DeepEqual(t, live.Spec.Template.Spec.Tolerations, merged.Spec.Template.Spec.Tolerations)
+	assert.Equal(t, "42", merged.ResourceVersion)
+	assert.Equal(t, true, merged.Status.Ready)
+}
+
+func TestApplyIgnoredFields(t *testing.T) {
+	runAlways := kubevirtapiv1.RunStrategyAlways
+	desired := &kubevirtapiv1.VirtualMachine{
+		Spec: kubevirtapiv1.VirtualMachineSpec{
+			RunStrategy: &runAlways,
+			Template:    &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{},
+		},
+	}
+	desired.Name = "machine-test"
+	desired.Spec.Template.ObjectMeta.Labels = map[string]string{"name": "machine-test"}
+	assert.NilError(t, render.SetLastAppliedConfiguration(desired))
+
+	live := desired.DeepCopy()
+	live.Spec.Template.ObjectMeta.Annotations = map[string]string{"infra.example.com/tweak": "true"}
+	live.Spec.Template.ObjectMeta.Labels = nil
+
+	result, err := applyIgnoredFields([]string{"spec.template.metadata.annotations", "spec.template.metadata.labels"}, desired, live)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, live.Spec.Template.ObjectMeta.Annotations, result.Spec.Template.ObjectMeta.Annotations)
+	assert.Assert(t, result.Spec.Template.ObjectMeta.Labels == nil)
+	assert.Equal(t, runAlways, *result.Spec.RunStrategy)
+
+	merged, err := mergeVirtualMachine(result, live)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, live.Spec.Template.ObjectMeta.Annotations, merged.Spec.Template.ObjectMeta.Annotations)
+}
+
+func TestKeepDataVolumeAccessModes(t *testing.T) {
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{SourcePvcName: SourceTestPvcName, IgnitionSecretName: workerUserDataSecretName}
+	live, err := render.RenderVirtualMachine(machine, providerSpec, render.Defaults{})
+	assert.NilError(t, err)
+
+	providerSpec.EvictionStrategy = kubevirtproviderv1.EvictionStrategyLiveMigrate
+	desired, err := render.RenderVirtualMachine(machine, providerSpec, render.Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, desired.Spec.DataVolumeTemplates[0].Spec.PVC.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany})
+
+	result, err := keepDataVolumeAccessModes(desired, live)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result.Spec.DataVolumeTemplates[0].Spec.PVC.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce})
+	assert.DeepEqual(t, desired.Spec.DataVolumeTemplates[0].Spec.PVC.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany})
+	merged, err := mergeVirtualMachine(result, live)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, merged.Spec.DataVolumeTemplates[0].Spec.PVC.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce})
+	assert.Assert(t, merged.Spec.Template.Spec.EvictionStrategy != nil)
+
+	unchanged, err := keepDataVolumeAccessModes(live, live.DeepCopy())
+	assert.NilError(t, err)
+	assert.Assert(t, unchanged == live)
+}
+
+func TestValidateIgnoredFields(t *testing.T) {
+	assert.NilError(t, validateIgnoredFields("machine-test", []string{"spec.template.metadata.annotations", "metadata.labels"}))
+	assert.Error(t, validateIgnoredFields("machine-test", []string{"status.ready"}),
+		`machine-test: ignored field "status.ready" must be under spec, metadata.labels or metadata.annotations`)
+}
+
+func TestVMConditions(t *testing.T) {
+	conditionStatuses := func(conditions []kubevirtproviderv1.KubevirtMachineCondition) map[kubevirtproviderv1.KubevirtMachineConditionType]string {
+		statuses := map[kubevirtproviderv1.KubevirtMachineConditionType]string{}
+		for _, c := range conditions {
+			statuses[c.Type] = string(c.Status) + "/" + c.Reason
+		}
+		return statuses
+	}
+
+	cases := []struct {
+		name     string
+		vm       *kubevirtapiv1.VirtualMachine
+		vmi      *kubevirtapiv1.VirtualMachineInstance
+		expected map[kubevirtproviderv1.KubevirtMachineConditionType]string
+	}{
+		{
+			name: "Waiting for the data volumes",
+			vm:   &kubevirtapiv1.VirtualMachine{},
+			expected: map[kubevirtproviderv1.KubevirtMachineConditionType]string{
+				kubevirtproviderv1.VMProvisionedCondition:  "True/VMCreated",
+				kubevirtproviderv1.VMReadyCondition:        "False/VMNotReady",
+				kubevirtproviderv1.VolumesReadyCondition:   "False/WaitingForDataVolumes",
+				kubevirtproviderv1.NetworkReadyCondition:   "False/WaitingForVMI",
+				kubevirtproviderv1.AgentConnectedCondition: "False/WaitingForVMI",
+			},
+		},
+		{
+			name: "Failed VM",
+			vm: &kubevirtapiv1.VirtualMachine{Status: kubevirtapiv1.VirtualMachineStatus{Conditions: []kubevirtapiv1.VirtualMachineCondition{
+				{Type: kubevirtapiv1.VirtualMachineFailure, Status: corev1.ConditionTrue, Reason: "FailedCreate"},
+			}}},
+			vmi: &kubevirtapiv1.VirtualMachineInstance{},
+			expected: map[kubevirtproviderv1.KubevirtMachineConditionType]string{
+				kubevirtproviderv1.VMProvisionedCondition:  "True/VMCreated",
+				kubevirtproviderv1.VMReadyCondition:        "False/FailedCreate",
+				kubevirtproviderv1.VolumesReadyCondition:   "True/DataVolumesImported",
+				kubevirtproviderv1.NetworkReadyCondition:   "False/WaitingForIP",
+				kubevirtproviderv1.AgentConnectedCondition: "False/AgentNotConnected",
+			},
+		},
+		{
+			name: "Running VM with the guest agent",
+			vm:   &kubevirtapiv1.VirtualMachine{Status: kubevirtapiv1.VirtualMachineStatus{Created: true, Ready: true}},
+			vmi: &kubevirtapiv1.VirtualMachineInstance{Status: kubevirtapiv1.VirtualMachineInstanceStatus{
+				Interfaces: []kubevirtapiv1.VirtualMachineInstanceNetworkInterface{{Name: "default", IP: "10.128.0.5"}},
+				Conditions: []kubevirtapiv1.VirtualMachineInstanceCondition{
+					{Type: kubevirtapiv1.VirtualMachineInstanceAgentConnected, Status: corev1.ConditionTrue},
+				},
+			}},
+			expected: map[kubevirtproviderv1.KubevirtMachineConditionType]string{
+				kubevirtproviderv1.VMProvisionedCondition:  "True/VMCreated",
+				kubevirtproviderv1.VMReadyCondition:        "True/VMReady",
+				kubevirtproviderv1.VolumesReadyCondition:   "True/DataVolumesImported",
+				kubevirtproviderv1.NetworkReadyCondition:   "True/IPAssigned",
+				kubevirtproviderv1.AgentConnectedCondition: "True/AgentConnected",
+			},
+		},
+		{
+			name: "Paused VM",
+			vm:   &kubevirtapiv1.VirtualMachine{Status: kubevirtapiv1.VirtualMachineStatus{Created: true, Ready: true}},
+			vmi: &kubevirtapiv1.VirtualMachineInstance{Status: kubevirtapiv1.VirtualMachineInstanceStatus{
+				Interfaces: []kubevirtapiv1.VirtualMachineInstanceNetworkInterface{{Name: "default", IP: "10.128.0.5"}},
+				Conditions: []kubevirtapiv1.VirtualMachineInstanceCondition{
+					{Type: kubevirtapiv1.VirtualMachineInstancePaused, Status: corev1.ConditionTrue},
+				},
+			}},
+			expected: map[kubevirtproviderv1.KubevirtMachineConditionType]string{
+				kubevirtproviderv1.VMProvisionedCondition:  "True/VMCreated",
+				kubevirtproviderv1.VMReadyCondition:        "False/VMPaused",
+				kubevirtproviderv1.VolumesReadyCondition:   "True/DataVolumesImported",
+				kubevirtproviderv1.NetworkReadyCondition:   "True/IPAssigned",
+				kubevirtproviderv1.AgentConnectedCondition: "False/AgentNotConnected",
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.DeepEqual(t, conditionStatuses(vmConditions(tc.vm, tc.vmi)), tc.expected)
+		})
+	}
+}
+
+func TestDataVolumeCondition(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   cdiv1.DataVolumeStatus
+		expected string
+	}{
+		{
+			name:     "Import in progress",
+			status:   cdiv1.DataVolumeStatus{Phase: cdiv1.ImportInProgress, Progress: "42.00%"},
+			expected: "False/ImportInProgress/Importing image: 42.00%",
+		},
+		{
+			name:     "Clone in progress",
+			status:   cdiv1.DataVolumeStatus{Phase: cdiv1.CloneInProgress, Progress: "7.50%"},
+			expected: "False/CloneInProgress/Cloning image: 7.50%",
+		},
+		{
+			name:     "Pending",
+			expected: "False/WaitingForDataVolumes/Waiting for data volume machine-test-bootvolume",
+		},
+		{
+			name:     "Imported",
+			status:   cdiv1.DataVolumeStatus{Phase: cdiv1.Succeeded, Progress: "100.0%"},
+			expected: "True/Succeeded/Data volume machine-test-bootvolume is Succeeded",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			dataVolume := &cdiv1.DataVolume{Status: tc.status}
+			dataVolume.Name = render.BootVolumeName(mahcineName)
+			condition := dataVolumeCondition(dataVolume)
+			assert.Equal(t, condition.Type, kubevirtproviderv1.VolumesReadyCondition)
+			assert.Equal(t, string(condition.Status)+"/"+condition.Reason+"/"+condition.Message, tc.expected)
+		})
+	}
 }
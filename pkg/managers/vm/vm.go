@@ -1,13 +1,22 @@
 package vm
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
 
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/conditions"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
@@ -29,6 +38,9 @@ type ProviderVM interface {
 	Delete(machine *machinev1.Machine) error
 	Update(machine *machinev1.Machine) (bool, error)
 	Exists(machine *machinev1.Machine) (bool, error)
+	PortForward(namespace, machineName string, port int) (io.ReadWriteCloser, error)
+	RepairProviderID(machine *machinev1.Machine) (bool, error)
+	RecordInterruptedOperation(machine *machinev1.Machine, operationType kubevirtproviderv1.OperationType) error
 }
 
 // manager is the struct which implement ProviderVM interface
@@ -37,58 +49,100 @@ type ProviderVM interface {
 type manager struct {
 	underkubeClientBuilder underkube.ClientBuilderFuncType
 	overkubeClient         overkube.Client
+	providerConfig         *providerconfig.Store
+	// credentialsFromSecrets is true when the underkube clients are built from the credentials secrets of the
+	// machines, rather than from a kubeconfig file or the in-cluster configuration
+	credentialsFromSecrets bool
 }
 
-// New creates provider vm instance
-func New(underkubeClientBuilder underkube.ClientBuilderFuncType, overkubeClient overkube.Client) ProviderVM {
+// New creates provider vm instance, the provider config store holds the defaults of the provider specs
+func New(underkubeClientBuilder underkube.ClientBuilderFuncType, overkubeClient overkube.Client, providerConfig *providerconfig.Store, credentialsFromSecrets bool) ProviderVM {
 	return &manager{
 		overkubeClient:         overkubeClient,
 		underkubeClientBuilder: underkubeClientBuilder,
+		providerConfig:         providerConfig,
+		credentialsFromSecrets: credentialsFromSecrets,
 	}
 }
 
 // Create creates machine if it does not exists.
 func (m *manager) Create(machine *machinev1.Machine) (resultErr error) {
-	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder)
+	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder, m.providerConfig.Get(), m.credentialsFromSecrets)
 	if err != nil {
 		return err
 	}
 
-	virtualMachineFromMachine, err := machineScope.createVirtualMachineFromMachine()
-	if err != nil {
-		return err
-	}
-
-	klog.Infof("%s: create machine", machineScope.getMachineName())
-
 	defer func() {
 		// After the operation is done (success or failure)
 		// Update the machine object with the relevant changes
+		machineScope.setLastOperation(kubevirtproviderv1.CreateOperation, resultErr)
 		if err := machineScope.patchMachine(); err != nil {
 			resultErr = err
 		}
 	}()
 
-	createdVM, err := m.createUnderkubeVM(virtualMachineFromMachine, machineScope)
+	virtualMachineFromMachine, err := machineScope.createVirtualMachineFromMachine()
+	if err != nil {
+		return err
+	}
+
+	klog.Infof("%s: create machine", machineScope.getMachineName())
+
+	if err := m.preflight(virtualMachineFromMachine, machineScope); err != nil {
+		return err
+	}
+
+	if err := m.checkMediatedDevices(machineScope); err != nil {
+		return err
+	}
+
+	if err := m.checkBudget(virtualMachineFromMachine, machineScope); err != nil {
+		return err
+	}
+
+	if err := m.authorizeClones(virtualMachineFromMachine, machineScope); err != nil {
+		return err
+	}
+
+	if err := m.syncUserData(virtualMachineFromMachine, machineScope); err != nil {
+		return fmt.Errorf("failed to sync user data: %w", err)
+	}
+
+	if err := machineScope.setCreationIntent(virtualMachineFromMachine); err != nil {
+		return err
+	}
+
+	createdVM, err := m.createOrAdoptVM(virtualMachineFromMachine, machineScope)
 
 	if err != nil {
 		klog.Errorf("%s: error creating machine: %v", machineScope.getMachineName(), err)
-		conditionFailed := conditionFailed()
-		conditionFailed.Message = err.Error()
+		machineScope.setCondition(conditions.Failed(kubevirtproviderv1.VMProvisionedCondition, "VMCreationFailed", err))
 		return fmt.Errorf("failed to create virtual machine: %w", err)
 	}
+	machineScope.recordVMMutation(kubevirtproviderv1.VMCreatedMutation, "")
 
-	_, err = m.createUnderkubeService(virtualMachineFromMachine.Name, virtualMachineFromMachine.Namespace, machineScope)
+	var service *corev1.Service
+	if machineScope.isSharedServiceMode() {
+		service, err = m.syncSharedService(createdVM, machineScope)
+	} else if machineScope.hasService() {
+		service, err = m.createUnderkubeService(virtualMachineFromMachine.Name, virtualMachineFromMachine.Namespace, machineScope)
+		machineScope.machineProviderStatus.MachineServiceCreated = err == nil
+	}
 	if err != nil {
 		klog.Errorf("%s: error creating machine: %v", machineScope.getMachineName(), err)
-		conditionFailed := conditionFailed()
-		conditionFailed.Message = err.Error()
+		machineScope.setCondition(conditions.Failed(kubevirtproviderv1.NetworkReadyCondition, "ServiceCreationFailed", err))
 		return fmt.Errorf("failed to create service: %w", err)
 	}
 
+	if machineScope.machineProviderSpec.Expose != nil {
+		if err := m.syncIngress(virtualMachineFromMachine, machineScope); err != nil {
+			return fmt.Errorf("failed to create ingress: %w", err)
+		}
+	}
+
 	klog.Infof("Created Machine %v", machineScope.getMachineName())
 
-	if err := m.syncMachine(createdVM, machineScope); err != nil {
+	if err := m.syncMachine(createdVM, service, machineScope); err != nil {
 		klog.Errorf("%s: fail syncing machine from vm: %v", machineScope.getMachineName(), err)
 		return err
 	}
@@ -96,13 +150,44 @@ func (m *manager) Create(machine *machinev1.Machine) (resultErr error) {
 	return nil
 }
 
+// preflight checks the underkube can run the VM before creating anything, so a machine targeting an
+// underkube without KubeVirt fails right away with a hint rather than with an API error
+func (m *manager) preflight(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	needs := infraCapabilityNeeds(vm, machineScope.machineProviderSpec)
+	options := underkube.PreflightOptions{
+		Namespace:    vm.Namespace,
+		Capabilities: requiredCapabilities(needs),
+		FeatureGates: requiredFeatureGates(vm),
+	}
+	capabilities, err := underkube.Preflight(machineScope.underkubeClient, options)
+	if capabilities != nil {
+		machineScope.setCondition(missingCapabilitiesCondition(needs, capabilities))
+	}
+	if underkube.IsInfraNotReady(err) {
+		machineScope.setCondition(conditions.Failed(kubevirtproviderv1.InfraReadyCondition, string(kubevirtproviderv1.InfraNotReadyFailure), err))
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to run the preflight checks: %w", err)
+	}
+	machineScope.setCondition(conditions.True(kubevirtproviderv1.InfraReadyCondition, "InfraReady"))
+	return nil
+}
+
 // delete deletes machine
-func (m *manager) Delete(machine *machinev1.Machine) error {
-	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder)
+func (m *manager) Delete(machine *machinev1.Machine) (resultErr error) {
+	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder, m.providerConfig.Get(), m.credentialsFromSecrets)
 	if err != nil {
 		return err
 	}
 
+	defer func() {
+		machineScope.setLastOperation(kubevirtproviderv1.DeleteOperation, resultErr)
+		if err := machineScope.patchMachine(); err != nil {
+			resultErr = err
+		}
+	}()
+
 	virtualMachineFromMachine, err := machineScope.createVirtualMachineFromMachine()
 	if err != nil {
 		return err
@@ -115,8 +200,7 @@ func (m *manager) Delete(machine *machinev1.Machine) error {
 		// TODO ask Nir how to check it
 		if strings.Contains(err.Error(), "not found") {
 			klog.Infof("%s: VM does not exist", machineScope.getMachineName())
-			return m.removeServiceIfNeeded(virtualMachineFromMachine, machineScope)
-
+			return m.removeDependentsOfDeletedVM(virtualMachineFromMachine, machineScope)
 		}
 
 		klog.Errorf("%s: error getting existing VM: %v", machineScope.getMachineName(), err)
@@ -125,15 +209,31 @@ func (m *manager) Delete(machine *machinev1.Machine) error {
 
 	if existingVM == nil {
 		klog.Warningf("%s: VM not found to delete for machine", machineScope.getMachineName())
-		return m.removeServiceIfNeeded(virtualMachineFromMachine, machineScope)
+		return m.removeDependentsOfDeletedVM(virtualMachineFromMachine, machineScope)
 	}
 
-	if err := m.deleteUnderkubeVM(existingVM.GetName(), existingVM.GetNamespace(), machineScope); err != nil {
+	if err := m.checkDeletionProtection(existingVM, machineScope); err != nil {
+		return err
+	}
+	gracePeriod := deletionGracePeriod(machineScope.machineProviderSpec)
+	if deadline := getDeadlines(machineScope.machineProviderSpec).deletionDeadline; deletionDeadlineElapsed(deadline, machine, time.Now()) {
+		machineScope.setFailure(kubevirtproviderv1.DeletionTimeoutFailure, fmt.Sprintf("the VM is not deleted %v after the machine deletion", deadline))
+		klog.Warningf("%s: deletion deadline elapsed, deleting the VM without grace period", machineScope.getMachineName())
+		gracePeriod = 0
+	} else if err := m.checkNodeDrained(machineScope); err != nil {
+		return err
+	} else if err := m.checkDeletionSlot(existingVM, machineScope); err != nil {
+		return err
+	}
+	if err := m.deleteUnderkubeVM(existingVM.GetName(), existingVM.GetNamespace(), gracePeriod, machineScope); err != nil {
 		return fmt.Errorf("failed to delete VM: %w", err)
 	}
+	if existingVM.DeletionTimestamp == nil {
+		machineScope.recordVMMutation(kubevirtproviderv1.VMDeletedMutation, "")
+	}
 
-	if err := m.removeServiceIfNeeded(virtualMachineFromMachine, machineScope); err != nil {
-		return fmt.Errorf("failed to delete the service of VM: %w", err)
+	if err := m.removeVMDependents(virtualMachineFromMachine, machineScope); err != nil {
+		return err
 	}
 
 	klog.Infof("Deleted machine %v", machineScope.getMachineName())
@@ -142,10 +242,25 @@ func (m *manager) Delete(machine *machinev1.Machine) error {
 }
 
 func (m *manager) removeServiceIfNeeded(virtualMachineFromMachine *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	if machineScope.isSharedServiceMode() {
+		if err := m.removeSharedServiceEndpoint(virtualMachineFromMachine.Name, virtualMachineFromMachine.Namespace, machineScope); err != nil {
+			return err
+		}
+	}
+	return m.removeMachineServiceIfNeeded(virtualMachineFromMachine, machineScope)
+}
+
+// removeMachineServiceIfNeeded removes the service of the VM, when the machine has one or had one before its
+// service mode changed
+func (m *manager) removeMachineServiceIfNeeded(virtualMachineFromMachine *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	if !machineScope.hasMachineService() {
+		return nil
+	}
 	service, err := m.getUnderkubeService(virtualMachineFromMachine.GetName(), virtualMachineFromMachine.GetNamespace(), machineScope)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			klog.Infof("%s: Service does not exist", machineScope.getMachineName())
+			machineScope.machineProviderStatus.MachineServiceCreated = false
 			return nil
 		}
 		klog.Errorf("%s: error getting service of VM: %v", machineScope.getMachineName(), err)
@@ -156,46 +271,80 @@ func (m *manager) removeServiceIfNeeded(virtualMachineFromMachine *kubevirtapiv1
 			return err
 		}
 	}
+	machineScope.machineProviderStatus.MachineServiceCreated = false
 	klog.Infof("Deleted service %v", machineScope.getMachineName())
 	return nil
 }
 
 // update finds a vm and reconciles the machine resource status against it.
 func (m *manager) Update(machine *machinev1.Machine) (wasUpdated bool, resultErr error) {
-	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder)
+	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder, m.providerConfig.Get(), m.credentialsFromSecrets)
 	if err != nil {
 		return false, err
 	}
 
-	virtualMachineFromMachine, err := machineScope.createVirtualMachineFromMachine()
-	if err != nil {
-		return false, err
-	}
-
-	klog.Infof("%s: update machine", machineScope.getMachineName())
-
 	defer func() {
 		// After the operation is done (success or failure)
 		// Update the machine object with the relevant changes
+		machineScope.setLastOperation(kubevirtproviderv1.UpdateOperation, resultErr)
 		if err := machineScope.patchMachine(); err != nil {
 			resultErr = err
 		}
 	}()
 
+	virtualMachineFromMachine, err := machineScope.createVirtualMachineFromMachine()
+	if err != nil {
+		return false, err
+	}
+
+	klog.Infof("%s: update machine", machineScope.getMachineName())
+
 	wasUpdated, updatedVM, err := m.updateVM(err, virtualMachineFromMachine, machineScope)
 	if err != nil {
 		return false, err
 	}
 
-	err = m.createServiceIfNeeded(err, updatedVM, machineScope, updatedVM, virtualMachineFromMachine)
+	if err := m.expandBootVolumeIfNeeded(updatedVM, machineScope); err != nil {
+		return false, fmt.Errorf("failed to expand the boot volume: %w", err)
+	}
+
+	if err := m.cloneIfRequested(updatedVM, machineScope); err != nil {
+		return false, err
+	}
+
+	if err := m.syncPause(updatedVM, machineScope); err != nil {
+		return false, err
+	}
+
+	if err := m.syncRestart(updatedVM, machineScope); err != nil {
+		return false, err
+	}
+
+	service, err := m.createServiceIfNeeded(err, updatedVM, machineScope, updatedVM, virtualMachineFromMachine)
 	if err != nil {
 		return false, err
 	}
 
-	if err := m.syncMachine(updatedVM, machineScope); err != nil {
+	if machineScope.hasIngress() {
+		if err := m.syncIngress(updatedVM, machineScope); err != nil {
+			return false, fmt.Errorf("failed to sync ingress: %w", err)
+		}
+	}
+
+	if err := m.syncMachine(updatedVM, service, machineScope); err != nil {
 		klog.Errorf("%s: fail syncing machine from vm: %v", machineScope.getMachineName(), err)
 		return false, err
 	}
+	if machineScope.isFailed() {
+		// Fail the update, the machine controller would move the machine out of the Failed phase otherwise
+		return false, fmt.Errorf("machine failed: %s", *machineScope.machine.Status.ErrorMessage)
+	}
+	// The machine controller moves the machine to the Provisioned phase once the update succeeds
+	if machineScope.isProvisioning() && !isVMStopped(updatedVM) {
+		if err := m.requeueIfInstancePending(updatedVM, machineScope); err != nil {
+			return wasUpdated, err
+		}
+	}
 	return wasUpdated, nil
 }
 
@@ -217,79 +366,267 @@ func (m *manager) updateVM(err error, virtualMachineFromMachine *kubevirtapiv1.V
 		return false, nil, &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterFatalSeconds * time.Second}
 	}
 
-	previousResourceVersion := existingVM.ResourceVersion
-	virtualMachineFromMachine.ObjectMeta.ResourceVersion = previousResourceVersion
+	if err := m.syncUserData(virtualMachineFromMachine, machineScope); err != nil {
+		return false, nil, fmt.Errorf("failed to sync user data: %w", err)
+	}
+
+	powerChanged, err := m.syncPowerState(virtualMachineFromMachine, existingVM, machineScope)
+	if err != nil {
+		return false, nil, err
+	}
+	if powerChanged {
+		// The stop and start subresources changed the run strategy of the VM
+		existingVM, err = m.getUnderkubeVM(virtualMachineFromMachine.GetName(), virtualMachineFromMachine.GetNamespace(), machineScope)
+		if err != nil {
+			return false, nil, err
+		}
+	}
 
-	//TODO remove it after pushing that PR: https://github.com/kubevirt/kubevirt/pull/3889
-	virtualMachineFromMachine.Status = kubevirtapiv1.VirtualMachineStatus{
-		Created: existingVM.Status.Created,
-		Ready:   existingVM.Status.Ready,
+	virtualMachineFromMachine, err = applyIgnoredFields(machineScope.machineProviderSpec.IgnoredFields, virtualMachineFromMachine, existingVM)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to apply ignored fields: %w", err)
+	}
+	virtualMachineFromMachine, err = keepDataVolumeAccessModes(virtualMachineFromMachine, existingVM)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to keep the access modes of the data volumes: %w", err)
 	}
 
-	updatedVM, err := m.updateUnderkubeVM(virtualMachineFromMachine, machineScope)
+	upToDate, err := isVirtualMachineUpToDate(virtualMachineFromMachine, existingVM)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to compare VM: %w", err)
+	}
+	if upToDate {
+		klog.Infof("%s: VM is up to date, skipping update", machineScope.getMachineName())
+		return false, existingVM, nil
+	}
+
+	// Merge instead of replacing the live VM, so the changes made on the underkube side to fields
+	// the provider doesn't own survive, and the status and resource version are kept
+	mergedVM, err := mergeVirtualMachine(virtualMachineFromMachine, existingVM)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to merge VM: %w", err)
+	}
+
+	updatedVM, err := m.updateUnderkubeVM(mergedVM, machineScope)
 	if err != nil {
 		return false, nil, fmt.Errorf("failed to update VM: %w", err)
 	}
+	machineScope.recordVMMutation(kubevirtproviderv1.VMUpdatedMutation, vmSpecDiff(existingVM, updatedVM))
 	currentResourceVersion := updatedVM.ResourceVersion
 
 	klog.Infof("Updated machine %s", machineScope.getMachineName())
 
-	wasUpdated := previousResourceVersion != currentResourceVersion
+	wasUpdated := existingVM.ResourceVersion != currentResourceVersion
 	return wasUpdated, updatedVM, nil
 }
 
-func (m *manager) createServiceIfNeeded(err error, updatedVM *kubevirtapiv1.VirtualMachine, machineScope *machineScope, getUpdatedVM *kubevirtapiv1.VirtualMachine, virtualMachineFromMachine *kubevirtapiv1.VirtualMachine) error {
+// expandBootVolumeIfNeeded grows the PVC of the boot volume when the requested storage increased.
+// PVCs can't shrink, so a smaller request only applies to new machines.
+func (m *manager) expandBootVolumeIfNeeded(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	requestedStorage, err := apiresource.ParseQuantity(render.RequestedStorage(machineScope.machineProviderSpec, machineScope.renderDefaults()))
+	if err != nil {
+		return err
+	}
+
+	pvc, err := machineScope.underkubeClient.GetPersistentVolumeClaim(render.BootVolumeName(vm.Name), vm.Namespace, k8smetav1.GetOptions{})
+	if err != nil {
+		if apimachineryerrors.IsNotFound(err) {
+			// The data volume didn't create it yet, it will get the requested size
+			return nil
+		}
+		return err
+	}
+	currentStorage := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if requestedStorage.Cmp(currentStorage) <= 0 {
+		return nil
+	}
+
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		klog.Warningf("%s: can't expand the boot volume to %s, its PVC has no storage class", machineScope.getMachineName(), requestedStorage.String())
+		return nil
+	}
+	storageClass, err := machineScope.underkubeClient.GetStorageClass(*pvc.Spec.StorageClassName, k8smetav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+		klog.Warningf("%s: can't expand the boot volume to %s, storage class %s doesn't allow volume expansion", machineScope.getMachineName(), requestedStorage.String(), storageClass.Name)
+		return nil
+	}
+
+	pvc.Spec.Resources.Requests[corev1.ResourceStorage] = requestedStorage
+	if _, err := machineScope.underkubeClient.UpdatePersistentVolumeClaim(pvc, vm.Namespace); err != nil {
+		return err
+	}
+	klog.Infof("%s: expanding the boot volume from %s to %s", machineScope.getMachineName(), currentStorage.String(), requestedStorage.String())
+	return nil
+}
+
+// syncUserData writes the rendered user data of the machine to a secret next to the VM, and points the
+// cloud-init volume of the VM to it. User data that doesn't need rendering is read from the ignition
+// secret as before.
+func (m *manager) syncUserData(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	userData, err := machineScope.getUserData(vm.Namespace)
+	if err != nil {
+		return err
+	}
+	renderedUserData, rendered, err := renderUserData([]byte(userData), getNodeIPHint(machineScope.machineProviderSpec))
+	if err != nil {
+		return machinecontroller.InvalidMachineConfiguration("%s: invalid cloud-config user data: %v", machineScope.getMachineName(), err)
+	}
+	if !rendered {
+		return nil
+	}
+
+	secretName := buildUserDataSecretName(vm.Name)
+	secret, err := machineScope.underkubeClient.GetSecret(secretName, vm.Namespace, k8smetav1.GetOptions{})
+	switch {
+	case apimachineryerrors.IsNotFound(err):
+		secret = &corev1.Secret{Data: map[string][]byte{renderedUserDataKey: renderedUserData}}
+		secret.Name = secretName
+		secret.Labels = buildVMResourceLabels(vm.Name, machineScope.machine)
+		if _, err := machineScope.underkubeClient.CreateSecret(secret, vm.Namespace); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !bytes.Equal(secret.Data[renderedUserDataKey], renderedUserData):
+		secret.Data = map[string][]byte{renderedUserDataKey: renderedUserData}
+		if _, err := machineScope.underkubeClient.UpdateSecret(secret, vm.Namespace); err != nil {
+			return err
+		}
+	}
+
+	for _, volume := range vm.Spec.Template.Spec.Volumes {
+		if volume.CloudInitConfigDrive != nil {
+			volume.CloudInitConfigDrive.UserDataSecretRef = &corev1.LocalObjectReference{Name: secretName}
+		}
+	}
+	return render.SetLastAppliedConfiguration(vm)
+}
+
+func (m *manager) removeUserDataSecretIfNeeded(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+	err := machineScope.underkubeClient.DeleteSecret(buildUserDataSecretName(vm.Name), vm.Namespace, &k8smetav1.DeleteOptions{})
+	if err != nil && !apimachineryerrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+func (m *manager) createServiceIfNeeded(err error, updatedVM *kubevirtapiv1.VirtualMachine, machineScope *machineScope, getUpdatedVM *kubevirtapiv1.VirtualMachine, virtualMachineFromMachine *kubevirtapiv1.VirtualMachine) (*corev1.Service, error) {
+	if !machineScope.isSharedServiceMode() && machineScope.hasService() {
+		return m.syncMachineService(updatedVM, machineScope, virtualMachineFromMachine)
+	}
+	// The service of the VM created before the service mode changed is removed
+	if err := m.removeMachineServiceIfNeeded(updatedVM, machineScope); err != nil {
+		return nil, fmt.Errorf("failed to delete the service of VM: %w", err)
+	}
+	if machineScope.isSharedServiceMode() {
+		return m.syncSharedService(updatedVM, machineScope)
+	}
+	return nil, nil
+}
+
+// syncMachineService creates the service of the VM when missing, and updates its ports
+func (m *manager) syncMachineService(updatedVM *kubevirtapiv1.VirtualMachine, machineScope *machineScope, virtualMachineFromMachine *kubevirtapiv1.VirtualMachine) (*corev1.Service, error) {
 	serviceWasFound := true
-	_, err = m.getUnderkubeService(updatedVM.GetName(), updatedVM.GetNamespace(), machineScope)
+	service, err := m.getUnderkubeService(updatedVM.GetName(), updatedVM.GetNamespace(), machineScope)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			klog.Infof("%s: service does not exist", machineScope.getMachineName())
 			serviceWasFound = false
 		} else {
-			return fmt.Errorf("%s: error getting service of VM: %v", machineScope.getMachineName(), err)
+			return nil, fmt.Errorf("%s: error getting service of VM: %v", machineScope.getMachineName(), err)
 		}
 
 	}
 	if serviceWasFound {
-		return nil
+		machineScope.machineProviderStatus.MachineServiceCreated = true
+		ports := buildServicePorts(machineScope.machineProviderSpec.Expose)
+		if equality.Semantic.DeepEqual(service.Spec.Ports, ports) {
+			return service, nil
+		}
+		service.Spec.Ports = ports
+		klog.Infof("%s: updating the ports of the service", machineScope.getMachineName())
+		if service, err = machineScope.underkubeClient.UpdateService(service, updatedVM.GetNamespace()); err != nil {
+			return nil, fmt.Errorf("failed to update service: %w", err)
+		}
+		return service, nil
 	}
-	_, err = m.createUnderkubeService(virtualMachineFromMachine.Name, virtualMachineFromMachine.Namespace, machineScope)
+	service, err = m.createUnderkubeService(virtualMachineFromMachine.Name, virtualMachineFromMachine.Namespace, machineScope)
 	if err != nil {
 		klog.Errorf("%s: error updating machine: %v", machineScope.getMachineName(), err)
-		conditionFailed := conditionFailed()
-		conditionFailed.Message = err.Error()
-		return fmt.Errorf("failed to create service: %w", err)
+		machineScope.setCondition(conditions.Failed(kubevirtproviderv1.NetworkReadyCondition, "ServiceCreationFailed", err))
+		return nil, fmt.Errorf("failed to create service: %w", err)
 	}
+	machineScope.machineProviderStatus.MachineServiceCreated = true
 
-	return nil
+	return service, nil
 }
 
-func (m *manager) syncMachine(vm *kubevirtapiv1.VirtualMachine, machineScope *machineScope) error {
+func (m *manager) syncMachine(vm *kubevirtapiv1.VirtualMachine, service *corev1.Service, machineScope *machineScope) error {
 	vmi, err := m.getUnderkubeVMI(vm.Name, vm.Namespace, machineScope)
+	// A failed lookup tells nothing about the VMI, only a missing one lets the import deadline elapse
+	vmiNotFound := apimachineryerrors.IsNotFound(err)
 	if err != nil {
 		klog.Errorf("%s: error getting vmi for machine: %v", machineScope.getMachineName(), err)
+		// The KubeVirt client returns an empty VMI along with the error
+		vmi = nil
 	}
-	if err := machineScope.SyncMachineFromVm(vm, vmi); err != nil {
+	if err := machineScope.SyncMachineFromVm(vm, vmi, service); err != nil {
 		klog.Errorf("%s: fail syncing machine from vm: %v", machineScope.getMachineName(), err)
 		return err
 	}
+	if err := m.syncInfraTopologyLabels(vmi, machineScope); err != nil {
+		klog.Errorf("%s: error mirroring the topology labels of the underkube node: %v", machineScope.getMachineName(), err)
+	}
+	if err := m.annotateNode(vm, vmi, machineScope); err != nil {
+		klog.Errorf("%s: error annotating the node of the machine: %v", machineScope.getMachineName(), err)
+	}
+	if vmi == nil {
+		// KubeVirt only starts the VMI once the boot volume is imported, report the progress meanwhile
+		dataVolume, err := machineScope.underkubeClient.GetDataVolume(render.BootVolumeName(vm.Name), vm.Namespace, k8smetav1.GetOptions{})
+		if err != nil {
+			klog.Errorf("%s: error getting the boot volume of the vm: %v", machineScope.getMachineName(), err)
+		} else {
+			machineScope.setCondition(dataVolumeCondition(dataVolume))
+			machineScope.setBootVolumeCloneStrategy(dataVolume)
+			if reason, message := importDeadlineElapsed(getDeadlines(machineScope.machineProviderSpec), vm, dataVolume, time.Now()); vmiNotFound && reason != "" {
+				machineScope.setFailed(reason, message)
+			}
+		}
+	}
+	if vmi != nil {
+		// The launcher pod explains why a VMI doesn't start, it is only reported in the conditions
+		pod, err := m.getLauncherPod(vmi, machineScope)
+		if err != nil {
+			klog.Errorf("%s: error getting the launcher pod of the vmi: %v", machineScope.getMachineName(), err)
+		} else if pod != nil {
+			for _, condition := range launcherPodConditions(pod) {
+				machineScope.setCondition(condition)
+			}
+		}
+	}
 	return nil
 }
 
 // exists returns true if machine exists.
 func (m *manager) Exists(machine *machinev1.Machine) (bool, error) {
-	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder)
+	machineScope, err := newMachineScope(machine, m.overkubeClient, m.underkubeClientBuilder, m.providerConfig.Get(), m.credentialsFromSecrets)
 	if err != nil {
 		return false, err
 	}
 
 	klog.Infof("%s: check if machine exists", machineScope.getMachineName())
-	existingVM, err := m.getUnderkubeVM(machine.GetName(), getVMNamespace(machine), machineScope)
+	if err := machineScope.validateVMNamespace(); err != nil {
+		return false, err
+	}
+	existingVM, err := m.getUnderkubeVM(machine.GetName(), machineScope.getVMNamespace(), machineScope)
 	if err != nil {
 		// TODO ask Nir how to check it
 		if strings.Contains(err.Error(), "not found") {
 			klog.Infof("%s: VM does not exist", machineScope.getMachineName())
-			return false, nil
+			return m.existsIfDeleting(machineScope)
 		}
 		klog.Errorf("%s: error getting existing VM: %v", machineScope.getMachineName(), err)
 		return false, err
@@ -297,7 +634,7 @@ func (m *manager) Exists(machine *machinev1.Machine) (bool, error) {
 
 	if existingVM == nil {
 		klog.Infof("%s: VM does not exist", machineScope.getMachineName())
-		return false, nil
+		return m.existsIfDeleting(machineScope)
 	}
 
 	return true, nil
@@ -314,9 +651,10 @@ func (m *manager) getUnderkubeVMI(vmName, vmNamespace string, machineScope *mach
 	return machineScope.underkubeClient.GetVirtualMachineInstance(vmNamespace, vmName, &k8smetav1.GetOptions{})
 }
 
-func (m *manager) deleteUnderkubeVM(vmName, vmNamespace string, machineScope *machineScope) error {
-	gracePeriod := int64(10)
-	return machineScope.underkubeClient.DeleteVirtualMachine(vmNamespace, vmName, &k8smetav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+func (m *manager) deleteUnderkubeVM(vmName, vmNamespace string, gracePeriod int64, machineScope *machineScope) error {
+	// In the foreground, so the VM, and so the machine, are kept until the VMI and the data volumes are gone
+	propagationPolicy := k8smetav1.DeletePropagationForeground
+	return machineScope.underkubeClient.DeleteVirtualMachine(vmNamespace, vmName, &k8smetav1.DeleteOptions{GracePeriodSeconds: &gracePeriod, PropagationPolicy: &propagationPolicy})
 }
 
 func (m *manager) updateUnderkubeVM(updatedVM *kubevirtapiv1.VirtualMachine, machineScope *machineScope) (*kubevirtapiv1.VirtualMachine, error) {
@@ -326,10 +664,12 @@ func (m *manager) updateUnderkubeVM(updatedVM *kubevirtapiv1.VirtualMachine, mac
 func (m *manager) createUnderkubeService(vmName, namespace string, machineScope *machineScope) (*corev1.Service, error) {
 	service := &corev1.Service{}
 	service.Name = vmName
+	service.Labels = buildVMResourceLabels(vmName, machineScope.machine)
 	service.Spec = corev1.ServiceSpec{
 		ClusterIP: "None",
 		Selector:  map[string]string{"name": vmName},
 		Type:      corev1.ServiceTypeClusterIP,
+		Ports:     buildServicePorts(machineScope.machineProviderSpec.Expose),
 	}
 
 	return machineScope.underkubeClient.CreateService(service, namespace)
@@ -365,15 +705,3 @@ func (m *manager) isMaster() (bool, error) {
 	// }
 	return false, nil
 }
-
-func (m *manager) requeueIfInstancePending(vm *kubevirtapiv1.VirtualMachine, machineName string) error {
-	// If machine state is still pending, we will return an error to keep the controllers
-	// attempting to update status until it hits a more permanent state. This will ensure
-	// we get a public IP populated more quickly.
-	if !vm.Status.Ready {
-		klog.Infof("%s: VM status is not ready, returning an error to requeue", machineName)
-		return &machinecontroller.RequeueAfterError{RequeueAfter: requeueAfterSeconds * time.Second}
-	}
-
-	return nil
-}
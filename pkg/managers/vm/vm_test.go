@@ -4,16 +4,27 @@ import (
 	"errors"
 	"fmt"
 	"testing"
-
+	"time"
+
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
 
 	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 
 	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
 	mockoverkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube/mock"
 	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
 	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
 	"gotest.tools/assert"
 )
 
@@ -103,15 +114,23 @@ func TestCreate(t *testing.T) {
 				t.Fatalf("Unable to build virtual machine with error: %v", err)
 			}
 
-			virtualMachine := stubVirtualMachine(machineScope)
+			virtualMachine := stubVirtualMachine(t, machineScope)
 			vmi, _ := stubVmi(virtualMachine)
 
-			returnVM := stubVirtualMachine(machineScope)
+			returnVM := stubVirtualMachine(t, machineScope)
 			returnVM.Status.Ready = tc.wantVMToBeReady
 
 			// TODO: test negative flow, return err != nil
 			mockUnderkube.EXPECT().CreateVirtualMachine(clusterID, virtualMachine).Return(returnVM, tc.ClientCreateVMError).AnyTimes()
+			mockUnderkube.EXPECT().ServerResourcesForGroupVersion(gomock.Any()).Return(stubAPIResources(), nil).AnyTimes()
+			mockUnderkube.EXPECT().GetNamespace(clusterID, gomock.Any()).Return(&corev1.Namespace{}, nil).AnyTimes()
+			mockUnderkube.EXPECT().GetKubeVirtVersion().Return("v0.29.2", nil).AnyTimes()
+			mockUnderkube.EXPECT().GetFeatureGates().Return([]string{dataVolumesFeatureGate}, nil).AnyTimes()
+			mockUnderkube.EXPECT().GetMutatingWebhookConfiguration(gomock.Any(), gomock.Any()).Return(&admissionregistrationv1beta1.MutatingWebhookConfiguration{}, nil).AnyTimes()
 			mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, virtualMachine.Name, gomock.Any()).Return(vmi, nil).AnyTimes()
+			mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+			mockUnderkube.EXPECT().AllowedNamespaces().Return(nil).AnyTimes()
+			mockUnderkube.EXPECT().ListPods(clusterID, gomock.Any()).Return(&corev1.PodList{}, nil).AnyTimes()
 
 			if tc.wantCreateServiceErr == "" {
 				mockUnderkube.EXPECT().CreateService(gomock.Any(), virtualMachine.Namespace).Return(stubService(virtualMachine.Name), nil).AnyTimes()
@@ -123,7 +142,7 @@ func TestCreate(t *testing.T) {
 			mockOvernderkube.EXPECT().StatusPatchMachine(machine, machine.DeepCopy()).Return(nil).AnyTimes()
 			mockOvernderkube.EXPECT().GetSecret(workerUserDataSecretName, machine.Namespace).Return(stubSecret(), nil).AnyTimes()
 
-			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube)
+			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube, nil, true)
 			err = providerVMInstance.Create(machine)
 			if tc.wantValidateMachineErr != "" {
 				assert.Equal(t, tc.wantValidateMachineErr, err.Error())
@@ -267,7 +286,7 @@ func TestDelete(t *testing.T) {
 				t.Fatalf("Unable to build virtual machine with error: %v", err)
 			}
 
-			virtualMachine := stubVirtualMachine(machineScope)
+			virtualMachine := stubVirtualMachine(t, machineScope)
 			vmi, _ := stubVmi(virtualMachine)
 
 			var returnVM *kubevirtapiv1.VirtualMachine
@@ -279,6 +298,9 @@ func TestDelete(t *testing.T) {
 			mockUnderkube.EXPECT().GetVirtualMachine(clusterID, virtualMachine.Name, gomock.Any()).Return(returnVM, tc.clientGetVMError).AnyTimes()
 			mockUnderkube.EXPECT().DeleteVirtualMachine(clusterID, virtualMachine.Name, gomock.Any()).Return(tc.clientDeleteVMError).AnyTimes()
 			mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, virtualMachine.Name, gomock.Any()).Return(vmi, nil).AnyTimes()
+			mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+			mockUnderkube.EXPECT().AllowedNamespaces().Return(nil).AnyTimes()
+			mockUnderkube.EXPECT().ListPods(clusterID, gomock.Any()).Return(&corev1.PodList{}, nil).AnyTimes()
 
 			if tc.wantGetServiceErr == "" {
 				mockUnderkube.EXPECT().GetService(virtualMachine.Name, virtualMachine.Namespace, gomock.Any()).Return(stubService(virtualMachine.Name), nil).AnyTimes()
@@ -290,6 +312,10 @@ func TestDelete(t *testing.T) {
 			} else {
 				mockUnderkube.EXPECT().DeleteService(virtualMachine.Name, virtualMachine.Namespace, gomock.Any()).Return(tc.ClientDeleteServiceError).AnyTimes()
 			}
+			mockUnderkube.EXPECT().DeleteSecret(buildUserDataSecretName(virtualMachine.Name), virtualMachine.Namespace, gomock.Any()).Return(nil).AnyTimes()
+			mockUnderkube.EXPECT().DeleteIngress(virtualMachine.Name, virtualMachine.Namespace, gomock.Any()).Return(nil).AnyTimes()
+			mockUnderkube.EXPECT().GetSecret(buildUserDataSecretName(virtualMachine.Name), virtualMachine.Namespace, gomock.Any()).Return(nil, apimachineryerrors.NewNotFound(corev1.Resource("secrets"), virtualMachine.Name)).AnyTimes()
+			mockUnderkube.EXPECT().GetDataVolume(render.BootVolumeName(virtualMachine.Name), virtualMachine.Namespace, gomock.Any()).Return(nil, apimachineryerrors.NewNotFound(corev1.Resource("datavolumes"), virtualMachine.Name)).AnyTimes()
 
 			//overkube mocks
 			// TODO: test negative flow, return err != nil
@@ -297,7 +323,7 @@ func TestDelete(t *testing.T) {
 			mockOvernderkube.EXPECT().StatusPatchMachine(machine, machine.DeepCopy()).Return(nil).AnyTimes()
 			mockOvernderkube.EXPECT().GetSecret(workerUserDataSecretName, machine.Namespace).Return(stubSecret(), nil).AnyTimes()
 
-			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube)
+			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube, nil, true)
 			err = providerVMInstance.Delete(machine)
 
 			// getServicErr
@@ -376,7 +402,7 @@ func TestExists(t *testing.T) {
 				t.Fatalf("Unable to build virtual machine with error: %v", err)
 			}
 
-			virtualMachine := stubVirtualMachine(machineScope)
+			virtualMachine := stubVirtualMachine(t, machineScope)
 			vmi, _ := stubVmi(virtualMachine)
 
 			var returnVM *kubevirtapiv1.VirtualMachine
@@ -387,9 +413,12 @@ func TestExists(t *testing.T) {
 			//underkube mocks
 			mockUnderkube.EXPECT().GetVirtualMachine(clusterID, virtualMachine.Name, gomock.Any()).Return(returnVM, tc.clientGetError).AnyTimes()
 			mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, virtualMachine.Name, gomock.Any()).Return(vmi, nil).AnyTimes()
+			mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+			mockUnderkube.EXPECT().AllowedNamespaces().Return(nil).AnyTimes()
+			mockUnderkube.EXPECT().ListPods(clusterID, gomock.Any()).Return(&corev1.PodList{}, nil).AnyTimes()
 			mockOvernderkube.EXPECT().GetSecret(workerUserDataSecretName, machine.Namespace).Return(stubSecret(), nil).AnyTimes()
 
-			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube)
+			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube, nil, true)
 			existsVM, err := providerVMInstance.Exists(machine)
 
 			if tc.clientGetError != nil {
@@ -419,6 +448,7 @@ func TestUpdate(t *testing.T) {
 		clientCreateServiceError error
 		clientGetServiceError    error
 		emptyGetVM               bool
+		liveVMDiffers            bool
 		labels                   map[string]string
 		providerID               string
 		wantVMToBeReady          bool
@@ -516,10 +546,24 @@ func TestUpdate(t *testing.T) {
 			clientUpdateVMError:    errors.New("client error"),
 			wantUpdateVMErr:        "failed to update VM: client error",
 			emptyGetVM:             false,
+			liveVMDiffers:          true,
 			labels:                 nil,
 			providerID:             "",
 			wantVMToBeReady:        false,
 		},
+		{
+			name:                   "Skip updating an up to date VM",
+			wantValidateMachineErr: "",
+			wantUpdateVMErr:        "",
+			clientGetVMError:       nil,
+			clientUpdateVMError:    errors.New("client error"),
+			emptyGetVM:             false,
+			liveVMDiffers:          false,
+			labels:                 nil,
+			providerID:             fmt.Sprintf("kubevirt:///%s/%s", defaultNamespace, mahcineName),
+			wantVMToBeReady:        true,
+			wantGetServiceErr:      "",
+		},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -542,11 +586,11 @@ func TestUpdate(t *testing.T) {
 				t.Fatalf("Unable to build virtual machine with error: %v", err)
 			}
 
-			virtualMachine := stubVirtualMachine(machineScope)
+			virtualMachine := stubVirtualMachine(t, machineScope)
 			vmi, _ := stubVmi(virtualMachine)
 			var getReturnVM *kubevirtapiv1.VirtualMachine
 			if !tc.emptyGetVM {
-				returnVMResult := stubVirtualMachine(machineScope)
+				returnVMResult := stubVirtualMachine(t, machineScope)
 				getReturnVM = returnVMResult
 				getReturnVM.Status = kubevirtapiv1.VirtualMachineStatus{
 					Created: true,
@@ -555,17 +599,28 @@ func TestUpdate(t *testing.T) {
 				getReturnVM.Status.Created = true
 				getReturnVM.Status.Ready = tc.wantVMToBeReady
 
+				if tc.liveVMDiffers {
+					getReturnVM.Spec.Template.Spec.Domain.Resources.Requests[corev1.ResourceMemory] = apiresource.MustParse("1Gi")
+				}
 			}
 
-			updateReturnVM := stubVirtualMachine(machineScope)
+			updateReturnVM := stubVirtualMachine(t, machineScope)
 			updateReturnVM.Status = kubevirtapiv1.VirtualMachineStatus{
 				Created: true,
 				Ready:   tc.wantVMToBeReady,
 			}
 
 			mockUnderkube.EXPECT().GetVirtualMachine(clusterID, virtualMachine.Name, gomock.Any()).Return(getReturnVM, tc.clientGetVMError).AnyTimes()
-			mockUnderkube.EXPECT().UpdateVirtualMachine(clusterID, getReturnVM).Return(updateReturnVM, tc.clientUpdateVMError).AnyTimes()
+			var updatedVM *kubevirtapiv1.VirtualMachine
+			mockUnderkube.EXPECT().UpdateVirtualMachine(clusterID, gomock.Any()).Do(func(namespace string, vm *kubevirtapiv1.VirtualMachine) {
+				updatedVM = vm
+			}).Return(updateReturnVM, tc.clientUpdateVMError).AnyTimes()
 			mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, virtualMachine.Name, gomock.Any()).Return(vmi, nil).AnyTimes()
+			mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+			mockUnderkube.EXPECT().AllowedNamespaces().Return(nil).AnyTimes()
+			mockUnderkube.EXPECT().ListPods(clusterID, gomock.Any()).Return(&corev1.PodList{}, nil).AnyTimes()
+			mockUnderkube.EXPECT().GetPersistentVolumeClaim(render.BootVolumeName(virtualMachine.Name), virtualMachine.Namespace, gomock.Any()).Return(stubBootVolumePVC(render.DefaultRequestedStorage, ""), nil).AnyTimes()
+			mockUnderkube.EXPECT().GetIngress(virtualMachine.Name, virtualMachine.Namespace, gomock.Any()).Return(nil, apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "ingresses"}, virtualMachine.Name)).AnyTimes()
 
 			if tc.wantGetServiceErr == "" {
 				mockUnderkube.EXPECT().GetService(virtualMachine.Name, virtualMachine.Namespace, gomock.Any()).Return(stubService(virtualMachine.Name), nil).AnyTimes()
@@ -584,9 +639,14 @@ func TestUpdate(t *testing.T) {
 			mockOvernderkube.EXPECT().StatusPatchMachine(machine, machine.DeepCopy()).Return(nil).AnyTimes()
 			mockOvernderkube.EXPECT().GetSecret(workerUserDataSecretName, machine.Namespace).Return(stubSecret(), nil).AnyTimes()
 
-			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube)
+			providerVMInstance := New(kubevirtClientMockBuilder, mockOvernderkube, nil, true)
 			// TODO: test the bool wasUpdated
 			_, err = providerVMInstance.Update(machine)
+			if tc.liveVMDiffers {
+				assert.Equal(t, updatedVM.Spec.Template.Spec.Domain.Resources.Requests.Memory().String(), render.DefaultRequestedMemory)
+			} else {
+				assert.Assert(t, updatedVM == nil)
+			}
 
 			if tc.wantValidateMachineErr != "" {
 				assert.Equal(t, tc.wantValidateMachineErr, err.Error())
@@ -606,12 +666,126 @@ func TestUpdate(t *testing.T) {
 				assert.Equal(t, err, nil)
 				//providerID := fmt.Sprintf("kubevirt:///%s/%s", machineScope.machine.GetNamespace(), machineScope.virtualMachine.GetName())
 				assert.Equal(t, *machine.Spec.ProviderID, tc.providerID)
+				assert.DeepEqual(t, corev1.NodeAddress{Type: corev1.NodeInternalDNS, Address: "machine-test.kubevirt-actuator-cluster.svc.cluster.local"}, machine.Status.Addresses[1])
 			}
 		})
 	}
 
 }
 
+func TestExpandBootVolume(t *testing.T) {
+	cases := []struct {
+		name               string
+		requestedStorage   string
+		pvcStorage         string
+		pvcNotFound        bool
+		allowExpansion     bool
+		wantExpandedVolume bool
+	}{
+		{
+			name:             "Keep a PVC of the requested size",
+			requestedStorage: "35Gi",
+			pvcStorage:       "35Gi",
+			allowExpansion:   true,
+		},
+		{
+			name:             "Never shrink a PVC",
+			requestedStorage: "20Gi",
+			pvcStorage:       "35Gi",
+			allowExpansion:   true,
+		},
+		{
+			name:             "Skip a PVC the data volume didn't create yet",
+			requestedStorage: "50Gi",
+			pvcNotFound:      true,
+			allowExpansion:   true,
+		},
+		{
+			name:             "Skip a storage class that doesn't allow expansion",
+			requestedStorage: "50Gi",
+			pvcStorage:       "35Gi",
+			allowExpansion:   false,
+		},
+		{
+			name:               "Expand a PVC when the requested storage grows",
+			requestedStorage:   "50Gi",
+			pvcStorage:         "35Gi",
+			allowExpansion:     true,
+			wantExpandedVolume: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+			mockUnderkube.EXPECT().DefaultNamespace().Return("").AnyTimes()
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			scope := &machineScope{
+				underkubeClient:     mockUnderkube,
+				machine:             machine,
+				machineProviderSpec: &kubevirtproviderv1.KubevirtMachineProviderSpec{RequestedStorage: tc.requestedStorage},
+			}
+			vm := &kubevirtapiv1.VirtualMachine{}
+			vm.Name = mahcineName
+			vm.Namespace = clusterID
+
+			pvcName := render.BootVolumeName(vm.Name)
+			if tc.pvcNotFound {
+				mockUnderkube.EXPECT().GetPersistentVolumeClaim(pvcName, clusterID, gomock.Any()).Return(nil, apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "persistentvolumeclaims"}, pvcName))
+			} else {
+				mockUnderkube.EXPECT().GetPersistentVolumeClaim(pvcName, clusterID, gomock.Any()).Return(stubBootVolumePVC(tc.pvcStorage, "standard"), nil)
+			}
+			storageClass := &storagev1.StorageClass{AllowVolumeExpansion: &tc.allowExpansion}
+			storageClass.Name = "standard"
+			mockUnderkube.EXPECT().GetStorageClass("standard", gomock.Any()).Return(storageClass, nil).AnyTimes()
+
+			var expandedPVC *corev1.PersistentVolumeClaim
+			mockUnderkube.EXPECT().UpdatePersistentVolumeClaim(gomock.Any(), clusterID).Do(func(pvc *corev1.PersistentVolumeClaim, namespace string) {
+				expandedPVC = pvc
+			}).Return(nil, nil).AnyTimes()
+
+			manager := &manager{}
+			assert.NilError(t, manager.expandBootVolumeIfNeeded(vm, scope))
+			if tc.wantExpandedVolume {
+				assert.Assert(t, expandedPVC != nil)
+				storage := expandedPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+				assert.Equal(t, tc.requestedStorage, storage.String())
+			} else {
+				assert.Assert(t, expandedPVC == nil)
+			}
+		})
+	}
+}
+
+func TestSyncUserData(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+	mockOverkube := mockoverkube.NewMockClient(mockCtrl)
+
+	machine := initializeMachine(t, mockUnderkube, nil, "")
+	kubevirtClientMockBuilder := func(overkubeClient overkube.Client, secretName, namespace string) (underkube.Client, error) {
+		return mockUnderkube, nil
+	}
+	machineScope, err := stubMachineScope(machine, mockOverkube, kubevirtClientMockBuilder)
+	assert.NilError(t, err)
+	virtualMachine := stubVirtualMachine(t, machineScope)
+
+	cloudConfigSecret := &corev1.Secret{Data: map[string][]byte{userDataKey: []byte("#cloud-config\n")}}
+	secretName := buildUserDataSecretName(virtualMachine.Name)
+	mockOverkube.EXPECT().GetSecret(workerUserDataSecretName, machine.Namespace).Return(cloudConfigSecret, nil)
+	mockUnderkube.EXPECT().GetSecret(secretName, clusterID, gomock.Any()).Return(nil, apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, secretName))
+	mockUnderkube.EXPECT().CreateSecret(gomock.Any(), clusterID).Return(nil, nil)
+
+	manager := &manager{}
+	assert.NilError(t, manager.syncUserData(virtualMachine, machineScope))
+	cloudInitVolume := virtualMachine.Spec.Template.Spec.Volumes[1]
+	assert.Equal(t, secretName, cloudInitVolume.CloudInitConfigDrive.UserDataSecretRef.Name)
+}
+
 // func DefaultVirtualMachine(started bool) (*kubevirtapiv1.VirtualMachine, *kubevirtapiv1.VirtualMachineInstance) {
 // 	return DefaultVirtualMachineWithNames(started, "testvmi", "testvmi")
 // }
@@ -648,3 +822,96 @@ func TestUpdate(t *testing.T) {
 // 	}
 // 	return vm
 // }
+
+func TestServiceOfMachineWithoutService(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	// The underkube mock expects no call, the service is neither created, updated nor deleted
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	machineScope := &machineScope{
+		machine:               machine,
+		underkubeClient:       mockUnderkube,
+		machineProviderSpec:   &kubevirtproviderv1.KubevirtMachineProviderSpec{},
+		machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{},
+		providerConfig:        kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultServiceMode: kubevirtproviderv1.NoServiceMode},
+	}
+	vm := &kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName}}
+
+	m := &manager{}
+	service, err := m.createServiceIfNeeded(nil, vm, machineScope, vm, vm)
+	assert.NilError(t, err)
+	assert.Assert(t, service == nil)
+	assert.NilError(t, m.removeServiceIfNeeded(vm, machineScope))
+}
+
+func TestServiceRemovedAfterServiceModeChange(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+	machine, err := stubMachine(nil, "")
+	assert.NilError(t, err)
+	machineScope := &machineScope{
+		machine:               machine,
+		underkubeClient:       mockUnderkube,
+		machineProviderSpec:   &kubevirtproviderv1.KubevirtMachineProviderSpec{ServiceMode: kubevirtproviderv1.NoServiceMode},
+		machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{MachineServiceCreated: true},
+	}
+	vm := &kubevirtapiv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Namespace: clusterID, Name: mahcineName}}
+	mockUnderkube.EXPECT().GetService(mahcineName, clusterID, gomock.Any()).Return(stubService(mahcineName), nil)
+	mockUnderkube.EXPECT().DeleteService(mahcineName, clusterID, gomock.Any()).Return(nil)
+
+	m := &manager{}
+	service, err := m.createServiceIfNeeded(nil, vm, machineScope, vm, vm)
+	assert.NilError(t, err)
+	assert.Assert(t, service == nil)
+	assert.Equal(t, machineScope.machineProviderStatus.MachineServiceCreated, false)
+	// Once removed, the service is no longer looked up
+	assert.NilError(t, m.removeServiceIfNeeded(vm, machineScope))
+}
+
+func TestSyncMachineImportDeadline(t *testing.T) {
+	cases := []struct {
+		name       string
+		vmiErr     error
+		wantFailed bool
+	}{
+		{
+			name:       "VMI not found",
+			vmiErr:     apimachineryerrors.NewNotFound(kubevirtapiv1.Resource("virtualmachineinstance"), mahcineName),
+			wantFailed: true,
+		},
+		{
+			name:   "VMI lookup failed",
+			vmiErr: fmt.Errorf("connection refused"),
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+			mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+
+			machine, err := stubMachine(nil, "")
+			assert.NilError(t, err)
+			machineScope := &machineScope{
+				machine:               machine,
+				underkubeClient:       mockUnderkube,
+				machineProviderSpec:   &kubevirtproviderv1.KubevirtMachineProviderSpec{},
+				machineProviderStatus: &kubevirtproviderv1.KubevirtMachineProviderStatus{},
+			}
+			vm := stubVirtualMachine(t, machineScope)
+			vm.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+			mockUnderkube.EXPECT().GetVirtualMachineInstance(clusterID, mahcineName, gomock.Any()).Return(&kubevirtapiv1.VirtualMachineInstance{}, tc.vmiErr)
+			mockUnderkube.EXPECT().GetDataVolume(render.BootVolumeName(mahcineName), clusterID, gomock.Any()).Return(
+				&cdiv1.DataVolume{Status: cdiv1.DataVolumeStatus{Phase: cdiv1.ImportInProgress}}, nil)
+
+			m := &manager{}
+			assert.NilError(t, m.syncMachine(vm, nil, machineScope))
+			assert.Equal(t, machineScope.isFailed(), tc.wantFailed)
+		})
+	}
+}
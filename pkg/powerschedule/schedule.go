@@ -0,0 +1,155 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package powerschedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// lookback bounds the search of the last time a schedule fired, a weekly schedule always fired within it
+	lookback = 8 * 24 * time.Hour
+)
+
+// schedule is a cron schedule: minute, hour, day of month, month and day of week. Each field is *, or a
+// list of values and ranges, optionally with a /step. The day of week runs from 0 (Sunday) to 7 (Sunday).
+type schedule struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek map[int]bool
+	// anyDayOfMonth and anyDayOfWeek are set when the field is *. As in cron, when both day fields are
+	// restricted, a day matching either of them matches.
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// parseSchedule parses a cron schedule
+func parseSchedule(spec string) (*schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 fields, got %d", spec, len(fields))
+	}
+	bounds := []struct {
+		name     string
+		min, max int
+	}{
+		{"minute", 0, 59},
+		{"hour", 0, 23},
+		{"day of month", 1, 31},
+		{"month", 1, 12},
+		{"day of week", 0, 7},
+	}
+	values := make([]map[int]bool, len(fields))
+	for i, field := range fields {
+		var err error
+		if values[i], err = parseField(field, bounds[i].min, bounds[i].max); err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: invalid %s: %v", spec, bounds[i].name, err)
+		}
+	}
+	// 7 is another name of Sunday
+	if values[4][7] {
+		values[4][0] = true
+	}
+	return &schedule{
+		minutes:       values[0],
+		hours:         values[1],
+		daysOfMonth:   values[2],
+		months:        values[3],
+		daysOfWeek:    values[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+	}, nil
+}
+
+func parseField(field string, min, max int) (map[int]bool, error) {
+	values := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("invalid step %q", part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		first, last := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if first, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+			last = first
+			if len(bounds) == 2 {
+				if last, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			}
+		}
+		if first < min || last > max || first > last {
+			return nil, fmt.Errorf("%q is out of the range %d-%d", part, min, max)
+		}
+		for value := first; value <= last; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// matches returns true when the schedule fires at the minute of t
+func (s *schedule) matches(t time.Time) bool {
+	if !s.minutes[t.Minute()] || !s.hours[t.Hour()] || !s.months[int(t.Month())] {
+		return false
+	}
+	dayOfMonth, dayOfWeek := s.daysOfMonth[t.Day()], s.daysOfWeek[int(t.Weekday())]
+	switch {
+	case s.anyDayOfMonth:
+		return dayOfWeek
+	case s.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+// last returns the last time the schedule fired at or before now, false when it didn't fire within the
+// lookback
+func (s *schedule) last(now time.Time) (time.Time, bool) {
+	t := now.Truncate(time.Minute)
+	for oldest := now.Add(-lookback); !t.Before(oldest); t = t.Add(-time.Minute) {
+		if s.matches(t) {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// desiredPowerState returns the power state of the schedule that fired last, empty when neither fired
+// within the lookback
+func desiredPowerState(stop, start *schedule, now time.Time) string {
+	lastStop, stopped := stop.last(now)
+	lastStart, started := start.last(now)
+	switch {
+	case stopped && (!started || lastStop.After(lastStart)):
+		return powerStateStopped
+	case started:
+		return powerStateRunning
+	default:
+		return ""
+	}
+}
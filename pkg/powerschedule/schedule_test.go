@@ -0,0 +1,94 @@
+package powerschedule
+
+import (
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestParseSchedule(t *testing.T) {
+	cases := []struct {
+		name    string
+		spec    string
+		wantErr string
+	}{
+		{
+			name: "Weekdays",
+			spec: "0 19 * * 1-5",
+		},
+		{
+			name: "Lists and steps",
+			spec: "*/15 8,20 1-15/2 * 7",
+		},
+		{
+			name:    "Missing field",
+			spec:    "0 19 * *",
+			wantErr: `invalid schedule "0 19 * *": expected 5 fields, got 4`,
+		},
+		{
+			name:    "Out of range",
+			spec:    "0 24 * * *",
+			wantErr: `invalid schedule "0 24 * * *": invalid hour: "24" is out of the range 0-23`,
+		},
+		{
+			name:    "Invalid step",
+			spec:    "*/0 * * * *",
+			wantErr: `invalid schedule "*/0 * * * *": invalid minute: invalid step "0"`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseSchedule(tc.spec)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestDesiredPowerState(t *testing.T) {
+	// Stop on weekday evenings, start on weekday mornings: the VMs stay stopped over the weekend
+	stop, err := parseSchedule("0 19 * * 1-5")
+	assert.NilError(t, err)
+	start, err := parseSchedule("30 7 * * 1-5")
+	assert.NilError(t, err)
+
+	cases := []struct {
+		name           string
+		now            time.Time
+		wantPowerState string
+	}{
+		{
+			name:           "Working hours",
+			now:            time.Date(2020, time.June, 3, 12, 0, 0, 0, time.UTC),
+			wantPowerState: powerStateRunning,
+		},
+		{
+			name:           "Start minute",
+			now:            time.Date(2020, time.June, 3, 7, 30, 0, 0, time.UTC),
+			wantPowerState: powerStateRunning,
+		},
+		{
+			name:           "Night",
+			now:            time.Date(2020, time.June, 3, 23, 0, 0, 0, time.UTC),
+			wantPowerState: powerStateStopped,
+		},
+		{
+			name:           "Weekend",
+			now:            time.Date(2020, time.June, 7, 12, 0, 0, 0, time.UTC),
+			wantPowerState: powerStateStopped,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, desiredPowerState(stop, start, tc.now), tc.wantPowerState)
+		})
+	}
+
+	never, err := parseSchedule("0 0 31 2 *")
+	assert.NilError(t, err)
+	assert.Equal(t, desiredPowerState(never, never, time.Date(2020, time.June, 3, 12, 0, 0, 0, time.UTC)), "")
+}
@@ -0,0 +1,129 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package powerschedule stops the VMs of the machinesets off-hours and starts them back on schedule, so
+// the dev and test tenant clusters don't use the underkube capacity overnight. The disks of the stopped
+// VMs are kept.
+package powerschedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// StopScheduleAnnotation and StartScheduleAnnotation hold the cron schedules, in UTC, at which the VMs
+	// of a machineset are stopped and started. Both must be set. While they are, the scheduler owns the
+	// power state of the machines and overrides the one set by hand.
+	StopScheduleAnnotation  = "kubevirt.io/power-stop-schedule"
+	StartScheduleAnnotation = "kubevirt.io/power-start-schedule"
+
+	powerStateStopped = render.PowerStateStopped
+	powerStateRunning = render.PowerStateRunning
+
+	// interval is how often the schedules are evaluated, the cron resolution
+	interval = time.Minute
+)
+
+// Scheduler sets the power state of the machines of the machinesets with a power schedule. The machine
+// controller stops and starts their VMs.
+type Scheduler struct {
+	client client.Client
+}
+
+// New creates a scheduler, to be added to the manager as a runnable
+func New(client client.Client) *Scheduler {
+	return &Scheduler{client: client}
+}
+
+// Start runs the scheduler until the stop channel is closed
+func (s *Scheduler) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := s.scheduleAll(time.Now().UTC()); err != nil {
+			klog.Errorf("failed to apply the power schedules: %v", err)
+		}
+	}, interval, stop)
+	return nil
+}
+
+func (s *Scheduler) scheduleAll(now time.Time) error {
+	machineSets := &machinev1.MachineSetList{}
+	if err := s.client.List(context.Background(), machineSets); err != nil {
+		return err
+	}
+	for i := range machineSets.Items {
+		machineSet := &machineSets.Items[i]
+		stopSchedule, startSchedule := machineSet.Annotations[StopScheduleAnnotation], machineSet.Annotations[StartScheduleAnnotation]
+		if stopSchedule == "" && startSchedule == "" {
+			continue
+		}
+		if err := s.scheduleMachineSet(machineSet, stopSchedule, startSchedule, now); err != nil {
+			klog.Errorf("%s: failed to apply the power schedule: %v", machineSet.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) scheduleMachineSet(machineSet *machinev1.MachineSet, stopSchedule, startSchedule string, now time.Time) error {
+	if stopSchedule == "" || startSchedule == "" {
+		return fmt.Errorf("both %s and %s must be set", StopScheduleAnnotation, StartScheduleAnnotation)
+	}
+	stop, err := parseSchedule(stopSchedule)
+	if err != nil {
+		return err
+	}
+	start, err := parseSchedule(startSchedule)
+	if err != nil {
+		return err
+	}
+	powerState := desiredPowerState(stop, start, now)
+	if powerState == "" {
+		return nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&machineSet.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	machines := &machinev1.MachineList{}
+	if err := s.client.List(context.Background(), machines, client.InNamespace(machineSet.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for i := range machines.Items {
+		machine := &machines.Items[i]
+		if machine.DeletionTimestamp != nil || machine.Annotations[render.PowerStateAnnotation] == powerState {
+			continue
+		}
+		originMachine := machine.DeepCopy()
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[render.PowerStateAnnotation] = powerState
+		klog.Infof("%s: setting the power state of machine %s to %s", machineSet.GetName(), machine.GetName(), powerState)
+		if err := s.client.Patch(context.Background(), machine, client.MergeFrom(originMachine)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
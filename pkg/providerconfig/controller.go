@@ -0,0 +1,272 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package providerconfig watches the cluster wide KubevirtProviderConfig and keeps its spec in a store
+// the other controllers read, so changing the configuration doesn't need a restart.
+package providerconfig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/debug"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	controllerName = "providerconfig-controller"
+	// resourceName is the resource of the KubevirtProviderConfig CRD
+	resourceName = "kubevirtproviderconfigs"
+)
+
+// Store holds the spec of the current KubevirtProviderConfig. A nil store holds the empty spec.
+type Store struct {
+	lock sync.RWMutex
+	spec kubevirtproviderv1.KubevirtProviderConfigSpec
+}
+
+// NewStore creates a store holding the empty spec, until the controller reads the configuration
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Get returns the current spec
+func (s *Store) Get() kubevirtproviderv1.KubevirtProviderConfigSpec {
+	if s == nil {
+		return kubevirtproviderv1.KubevirtProviderConfigSpec{}
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return *s.spec.DeepCopy()
+}
+
+func (s *Store) set(spec kubevirtproviderv1.KubevirtProviderConfigSpec) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.spec = spec
+}
+
+// CredentialsSecretAnnotation names, on a machine, the credentials secret it uses instead of the one of its
+// provider spec. The secret must be one of the CredentialsOverrideSecrets of the configuration.
+const CredentialsSecretAnnotation = "kubevirt.io/underkube-credentials-secret"
+
+// UnderKubeconfigSecretName returns the credentials secret of the machine: the one of its annotation, else
+// the one of its provider spec, else the default one of the configuration. It fails when the annotation
+// names a secret the configuration doesn't allow.
+func UnderKubeconfigSecretName(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, spec kubevirtproviderv1.KubevirtProviderConfigSpec) (string, error) {
+	if override, ok := machine.Annotations[CredentialsSecretAnnotation]; ok {
+		for _, allowed := range spec.CredentialsOverrideSecrets {
+			if override == allowed {
+				return override, nil
+			}
+		}
+		return "", fmt.Errorf("the credentials secret %q of the %s annotation isn't one of the credentialsOverrideSecrets of the KubevirtProviderConfig", override, CredentialsSecretAnnotation)
+	}
+	if providerSpec.UnderKubeconfigSecretName != "" {
+		return providerSpec.UnderKubeconfigSecretName, nil
+	}
+	return spec.DefaultUnderKubeconfigSecretName, nil
+}
+
+type reconciler struct {
+	client client.Client
+	store  *Store
+	// credentialsLimiter is the limiter of the credentials controller, nil when it doesn't run
+	credentialsLimiter *debug.Limiter
+}
+
+// Add creates the provider config controller and adds it to the manager. Without the KubevirtProviderConfig
+// CRD, nothing is watched and the default configuration is used, the manager must be restarted once the CRD
+// is installed.
+func Add(mgr manager.Manager, store *Store, credentialsLimiter *debug.Limiter) error {
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		return fmt.Errorf("failed to create the discovery client: %w", err)
+	}
+	installed, err := crdInstalled(discoveryClient)
+	if err != nil {
+		return fmt.Errorf("failed to discover the KubevirtProviderConfig CRD: %w", err)
+	}
+	if !installed {
+		klog.Warningf("The KubevirtProviderConfig CRD is not installed, using the default configuration. Install config/crds and restart the manager to configure the provider.")
+		return nil
+	}
+
+	r := &reconciler{
+		client:             mgr.GetClient(),
+		store:              store,
+		credentialsLimiter: credentialsLimiter,
+	}
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &kubevirtproviderv1.KubevirtProviderConfig{}}, &handler.EnqueueRequestForObject{})
+}
+
+// crdInstalled returns true when the API server serves the KubevirtProviderConfig resource
+func crdInstalled(client discovery.ServerResourcesInterface) (bool, error) {
+	resources, err := client.ServerResourcesForGroupVersion(kubevirtproviderv1.SchemeGroupVersion.String())
+	if apimachineryerrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, resource := range resources.APIResources {
+		if resource.Name == resourceName {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Reconcile stores the spec of the configuration. An invalid spec is reported and the previous one is
+// kept, a deleted configuration resets the defaults.
+func (r *reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	if request.Name != kubevirtproviderv1.ProviderConfigName {
+		klog.Warningf("ignoring KubevirtProviderConfig %s, only the one named %s is used", request.Name, kubevirtproviderv1.ProviderConfigName)
+		return reconcile.Result{}, nil
+	}
+
+	config := &kubevirtproviderv1.KubevirtProviderConfig{}
+	if err := r.client.Get(context.Background(), request.NamespacedName, config); err != nil {
+		if !apimachineryerrors.IsNotFound(err) {
+			return reconcile.Result{}, err
+		}
+		klog.Infof("KubevirtProviderConfig %s not found, using the default configuration", request.Name)
+	}
+	if err := validateSpec(&config.Spec); err != nil {
+		klog.Errorf("KubevirtProviderConfig %s is invalid, keeping the previous configuration: %v", request.Name, err)
+		return reconcile.Result{}, nil
+	}
+
+	r.store.set(config.Spec)
+	underkube.SetFaultInjection(config.Spec.FaultInjection)
+	if r.credentialsLimiter != nil {
+		concurrency := 1
+		if config.Spec.Reconcile != nil && config.Spec.Reconcile.CredentialsConcurrency > 0 {
+			concurrency = config.Spec.Reconcile.CredentialsConcurrency
+		}
+		r.credentialsLimiter.SetLimit(concurrency)
+	}
+	klog.Infof("KubevirtProviderConfig %s applied", request.Name)
+	return reconcile.Result{}, nil
+}
+
+func validateSpec(spec *kubevirtproviderv1.KubevirtProviderConfigSpec) error {
+	quantities := []struct{ field, value string }{
+		{"defaultRequestedMemory", spec.DefaultRequestedMemory},
+		{"defaultRequestedStorage", spec.DefaultRequestedStorage},
+	}
+	for _, quantity := range quantities {
+		if quantity.value == "" {
+			continue
+		}
+		if _, err := apiresource.ParseQuantity(quantity.value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %v", quantity.value, quantity.field, err)
+		}
+	}
+	switch spec.DefaultServiceMode {
+	case "", kubevirtproviderv1.PerMachineServiceMode, kubevirtproviderv1.MachineSetServiceMode, kubevirtproviderv1.NoServiceMode:
+	default:
+		return fmt.Errorf("invalid value %q for defaultServiceMode", spec.DefaultServiceMode)
+	}
+	if spec.ServiceDomain != "" {
+		if errs := validation.IsDNS1123Subdomain(spec.ServiceDomain); len(errs) > 0 {
+			return fmt.Errorf("invalid value %q for serviceDomain: %s", spec.ServiceDomain, strings.Join(errs, ", "))
+		}
+	}
+	if spec.Reconcile != nil && spec.Reconcile.CredentialsConcurrency < 0 {
+		return fmt.Errorf("reconcile.credentialsConcurrency can't be negative")
+	}
+	if spec.Reconcile != nil && spec.Reconcile.MaxConcurrentDeletions < 0 {
+		return fmt.Errorf("reconcile.maxConcurrentDeletions can't be negative")
+	}
+
+	clusterIDs := map[string]bool{}
+	for i, budget := range spec.Budgets {
+		if budget.ClusterID == "" {
+			return fmt.Errorf("budgets[%d]: missing clusterID", i)
+		}
+		if clusterIDs[budget.ClusterID] {
+			return fmt.Errorf("budgets[%d]: duplicate budget for cluster %s", i, budget.ClusterID)
+		}
+		clusterIDs[budget.ClusterID] = true
+		quantities = []struct{ field, value string }{
+			{"maxCPU", budget.MaxCPU},
+			{"maxMemory", budget.MaxMemory},
+			{"maxStorage", budget.MaxStorage},
+		}
+		for _, quantity := range quantities {
+			if quantity.value == "" {
+				continue
+			}
+			if _, err := apiresource.ParseQuantity(quantity.value); err != nil {
+				return fmt.Errorf("budgets[%d]: invalid value %q for %s: %v", i, quantity.value, quantity.field, err)
+			}
+		}
+	}
+	if spec.FaultInjection != nil {
+		if err := validateFaultInjection(spec.FaultInjection); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// faultInjectionVerbs are the HTTP methods of the underkube requests
+var faultInjectionVerbs = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+func validateFaultInjection(faultInjection *kubevirtproviderv1.FaultInjection) error {
+	for i, rule := range faultInjection.Rules {
+		for _, verb := range rule.Verbs {
+			known := false
+			for _, knownVerb := range faultInjectionVerbs {
+				known = known || strings.EqualFold(verb, knownVerb)
+			}
+			if !known {
+				return fmt.Errorf("faultInjection.rules[%d]: unknown verb %q, expected one of %s", i, verb, strings.Join(faultInjectionVerbs, ", "))
+			}
+		}
+		if rule.ErrorPercent < 0 || rule.ErrorPercent > 100 {
+			return fmt.Errorf("faultInjection.rules[%d]: errorPercent must be between 0 and 100", i)
+		}
+		if rule.PartialFailurePercent < 0 || rule.PartialFailurePercent > 100 {
+			return fmt.Errorf("faultInjection.rules[%d]: partialFailurePercent must be between 0 and 100", i)
+		}
+		if rule.Latency.Duration < 0 {
+			return fmt.Errorf("faultInjection.rules[%d]: latency can't be negative", i)
+		}
+	}
+	return nil
+}
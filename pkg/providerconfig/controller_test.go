@@ -0,0 +1,231 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package providerconfig
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+func TestValidateSpec(t *testing.T) {
+	cases := []struct {
+		name    string
+		spec    kubevirtproviderv1.KubevirtProviderConfigSpec
+		wantErr string
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name: "Valid",
+			spec: kubevirtproviderv1.KubevirtProviderConfigSpec{
+				DefaultUnderKubeconfigSecretName: "kubeconfig",
+				DefaultStorageClassName:          "fast",
+				DefaultRequestedMemory:           "4Gi",
+				DefaultRequestedStorage:          "50Gi",
+				DefaultServiceMode:               kubevirtproviderv1.NoServiceMode,
+				ServiceDomain:                    "underkube.example.com",
+				Reconcile:                        &kubevirtproviderv1.ReconcileTuning{CredentialsConcurrency: 4},
+			},
+		},
+		{
+			name:    "Invalid memory",
+			spec:    kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultRequestedMemory: "lots"},
+			wantErr: `invalid value "lots" for defaultRequestedMemory: quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`,
+		},
+		{
+			name:    "Invalid service domain",
+			spec:    kubevirtproviderv1.KubevirtProviderConfigSpec{ServiceDomain: "cluster.local."},
+			wantErr: `invalid value "cluster.local." for serviceDomain: a DNS-1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+		{
+			name:    "Invalid service mode",
+			spec:    kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultServiceMode: "Shared"},
+			wantErr: `invalid value "Shared" for defaultServiceMode`,
+		},
+		{
+			name:    "Negative concurrency",
+			spec:    kubevirtproviderv1.KubevirtProviderConfigSpec{Reconcile: &kubevirtproviderv1.ReconcileTuning{CredentialsConcurrency: -1}},
+			wantErr: "reconcile.credentialsConcurrency can't be negative",
+		},
+		{
+			name:    "Negative deletions",
+			spec:    kubevirtproviderv1.KubevirtProviderConfigSpec{Reconcile: &kubevirtproviderv1.ReconcileTuning{MaxConcurrentDeletions: -1}},
+			wantErr: "reconcile.maxConcurrentDeletions can't be negative",
+		},
+		{
+			name: "Valid budgets",
+			spec: kubevirtproviderv1.KubevirtProviderConfigSpec{Budgets: []kubevirtproviderv1.ClusterBudget{
+				{ClusterID: "tenant-1", MaxCPU: "64", MaxMemory: "256Gi", MaxStorage: "2Ti"},
+				{ClusterID: "tenant-2", MaxCPU: "16"},
+			}},
+		},
+		{
+			name:    "Budget without cluster",
+			spec:    kubevirtproviderv1.KubevirtProviderConfigSpec{Budgets: []kubevirtproviderv1.ClusterBudget{{MaxCPU: "16"}}},
+			wantErr: "budgets[0]: missing clusterID",
+		},
+		{
+			name: "Duplicate budget",
+			spec: kubevirtproviderv1.KubevirtProviderConfigSpec{Budgets: []kubevirtproviderv1.ClusterBudget{
+				{ClusterID: "tenant-1", MaxCPU: "64"},
+				{ClusterID: "tenant-1", MaxCPU: "16"},
+			}},
+			wantErr: "budgets[1]: duplicate budget for cluster tenant-1",
+		},
+		{
+			name: "Valid fault injection",
+			spec: kubevirtproviderv1.KubevirtProviderConfigSpec{FaultInjection: &kubevirtproviderv1.FaultInjection{Rules: []kubevirtproviderv1.FaultInjectionRule{
+				{Verbs: []string{"post", "DELETE"}, ErrorPercent: 10, PartialFailurePercent: 5},
+				{Latency: metav1.Duration{Duration: 200 * time.Millisecond}},
+			}}},
+		},
+		{
+			name: "Fault injection on an unknown verb",
+			spec: kubevirtproviderv1.KubevirtProviderConfigSpec{FaultInjection: &kubevirtproviderv1.FaultInjection{Rules: []kubevirtproviderv1.FaultInjectionRule{
+				{Verbs: []string{"WATCH"}, ErrorPercent: 10},
+			}}},
+			wantErr: `faultInjection.rules[0]: unknown verb "WATCH", expected one of GET, POST, PUT, PATCH, DELETE`,
+		},
+		{
+			name: "Fault injection above 100 percent",
+			spec: kubevirtproviderv1.KubevirtProviderConfigSpec{FaultInjection: &kubevirtproviderv1.FaultInjection{Rules: []kubevirtproviderv1.FaultInjectionRule{
+				{ErrorPercent: 10},
+				{PartialFailurePercent: 150},
+			}}},
+			wantErr: "faultInjection.rules[1]: partialFailurePercent must be between 0 and 100",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateSpec(&tc.spec)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	var nilStore *Store
+	assert.DeepEqual(t, nilStore.Get(), kubevirtproviderv1.KubevirtProviderConfigSpec{})
+
+	store := NewStore()
+	store.set(kubevirtproviderv1.KubevirtProviderConfigSpec{Reconcile: &kubevirtproviderv1.ReconcileTuning{CredentialsConcurrency: 2}})
+	spec := store.Get()
+	spec.Reconcile.CredentialsConcurrency = 5
+	assert.Equal(t, store.Get().Reconcile.CredentialsConcurrency, 2)
+}
+
+func TestUnderKubeconfigSecretName(t *testing.T) {
+	spec := kubevirtproviderv1.KubevirtProviderConfigSpec{
+		DefaultUnderKubeconfigSecretName: "default-kubeconfig",
+		CredentialsOverrideSecrets:       []string{"new-infra-kubeconfig"},
+	}
+	cases := []struct {
+		name         string
+		annotation   string
+		specSecret   string
+		wantSecret   string
+		wantErr      string
+		noAnnotation bool
+	}{
+		{
+			name:         "Default secret",
+			noAnnotation: true,
+			wantSecret:   "default-kubeconfig",
+		},
+		{
+			name:         "Provider spec secret",
+			noAnnotation: true,
+			specSecret:   "kubeconfig",
+			wantSecret:   "kubeconfig",
+		},
+		{
+			name:       "Allowed override",
+			annotation: "new-infra-kubeconfig",
+			specSecret: "kubeconfig",
+			wantSecret: "new-infra-kubeconfig",
+		},
+		{
+			name:       "Override not allowed",
+			annotation: "other-kubeconfig",
+			wantErr:    `the credentials secret "other-kubeconfig" of the kubevirt.io/underkube-credentials-secret annotation isn't one of the credentialsOverrideSecrets of the KubevirtProviderConfig`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine := &machinev1.Machine{}
+			if !tc.noAnnotation {
+				machine.Annotations = map[string]string{CredentialsSecretAnnotation: tc.annotation}
+			}
+			secretName, err := UnderKubeconfigSecretName(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{UnderKubeconfigSecretName: tc.specSecret}, spec)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+				assert.Equal(t, secretName, tc.wantSecret)
+			}
+		})
+	}
+}
+
+// servedResources is a discovery client serving its resources in the KubevirtProviderConfig group version
+type servedResources struct {
+	discovery.ServerResourcesInterface
+	resources []string
+	err       error
+}
+
+func (s servedResources) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	list := &metav1.APIResourceList{GroupVersion: groupVersion}
+	for _, resource := range s.resources {
+		list.APIResources = append(list.APIResources, metav1.APIResource{Name: resource})
+	}
+	return list, nil
+}
+
+func TestCRDInstalled(t *testing.T) {
+	installed, err := crdInstalled(servedResources{resources: []string{"kubevirtproviderconfigs"}})
+	assert.NilError(t, err)
+	assert.Assert(t, installed)
+
+	installed, err = crdInstalled(servedResources{err: apimachineryerrors.NewNotFound(schema.GroupResource{}, "")})
+	assert.NilError(t, err)
+	assert.Assert(t, !installed)
+
+	installed, err = crdInstalled(servedResources{})
+	assert.NilError(t, err)
+	assert.Assert(t, !installed)
+
+	_, err = crdInstalled(servedResources{err: errors.New("connection refused")})
+	assert.Error(t, err, "connection refused")
+}
@@ -0,0 +1,276 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package recommender compares the resources used by the nodes of each machineset with the resources
+// their VMs request, and writes the size it recommends on the machineset annotations when it differs
+// from the request.
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RecommendedCPUAnnotation and RecommendedMemoryAnnotation hold the size recommended for the VMs of a machineset
+	RecommendedCPUAnnotation    = "kubevirt.io/recommended-cpu"
+	RecommendedMemoryAnnotation = "kubevirt.io/recommended-memory"
+
+	// headroomPercent is added on top of the peak usage, so the nodes aren't sized right at their limit
+	headroomPercent = 20
+	memoryUnit      = 64 * 1024 * 1024
+	// peakWindow is how long the usage samples count towards the peak, so a daily peak isn't missed
+	// between two samples of the same day
+	peakWindow = 24 * time.Hour
+	// minDeltaPercent is how far the recommendation has to be from the request to be worth writing
+	minDeltaPercent = 10
+)
+
+var nodeMetricsGVK = metav1.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "NodeMetrics"}
+
+// Recommender periodically recommends a size for the VMs of every machineset, from the peak usage of
+// their nodes over the last day. It only writes annotations, resizing the machines is left to the
+// operator.
+type Recommender struct {
+	client   client.Client
+	interval time.Duration
+	// samples are the node usages sampled for each machineset, by namespace/name, over the peak window
+	samples map[string][]usageSample
+}
+
+// usageSample is the usage of the nodes of a machineset at a time
+type usageSample struct {
+	time   time.Time
+	usages []corev1.ResourceList
+}
+
+// New creates a recommender, to be added to the manager as a runnable
+func New(client client.Client, interval time.Duration) *Recommender {
+	return &Recommender{
+		client:   client,
+		interval: interval,
+		samples:  map[string][]usageSample{},
+	}
+}
+
+// Start runs the recommender until the stop channel is closed
+func (r *Recommender) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := r.recommendAll(time.Now()); err != nil {
+			klog.Errorf("failed to recommend machineset sizes: %v", err)
+		}
+	}, r.interval, stop)
+	return nil
+}
+
+func (r *Recommender) recommendAll(now time.Time) error {
+	machineSets := &machinev1.MachineSetList{}
+	if err := r.client.List(context.Background(), machineSets); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	for i := range machineSets.Items {
+		seen[machineSetKey(&machineSets.Items[i])] = true
+		if err := r.recommendMachineSet(&machineSets.Items[i], now); err != nil {
+			klog.Errorf("%s: failed to recommend a size: %v", machineSets.Items[i].GetName(), err)
+		}
+	}
+	for key := range r.samples {
+		if !seen[key] {
+			delete(r.samples, key)
+		}
+	}
+	return nil
+}
+
+func machineSetKey(machineSet *machinev1.MachineSet) string {
+	return machineSet.Namespace + "/" + machineSet.Name
+}
+
+func (r *Recommender) recommendMachineSet(machineSet *machinev1.MachineSet, now time.Time) error {
+	selector, err := metav1.LabelSelectorAsSelector(&machineSet.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	machines := &machinev1.MachineList{}
+	if err := r.client.List(context.Background(), machines, client.InNamespace(machineSet.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+
+	var usages []corev1.ResourceList
+	for _, machine := range machines.Items {
+		if machine.Status.NodeRef == nil {
+			continue
+		}
+		usage, err := r.getNodeUsage(machine.Status.NodeRef.Name)
+		if err != nil {
+			if apimachineryerrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		usages = append(usages, usage)
+	}
+	windowUsages := r.addSample(machineSetKey(machineSet), usages, now)
+	if len(windowUsages) == 0 {
+		klog.V(3).Infof("%s: no node metrics yet, skipping recommendation", machineSet.GetName())
+		return nil
+	}
+
+	providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(machineSet.Spec.Template.Spec.ProviderSpec.Value)
+	if err != nil {
+		return fmt.Errorf("failed to get the provider spec: %w", err)
+	}
+
+	recommendation := recommend(windowUsages)
+	originMachineSet := machineSet.DeepCopy()
+	changed := false
+	for _, resource := range []struct {
+		annotation  string
+		recommended apiresource.Quantity
+		requested   string
+	}{
+		{RecommendedCPUAnnotation, *recommendation.Cpu(), providerSpec.RequestedCPU},
+		{RecommendedMemoryAnnotation, *recommendation.Memory(), providerSpec.RequestedMemory},
+	} {
+		value := resource.recommended.String()
+		delta, differs := requestDelta(resource.recommended, resource.requested)
+		if !differs {
+			if _, ok := machineSet.Annotations[resource.annotation]; ok {
+				klog.Infof("%s: the request of %s is within %d%% of the recommended %s, dropping %s", machineSet.GetName(),
+					resource.requested, minDeltaPercent, value, resource.annotation)
+				delete(machineSet.Annotations, resource.annotation)
+				changed = true
+			}
+			continue
+		}
+		if machineSet.Annotations[resource.annotation] == value {
+			continue
+		}
+		klog.Infof("%s: recommending %s %s, %s from the request of %q", machineSet.GetName(), resource.annotation, value, delta, resource.requested)
+		if machineSet.Annotations == nil {
+			machineSet.Annotations = map[string]string{}
+		}
+		machineSet.Annotations[resource.annotation] = value
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return r.client.Patch(context.Background(), machineSet, client.MergeFrom(originMachineSet))
+}
+
+// addSample records the usages of the nodes of a machineset, drops the samples older than the peak
+// window, and returns the usages sampled within the window
+func (r *Recommender) addSample(key string, usages []corev1.ResourceList, now time.Time) []corev1.ResourceList {
+	var samples []usageSample
+	for _, sample := range r.samples[key] {
+		if now.Sub(sample.time) < peakWindow {
+			samples = append(samples, sample)
+		}
+	}
+	if len(usages) > 0 {
+		samples = append(samples, usageSample{time: now, usages: usages})
+	}
+	r.samples[key] = samples
+
+	var windowUsages []corev1.ResourceList
+	for _, sample := range samples {
+		windowUsages = append(windowUsages, sample.usages...)
+	}
+	return windowUsages
+}
+
+// requestDelta returns the difference between the recommended and the requested quantities, as a
+// percentage of the request, and whether it is at least the minimum delta. A request unset or invalid
+// always differs.
+func requestDelta(recommended apiresource.Quantity, requested string) (string, bool) {
+	request, err := apiresource.ParseQuantity(requested)
+	if err != nil || request.IsZero() {
+		return "no valid request", true
+	}
+	delta := (recommended.MilliValue() - request.MilliValue()) * 100 / request.MilliValue()
+	if delta > -minDeltaPercent && delta < minDeltaPercent {
+		return fmt.Sprintf("%+d%%", delta), false
+	}
+	return fmt.Sprintf("%+d%%", delta), true
+}
+
+// getNodeUsage reads the usage of a node from the metrics API
+func (r *Recommender) getNodeUsage(nodeName string) (corev1.ResourceList, error) {
+	nodeMetrics := &unstructured.Unstructured{}
+	nodeMetrics.SetAPIVersion(nodeMetricsGVK.Group + "/" + nodeMetricsGVK.Version)
+	nodeMetrics.SetKind(nodeMetricsGVK.Kind)
+	if err := r.client.Get(context.Background(), client.ObjectKey{Name: nodeName}, nodeMetrics); err != nil {
+		return nil, err
+	}
+
+	usage := corev1.ResourceList{}
+	for _, resourceName := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		value, found, err := unstructured.NestedString(nodeMetrics.Object, "usage", string(resourceName))
+		if err != nil || !found {
+			continue
+		}
+		quantity, err := apiresource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s usage %q for node %s: %w", resourceName, value, nodeName, err)
+		}
+		usage[resourceName] = quantity
+	}
+	return usage, nil
+}
+
+// recommend returns the peak usage of the nodes plus some headroom, rounded up to whole cores and to
+// a multiple of 64Mi, so the recommendation doesn't change on every small usage variation.
+func recommend(usages []corev1.ResourceList) corev1.ResourceList {
+	var peakMilliCPU, peakMemory int64
+	for _, usage := range usages {
+		if cpu, ok := usage[corev1.ResourceCPU]; ok && cpu.MilliValue() > peakMilliCPU {
+			peakMilliCPU = cpu.MilliValue()
+		}
+		if memory, ok := usage[corev1.ResourceMemory]; ok && memory.Value() > peakMemory {
+			peakMemory = memory.Value()
+		}
+	}
+
+	milliCPU := peakMilliCPU * (100 + headroomPercent) / 100
+	cores := (milliCPU + 999) / 1000
+	if cores < 1 {
+		cores = 1
+	}
+	memory := peakMemory * (100 + headroomPercent) / 100
+	memory = (memory + memoryUnit - 1) / memoryUnit * memoryUnit
+	if memory < memoryUnit {
+		memory = memoryUnit
+	}
+
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    *apiresource.NewQuantity(cores, apiresource.DecimalSI),
+		corev1.ResourceMemory: *apiresource.NewQuantity(memory, apiresource.BinarySI),
+	}
+}
@@ -0,0 +1,187 @@
+package recommender
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// metricsClient is a client listing the given machines, serving the usage of their nodes from the
+// metrics API, and recording the patched machineset
+type metricsClient struct {
+	client.Client
+	machines []machinev1.Machine
+	usages   map[string]corev1.ResourceList
+	patched  *machinev1.MachineSet
+}
+
+func (c *metricsClient) List(_ context.Context, list runtime.Object, _ ...client.ListOption) error {
+	list.(*machinev1.MachineList).Items = c.machines
+	return nil
+}
+
+func (c *metricsClient) Get(_ context.Context, key client.ObjectKey, obj runtime.Object) error {
+	usage, ok := c.usages[key.Name]
+	if !ok {
+		return apimachineryerrors.NewNotFound(schema.GroupResource{Group: "metrics.k8s.io", Resource: "nodes"}, key.Name)
+	}
+	nodeMetrics := obj.(*unstructured.Unstructured)
+	for resourceName, quantity := range usage {
+		if err := unstructured.SetNestedField(nodeMetrics.Object, quantity.String(), "usage", string(resourceName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *metricsClient) Patch(_ context.Context, obj runtime.Object, _ client.Patch, _ ...client.PatchOption) error {
+	c.patched = obj.(*machinev1.MachineSet).DeepCopy()
+	return nil
+}
+
+func TestRecommend(t *testing.T) {
+	cases := []struct {
+		name       string
+		usages     []corev1.ResourceList
+		wantCPU    string
+		wantMemory string
+	}{
+		{
+			name: "Size for the busiest node plus headroom",
+			usages: []corev1.ResourceList{
+				{corev1.ResourceCPU: apiresource.MustParse("1500m"), corev1.ResourceMemory: apiresource.MustParse("3Gi")},
+				{corev1.ResourceCPU: apiresource.MustParse("2500m"), corev1.ResourceMemory: apiresource.MustParse("1Gi")},
+			},
+			wantCPU:    "3",
+			wantMemory: "3712Mi",
+		},
+		{
+			name: "Never recommend less than the minimum",
+			usages: []corev1.ResourceList{
+				{corev1.ResourceCPU: apiresource.MustParse("10m"), corev1.ResourceMemory: apiresource.MustParse("1Mi")},
+			},
+			wantCPU:    "1",
+			wantMemory: "64Mi",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			recommendation := recommend(tc.usages)
+			assert.Equal(t, tc.wantCPU, recommendation.Cpu().String())
+			assert.Equal(t, tc.wantMemory, recommendation.Memory().String())
+		})
+	}
+}
+
+func TestRecommendMachineSet(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	peakUsage := corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("2500m"), corev1.ResourceMemory: apiresource.MustParse("3Gi")}
+	lowUsage := corev1.ResourceList{corev1.ResourceCPU: apiresource.MustParse("100m"), corev1.ResourceMemory: apiresource.MustParse("100Mi")}
+	recommended := map[string]string{RecommendedCPUAnnotation: "3", RecommendedMemoryAnnotation: "3712Mi"}
+
+	cases := []struct {
+		name            string
+		requestedCPU    string
+		requestedMemory string
+		annotations     map[string]string
+		samples         []usageSample
+		usages          map[string]corev1.ResourceList
+		wantPatch       bool
+		wantAnnotations map[string]string
+	}{
+		{
+			name:            "Recommend off the request",
+			requestedCPU:    "1",
+			requestedMemory: "1Gi",
+			usages:          map[string]corev1.ResourceList{"node-a": peakUsage, "node-b": lowUsage},
+			wantPatch:       true,
+			wantAnnotations: recommended,
+		},
+		{
+			name:            "Recommend without request",
+			usages:          map[string]corev1.ResourceList{"node-a": peakUsage},
+			wantPatch:       true,
+			wantAnnotations: recommended,
+		},
+		{
+			name:            "Keep the peak of the window",
+			requestedCPU:    "1",
+			requestedMemory: "1Gi",
+			samples:         []usageSample{{time: now.Add(-time.Hour), usages: []corev1.ResourceList{peakUsage}}},
+			usages:          map[string]corev1.ResourceList{"node-a": lowUsage},
+			wantPatch:       true,
+			wantAnnotations: recommended,
+		},
+		{
+			name:            "Drop the peak out of the window",
+			requestedCPU:    "4",
+			requestedMemory: "4Gi",
+			annotations:     recommended,
+			samples:         []usageSample{{time: now.Add(-peakWindow), usages: []corev1.ResourceList{peakUsage}}},
+			usages:          map[string]corev1.ResourceList{"node-a": lowUsage},
+			wantPatch:       true,
+			wantAnnotations: map[string]string{RecommendedCPUAnnotation: "1", RecommendedMemoryAnnotation: "128Mi"},
+		},
+		{
+			name:            "Request close to the recommendation",
+			requestedCPU:    "3",
+			requestedMemory: "3584Mi",
+			annotations:     map[string]string{RecommendedCPUAnnotation: "4", RecommendedMemoryAnnotation: "8Gi"},
+			usages:          map[string]corev1.ResourceList{"node-a": peakUsage},
+			wantPatch:       true,
+			wantAnnotations: map[string]string{},
+		},
+		{
+			name:            "Recommendation unchanged",
+			requestedCPU:    "1",
+			requestedMemory: "1Gi",
+			annotations:     recommended,
+			usages:          map[string]corev1.ResourceList{"node-a": peakUsage},
+			wantAnnotations: recommended,
+		},
+		{
+			name:         "No node metrics",
+			requestedCPU: "1",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			providerSpec, err := kubevirtproviderv1.RawExtensionFromProviderSpec(&kubevirtproviderv1.KubevirtMachineProviderSpec{
+				RequestedCPU:    tc.requestedCPU,
+				RequestedMemory: tc.requestedMemory,
+			})
+			assert.NilError(t, err)
+			machineSet := &machinev1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "default", Annotations: map[string]string{}}}
+			for key, value := range tc.annotations {
+				machineSet.Annotations[key] = value
+			}
+			machineSet.Spec.Template.Spec.ProviderSpec.Value = providerSpec
+
+			var machines []machinev1.Machine
+			for _, nodeName := range []string{"node-a", "node-b"} {
+				machines = append(machines, machinev1.Machine{Status: machinev1.MachineStatus{NodeRef: &corev1.ObjectReference{Name: nodeName}}})
+			}
+			fakeClient := &metricsClient{machines: machines, usages: tc.usages}
+			recommender := New(fakeClient, time.Minute)
+			recommender.samples[machineSetKey(machineSet)] = tc.samples
+
+			assert.NilError(t, recommender.recommendMachineSet(machineSet, now))
+			assert.Equal(t, fakeClient.patched != nil, tc.wantPatch)
+			if tc.wantAnnotations != nil {
+				assert.DeepEqual(t, machineSet.Annotations, tc.wantAnnotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+)
+
+// SeccompPodAnnotation sets the seccomp profile of a pod, KubeVirt copies it from the VMI to its
+// virt-launcher pod. It is the legacy annotation of the PodSecurityPolicy era, Kubernetes 1.25 and
+// later ignore it.
+const SeccompPodAnnotation = "seccomp.security.alpha.kubernetes.io/pod"
+
+// annotationProfiles are the KubeVirt annotations of the VMI each profile expands into. The KubeVirt
+// releases that don't know an annotation ignore it.
+var annotationProfiles = map[kubevirtproviderv1.AnnotationProfile]map[string]string{
+	kubevirtproviderv1.MigratableBridgeProfile: {
+		"kubevirt.io/allow-pod-bridge-network-live-migration": "",
+	},
+	kubevirtproviderv1.DeschedulerEvictableProfile: {
+		"descheduler.alpha.kubernetes.io/evict": "true",
+	},
+	kubevirtproviderv1.KeepLauncherOnFailureProfile: {
+		"kubevirt.io/keep-launcher-alive-after-failure": "true",
+	},
+}
+
+// IsAnnotationProfile returns true when the profile is a known one
+func IsAnnotationProfile(profile kubevirtproviderv1.AnnotationProfile) bool {
+	_, ok := annotationProfiles[profile]
+	return ok
+}
+
+// buildVMIAnnotations returns the annotations of the VMI: the propagated annotations of the machine, the ones
+// of its profiles, and the seccomp profile of its virt-launcher pod. It returns nil when there is none.
+func buildVMIAnnotations(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) map[string]string {
+	annotations := map[string]string{}
+	if providerSpec.MetadataPropagation != nil {
+		annotations = propagatedMetadata(machine.Annotations, providerSpec.MetadataPropagation.AnnotationPrefixes)
+	}
+	for key, value := range buildProfileAnnotations(providerSpec.AnnotationProfiles) {
+		annotations[key] = value
+	}
+	if providerSpec.LauncherSecurity != nil && providerSpec.LauncherSecurity.SeccompProfile != "" {
+		annotations[SeccompPodAnnotation] = providerSpec.LauncherSecurity.SeccompProfile
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// buildProfileAnnotations returns the annotations the profiles expand into, nil when there is none
+func buildProfileAnnotations(profiles []kubevirtproviderv1.AnnotationProfile) map[string]string {
+	var annotations map[string]string
+	for _, profile := range profiles {
+		for key, value := range annotationProfiles[profile] {
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"encoding/json"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	// LastAppliedConfigurationAnnotation holds the VM the provider applied last, the same way
+	// kubectl apply does, so the next update knows which fields the provider owns.
+	LastAppliedConfigurationAnnotation = "kubevirt.io/last-applied-configuration"
+)
+
+// SetLastAppliedConfiguration records the metadata and spec the provider sets on the VM in the
+// last-applied annotation. The VM annotations are copied, since they are shared with the machine.
+func SetLastAppliedConfiguration(vm *kubevirtapiv1.VirtualMachine) error {
+	annotations := make(map[string]string, len(vm.Annotations)+1)
+	for key, value := range vm.Annotations {
+		if key != LastAppliedConfigurationAnnotation {
+			annotations[key] = value
+		}
+	}
+	vm.Annotations = annotations
+
+	config, err := json.Marshal(AppliedConfiguration(vm))
+	if err != nil {
+		return err
+	}
+	annotations[LastAppliedConfigurationAnnotation] = string(config)
+	return nil
+}
+
+// AppliedConfiguration returns the part of the VM the provider owns, leaving out the status and the
+// metadata set by the underkube.
+func AppliedConfiguration(vm *kubevirtapiv1.VirtualMachine) *kubevirtapiv1.VirtualMachine {
+	return &kubevirtapiv1.VirtualMachine{
+		TypeMeta: vm.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        vm.Name,
+			Namespace:   vm.Namespace,
+			Labels:      vm.Labels,
+			Annotations: vm.Annotations,
+		},
+		Spec: vm.Spec,
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package render
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// buildNetworks returns the KubeVirt networks and interfaces of the VM, and the cloud-init network
+// data configuring them in the guest. The MAC addresses are static or derived from the machine and
+// interface names, so the network data can match the NICs whatever name the guest gives them. The NICs
+// left to KubeMacPool have no network data.
+func buildNetworks(machineName string, interfaces []kubevirtproviderv1.NetworkInterface, allocation kubevirtproviderv1.MacAddressAllocation) ([]kubevirtapiv1.Network, []kubevirtapiv1.Interface, string, error) {
+	if len(interfaces) == 0 {
+		return nil, nil, "", nil
+	}
+
+	var networks []kubevirtapiv1.Network
+	var vmInterfaces []kubevirtapiv1.Interface
+	ethernets := map[string]interface{}{}
+	for _, iface := range interfaces {
+		network := kubevirtapiv1.Network{Name: iface.Name}
+		if iface.NetworkName == "" {
+			network.Pod = &kubevirtapiv1.PodNetwork{}
+		} else {
+			network.Multus = &kubevirtapiv1.MultusNetwork{NetworkName: iface.NetworkName}
+		}
+		networks = append(networks, network)
+
+		macAddress := interfaceMacAddress(machineName, iface, allocation)
+		bindingMethod := kubevirtapiv1.InterfaceBindingMethod{Bridge: &kubevirtapiv1.InterfaceBridge{}}
+		switch iface.Binding {
+		case kubevirtproviderv1.InterfaceBindingMasquerade:
+			bindingMethod = kubevirtapiv1.InterfaceBindingMethod{Masquerade: &kubevirtapiv1.InterfaceMasquerade{}}
+		case kubevirtproviderv1.InterfaceBindingSRIOV:
+			bindingMethod = kubevirtapiv1.InterfaceBindingMethod{SRIOV: &kubevirtapiv1.InterfaceSRIOV{}}
+		}
+		vmInterfaces = append(vmInterfaces, kubevirtapiv1.Interface{
+			Name:                   iface.Name,
+			InterfaceBindingMethod: bindingMethod,
+			MacAddress:             macAddress,
+			BootOrder:              iface.BootOrder,
+		})
+
+		if macAddress != "" {
+			ethernets[iface.Name] = buildEthernetConfig(iface, macAddress)
+		}
+	}
+	if len(ethernets) == 0 {
+		return networks, vmInterfaces, "", nil
+	}
+
+	networkData, err := yaml.Marshal(map[string]interface{}{
+		"version":   2,
+		"ethernets": ethernets,
+	})
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return networks, vmInterfaces, string(networkData), nil
+}
+
+// buildNetworkData returns the network data of the cloud-init volume of the VM: the one of the provider
+// spec, inline or from its secret, taking precedence over the one generated from the interfaces
+func buildNetworkData(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, generated string) (string, *corev1.LocalObjectReference) {
+	if providerSpec.NetworkDataSecretName != "" {
+		return "", &corev1.LocalObjectReference{Name: providerSpec.NetworkDataSecretName}
+	}
+	if providerSpec.NetworkData != "" {
+		return providerSpec.NetworkData, nil
+	}
+	return generated, nil
+}
+
+// buildSRIOVResources returns the virtual functions the sriov NICs of the VM request, by device plugin
+// resource. Extended resources can't be overcommitted, so they are both requested and limited.
+func buildSRIOVResources(interfaces []kubevirtproviderv1.NetworkInterface) corev1.ResourceList {
+	counts := map[string]int64{}
+	for _, iface := range interfaces {
+		if iface.Binding == kubevirtproviderv1.InterfaceBindingSRIOV {
+			counts[iface.ResourceName]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	resources := corev1.ResourceList{}
+	for name, count := range counts {
+		resources[corev1.ResourceName(name)] = *apiresource.NewQuantity(count, apiresource.DecimalSI)
+	}
+	return resources
+}
+
+// buildEthernetConfig returns the network config version 2 of an interface
+func buildEthernetConfig(iface kubevirtproviderv1.NetworkInterface, macAddress string) map[string]interface{} {
+	config := map[string]interface{}{
+		"match":    map[string]interface{}{"macaddress": macAddress},
+		"set-name": iface.Name,
+	}
+	if len(iface.Addresses) == 0 {
+		config["dhcp4"] = true
+	} else {
+		config["addresses"] = iface.Addresses
+	}
+	if iface.Gateway != "" {
+		if net.ParseIP(iface.Gateway).To4() != nil {
+			config["gateway4"] = iface.Gateway
+		} else {
+			config["gateway6"] = iface.Gateway
+		}
+	}
+	if len(iface.Nameservers) > 0 {
+		config["nameservers"] = map[string]interface{}{"addresses": iface.Nameservers}
+	}
+	return config
+}
+
+// interfaceMacAddress returns the MAC address of the NIC: its static one, in canonical form, or else the
+// derived one, or else an empty string for KubeMacPool to allocate it
+func interfaceMacAddress(machineName string, iface kubevirtproviderv1.NetworkInterface, allocation kubevirtproviderv1.MacAddressAllocation) string {
+	if iface.MacAddress != "" {
+		if mac, err := net.ParseMAC(iface.MacAddress); err == nil {
+			return mac.String()
+		}
+		return iface.MacAddress
+	}
+	if allocation == kubevirtproviderv1.MacAddressAllocationPool {
+		return ""
+	}
+	return buildMacAddress(machineName, iface.Name)
+}
+
+// buildMacAddress returns a stable, locally administered, unicast MAC address for the interface
+func buildMacAddress(machineName, interfaceName string) string {
+	sum := sha256.Sum256([]byte(machineName + "/" + interfaceName))
+	return fmt.Sprintf("02:%02x:%02x:%02x:%02x:%02x", sum[0], sum[1], sum[2], sum[3], sum[4])
+}
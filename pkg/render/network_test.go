@@ -0,0 +1,119 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestBuildNetworks(t *testing.T) {
+	interfaces := []kubevirtproviderv1.NetworkInterface{
+		{
+			Name: "default",
+			Role: kubevirtproviderv1.InterfaceRolePrimary,
+		},
+		{
+			Name:        "storage",
+			NetworkName: "storage-net",
+			Role:        kubevirtproviderv1.InterfaceRoleStorage,
+			Addresses:   []string{"192.168.10.5/24"},
+			Gateway:     "192.168.10.1",
+			Nameservers: []string{"192.168.10.2"},
+		},
+	}
+	networks, vmInterfaces, networkData, err := buildNetworks("machine-test", interfaces, "")
+	assert.NilError(t, err)
+
+	assert.DeepEqual(t, []kubevirtapiv1.Network{
+		{Name: "default", NetworkSource: kubevirtapiv1.NetworkSource{Pod: &kubevirtapiv1.PodNetwork{}}},
+		{Name: "storage", NetworkSource: kubevirtapiv1.NetworkSource{Multus: &kubevirtapiv1.MultusNetwork{NetworkName: "storage-net"}}},
+	}, networks)
+	defaultMac := buildMacAddress("machine-test", "default")
+	storageMac := buildMacAddress("machine-test", "storage")
+	assert.Equal(t, defaultMac, vmInterfaces[0].MacAddress)
+	assert.Equal(t, storageMac, vmInterfaces[1].MacAddress)
+	assert.Equal(t, `ethernets:
+  default:
+    dhcp4: true
+    match:
+      macaddress: `+defaultMac+`
+    set-name: default
+  storage:
+    addresses:
+    - 192.168.10.5/24
+    gateway4: 192.168.10.1
+    match:
+      macaddress: `+storageMac+`
+    nameservers:
+      addresses:
+      - 192.168.10.2
+    set-name: storage
+version: 2
+`, networkData)
+}
+
+func TestBuildInterfaceBindings(t *testing.T) {
+	interfaces := []kubevirtproviderv1.NetworkInterface{
+		{Name: "default", Binding: kubevirtproviderv1.InterfaceBindingMasquerade},
+		{Name: "fast0", NetworkName: "sriov-net", Binding: kubevirtproviderv1.InterfaceBindingSRIOV, ResourceName: "intel.com/sriov_netdevice"},
+		{Name: "fast1", NetworkName: "sriov-net", Binding: kubevirtproviderv1.InterfaceBindingSRIOV, ResourceName: "intel.com/sriov_netdevice"},
+	}
+	_, vmInterfaces, _, err := buildNetworks("machine-test", interfaces, "")
+	assert.NilError(t, err)
+	assert.Assert(t, vmInterfaces[0].Masquerade != nil)
+	assert.Assert(t, vmInterfaces[1].SRIOV != nil)
+	assert.Assert(t, vmInterfaces[2].SRIOV != nil)
+
+	assert.Assert(t, buildSRIOVResources(interfaces[:1]) == nil)
+	resources := buildSRIOVResources(interfaces)
+	assert.Equal(t, len(resources), 1)
+	quantity := resources["intel.com/sriov_netdevice"]
+	assert.Equal(t, quantity.Value(), int64(2))
+}
+
+func TestBuildMacAddresses(t *testing.T) {
+	interfaces := []kubevirtproviderv1.NetworkInterface{
+		{Name: "default"},
+		{Name: "tenant", NetworkName: "tenant-net", MacAddress: "52:54:00:AB:CD:EF"},
+	}
+
+	_, vmInterfaces, networkData, err := buildNetworks("machine-test", interfaces, kubevirtproviderv1.MacAddressAllocationDerived)
+	assert.NilError(t, err)
+	assert.Equal(t, vmInterfaces[0].MacAddress, buildMacAddress("machine-test", "default"))
+	assert.Equal(t, vmInterfaces[1].MacAddress, "52:54:00:ab:cd:ef")
+	assert.Assert(t, strings.Contains(networkData, "macaddress: 52:54:00:ab:cd:ef"))
+
+	_, vmInterfaces, networkData, err = buildNetworks("machine-test", interfaces, kubevirtproviderv1.MacAddressAllocationPool)
+	assert.NilError(t, err)
+	assert.Equal(t, vmInterfaces[0].MacAddress, "")
+	assert.Equal(t, vmInterfaces[1].MacAddress, "52:54:00:ab:cd:ef")
+	assert.Assert(t, !strings.Contains(networkData, "default"))
+	assert.Assert(t, strings.Contains(networkData, "tenant"))
+
+	_, _, networkData, err = buildNetworks("machine-test", interfaces[:1], kubevirtproviderv1.MacAddressAllocationPool)
+	assert.NilError(t, err)
+	assert.Equal(t, networkData, "")
+}
+
+func TestBuildNetworkData(t *testing.T) {
+	const generated = "version: 2\n"
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{}
+	networkData, secretRef := buildNetworkData(providerSpec, generated)
+	assert.Equal(t, networkData, generated)
+	assert.Assert(t, secretRef == nil)
+
+	providerSpec.NetworkData = "version: 1\n"
+	networkData, secretRef = buildNetworkData(providerSpec, generated)
+	assert.Equal(t, networkData, "version: 1\n")
+	assert.Assert(t, secretRef == nil)
+
+	providerSpec.NetworkData = ""
+	providerSpec.NetworkDataSecretName = "worker-network-data"
+	networkData, secretRef = buildNetworkData(providerSpec, generated)
+	assert.Equal(t, networkData, "")
+	assert.DeepEqual(t, secretRef, &corev1.LocalObjectReference{Name: "worker-network-data"})
+}
@@ -0,0 +1,614 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package render builds the KubeVirt VM the provider creates for a machine. It only depends on the
+// machine, its provider spec and the defaults of the provider, so installers and tests can produce the
+// exact VM the provider would create without talking to any cluster. The provider spec is expected to be
+// valid, the validation is left to the machine controller.
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+const (
+	APIVersion = "kubevirt.io/v1alpha3"
+	Kind       = "VirtualMachine"
+
+	// VMLabel holds the name of the VM on its VMI and on the underkube resources created for it
+	VMLabel = "kubevirt.io/vm"
+	// MachineRoleLabel is the role of the machine in its cluster, e.g. master or worker. It is copied to
+	// the VMI with the cluster ID, so the affinity rules can select the VMs of a cluster and role.
+	MachineRoleLabel = "machine.openshift.io/cluster-api-machine-role"
+	// RequestedCPUAnnotation and RequestedMemoryAnnotation override the resources of the provider spec
+	// on a single machine, without creating a new machineset
+	RequestedCPUAnnotation    = "kubevirt.io/requested-cpu"
+	RequestedMemoryAnnotation = "kubevirt.io/requested-memory"
+	// PowerStateAnnotation stops the VM of the machine when set to PowerStateStopped, its disks are kept.
+	// The power schedule of the machineset sets it, it can also be set by hand.
+	PowerStateAnnotation = "kubevirt.io/power-state"
+	PowerStateStopped    = "Stopped"
+	PowerStateRunning    = "Running"
+	// ProtectAnnotation set to true on the machine or on the VM keeps the provider from deleting the VM,
+	// the deletion of the machine waits until it is removed, its deletion deadline still runs. The machine
+	// one isn't copied to the VM, so each one is removed where it was set.
+	ProtectAnnotation = "kubevirt.io/protect"
+	// RestartAnnotation requests a restart of the VM of the machine, once per value: the provider sets
+	// RestartedAnnotation to the value once the VM restarted and is ready again. The rolling restart of
+	// the machineset sets it, it can also be set by hand.
+	RestartAnnotation   = "kubevirt.io/restart-requested"
+	RestartedAnnotation = "kubevirt.io/restarted"
+
+	// DefaultRequestedMemory and DefaultRequestedStorage size the VMs whose provider spec and Defaults
+	// don't
+	DefaultRequestedMemory  = "2048M"
+	DefaultRequestedStorage = "35Gi"
+
+	// upstreamMachineClusterIDLabel is the label that a machine must have to identify the cluster to which it belongs
+	upstreamMachineClusterIDLabel = "sigs.k8s.io/cluster-api-cluster"
+
+	defaultPersistentVolumeAccessMode = corev1.ReadWriteOnce
+	// migratablePersistentVolumeAccessMode lets the target virt-launcher pod of a migration attach the
+	// boot volume while the source one still runs
+	migratablePersistentVolumeAccessMode = corev1.ReadWriteMany
+	defaultDataVolumeDiskName            = "datavolumedisk1"
+	defaultCloudInitVolumeDiskName       = "cloudinitdisk"
+	defaultBootVolumeDiskName            = "bootvolume"
+	configVolumeDiskNamePrefix           = "config-"
+	defaultBus                           = "virtio"
+)
+
+// Defaults are the values the provider uses when the provider spec doesn't set them. The empty fields
+// fall back to the built-in defaults.
+type Defaults struct {
+	// Namespace is the namespace of the current context of the underkube kubeconfig
+	Namespace string
+	// RequestedMemory is the memory of the VMs
+	RequestedMemory string
+	// RequestedStorage is the size of the boot volume of the VMs
+	RequestedStorage string
+	// StorageClassName is the storage class of the boot volume of the VMs, the underkube default when empty
+	StorageClassName string
+}
+
+// RenderVirtualMachine returns the VM the provider creates for the machine
+func RenderVirtualMachine(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, defaults Defaults) (*kubevirtapiv1.VirtualMachine, error) {
+	runStrategy := RunStrategy(machine, providerSpec)
+	namespace := VMNamespace(machine, providerSpec, defaults)
+
+	vmiTemplate, err := buildVMITemplate(machine, providerSpec, defaults)
+	if err != nil {
+		return nil, err
+	}
+	bootVolume, err := buildBootVolumeDataVolumeTemplate(machine.GetName(), providerSpec.SourcePvcName, namespace, providerSpec.SourcePvcNamespace, StorageClassName(providerSpec, defaults), RequestedStorage(providerSpec, defaults), bootVolumeAccessMode(providerSpec))
+	if err != nil {
+		return nil, err
+	}
+
+	virtualMachine := kubevirtapiv1.VirtualMachine{
+		Spec: kubevirtapiv1.VirtualMachineSpec{
+			RunStrategy: &runStrategy,
+			DataVolumeTemplates: []cdiv1.DataVolume{
+				*bootVolume,
+			},
+			Template: vmiTemplate,
+		},
+	}
+
+	virtualMachine.APIVersion = APIVersion
+	virtualMachine.Kind = Kind
+	virtualMachine.ObjectMeta = metav1.ObjectMeta{
+		Name:            machine.Name,
+		Namespace:       namespace,
+		Labels:          machine.Labels,
+		Annotations:     buildVMAnnotations(machine),
+		OwnerReferences: nil,
+		ClusterName:     machine.ClusterName,
+	}
+
+	if err := SetLastAppliedConfiguration(&virtualMachine); err != nil {
+		return nil, fmt.Errorf("failed to record the applied configuration of the VM: %w", err)
+	}
+
+	return &virtualMachine, nil
+}
+
+// VMNamespace returns the underkube namespace of the VM of the machine: the provider spec namespace, else the
+// namespace of the kubeconfig context, else the cluster ID, else the machine namespace
+func VMNamespace(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, defaults Defaults) string {
+	if providerSpec.Namespace != "" {
+		return providerSpec.Namespace
+	}
+	if defaults.Namespace != "" {
+		return defaults.Namespace
+	}
+	if namespace, ok := ClusterID(machine); ok {
+		return namespace
+	}
+	return machine.Namespace
+}
+
+// MachineVMNamespace returns the underkube namespace of the VM of the machine: the one recorded in the provider
+// status, else the one VMNamespace resolves with the namespace of the kubeconfig context. The machines created
+// before the namespace was recorded never used the context namespace, it is ignored for them.
+func MachineVMNamespace(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, providerStatus *kubevirtproviderv1.KubevirtMachineProviderStatus, contextNamespace string) string {
+	if providerStatus != nil && providerStatus.VMNamespace != "" {
+		return providerStatus.VMNamespace
+	}
+	if machine.Spec.ProviderID != nil && *machine.Spec.ProviderID != "" {
+		contextNamespace = ""
+	}
+	return VMNamespace(machine, providerSpec, Defaults{Namespace: contextNamespace})
+}
+
+// ClusterID get cluster ID by machine.openshift.io/cluster-api-cluster label
+func ClusterID(machine *machinev1.Machine) (string, bool) {
+	clusterID, ok := machine.Labels[machinev1.MachineClusterIDLabel]
+	// NOTE: This block can be removed after the label renaming transition to machine.openshift.io
+	if !ok {
+		clusterID, ok = machine.Labels[upstreamMachineClusterIDLabel]
+	}
+	return clusterID, ok
+}
+
+// RequestedMemory returns the memory requested for the VM, the machine annotation taking precedence
+// over the provider spec
+func RequestedMemory(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, defaults Defaults) string {
+	if requestedMemory := machine.Annotations[RequestedMemoryAnnotation]; requestedMemory != "" {
+		return requestedMemory
+	}
+	if providerSpec.RequestedMemory != "" {
+		return providerSpec.RequestedMemory
+	}
+	if defaults.RequestedMemory != "" {
+		return defaults.RequestedMemory
+	}
+	return DefaultRequestedMemory
+}
+
+// RequestedCPU returns the CPU requested for the VM, the machine annotation taking precedence
+// over the provider spec
+func RequestedCPU(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) string {
+	if requestedCPU := machine.Annotations[RequestedCPUAnnotation]; requestedCPU != "" {
+		return requestedCPU
+	}
+	return providerSpec.RequestedCPU
+}
+
+// RequestedStorage returns the size of the root disk of the VM
+func RequestedStorage(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, defaults Defaults) string {
+	if providerSpec.RequestedStorage != "" {
+		return providerSpec.RequestedStorage
+	}
+	if defaults.RequestedStorage != "" {
+		return defaults.RequestedStorage
+	}
+	return DefaultRequestedStorage
+}
+
+// RunStrategy returns the run strategy of the VM: halted when the machine is powered off, else the one of
+// the provider spec
+func RunStrategy(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) kubevirtapiv1.VirtualMachineRunStrategy {
+	if machine.Annotations[PowerStateAnnotation] == PowerStateStopped {
+		return kubevirtapiv1.RunStrategyHalted
+	}
+	if providerSpec.RunStrategy != "" {
+		return kubevirtapiv1.VirtualMachineRunStrategy(providerSpec.RunStrategy)
+	}
+	return kubevirtapiv1.RunStrategyAlways
+}
+
+// StorageClassName returns the storage class of the root disk of the VM, empty for the underkube default
+func StorageClassName(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, defaults Defaults) string {
+	if providerSpec.StorageClassName != "" {
+		return providerSpec.StorageClassName
+	}
+	return defaults.StorageClassName
+}
+
+// BootVolumeName returns the name of the data volume, and of its PVC, the VM boots from
+func BootVolumeName(virtualMachineName string) string {
+	return buildVolumeName(virtualMachineName, defaultBootVolumeDiskName)
+}
+
+func buildDataVolumeDiskName(virtualMachineName string) string {
+	return buildVolumeName(virtualMachineName, defaultDataVolumeDiskName)
+}
+func buildCloudInitVolumeDiskName(virtualMachineName string) string {
+	return buildVolumeName(virtualMachineName, defaultCloudInitVolumeDiskName)
+}
+
+func buildConfigVolumeDiskName(virtualMachineName, configVolumeName string) string {
+	return buildVolumeName(virtualMachineName, configVolumeDiskNamePrefix+configVolumeName)
+}
+
+func buildVolumeName(virtualMachineName, suffixVolumeName string) string {
+	return fmt.Sprintf("%s-%s", virtualMachineName, suffixVolumeName)
+}
+
+func buildVMITemplate(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec, defaults Defaults) (*kubevirtapiv1.VirtualMachineInstanceTemplateSpec, error) {
+	virtualMachineName := machine.GetName()
+
+	template := &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{}
+
+	template.ObjectMeta = metav1.ObjectMeta{
+		Labels:      buildVMILabels(machine, providerSpec),
+		Annotations: buildVMIAnnotations(machine, providerSpec),
+	}
+
+	networks, interfaces, networkData, err := buildNetworks(virtualMachineName, providerSpec.Interfaces, providerSpec.MacAddressAllocation)
+	if err != nil {
+		return nil, err
+	}
+	networkData, networkDataSecretRef := buildNetworkData(providerSpec, networkData)
+
+	template.Spec = kubevirtapiv1.VirtualMachineInstanceSpec{}
+	template.Spec.Volumes = []kubevirtapiv1.Volume{
+		{
+			Name: buildDataVolumeDiskName(virtualMachineName),
+			VolumeSource: kubevirtapiv1.VolumeSource{
+				DataVolume: &kubevirtapiv1.DataVolumeSource{
+					Name: BootVolumeName(virtualMachineName),
+				},
+			},
+		},
+		{
+			Name: buildCloudInitVolumeDiskName(virtualMachineName),
+			VolumeSource: kubevirtapiv1.VolumeSource{
+				CloudInitConfigDrive: &kubevirtapiv1.CloudInitConfigDriveSource{
+					UserDataSecretRef: &corev1.LocalObjectReference{
+						Name: providerSpec.IgnitionSecretName,
+					},
+					// TODO: Use UserData after fixing the blocking port
+					//UserData: userData,
+					NetworkData:          networkData,
+					NetworkDataSecretRef: networkDataSecretRef,
+				},
+			},
+		},
+	}
+
+	template.Spec.Domain = kubevirtapiv1.DomainSpec{}
+
+	requests := corev1.ResourceList{}
+
+	requestedMemory := RequestedMemory(machine, providerSpec, defaults)
+	memory, err := apiresource.ParseQuantity(requestedMemory)
+	if err != nil {
+		return nil, machinecontroller.InvalidMachineConfiguration("%v: invalid requested memory %q: %v", virtualMachineName, requestedMemory, err)
+	}
+	requests[corev1.ResourceMemory] = memory
+
+	if requestedCPU := RequestedCPU(machine, providerSpec); requestedCPU != "" {
+		cpu, err := apiresource.ParseQuantity(requestedCPU)
+		if err != nil {
+			return nil, machinecontroller.InvalidMachineConfiguration("%v: invalid requested CPU %q: %v", virtualMachineName, requestedCPU, err)
+		}
+		requests[corev1.ResourceCPU] = cpu
+	}
+
+	template.Spec.Domain.Resources = kubevirtapiv1.ResourceRequirements{
+		Requests: requests,
+	}
+	if sriovResources := buildSRIOVResources(providerSpec.Interfaces); sriovResources != nil {
+		template.Spec.Domain.Resources.Limits = corev1.ResourceList{}
+		for name, quantity := range sriovResources {
+			template.Spec.Domain.Resources.Requests[name] = quantity
+			template.Spec.Domain.Resources.Limits[name] = quantity
+		}
+	}
+	template.Spec.Domain.Devices = kubevirtapiv1.Devices{
+		Disks: []kubevirtapiv1.Disk{
+			{
+				Name: buildDataVolumeDiskName(virtualMachineName),
+				DiskDevice: kubevirtapiv1.DiskDevice{
+					Disk: &kubevirtapiv1.DiskTarget{
+						Bus: diskBus(providerSpec.DiskBuses, kubevirtproviderv1.BootDisk),
+					},
+				},
+				DedicatedIOThread: dedicatedIOThread(providerSpec.DedicatedIOThreadDisks, kubevirtproviderv1.BootDisk),
+				BootOrder:         diskBootOrder(providerSpec.DiskBootOrders, kubevirtproviderv1.BootDisk),
+			},
+			{
+				Name: buildCloudInitVolumeDiskName(virtualMachineName),
+				DiskDevice: kubevirtapiv1.DiskDevice{
+					Disk: &kubevirtapiv1.DiskTarget{
+						Bus: diskBus(providerSpec.DiskBuses, kubevirtproviderv1.CloudInitDisk),
+					},
+				},
+				DedicatedIOThread: dedicatedIOThread(providerSpec.DedicatedIOThreadDisks, kubevirtproviderv1.CloudInitDisk),
+				BootOrder:         diskBootOrder(providerSpec.DiskBootOrders, kubevirtproviderv1.CloudInitDisk),
+			},
+		},
+	}
+
+	for _, configVolume := range providerSpec.ConfigVolumes {
+		template.Spec.Volumes = append(template.Spec.Volumes, buildConfigVolume(virtualMachineName, configVolume))
+		template.Spec.Domain.Devices.Disks = append(template.Spec.Domain.Devices.Disks, kubevirtapiv1.Disk{
+			Name: buildConfigVolumeDiskName(virtualMachineName, configVolume.Name),
+			DiskDevice: kubevirtapiv1.DiskDevice{
+				Disk: &kubevirtapiv1.DiskTarget{
+					Bus: defaultBus,
+				},
+			},
+		})
+	}
+
+	template.Spec.Networks = networks
+	template.Spec.Domain.Devices.Interfaces = interfaces
+	template.Spec.Domain.Devices.GPUs = buildGPUs(providerSpec.GPUs, providerSpec.MediatedDevices)
+	if providerSpec.RNG {
+		template.Spec.Domain.Devices.Rng = &kubevirtapiv1.Rng{}
+	}
+	template.Spec.Domain.Devices.AutoattachSerialConsole = providerSpec.AutoattachSerialConsole
+	template.Spec.Domain.Devices.AutoattachGraphicsDevice = providerSpec.AutoattachGraphicsDevice
+	template.Spec.Domain.CPU = buildCPU(providerSpec.CPU)
+	template.Spec.Domain.Firmware, template.Spec.Domain.Features = buildFirmware(providerSpec.Firmware)
+	if providerSpec.MachineType != "" {
+		template.Spec.Domain.Machine = kubevirtapiv1.Machine{Type: providerSpec.MachineType}
+	}
+	if providerSpec.IOThreadsPolicy != "" {
+		ioThreadsPolicy := kubevirtapiv1.IOThreadsPolicy(providerSpec.IOThreadsPolicy)
+		template.Spec.Domain.IOThreadsPolicy = &ioThreadsPolicy
+	}
+	if providerSpec.Hugepages != nil {
+		template.Spec.Domain.Memory = &kubevirtapiv1.Memory{Hugepages: &kubevirtapiv1.Hugepages{PageSize: providerSpec.Hugepages.PageSize}}
+	}
+	template.Spec.ReadinessProbe = buildReadinessProbe(providerSpec.HealthCheck)
+	template.Spec.TerminationGracePeriodSeconds = providerSpec.TerminationGracePeriodSeconds
+	template.Spec.Tolerations = providerSpec.Tolerations
+	template.Spec.Affinity = providerSpec.Affinity
+	if providerSpec.EvictionStrategy == kubevirtproviderv1.EvictionStrategyLiveMigrate {
+		evictionStrategy := kubevirtapiv1.EvictionStrategyLiveMigrate
+		template.Spec.EvictionStrategy = &evictionStrategy
+	}
+
+	return template, nil
+}
+
+// diskBus returns the bus of the disk, the default one when the provider spec doesn't set it
+func diskBus(buses []kubevirtproviderv1.DiskBus, disk kubevirtproviderv1.DiskName) string {
+	for _, bus := range buses {
+		if bus.Disk == disk {
+			return string(bus.Bus)
+		}
+	}
+	return defaultBus
+}
+
+// buildConfigVolume returns the volume of the ConfigMap or the Secret of the config volume
+func buildConfigVolume(virtualMachineName string, configVolume kubevirtproviderv1.ConfigVolume) kubevirtapiv1.Volume {
+	volume := kubevirtapiv1.Volume{Name: buildConfigVolumeDiskName(virtualMachineName, configVolume.Name)}
+	if configVolume.SecretName != "" {
+		volume.Secret = &kubevirtapiv1.SecretVolumeSource{
+			SecretName:  configVolume.SecretName,
+			VolumeLabel: configVolume.VolumeLabel,
+		}
+		return volume
+	}
+	volume.ConfigMap = &kubevirtapiv1.ConfigMapVolumeSource{
+		LocalObjectReference: corev1.LocalObjectReference{Name: configVolume.ConfigMapName},
+		VolumeLabel:          configVolume.VolumeLabel,
+	}
+	return volume
+}
+
+// diskBootOrder returns the boot order of the disk, nil when the provider spec doesn't set it
+func diskBootOrder(bootOrders []kubevirtproviderv1.DiskBootOrder, disk kubevirtproviderv1.DiskName) *uint {
+	for _, bootOrder := range bootOrders {
+		if bootOrder.Disk == disk {
+			order := bootOrder.BootOrder
+			return &order
+		}
+	}
+	return nil
+}
+
+// dedicatedIOThread returns true when the disk is listed in the dedicated IO thread disks, nil otherwise so
+// the disks of the existing VMs are left unchanged
+func dedicatedIOThread(disks []kubevirtproviderv1.DiskName, disk kubevirtproviderv1.DiskName) *bool {
+	for _, dedicated := range disks {
+		if dedicated == disk {
+			result := true
+			return &result
+		}
+	}
+	return nil
+}
+
+// bootVolumeAccessMode returns the access mode of the boot volume, which a live migrated VM shares between
+// the source and target nodes. The provider keeps the access mode of the boot volumes of the existing VMs.
+func bootVolumeAccessMode(providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) corev1.PersistentVolumeAccessMode {
+	if providerSpec.EvictionStrategy == kubevirtproviderv1.EvictionStrategyLiveMigrate {
+		return migratablePersistentVolumeAccessMode
+	}
+	return defaultPersistentVolumeAccessMode
+}
+
+// buildVMAnnotations returns the annotations of the VM: the ones of the machine but the protection one
+func buildVMAnnotations(machine *machinev1.Machine) map[string]string {
+	if _, ok := machine.Annotations[ProtectAnnotation]; !ok {
+		return machine.Annotations
+	}
+	annotations := make(map[string]string, len(machine.Annotations))
+	for key, value := range machine.Annotations {
+		if key != ProtectAnnotation {
+			annotations[key] = value
+		}
+	}
+	return annotations
+}
+
+// buildVMILabels returns the labels of the VMI, which its virt-launcher pod carries: the propagated labels
+// of the machine, the VM name, and the cluster ID and role of the machine the affinity rules select
+func buildVMILabels(machine *machinev1.Machine, providerSpec *kubevirtproviderv1.KubevirtMachineProviderSpec) map[string]string {
+	labels := map[string]string{}
+	if providerSpec.MetadataPropagation != nil {
+		labels = propagatedMetadata(machine.Labels, providerSpec.MetadataPropagation.LabelPrefixes)
+	}
+	labels[VMLabel] = machine.GetName()
+	labels["name"] = machine.GetName()
+	if clusterID, ok := ClusterID(machine); ok {
+		labels[machinev1.MachineClusterIDLabel] = clusterID
+	}
+	if role, ok := machine.Labels[MachineRoleLabel]; ok {
+		labels[MachineRoleLabel] = role
+	}
+	return labels
+}
+
+// propagatedMetadata returns the labels or annotations whose key starts with one of the prefixes, never nil
+func propagatedMetadata(metadata map[string]string, prefixes []string) map[string]string {
+	propagated := map[string]string{}
+	for key, value := range metadata {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				propagated[key] = value
+				break
+			}
+		}
+	}
+	return propagated
+}
+
+// buildGPUs returns the GPU devices of the VM. The v1alpha3 API has no mediated device type, KubeVirt
+// attaches the mediated devices a device plugin allocates to a GPU device.
+func buildGPUs(gpus []kubevirtproviderv1.GPU, mediatedDevices []kubevirtproviderv1.MediatedDevice) []kubevirtapiv1.GPU {
+	if len(gpus) == 0 && len(mediatedDevices) == 0 {
+		return nil
+	}
+	result := make([]kubevirtapiv1.GPU, 0, len(gpus)+len(mediatedDevices))
+	for _, gpu := range gpus {
+		result = append(result, kubevirtapiv1.GPU{Name: gpu.Name, DeviceName: gpu.DeviceName})
+	}
+	for _, device := range mediatedDevices {
+		result = append(result, kubevirtapiv1.GPU{Name: device.Name, DeviceName: device.DeviceName})
+	}
+	return result
+}
+
+func buildCPU(cpu *kubevirtproviderv1.CPU) *kubevirtapiv1.CPU {
+	if cpu == nil {
+		return nil
+	}
+	result := &kubevirtapiv1.CPU{
+		Sockets:               cpu.Sockets,
+		Cores:                 cpu.Cores,
+		Threads:               cpu.Threads,
+		Model:                 cpu.Model,
+		DedicatedCPUPlacement: cpu.DedicatedCPUPlacement,
+		IsolateEmulatorThread: cpu.IsolateEmulatorThread,
+	}
+	for _, feature := range cpu.Features {
+		result.Features = append(result.Features, kubevirtapiv1.CPUFeature{Name: feature.Name, Policy: feature.Policy})
+	}
+	return result
+}
+
+// buildFirmware returns the firmware and the features of the VM domain
+func buildFirmware(firmware *kubevirtproviderv1.Firmware) (*kubevirtapiv1.Firmware, *kubevirtapiv1.Features) {
+	if firmware == nil {
+		return nil, nil
+	}
+	var domainFirmware *kubevirtapiv1.Firmware
+	switch firmware.Bootloader {
+	case kubevirtproviderv1.EFIBootloader:
+		domainFirmware = &kubevirtapiv1.Firmware{Bootloader: &kubevirtapiv1.Bootloader{EFI: &kubevirtapiv1.EFI{}}}
+	case kubevirtproviderv1.BIOSBootloader:
+		domainFirmware = &kubevirtapiv1.Firmware{Bootloader: &kubevirtapiv1.Bootloader{BIOS: &kubevirtapiv1.BIOS{}}}
+	}
+	var features *kubevirtapiv1.Features
+	if firmware.SMM {
+		enabled := true
+		features = &kubevirtapiv1.Features{SMM: &kubevirtapiv1.FeatureState{Enabled: &enabled}}
+	}
+	return domainFirmware, features
+}
+
+func buildBootVolumeDataVolumeTemplate(virtualMachineName, pvcName, dvNamespace, pvcNamespace, storageClassName, requestedStorage string, accessMode corev1.PersistentVolumeAccessMode) (*cdiv1.DataVolume, error) {
+	storage, err := apiresource.ParseQuantity(requestedStorage)
+	if err != nil {
+		return nil, machinecontroller.InvalidMachineConfiguration("%v: invalid requested storage %q: %v", virtualMachineName, requestedStorage, err)
+	}
+
+	persistentVolumeClaimSpec := corev1.PersistentVolumeClaimSpec{
+		AccessModes: []corev1.PersistentVolumeAccessMode{
+			accessMode,
+		},
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceStorage: storage,
+			},
+		},
+	}
+	if storageClassName != "" {
+		persistentVolumeClaimSpec.StorageClassName = &storageClassName
+	}
+
+	// The source PVC is next to the VM unless the provider spec names its namespace
+	if pvcNamespace == "" {
+		pvcNamespace = dvNamespace
+	}
+
+	return &cdiv1.DataVolume{
+		TypeMeta: metav1.TypeMeta{APIVersion: cdiv1.SchemeGroupVersion.String()},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      BootVolumeName(virtualMachineName),
+			Namespace: dvNamespace,
+		},
+		Spec: cdiv1.DataVolumeSpec{
+			Source: cdiv1.DataVolumeSource{
+				PVC: &cdiv1.DataVolumeSourcePVC{
+					Name:      pvcName,
+					Namespace: pvcNamespace,
+				},
+			},
+			PVC: &persistentVolumeClaimSpec,
+		},
+	}, nil
+}
+
+// buildReadinessProbe turns the health check of the provider spec into a VMI readiness probe. The
+// unset fields are left for the underkube to default.
+func buildReadinessProbe(healthCheck *kubevirtproviderv1.HealthCheck) *kubevirtapiv1.Probe {
+	if healthCheck == nil {
+		return nil
+	}
+
+	probe := &kubevirtapiv1.Probe{
+		PeriodSeconds:    healthCheck.IntervalSeconds,
+		TimeoutSeconds:   healthCheck.TimeoutSeconds,
+		FailureThreshold: healthCheck.FailureThreshold,
+	}
+	port := intstr.FromInt(int(healthCheck.Port))
+	if healthCheck.Path != "" {
+		probe.HTTPGet = &corev1.HTTPGetAction{Path: healthCheck.Path, Port: port}
+	} else {
+		probe.TCPSocket = &corev1.TCPSocketAction{Port: port}
+	}
+	return probe
+}
@@ -0,0 +1,583 @@
+package render
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+	cdiv1 "kubevirt.io/containerized-data-importer/pkg/apis/core/v1alpha1"
+)
+
+func TestRenderVirtualMachine(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name:        "machine-test",
+		Namespace:   "openshift-machine-api",
+		Labels:      map[string]string{machinev1.MachineClusterIDLabel: "cluster-test"},
+		Annotations: map[string]string{RequestedCPUAnnotation: "4"},
+	}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		StorageClassName:   "fast",
+		RequestedCPU:       "2",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{Namespace: "tenant", RequestedMemory: "8Gi"})
+	assert.NilError(t, err)
+
+	storageClassName := "fast"
+	runAlways := kubevirtapiv1.RunStrategyAlways
+	want := &kubevirtapiv1.VirtualMachine{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine-test",
+			Namespace: "tenant",
+			Labels:    machine.Labels,
+		},
+		Spec: kubevirtapiv1.VirtualMachineSpec{
+			RunStrategy: &runAlways,
+			DataVolumeTemplates: []cdiv1.DataVolume{{
+				TypeMeta:   metav1.TypeMeta{APIVersion: cdiv1.SchemeGroupVersion.String()},
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-test-bootvolume", Namespace: "tenant"},
+				Spec: cdiv1.DataVolumeSpec{
+					Source: cdiv1.DataVolumeSource{PVC: &cdiv1.DataVolumeSourcePVC{Name: "rhcos", Namespace: "tenant"}},
+					PVC: &corev1.PersistentVolumeClaimSpec{
+						AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+						Resources:        corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: apiresource.MustParse(DefaultRequestedStorage)}},
+						StorageClassName: &storageClassName,
+					},
+				},
+			}},
+			Template: &kubevirtapiv1.VirtualMachineInstanceTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{VMLabel: "machine-test", "name": "machine-test", machinev1.MachineClusterIDLabel: "cluster-test"}},
+				Spec: kubevirtapiv1.VirtualMachineInstanceSpec{
+					Domain: kubevirtapiv1.DomainSpec{
+						Resources: kubevirtapiv1.ResourceRequirements{Requests: corev1.ResourceList{
+							corev1.ResourceMemory: apiresource.MustParse("8Gi"),
+							corev1.ResourceCPU:    apiresource.MustParse("4"),
+						}},
+						Devices: kubevirtapiv1.Devices{Disks: []kubevirtapiv1.Disk{
+							{Name: "machine-test-datavolumedisk1", DiskDevice: kubevirtapiv1.DiskDevice{Disk: &kubevirtapiv1.DiskTarget{Bus: "virtio"}}},
+							{Name: "machine-test-cloudinitdisk", DiskDevice: kubevirtapiv1.DiskDevice{Disk: &kubevirtapiv1.DiskTarget{Bus: "virtio"}}},
+						}},
+					},
+					Volumes: []kubevirtapiv1.Volume{
+						{
+							Name:         "machine-test-datavolumedisk1",
+							VolumeSource: kubevirtapiv1.VolumeSource{DataVolume: &kubevirtapiv1.DataVolumeSource{Name: "machine-test-bootvolume"}},
+						},
+						{
+							Name: "machine-test-cloudinitdisk",
+							VolumeSource: kubevirtapiv1.VolumeSource{CloudInitConfigDrive: &kubevirtapiv1.CloudInitConfigDriveSource{
+								UserDataSecretRef: &corev1.LocalObjectReference{Name: "worker-user-data"},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	applied := vm.Annotations[LastAppliedConfigurationAnnotation]
+	assert.Assert(t, applied != "")
+	delete(vm.Annotations, LastAppliedConfigurationAnnotation)
+	assert.DeepEqual(t, vm.Annotations, machine.Annotations)
+	vm.Annotations = nil
+	assert.DeepEqual(t, vm, want)
+}
+
+func TestRenderVirtualMachineInvalidQuantities(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Namespace: "openshift-machine-api"}}
+
+	_, err := RenderVirtualMachine(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{RequestedMemory: "lots"}, Defaults{})
+	assert.Error(t, err, `machine-test: invalid requested memory "lots": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`)
+	_, err = RenderVirtualMachine(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{RequestedCPU: "many"}, Defaults{})
+	assert.Error(t, err, `machine-test: invalid requested CPU "many": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`)
+	_, err = RenderVirtualMachine(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{RequestedStorage: "huge"}, Defaults{})
+	assert.Error(t, err, `machine-test: invalid requested storage "huge": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`)
+}
+
+func TestVMNamespace(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-machine-api"}}
+	assert.Equal(t, VMNamespace(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{}, Defaults{}), "openshift-machine-api")
+	machine.Labels = map[string]string{upstreamMachineClusterIDLabel: "cluster-test"}
+	assert.Equal(t, VMNamespace(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{}, Defaults{}), "cluster-test")
+	assert.Equal(t, VMNamespace(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{}, Defaults{Namespace: "tenant"}), "tenant")
+	assert.Equal(t, VMNamespace(machine, &kubevirtproviderv1.KubevirtMachineProviderSpec{Namespace: "vms"}, Defaults{Namespace: "tenant"}), "vms")
+}
+
+func TestMachineVMNamespace(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Namespace: "openshift-machine-api", Labels: map[string]string{upstreamMachineClusterIDLabel: "cluster-test"}}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{}
+	assert.Equal(t, MachineVMNamespace(machine, providerSpec, nil, "tenant"), "tenant")
+	assert.Equal(t, MachineVMNamespace(machine, providerSpec, &kubevirtproviderv1.KubevirtMachineProviderStatus{VMNamespace: "cluster-test"}, "tenant"), "cluster-test")
+	// Created before the namespace was recorded
+	providerID := "kubevirt://cluster-test/machine"
+	machine.Spec.ProviderID = &providerID
+	assert.Equal(t, MachineVMNamespace(machine, providerSpec, &kubevirtproviderv1.KubevirtMachineProviderStatus{}, "tenant"), "cluster-test")
+}
+
+func TestBuildReadinessProbe(t *testing.T) {
+	cases := []struct {
+		name        string
+		healthCheck *kubevirtproviderv1.HealthCheck
+		wantProbe   *kubevirtapiv1.Probe
+	}{
+		{
+			name: "No probe without a health check",
+		},
+		{
+			name:        "TCP probe without a path",
+			healthCheck: &kubevirtproviderv1.HealthCheck{Port: 22},
+			wantProbe: &kubevirtapiv1.Probe{
+				Handler: kubevirtapiv1.Handler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(22)}},
+			},
+		},
+		{
+			name:        "HTTP probe with a path",
+			healthCheck: &kubevirtproviderv1.HealthCheck{Port: 10256, Path: "/healthz", IntervalSeconds: 5, FailureThreshold: 2},
+			wantProbe: &kubevirtapiv1.Probe{
+				Handler:          kubevirtapiv1.Handler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(10256)}},
+				PeriodSeconds:    5,
+				FailureThreshold: 2,
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.DeepEqual(t, tc.wantProbe, buildReadinessProbe(tc.healthCheck))
+		})
+	}
+}
+
+func TestRenderVirtualMachineSourcePVCNamespace(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		SourcePvcNamespace: "images",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{Namespace: "tenant"})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.DataVolumeTemplates[0].Spec.Source.PVC, &cdiv1.DataVolumeSourcePVC{Name: "rhcos", Namespace: "images"})
+	assert.Equal(t, vm.Spec.DataVolumeTemplates[0].Namespace, "tenant")
+}
+
+func TestRenderVirtualMachineGPUs(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		GPUs:               []kubevirtproviderv1.GPU{{Name: "gpu1", DeviceName: "nvidia.com/GV100GL_Tesla_V100"}},
+		MediatedDevices:    []kubevirtproviderv1.MediatedDevice{{Name: "vgpu1", DeviceName: "nvidia.com/GRID_T4-1Q"}},
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Domain.Devices.GPUs, []kubevirtapiv1.GPU{
+		{Name: "gpu1", DeviceName: "nvidia.com/GV100GL_Tesla_V100"},
+		{Name: "vgpu1", DeviceName: "nvidia.com/GRID_T4-1Q"},
+	})
+}
+
+func TestRenderVirtualMachineCPU(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.Spec.Domain.CPU == nil)
+
+	providerSpec.CPU = &kubevirtproviderv1.CPU{
+		Sockets:               2,
+		Cores:                 2,
+		Threads:               1,
+		Model:                 "Skylake-Server",
+		Features:              []kubevirtproviderv1.CPUFeature{{Name: "vmx"}, {Name: "pcid", Policy: "disable"}},
+		DedicatedCPUPlacement: true,
+	}
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Domain.CPU, &kubevirtapiv1.CPU{
+		Sockets:               2,
+		Cores:                 2,
+		Threads:               1,
+		Model:                 "Skylake-Server",
+		Features:              []kubevirtapiv1.CPUFeature{{Name: "vmx"}, {Name: "pcid", Policy: "disable"}},
+		DedicatedCPUPlacement: true,
+	})
+}
+
+func TestRenderVirtualMachineHugepages(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		RequestedMemory:    "8Gi",
+		Hugepages:          &kubevirtproviderv1.Hugepages{PageSize: "1Gi"},
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Domain.Memory, &kubevirtapiv1.Memory{Hugepages: &kubevirtapiv1.Hugepages{PageSize: "1Gi"}})
+}
+
+func TestRenderVirtualMachineMachineType(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Equal(t, vm.Spec.Template.Spec.Domain.Machine.Type, "")
+
+	providerSpec.MachineType = "q35"
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Equal(t, vm.Spec.Template.Spec.Domain.Machine.Type, "q35")
+}
+
+func TestRenderVirtualMachineAnnotationProfiles(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.ObjectMeta.Annotations == nil)
+
+	providerSpec.AnnotationProfiles = []kubevirtproviderv1.AnnotationProfile{kubevirtproviderv1.MigratableBridgeProfile, kubevirtproviderv1.DeschedulerEvictableProfile}
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.ObjectMeta.Annotations, map[string]string{
+		"kubevirt.io/allow-pod-bridge-network-live-migration": "",
+		"descheduler.alpha.kubernetes.io/evict":               "true",
+	})
+
+	providerSpec.AnnotationProfiles = nil
+	providerSpec.LauncherSecurity = &kubevirtproviderv1.LauncherSecurity{SeccompProfile: "runtime/default"}
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.ObjectMeta.Annotations, map[string]string{SeccompPodAnnotation: "runtime/default"})
+}
+
+func TestRenderVirtualMachineProtectAnnotation(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name:        "machine-test",
+		Annotations: map[string]string{ProtectAnnotation: "true", RequestedCPUAnnotation: "4"},
+	}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	_, protected := vm.Annotations[ProtectAnnotation]
+	assert.Assert(t, !protected)
+	assert.Equal(t, vm.Annotations[RequestedCPUAnnotation], "4")
+	assert.Equal(t, machine.Annotations[ProtectAnnotation], "true")
+}
+
+func TestRenderVirtualMachineMetadataPropagation(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{
+		Name: "machine-test",
+		Labels: map[string]string{
+			machinev1.MachineClusterIDLabel: "kubevirt-actuator-cluster",
+			"backup.example.com/policy":     "daily",
+			"monitoring":                    "enabled",
+			"team":                          "infra",
+			VMLabel:                         "other-vm",
+		},
+		Annotations: map[string]string{
+			"backup.example.com/retention": "7d",
+			PowerStateAnnotation:           PowerStateRunning,
+		},
+	}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		AnnotationProfiles: []kubevirtproviderv1.AnnotationProfile{kubevirtproviderv1.DeschedulerEvictableProfile},
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.ObjectMeta.Labels, map[string]string{
+		VMLabel:                         "machine-test",
+		"name":                          "machine-test",
+		machinev1.MachineClusterIDLabel: "kubevirt-actuator-cluster",
+	})
+
+	providerSpec.MetadataPropagation = &kubevirtproviderv1.MetadataPropagation{
+		LabelPrefixes:      []string{"backup.example.com/", "monitoring", "kubevirt.io/vm"},
+		AnnotationPrefixes: []string{"backup.example.com/"},
+	}
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.ObjectMeta.Labels, map[string]string{
+		VMLabel:                         "machine-test",
+		"name":                          "machine-test",
+		machinev1.MachineClusterIDLabel: "kubevirt-actuator-cluster",
+		"backup.example.com/policy":     "daily",
+		"monitoring":                    "enabled",
+	})
+	assert.DeepEqual(t, vm.Spec.Template.ObjectMeta.Annotations, map[string]string{
+		"backup.example.com/retention":          "7d",
+		"descheduler.alpha.kubernetes.io/evict": "true",
+	})
+}
+
+func TestRenderVirtualMachineRNG(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.Spec.Domain.Devices.Rng == nil)
+
+	providerSpec.RNG = true
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Domain.Devices.Rng, &kubevirtapiv1.Rng{})
+}
+
+func TestRenderVirtualMachineIOThreads(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.Spec.Domain.IOThreadsPolicy == nil)
+	for _, disk := range vm.Spec.Template.Spec.Domain.Devices.Disks {
+		assert.Assert(t, disk.DedicatedIOThread == nil)
+	}
+
+	providerSpec.IOThreadsPolicy = kubevirtproviderv1.IOThreadsPolicyShared
+	providerSpec.DedicatedIOThreadDisks = []kubevirtproviderv1.DiskName{kubevirtproviderv1.BootDisk}
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	shared := kubevirtapiv1.IOThreadsPolicyShared
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Domain.IOThreadsPolicy, &shared)
+	dedicated := true
+	disks := vm.Spec.Template.Spec.Domain.Devices.Disks
+	assert.Equal(t, disks[0].Name, "machine-test-datavolumedisk1")
+	assert.DeepEqual(t, disks[0].DedicatedIOThread, &dedicated)
+	assert.Equal(t, disks[1].Name, "machine-test-cloudinitdisk")
+	assert.Assert(t, disks[1].DedicatedIOThread == nil)
+}
+
+func TestRenderVirtualMachineDiskBuses(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		DiskBuses:          []kubevirtproviderv1.DiskBus{{Disk: kubevirtproviderv1.BootDisk, Bus: kubevirtproviderv1.SATABus}},
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	disks := vm.Spec.Template.Spec.Domain.Devices.Disks
+	assert.Equal(t, disks[0].Name, "machine-test-datavolumedisk1")
+	assert.Equal(t, disks[0].Disk.Bus, "sata")
+	assert.Equal(t, disks[1].Name, "machine-test-cloudinitdisk")
+	assert.Equal(t, disks[1].Disk.Bus, "virtio")
+}
+
+func TestRenderVirtualMachineBootOrder(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	pxe := uint(2)
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		DiskBootOrders:     []kubevirtproviderv1.DiskBootOrder{{Disk: kubevirtproviderv1.BootDisk, BootOrder: 1}},
+		Interfaces:         []kubevirtproviderv1.NetworkInterface{{Name: "default", BootOrder: &pxe}},
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	devices := vm.Spec.Template.Spec.Domain.Devices
+	assert.Equal(t, devices.Disks[0].Name, "machine-test-datavolumedisk1")
+	assert.Equal(t, *devices.Disks[0].BootOrder, uint(1))
+	assert.Equal(t, devices.Disks[1].Name, "machine-test-cloudinitdisk")
+	assert.Assert(t, devices.Disks[1].BootOrder == nil)
+	assert.Equal(t, *devices.Interfaces[0].BootOrder, uint(2))
+}
+
+func TestRenderVirtualMachineConfigVolumes(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		ConfigVolumes: []kubevirtproviderv1.ConfigVolume{
+			{Name: "agent", ConfigMapName: "agent-config", VolumeLabel: "AGENT"},
+			{Name: "certs", SecretName: "infra-certs"},
+		},
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	volumes := vm.Spec.Template.Spec.Volumes
+	assert.Equal(t, len(volumes), 4)
+	assert.DeepEqual(t, volumes[2], kubevirtapiv1.Volume{
+		Name: "machine-test-config-agent",
+		VolumeSource: kubevirtapiv1.VolumeSource{
+			ConfigMap: &kubevirtapiv1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "agent-config"}, VolumeLabel: "AGENT"},
+		},
+	})
+	assert.DeepEqual(t, volumes[3], kubevirtapiv1.Volume{
+		Name:         "machine-test-config-certs",
+		VolumeSource: kubevirtapiv1.VolumeSource{Secret: &kubevirtapiv1.SecretVolumeSource{SecretName: "infra-certs"}},
+	})
+	disks := vm.Spec.Template.Spec.Domain.Devices.Disks
+	assert.Equal(t, len(disks), 4)
+	assert.Equal(t, disks[2].Name, "machine-test-config-agent")
+	assert.Equal(t, disks[2].Disk.Bus, "virtio")
+	assert.Equal(t, disks[3].Name, "machine-test-config-certs")
+}
+
+func TestRenderVirtualMachineAutoattachDevices(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.Spec.Domain.Devices.AutoattachSerialConsole == nil)
+	assert.Assert(t, vm.Spec.Template.Spec.Domain.Devices.AutoattachGraphicsDevice == nil)
+
+	serialConsole, graphicsDevice := true, false
+	providerSpec.AutoattachSerialConsole = &serialConsole
+	providerSpec.AutoattachGraphicsDevice = &graphicsDevice
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Equal(t, *vm.Spec.Template.Spec.Domain.Devices.AutoattachSerialConsole, true)
+	assert.Equal(t, *vm.Spec.Template.Spec.Domain.Devices.AutoattachGraphicsDevice, false)
+}
+
+func TestRenderVirtualMachineFirmware(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.Spec.Domain.Firmware == nil)
+	assert.Assert(t, vm.Spec.Template.Spec.Domain.Features == nil)
+
+	providerSpec.Firmware = &kubevirtproviderv1.Firmware{Bootloader: kubevirtproviderv1.EFIBootloader, SMM: true}
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	enabled := true
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Domain.Firmware, &kubevirtapiv1.Firmware{Bootloader: &kubevirtapiv1.Bootloader{EFI: &kubevirtapiv1.EFI{}}})
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Domain.Features, &kubevirtapiv1.Features{SMM: &kubevirtapiv1.FeatureState{Enabled: &enabled}})
+}
+
+func TestRunStrategy(t *testing.T) {
+	machine := &machinev1.Machine{}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{}
+	assert.Equal(t, RunStrategy(machine, providerSpec), kubevirtapiv1.RunStrategyAlways)
+	machine.Annotations = map[string]string{PowerStateAnnotation: PowerStateStopped}
+	assert.Equal(t, RunStrategy(machine, providerSpec), kubevirtapiv1.RunStrategyHalted)
+	machine.Annotations[PowerStateAnnotation] = PowerStateRunning
+	assert.Equal(t, RunStrategy(machine, providerSpec), kubevirtapiv1.RunStrategyAlways)
+
+	providerSpec.RunStrategy = kubevirtproviderv1.RunStrategyRerunOnFailure
+	assert.Equal(t, RunStrategy(machine, providerSpec), kubevirtapiv1.RunStrategyRerunOnFailure)
+	machine.Annotations[PowerStateAnnotation] = PowerStateStopped
+	assert.Equal(t, RunStrategy(machine, providerSpec), kubevirtapiv1.RunStrategyHalted)
+}
+
+func TestRenderVirtualMachineScheduling(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test", Labels: map[string]string{
+		machinev1.MachineClusterIDLabel: "tenant-a",
+		MachineRoleLabel:                "master",
+		"other":                         "label",
+	}}}
+	tolerations := []corev1.Toleration{
+		{Key: "example.com/tenant-capacity", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	}
+	affinity := &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{machinev1.MachineClusterIDLabel: "tenant-a", MachineRoleLabel: "master"}},
+			TopologyKey:   "kubernetes.io/hostname",
+		}},
+	}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+		Tolerations:        tolerations,
+		Affinity:           affinity,
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Tolerations, tolerations)
+	assert.DeepEqual(t, vm.Spec.Template.Spec.Affinity, affinity)
+	assert.DeepEqual(t, vm.Spec.Template.ObjectMeta.Labels, map[string]string{
+		VMLabel:                         "machine-test",
+		"name":                          "machine-test",
+		machinev1.MachineClusterIDLabel: "tenant-a",
+		MachineRoleLabel:                "master",
+	})
+}
+
+func TestRenderVirtualMachineEvictionStrategy(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.Spec.EvictionStrategy == nil)
+	assert.DeepEqual(t, vm.Spec.DataVolumeTemplates[0].Spec.PVC.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce})
+
+	providerSpec.EvictionStrategy = kubevirtproviderv1.EvictionStrategyLiveMigrate
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Equal(t, *vm.Spec.Template.Spec.EvictionStrategy, kubevirtapiv1.EvictionStrategyLiveMigrate)
+	assert.DeepEqual(t, vm.Spec.DataVolumeTemplates[0].Spec.PVC.AccessModes, []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany})
+}
+
+func TestRenderVirtualMachineTerminationGracePeriod(t *testing.T) {
+	machine := &machinev1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "machine-test"}}
+	providerSpec := &kubevirtproviderv1.KubevirtMachineProviderSpec{
+		SourcePvcName:      "rhcos",
+		IgnitionSecretName: "worker-user-data",
+	}
+
+	vm, err := RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Assert(t, vm.Spec.Template.Spec.TerminationGracePeriodSeconds == nil)
+
+	gracePeriod := int64(120)
+	providerSpec.TerminationGracePeriodSeconds = &gracePeriod
+	vm, err = RenderVirtualMachine(machine, providerSpec, Defaults{})
+	assert.NilError(t, err)
+	assert.Equal(t, *vm.Spec.Template.Spec.TerminationGracePeriodSeconds, gracePeriod)
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package repair watches the machines the machine controller moved to the Failed phase because it didn't
+// find their VM, and brings them back when the VM turns out to exist. The machine controller never
+// reconciles a failed machine again, so without it such a machine stays failed for good.
+package repair
+
+import (
+	"context"
+
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	controllerName = "providerid-repair-controller"
+)
+
+// ProviderIDRepairer repairs the providerID of a failed machine whose VM exists, it returns true when the
+// machine was repaired
+type ProviderIDRepairer interface {
+	RepairProviderID(machine *machinev1.Machine) (bool, error)
+}
+
+type reconciler struct {
+	client   client.Client
+	repairer ProviderIDRepairer
+}
+
+// Add creates the providerID repair controller and adds it to the manager
+func Add(mgr manager.Manager, repairer ProviderIDRepairer) error {
+	r := &reconciler{
+		client:   mgr.GetClient(),
+		repairer: repairer,
+	}
+	c, err := controller.New(controllerName, mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &machinev1.Machine{}}, &handler.EnqueueRequestForObject{})
+}
+
+// Reconcile repairs the machine when it is failed for a VM that exists. The machines being deleted are left
+// to the machine controller.
+func (r *reconciler) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	machine := &machinev1.Machine{}
+	if err := r.client.Get(context.Background(), request.NamespacedName, machine); err != nil {
+		if apimachineryerrors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if machine.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+
+	repaired, err := r.repairer.RepairProviderID(machine)
+	if err != nil {
+		klog.Errorf("%s: failed to repair the providerID: %v", machine.GetName(), err)
+		return reconcile.Result{}, err
+	}
+	if repaired {
+		klog.Infof("%s: providerID repaired, the machine controller reconciles the machine again", machine.GetName())
+	}
+	return reconcile.Result{}, nil
+}
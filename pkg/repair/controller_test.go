@@ -0,0 +1,128 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package repair
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smetav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// machineGetter is a client serving Get from a single machine, or an error
+type machineGetter struct {
+	client.Client
+	machine *machinev1.Machine
+	err     error
+}
+
+func (c *machineGetter) Get(_ context.Context, _ client.ObjectKey, obj runtime.Object) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.machine.DeepCopyInto(obj.(*machinev1.Machine))
+	return nil
+}
+
+// repairer records the machines it is asked to repair
+type repairer struct {
+	repaired bool
+	err      error
+	machines []string
+}
+
+func (r *repairer) RepairProviderID(machine *machinev1.Machine) (bool, error) {
+	r.machines = append(r.machines, machine.Name)
+	return r.repaired, r.err
+}
+
+func TestReconcile(t *testing.T) {
+	deletionTimestamp := k8smetav1.Now()
+	cases := []struct {
+		name              string
+		getErr            error
+		deletionTimestamp *k8smetav1.Time
+		repaired          bool
+		repairErr         error
+		wantRepair        bool
+		wantErr           string
+	}{
+		{
+			name:   "Machine is gone",
+			getErr: apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "machines"}, "worker-1"),
+		},
+		{
+			name:    "Machine can't be read",
+			getErr:  errors.New("connection refused"),
+			wantErr: "connection refused",
+		},
+		{
+			name:              "Machine being deleted",
+			deletionTimestamp: &deletionTimestamp,
+		},
+		{
+			name:       "Machine not repaired",
+			wantRepair: true,
+		},
+		{
+			name:       "Machine repaired",
+			repaired:   true,
+			wantRepair: true,
+		},
+		{
+			name:       "Repair failed",
+			repairErr:  errors.New("underkube unreachable"),
+			wantRepair: true,
+			wantErr:    "underkube unreachable",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine := &machinev1.Machine{}
+			machine.Name = "worker-1"
+			machine.Namespace = "openshift-machine-api"
+			machine.DeletionTimestamp = tc.deletionTimestamp
+			repairer := &repairer{repaired: tc.repaired, err: tc.repairErr}
+			r := &reconciler{
+				client:   &machineGetter{machine: machine, err: tc.getErr},
+				repairer: repairer,
+			}
+
+			result, err := r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: machine.Namespace, Name: machine.Name}})
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+			assert.DeepEqual(t, result, reconcile.Result{})
+			if tc.wantRepair {
+				assert.DeepEqual(t, repairer.machines, []string{"worker-1"})
+			} else {
+				assert.Assert(t, repairer.machines == nil)
+			}
+		})
+	}
+}
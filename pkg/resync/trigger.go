@@ -0,0 +1,144 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package resync lets the controllers running next to the provider, e.g. the maintenance operators of an
+// underkube, force the reconcile of a machine, or of all the machines of an underkube, when something the
+// provider doesn't watch changed.
+package resync
+
+import (
+	"context"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ResyncAnnotation is set on the machines to resync, to the time of the request. Changing the machine
+	// enqueues it in the machine controller, the processes outside of the provider can set it as well.
+	ResyncAnnotation = "kubevirt.io/resync-requested"
+	// requestsBuffer is the number of requests queued before Resync blocks
+	requestsBuffer = 100
+)
+
+// Request selects the machines to resync
+type Request struct {
+	// Namespace of the machines
+	Namespace string
+	// Name of the machine, the machines SecretName selects when empty
+	Name string
+	// SecretName is the credentials secret of the underkube whose machines are resynced, all the machines
+	// of the namespace when empty
+	SecretName string
+}
+
+// Trigger resyncs the machines of the requests it receives, to be added to the manager as a runnable
+type Trigger struct {
+	client         client.Client
+	providerConfig *providerconfig.Store
+	requests       chan Request
+}
+
+// New creates a trigger, the provider config store holds the default credentials secret of the machines
+func New(client client.Client, providerConfig *providerconfig.Store) *Trigger {
+	return &Trigger{
+		client:         client,
+		providerConfig: providerConfig,
+		requests:       make(chan Request, requestsBuffer),
+	}
+}
+
+// Requests returns the channel the requests are sent to, sending blocks while the trigger is behind
+func (t *Trigger) Requests() chan<- Request {
+	return t.requests
+}
+
+// Resync requests the resync of the machines the request selects
+func (t *Trigger) Resync(request Request) {
+	t.requests <- request
+}
+
+// Start handles the requests until the stop channel is closed. A request that fails is logged and dropped,
+// the sender asks again if it still needs it.
+func (t *Trigger) Start(stop <-chan struct{}) error {
+	for {
+		select {
+		case <-stop:
+			return nil
+		case request := <-t.requests:
+			if err := t.resync(request); err != nil {
+				klog.Errorf("failed to resync the machines of %+v: %v", request, err)
+			}
+		}
+	}
+}
+
+func (t *Trigger) resync(request Request) error {
+	machines := &machinev1.MachineList{}
+	if err := t.client.List(context.Background(), machines, client.InNamespace(request.Namespace)); err != nil {
+		return err
+	}
+	token := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, machine := range machinesToResync(machines.Items, request, t.providerConfig.Get()) {
+		originMachine := machine.DeepCopy()
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[ResyncAnnotation] = token
+		klog.Infof("%s: resync requested, enqueuing the machine", machine.GetName())
+		if err := t.client.Patch(context.Background(), machine, client.MergeFrom(originMachine)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// machinesToResync returns the machines the request selects. The machines whose credentials can't be
+// resolved only match a request naming them.
+func machinesToResync(machines []machinev1.Machine, request Request, spec kubevirtproviderv1.KubevirtProviderConfigSpec) []*machinev1.Machine {
+	var result []*machinev1.Machine
+	for i := range machines {
+		machine := &machines[i]
+		if request.Name != "" {
+			if machine.GetName() == request.Name {
+				result = append(result, machine)
+			}
+			continue
+		}
+		if request.SecretName == "" {
+			result = append(result, machine)
+			continue
+		}
+		providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(machine.Spec.ProviderSpec.Value)
+		if err != nil {
+			klog.V(3).Infof("%s: failed to get the provider spec: %v", machine.GetName(), err)
+			continue
+		}
+		secretName, err := providerconfig.UnderKubeconfigSecretName(machine, providerSpec, spec)
+		if err != nil {
+			klog.V(3).Infof("%s: %v", machine.GetName(), err)
+			continue
+		}
+		if secretName == request.SecretName {
+			result = append(result, machine)
+		}
+	}
+	return result
+}
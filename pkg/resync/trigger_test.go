@@ -0,0 +1,77 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package resync
+
+import (
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+)
+
+func TestMachinesToResync(t *testing.T) {
+	stubMachine := func(name, secretName string) machinev1.Machine {
+		providerSpec, err := kubevirtproviderv1.RawExtensionFromProviderSpec(&kubevirtproviderv1.KubevirtMachineProviderSpec{UnderKubeconfigSecretName: secretName})
+		assert.NilError(t, err)
+		machine := machinev1.Machine{}
+		machine.Name = name
+		machine.Spec.ProviderSpec.Value = providerSpec
+		return machine
+	}
+	machines := []machinev1.Machine{
+		stubMachine("worker-1", "infra-a"),
+		stubMachine("worker-2", "infra-b"),
+		stubMachine("worker-3", ""),
+	}
+	spec := kubevirtproviderv1.KubevirtProviderConfigSpec{DefaultUnderKubeconfigSecretName: "infra-a"}
+
+	cases := []struct {
+		name      string
+		request   Request
+		wantNames []string
+	}{
+		{
+			name:      "One machine",
+			request:   Request{Name: "worker-2"},
+			wantNames: []string{"worker-2"},
+		},
+		{
+			name:    "Unknown machine",
+			request: Request{Name: "worker-4"},
+		},
+		{
+			name:      "Machines of an underkube",
+			request:   Request{SecretName: "infra-a"},
+			wantNames: []string{"worker-1", "worker-3"},
+		},
+		{
+			name:      "All machines",
+			request:   Request{},
+			wantNames: []string{"worker-1", "worker-2", "worker-3"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var names []string
+			for _, machine := range machinesToResync(machines, tc.request, spec) {
+				names = append(names, machine.Name)
+			}
+			assert.DeepEqual(t, names, tc.wantNames)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rollingrestart restarts the VMs of a machineset a few at a time, e.g. to pick up a new
+// configuration of the guests or of the underkube, without taking down the workloads of the machineset at
+// once. The machines are kept, only their VMs restart.
+package rollingrestart
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// RestartAnnotation set on a machineset requests the restart of the VMs of all its machines, once per
+	// value, e.g. the time of the request. RestartedAnnotation is set to the value once all of them
+	// restarted.
+	RestartAnnotation   = render.RestartAnnotation
+	RestartedAnnotation = render.RestartedAnnotation
+	// MaxUnavailableAnnotation is the number of machines of the machineset restarting, or otherwise not
+	// running, at the same time. Defaults to 1.
+	MaxUnavailableAnnotation = "kubevirt.io/restart-max-unavailable"
+	// RestartRequestedAtAnnotation is the time the restarter requested the restart of a machine. The
+	// machine counts as unavailable until its node turned ready after that time.
+	RestartRequestedAtAnnotation = "kubevirt.io/restart-requested-at"
+
+	// machinePhaseRunning is the phase of the machines whose node joined
+	machinePhaseRunning = "Running"
+
+	// interval is how often the progress of the restarts is checked
+	interval = 30 * time.Second
+)
+
+// Restarter requests the restart of the machines of the machinesets with a pending restart, no more than
+// the max unavailable of the machineset at a time. The machine controller restarts their VMs.
+type Restarter struct {
+	client client.Client
+}
+
+// New creates a restarter, to be added to the manager as a runnable
+func New(client client.Client) *Restarter {
+	return &Restarter{client: client}
+}
+
+// Start runs the restarter until the stop channel is closed
+func (r *Restarter) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := r.restartAll(); err != nil {
+			klog.Errorf("failed to run the rolling restarts: %v", err)
+		}
+	}, interval, stop)
+	return nil
+}
+
+func (r *Restarter) restartAll() error {
+	machineSets := &machinev1.MachineSetList{}
+	if err := r.client.List(context.Background(), machineSets); err != nil {
+		return err
+	}
+	for i := range machineSets.Items {
+		machineSet := &machineSets.Items[i]
+		token := machineSet.Annotations[RestartAnnotation]
+		if token == "" || machineSet.Annotations[RestartedAnnotation] == token {
+			continue
+		}
+		if err := r.restartMachineSet(machineSet, token); err != nil {
+			klog.Errorf("%s: failed to run the rolling restart: %v", machineSet.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (r *Restarter) restartMachineSet(machineSet *machinev1.MachineSet, token string) error {
+	maxUnavailable, err := parseMaxUnavailable(machineSet.Annotations[MaxUnavailableAnnotation])
+	if err != nil {
+		return err
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&machineSet.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	machines := &machinev1.MachineList{}
+	if err := r.client.List(context.Background(), machines, client.InNamespace(machineSet.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+
+	nodes, err := r.getRestartedNodes(machines.Items, token)
+	if err != nil {
+		return err
+	}
+
+	toRestart, done := selectRestarts(machines.Items, nodes, token, maxUnavailable)
+	for _, machine := range toRestart {
+		originMachine := machine.DeepCopy()
+		if machine.Annotations == nil {
+			machine.Annotations = map[string]string{}
+		}
+		machine.Annotations[RestartAnnotation] = token
+		machine.Annotations[RestartRequestedAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+		klog.Infof("%s: restarting machine %s", machineSet.GetName(), machine.GetName())
+		if err := r.client.Patch(context.Background(), machine, client.MergeFrom(originMachine)); err != nil {
+			return err
+		}
+	}
+	if !done {
+		return nil
+	}
+
+	originMachineSet := machineSet.DeepCopy()
+	machineSet.Annotations[RestartedAnnotation] = token
+	klog.Infof("%s: all the machines restarted", machineSet.GetName())
+	return r.client.Patch(context.Background(), machineSet, client.MergeFrom(originMachineSet))
+}
+
+// getRestartedNodes returns the nodes of the machines whose VM restarted, by name. The nodes not found are
+// left out.
+func (r *Restarter) getRestartedNodes(machines []machinev1.Machine, token string) (map[string]*corev1.Node, error) {
+	nodes := map[string]*corev1.Node{}
+	for i := range machines {
+		machine := &machines[i]
+		if machine.Annotations[RestartedAnnotation] != token || machine.Status.NodeRef == nil {
+			continue
+		}
+		node := &corev1.Node{}
+		if err := r.client.Get(context.Background(), client.ObjectKey{Name: machine.Status.NodeRef.Name}, node); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		nodes[node.Name] = node
+	}
+	return nodes, nil
+}
+
+// parseMaxUnavailable parses the max unavailable annotation, 1 when unset
+func parseMaxUnavailable(value string) (int, error) {
+	if value == "" {
+		return 1, nil
+	}
+	maxUnavailable, err := strconv.Atoi(value)
+	if err != nil || maxUnavailable < 1 {
+		return 0, fmt.Errorf("invalid %s %q, expected a positive integer", MaxUnavailableAnnotation, value)
+	}
+	return maxUnavailable, nil
+}
+
+// selectRestarts returns the machines to restart now, in the order of their names, and whether all the
+// machines restarted. A machine restarting, or not running, counts against the max unavailable until its
+// VM restarted and its node, out of the given nodes, rejoined. The machines being deleted are left out, the
+// machineset replaces them.
+func selectRestarts(machines []machinev1.Machine, nodes map[string]*corev1.Node, token string, maxUnavailable int) ([]*machinev1.Machine, bool) {
+	unavailable := 0
+	var pending []*machinev1.Machine
+	for i := range machines {
+		machine := &machines[i]
+		if machine.DeletionTimestamp != nil {
+			continue
+		}
+		if machine.Annotations[RestartedAnnotation] == token {
+			if !nodeRejoined(machine, nodes) {
+				unavailable++
+			}
+			continue
+		}
+		running := machine.Status.Phase != nil && *machine.Status.Phase == machinePhaseRunning
+		if machine.Annotations[RestartAnnotation] == token || !running {
+			unavailable++
+			continue
+		}
+		pending = append(pending, machine)
+	}
+	if unavailable == 0 && len(pending) == 0 {
+		return nil, true
+	}
+
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Name < pending[j].Name })
+	available := maxUnavailable - unavailable
+	if available <= 0 {
+		return nil, false
+	}
+	if available < len(pending) {
+		pending = pending[:available]
+	}
+	return pending, false
+}
+
+// nodeRejoined returns whether the node of the machine is ready, and turned ready after the restart was
+// requested. The VM being ready again does not mean that the node rejoined the cluster yet.
+func nodeRejoined(machine *machinev1.Machine, nodes map[string]*corev1.Node) bool {
+	if machine.Status.NodeRef == nil {
+		return false
+	}
+	node, ok := nodes[machine.Status.NodeRef.Name]
+	if !ok {
+		return false
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type != corev1.NodeReady {
+			continue
+		}
+		if condition.Status != corev1.ConditionTrue {
+			return false
+		}
+		requestedAt, err := time.Parse(time.RFC3339, machine.Annotations[RestartRequestedAtAnnotation])
+		if err != nil {
+			// Restart not requested by the restarter, the time it restarted is unknown
+			return true
+		}
+		return condition.LastTransitionTime.Time.After(requestedAt)
+	}
+	return false
+}
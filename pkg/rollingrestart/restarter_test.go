@@ -0,0 +1,177 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollingrestart
+
+import (
+	"testing"
+	"time"
+
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseMaxUnavailable(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr string
+	}{
+		{
+			name: "Default",
+			want: 1,
+		},
+		{
+			name:  "Set",
+			value: "3",
+			want:  3,
+		},
+		{
+			name:    "Zero",
+			value:   "0",
+			wantErr: `invalid kubevirt.io/restart-max-unavailable "0", expected a positive integer`,
+		},
+		{
+			name:    "Percentage",
+			value:   "25%",
+			wantErr: `invalid kubevirt.io/restart-max-unavailable "25%", expected a positive integer`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			maxUnavailable, err := parseMaxUnavailable(tc.value)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+				assert.Equal(t, maxUnavailable, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectRestarts(t *testing.T) {
+	const token = "1"
+	stubMachine := func(name, phase string, annotations map[string]string) machinev1.Machine {
+		return machinev1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations},
+			Status:     machinev1.MachineStatus{Phase: &phase, NodeRef: &corev1.ObjectReference{Name: name}},
+		}
+	}
+	requestedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	stubNodes := func(name string, status corev1.ConditionStatus, transition time.Time) map[string]*corev1.Node {
+		return map[string]*corev1.Node{name: {
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{{
+				Type:               corev1.NodeReady,
+				Status:             status,
+				LastTransitionTime: metav1.NewTime(transition),
+			}}},
+		}}
+	}
+	requested := map[string]string{RestartAnnotation: token}
+	restarted := map[string]string{
+		RestartAnnotation:            token,
+		RestartedAnnotation:          token,
+		RestartRequestedAtAnnotation: requestedAt.Format(time.RFC3339),
+	}
+	deleted := stubMachine("deleted", machinePhaseRunning, nil)
+	deleted.DeletionTimestamp = &metav1.Time{}
+
+	cases := []struct {
+		name           string
+		machines       []machinev1.Machine
+		nodes          map[string]*corev1.Node
+		maxUnavailable int
+		want           []string
+		wantDone       bool
+	}{
+		{
+			name:           "Start with the first machine",
+			machines:       []machinev1.Machine{stubMachine("b", machinePhaseRunning, nil), stubMachine("a", machinePhaseRunning, nil)},
+			maxUnavailable: 1,
+			want:           []string{"a"},
+		},
+		{
+			name:           "Several at a time",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, nil), stubMachine("b", machinePhaseRunning, nil), stubMachine("c", machinePhaseRunning, nil)},
+			maxUnavailable: 2,
+			want:           []string{"a", "b"},
+		},
+		{
+			name:           "Wait for the restarting machine",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, requested), stubMachine("b", machinePhaseRunning, nil)},
+			maxUnavailable: 1,
+		},
+		{
+			name:           "Move on once restarted and the node rejoined",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, restarted), stubMachine("b", machinePhaseRunning, nil)},
+			nodes:          stubNodes("a", corev1.ConditionTrue, requestedAt.Add(time.Minute)),
+			maxUnavailable: 1,
+			want:           []string{"b"},
+		},
+		{
+			name:           "Wait for the node of the restarted machine",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, restarted), stubMachine("b", machinePhaseRunning, nil)},
+			nodes:          stubNodes("a", corev1.ConditionFalse, requestedAt.Add(time.Minute)),
+			maxUnavailable: 1,
+		},
+		{
+			name:           "Wait for the node ready since before the restart",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, restarted), stubMachine("b", machinePhaseRunning, nil)},
+			nodes:          stubNodes("a", corev1.ConditionTrue, requestedAt.Add(-time.Minute)),
+			maxUnavailable: 1,
+		},
+		{
+			name:           "Wait for the node not found",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, restarted), stubMachine("b", machinePhaseRunning, nil)},
+			maxUnavailable: 1,
+		},
+		{
+			name:           "Machine not running counts as unavailable",
+			machines:       []machinev1.Machine{stubMachine("a", "Provisioning", nil), stubMachine("b", machinePhaseRunning, nil), stubMachine("c", machinePhaseRunning, nil)},
+			maxUnavailable: 2,
+			want:           []string{"b"},
+		},
+		{
+			name:           "Previous restart",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, map[string]string{RestartAnnotation: "0", RestartedAnnotation: "0"})},
+			maxUnavailable: 1,
+			want:           []string{"a"},
+		},
+		{
+			name:           "Done",
+			machines:       []machinev1.Machine{stubMachine("a", machinePhaseRunning, restarted), deleted},
+			nodes:          stubNodes("a", corev1.ConditionTrue, requestedAt.Add(time.Minute)),
+			maxUnavailable: 1,
+			wantDone:       true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			toRestart, done := selectRestarts(tc.machines, tc.nodes, token, tc.maxUnavailable)
+			var names []string
+			for _, machine := range toRestart {
+				names = append(names, machine.Name)
+			}
+			assert.DeepEqual(t, names, tc.want)
+			assert.Equal(t, done, tc.wantDone)
+		})
+	}
+}
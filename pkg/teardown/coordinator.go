@@ -0,0 +1,407 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package teardown deletes the machines of a tenant cluster in order, the workers before the control plane
+// so the workloads drain while the API server still runs, and waits for the underkube resources of the
+// cluster to be gone. The machines wait for the cleanup of their own VMs on deletion, the teardown checks
+// nothing of the cluster was left behind. The underkube namespaces aren't created by the provider, they are
+// left to whoever created them.
+package teardown
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// TeardownLabel set on a ConfigMap of the namespace of the machines requests the teardown of the cluster
+	// whose ID it holds. The progress of the teardown is written to the ConfigMap, which is kept once the
+	// teardown is complete.
+	TeardownLabel = "kubevirt.io/teardown-cluster"
+	// progressKey holds the Progress in json in the teardown ConfigMap
+	progressKey = "progress.json"
+
+	// controlPlaneRole is the role of the control plane machines
+	controlPlaneRole = "master"
+
+	// interval is how often the teardowns progress
+	interval = 30 * time.Second
+)
+
+// Phase is a step of the teardown of a cluster
+type Phase string
+
+const (
+	// DeletingWorkersPhase deletes the machinesets and machines of the cluster, but the control plane ones
+	DeletingWorkersPhase Phase = "DeletingWorkers"
+	// DeletingControlPlanePhase deletes the control plane machinesets and machines, once the workers are gone
+	DeletingControlPlanePhase Phase = "DeletingControlPlane"
+	// CleaningUpInfraPhase waits for the underkube resources of the cluster to be gone, once all the
+	// machines are
+	CleaningUpInfraPhase Phase = "CleaningUpInfra"
+	// CompletePhase is reached when nothing of the cluster is left
+	CompletePhase Phase = "Complete"
+)
+
+// Progress is the progress of the teardown of a cluster
+type Progress struct {
+	ClusterID string `json:"clusterID"`
+	Phase     Phase  `json:"phase"`
+	// Machines lists the machines of the phase not deleted yet
+	Machines []string `json:"machines,omitempty"`
+	// InfraResources lists, as kind namespace/name, the underkube resources of the cluster not deleted yet
+	InfraResources []string `json:"infraResources,omitempty"`
+	// Infras are the underkube namespaces the machines of the cluster ran in, recorded before the machines
+	// are deleted so they can be checked once the machines are gone
+	Infras []Infra `json:"infras,omitempty"`
+	// UncheckedInfras lists, with the reason, the machines whose underkube namespace couldn't be resolved
+	// and the infras whose underkube couldn't be reached. Nothing is checked there, the teardown completes
+	// without them.
+	UncheckedInfras []string `json:"uncheckedInfras,omitempty"`
+	// Error is the error of the last attempt to progress, empty when it succeeded
+	Error      string      `json:"error,omitempty"`
+	UpdateTime metav1.Time `json:"updateTime"`
+}
+
+// Infra is an underkube namespace of the VMs of a cluster
+type Infra struct {
+	// SecretName is the credentials secret of the underkube
+	SecretName string `json:"secretName"`
+	// Namespace of the VMs in the underkube
+	Namespace string `json:"namespace"`
+}
+
+// Coordinator runs the teardowns requested by the teardown ConfigMaps
+type Coordinator struct {
+	client                 client.Client
+	apiReader              client.Reader
+	namespace              string
+	overkubeClient         overkube.Client
+	underkubeClientBuilder underkube.ClientBuilderFuncType
+	providerConfig         *providerconfig.Store
+}
+
+// New creates a coordinator, to be added to the manager as a runnable. Only the teardown ConfigMaps of the
+// namespace of the machines are read, all the namespaces when it's empty. They are listed with the API
+// reader, the cache of the manager would hold all the ConfigMaps of the namespace. The provider config
+// store holds the default credentials secret of the machines.
+func New(client client.Client, apiReader client.Reader, namespace string, overkubeClient overkube.Client, underkubeClientBuilder underkube.ClientBuilderFuncType, providerConfig *providerconfig.Store) *Coordinator {
+	return &Coordinator{
+		client:                 client,
+		apiReader:              apiReader,
+		namespace:              namespace,
+		overkubeClient:         overkubeClient,
+		underkubeClientBuilder: underkubeClientBuilder,
+		providerConfig:         providerConfig,
+	}
+}
+
+// Start runs the coordinator until the stop channel is closed
+func (c *Coordinator) Start(stop <-chan struct{}) error {
+	wait.Until(func() {
+		if err := c.teardownAll(); err != nil {
+			klog.Errorf("failed to run the cluster teardowns: %v", err)
+		}
+	}, interval, stop)
+	return nil
+}
+
+func (c *Coordinator) teardownAll() error {
+	configMaps := &corev1.ConfigMapList{}
+	if err := c.apiReader.List(context.Background(), configMaps, client.InNamespace(c.namespace), client.HasLabels{TeardownLabel}); err != nil {
+		return err
+	}
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		if err := c.teardownCluster(configMap); err != nil {
+			klog.Errorf("%s/%s: failed to write the teardown progress: %v", configMap.Namespace, configMap.Name, err)
+		}
+	}
+	return nil
+}
+
+// teardownCluster moves the teardown of the ConfigMap forward and writes its progress. The failures to
+// progress are reported in the progress, and retried on the next run.
+func (c *Coordinator) teardownCluster(configMap *corev1.ConfigMap) error {
+	progress := &Progress{}
+	if data, ok := configMap.Data[progressKey]; ok {
+		if err := json.Unmarshal([]byte(data), progress); err != nil {
+			klog.Warningf("%s/%s: ignoring the invalid teardown progress: %v", configMap.Namespace, configMap.Name, err)
+			progress = &Progress{}
+		}
+	}
+	clusterID := configMap.Labels[TeardownLabel]
+	if progress.Phase == CompletePhase && progress.ClusterID == clusterID {
+		return nil
+	}
+	if progress.ClusterID != clusterID {
+		progress = &Progress{ClusterID: clusterID}
+	}
+
+	origin := *progress
+	progress.Error = ""
+	if err := c.progress(configMap.Namespace, progress); err != nil {
+		progress.Error = err.Error()
+	}
+	if equality.Semantic.DeepEqual(*progress, origin) {
+		return nil
+	}
+	if progress.Phase != origin.Phase {
+		klog.Infof("%s/%s: teardown of cluster %s: %s", configMap.Namespace, configMap.Name, clusterID, progress.Phase)
+	}
+
+	progress.UpdateTime = metav1.Now()
+	data, err := json.MarshalIndent(progress, "", "  ")
+	if err != nil {
+		return err
+	}
+	originConfigMap := configMap.DeepCopy()
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[progressKey] = string(data)
+	return c.client.Patch(context.Background(), configMap, client.MergeFrom(originConfigMap))
+}
+
+// progress deletes the machinesets and machines of the current phase, or checks the underkube resources
+// once the machines are gone
+func (c *Coordinator) progress(namespace string, progress *Progress) error {
+	if progress.ClusterID == "" {
+		return fmt.Errorf("the %s label holds no cluster ID", TeardownLabel)
+	}
+	machineSets := &machinev1.MachineSetList{}
+	if err := c.client.List(context.Background(), machineSets, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	machines := &machinev1.MachineList{}
+	if err := c.client.List(context.Background(), machines, client.InNamespace(namespace), client.MatchingLabels{machinev1.MachineClusterIDLabel: progress.ClusterID}); err != nil {
+		return err
+	}
+	c.recordInfras(machines.Items, progress)
+
+	phase, phaseMachineSets, phaseMachines := planTeardown(machineSets.Items, machines.Items, progress.ClusterID)
+	progress.Phase = phase
+	progress.Machines = nil
+	for _, machineSet := range phaseMachineSets {
+		if machineSet.DeletionTimestamp != nil {
+			continue
+		}
+		klog.Infof("%s: deleting machineset %s of cluster %s", namespace, machineSet.Name, progress.ClusterID)
+		if err := c.client.Delete(context.Background(), machineSet); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete machineset %s: %w", machineSet.Name, err)
+		}
+	}
+	for _, machine := range phaseMachines {
+		progress.Machines = append(progress.Machines, machine.Name)
+		if machine.DeletionTimestamp != nil {
+			continue
+		}
+		klog.Infof("%s: deleting machine %s of cluster %s", namespace, machine.Name, progress.ClusterID)
+		if err := c.client.Delete(context.Background(), machine); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete machine %s: %w", machine.Name, err)
+		}
+	}
+	if phase != CleaningUpInfraPhase {
+		return nil
+	}
+
+	progress.InfraResources = nil
+	for _, infra := range progress.Infras {
+		underkubeClient, err := c.underkubeClientBuilder(c.overkubeClient, infra.SecretName, namespace)
+		if err != nil {
+			recordUncheckedInfra(progress, fmt.Sprintf("namespace %s of secret %s: failed to get the underkube client: %v", infra.Namespace, infra.SecretName, err))
+			continue
+		}
+		remaining, err := remainingInfraResources(underkubeClient, progress.ClusterID, infra.Namespace)
+		if err != nil {
+			return err
+		}
+		progress.InfraResources = append(progress.InfraResources, remaining...)
+	}
+	if len(progress.InfraResources) == 0 {
+		progress.Phase = CompletePhase
+	}
+	return nil
+}
+
+// recordInfras adds the underkube namespaces of the machines to the infras of the progress. The machines
+// whose namespace can't be resolved are recorded as unchecked, so they don't hold the teardown back.
+func (c *Coordinator) recordInfras(machines []machinev1.Machine, progress *Progress) {
+	recorded := map[Infra]bool{}
+	for _, infra := range progress.Infras {
+		recorded[infra] = true
+	}
+	for i := range machines {
+		machine := &machines[i]
+		providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(machine.Spec.ProviderSpec.Value)
+		if err != nil {
+			klog.V(3).Infof("%s: failed to get the provider spec: %v", machine.GetName(), err)
+			continue
+		}
+		secretName, err := providerconfig.UnderKubeconfigSecretName(machine, providerSpec, c.providerConfig.Get())
+		if err != nil {
+			recordUncheckedInfra(progress, fmt.Sprintf("machine %s: %v", machine.GetName(), err))
+			continue
+		}
+		underkubeClient, err := c.underkubeClientBuilder(c.overkubeClient, secretName, machine.Namespace)
+		if err != nil {
+			recordUncheckedInfra(progress, fmt.Sprintf("machine %s: failed to get the underkube client of secret %s: %v", machine.GetName(), secretName, err))
+			continue
+		}
+		providerStatus, err := kubevirtproviderv1.ProviderStatusFromRawExtension(machine.Status.ProviderStatus)
+		if err != nil {
+			klog.V(3).Infof("%s: failed to get the provider status: %v", machine.GetName(), err)
+		}
+		infra := Infra{
+			SecretName: secretName,
+			Namespace:  render.MachineVMNamespace(machine, providerSpec, providerStatus, underkubeClient.DefaultNamespace()),
+		}
+		if !recorded[infra] {
+			recorded[infra] = true
+			progress.Infras = append(progress.Infras, infra)
+		}
+	}
+	sort.Slice(progress.Infras, func(i, j int) bool {
+		if progress.Infras[i].SecretName != progress.Infras[j].SecretName {
+			return progress.Infras[i].SecretName < progress.Infras[j].SecretName
+		}
+		return progress.Infras[i].Namespace < progress.Infras[j].Namespace
+	})
+}
+
+// recordUncheckedInfra adds the unchecked infra to the progress, unless it's already there
+func recordUncheckedInfra(progress *Progress, unchecked string) {
+	for _, recorded := range progress.UncheckedInfras {
+		if recorded == unchecked {
+			return
+		}
+	}
+	klog.Warningf("teardown of cluster %s: not checking %s", progress.ClusterID, unchecked)
+	progress.UncheckedInfras = append(progress.UncheckedInfras, unchecked)
+}
+
+// planTeardown returns the phase of the teardown of the cluster, with the machinesets and machines to delete
+// in it. The machinesets of the cluster are the ones creating its machines.
+func planTeardown(machineSets []machinev1.MachineSet, machines []machinev1.Machine, clusterID string) (Phase, []*machinev1.MachineSet, []*machinev1.Machine) {
+	var workerMachineSets, controlPlaneMachineSets []*machinev1.MachineSet
+	for i := range machineSets {
+		machineSet := &machineSets[i]
+		labels := machineSet.Spec.Template.Labels
+		if labels[machinev1.MachineClusterIDLabel] != clusterID {
+			continue
+		}
+		if labels[render.MachineRoleLabel] == controlPlaneRole {
+			controlPlaneMachineSets = append(controlPlaneMachineSets, machineSet)
+		} else {
+			workerMachineSets = append(workerMachineSets, machineSet)
+		}
+	}
+	var workerMachines, controlPlaneMachines []*machinev1.Machine
+	for i := range machines {
+		machine := &machines[i]
+		if machine.Labels[render.MachineRoleLabel] == controlPlaneRole {
+			controlPlaneMachines = append(controlPlaneMachines, machine)
+		} else {
+			workerMachines = append(workerMachines, machine)
+		}
+	}
+
+	switch {
+	case len(workerMachineSets) > 0 || len(workerMachines) > 0:
+		return DeletingWorkersPhase, workerMachineSets, workerMachines
+	case len(controlPlaneMachineSets) > 0 || len(controlPlaneMachines) > 0:
+		return DeletingControlPlanePhase, controlPlaneMachineSets, controlPlaneMachines
+	}
+	return CleaningUpInfraPhase, nil, nil
+}
+
+// remainingInfraResources lists, as kind namespace/name, the underkube resources the provider created for the
+// cluster in the namespace. The data volumes and boot volumes are owned by the VMs, which are deleted in the
+// foreground.
+func remainingInfraResources(underkubeClient underkube.Client, clusterID, namespace string) ([]string, error) {
+	options := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", machinev1.MachineClusterIDLabel, clusterID)}
+	var remaining []string
+	add := func(kind string, meta metav1.ObjectMeta) {
+		remaining = append(remaining, fmt.Sprintf("%s %s/%s", kind, meta.Namespace, meta.Name))
+	}
+
+	vms, err := underkubeClient.ListVirtualMachine(namespace, &options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the VMs of namespace %s: %w", namespace, err)
+	}
+	for _, vm := range vms.Items {
+		add("VirtualMachine", vm.ObjectMeta)
+	}
+	services, err := underkubeClient.ListServices(namespace, options)
+	if err := ignoreForbidden(err, "services", namespace); err != nil {
+		return nil, err
+	}
+	if services != nil {
+		for _, service := range services.Items {
+			add("Service", service.ObjectMeta)
+		}
+	}
+	secrets, err := underkubeClient.ListSecrets(namespace, options)
+	if err := ignoreForbidden(err, "secrets", namespace); err != nil {
+		return nil, err
+	}
+	if secrets != nil {
+		for _, secret := range secrets.Items {
+			add("Secret", secret.ObjectMeta)
+		}
+	}
+	ingresses, err := underkubeClient.ListIngresses(namespace, options)
+	if err := ignoreForbidden(err, "ingresses", namespace); err != nil {
+		return nil, err
+	}
+	if ingresses != nil {
+		for _, ingress := range ingresses.Items {
+			add("Ingress", ingress.ObjectMeta)
+		}
+	}
+	return remaining, nil
+}
+
+// ignoreForbidden wraps the error of the list of the resources of the namespace, nil when the credentials
+// aren't allowed to list them: the provider only creates the services, secrets and ingresses it's allowed
+// to, so none are left where they can't be listed.
+func ignoreForbidden(err error, resources, namespace string) error {
+	if apimachineryerrors.IsForbidden(err) {
+		klog.V(3).Infof("not checking the %s of namespace %s: %v", resources, namespace, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list the %s of namespace %s: %w", resources, namespace, err)
+	}
+	return nil
+}
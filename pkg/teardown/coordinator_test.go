@@ -0,0 +1,209 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package teardown
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/overkube"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube"
+	mockunderkube "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/clients/underkube/mock"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/render"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtapiv1 "kubevirt.io/client-go/api/v1"
+)
+
+const clusterID = "tenant-1"
+
+func TestPlanTeardown(t *testing.T) {
+	stubMachineSet := func(name, clusterID, role string) machinev1.MachineSet {
+		machineSet := machinev1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		machineSet.Spec.Template.Labels = map[string]string{machinev1.MachineClusterIDLabel: clusterID, render.MachineRoleLabel: role}
+		return machineSet
+	}
+	stubMachine := func(name, role string) machinev1.Machine {
+		return machinev1.Machine{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{machinev1.MachineClusterIDLabel: clusterID, render.MachineRoleLabel: role},
+		}}
+	}
+
+	cases := []struct {
+		name            string
+		machineSets     []machinev1.MachineSet
+		machines        []machinev1.Machine
+		wantPhase       Phase
+		wantMachineSets []string
+		wantMachines    []string
+	}{
+		{
+			name:            "Workers first",
+			machineSets:     []machinev1.MachineSet{stubMachineSet("workers", clusterID, "worker"), stubMachineSet("other-workers", "tenant-2", "worker")},
+			machines:        []machinev1.Machine{stubMachine("master-0", controlPlaneRole), stubMachine("workers-a", "worker"), stubMachine("infra-0", "infra")},
+			wantPhase:       DeletingWorkersPhase,
+			wantMachineSets: []string{"workers"},
+			wantMachines:    []string{"workers-a", "infra-0"},
+		},
+		{
+			name:            "Worker machinesets without machines",
+			machineSets:     []machinev1.MachineSet{stubMachineSet("workers", clusterID, "worker")},
+			machines:        []machinev1.Machine{stubMachine("master-0", controlPlaneRole)},
+			wantPhase:       DeletingWorkersPhase,
+			wantMachineSets: []string{"workers"},
+		},
+		{
+			name:            "Control plane once the workers are gone",
+			machineSets:     []machinev1.MachineSet{stubMachineSet("masters", clusterID, controlPlaneRole)},
+			machines:        []machinev1.Machine{stubMachine("master-0", controlPlaneRole), stubMachine("master-1", controlPlaneRole)},
+			wantPhase:       DeletingControlPlanePhase,
+			wantMachineSets: []string{"masters"},
+			wantMachines:    []string{"master-0", "master-1"},
+		},
+		{
+			name:        "Infra cleanup once the machines are gone",
+			machineSets: []machinev1.MachineSet{stubMachineSet("other-workers", "tenant-2", "worker")},
+			wantPhase:   CleaningUpInfraPhase,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			phase, machineSets, machines := planTeardown(tc.machineSets, tc.machines, clusterID)
+			assert.Equal(t, phase, tc.wantPhase)
+			var machineSetNames, machineNames []string
+			for _, machineSet := range machineSets {
+				machineSetNames = append(machineSetNames, machineSet.Name)
+			}
+			for _, machine := range machines {
+				machineNames = append(machineNames, machine.Name)
+			}
+			assert.DeepEqual(t, machineSetNames, tc.wantMachineSets)
+			assert.DeepEqual(t, machineNames, tc.wantMachines)
+		})
+	}
+}
+
+func TestRemainingInfraResources(t *testing.T) {
+	const namespace = "vms"
+	options := metav1.ListOptions{LabelSelector: machinev1.MachineClusterIDLabel + "=" + clusterID}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Namespace: namespace, Name: name}
+	}
+
+	t.Run("Resources left", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+		mockUnderkube.EXPECT().ListVirtualMachine(namespace, &options).Return(&kubevirtapiv1.VirtualMachineList{
+			Items: []kubevirtapiv1.VirtualMachine{{ObjectMeta: meta("master-0")}},
+		}, nil)
+		mockUnderkube.EXPECT().ListServices(namespace, options).Return(&corev1.ServiceList{
+			Items: []corev1.Service{{ObjectMeta: meta("workers-machines")}},
+		}, nil)
+		mockUnderkube.EXPECT().ListSecrets(namespace, options).Return(&corev1.SecretList{
+			Items: []corev1.Secret{{ObjectMeta: meta("master-0-userdata")}},
+		}, nil)
+		mockUnderkube.EXPECT().ListIngresses(namespace, options).Return(&networkingv1beta1.IngressList{}, nil)
+
+		remaining, err := remainingInfraResources(mockUnderkube, clusterID, namespace)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, remaining, []string{
+			"VirtualMachine vms/master-0",
+			"Service vms/workers-machines",
+			"Secret vms/master-0-userdata",
+		})
+	})
+
+	t.Run("Forbidden lists skipped", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+		forbidden := func(resource string) error {
+			return apimachineryerrors.NewForbidden(schema.GroupResource{Resource: resource}, "", fmt.Errorf("not allowed"))
+		}
+		mockUnderkube.EXPECT().ListVirtualMachine(namespace, &options).Return(&kubevirtapiv1.VirtualMachineList{}, nil)
+		mockUnderkube.EXPECT().ListServices(namespace, options).Return(nil, forbidden("services"))
+		mockUnderkube.EXPECT().ListSecrets(namespace, options).Return(&corev1.SecretList{
+			Items: []corev1.Secret{{ObjectMeta: meta("master-0-userdata")}},
+		}, nil)
+		mockUnderkube.EXPECT().ListIngresses(namespace, options).Return(nil, forbidden("ingresses"))
+
+		remaining, err := remainingInfraResources(mockUnderkube, clusterID, namespace)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, remaining, []string{"Secret vms/master-0-userdata"})
+	})
+
+	t.Run("List fails", func(t *testing.T) {
+		mockCtrl := gomock.NewController(t)
+		defer mockCtrl.Finish()
+		mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+		mockUnderkube.EXPECT().ListVirtualMachine(namespace, &options).Return(nil, fmt.Errorf("forbidden"))
+
+		_, err := remainingInfraResources(mockUnderkube, clusterID, namespace)
+		assert.Error(t, err, "failed to list the VMs of namespace vms: forbidden")
+	})
+}
+
+func TestRecordInfras(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	mockUnderkube := mockunderkube.NewMockClient(mockCtrl)
+	mockUnderkube.EXPECT().DefaultNamespace().Return("vms").AnyTimes()
+
+	machine := func(name, secretName string, annotations map[string]string) machinev1.Machine {
+		providerSpec, err := kubevirtproviderv1.RawExtensionFromProviderSpec(&kubevirtproviderv1.KubevirtMachineProviderSpec{
+			UnderKubeconfigSecretName: secretName,
+		})
+		assert.NilError(t, err)
+		m := machinev1.Machine{}
+		m.Name = name
+		m.Namespace = "openshift-machine-api"
+		m.Annotations = annotations
+		m.Spec.ProviderSpec.Value = providerSpec
+		return m
+	}
+	coordinator := New(nil, nil, "openshift-machine-api", nil, func(_ overkube.Client, secretName, _ string) (underkube.Client, error) {
+		if secretName == "deleted" {
+			return nil, fmt.Errorf("secret deleted not found")
+		}
+		return mockUnderkube, nil
+	}, providerconfig.NewStore())
+
+	progress := &Progress{ClusterID: clusterID}
+	machines := []machinev1.Machine{
+		machine("master-0", "underkube", nil),
+		machine("worker-0", "deleted", nil),
+		machine("worker-1", "underkube", map[string]string{providerconfig.CredentialsSecretAnnotation: "other"}),
+	}
+	coordinator.recordInfras(machines, progress)
+	coordinator.recordInfras(machines, progress)
+
+	assert.DeepEqual(t, progress.Infras, []Infra{{SecretName: "underkube", Namespace: "vms"}})
+	assert.DeepEqual(t, progress.UncheckedInfras, []string{
+		"machine worker-0: failed to get the underkube client of secret deleted: secret deleted not found",
+		`machine worker-1: the credentials secret "other" of the kubevirt.io/underkube-credentials-secret annotation isn't one of the credentialsOverrideSecrets of the KubevirtProviderConfig`,
+	})
+}
@@ -17,4 +17,36 @@ var (
 
 	// String is the human-friendly representation of the version.
 	String = fmt.Sprintf("ClusterAPIProviderKubevirt %s", Raw)
+
+	// SupportedKubeVirt is the range of infra KubeVirt versions serving the v1alpha3 API the provider
+	// vendors. The newer releases keep serving it, only the older ones lack it.
+	SupportedKubeVirt = ">=0.26.0"
+
+	// TestedKubeVirt is the range of infra KubeVirt versions the provider is tested with. Keep it in sync
+	// with kubevirt.io/client-go in go.mod.
+	TestedKubeVirt = ">=0.26.0 <0.35.0"
+
+	supportedKubeVirtRange = semver.MustParseRange(SupportedKubeVirt)
+	testedKubeVirtRange    = semver.MustParseRange(TestedKubeVirt)
 )
+
+// IsSupportedKubeVirt returns whether the KubeVirt git version, e.g. v0.29.2, is in the SupportedKubeVirt range
+func IsSupportedKubeVirt(gitVersion string) (bool, error) {
+	return inKubeVirtRange(gitVersion, supportedKubeVirtRange)
+}
+
+// IsTestedKubeVirt returns whether the KubeVirt git version, e.g. v0.29.2, is in the TestedKubeVirt range
+func IsTestedKubeVirt(gitVersion string) (bool, error) {
+	return inKubeVirtRange(gitVersion, testedKubeVirtRange)
+}
+
+func inKubeVirtRange(gitVersion string, kubeVirtRange semver.Range) (bool, error) {
+	v, err := semver.ParseTolerant(gitVersion)
+	if err != nil {
+		return false, err
+	}
+	// Compare the release only, so v0.29.2-rc.1 or v0.29.2-12-gabcdef builds are in their release range
+	v.Pre = nil
+	v.Build = nil
+	return kubeVirtRange(v), nil
+}
@@ -0,0 +1,50 @@
+package version
+
+import (
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestIsSupportedKubeVirt(t *testing.T) {
+	cases := []struct {
+		gitVersion    string
+		wantSupported bool
+		wantErr       bool
+	}{
+		{gitVersion: "v0.29.2", wantSupported: true},
+		{gitVersion: "v0.26.0-rc.0", wantSupported: true},
+		{gitVersion: "v0.30.1-12-gabcdef0", wantSupported: true},
+		{gitVersion: "v0.25.0"},
+		{gitVersion: "v0.35.0", wantSupported: true},
+		{gitVersion: "v0.0.0-master+$Format:%h$", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.gitVersion, func(t *testing.T) {
+			supported, err := IsSupportedKubeVirt(tc.gitVersion)
+			assert.Equal(t, err != nil, tc.wantErr)
+			assert.Equal(t, supported, tc.wantSupported)
+		})
+	}
+}
+
+func TestIsTestedKubeVirt(t *testing.T) {
+	cases := []struct {
+		gitVersion string
+		wantTested bool
+		wantErr    bool
+	}{
+		{gitVersion: "v0.29.2", wantTested: true},
+		{gitVersion: "v0.34.2-rc.0", wantTested: true},
+		{gitVersion: "v0.25.0"},
+		{gitVersion: "v0.35.0"},
+		{gitVersion: "v0.0.0-master+$Format:%h$", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.gitVersion, func(t *testing.T) {
+			tested, err := IsTestedKubeVirt(tc.gitVersion)
+			assert.Equal(t, err != nil, tc.wantErr)
+			assert.Equal(t, tested, tc.wantTested)
+		})
+	}
+}
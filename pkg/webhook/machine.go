@@ -0,0 +1,125 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package webhook serves the admission webhook validating the machines, so an invalid machine is refused
+// when it is written rather than failed by the actuator.
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// MachineValidationPath is the path the ValidatingWebhookConfiguration of the machines must call
+const MachineValidationPath = "/validate-machine"
+
+// machineValidator refuses the machines whose credentials secret override isn't allowed by the provider
+// configuration, names a secret missing from the namespace of the machine, or changes once the VM of the
+// machine is created
+type machineValidator struct {
+	reader         client.Reader
+	providerConfig *providerconfig.Store
+	decoder        *admission.Decoder
+}
+
+// Add registers the machine validation on the webhook server of the manager. The secrets are read from
+// the API server rather than from the cache, not to watch all of them.
+func Add(mgr manager.Manager, providerConfig *providerconfig.Store) {
+	validator := &machineValidator{reader: mgr.GetAPIReader(), providerConfig: providerConfig}
+	mgr.GetWebhookServer().Register(MachineValidationPath, &webhook.Admission{Handler: validator})
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *machineValidator) InjectDecoder(decoder *admission.Decoder) error {
+	v.decoder = decoder
+	return nil
+}
+
+// Handle implements admission.Handler
+func (v *machineValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	machine := &machinev1.Machine{}
+	if err := v.decoder.Decode(req, machine); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	if req.Operation == admissionv1beta1.Update {
+		oldMachine := &machinev1.Machine{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldMachine); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if err := validateCredentialsOverrideUpdate(oldMachine, machine); err != nil {
+			klog.Infof("%s: refusing the machine update: %v", machine.GetName(), err)
+			return admission.Denied(err.Error())
+		}
+	}
+	if err := v.validateCredentialsOverride(ctx, machine, v.providerConfig.Get()); err != nil {
+		klog.Infof("%s: refusing the machine: %v", machine.GetName(), err)
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
+
+// validateCredentialsOverrideUpdate refuses to change the credentials secret annotation of a machine once it
+// has a provider ID, its VM would be looked up on another underkube
+func validateCredentialsOverrideUpdate(oldMachine, machine *machinev1.Machine) error {
+	if oldMachine.Spec.ProviderID == nil || *oldMachine.Spec.ProviderID == "" {
+		return nil
+	}
+	oldSecretName, hadOverride := oldMachine.Annotations[providerconfig.CredentialsSecretAnnotation]
+	secretName, hasOverride := machine.Annotations[providerconfig.CredentialsSecretAnnotation]
+	if hadOverride != hasOverride || oldSecretName != secretName {
+		return fmt.Errorf("the %s annotation can't change once the machine has a provider ID", providerconfig.CredentialsSecretAnnotation)
+	}
+	return nil
+}
+
+// validateCredentialsOverride checks the credentials secret the annotation of the machine names is allowed,
+// and exists. The machines without the annotation are left to the actuator.
+func (v *machineValidator) validateCredentialsOverride(ctx context.Context, machine *machinev1.Machine, spec kubevirtproviderv1.KubevirtProviderConfigSpec) error {
+	if _, ok := machine.Annotations[providerconfig.CredentialsSecretAnnotation]; !ok {
+		return nil
+	}
+	providerSpec, err := kubevirtproviderv1.ProviderSpecFromRawExtension(machine.Spec.ProviderSpec.Value)
+	if err != nil {
+		return fmt.Errorf("failed to get the provider spec: %v", err)
+	}
+	secretName, err := providerconfig.UnderKubeconfigSecretName(machine, providerSpec, spec)
+	if err != nil {
+		return err
+	}
+	secret := &corev1.Secret{}
+	err = v.reader.Get(ctx, types.NamespacedName{Namespace: machine.GetNamespace(), Name: secretName}, secret)
+	if apimachineryerrors.IsNotFound(err) {
+		return fmt.Errorf("the credentials secret %s/%s doesn't exist", machine.GetNamespace(), secretName)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get the credentials secret %s/%s: %v", machine.GetNamespace(), secretName, err)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2026 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	kubevirtproviderv1 "github.com/kubevirt/cluster-api-provider-kubevirt/pkg/apis/kubevirtprovider/v1"
+	"github.com/kubevirt/cluster-api-provider-kubevirt/pkg/providerconfig"
+	machinev1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	"gotest.tools/assert"
+	apimachineryerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// secretReader is a client.Reader finding the secrets of its set
+type secretReader map[client.ObjectKey]bool
+
+func (r secretReader) Get(_ context.Context, key client.ObjectKey, _ runtime.Object) error {
+	if !r[key] {
+		return apimachineryerrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, key.Name)
+	}
+	return nil
+}
+
+func (r secretReader) List(context.Context, runtime.Object, ...client.ListOption) error {
+	return nil
+}
+
+func TestValidateCredentialsOverride(t *testing.T) {
+	providerSpec, err := kubevirtproviderv1.RawExtensionFromProviderSpec(&kubevirtproviderv1.KubevirtMachineProviderSpec{UnderKubeconfigSecretName: "kubeconfig"})
+	assert.NilError(t, err)
+	validator := &machineValidator{
+		reader: secretReader{
+			{Namespace: "openshift-machine-api", Name: "new-infra-kubeconfig"}: true,
+		},
+	}
+
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		allowed     []string
+		wantErr     string
+	}{
+		{
+			name: "No override",
+		},
+		{
+			name:        "Allowed existing secret",
+			annotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"},
+			allowed:     []string{"new-infra-kubeconfig", "missing-kubeconfig"},
+		},
+		{
+			name:        "Secret not allowed",
+			annotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"},
+			wantErr:     `the credentials secret "new-infra-kubeconfig" of the kubevirt.io/underkube-credentials-secret annotation isn't one of the credentialsOverrideSecrets of the KubevirtProviderConfig`,
+		},
+		{
+			name:        "Missing secret",
+			annotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "missing-kubeconfig"},
+			allowed:     []string{"new-infra-kubeconfig", "missing-kubeconfig"},
+			wantErr:     "the credentials secret openshift-machine-api/missing-kubeconfig doesn't exist",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			machine := &machinev1.Machine{}
+			machine.Name = "machine-test"
+			machine.Namespace = "openshift-machine-api"
+			machine.Annotations = tc.annotations
+			machine.Spec.ProviderSpec.Value = providerSpec
+			spec := kubevirtproviderv1.KubevirtProviderConfigSpec{CredentialsOverrideSecrets: tc.allowed}
+
+			err := validator.validateCredentialsOverride(context.Background(), machine, spec)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialsOverrideUpdate(t *testing.T) {
+	providerID := "kubevirt://cluster-test/machine-test"
+	cases := []struct {
+		name           string
+		providerID     *string
+		oldAnnotations map[string]string
+		annotations    map[string]string
+		wantErr        string
+	}{
+		{
+			name:        "Override before the VM is created",
+			annotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"},
+		},
+		{
+			name:           "Unchanged override",
+			providerID:     &providerID,
+			oldAnnotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"},
+			annotations:    map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig", "other": "annotation"},
+		},
+		{
+			name:        "Override added",
+			providerID:  &providerID,
+			annotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"},
+			wantErr:     "the kubevirt.io/underkube-credentials-secret annotation can't change once the machine has a provider ID",
+		},
+		{
+			name:           "Override changed",
+			providerID:     &providerID,
+			oldAnnotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"},
+			annotations:    map[string]string{providerconfig.CredentialsSecretAnnotation: "other-infra-kubeconfig"},
+			wantErr:        "the kubevirt.io/underkube-credentials-secret annotation can't change once the machine has a provider ID",
+		},
+		{
+			name:           "Override removed",
+			providerID:     &providerID,
+			oldAnnotations: map[string]string{providerconfig.CredentialsSecretAnnotation: "new-infra-kubeconfig"},
+			wantErr:        "the kubevirt.io/underkube-credentials-secret annotation can't change once the machine has a provider ID",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			oldMachine := &machinev1.Machine{}
+			oldMachine.Annotations = tc.oldAnnotations
+			oldMachine.Spec.ProviderID = tc.providerID
+			machine := oldMachine.DeepCopy()
+			machine.Annotations = tc.annotations
+
+			err := validateCredentialsOverrideUpdate(oldMachine, machine)
+			if tc.wantErr != "" {
+				assert.Error(t, err, tc.wantErr)
+			} else {
+				assert.NilError(t, err)
+			}
+		})
+	}
+}